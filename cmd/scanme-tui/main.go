@@ -0,0 +1,164 @@
+// Command scanme-tui SYN scans hosts while displaying the progress of every
+// scan, the open ports found, the send rate and bandwidth and the log in the
+// terminal.
+//
+//	sudo scanme-tui -ip 192.168.1.10,192.168.1.20 -ports 1-1024 -rate 500
+//
+// Press CTRL+C to stop: the results found so far are written to the -o file
+// before exiting.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/CyberRoute/scanme/scanme"
+	"github.com/CyberRoute/scanme/scanme/config"
+	"github.com/google/gopacket/routing"
+)
+
+var (
+	targetIPs = flag.String("ip", "", "Comma-separated IP addresses and CIDR blocks to scan.")
+	ports     = flag.String("ports", "", "Ports to scan, e.g. 22,80,8000-8100. Defaults to all ports.")
+	rateLimit = flag.Int("rate", 0, "Maximum number of packets sent per second per host, 0 for no limit.")
+	output    = flag.String("o", "scanme-results.json", "JSON file the results are written to.")
+	refresh   = flag.Duration("refresh", 250*time.Millisecond, "Interval between screen updates.")
+)
+
+func main() {
+	flag.Parse()
+	if *targetIPs == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	cfg := config.Default()
+	cfg.Targets = strings.Split(*targetIPs, ",")
+	cfg.Ports = *ports
+	cfg.RateLimit = *rateLimit
+	if err := cfg.Validate(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	targets, err := cfg.TargetIPs()
+	if err != nil {
+		log.Fatal(err)
+	}
+	options, err := cfg.ScanOptions()
+	if err != nil {
+		log.Fatal(err)
+	}
+	router, err := routing.New()
+	if err != nil {
+		log.Fatal("Routing error:", err)
+	}
+
+	v := newView(targets)
+	// The log goes to the status panel rather than over the screen.
+	log.SetOutput(v.log)
+	log.SetFlags(log.Ltime)
+
+	events := make(chan scanme.ScanEvent, 4096)
+	options = append(options, scanme.WithEvents(events), scanme.WithBandwidthMonitor())
+
+	var mu sync.Mutex
+	var results []*scanme.ScanResult
+	var scanners []scanme.Scanner
+	var wg sync.WaitGroup
+	for _, ip := range targets {
+		scanner, err := scanme.NewScanner(ip, router, options...)
+		if err != nil {
+			log.Printf("Unable to create scanner for %v: %v", ip, err)
+			v.fail(ip, err)
+			continue
+		}
+		scanners = append(scanners, scanner)
+		if m, ok := scanner.(bandwidthMeter); ok {
+			v.meters = append(v.meters, m)
+		}
+
+		wg.Add(1)
+		go func(ip net.IP, scanner scanme.Scanner) {
+			defer wg.Done()
+			result, err := scanner.Synscan()
+			if err != nil {
+				log.Printf("Scan of %v: %v", ip, err)
+				v.fail(ip, err)
+			}
+			if result != nil {
+				mu.Lock()
+				results = append(results, result)
+				mu.Unlock()
+			}
+		}(ip, scanner)
+	}
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	// CTRL+C stops the scans, which return the ports found so far.
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt, syscall.SIGTERM)
+
+	v.open()
+	ticker := time.NewTicker(*refresh)
+loop:
+	for {
+		select {
+		case e := <-events:
+			v.update(e)
+		case <-ticker.C:
+			v.render()
+		case <-interrupt:
+			log.Printf("Interrupted, stopping the scans")
+			signal.Stop(interrupt)
+			for _, scanner := range scanners {
+				scanner.Close()
+			}
+		case <-done:
+			break loop
+		}
+	}
+	ticker.Stop()
+	// Drain the events sent before the scans returned.
+	for len(events) > 0 {
+		v.update(<-events)
+	}
+	v.render()
+	v.close()
+
+	for _, scanner := range scanners {
+		scanner.Close()
+	}
+	if err := writeResults(*output, results); err != nil {
+		log.SetOutput(os.Stderr)
+		log.Fatalf("Unable to write results: %v", err)
+	}
+	fmt.Printf("%d results written to %s\n", len(results), *output)
+}
+
+// writeResults writes results to the JSON file at path.
+func writeResults(path string, results []*scanme.ScanResult) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(f)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(results); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,246 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/CyberRoute/scanme/scanme"
+	"github.com/google/gopacket/layers"
+)
+
+// The screen is drawn with plain ANSI escape sequences, which every
+// terminal emulator in use today understands.
+const (
+	altScreenOn  = "\x1b[?1049h\x1b[?25l"
+	altScreenOff = "\x1b[?25h\x1b[?1049l"
+	clearScreen  = "\x1b[H\x1b[2J"
+	bold         = "\x1b[1m"
+	green        = "\x1b[32m"
+	red          = "\x1b[31m"
+	reset        = "\x1b[0m"
+
+	barWidth  = 40
+	portRows  = 15
+	logLines  = 8
+	maxErrLen = 60
+)
+
+// hostState is what the view knows about the scan of a host.
+type hostState struct {
+	ip          net.IP
+	sent, total int
+	open        []layers.TCPPort
+	done        bool
+	err         error
+}
+
+// openPort is a row of the table of open ports.
+type openPort struct {
+	ip   net.IP
+	port layers.TCPPort
+	at   time.Time
+}
+
+// view holds the state displayed on screen, updated from the events of the
+// scans.
+type view struct {
+	start time.Time
+	hosts []*hostState
+	byIP  map[string]*hostState
+	ports []openPort
+	log   *logPanel
+
+	// sent is the number of probes sent at the previous render, to compute
+	// the send rate.
+	sent     int
+	rendered time.Time
+	rate     float64
+
+	// meters measure the bandwidth of the scans.
+	meters []bandwidthMeter
+
+	mu sync.Mutex
+}
+
+// bandwidthMeter is implemented by the scanners, measuring their bandwidth
+// when created with scanme.WithBandwidthMonitor.
+type bandwidthMeter interface {
+	CurrentBandwidth() (txKBps, rxKBps float64)
+}
+
+func newView(targets []net.IP) *view {
+	v := &view{
+		start:    time.Now(),
+		byIP:     make(map[string]*hostState),
+		log:      &logPanel{},
+		rendered: time.Now(),
+	}
+	for _, ip := range targets {
+		h := &hostState{ip: ip}
+		v.hosts = append(v.hosts, h)
+		v.byIP[ip.String()] = h
+	}
+	return v
+}
+
+// open switches to the alternate screen, restored by close.
+func (v *view) open() {
+	fmt.Print(altScreenOn)
+}
+
+func (v *view) close() {
+	fmt.Print(altScreenOff)
+}
+
+// update applies e to the state of its host.
+func (v *view) update(e scanme.ScanEvent) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	h, ok := v.byIP[e.Target.String()]
+	if !ok {
+		return
+	}
+	switch e.Type {
+	case scanme.EventScanStarted:
+		h.total = e.Total
+	case scanme.EventProbeSent:
+		h.sent, h.total = e.Sent, e.Total
+	case scanme.EventPortOpen:
+		h.open = append(h.open, e.Port)
+		v.ports = append(v.ports, openPort{ip: e.Target, port: e.Port, at: e.Time})
+	case scanme.EventScanDone:
+		h.done = true
+	}
+}
+
+// fail records the error the scan of ip failed with.
+func (v *view) fail(ip net.IP, err error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if h, ok := v.byIP[ip.String()]; ok {
+		h.done, h.err = true, err
+	}
+}
+
+// render redraws the whole screen.
+func (v *view) render() {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	var sent, open int
+	for _, h := range v.hosts {
+		sent += h.sent
+		open += len(h.open)
+	}
+	now := time.Now()
+	if elapsed := now.Sub(v.rendered).Seconds(); elapsed > 0 {
+		v.rate = float64(sent-v.sent) / elapsed
+	}
+	v.sent, v.rendered = sent, now
+	var tx, rx float64
+	for _, m := range v.meters {
+		t, r := m.CurrentBandwidth()
+		tx, rx = tx+t, rx+r
+	}
+
+	var b bytes.Buffer
+	b.WriteString(clearScreen)
+	fmt.Fprintf(&b, "%sscanme%s  elapsed %v  probes %d  %.0f pkt/s  tx %.1f KB/s  rx %.1f KB/s  open ports %d\n\n",
+		bold, reset, now.Sub(v.start).Round(time.Second), sent, v.rate, tx, rx, open)
+
+	fmt.Fprintf(&b, "%sHosts%s\n", bold, reset)
+	for _, h := range v.hosts {
+		fmt.Fprintf(&b, "  %-15s %s %s\n", h.ip, progressBar(h.sent, h.total, h.done), h.status())
+	}
+
+	fmt.Fprintf(&b, "\n%sOpen ports%s\n", bold, reset)
+	fmt.Fprintf(&b, "  %-15s %-7s %-15s %s\n", "HOST", "PORT", "SERVICE", "FOUND")
+	rows := v.ports
+	if len(rows) > portRows {
+		rows = rows[len(rows)-portRows:]
+	}
+	for _, p := range rows {
+		fmt.Fprintf(&b, "  %-15s %-7d %-15s %s\n", p.ip, p.port, serviceName(p.port), p.at.Format("15:04:05"))
+	}
+	if len(v.ports) > portRows {
+		fmt.Fprintf(&b, "  ... %d more\n", len(v.ports)-portRows)
+	}
+
+	fmt.Fprintf(&b, "\n%sLog%s\n", bold, reset)
+	for _, line := range v.log.lines() {
+		fmt.Fprintf(&b, "  %s\n", line)
+	}
+	b.WriteString("\nPress CTRL+C to stop and save the results.\n")
+	os.Stdout.Write(b.Bytes())
+}
+
+// status describes the state of the scan of h.
+func (h *hostState) status() string {
+	switch {
+	case h.err != nil:
+		msg := h.err.Error()
+		if len(msg) > maxErrLen {
+			msg = msg[:maxErrLen] + "..."
+		}
+		return red + msg + reset
+	case h.done:
+		return fmt.Sprintf("%sdone%s, %d open", green, reset, len(h.open))
+	case h.total == 0:
+		return "resolving"
+	default:
+		return fmt.Sprintf("%d/%d, %d open", h.sent, h.total, len(h.open))
+	}
+}
+
+// progressBar draws the progress of sent probes out of total.
+func progressBar(sent, total int, done bool) string {
+	filled := 0
+	if done {
+		filled = barWidth
+	} else if total > 0 {
+		filled = sent * barWidth / total
+	}
+	return "[" + strings.Repeat("#", filled) + strings.Repeat(".", barWidth-filled) + "]"
+}
+
+// serviceName returns the service commonly found on port.
+func serviceName(port layers.TCPPort) string {
+	name := port.String()
+	if i := strings.IndexByte(name, '('); i >= 0 {
+		return strings.TrimSuffix(name[i+1:], ")")
+	}
+	return ""
+}
+
+// logPanel keeps the last lines written to the log, for the status panel.
+type logPanel struct {
+	mu   sync.Mutex
+	buf  []string
+	tail string
+}
+
+// Write implements io.Writer.
+func (l *logPanel) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	text := l.tail + string(p)
+	parts := strings.Split(text, "\n")
+	l.tail = parts[len(parts)-1]
+	l.buf = append(l.buf, parts[:len(parts)-1]...)
+	if len(l.buf) > logLines {
+		l.buf = l.buf[len(l.buf)-logLines:]
+	}
+	return len(p), nil
+}
+
+// lines returns the last lines of the log, oldest first.
+func (l *logPanel) lines() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]string(nil), l.buf...)
+}
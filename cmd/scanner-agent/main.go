@@ -0,0 +1,123 @@
+// Command scanner-agent runs the scanme gRPC service, or calls it.
+//
+//	scanner-agent serve -listen :50051 -max-scans 4
+//	scanner-agent scan -server scanner.example.com:50051 -ip 192.168.1.10 -ports 1-1024
+//
+// Connections are not encrypted: run the service on a trusted network or
+// behind a TLS terminating proxy.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	scanmegrpc "github.com/CyberRoute/scanme/scanme/grpc"
+	"github.com/CyberRoute/scanme/scanme/grpc/scannerpb"
+	"github.com/google/gopacket/routing"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+	}
+	switch os.Args[1] {
+	case "serve":
+		serve(os.Args[2:])
+	case "scan":
+		scan(os.Args[2:])
+	default:
+		usage()
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: scanner-agent serve|scan [flags]")
+	os.Exit(2)
+}
+
+func serve(args []string) {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	listen := fs.String("listen", ":50051", "Address to listen on.")
+	maxScans := fs.Int("max-scans", 4, "Maximum number of scans running at once.")
+	fs.Parse(args)
+
+	router, err := routing.New()
+	if err != nil {
+		log.Fatal("Routing error:", err)
+	}
+	lis, err := net.Listen("tcp", *listen)
+	if err != nil {
+		log.Fatal(err)
+	}
+	srv := grpc.NewServer()
+	scannerpb.RegisterScannerServiceServer(srv, scanmegrpc.NewServer(*maxScans, router))
+	log.Printf("serving scans on %v", lis.Addr())
+	log.Fatal(srv.Serve(lis))
+}
+
+func scan(args []string) {
+	fs := flag.NewFlagSet("scan", flag.ExitOnError)
+	server := fs.String("server", "localhost:50051", "Address of the scanner service.")
+	targetIP := fs.String("ip", "", "IP address to scan.")
+	ports := fs.String("ports", "", "Ports to scan, e.g. 22,80,8000-8100. Defaults to all ports.")
+	connect := fs.Bool("connect", false, "Run a connect scan instead of a SYN scan.")
+	options := fs.String("options", "", "Scan options, e.g. rate_limit=1000,random_order=true.")
+	timeout := fs.Duration("timeout", 10*time.Minute, "Maximum duration of the scan.")
+	fs.Parse(args)
+	if *targetIP == "" {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	req := &scannerpb.ScanRequest{
+		TargetIp:  *targetIP,
+		PortRange: *ports,
+		Options:   make(map[string]string),
+	}
+	if *connect {
+		req.ScanType = scannerpb.ScanType_SCAN_TYPE_CONNECT
+	}
+	for _, opt := range strings.Split(*options, ",") {
+		if opt == "" {
+			continue
+		}
+		name, value, _ := strings.Cut(opt, "=")
+		req.Options[name] = value
+	}
+
+	conn, err := grpc.NewClient(*server, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+	stream, err := scannerpb.NewScannerServiceClient(conn).Scan(ctx, req)
+	if err != nil {
+		log.Fatal(err)
+	}
+	for {
+		event, err := stream.Recv()
+		if err == io.EOF {
+			return
+		} else if err != nil {
+			log.Fatal(err)
+		}
+		if p := event.GetPort(); p != nil {
+			log.Printf("Port %d(%s) %s", p.Port, p.Service, p.State)
+		}
+		if done := event.GetDone(); done != nil {
+			log.Printf("%d ports found in %v", len(done.Results), time.Duration(done.Stats.GetDurationNs()))
+		}
+	}
+}
@@ -47,6 +47,10 @@ func main() {
 	if err != nil {
 		log.Fatalf("Unable to create scanner for %v: %v", ip, err)
 	}
+	// SendSynTCP4 is not part of the scanme.Scanner interface.
+	sender := scanner.(interface {
+		SendSynTCP4(ip string, p layers.TCPPort) error
+	})
 
 	var wg sync.WaitGroup
 	ports := make(chan layers.TCPPort, 100) // Buffered channel to limit concurrency
@@ -55,7 +59,9 @@ func main() {
 	portScanner := func() {
 		defer wg.Done()
 		for port := range ports {
-			scanner.SendSynTCP4(targetIP, port)
+			if err := sender.SendSynTCP4(targetIP, port); err != nil {
+				log.Printf("Error scanning port %d: %v", port, err)
+			}
 		}
 	}
 
@@ -43,6 +43,10 @@ func main() {
 	if err != nil {
 		log.Fatalf("Unable to create scanner for %v: %v", ip, err)
 	}
+	// SendSynTCP6 is not part of the scanme.Scanner interface.
+	sender := scanner.(interface {
+		SendSynTCP6(ip string, p layers.TCPPort) error
+	})
 
 	var wg sync.WaitGroup
 	ports := make(chan layers.TCPPort, 100) // Buffered channel to limit concurrency
@@ -51,7 +55,9 @@ func main() {
 	portScanner := func() {
 		defer wg.Done()
 		for port := range ports {
-			scanner.SendSynTCP6(targetIP, port)
+			if err := sender.SendSynTCP6(targetIP, port); err != nil {
+				log.Printf("Error scanning port %d: %v", port, err)
+			}
 		}
 	}
 
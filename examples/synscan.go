@@ -1,38 +1,99 @@
-
 //go:build ignore
 package main
 
 import (
+	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"log"
 	"net"
 	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
 	"github.com/CyberRoute/scanme/scanme"
+	"github.com/CyberRoute/scanme/scanme/config"
+	"github.com/CyberRoute/scanme/scanme/metrics"
 	"github.com/google/gopacket/routing"
 )
 
 var (
-	targetIP = flag.String("ip", "127.0.0.1", "IP address to bind the web UI server to.")
+	configFile  = flag.String("config", "", "YAML configuration file, flags set on the command line take precedence.")
+	printConfig = flag.Bool("print-config", false, "Print a configuration file template and exit.")
+	targetIP    = flag.String("ip", "127.0.0.1", "IP address to bind the web UI server to.")
+	ports       = flag.String("ports", "", "Ports to scan, e.g. 22,80,8000-8100. Defaults to all ports.")
+	rateLimit   = flag.Int("rate", 0, "Maximum number of packets sent per second, 0 for no limit.")
+	timeout     = flag.Duration("timeout", time.Second, "Banner grabbing timeout.")
+	output      = flag.String("output", "text", "Output format: text, json or xml.")
+	tmpl        = flag.String("template", "", "Print the results with a built-in template (brief, full or csv) or the text/template file at this path instead of the -output format.")
+	baseline    = flag.String("baseline", "", "JSON results of an earlier run, written with -output json, to check the results against. Exits with status 1 on violations.")
+	strict      = flag.Bool("strict", false, "With -baseline, also report ports open in the baseline that are now closed.")
 )
 
+// drainTimeout bounds how long a scan stopped by SIGTERM waits for the
+// replies in flight.
+const drainTimeout = 5 * time.Second
+
 func main() {
 
 	flag.Parse()
-	if *targetIP == "" {
-		fmt.Println("No ip specified.")
+	if *printConfig {
+		fmt.Print(config.GenerateDefaultConfig())
+		return
+	}
+
+	cfg := config.Default()
+	if *configFile != "" {
+		var err error
+		if cfg, err = config.LoadConfig(*configFile); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if len(cfg.Targets) == 0 {
+		cfg.Targets = []string{*targetIP}
+	}
+	// Flags given on the command line override the configuration file.
+	flag.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "ip":
+			cfg.Targets = []string{*targetIP}
+		case "ports":
+			cfg.Ports = *ports
+		case "rate":
+			cfg.RateLimit = *rateLimit
+		case "timeout":
+			cfg.Timeout = *timeout
+		case "output":
+			cfg.Output = *output
+		}
+	})
+	if err := cfg.Validate(); err != nil {
+		fmt.Println(err)
 		flag.Usage()
 		os.Exit(1)
 	}
-	targetIP := *targetIP
 
-	ip := net.ParseIP(targetIP)
-	if ip == nil {
-		log.Fatalf("Invalid IP address: %q", targetIP)
-	} else if ip = ip.To4(); ip == nil {
-		log.Fatalf("Non-IPv4 address provided: %q", targetIP)
+	targets, err := cfg.TargetIPs()
+	if err != nil {
+		log.Fatal(err)
+	}
+	options, err := cfg.ScanOptions()
+	if err != nil {
+		log.Fatal(err)
+	}
+	var baselines map[string]*scanme.ScanResult
+	if *baseline != "" {
+		if baselines, err = loadBaseline(*baseline); err != nil {
+			log.Fatalf("Unable to load baseline: %v", err)
+		}
+	}
+	if cfg.MetricsAddr != "" {
+		go func() {
+			log.Fatal(metrics.ServeMetrics(cfg.MetricsAddr))
+		}()
 	}
 
 	startTime := time.Now() // Record the start time
@@ -42,34 +103,163 @@ func main() {
 		log.Fatal("Routing error:", err)
 	}
 
-	scanner, err := scanme.NewScanner(ip, router)
+	// SIGTERM stops the scan in progress, whose open ports found so far are
+	// still printed, and skips the remaining targets.
+	sigterm := make(chan os.Signal, 1)
+	signal.Notify(sigterm, syscall.SIGTERM)
+
+	violations := 0
+	for _, ip := range targets {
+		ip4 := ip.To4()
+		if ip4 == nil {
+			log.Fatalf("Non-IPv4 address provided: %q", ip)
+		}
+		result, stopped := scan(ip4, router, cfg, options, sigterm)
+		if baselines != nil {
+			violations += checkBaseline(baselines, result, *strict)
+		}
+		if stopped {
+			break
+		}
+	}
+
+	elapsedTime := time.Since(startTime)
+	log.Printf("Execution time: %s", elapsedTime)
+	if violations > 0 {
+		log.Printf("%d violations of the baseline %s", violations, *baseline)
+		os.Exit(1)
+	}
+}
+
+// scan SYN scans ip, prints the result and returns it. It reports whether
+// the scan was stopped by a signal on sigterm.
+func scan(ip net.IP, router routing.Router, cfg *config.Config, options []scanme.Option, sigterm <-chan os.Signal) (result *scanme.ScanResult, stopped bool) {
+	var scanner scanme.Scanner
+	scanner, err := scanme.NewScanner(ip, router, options...)
 	if err != nil {
 		log.Fatalf("Unable to create scanner for %v: %v", ip, err)
 	}
+	defer scanner.Close()
 
-	openPorts, err := scanner.Synscan()
-	if err != nil {
+	done := make(chan struct{})
+	go func() {
+		select {
+		case <-sigterm:
+			log.Printf("SIGTERM received, stopping the scan of %v", ip)
+			// Replies to the probes already sent still count.
+			if err := scanner.GracefulClose(drainTimeout); err != nil {
+				log.Printf("Closing scanner: %v", err)
+			}
+		case <-done:
+		}
+	}()
+	result, err = scanner.Synscan()
+	close(done)
+	if errors.Is(err, scanme.ErrScannerClosed) && result != nil {
+		stopped = true
+	} else if err != nil {
 		log.Fatalf("Unable to scan %v: %v", ip, err)
+	}
 
+	if *tmpl != "" {
+		if err := printResult(*tmpl, result); err != nil {
+			log.Fatalf("Unable to render the result of %v: %v", ip, err)
+		}
+		return result, stopped
 	}
-	// Process open ports
-	for port, service := range openPorts {
-		var banner string
-		var err error
-		banner = scanme.GrabBanner(targetIP, int(port))
-		if err != nil {
-			log.Printf("Error grabbing banner for port %d (%s): %v", port, service, err)
+
+	switch cfg.Output {
+	case "json":
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(result); err != nil {
+			log.Fatal(err)
+		}
+		return result, stopped
+	case "xml":
+		if err := scanme.WriteNmapXML(os.Stdout, result); err != nil {
+			log.Fatal(err)
+		}
+		return result, stopped
+	}
+
+	// Process open ports, without grabbing banners once stopped
+	for _, port := range result.Ports {
+		if stopped {
+			log.Printf("Port %v(%v) %v", port.Port, port.Service, port.State)
 			continue
 		}
+		banner, err := scanner.GrabBanner(port.Port, cfg.Timeout)
+		if err != nil {
+			log.Printf("Error grabbing banner for port %d (%s): %v", port.Port, port.Service, err)
+		}
 		if banner != "" {
-			log.Printf("Port %v %v Banner: %s", port, service, banner)
+			log.Printf("Port %v(%v) %v Banner: %s", port.Port, port.Service, port.State, banner)
 		} else {
-			log.Printf("Port %v %v", port, service)
+			log.Printf("Port %v(%v) %v", port.Port, port.Service, port.State)
 		}
 	}
+	return result, stopped
+}
 
-	defer scanner.Close()
+// loadBaseline reads the results written with -output json to the file at
+// path, by target.
+func loadBaseline(path string) (map[string]*scanme.ScanResult, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
 
-	elapsedTime := time.Since(startTime)
-	log.Printf("Execution time: %s", elapsedTime)
+	baselines := make(map[string]*scanme.ScanResult)
+	dec := json.NewDecoder(f)
+	for {
+		var result scanme.ScanResult
+		if err := dec.Decode(&result); err == io.EOF {
+			return baselines, nil
+		} else if err != nil {
+			return nil, fmt.Errorf("%s: %v", path, err)
+		}
+		baselines[result.Target.String()] = &result
+	}
+}
+
+// checkBaseline checks result against the baseline of the same target, logs
+// the violations found and returns their number. Targets missing from the
+// baseline are skipped.
+func checkBaseline(baselines map[string]*scanme.ScanResult, result *scanme.ScanResult, strict bool) int {
+	b, ok := baselines[result.Target.String()]
+	if !ok {
+		log.Printf("No baseline for %v", result.Target)
+		return 0
+	}
+	c := scanme.NewComparator(b)
+	if strict {
+		c.Strict()
+	}
+	violations, err := c.Check(result)
+	if err != nil {
+		log.Fatalf("Unable to check %v against the baseline: %v", result.Target, err)
+	}
+	for _, v := range violations {
+		log.Printf("%v: %v", result.Target, v)
+	}
+	return len(violations)
+}
+
+// printResult writes result to stdout rendered with the built-in template
+// name, or else the template file at that path.
+func printResult(name string, result *scanme.ScanResult) error {
+	var out string
+	var err error
+	if tmpl, ok := scanme.BuiltinTemplate(name); ok {
+		out, err = scanme.RenderTemplate(tmpl, result)
+	} else {
+		out, err = scanme.RenderTemplateFile(name, result)
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Print(out)
+	return nil
 }
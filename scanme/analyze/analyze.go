@@ -0,0 +1,154 @@
+// Package analyze replays packet captures of SYN scans through the
+// scanner's classification logic, to reproduce findings offline or to test
+// without a network.
+package analyze
+
+import (
+	"errors"
+	"io"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/CyberRoute/scanme/scanme"
+	"github.com/CyberRoute/scanme/utils"
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"github.com/google/gopacket/pcap"
+)
+
+// ErrNoProbes is returned by AnalyzePCAP when the capture holds no SYN sent
+// from the given local address and port.
+var ErrNoProbes = errors.New("no SYN probes from the local address in the capture")
+
+// AnalyzePCAP reads the SYN scan captured in the pcap file at path, made from
+// localIP:localPort, and returns the ScanResult it shows. The target is the
+// destination of the first SYN probe in the capture, and every reply is
+// classified with scanme.ClassifyReply, like the Synscan receive loop does.
+//
+// Unlike a live Synscan, which only reports open ports, every probed port is
+// part of the result: "open" or "closed" when it answered with a SYN-ACK or a
+// RST, "filtered" otherwise. Filtering the result with
+// scanme.FilterOpenPorts() gives what the live scan would have returned.
+func AnalyzePCAP(path string, localIP net.IP, localPort layers.TCPPort) (*scanme.ScanResult, error) {
+	handle, err := pcap.OpenOffline(path)
+	if err != nil {
+		return nil, err
+	}
+	defer handle.Close()
+
+	var eth layers.Ethernet
+	var dot1q layers.Dot1Q
+	var ip4 layers.IPv4
+	var tcp layers.TCP
+	parser := gopacket.NewDecodingLayerParser(layers.LayerTypeEthernet, &eth, &dot1q, &ip4, &tcp)
+	parser.IgnoreUnsupported = true
+	decoded := []gopacket.LayerType{}
+
+	result := &scanme.ScanResult{}
+	sentAt := make(map[layers.TCPPort]time.Time)
+	states := make(map[layers.TCPPort]string)
+	latencies := make(map[layers.TCPPort]time.Duration)
+	for {
+		data, ci, err := handle.ReadPacketData()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		if result.StartTime.IsZero() {
+			result.StartTime = ci.Timestamp
+		}
+		result.EndTime = ci.Timestamp
+
+		//nolint:staticcheck // SA9003 ignore this!
+		if err := parser.DecodeLayers(data, &decoded); err != nil {
+			// Errors here are due to the decoder, and not all layers are implemented.
+		}
+		isTCP := len(decoded) > 0 && decoded[len(decoded)-1] == layers.LayerTypeTCP
+		if isTCP && ip4.SrcIP.Equal(localIP) && tcp.SrcPort == localPort && tcp.SYN && !tcp.ACK {
+			if result.Target == nil {
+				result.Target = append(net.IP(nil), ip4.DstIP...)
+			}
+			if ip4.DstIP.Equal(result.Target) {
+				result.Stats.PacketsSent++
+				if _, ok := sentAt[tcp.DstPort]; !ok {
+					sentAt[tcp.DstPort] = ci.Timestamp
+				}
+			}
+			continue
+		}
+		if result.Target == nil {
+			continue
+		}
+
+		port, state, ok := scanme.ClassifyReply(data, result.Target, localIP, localPort)
+		if !ok {
+			continue
+		}
+		result.Stats.PacketsReceived++
+		// A SYN-ACK wins over anything else received for the same port.
+		if states[port] == "open" {
+			continue
+		}
+		states[port] = state
+		if sent, probed := sentAt[port]; probed && state == "open" {
+			latencies[port] = ci.Timestamp.Sub(sent)
+		}
+	}
+	if result.Target == nil {
+		return nil, ErrNoProbes
+	}
+
+	for port := range sentAt {
+		if states[port] == "" {
+			states[port] = "filtered"
+		}
+	}
+	for port, state := range states {
+		result.Ports = append(result.Ports, scanme.PortResult{
+			Port:    port,
+			State:   state,
+			Service: serviceName(port),
+			Latency: latencies[port],
+		})
+	}
+	sort.Slice(result.Ports, func(i, j int) bool { return result.Ports[i].Port < result.Ports[j].Port })
+	result.Stats.Duration = result.EndTime.Sub(result.StartTime)
+	setLatencyStats(result)
+	return result, nil
+}
+
+// serviceName returns the IANA name of the TCP service on port, like the
+// scanner does.
+func serviceName(port layers.TCPPort) string {
+	name, err := utils.GetServiceName(strconv.Itoa(int(port)), "tcp")
+	if err != nil {
+		return ""
+	}
+	return strings.Trim(name, "()")
+}
+
+// setLatencyStats computes the latency stats of result from its ports.
+func setLatencyStats(result *scanme.ScanResult) {
+	var total time.Duration
+	var n int
+	for _, p := range result.Ports {
+		if p.Latency == 0 {
+			continue
+		}
+		if n == 0 || p.Latency < result.Stats.MinLatency {
+			result.Stats.MinLatency = p.Latency
+		}
+		if p.Latency > result.Stats.MaxLatency {
+			result.Stats.MaxLatency = p.Latency
+		}
+		total += p.Latency
+		n++
+	}
+	if n > 0 {
+		result.Stats.MeanLatency = total / time.Duration(n)
+	}
+}
@@ -0,0 +1,113 @@
+package analyze
+
+import (
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/CyberRoute/scanme/scanme"
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"github.com/google/gopacket/pcapgo"
+)
+
+var (
+	testLocal  = net.IPv4(192, 0, 2, 1)
+	testTarget = net.IPv4(192, 0, 2, 10)
+)
+
+const testLocalPort = 54321
+
+// testdata/synscan.pcap holds a SYN scan of 192.0.2.10 from 192.0.2.1:54321
+// probing ports 21, 22, 23, 25, 80, 443 and 8080, 25 and 21 being probed
+// again after 500ms and 1s. The target answers with a SYN-ACK on 22 and 80
+// and a RST on 23 and 443, a router sends an ICMP administratively
+// prohibited for 8080 and 21 and 25 stay silent. The capture also holds a
+// SYN-ACK from another host, a RST to another local port, and a RST on 22
+// following its SYN-ACK.
+func TestAnalyzePCAP(t *testing.T) {
+	result, err := AnalyzePCAP("testdata/synscan.pcap", testLocal, testLocalPort)
+	if err != nil {
+		t.Fatalf("AnalyzePCAP() error = %v", err)
+	}
+	if !result.Target.Equal(testTarget) {
+		t.Errorf("Target = %v, want %v", result.Target, testTarget)
+	}
+
+	want := []struct {
+		port    layers.TCPPort
+		state   string
+		latency time.Duration
+	}{
+		{21, "filtered", 0},
+		{22, "open", 2 * time.Millisecond},
+		{23, "closed", 0},
+		{25, "filtered", 0},
+		{80, "open", 3 * time.Millisecond},
+		{443, "closed", 0},
+		{8080, "filtered", 0},
+	}
+	if len(result.Ports) != len(want) {
+		t.Fatalf("Ports = %+v, want %d ports", result.Ports, len(want))
+	}
+	for i, w := range want {
+		p := result.Ports[i]
+		if p.Port != w.port || p.State != w.state || p.Latency != w.latency {
+			t.Errorf("Ports[%d] = %d %s %v, want %d %s %v", i, p.Port, p.State, p.Latency, w.port, w.state, w.latency)
+		}
+	}
+
+	stats := result.Stats
+	if stats.PacketsSent != 9 || stats.PacketsReceived != 6 {
+		t.Errorf("%d packets sent, %d received, want 9 and 6", stats.PacketsSent, stats.PacketsReceived)
+	}
+	if stats.Duration != time.Second {
+		t.Errorf("Duration = %v, want 1s", stats.Duration)
+	}
+	if stats.MinLatency != 2*time.Millisecond || stats.MaxLatency != 3*time.Millisecond || stats.MeanLatency != 2500*time.Microsecond {
+		t.Errorf("latencies = %v/%v/%v, want 2ms/2.5ms/3ms", stats.MinLatency, stats.MeanLatency, stats.MaxLatency)
+	}
+
+	// A live Synscan only reports the open ports.
+	open := scanme.FilterOpenPorts().Apply(result)
+	if len(open.Ports) != 2 || open.Ports[0].Port != 22 || open.Ports[1].Port != 80 {
+		t.Errorf("open ports = %+v, want 22 and 80", open.Ports)
+	}
+}
+
+// TestAnalyzePCAPOtherSource checks that the probes must come from the given
+// local address and port.
+func TestAnalyzePCAPOtherSource(t *testing.T) {
+	if _, err := AnalyzePCAP("testdata/synscan.pcap", testLocal, testLocalPort+1); !errors.Is(err, ErrNoProbes) {
+		t.Errorf("other local port: error = %v, want ErrNoProbes", err)
+	}
+	if _, err := AnalyzePCAP("testdata/synscan.pcap", net.IPv4(192, 0, 2, 2), testLocalPort); !errors.Is(err, ErrNoProbes) {
+		t.Errorf("other local address: error = %v, want ErrNoProbes", err)
+	}
+}
+
+func TestAnalyzePCAPEmpty(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "empty.pcap")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := pcapgo.NewWriter(f)
+	if err := w.WriteFileHeader(65535, layers.LinkTypeEthernet); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.WritePacket(gopacket.CaptureInfo{Timestamp: time.Unix(1, 0)}, nil); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	if _, err := AnalyzePCAP(path, testLocal, testLocalPort); !errors.Is(err, ErrNoProbes) {
+		t.Errorf("error = %v, want ErrNoProbes", err)
+	}
+	if _, err := AnalyzePCAP(filepath.Join(t.TempDir(), "missing.pcap"), testLocal, testLocalPort); err == nil {
+		t.Error("missing file: error = nil")
+	}
+}
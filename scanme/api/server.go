@@ -0,0 +1,540 @@
+// Package api serves scans over a REST API, so that scanme can run as a
+// daemon triggered by automation tools over HTTP:
+//
+//	POST   /scan             {"target", "port_range", "scan_type"}  starts a scan, returns its ID
+//	GET    /scan/{id}        the status of the scan and, once done, its ScanResult
+//	GET    /scan/{id}/stream the open ports as they are found, as server-sent events
+//	DELETE /scan/{id}        cancels the scan
+//	GET    /openapi.yaml     the OpenAPI 3.0 description of the API
+//
+// Requests carry the key set with WithAPIKey as a Bearer token.
+package api
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	_ "embed"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/CyberRoute/scanme/scanme"
+	"github.com/CyberRoute/scanme/scanme/config"
+	"github.com/google/gopacket/layers"
+	"github.com/google/gopacket/routing"
+)
+
+// OpenAPISpec is the OpenAPI 3.0 description of the API, served on
+// /openapi.yaml.
+//
+//go:embed openapi.yaml
+var OpenAPISpec []byte
+
+const (
+	// defaultMaxScans is the number of scans run at once unless
+	// WithMaxConcurrentScans says otherwise.
+	defaultMaxScans = 4
+	// retention is how long finished scans can be fetched.
+	retention = time.Hour
+)
+
+// Scan states, as reported by GET /scan/{id}.
+const (
+	StatusRunning  = "running"
+	StatusDone     = "done"
+	StatusFailed   = "failed"
+	StatusCanceled = "canceled"
+)
+
+// Option configures an APIServer.
+type Option func(*APIServer)
+
+// WithAPIKey requires requests to carry key as a Bearer token in their
+// Authorization header. Without it, the API is open to anyone who can reach
+// it.
+func WithAPIKey(key string) Option {
+	return func(s *APIServer) {
+		s.apiKey = key
+	}
+}
+
+// WithLogger sets the logger of the server, slog.Default by default.
+func WithLogger(l *slog.Logger) Option {
+	return func(s *APIServer) {
+		if l != nil {
+			s.logger = l
+		}
+	}
+}
+
+// WithMaxConcurrentScans sets the number of scans run at once, 4 by
+// default. Scans started beyond it are rejected with 429 Too Many Requests.
+func WithMaxConcurrentScans(n int) Option {
+	return func(s *APIServer) {
+		if n > 0 {
+			s.maxScans = n
+		}
+	}
+}
+
+// ScanRequest is the body of POST /scan.
+type ScanRequest struct {
+	Target string `json:"target"`
+	// PortRange is the set of ports to scan, such as "22,80,8000-8100",
+	// every port when empty.
+	PortRange string `json:"port_range"`
+	// ScanType is "syn", the default, or "connect".
+	ScanType string `json:"scan_type"`
+}
+
+// PortEvent reports an open port found by a scan.
+type PortEvent struct {
+	Port    uint16 `json:"port"`
+	State   string `json:"state"`
+	Service string `json:"service,omitempty"`
+}
+
+// ScanStatus is the body of GET /scan/{id}.
+type ScanStatus struct {
+	ID        string             `json:"id"`
+	Target    string             `json:"target"`
+	ScanType  string             `json:"scan_type"`
+	Status    string             `json:"status"`
+	Error     string             `json:"error,omitempty"`
+	StartTime time.Time          `json:"start_time"`
+	Result    *scanme.ScanResult `json:"result,omitempty"`
+}
+
+// job is a scan started through the API.
+type job struct {
+	id       string
+	target   net.IP
+	scanType string
+	start    time.Time
+	cancel   context.CancelFunc
+
+	mu     sync.Mutex
+	status string
+	err    error
+	result *scanme.ScanResult
+	ended  time.Time
+	ports  []PortEvent
+	// changed is closed and replaced whenever ports or status change, to
+	// wake up the streams of the scan.
+	changed chan struct{}
+}
+
+// APIServer runs the scans requested over HTTP. It implements
+// http.Handler, see the package documentation for the endpoints.
+type APIServer struct {
+	addr     string
+	router   routing.Router
+	apiKey   string
+	maxScans int
+	logger   *slog.Logger
+	sem      chan struct{}
+	mux      *http.ServeMux
+	srv      *http.Server
+
+	mu   sync.Mutex
+	jobs map[string]*job
+}
+
+// NewAPIServer creates a server listening on addr, see ListenAndServe, and
+// routing packets with router.
+func NewAPIServer(addr string, router routing.Router, opts ...Option) *APIServer {
+	s := &APIServer{
+		addr:     addr,
+		router:   router,
+		maxScans: defaultMaxScans,
+		logger:   slog.Default(),
+		mux:      http.NewServeMux(),
+		jobs:     make(map[string]*job),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	s.sem = make(chan struct{}, s.maxScans)
+
+	s.mux.HandleFunc("/scan", s.handleScan)
+	s.mux.HandleFunc("/scan/", s.handleScanID)
+	s.mux.HandleFunc("/openapi.yaml", handleSpec)
+	s.srv = &http.Server{Addr: addr, Handler: s}
+	return s
+}
+
+// ListenAndServe serves the API on the address given to NewAPIServer until
+// Shutdown is called.
+func (s *APIServer) ListenAndServe() error {
+	return s.srv.ListenAndServe()
+}
+
+// Shutdown stops accepting requests, cancels the running scans and waits
+// for the open requests to complete, or ctx to be done.
+func (s *APIServer) Shutdown(ctx context.Context) error {
+	s.mu.Lock()
+	for _, j := range s.jobs {
+		j.cancel()
+	}
+	s.mu.Unlock()
+	return s.srv.Shutdown(ctx)
+}
+
+// ServeHTTP implements http.Handler.
+func (s *APIServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/openapi.yaml" && !s.authorized(r) {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="scanme"`)
+		writeError(w, http.StatusUnauthorized, "invalid or missing API key")
+		return
+	}
+	s.mux.ServeHTTP(w, r)
+}
+
+// authorized reports whether r carries the API key, if one is set.
+func (s *APIServer) authorized(r *http.Request) bool {
+	if s.apiKey == "" {
+		return true
+	}
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return ok && subtle.ConstantTimeCompare([]byte(token), []byte(s.apiKey)) == 1
+}
+
+// handleScan starts the scan described by the body of a POST request.
+func (s *APIServer) handleScan(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	var req ScanRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid request: %v", err))
+		return
+	}
+	ip := net.ParseIP(req.Target)
+	if ip == nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid target %q", req.Target))
+		return
+	}
+	switch req.ScanType {
+	case "", "syn":
+		req.ScanType = "syn"
+		if ip = ip.To4(); ip == nil {
+			writeError(w, http.StatusBadRequest, "SYN scans require an IPv4 target")
+			return
+		}
+	case "connect":
+	default:
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("unknown scan type %q", req.ScanType))
+		return
+	}
+	cfg := config.Default()
+	cfg.Ports = req.PortRange
+	opts, err := cfg.ScanOptions()
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	select {
+	case s.sem <- struct{}{}:
+	default:
+		writeError(w, http.StatusTooManyRequests, fmt.Sprintf("%d scans already running", s.maxScans))
+		return
+	}
+
+	id, err := newID()
+	if err != nil {
+		<-s.sem
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	j := &job{
+		id:       id,
+		target:   ip,
+		scanType: req.ScanType,
+		start:    time.Now(),
+		cancel:   cancel,
+		status:   StatusRunning,
+		changed:  make(chan struct{}),
+	}
+	s.mu.Lock()
+	s.prune()
+	s.jobs[id] = j
+	s.mu.Unlock()
+
+	go func() {
+		defer func() { <-s.sem }()
+		defer cancel()
+		s.run(ctx, j, cfg, opts)
+	}()
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Location", "/scan/"+id)
+	w.WriteHeader(http.StatusAccepted)
+	if err := json.NewEncoder(w).Encode(map[string]string{"id": id}); err != nil {
+		s.logger.Error("api: error answering scan", "id", id, "err", err)
+	}
+}
+
+// prune forgets the scans finished for longer than retention. s.mu must be
+// held.
+func (s *APIServer) prune() {
+	for id, j := range s.jobs {
+		j.mu.Lock()
+		expired := !j.ended.IsZero() && time.Since(j.ended) > retention
+		j.mu.Unlock()
+		if expired {
+			delete(s.jobs, id)
+		}
+	}
+}
+
+// run runs the scan of j until it completes or ctx is done.
+func (s *APIServer) run(ctx context.Context, j *job, cfg *config.Config, opts []scanme.Option) {
+	var result *scanme.ScanResult
+	var err error
+	if j.scanType == "connect" {
+		result, err = s.connScan(ctx, j, cfg)
+	} else {
+		result, err = s.synScan(ctx, j, opts)
+	}
+
+	status := StatusDone
+	switch {
+	case ctx.Err() != nil:
+		status = StatusCanceled
+	case err != nil:
+		status = StatusFailed
+		s.logger.Error("api: scan failed", "id", j.id, "target", j.target, "err", err)
+	}
+	j.finish(status, result, err)
+}
+
+// synScan runs a SYN scan, reporting open ports to j as they are found.
+func (s *APIServer) synScan(ctx context.Context, j *job, opts []scanme.Option) (*scanme.ScanResult, error) {
+	events := make(chan scanme.ScanEvent, 1024)
+	scanner, err := scanme.NewScanner(j.target, s.router, append(opts, scanme.WithEvents(events))...)
+	if err != nil {
+		return nil, err
+	}
+	defer scanner.Close()
+
+	// Synscan has no context: closing the scanner stops it.
+	stop := context.AfterFunc(ctx, scanner.Close)
+	defer stop()
+
+	forwarded := make(chan struct{})
+	go func() {
+		defer close(forwarded)
+		for e := range events {
+			if e.Type == scanme.EventPortOpen {
+				j.addPort(PortEvent{Port: uint16(e.Port), State: "open", Service: serviceName(e.Port)})
+			}
+		}
+	}()
+	result, err := scanner.Synscan()
+	close(events)
+	<-forwarded
+	return result, err
+}
+
+// connScan runs a connect scan, which always probes every port, and keeps
+// the ports of the requested range. It cannot be stopped: once canceled,
+// its result is discarded.
+func (s *APIServer) connScan(ctx context.Context, j *job, cfg *config.Config) (*scanme.ScanResult, error) {
+	ports, err := scanme.ParsePorts(cfg.Ports)
+	if err != nil {
+		return nil, err
+	}
+	wanted := make(map[layers.TCPPort]bool, len(ports))
+	for _, p := range ports {
+		wanted[p] = true
+	}
+
+	scanner, err := scanme.NewScanner(j.target, s.router)
+	if err != nil {
+		return nil, err
+	}
+	defer scanner.Close()
+
+	open, err := scanner.ConnScan()
+	if err != nil {
+		return nil, err
+	}
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	result := &scanme.ScanResult{Target: j.target, StartTime: j.start, EndTime: time.Now()}
+	for port, state := range open {
+		if len(wanted) > 0 && !wanted[port] {
+			continue
+		}
+		// ConnScan reports states as "<service> open".
+		service := strings.TrimSpace(strings.TrimSuffix(state, "open"))
+		result.Ports = append(result.Ports, scanme.PortResult{Port: port, State: "open", Service: service})
+	}
+	sort.Slice(result.Ports, func(i, k int) bool { return result.Ports[i].Port < result.Ports[k].Port })
+	result.Stats.Duration = result.EndTime.Sub(result.StartTime)
+	for _, p := range result.Ports {
+		j.addPort(PortEvent{Port: uint16(p.Port), State: p.State, Service: p.Service})
+	}
+	return result, nil
+}
+
+// serviceName returns the service commonly found on port.
+func serviceName(port layers.TCPPort) string {
+	name := port.String()
+	if i := strings.IndexByte(name, '('); i >= 0 {
+		return strings.TrimSuffix(name[i+1:], ")")
+	}
+	return ""
+}
+
+// handleScanID serves the requests on /scan/{id} and /scan/{id}/stream.
+func (s *APIServer) handleScanID(w http.ResponseWriter, r *http.Request) {
+	id, sub, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/scan/"), "/")
+	s.mu.Lock()
+	j, ok := s.jobs[id]
+	s.mu.Unlock()
+	if !ok {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("unknown scan %q", id))
+		return
+	}
+
+	switch {
+	case sub == "" && r.Method == http.MethodGet:
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(j.scanStatus()); err != nil {
+			s.logger.Error("api: error sending scan", "id", id, "err", err)
+		}
+	case sub == "" && r.Method == http.MethodDelete:
+		j.cancel()
+		w.WriteHeader(http.StatusNoContent)
+	case sub == "stream" && r.Method == http.MethodGet:
+		s.stream(w, r, j)
+	case sub == "" || sub == "stream":
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+	default:
+		writeError(w, http.StatusNotFound, "not found")
+	}
+}
+
+// stream sends the open ports of j as server-sent "port" events, those
+// found so far first, then a "done" event carrying the final status of the
+// scan.
+func (s *APIServer) stream(w http.ResponseWriter, r *http.Request, j *job) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, "streaming unsupported")
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+
+	var sent int
+	for {
+		j.mu.Lock()
+		ports := j.ports[sent:]
+		finished := j.status != StatusRunning
+		changed := j.changed
+		j.mu.Unlock()
+
+		for _, p := range ports {
+			if err := writeEvent(w, "port", p); err != nil {
+				return
+			}
+		}
+		sent += len(ports)
+		if finished {
+			writeEvent(w, "done", j.scanStatus())
+			flusher.Flush()
+			return
+		}
+		flusher.Flush()
+
+		select {
+		case <-changed:
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// writeEvent writes v as the JSON data of a server-sent event.
+func writeEvent(w http.ResponseWriter, event string, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, data)
+	return err
+}
+
+func handleSpec(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/yaml")
+	w.Write(OpenAPISpec)
+}
+
+// addPort records an open port and wakes up the streams of j.
+func (j *job) addPort(p PortEvent) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.ports = append(j.ports, p)
+	close(j.changed)
+	j.changed = make(chan struct{})
+}
+
+// finish records the outcome of the scan and wakes up the streams of j.
+func (j *job) finish(status string, result *scanme.ScanResult, err error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.status, j.result, j.err = status, result, err
+	j.ended = time.Now()
+	close(j.changed)
+	j.changed = make(chan struct{})
+}
+
+// scanStatus returns the current status of j.
+func (j *job) scanStatus() ScanStatus {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	st := ScanStatus{
+		ID:        j.id,
+		Target:    j.target.String(),
+		ScanType:  j.scanType,
+		Status:    j.status,
+		StartTime: j.start,
+		Result:    j.result,
+	}
+	if j.err != nil && !errors.Is(j.err, scanme.ErrScannerClosed) {
+		st.Error = j.err.Error()
+	}
+	return st
+}
+
+// newID returns a random scan ID.
+func newID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("error generating scan ID: %v", err)
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// writeError answers with status and a JSON body describing the error.
+func writeError(w http.ResponseWriter, status int, msg string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": msg})
+}
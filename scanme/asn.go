@@ -0,0 +1,23 @@
+package scanme
+
+import (
+	"log/slog"
+	"net"
+
+	"github.com/CyberRoute/scanme/scanme/intel"
+)
+
+// lookupASN looks up the autonomous system announcing ip in the background,
+// so that the lookup overlaps with the scan. The returned channel receives
+// the result, nil if the lookup failed.
+func lookupASN(ip net.IP, logger *slog.Logger) <-chan *intel.ASNInfo {
+	ch := make(chan *intel.ASNInfo, 1)
+	go func() {
+		info, err := intel.LookupASN(ip)
+		if err != nil {
+			logger.Error("ASN lookup failed", "ip", ip, "err", err)
+		}
+		ch <- info
+	}()
+	return ch
+}
@@ -0,0 +1,130 @@
+package scanme
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// zoneTransferTimeout bounds DNSZoneTransfer when ctx has no deadline.
+const zoneTransferTimeout = 10 * time.Second
+
+// maxZoneRecords bounds the records DNSZoneTransfer reads, against servers
+// never ending the transfer.
+const maxZoneRecords = 100000
+
+// ErrZoneTransferDenied is returned by DNSZoneTransfer when the server
+// refuses to transfer the zone.
+var ErrZoneTransferDenied = errors.New("zone transfer denied")
+
+// DNSRecord is a resource record of a zone, TTL holding its time to live in
+// seconds and Value its data in zone file format, such as
+// "10 mail.example.com." for an MX record.
+type DNSRecord struct {
+	Name  string
+	Type  string
+	TTL   string
+	Value string
+}
+
+// DNSZoneTransfer requests a transfer (AXFR) of the zone domain from the DNS
+// server on TCP port 53 of the target, and returns the records of the zone.
+// Servers should only allow their secondaries to transfer zones: one that
+// does reveals every host of the zone. An ErrZoneTransferDenied is returned
+// when the server refuses.
+func (s *scanner) DNSZoneTransfer(ctx context.Context, domain string) ([]DNSRecord, error) {
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, zoneTransferTimeout)
+		defer cancel()
+	}
+	conn, err := dialICS(ctx, s.dst, "53")
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	return readZone(conn, domain)
+}
+
+// readZone requests the transfer of the zone domain on conn, a TCP
+// connection to a DNS server, and reads the records of the zone.
+func readZone(conn net.Conn, domain string) ([]DNSRecord, error) {
+	dc := &dns.Conn{Conn: conn}
+	q := new(dns.Msg)
+	q.SetAxfr(dns.Fqdn(domain))
+	if err := dc.WriteMsg(q); err != nil {
+		return nil, err
+	}
+
+	var records []DNSRecord
+	soas := 0
+	for soas < 2 {
+		in, err := dc.ReadMsg()
+		if err != nil {
+			if errors.Is(err, io.EOF) && records == nil {
+				// Some servers close the connection instead of refusing.
+				return nil, ErrZoneTransferDenied
+			}
+			return nil, err
+		}
+		if in.Id != q.Id {
+			return nil, dns.ErrId
+		}
+		if records == nil {
+			if in.Rcode != dns.RcodeSuccess {
+				return nil, fmt.Errorf("%w: %s", ErrZoneTransferDenied, dns.RcodeToString[in.Rcode])
+			}
+			if len(in.Answer) == 0 || in.Answer[0].Header().Rrtype != dns.TypeSOA {
+				return nil, ErrZoneTransferDenied
+			}
+		}
+		for _, rr := range in.Answer {
+			if rr.Header().Rrtype == dns.TypeSOA {
+				soas++
+				// The SOA record starting the zone ends it too.
+				if soas == 2 {
+					break
+				}
+			}
+			records = append(records, dnsRecord(rr))
+		}
+		if len(records) > maxZoneRecords {
+			return records, fmt.Errorf("zone transfer of %s: more than %d records", domain, maxZoneRecords)
+		}
+	}
+	return records, nil
+}
+
+// dnsRecord converts rr to a DNSRecord.
+func dnsRecord(rr dns.RR) DNSRecord {
+	h := rr.Header()
+	return DNSRecord{
+		Name:  h.Name,
+		Type:  dns.TypeToString[h.Rrtype],
+		TTL:   strconv.FormatUint(uint64(h.Ttl), 10),
+		Value: strings.TrimPrefix(rr.String(), h.String()),
+	}
+}
+
+// zoneTransfer attempts the transfer of the zone set with
+// WithDNSZoneTransfer from the target when TCP port 53 is open, recording
+// the records in result.
+func (s *scanner) zoneTransfer(ctx context.Context, result *ScanResult) {
+	if p, ok := result.Port(53); !ok || p.State != "open" {
+		return
+	}
+	records, err := s.DNSZoneTransfer(ctx, s.axfrDomain)
+	if err != nil {
+		s.logger.Debug("zone transfer failed", "target", s.dst, "domain", s.axfrDomain, "err", err)
+		return
+	}
+	s.logger.Warn("zone transfer allowed", "target", s.dst, "domain", s.axfrDomain, "records", len(records))
+	result.ZoneTransfer = records
+}
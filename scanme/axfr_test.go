@@ -0,0 +1,255 @@
+package scanme
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// testZone is the zone example.test, served by testAuthority.
+const testZone = `$ORIGIN example.test.
+$TTL 3600
+@	IN SOA ns1 hostmaster 2024030501 7200 900 1209600 300
+@	IN NS ns1
+@	IN MX 10 mail
+ns1	IN A 192.0.2.53
+mail	300 IN A 192.0.2.25
+www	IN AAAA 2001:db8::80
+ftp	IN CNAME www
+@	IN TXT "v=spf1 mx -all"
+`
+
+// zoneRecords returns the records of testZone, starting with its SOA.
+func zoneRecords(t *testing.T) []dns.RR {
+	t.Helper()
+	var rrs []dns.RR
+	zp := dns.NewZoneParser(strings.NewReader(testZone), "", "")
+	for rr, ok := zp.Next(); ok; rr, ok = zp.Next() {
+		rrs = append(rrs, rr)
+	}
+	if err := zp.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return rrs
+}
+
+// testAuthority serves AXFR requests on a TCP listener, answering them with
+// handle.
+func testAuthority(t *testing.T, ln net.Listener, handle func(w dns.ResponseWriter, r *dns.Msg)) {
+	t.Helper()
+	started := make(chan struct{})
+	srv := &dns.Server{
+		Listener:          ln,
+		Handler:           dns.HandlerFunc(handle),
+		NotifyStartedFunc: func() { close(started) },
+	}
+	go srv.ActivateAndServe()
+	<-started
+	t.Cleanup(func() { srv.Shutdown() })
+}
+
+// transferZone returns a handler sending the records of testZone in
+// messages of perMsg records, closed by the SOA again.
+func transferZone(t *testing.T, perMsg int) func(dns.ResponseWriter, *dns.Msg) {
+	rrs := zoneRecords(t)
+	rrs = append(rrs, rrs[0])
+	return func(w dns.ResponseWriter, r *dns.Msg) {
+		if len(r.Question) != 1 || r.Question[0].Qtype != dns.TypeAXFR || r.Question[0].Name != "example.test." {
+			m := new(dns.Msg)
+			m.SetRcode(r, dns.RcodeNotAuth)
+			w.WriteMsg(m)
+			return
+		}
+		for i := 0; i < len(rrs); i += perMsg {
+			m := new(dns.Msg)
+			m.SetReply(r)
+			m.Authoritative = true
+			m.Answer = rrs[i:min(i+perMsg, len(rrs))]
+			if err := w.WriteMsg(m); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// dialAuthority starts a server answering with handle on an ephemeral port
+// of 127.0.0.1 and returns a connection to it.
+func dialAuthority(t *testing.T, handle func(dns.ResponseWriter, *dns.Msg)) net.Conn {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	testAuthority(t, ln, handle)
+	conn, err := net.DialTimeout("tcp", ln.Addr().String(), time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+var wantZone = []DNSRecord{
+	{Name: "example.test.", Type: "SOA", TTL: "3600", Value: "ns1.example.test. hostmaster.example.test. 2024030501 7200 900 1209600 300"},
+	{Name: "example.test.", Type: "NS", TTL: "3600", Value: "ns1.example.test."},
+	{Name: "example.test.", Type: "MX", TTL: "3600", Value: "10 mail.example.test."},
+	{Name: "ns1.example.test.", Type: "A", TTL: "3600", Value: "192.0.2.53"},
+	{Name: "mail.example.test.", Type: "A", TTL: "300", Value: "192.0.2.25"},
+	{Name: "www.example.test.", Type: "AAAA", TTL: "3600", Value: "2001:db8::80"},
+	{Name: "ftp.example.test.", Type: "CNAME", TTL: "3600", Value: "www.example.test."},
+	{Name: "example.test.", Type: "TXT", TTL: "3600", Value: `"v=spf1 mx -all"`},
+}
+
+func TestReadZone(t *testing.T) {
+	// A single message, several, and one per record with the closing SOA
+	// alone in the last.
+	for _, perMsg := range []int{100, 3, 1} {
+		records, err := readZone(dialAuthority(t, transferZone(t, perMsg)), "example.test")
+		if err != nil {
+			t.Fatalf("%d records per message: readZone() error = %v", perMsg, err)
+		}
+		if len(records) != len(wantZone) {
+			t.Fatalf("%d records per message: got %d records, want %d: %+v", perMsg, len(records), len(wantZone), records)
+		}
+		for i, r := range records {
+			if r != wantZone[i] {
+				t.Errorf("%d records per message: record %d = %+v, want %+v", perMsg, i, r, wantZone[i])
+			}
+		}
+	}
+}
+
+func TestReadZoneDenied(t *testing.T) {
+	reply := func(rcode int, answer ...dns.RR) func(dns.ResponseWriter, *dns.Msg) {
+		return func(w dns.ResponseWriter, r *dns.Msg) {
+			m := new(dns.Msg)
+			m.SetRcode(r, rcode)
+			m.Answer = answer
+			w.WriteMsg(m)
+		}
+	}
+	a, err := dns.NewRR("www.example.test. 3600 IN A 192.0.2.80")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name   string
+		domain string
+		handle func(dns.ResponseWriter, *dns.Msg)
+		rcode  string
+	}{
+		{"refused", "example.test", reply(dns.RcodeRefused), "REFUSED"},
+		{"not authoritative", "example.test", reply(dns.RcodeNotAuth), "NOTAUTH"},
+		{"no records", "example.test", reply(dns.RcodeSuccess), ""},
+		{"no SOA first", "example.test", reply(dns.RcodeSuccess, a), ""},
+		{"connection closed", "example.test", func(w dns.ResponseWriter, r *dns.Msg) { w.Close() }, ""},
+		{"other zone", "example.org", transferZone(t, 100), "NOTAUTH"},
+	}
+	for _, tt := range tests {
+		records, err := readZone(dialAuthority(t, tt.handle), tt.domain)
+		if !errors.Is(err, ErrZoneTransferDenied) {
+			t.Errorf("%s: readZone() = %v, %v, want ErrZoneTransferDenied", tt.name, records, err)
+			continue
+		}
+		if !strings.Contains(err.Error(), tt.rcode) {
+			t.Errorf("%s: error %q does not name the rcode %s", tt.name, err, tt.rcode)
+		}
+	}
+}
+
+func TestReadZoneErrors(t *testing.T) {
+	rrs := zoneRecords(t)
+
+	// The server goes away in the middle of the transfer.
+	truncated := func(w dns.ResponseWriter, r *dns.Msg) {
+		m := new(dns.Msg)
+		m.SetReply(r)
+		m.Answer = rrs[:3]
+		w.WriteMsg(m)
+		w.Close()
+	}
+	if _, err := readZone(dialAuthority(t, truncated), "example.test"); err == nil || errors.Is(err, ErrZoneTransferDenied) {
+		t.Errorf("truncated transfer: error = %v, want an error other than ErrZoneTransferDenied", err)
+	}
+
+	wrongID := func(w dns.ResponseWriter, r *dns.Msg) {
+		m := new(dns.Msg)
+		m.SetReply(r)
+		m.Id = r.Id + 1
+		m.Answer = append(rrs, rrs[0])
+		w.WriteMsg(m)
+	}
+	if _, err := readZone(dialAuthority(t, wrongID), "example.test"); !errors.Is(err, dns.ErrId) {
+		t.Errorf("reply to another query: error = %v, want dns.ErrId", err)
+	}
+
+	// A server sending records without ever ending the zone.
+	endless := func(w dns.ResponseWriter, r *dns.Msg) {
+		var batch []dns.RR
+		for i := 0; i < 1000; i++ {
+			batch = append(batch, &dns.A{
+				Hdr: dns.RR_Header{Name: "host.example.test.", Rrtype: dns.TypeA, Class: dns.ClassINET, Ttl: 60},
+				A:   net.IPv4(192, 0, 2, byte(i)),
+			})
+		}
+		for answer := append([]dns.RR{rrs[0]}, batch...); ; answer = batch {
+			m := new(dns.Msg)
+			m.SetReply(r)
+			m.Answer = answer
+			if err := w.WriteMsg(m); err != nil {
+				return
+			}
+		}
+	}
+	records, err := readZone(dialAuthority(t, endless), "example.test")
+	if err == nil || !strings.Contains(err.Error(), "more than") {
+		t.Errorf("endless transfer: error = %v, want the record limit", err)
+	}
+	if len(records) <= maxZoneRecords || len(records) > maxZoneRecords+1001 {
+		t.Errorf("endless transfer: %d records read, want just over %d", len(records), maxZoneRecords)
+	}
+}
+
+// TestDNSZoneTransfer transfers testZone from a server on port 53 of
+// 127.0.0.1, where DNSZoneTransfer connects, which needs root.
+func TestDNSZoneTransfer(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:53")
+	if err != nil {
+		t.Skipf("listening on port 53: %v", err)
+	}
+	testAuthority(t, ln, transferZone(t, 3))
+	s := newTestScanner(WithDNSZoneTransfer("example.test"))
+	s.dst = net.IPv4(127, 0, 0, 1)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	records, err := s.DNSZoneTransfer(ctx, "example.test")
+	if err != nil {
+		t.Fatalf("DNSZoneTransfer() error = %v", err)
+	}
+	if len(records) != len(wantZone) {
+		t.Errorf("DNSZoneTransfer() returned %d records, want %d", len(records), len(wantZone))
+	}
+	if _, err := s.DNSZoneTransfer(ctx, "example.org"); !errors.Is(err, ErrZoneTransferDenied) {
+		t.Errorf("DNSZoneTransfer() of another zone: error = %v, want ErrZoneTransferDenied", err)
+	}
+
+	// What Synscan does with WithDNSZoneTransfer once it found port 53
+	// open, and does not when it is closed.
+	result := &ScanResult{Ports: []PortResult{{Port: 53, State: "open"}}}
+	s.zoneTransfer(ctx, result)
+	if len(result.ZoneTransfer) != len(wantZone) || result.ZoneTransfer[2] != wantZone[2] {
+		t.Errorf("ZoneTransfer = %+v, want the records of example.test", result.ZoneTransfer)
+	}
+	result = &ScanResult{Ports: []PortResult{{Port: 53, State: "closed"}}}
+	s.zoneTransfer(ctx, result)
+	if result.ZoneTransfer != nil {
+		t.Errorf("ZoneTransfer = %+v with port 53 closed, want nil", result.ZoneTransfer)
+	}
+}
@@ -0,0 +1,62 @@
+package scanme
+
+import (
+	_ "embed"
+	"encoding/json"
+
+	"github.com/google/gopacket/layers"
+)
+
+// backdoorDB lists the default ports of well-known backdoors and trojans,
+// compiled from public port references such as the SANS Internet Storm
+// Center. Several are also used by legitimate services, so an open port
+// only hints at a compromise.
+//
+//go:embed data/backdoor-ports.json
+var backdoorDB []byte
+
+// backdoorPort is an entry of backdoorDB.
+type backdoorPort struct {
+	Port layers.TCPPort `json:"port"`
+	Name string         `json:"name"`
+}
+
+// backdoorPorts returns the ports listed in backdoorDB. The list is
+// embedded, so failing to parse it is a bug.
+func backdoorPorts() []layers.TCPPort {
+	var entries []backdoorPort
+	if err := json.Unmarshal(backdoorDB, &entries); err != nil {
+		panic("scanme: invalid backdoor port list: " + err.Error())
+	}
+	ports := make([]layers.TCPPort, 0, len(entries))
+	for _, e := range entries {
+		ports = append(ports, e.Port)
+	}
+	return ports
+}
+
+// extraPorts returns the ports set with WithExtraPorts that ports does not
+// hold, in order, so that Synscan probes them after ports.
+func (s *scanner) extraPorts(ports []layers.TCPPort) []layers.TCPPort {
+	if len(s.extra) == 0 {
+		return nil
+	}
+	seen := portSet(ports)
+	var extra []layers.TCPPort
+	for _, p := range s.extra {
+		if p != 0 && !seen[p] && s.allowed(p) {
+			seen[p] = true
+			extra = append(extra, p)
+		}
+	}
+	return extra
+}
+
+// flagSuspicious sets Suspicious on the open ports of r found in suspicious.
+func (r *ScanResult) flagSuspicious(suspicious map[layers.TCPPort]bool) {
+	for i := range r.Ports {
+		if r.Ports[i].State == "open" && suspicious[r.Ports[i].Port] {
+			r.Ports[i].Suspicious = true
+		}
+	}
+}
@@ -0,0 +1,377 @@
+package scanme
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+	"unicode/utf16"
+)
+
+// bacnetPort is the port of BACnet/IP.
+const bacnetPort = 47808
+
+// bacnetTimeout is how long BACnetProbe waits for replies when ctx has no
+// deadline.
+const bacnetTimeout = 3 * time.Second
+
+// bacnetDeviceObject is the object type of BACnet devices.
+const bacnetDeviceObject = 8
+
+// bacnetNoSegmentation is the segmentation-supported value of devices
+// supporting none.
+const bacnetNoSegmentation = 3
+
+// ErrNotBACnet is returned by BACnetProbe when no BACnet device answered
+// the Who-Is request.
+var ErrNotBACnet = errors.New("no BACnet device answered")
+
+// BACnetInfo describes the BACnet device of the target.
+type BACnetInfo struct {
+	// DeviceID is the instance number of the device object.
+	DeviceID              uint32
+	MaxAPDULength         int
+	SegmentationSupported bool
+	VendorID              uint16
+	// The properties of the device object, empty when the device did not
+	// return them.
+	ObjectName  string
+	Description string
+	Location    string
+	VendorName  string
+}
+
+// ServiceHint returns the classification of the device for
+// PortResult.ServiceHint.
+func (b *BACnetInfo) ServiceHint() string {
+	if b.VendorName != "" {
+		return fmt.Sprintf("bacnet (device %d, %s)", b.DeviceID, b.VendorName)
+	}
+	return fmt.Sprintf("bacnet (device %d, vendor %d)", b.DeviceID, b.VendorID)
+}
+
+// bacnetProperties are the properties of the device object read by
+// BACnetProbe, by property identifier.
+var bacnetProperties = []struct {
+	id    byte
+	field func(*BACnetInfo) *string
+}{
+	{77, func(b *BACnetInfo) *string { return &b.ObjectName }},
+	{28, func(b *BACnetInfo) *string { return &b.Description }},
+	{58, func(b *BACnetInfo) *string { return &b.Location }},
+	{121, func(b *BACnetInfo) *string { return &b.VendorName }},
+}
+
+// bacnetRoute is the BACnet network and MAC address of a device behind a
+// BACnet router, from the source of its replies.
+type bacnetRoute struct {
+	network uint16
+	addr    []byte
+}
+
+// BACnetProbe sends a BACnet Who-Is request to UDP port 47808 of the target
+// and reads the device identifier, maximum APDU length, segmentation
+// support and vendor from the I-Am reply, then the object-name,
+// description, location and vendor-name properties of the device with
+// ReadProperty requests. Devices answering in the clear expose the building
+// systems they control; only probe devices you are authorized to audit.
+//
+// The replies are received on local port 47808 when it is free, as devices
+// often broadcast their I-Am to it, and on an ephemeral port otherwise.
+func (s *scanner) BACnetProbe(ctx context.Context) (*BACnetInfo, error) {
+	conn, err := net.ListenPacket("udp4", fmt.Sprintf(":%d", bacnetPort))
+	if err != nil {
+		if conn, err = net.ListenPacket("udp4", ""); err != nil {
+			return nil, err
+		}
+	}
+	defer conn.Close()
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(bacnetTimeout)
+	}
+	if err := conn.SetDeadline(deadline); err != nil {
+		return nil, err
+	}
+	addr := &net.UDPAddr{IP: s.dst, Port: bacnetPort}
+
+	if _, err := conn.WriteTo(bacnetWhoIs(), addr); err != nil {
+		return nil, err
+	}
+	var info *BACnetInfo
+	var route *bacnetRoute
+	buf := make([]byte, 1500)
+	for info == nil {
+		apdu, src, err := s.readBACnet(ctx, conn, buf)
+		if err != nil {
+			if isTimeout(err) {
+				return nil, ErrNotBACnet
+			}
+			return nil, err
+		}
+		info, route = parseBACnetIAm(apdu), src
+	}
+
+	// Requests are sent at once and told apart by their invoke ID, the
+	// index of their property plus one.
+	for i, p := range bacnetProperties {
+		req := bacnetReadProperty(byte(i+1), info.DeviceID, p.id, route)
+		if _, err := conn.WriteTo(req, addr); err != nil {
+			return nil, err
+		}
+	}
+	for answered := 0; answered < len(bacnetProperties); {
+		apdu, _, err := s.readBACnet(ctx, conn, buf)
+		if err != nil {
+			if isTimeout(err) {
+				break
+			}
+			return nil, err
+		}
+		id, value, ok := parseBACnetReadPropertyAck(apdu)
+		if !ok || id < 1 || int(id) > len(bacnetProperties) {
+			continue
+		}
+		*bacnetProperties[id-1].field(info) = value
+		answered++
+	}
+	return info, nil
+}
+
+// readBACnet returns the APDU of the next BACnet/IP message received from
+// the target, and the route of the device sending it, nil when on the
+// network of the target.
+func (s *scanner) readBACnet(ctx context.Context, conn net.PacketConn, buf []byte) ([]byte, *bacnetRoute, error) {
+	for {
+		if err := ctx.Err(); err != nil {
+			return nil, nil, err
+		}
+		n, from, err := conn.ReadFrom(buf)
+		if err != nil {
+			return nil, nil, err
+		}
+		if ip, ok := from.(*net.UDPAddr); !ok || !ip.IP.Equal(s.dst) {
+			continue
+		}
+		if apdu, route, ok := parseBACnetNPDU(buf[:n]); ok {
+			return apdu, route, nil
+		}
+	}
+}
+
+// isTimeout reports whether err is a network timeout.
+func isTimeout(err error) bool {
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// bacnetWhoIs returns a BACnet/IP Original-Unicast-NPDU holding a Who-Is
+// request without range, to which every device answers.
+func bacnetWhoIs() []byte {
+	return bacnetBVLC([]byte{
+		0x01, 0x00, // NPDU: version 1, no control flags
+		0x10, 0x08, // unconfirmed request: who-is
+	})
+}
+
+// bacnetReadProperty returns a BACnet/IP ReadProperty request for the
+// property prop of the device object instance, routed to route unless nil.
+func bacnetReadProperty(invokeID byte, instance uint32, prop byte, route *bacnetRoute) []byte {
+	npdu := []byte{0x01, 0x04} // version 1, expecting reply
+	if route != nil {
+		npdu[1] |= 0x20 // destination specified
+		npdu = binary.BigEndian.AppendUint16(npdu, route.network)
+		npdu = append(npdu, byte(len(route.addr)))
+		npdu = append(npdu, route.addr...)
+		npdu = append(npdu, 0xff) // hop count
+	}
+	npdu = append(npdu,
+		0x00, // confirmed request, unsegmented
+		0x05, // max APDU length accepted: 1476
+		invokeID,
+		0x0c, // service: readProperty
+		0x0c, // context tag 0, length 4: object identifier
+	)
+	npdu = binary.BigEndian.AppendUint32(npdu, bacnetDeviceObject<<22|instance&0x3fffff)
+	npdu = append(npdu, 0x19, prop) // context tag 1, length 1: property identifier
+	return bacnetBVLC(npdu)
+}
+
+// bacnetBVLC wraps npdu in an Original-Unicast-NPDU BACnet Virtual Link
+// Control header.
+func bacnetBVLC(npdu []byte) []byte {
+	msg := []byte{0x81, 0x0a, 0, 0}
+	binary.BigEndian.PutUint16(msg[2:], uint16(4+len(npdu)))
+	return append(msg, npdu...)
+}
+
+// parseBACnetNPDU returns the APDU of the BACnet/IP message msg, and the
+// source network and address of the NPDU when set by a router. ok is false
+// for network layer messages and malformed messages.
+func parseBACnetNPDU(msg []byte) (apdu []byte, route *bacnetRoute, ok bool) {
+	if len(msg) < 4 || msg[0] != 0x81 || int(binary.BigEndian.Uint16(msg[2:])) != len(msg) {
+		return nil, nil, false
+	}
+	npdu := msg[4:]
+	switch msg[1] {
+	case 0x0a, 0x0b: // original unicast and broadcast NPDU
+	case 0x04: // forwarded NPDU, after the address of the originator
+		if len(npdu) < 6 {
+			return nil, nil, false
+		}
+		npdu = npdu[6:]
+	default:
+		return nil, nil, false
+	}
+
+	if len(npdu) < 2 || npdu[0] != 0x01 || npdu[1]&0x80 != 0 {
+		return nil, nil, false
+	}
+	control, rest := npdu[1], npdu[2:]
+	hasDest := control&0x20 != 0
+	if hasDest {
+		if len(rest) < 3 || len(rest) < 3+int(rest[2]) {
+			return nil, nil, false
+		}
+		rest = rest[3+int(rest[2]):]
+	}
+	if control&0x08 != 0 {
+		if len(rest) < 3 || len(rest) < 3+int(rest[2]) {
+			return nil, nil, false
+		}
+		route = &bacnetRoute{
+			network: binary.BigEndian.Uint16(rest),
+			addr:    append([]byte(nil), rest[3:3+int(rest[2])]...),
+		}
+		rest = rest[3+int(rest[2]):]
+	}
+	if hasDest {
+		if len(rest) < 1 {
+			return nil, nil, false
+		}
+		rest = rest[1:] // hop count
+	}
+	return rest, route, len(rest) > 0
+}
+
+// parseBACnetIAm parses an I-Am request, nil when apdu is not the I-Am of
+// a device.
+func parseBACnetIAm(apdu []byte) *BACnetInfo {
+	if len(apdu) < 2 || apdu[0] != 0x10 || apdu[1] != 0x00 {
+		return nil
+	}
+	var values [4][]byte
+	rest := apdu[2:]
+	for i, want := range []byte{12, 2, 9, 2} {
+		tag, context, value, next, ok := bacnetTag(rest)
+		if !ok || context || tag != want {
+			return nil
+		}
+		values[i], rest = value, next
+	}
+	if len(values[0]) != 4 {
+		return nil
+	}
+	id := binary.BigEndian.Uint32(values[0])
+	if id>>22 != bacnetDeviceObject {
+		return nil
+	}
+	return &BACnetInfo{
+		DeviceID:              id & 0x3fffff,
+		MaxAPDULength:         int(bacnetUnsigned(values[1])),
+		SegmentationSupported: bacnetUnsigned(values[2]) != bacnetNoSegmentation,
+		VendorID:              uint16(bacnetUnsigned(values[3])),
+	}
+}
+
+// parseBACnetReadPropertyAck returns the invoke ID and the character string
+// value of an unsegmented ReadProperty acknowledgement. ok is false for
+// other APDUs, such as the errors of devices lacking the property.
+func parseBACnetReadPropertyAck(apdu []byte) (invokeID byte, value string, ok bool) {
+	if len(apdu) < 3 || apdu[0] != 0x30 || apdu[2] != 0x0c {
+		return 0, "", false
+	}
+	rest := apdu[3:]
+	// Skip the object and property identifiers, and the array index if
+	// any, up to the opening tag 3 of the value.
+	for len(rest) > 0 && rest[0] != 0x3e {
+		var ok bool
+		if _, _, _, rest, ok = bacnetTag(rest); !ok {
+			return 0, "", false
+		}
+	}
+	if len(rest) == 0 {
+		return 0, "", false
+	}
+	tag, context, v, _, ok := bacnetTag(rest[1:])
+	if !ok || context || tag != 7 || len(v) == 0 {
+		return 0, "", false
+	}
+	return apdu[1], bacnetString(v[0], v[1:]), true
+}
+
+// bacnetTag decodes the tag at the start of b and returns its number,
+// whether it is a context tag, its value and the bytes following it.
+// Opening and closing tags have no value.
+func bacnetTag(b []byte) (tag byte, context bool, value, rest []byte, ok bool) {
+	if len(b) == 0 {
+		return 0, false, nil, nil, false
+	}
+	tag, context, lvt := b[0]>>4, b[0]&0x08 != 0, b[0]&0x07
+	b = b[1:]
+	if tag == 15 {
+		if len(b) == 0 {
+			return 0, false, nil, nil, false
+		}
+		tag, b = b[0], b[1:]
+	}
+	if context && (lvt == 6 || lvt == 7) {
+		return tag, context, nil, b, true
+	}
+	length := int(lvt)
+	if lvt == 5 {
+		if len(b) == 0 {
+			return 0, false, nil, nil, false
+		}
+		length, b = int(b[0]), b[1:]
+		switch length {
+		case 254:
+			if len(b) < 2 {
+				return 0, false, nil, nil, false
+			}
+			length, b = int(binary.BigEndian.Uint16(b)), b[2:]
+		case 255:
+			if len(b) < 4 {
+				return 0, false, nil, nil, false
+			}
+			length, b = int(binary.BigEndian.Uint32(b)), b[4:]
+		}
+	}
+	if length < 0 || len(b) < length {
+		return 0, false, nil, nil, false
+	}
+	return tag, context, b[:length], b[length:], true
+}
+
+// bacnetUnsigned decodes a big-endian unsigned value of up to 4 bytes.
+func bacnetUnsigned(b []byte) uint32 {
+	var v uint32
+	for _, c := range b {
+		v = v<<8 | uint32(c)
+	}
+	return v
+}
+
+// bacnetString decodes a character string in charset, UTF-8 unless UCS-2.
+func bacnetString(charset byte, b []byte) string {
+	if charset != 4 {
+		return string(b)
+	}
+	units := make([]uint16, len(b)/2)
+	for i := range units {
+		units[i] = binary.BigEndian.Uint16(b[2*i:])
+	}
+	return string(utf16.Decode(units))
+}
@@ -0,0 +1,124 @@
+package scanme
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// bandwidthInterval is the period over which bandwidthMonitor computes the
+// current rates.
+const bandwidthInterval = time.Second
+
+// bandwidthMonitor measures the traffic of a SYN scan, set with
+// WithBandwidthMonitor: the bytes written and read are counted as they go,
+// and turned into rates in KB/s every second by the goroutine started with
+// run. A nil *bandwidthMonitor counts nothing.
+type bandwidthMonitor struct {
+	tx, rx atomic.Int64 // bytes since the last tick
+	start  time.Time
+
+	mu             sync.Mutex
+	txRate, rxRate float64
+	peakTx, peakRx float64
+	totalTx        int64 // bytes up to the last tick
+
+	stopOnce sync.Once
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+func newBandwidthMonitor() *bandwidthMonitor {
+	m := &bandwidthMonitor{
+		start: time.Now(),
+		stop:  make(chan struct{}),
+		done:  make(chan struct{}),
+	}
+	go m.run()
+	return m
+}
+
+// sent counts n bytes written.
+func (m *bandwidthMonitor) sent(n int) {
+	if m == nil {
+		return
+	}
+	m.tx.Add(int64(n))
+}
+
+// received counts n bytes read.
+func (m *bandwidthMonitor) received(n int) {
+	if m == nil {
+		return
+	}
+	m.rx.Add(int64(n))
+}
+
+// run updates the rates every second until halt is called.
+func (m *bandwidthMonitor) run() {
+	defer close(m.done)
+	ticker := time.NewTicker(bandwidthInterval)
+	defer ticker.Stop()
+	last := time.Now()
+	for {
+		select {
+		case <-m.stop:
+			return
+		case now := <-ticker.C:
+			seconds := now.Sub(last).Seconds()
+			last = now
+			txBytes := m.tx.Swap(0)
+			tx := float64(txBytes) / 1024 / seconds
+			rx := float64(m.rx.Swap(0)) / 1024 / seconds
+			m.mu.Lock()
+			m.totalTx += txBytes
+			m.txRate, m.rxRate = tx, rx
+			m.peakTx, m.peakRx = max(m.peakTx, tx), max(m.peakRx, rx)
+			m.mu.Unlock()
+		}
+	}
+}
+
+// current returns the send and receive rates of the last second, in KB/s.
+func (m *bandwidthMonitor) current() (txKBps, rxKBps float64) {
+	if m == nil {
+		return 0, 0
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.txRate, m.rxRate
+}
+
+// halt stops the goroutine updating the rates. It may be called more than
+// once.
+func (m *bandwidthMonitor) halt() {
+	if m == nil {
+		return
+	}
+	m.stopOnce.Do(func() { close(m.stop) })
+	<-m.done
+}
+
+// finish stops the monitor and records the peak and average rates in
+// stats. The average covers the whole scan, so that scans shorter than a
+// second get a peak too.
+func (m *bandwidthMonitor) finish(stats *ScanStats) {
+	if m == nil {
+		return
+	}
+	m.halt()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if seconds := time.Since(m.start).Seconds(); seconds > 0 {
+		stats.AvgTxKBps = float64(m.totalTx+m.tx.Load()) / 1024 / seconds
+	}
+	stats.PeakTxKBps = max(m.peakTx, stats.AvgTxKBps)
+	stats.PeakRxKBps = m.peakRx
+}
+
+// CurrentBandwidth returns the send and receive rates of the SYN scan in
+// progress over the last second, in KB/s, when the scanner was created with
+// WithBandwidthMonitor; zero otherwise.
+func (s *scanner) CurrentBandwidth() (txKBps, rxKBps float64) {
+	return s.monitor.Load().current()
+}
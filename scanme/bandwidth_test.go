@@ -0,0 +1,114 @@
+package scanme
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/CyberRoute/scanme/scanme/queue"
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+)
+
+func TestBandwidthMonitorFinish(t *testing.T) {
+	m := newBandwidthMonitor()
+	for i := 0; i < 100; i++ {
+		m.sent(1024)
+		m.received(64)
+	}
+	time.Sleep(10 * time.Millisecond)
+	var stats ScanStats
+	m.finish(&stats)
+
+	// 100KB were sent in a little over 10ms.
+	if stats.AvgTxKBps <= 0 || stats.AvgTxKBps > 100/0.01 {
+		t.Errorf("AvgTxKBps = %v, want within (0, 10000]", stats.AvgTxKBps)
+	}
+	// No tick happened: the peak is the average.
+	if stats.PeakTxKBps != stats.AvgTxKBps {
+		t.Errorf("PeakTxKBps = %v, want AvgTxKBps %v", stats.PeakTxKBps, stats.AvgTxKBps)
+	}
+	m.halt()
+}
+
+func TestBandwidthMonitorNil(t *testing.T) {
+	var m *bandwidthMonitor
+	m.sent(1)
+	m.received(1)
+	m.halt()
+	var stats ScanStats
+	m.finish(&stats)
+	if stats != (ScanStats{}) {
+		t.Errorf("nil monitor set %+v", stats)
+	}
+	if tx, rx := newTestScanner().CurrentBandwidth(); tx != 0 || rx != 0 {
+		t.Errorf("CurrentBandwidth() = %v, %v without monitor, want 0, 0", tx, rx)
+	}
+}
+
+// BenchmarkBandwidthMonitorOverhead compares the work done per probe by a
+// scan, serializing and queuing the probe, writing it and classifying its
+// reply, with the calls WithBandwidthMonitor adds to it. Timing whole probes
+// with and without a monitor is too noisy to tell a 1% difference, so the
+// calls of the monitor are timed on their own. The benchmark fails when
+// they cost 1% of a probe or more.
+func BenchmarkBandwidthMonitorOverhead(b *testing.B) {
+	s := newTestScanner()
+	s.opts = gopacket.SerializeOptions{FixLengths: true, ComputeChecksums: true}
+	s.queue = queue.New(func([]byte) error { return nil }, 1024, nil)
+	defer s.queue.Close()
+
+	eth := layers.Ethernet{
+		SrcMAC:       net.HardwareAddr{0, 1, 2, 3, 4, 5},
+		DstMAC:       net.HardwareAddr{0, 1, 2, 3, 4, 6},
+		EthernetType: layers.EthernetTypeIPv4,
+	}
+	ip4 := layers.IPv4{Version: 4, TTL: 64, Protocol: layers.IPProtocolTCP, SrcIP: testLocal, DstIP: testTarget}
+	tcp := layers.TCP{SrcPort: testLocalPort, DstPort: 80, Window: 1024, SYN: true}
+	if err := tcp.SetNetworkLayerForChecksum(&ip4); err != nil {
+		b.Fatal(err)
+	}
+	replyIP := layers.IPv4{Version: 4, TTL: 64, Protocol: layers.IPProtocolTCP, SrcIP: testTarget, DstIP: testLocal}
+	replyTCP := layers.TCP{SrcPort: 80, DstPort: testLocalPort, Window: 1024, SYN: true, ACK: true}
+	if err := replyTCP.SetNetworkLayerForChecksum(&replyIP); err != nil {
+		b.Fatal(err)
+	}
+	buf := gopacket.NewSerializeBuffer()
+	if err := gopacket.SerializeLayers(buf, s.opts, &eth, &replyIP, &replyTCP); err != nil {
+		b.Fatal(err)
+	}
+	reply := buf.Bytes()
+
+	monitor := newBandwidthMonitor()
+	defer monitor.halt()
+	s.monitor.Store(monitor)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	start := time.Now()
+	for i := 0; i < b.N; i++ {
+		if err := s.sendPriority(queue.PriorityHigh, &eth, &ip4, &tcp); err != nil {
+			b.Fatal(err)
+		}
+		if c := classifyReply(reply, testTarget, testLocal, testLocalPort); c.state != "open" {
+			b.Fatalf("reply classified %q, want open", c.state)
+		}
+	}
+	probes := time.Since(start)
+	// What writePacket and the receive loop of Synscan add.
+	start = time.Now()
+	for i := 0; i < b.N; i++ {
+		s.monitor.Load().sent(len(reply))
+		s.monitor.Load().received(len(reply))
+	}
+	calls := time.Since(start)
+	b.StopTimer()
+
+	overhead := 100 * calls.Seconds() / probes.Seconds()
+	b.ReportMetric(float64(calls.Nanoseconds())/float64(b.N), "monitor-ns/probe")
+	b.ReportMetric(overhead, "%overhead")
+	// Runs of a few probes are too short to judge.
+	if b.N >= 10000 && overhead >= 1 {
+		b.Errorf("the bandwidth monitor costs %.2f%% of the work per probe, want less than 1%%", overhead)
+	}
+}
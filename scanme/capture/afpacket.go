@@ -0,0 +1,64 @@
+//go:build linux
+
+package capture
+
+import (
+	"time"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/afpacket"
+	"github.com/google/gopacket/layers"
+	"github.com/google/gopacket/pcap"
+	"golang.org/x/net/bpf"
+)
+
+// afpacketSource reads packets from an AF_PACKET memory-mapped ring buffer.
+type afpacketSource struct {
+	tp      *afpacket.TPacket
+	snaplen int
+}
+
+// OpenAFPacket opens a capture on iface backed by an AF_PACKET TPACKET_V3
+// ring buffer shared with the kernel, which avoids the copy of every packet
+// libpcap makes.
+//
+// ReadPacketData returns slices of the ring buffer itself: the data is only
+// valid until the next call, so callers must decode or copy it first.
+func OpenAFPacket(iface string, snaplen int, timeout time.Duration) (PacketSource, error) {
+	tp, err := afpacket.NewTPacket(
+		afpacket.OptInterface(iface),
+		afpacket.OptPollTimeout(timeout),
+		afpacket.TPacketVersion3,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &afpacketSource{tp: tp, snaplen: snaplen}, nil
+}
+
+// ReadPacketData returns the next packet without copying it out of the ring.
+func (a *afpacketSource) ReadPacketData() ([]byte, gopacket.CaptureInfo, error) {
+	data, ci, err := a.tp.ZeroCopyReadPacketData()
+	if err == afpacket.ErrTimeout {
+		return nil, ci, pcap.NextErrorTimeoutExpired
+	}
+	return data, ci, err
+}
+
+// SetBPFFilter compiles expr with libpcap and attaches it to the socket.
+func (a *afpacketSource) SetBPFFilter(expr string) error {
+	insns, err := pcap.CompileBPFFilter(layers.LinkTypeEthernet, a.snaplen, expr)
+	if err != nil {
+		return err
+	}
+	raw := make([]bpf.RawInstruction, len(insns))
+	for i, ins := range insns {
+		raw[i] = bpf.RawInstruction{Op: ins.Code, Jt: ins.Jt, Jf: ins.Jf, K: ins.K}
+	}
+	return a.tp.SetBPF(raw)
+}
+
+// Close releases the ring buffer and the socket.
+func (a *afpacketSource) Close() {
+	a.tp.Close()
+}
@@ -0,0 +1,10 @@
+//go:build !linux
+
+package capture
+
+import "time"
+
+// OpenAFPacket returns ErrAFPacketUnsupported, AF_PACKET only exists on Linux.
+func OpenAFPacket(iface string, snaplen int, timeout time.Duration) (PacketSource, error) {
+	return nil, ErrAFPacketUnsupported
+}
@@ -0,0 +1,34 @@
+// Package capture abstracts the packet capture backend the scanner reads
+// replies from: libpcap, available everywhere, or AF_PACKET memory-mapped
+// ring buffers on Linux.
+package capture
+
+import (
+	"errors"
+	"time"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/pcap"
+)
+
+// ErrAFPacketUnsupported is returned by OpenAFPacket on platforms other
+// than Linux.
+var ErrAFPacketUnsupported = errors.New("AF_PACKET capture is only supported on Linux")
+
+// PacketSource is a capture handle. *pcap.Handle implements it.
+//
+// ReadPacketData returns pcap.NextErrorTimeoutExpired when no packet arrived
+// within the read timeout the source was opened with, whatever the backend,
+// so that reading loops can check for cancellation.
+type PacketSource interface {
+	ReadPacketData() (data []byte, ci gopacket.CaptureInfo, err error)
+	SetBPFFilter(expr string) error
+	Close()
+}
+
+var _ PacketSource = (*pcap.Handle)(nil)
+
+// OpenPcap opens a promiscuous libpcap capture on iface.
+func OpenPcap(iface string, snaplen int, timeout time.Duration) (PacketSource, error) {
+	return pcap.OpenLive(iface, int32(snaplen), true, timeout)
+}
@@ -0,0 +1,156 @@
+//go:build linux
+
+package capture
+
+import (
+	"encoding/binary"
+	"net"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/google/gopacket/pcap"
+)
+
+// injectRate is the rate, in packets per second, at which BenchmarkCapture
+// sends packets over the loopback interface.
+const injectRate = 100000
+
+// BenchmarkCapture compares the libpcap and AF_PACKET backends reading UDP
+// packets sent over the loopback interface at 100k packets per second. It
+// reports the packets received per second, the share of them lost, and the
+// CPU time the process spent per packet received, where the copies libpcap
+// makes show up. Capturing needs root.
+func BenchmarkCapture(b *testing.B) {
+	if os.Geteuid() != 0 {
+		b.Skip("capturing packets needs root")
+	}
+	backends := []struct {
+		name string
+		open func(iface string, snaplen int, timeout time.Duration) (PacketSource, error)
+	}{
+		{"pcap", OpenPcap},
+		{"afpacket", OpenAFPacket},
+	}
+	for _, backend := range backends {
+		b.Run(backend.name, func(b *testing.B) {
+			benchmarkCapture(b, backend.open)
+		})
+	}
+}
+
+func benchmarkCapture(b *testing.B, open func(string, int, time.Duration) (PacketSource, error)) {
+	sink, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer sink.Close()
+	go func() {
+		buf := make([]byte, 2048)
+		for {
+			if _, err := sink.Read(buf); err != nil {
+				return
+			}
+		}
+	}()
+	dst := sink.LocalAddr().(*net.UDPAddr)
+
+	src, err := open("lo", 65535, 10*time.Millisecond)
+	if err != nil {
+		b.Skipf("opening a capture on lo: %v", err)
+	}
+	defer src.Close()
+	conn, err := net.DialUDP("udp4", nil, dst)
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer conn.Close()
+
+	var before syscall.Rusage
+	if err := syscall.Getrusage(syscall.RUSAGE_SELF, &before); err != nil {
+		b.Fatal(err)
+	}
+	b.ResetTimer()
+	start := time.Now()
+	injected := make(chan struct{})
+	go func() {
+		defer close(injected)
+		inject(conn, b.N)
+	}()
+
+	// AF_PACKET sees the datagrams on lo twice, going out and coming in,
+	// where libpcap skips the first copy: each is counted once.
+	seen := make([]bool, b.N)
+	received := 0
+	var quietSince time.Time
+read:
+	for received < b.N {
+		data, _, err := src.ReadPacketData()
+		if err == pcap.NextErrorTimeoutExpired {
+			select {
+			case <-injected:
+				// Whatever is not read 100ms after the last packet
+				// was sent is lost.
+				if quietSince.IsZero() {
+					quietSince = time.Now()
+				} else if time.Since(quietSince) > 100*time.Millisecond {
+					break read
+				}
+			default:
+			}
+			continue
+		} else if err != nil {
+			b.Fatal(err)
+		}
+		quietSince = time.Time{}
+		if i, ok := datagramIndex(data, dst.Port); ok && i < len(seen) && !seen[i] {
+			seen[i] = true
+			received++
+		}
+	}
+	elapsed := time.Since(start)
+	b.StopTimer()
+	<-injected
+
+	var after syscall.Rusage
+	if err := syscall.Getrusage(syscall.RUSAGE_SELF, &after); err != nil {
+		b.Fatal(err)
+	}
+	cpu := time.Duration(after.Utime.Nano() + after.Stime.Nano() - before.Utime.Nano() - before.Stime.Nano())
+	b.ReportMetric(float64(received)/elapsed.Seconds(), "pkts/s")
+	b.ReportMetric(100*float64(b.N-received)/float64(b.N), "%lost")
+	if received > 0 {
+		b.ReportMetric(float64(cpu.Nanoseconds())/float64(received), "cpu-ns/pkt")
+	}
+}
+
+// inject sends n small UDP datagrams on conn at injectRate, each carrying
+// its index.
+func inject(conn *net.UDPConn, n int) {
+	payload := make([]byte, 64)
+	start := time.Now()
+	for sent := 0; sent < n; {
+		due := int(time.Since(start).Seconds() * injectRate)
+		for ; sent < n && sent < due; sent++ {
+			binary.BigEndian.PutUint64(payload, uint64(sent))
+			conn.Write(payload)
+		}
+		time.Sleep(100 * time.Microsecond)
+	}
+}
+
+// datagramIndex returns the index inject put in the UDP datagram to port
+// carried by the Ethernet frame data, as the loopback interface captures
+// them. ok is false for any other frame.
+func datagramIndex(data []byte, port int) (i int, ok bool) {
+	const ethLen, udpLen = 14, 8
+	if len(data) < ethLen+20 || binary.BigEndian.Uint16(data[12:]) != 0x0800 || data[ethLen+9] != syscall.IPPROTO_UDP {
+		return 0, false
+	}
+	udp := ethLen + int(data[ethLen]&0x0f)*4
+	if len(data) < udp+udpLen+8 || int(binary.BigEndian.Uint16(data[udp+2:])) != port {
+		return 0, false
+	}
+	return int(binary.BigEndian.Uint64(data[udp+udpLen:])), true
+}
@@ -0,0 +1,172 @@
+package scanme
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/google/gopacket/layers"
+)
+
+// defaultCheckpointInterval is how many ports Synscan probes between two
+// checkpoints, unless set with WithCheckpointInterval.
+const defaultCheckpointInterval = 1000
+
+// ErrScanInterrupted is returned by Synscan when it stops on SIGTERM after
+// saving a checkpoint, see WithCheckpointFile.
+var ErrScanInterrupted = errors.New("scan interrupted")
+
+// checkpoint is the state of an interrupted Synscan, as saved to the file
+// set with WithCheckpointFile.
+type checkpoint struct {
+	Target    string            `json:"target"`
+	LastPort  layers.TCPPort    `json:"last_port"`
+	Scanned   int               `json:"scanned"`
+	OpenPorts []layers.TCPPort  `json:"open_ports"`
+	Options   checkpointOptions `json:"options"`
+	Time      time.Time         `json:"time"`
+}
+
+// checkpointOptions records the options of the scan a checkpoint belongs
+// to, so that resuming with different ones can be reported.
+type checkpointOptions struct {
+	PortRange   string  `json:"port_range"`
+	RandomOrder bool    `json:"random_order"`
+	TTL         uint8   `json:"ttl"`
+	PacketRate  float64 `json:"packet_rate"`
+}
+
+// loadCheckpoint reads the checkpoint saved to path.
+func loadCheckpoint(path string) (*checkpoint, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var cp checkpoint
+	if err := json.Unmarshal(data, &cp); err != nil {
+		return nil, fmt.Errorf("error reading checkpoint %s: %w", path, err)
+	}
+	return &cp, nil
+}
+
+// save writes cp to path atomically: to a temporary file in the same
+// directory first, renamed over path once complete, so that a crash while
+// saving leaves the previous checkpoint intact.
+func (cp *checkpoint) save(path string) error {
+	data, err := json.MarshalIndent(cp, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// ClearCheckpoint removes the checkpoint file at path, so that the next scan
+// configured with WithCheckpointFile(path) starts from scratch. It is not an
+// error for the file not to exist.
+func ClearCheckpoint(path string) error {
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// checkpointer saves the progress of a Synscan every interval ports.
+type checkpointer struct {
+	path     string
+	interval int
+	state    checkpoint
+	pending  int
+	logger   *slog.Logger
+}
+
+// newCheckpointer returns the checkpointer of a Synscan of ports, picking up
+// from the checkpoint loaded by NewScanner, if any.
+func (s *scanner) newCheckpointer(ports []layers.TCPPort) *checkpointer {
+	c := &checkpointer{
+		path:     s.checkpointPath,
+		interval: s.checkpointInterval,
+		logger:   s.logger,
+		state: checkpoint{
+			Target: s.dst.String(),
+			Options: checkpointOptions{
+				PortRange:   describePorts(ports),
+				RandomOrder: s.randomOrder,
+				TTL:         s.ttl,
+				PacketRate:  packetRate(s.sendInterval),
+			},
+		},
+	}
+	if s.resume != nil {
+		if s.resume.Options != c.state.Options {
+			s.logger.Warn("resuming scan with different options", "target", s.dst, "options", fmt.Sprintf("%+v", c.state.Options), "checkpoint_options", fmt.Sprintf("%+v", s.resume.Options))
+		}
+		c.state.LastPort = s.resume.LastPort
+		c.state.Scanned = s.resume.Scanned
+		c.state.OpenPorts = s.resume.OpenPorts
+		s.resume = nil
+	}
+	return c
+}
+
+// remaining returns the ports still to be probed: those after the last
+// checkpointed one. When the checkpoint does not match ports, e.g. because
+// they were shuffled without WithRandomSeed, every port is probed again.
+func (c *checkpointer) remaining(ports []layers.TCPPort) []layers.TCPPort {
+	if c.state.Scanned == 0 {
+		return ports
+	}
+	i := c.state.Scanned - 1
+	if i >= len(ports) || ports[i] != c.state.LastPort {
+		c.logger.Info("checkpoint does not match the ports to scan, starting over", "target", c.state.Target)
+		c.state.Scanned, c.state.LastPort, c.state.OpenPorts = 0, 0, nil
+		return ports
+	}
+	c.logger.Info("resuming scan", "target", c.state.Target, "last_port", c.state.LastPort, "scanned", c.state.Scanned)
+	return ports[i+1:]
+}
+
+// probed records that port was probed and reports whether a checkpoint is
+// due.
+func (c *checkpointer) probed(port layers.TCPPort) bool {
+	c.state.LastPort = port
+	c.state.Scanned++
+	c.pending++
+	return c.pending >= c.interval
+}
+
+// save writes a checkpoint with the given open ports.
+func (c *checkpointer) save(openPorts map[layers.TCPPort]string) {
+	c.pending = 0
+	c.state.OpenPorts = c.state.OpenPorts[:0]
+	for port, state := range openPorts {
+		if state == "open" {
+			c.state.OpenPorts = append(c.state.OpenPorts, port)
+		}
+	}
+	sort.Slice(c.state.OpenPorts, func(i, j int) bool { return c.state.OpenPorts[i] < c.state.OpenPorts[j] })
+	c.state.Time = time.Now()
+	if err := c.state.save(c.path); err != nil {
+		c.logger.Error("error saving checkpoint", "path", c.path, "err", err)
+	}
+}
@@ -0,0 +1,148 @@
+package scanme
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/google/gopacket/layers"
+)
+
+// ViolationType tells which security regression a Violation reports.
+type ViolationType string
+
+const (
+	// ViolationNewOpenPort is a port open in the current scan but not in
+	// the baseline.
+	ViolationNewOpenPort ViolationType = "new-open-port"
+	// ViolationServiceChanged is a port open in both scans on which a
+	// different service was identified.
+	ViolationServiceChanged ViolationType = "service-changed"
+	// ViolationCertExpiryShorter is a TLS server whose certificate expires
+	// sooner than in the baseline.
+	ViolationCertExpiryShorter ViolationType = "cert-expiry-shorter"
+	// ViolationPortClosed is a port open in the baseline but not in the
+	// current scan, only raised by strict comparators.
+	ViolationPortClosed ViolationType = "port-closed"
+)
+
+// Violation is a security regression of a scan from its baseline. Baseline
+// and Current describe what was found on Port in each scan.
+type Violation struct {
+	Type     ViolationType  `json:"type"`
+	Port     layers.TCPPort `json:"port"`
+	Baseline string         `json:"baseline"`
+	Current  string         `json:"current"`
+}
+
+func (v Violation) String() string {
+	return fmt.Sprintf("%s on port %d: %q -> %q", v.Type, v.Port, v.Baseline, v.Current)
+}
+
+// Comparator checks scans of a target against a baseline scan of the same
+// target, such as the scan approved when the host was deployed, to detect
+// security regressions.
+type Comparator struct {
+	baseline *ScanResult
+	strict   bool
+}
+
+// NewComparator returns a Comparator checking scans against baseline.
+func NewComparator(baseline *ScanResult) *Comparator {
+	return &Comparator{baseline: baseline}
+}
+
+// Strict makes c also raise a ViolationPortClosed for every port open in
+// the baseline but no longer in the current scan, which may hide a service
+// moved elsewhere, and returns c.
+func (c *Comparator) Strict() *Comparator {
+	c.strict = true
+	return c
+}
+
+// Check compares current with the baseline and returns the violations
+// found, sorted by port. Services are compared by name, and by the
+// ServiceHint of the port when both scans recorded one; certificate
+// expiries only when both scans recorded one, see PortResult.CertExpiry.
+// Scans of different targets cannot be compared.
+func (c *Comparator) Check(current *ScanResult) ([]Violation, error) {
+	if c.baseline == nil || current == nil {
+		return nil, errors.New("comparator: missing scan result")
+	}
+	if !c.baseline.Target.Equal(current.Target) {
+		return nil, fmt.Errorf("comparator: baseline of %v compared with a scan of %v", c.baseline.Target, current.Target)
+	}
+
+	before := openPorts(c.baseline)
+	after := openPorts(current)
+
+	var violations []Violation
+	for port, p := range after {
+		b, found := before[port]
+		if !found {
+			violations = append(violations, Violation{
+				Type:    ViolationNewOpenPort,
+				Port:    port,
+				Current: describeService(p),
+			})
+			continue
+		}
+		if b.Service != p.Service || (b.ServiceHint != "" && p.ServiceHint != "" && b.ServiceHint != p.ServiceHint) {
+			violations = append(violations, Violation{
+				Type:     ViolationServiceChanged,
+				Port:     port,
+				Baseline: describeService(b),
+				Current:  describeService(p),
+			})
+		}
+		if !b.CertExpiry.IsZero() && !p.CertExpiry.IsZero() && p.CertExpiry.Before(b.CertExpiry) {
+			violations = append(violations, Violation{
+				Type:     ViolationCertExpiryShorter,
+				Port:     port,
+				Baseline: b.CertExpiry.Format("2006-01-02"),
+				Current:  p.CertExpiry.Format("2006-01-02"),
+			})
+		}
+	}
+	if c.strict {
+		for port, b := range before {
+			if _, found := after[port]; !found {
+				violations = append(violations, Violation{
+					Type:     ViolationPortClosed,
+					Port:     port,
+					Baseline: describeService(b),
+				})
+			}
+		}
+	}
+
+	sort.SliceStable(violations, func(i, j int) bool {
+		if violations[i].Port != violations[j].Port {
+			return violations[i].Port < violations[j].Port
+		}
+		return violations[i].Type < violations[j].Type
+	})
+	return violations, nil
+}
+
+// openPorts returns the open ports of r by port number.
+func openPorts(r *ScanResult) map[layers.TCPPort]PortResult {
+	open := make(map[layers.TCPPort]PortResult)
+	for _, p := range r.Ports {
+		if p.State == "open" {
+			open[p.Port] = p
+		}
+	}
+	return open
+}
+
+// describeService names the service found on p, with its hint if any.
+func describeService(p PortResult) string {
+	if p.ServiceHint == "" {
+		return p.Service
+	}
+	if p.Service == "" {
+		return p.ServiceHint
+	}
+	return p.Service + " (" + p.ServiceHint + ")"
+}
@@ -0,0 +1,87 @@
+package scanme
+
+import (
+	"net"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestComparatorCheck(t *testing.T) {
+	expiry := time.Date(2027, 1, 1, 0, 0, 0, 0, time.UTC)
+	baseline := &ScanResult{
+		Target: net.IPv4(10, 0, 0, 5),
+		Ports: []PortResult{
+			{Port: 22, State: "open", Service: "ssh", ServiceHint: "OpenSSH_9.6"},
+			{Port: 80, State: "open", Service: "http"},
+			{Port: 443, State: "open", Service: "https", CertExpiry: expiry},
+			{Port: 3306, State: "open", Service: "mysql"},
+			{Port: 8080, State: "closed"},
+		},
+	}
+	current := &ScanResult{
+		Target: net.IPv4(10, 0, 0, 5),
+		Ports: []PortResult{
+			{Port: 22, State: "open", Service: "ssh", ServiceHint: "Dropbear"},
+			{Port: 80, State: "open", Service: "http"},
+			{Port: 443, State: "open", Service: "https", CertExpiry: expiry.AddDate(0, -6, 0)},
+			{Port: 3306, State: "closed"},
+			{Port: 8080, State: "open", Service: "http-alt"},
+		},
+	}
+	changes := []Violation{
+		{Type: ViolationServiceChanged, Port: 22, Baseline: "ssh (OpenSSH_9.6)", Current: "ssh (Dropbear)"},
+		{Type: ViolationCertExpiryShorter, Port: 443, Baseline: "2027-01-01", Current: "2026-07-01"},
+		{Type: ViolationNewOpenPort, Port: 8080, Current: "http-alt"},
+	}
+
+	got, err := NewComparator(baseline).Check(current)
+	if err != nil {
+		t.Fatalf("Check() error = %v", err)
+	}
+	if !slices.Equal(got, changes) {
+		t.Errorf("Check() = %v, want %v", got, changes)
+	}
+
+	strict := slices.Insert(slices.Clone(changes), 2, Violation{Type: ViolationPortClosed, Port: 3306, Baseline: "mysql"})
+	got, err = NewComparator(baseline).Strict().Check(current)
+	if err != nil {
+		t.Fatalf("Strict().Check() error = %v", err)
+	}
+	if !slices.Equal(got, strict) {
+		t.Errorf("Strict().Check() = %v, want %v", got, strict)
+	}
+
+	if got, err := NewComparator(baseline).Strict().Check(baseline); err != nil || len(got) != 0 {
+		t.Errorf("Check(baseline) = %v, %v, want no violations", got, err)
+	}
+}
+
+func TestComparatorCheckHints(t *testing.T) {
+	// Hints are only compared when both scans recorded one, and a longer
+	// certificate validity is no regression.
+	baseline := &ScanResult{Target: net.IPv4(10, 0, 0, 5), Ports: []PortResult{
+		{Port: 22, State: "open", Service: "ssh", ServiceHint: "OpenSSH_9.6"},
+		{Port: 443, State: "open", Service: "https", CertExpiry: time.Date(2027, 1, 1, 0, 0, 0, 0, time.UTC)},
+	}}
+	current := &ScanResult{Target: net.IPv4(10, 0, 0, 5), Ports: []PortResult{
+		{Port: 22, State: "open", Service: "ssh"},
+		{Port: 443, State: "open", Service: "https", CertExpiry: time.Date(2028, 1, 1, 0, 0, 0, 0, time.UTC)},
+	}}
+	if got, err := NewComparator(baseline).Check(current); err != nil || len(got) != 0 {
+		t.Errorf("Check() = %v, %v, want no violations", got, err)
+	}
+}
+
+func TestComparatorCheckErrors(t *testing.T) {
+	baseline := &ScanResult{Target: net.IPv4(10, 0, 0, 5)}
+	if _, err := NewComparator(baseline).Check(&ScanResult{Target: net.IPv4(10, 0, 0, 6)}); err == nil {
+		t.Error("Check() of another target: error = nil")
+	}
+	if _, err := NewComparator(baseline).Check(nil); err == nil {
+		t.Error("Check(nil): error = nil")
+	}
+	if _, err := NewComparator(nil).Check(baseline); err == nil {
+		t.Error("Check() without baseline: error = nil")
+	}
+}
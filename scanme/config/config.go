@@ -0,0 +1,310 @@
+// Package config loads scan settings from YAML files, so that complex scans
+// can be described once and reused, for instance from CI pipelines.
+//
+// A template listing every setting with its default value is returned by
+// GenerateDefaultConfig.
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"os"
+	"time"
+
+	"github.com/CyberRoute/scanme/scanme"
+	"gopkg.in/yaml.v3"
+)
+
+// maxHostBits bounds the size of the CIDR blocks accepted as targets.
+const maxHostBits = 16
+
+// Config mirrors the scan options of the scanme package.
+type Config struct {
+	// Targets lists the IP addresses and CIDR blocks to scan.
+	Targets []string `yaml:"targets"`
+	// Ports is the set of ports to scan, such as "22,80,8000-8100".
+	// An empty value scans ports 1 to 65535.
+	Ports string `yaml:"ports"`
+	// ExcludePorts is the set of ports never scanned, in the same format as Ports.
+	ExcludePorts string `yaml:"exclude_ports"`
+	// RandomOrder probes ports in random order.
+	RandomOrder bool `yaml:"random_order"`
+	// Speed is the name of a timing template: paranoid, sneaky, polite,
+	// normal, aggressive or insane. Other settings override the template.
+	Speed string `yaml:"speed"`
+	// RateLimit caps the packets sent per second, 0 meaning no limit.
+	RateLimit int `yaml:"rate_limit"`
+	// Jitter varies the interval between probes by up to ±Jitter of it.
+	Jitter float64 `yaml:"jitter"`
+	// Timeout bounds banner grabbing on every open port.
+	Timeout time.Duration `yaml:"timeout"`
+	// TTL is the TTL of the IPv4 packets sent.
+	TTL uint8 `yaml:"ttl"`
+	// Decoys lists addresses to spoof decoy probes from.
+	Decoys []string `yaml:"decoys"`
+	// Fragmentation splits probes in IP fragments of this size, 0 disabling it.
+	Fragmentation int `yaml:"fragmentation"`
+	// ResolveDNS resolves the PTR records of the targets.
+	ResolveDNS bool `yaml:"resolve_dns"`
+	// ASNLookup looks up the autonomous system announcing the targets.
+	ASNLookup bool `yaml:"asn_lookup"`
+	// GeoIP lists MaxMind databases used to locate the targets.
+	GeoIP []string `yaml:"geoip"`
+	// Output is the output format: "text", "json" or "xml".
+	Output string `yaml:"output"`
+	// MetricsAddr, when set, is the address Prometheus metrics are served on.
+	MetricsAddr string `yaml:"metrics_addr"`
+}
+
+// Default returns the configuration used when no file is given.
+func Default() *Config {
+	return &Config{
+		Timeout: time.Second,
+		TTL:     64,
+		Output:  "text",
+	}
+}
+
+// LoadConfig reads the YAML file at path. Settings missing from the file
+// keep their default value, and unknown settings are reported as errors.
+func LoadConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	c := Default()
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(c); err != nil {
+		return nil, fmt.Errorf("error parsing %s: %v", path, err)
+	}
+	if err := c.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid config %s: %v", path, err)
+	}
+	return c, nil
+}
+
+// Validate checks that the settings of c are consistent.
+func (c *Config) Validate() error {
+	if _, err := c.TargetIPs(); err != nil {
+		return err
+	}
+	if _, err := scanme.ParsePorts(c.Ports); err != nil {
+		return err
+	}
+	if _, err := scanme.ParsePorts(c.ExcludePorts); err != nil {
+		return err
+	}
+	if _, err := parseSpeed(c.Speed); err != nil {
+		return err
+	}
+	if c.RateLimit < 0 {
+		return fmt.Errorf("rate_limit must not be negative")
+	}
+	if c.Jitter < 0 || c.Jitter > 1 {
+		return fmt.Errorf("jitter must be between 0 and 1")
+	}
+	for _, d := range c.Decoys {
+		if net.ParseIP(d) == nil {
+			return fmt.Errorf("invalid decoy address %q", d)
+		}
+	}
+	switch c.Output {
+	case "text", "json", "xml":
+	default:
+		return fmt.Errorf("unknown output format %q", c.Output)
+	}
+	return nil
+}
+
+// TargetIPs expands Targets into the list of addresses to scan. The network
+// and broadcast addresses of IPv4 CIDR blocks larger than /31 are skipped.
+func (c *Config) TargetIPs() ([]net.IP, error) {
+	var ips []net.IP
+	for _, t := range c.Targets {
+		if ip := net.ParseIP(t); ip != nil {
+			ips = append(ips, ip)
+			continue
+		}
+		_, ipnet, err := net.ParseCIDR(t)
+		if err != nil {
+			return nil, fmt.Errorf("invalid target %q", t)
+		}
+		if ones, bits := ipnet.Mask.Size(); bits-ones > maxHostBits {
+			return nil, fmt.Errorf("target %q holds too many addresses", t)
+		}
+		ips = append(ips, hosts(ipnet)...)
+	}
+	return ips, nil
+}
+
+// hosts returns the addresses of ipnet.
+func hosts(ipnet *net.IPNet) []net.IP {
+	var ips []net.IP
+	for ip := ipnet.IP.Mask(ipnet.Mask); ipnet.Contains(ip); ip = nextIP(ip) {
+		ips = append(ips, ip)
+	}
+	ones, bits := ipnet.Mask.Size()
+	if bits == 32 && bits-ones > 1 {
+		ips = ips[1 : len(ips)-1]
+	}
+	return ips
+}
+
+// nextIP returns the address following ip, wrapping around to zero.
+func nextIP(ip net.IP) net.IP {
+	next := make(net.IP, len(ip))
+	copy(next, ip)
+	for i := len(next) - 1; i >= 0; i-- {
+		next[i]++
+		if next[i] != 0 {
+			break
+		}
+	}
+	return next
+}
+
+// ScanOptions returns the scanme options matching c.
+func (c *Config) ScanOptions() ([]scanme.Option, error) {
+	ports, err := scanme.ParsePorts(c.Ports)
+	if err != nil {
+		return nil, err
+	}
+
+	exclude, err := scanme.ParsePorts(c.ExcludePorts)
+	if err != nil {
+		return nil, err
+	}
+
+	speed, err := parseSpeed(c.Speed)
+	if err != nil {
+		return nil, err
+	}
+
+	var opts []scanme.Option
+	if speed != nil {
+		opts = append(opts, scanme.WithSpeed(*speed))
+	}
+	if len(ports) > 0 {
+		opts = append(opts, scanme.WithPortList(ports))
+	}
+	if len(exclude) > 0 {
+		opts = append(opts, scanme.WithDenyList(exclude))
+	}
+	if c.RandomOrder {
+		opts = append(opts, scanme.WithRandomOrder())
+	}
+	if c.RateLimit > 0 {
+		opts = append(opts, scanme.WithRateLimit(c.RateLimit))
+	}
+	if c.Jitter > 0 {
+		if c.Jitter > 1 {
+			return nil, fmt.Errorf("jitter must be between 0 and 1")
+		}
+		opts = append(opts, scanme.WithJitter(c.Jitter))
+	}
+	if c.TTL != 0 {
+		opts = append(opts, scanme.WithTTL(c.TTL))
+	}
+	if len(c.Decoys) > 0 {
+		decoys := make([]net.IP, 0, len(c.Decoys))
+		for _, d := range c.Decoys {
+			ip := net.ParseIP(d)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid decoy address %q", d)
+			}
+			decoys = append(decoys, ip)
+		}
+		opts = append(opts, scanme.WithDecoys(decoys))
+	}
+	if c.Fragmentation > 0 {
+		opts = append(opts, scanme.WithFragmentation(c.Fragmentation))
+	}
+	if c.ResolveDNS {
+		opts = append(opts, scanme.WithDNSResolution())
+	}
+	if c.ASNLookup {
+		opts = append(opts, scanme.WithASNLookup())
+	}
+	for _, path := range c.GeoIP {
+		opts = append(opts, scanme.WithGeoIP(path))
+	}
+	if c.MetricsAddr != "" {
+		opts = append(opts, scanme.WithMetricsRegistry(nil))
+	}
+	return opts, nil
+}
+
+// parseSpeed returns the timing template named name, or nil when name is empty.
+func parseSpeed(name string) (*scanme.ScanSpeed, error) {
+	if name == "" {
+		return nil, nil
+	}
+	for speed := scanme.SpeedParanoid; speed <= scanme.SpeedInsane; speed++ {
+		if speed.String() == name {
+			return &speed, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown speed %q", name)
+}
+
+// GenerateDefaultConfig returns a commented YAML template of every setting,
+// holding its default value.
+func GenerateDefaultConfig() string {
+	return `# scanme configuration file.
+
+# IP addresses and CIDR blocks to scan, e.g. ["192.168.1.10", "10.0.0.0/24"].
+targets: []
+
+# Ports to scan as a comma separated list of ports and ranges,
+# e.g. "22,80,8000-8100". Empty scans ports 1 to 65535.
+ports: ""
+
+# Ports never scanned, in the same format as ports, e.g. "53".
+exclude_ports: ""
+
+# Probe ports in random order rather than sequentially.
+random_order: false
+
+# Timing template: paranoid, sneaky, polite, normal, aggressive or insane.
+# Empty sends as fast as possible. The settings below override the template.
+speed: ""
+
+# Maximum number of packets sent per second, 0 for no limit.
+rate_limit: 0
+
+# Vary the interval between packets at random by up to this fraction of it,
+# e.g. 0.5 for +/-50%. Only effective with a rate limit.
+jitter: 0
+
+# Timeout of banner grabbing on every open port.
+timeout: 1s
+
+# TTL of the IPv4 packets sent.
+ttl: 64
+
+# Addresses to spoof decoy probes from.
+decoys: []
+
+# Split probes in IP fragments of this many bytes, 0 to disable.
+fragmentation: 0
+
+# Resolve the PTR records of the targets.
+resolve_dns: false
+
+# Look up the autonomous system announcing the targets with the Team Cymru
+# whois service.
+asn_lookup: false
+
+# MaxMind GeoIP2/GeoLite2 databases (City, ASN or ISP) used to locate targets.
+geoip: []
+
+# Output format: text, json or xml.
+output: text
+
+# Address to serve Prometheus metrics on, e.g. ":9090". Empty disables them.
+metrics_addr: ""
+`
+}
@@ -0,0 +1,388 @@
+package config
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/CyberRoute/scanme/scanme"
+	"github.com/google/gopacket/layers"
+	"github.com/google/gopacket/routing"
+	"gopkg.in/yaml.v3"
+)
+
+// ScanType is the kind of port scan a ScanTemplate runs.
+type ScanType string
+
+const (
+	// ScanSYN runs a SYN scan, see Synscan.
+	ScanSYN ScanType = "syn"
+	// ScanConnect runs a connect scan, see ConnScan. Connect scans probe
+	// every port: the ports of the template only select the results kept.
+	ScanConnect ScanType = "connect"
+)
+
+// Hook is a follow-up probe a ScanTemplate runs on every open port once the
+// port scan of a target completes.
+type Hook string
+
+const (
+	// HookBanner grabs the banner of the service, recording its first line
+	// in PortResult.ServiceHint.
+	HookBanner Hook = "banner"
+	// HookTLS computes the JARM fingerprint of TLS servers, recording it, or
+	// the name KnownJARM gives it, in PortResult.ServiceHint, and records
+	// the expiry date of their certificate in PortResult.CertExpiry.
+	HookTLS Hook = "tls"
+	// HookHTTP fingerprints web servers into PortResult.HTTPFingerprint.
+	HookHTTP Hook = "http"
+)
+
+// defaultHookTimeout bounds the banner grab of HookBanner and the TLS
+// handshake of HookTLS when the template sets no Timeout.
+const defaultHookTimeout = 2 * time.Second
+
+// ScanTemplate bundles the settings of a common scanning scenario, so that
+// it can be run against any target in one call, saved and shared. Start
+// from one of the presets, such as QuickScan or ServiceScan, or describe
+// a template in YAML and load it with LoadTemplate.
+type ScanTemplate struct {
+	// Name identifies the template.
+	Name string `yaml:"name"`
+	// Description tells what the template is for.
+	Description string `yaml:"description,omitempty"`
+	// ScanType is the kind of port scan run, ScanSYN by default.
+	ScanType ScanType `yaml:"scan_type"`
+	// Ports is the set of ports to scan, such as "22,80,8000-8100". An empty
+	// value scans ports 1 to 65535, unless TopPorts is set.
+	Ports string `yaml:"ports,omitempty"`
+	// TopPorts scans the TopPorts ports found open most often, up to 100,
+	// instead of Ports.
+	TopPorts int `yaml:"top_ports,omitempty"`
+	// RandomOrder probes ports in random order.
+	RandomOrder bool `yaml:"random_order,omitempty"`
+	// Speed is the name of a timing template, as in Config.
+	Speed string `yaml:"speed,omitempty"`
+	// RateLimit caps the packets sent per second, 0 meaning no limit.
+	RateLimit int `yaml:"rate_limit,omitempty"`
+	// Jitter varies the interval between probes by up to ±Jitter of it.
+	Jitter float64 `yaml:"jitter,omitempty"`
+	// Fragmentation splits probes in IP fragments of this size, 0 disabling it.
+	Fragmentation int `yaml:"fragmentation,omitempty"`
+	// Timeout bounds the follow-up probes of Hooks on every open port.
+	Timeout scanme.Duration `yaml:"timeout,omitempty"`
+	// Hooks lists the follow-up probes run on every open port, in order.
+	Hooks []Hook `yaml:"hooks,omitempty"`
+}
+
+// The presets below cover the scans most often run by hand. They are
+// values: copy one and change its fields to derive a custom template.
+var (
+	// QuickScan quickly SYN scans the 100 ports found open most often.
+	QuickScan = ScanTemplate{
+		Name:        "quick",
+		Description: "SYN scan of the 100 most common ports",
+		ScanType:    ScanSYN,
+		TopPorts:    100,
+		Speed:       "aggressive",
+	}
+
+	// FullScan SYN scans every port and identifies the services found.
+	FullScan = ScanTemplate{
+		Name:        "full",
+		Description: "SYN scan of every port, followed by banner grabbing and TLS and HTTP fingerprinting",
+		ScanType:    ScanSYN,
+		Ports:       "1-65535",
+		Speed:       "normal",
+		Hooks:       []Hook{HookBanner, HookTLS, HookHTTP},
+	}
+
+	// StealthScan slowly SYN scans the most common ports in random order
+	// with fragmented probes, and makes no connection to the target.
+	StealthScan = ScanTemplate{
+		Name:          "stealth",
+		Description:   "slow, fragmented SYN scan of the 100 most common ports in random order",
+		ScanType:      ScanSYN,
+		TopPorts:      100,
+		RandomOrder:   true,
+		Speed:         "polite",
+		Jitter:        0.3,
+		Fragmentation: 8,
+	}
+
+	// ServiceScan SYN scans the most common ports and identifies the
+	// services found from their banner and HTTP headers.
+	ServiceScan = ScanTemplate{
+		Name:        "service",
+		Description: "SYN scan of the 100 most common ports, followed by banner grabbing and HTTP fingerprinting",
+		ScanType:    ScanSYN,
+		TopPorts:    100,
+		Speed:       "normal",
+		Hooks:       []Hook{HookBanner, HookHTTP},
+	}
+
+	// VulnScan SYN scans the most common ports and collects what a
+	// vulnerability assessment starts from: service banners, TLS
+	// fingerprints, flagging known C2 frameworks, and HTTP fingerprints.
+	VulnScan = ScanTemplate{
+		Name:        "vuln",
+		Description: "SYN scan of the 100 most common ports, followed by banner grabbing and TLS and HTTP fingerprinting",
+		ScanType:    ScanSYN,
+		TopPorts:    100,
+		Speed:       "normal",
+		Hooks:       []Hook{HookBanner, HookTLS, HookHTTP},
+	}
+)
+
+// LoadTemplate reads the YAML template at path, as written by Save.
+// Unknown settings are reported as errors.
+func LoadTemplate(path string) (*ScanTemplate, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	t := &ScanTemplate{}
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(t); err != nil {
+		return nil, fmt.Errorf("error parsing %s: %v", path, err)
+	}
+	if err := t.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid template %s: %v", path, err)
+	}
+	return t, nil
+}
+
+// Save writes t as YAML to path.
+func (t *ScanTemplate) Save(path string) error {
+	data, err := yaml.Marshal(t)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
+
+// Validate checks that the settings of t are consistent.
+func (t *ScanTemplate) Validate() error {
+	switch t.ScanType {
+	case "", ScanSYN, ScanConnect:
+	default:
+		return fmt.Errorf("unknown scan type %q", t.ScanType)
+	}
+	if t.TopPorts < 0 || t.TopPorts > 100 {
+		return fmt.Errorf("top_ports must be between 0 and 100")
+	}
+	if t.TopPorts > 0 && t.Ports != "" {
+		return fmt.Errorf("ports and top_ports are mutually exclusive")
+	}
+	if t.Timeout < 0 {
+		return fmt.Errorf("timeout must not be negative")
+	}
+	for _, h := range t.Hooks {
+		switch h {
+		case HookBanner, HookTLS, HookHTTP:
+		default:
+			return fmt.Errorf("unknown hook %q", h)
+		}
+	}
+	_, err := t.scanOptions()
+	return err
+}
+
+// scanOptions returns the scanme options matching t, through the Config
+// holding the same settings.
+func (t *ScanTemplate) scanOptions() ([]scanme.Option, error) {
+	c := &Config{
+		Ports:         t.Ports,
+		RandomOrder:   t.RandomOrder,
+		Speed:         t.Speed,
+		RateLimit:     t.RateLimit,
+		Jitter:        t.Jitter,
+		Fragmentation: t.Fragmentation,
+	}
+	if t.RateLimit < 0 {
+		return nil, fmt.Errorf("rate_limit must not be negative")
+	}
+	opts, err := c.ScanOptions()
+	if err != nil {
+		return nil, err
+	}
+	if t.TopPorts > 0 {
+		opts = append(opts, scanme.WithPortIterator(scanme.NewTopNIterator(t.TopPorts)))
+	}
+	return opts, nil
+}
+
+// Run scans targets one after the other, routing packets with router, and
+// returns the results of the hosts found up. Hosts not answering ARP
+// requests are skipped; other errors do not stop the scans of the
+// remaining targets and are returned, annotated with their target, along
+// with the results.
+func (t *ScanTemplate) Run(ctx context.Context, targets []net.IP, router routing.Router) ([]*scanme.ScanResult, error) {
+	if err := t.Validate(); err != nil {
+		return nil, err
+	}
+
+	var results []*scanme.ScanResult
+	var errs []error
+	for _, target := range targets {
+		if err := ctx.Err(); err != nil {
+			errs = append(errs, err)
+			break
+		}
+		result, err := t.scan(ctx, target, router)
+		if errors.Is(err, &scanme.ErrARPTimeout{}) {
+			continue
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%v: %w", target, err))
+			continue
+		}
+		results = append(results, result)
+	}
+	return results, errors.Join(errs...)
+}
+
+// fingerprinter is implemented by the scanner returned by scanme.NewScanner
+// for the tls and http hooks.
+type fingerprinter interface {
+	TLSCertExpiry(port layers.TCPPort, timeout time.Duration) (time.Time, error)
+	JARMFingerprint(port layers.TCPPort) (string, error)
+	HTTPFingerprint(port layers.TCPPort, useTLS bool) (*scanme.HTTPFingerprint, error)
+}
+
+// scan runs the port scan of t against target, then its hooks.
+func (t *ScanTemplate) scan(ctx context.Context, target net.IP, router routing.Router) (*scanme.ScanResult, error) {
+	opts, err := t.scanOptions()
+	if err != nil {
+		return nil, err
+	}
+	scanner, err := scanme.NewScanner(target, router, opts...)
+	if err != nil {
+		return nil, err
+	}
+	defer scanner.Close()
+
+	// Neither Synscan nor ConnScan take a context: closing the scanner
+	// stops them.
+	stop := context.AfterFunc(ctx, scanner.Close)
+	defer stop()
+
+	var result *scanme.ScanResult
+	if t.ScanType == ScanConnect {
+		start := time.Now()
+		open, err := scanner.ConnScan()
+		if err != nil {
+			return nil, err
+		}
+		result = t.connResult(target, start, open)
+	} else {
+		result, err = scanner.Synscan()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	timeout := time.Duration(t.Timeout)
+	if timeout == 0 {
+		timeout = defaultHookTimeout
+	}
+	fingerprints, _ := scanner.(fingerprinter)
+	for _, h := range t.Hooks {
+		if ctx.Err() != nil {
+			break
+		}
+		for i := range result.Ports {
+			p := &result.Ports[i]
+			if p.State != "open" {
+				continue
+			}
+			switch h {
+			case HookBanner:
+				banner, err := scanner.GrabBanner(p.Port, timeout)
+				if err == nil && banner != "" {
+					addHint(p, strings.SplitN(banner, "\n", 2)[0])
+				}
+			case HookTLS:
+				if fingerprints == nil {
+					continue
+				}
+				if expiry, err := fingerprints.TLSCertExpiry(p.Port, timeout); err == nil {
+					p.CertExpiry = expiry
+				}
+				fp, err := fingerprints.JARMFingerprint(p.Port)
+				if err != nil || strings.Trim(fp, "0") == "" {
+					continue
+				}
+				if name, ok := scanme.KnownJARM[fp]; ok {
+					addHint(p, "JARM "+name)
+				} else {
+					addHint(p, "JARM "+fp)
+				}
+			case HookHTTP:
+				if fingerprints == nil {
+					continue
+				}
+				useTLS := strings.Contains(p.Service, "https") || p.Port == 443 || p.Port == 8443
+				if fp, err := fingerprints.HTTPFingerprint(p.Port, useTLS); err == nil {
+					p.HTTPFingerprint = fp
+				}
+			}
+		}
+	}
+	return result, nil
+}
+
+// connResult builds a ScanResult from the open ports found by ConnScan,
+// keeping those that are part of the ports of t.
+func (t *ScanTemplate) connResult(target net.IP, start time.Time, open map[layers.TCPPort]string) *scanme.ScanResult {
+	var keep map[layers.TCPPort]bool
+	if t.TopPorts > 0 || t.Ports != "" {
+		keep = make(map[layers.TCPPort]bool)
+		if t.TopPorts > 0 {
+			it := scanme.NewTopNIterator(t.TopPorts)
+			for p, ok := it.Next(); ok; p, ok = it.Next() {
+				keep[p] = true
+			}
+		} else {
+			// Validate already parsed Ports.
+			ports, _ := scanme.ParsePorts(t.Ports)
+			for _, p := range ports {
+				keep[p] = true
+			}
+		}
+	}
+
+	result := &scanme.ScanResult{Target: target, StartTime: start, EndTime: time.Now()}
+	for port, state := range open {
+		if keep != nil && !keep[port] {
+			continue
+		}
+		result.Ports = append(result.Ports, scanme.PortResult{
+			Port:    port,
+			State:   "open",
+			Service: strings.TrimSuffix(state, " open"),
+		})
+	}
+	sort.Slice(result.Ports, func(i, j int) bool { return result.Ports[i].Port < result.Ports[j].Port })
+	result.Stats.Duration = result.EndTime.Sub(result.StartTime)
+	return result
+}
+
+// addHint appends hint to the ServiceHint of p, which several hooks may
+// contribute to.
+func addHint(p *scanme.PortResult, hint string) {
+	hint = strings.TrimSpace(hint)
+	if p.ServiceHint == "" {
+		p.ServiceHint = hint
+		return
+	}
+	p.ServiceHint += "; " + hint
+}
@@ -0,0 +1,160 @@
+package scanme
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/gopacket/layers"
+)
+
+// databaseTimeout bounds the database probes when ctx has no deadline.
+const databaseTimeout = 5 * time.Second
+
+// The database types reported in DatabaseInfo.Type.
+const (
+	DatabaseMySQL         = "mysql"
+	DatabasePostgreSQL    = "postgresql"
+	DatabaseRedis         = "redis"
+	DatabaseMongoDB       = "mongodb"
+	DatabaseElasticsearch = "elasticsearch"
+)
+
+// ErrNotDatabase is returned by the database probes when the service on the
+// port does not speak the protocol of the database probed.
+var ErrNotDatabase = errors.New("not the database probed")
+
+// DatabaseInfo describes a database server of the target, found by
+// MySQLProbe, PostgreSQLProbe, RedisProbe, MongoDBProbe or
+// ElasticsearchProbe.
+type DatabaseInfo struct {
+	// Type is the database, such as DatabaseRedis.
+	Type string
+	// Version is the version of the server, empty when it only tells
+	// authenticated clients.
+	Version string
+	// AuthRequired is set when the server refused the probe access
+	// without credentials.
+	AuthRequired bool
+	// AnonymousAccessAllowed is set when the server answered queries
+	// without credentials: anyone reaching it can read, and often write,
+	// its data. It is a critical finding.
+	AnonymousAccessAllowed bool
+	// DatabaseList lists the databases, or indices for Elasticsearch,
+	// when the server allowed anonymous access.
+	DatabaseList []string
+}
+
+// ServiceHint returns the classification of the server for
+// PortResult.ServiceHint, such as "redis-noauth" for servers allowing
+// anonymous access.
+func (d *DatabaseInfo) ServiceHint() string {
+	if d.AnonymousAccessAllowed {
+		return d.Type + "-noauth"
+	}
+	if d.Version == "" {
+		return d.Type
+	}
+	return d.Type + " " + d.Version
+}
+
+// withDatabaseTimeout returns ctx bounded by databaseTimeout when it has no
+// deadline.
+func withDatabaseTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if _, ok := ctx.Deadline(); ok {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, databaseTimeout)
+}
+
+// flagAnonymous logs the database servers allowing anonymous access.
+func (s *scanner) flagAnonymous(info *DatabaseInfo, port layers.TCPPort) {
+	if info.AnonymousAccessAllowed {
+		s.logger.Warn("database allows anonymous access", "target", s.dst, "port", port,
+			"type", info.Type, "severity", "critical")
+	}
+}
+
+// RedisProbe sends PING to the Redis server on port of the target. A server
+// answering PONG requires no password, its version and databases are then
+// read with INFO.
+func (s *scanner) RedisProbe(ctx context.Context, port layers.TCPPort) (*DatabaseInfo, error) {
+	ctx, cancel := withDatabaseTimeout(ctx)
+	defer cancel()
+	conn, err := dialICS(ctx, s.dst, strconv.Itoa(int(port)))
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+
+	if _, err := conn.Write([]byte("PING\r\n")); err != nil {
+		return nil, err
+	}
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, ErrNotDatabase
+	}
+	line = strings.TrimRight(line, "\r\n")
+	info := &DatabaseInfo{Type: DatabaseRedis}
+	switch {
+	case line == "+PONG":
+		info.AnonymousAccessAllowed = true
+	case strings.HasPrefix(line, "-NOAUTH"), strings.HasPrefix(line, "-WRONGPASS"):
+		info.AuthRequired = true
+		return info, nil
+	case strings.HasPrefix(line, "-DENIED"):
+		// Protected mode refuses remote clients of servers without a
+		// password.
+		info.AuthRequired = true
+		return info, nil
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrNotDatabase, line)
+	}
+
+	if _, err := conn.Write([]byte("INFO\r\n")); err != nil {
+		return info, err
+	}
+	body, err := readRedisBulk(r)
+	if err != nil {
+		return info, err
+	}
+	for _, line := range strings.Split(body, "\n") {
+		line = strings.TrimRight(line, "\r")
+		if v, ok := strings.CutPrefix(line, "redis_version:"); ok {
+			info.Version = v
+		}
+		// Keyspace lines, such as "db0:keys=12,expires=0,avg_ttl=0".
+		if name, _, ok := strings.Cut(line, ":keys="); ok && strings.HasPrefix(name, "db") {
+			info.DatabaseList = append(info.DatabaseList, name)
+		}
+	}
+	s.flagAnonymous(info, port)
+	return info, nil
+}
+
+// readRedisBulk reads a RESP bulk string from r.
+func readRedisBulk(r *bufio.Reader) (string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	line = strings.TrimRight(line, "\r\n")
+	if !strings.HasPrefix(line, "$") {
+		return "", fmt.Errorf("redis: unexpected reply %q", line)
+	}
+	n, err := strconv.Atoi(line[1:])
+	if err != nil || n < 0 || n > 1<<20 {
+		return "", fmt.Errorf("redis: unexpected reply %q", line)
+	}
+	body := make([]byte, n+2)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return "", err
+	}
+	return string(body[:n]), nil
+}
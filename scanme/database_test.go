@@ -0,0 +1,405 @@
+package scanme
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/google/gopacket/layers"
+)
+
+// checkDatabase runs probe against a server on 127.0.0.1 running serve and
+// compares the DatabaseInfo returned with want.
+func checkDatabase(t *testing.T, probe func(*scanner, context.Context, layers.TCPPort) (*DatabaseInfo, error), serve func(net.Conn), want *DatabaseInfo) {
+	t.Helper()
+	port := testServer(t, "127.0.0.1:0", serve)
+	s := newTestScanner()
+	s.dst = net.IPv4(127, 0, 0, 1)
+	info, err := probe(s, context.Background(), port)
+	if want == nil {
+		if !errors.Is(err, ErrNotDatabase) {
+			t.Errorf("probe error = %v, want ErrNotDatabase", err)
+		}
+		return
+	}
+	if err != nil {
+		t.Fatalf("probe error = %v", err)
+	}
+	if info.Type != want.Type || info.Version != want.Version || info.AuthRequired != want.AuthRequired ||
+		info.AnonymousAccessAllowed != want.AnonymousAccessAllowed || !slices.Equal(info.DatabaseList, want.DatabaseList) {
+		t.Errorf("probe = %+v, want %+v", info, want)
+	}
+}
+
+func TestRedisProbe(t *testing.T) {
+	redis := func(pong string) func(net.Conn) {
+		return func(conn net.Conn) {
+			r := bufio.NewReader(conn)
+			if line, _ := r.ReadString('\n'); line != "PING\r\n" {
+				return
+			}
+			conn.Write([]byte(pong))
+			if line, _ := r.ReadString('\n'); line != "INFO\r\n" {
+				return
+			}
+			info := "# Server\r\nredis_version:7.2.4\r\nredis_mode:standalone\r\n# Keyspace\r\ndb0:keys=12,expires=0,avg_ttl=0\r\ndb3:keys=1,expires=0,avg_ttl=0\r\n"
+			fmt.Fprintf(conn, "$%d\r\n%s\r\n", len(info), info)
+		}
+	}
+	tests := []struct {
+		name  string
+		serve func(net.Conn)
+		want  *DatabaseInfo
+	}{
+		{"no password", redis("+PONG\r\n"), &DatabaseInfo{Type: DatabaseRedis, Version: "7.2.4", AnonymousAccessAllowed: true, DatabaseList: []string{"db0", "db3"}}},
+		{"password", redis("-NOAUTH Authentication required.\r\n"), &DatabaseInfo{Type: DatabaseRedis, AuthRequired: true}},
+		{"protected mode", redis("-DENIED Redis is running in protected mode\r\n"), &DatabaseInfo{Type: DatabaseRedis, AuthRequired: true}},
+		{"not redis", redis("HTTP/1.1 400 Bad Request\r\n"), nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checkDatabase(t, (*scanner).RedisProbe, tt.serve, tt.want)
+		})
+	}
+}
+
+// writeMySQLRow sends the values of a row of the result set, as
+// length-encoded strings.
+func writeMySQLRow(conn net.Conn, seq byte, values ...string) {
+	var row []byte
+	for _, v := range values {
+		row = append(append(row, byte(len(v))), v...)
+	}
+	writeMySQLPacket(conn, seq, row)
+}
+
+func TestMySQLProbe(t *testing.T) {
+	mysql := func(handshake, login []byte) func(net.Conn) {
+		return func(conn net.Conn) {
+			writeMySQLPacket(conn, 0, handshake)
+			if _, seq, err := readMySQLPacket(conn); err != nil || seq != 1 {
+				return
+			}
+			writeMySQLPacket(conn, 2, login)
+			if query, _, err := readMySQLPacket(conn); err != nil || string(query) != "\x03SHOW DATABASES" {
+				return
+			}
+			writeMySQLPacket(conn, 1, []byte{1})
+			writeMySQLPacket(conn, 2, append([]byte{3}, "def"...))
+			writeMySQLPacket(conn, 3, []byte{0xfe, 0, 0, 2, 0})
+			writeMySQLRow(conn, 4, "information_schema")
+			writeMySQLRow(conn, 5, "shop")
+			writeMySQLPacket(conn, 6, []byte{0xfe, 0, 0, 2, 0})
+		}
+	}
+	handshake := append([]byte{10}, "8.0.36\x00"...)
+	handshake = append(handshake, make([]byte, 40)...)
+	ok := []byte{0, 0, 0, 2, 0, 0, 0}
+	denied := append([]byte{0xff, 0x15, 0x04}, "#28000Access denied for user ''@'10.0.0.1'"...)
+	notAllowed := append([]byte{0xff, 0x6a, 0x04}, "Host '10.0.0.1' is not allowed to connect"...)
+	tests := []struct {
+		name  string
+		serve func(net.Conn)
+		want  *DatabaseInfo
+	}{
+		{"anonymous", mysql(handshake, ok), &DatabaseInfo{Type: DatabaseMySQL, Version: "8.0.36", AnonymousAccessAllowed: true, DatabaseList: []string{"information_schema", "shop"}}},
+		{"denied", mysql(handshake, denied), &DatabaseInfo{Type: DatabaseMySQL, Version: "8.0.36", AuthRequired: true}},
+		{"host not allowed", mysql(notAllowed, nil), &DatabaseInfo{Type: DatabaseMySQL, AuthRequired: true}},
+		{"not mysql", func(conn net.Conn) { conn.Write([]byte("SSH-2.0-OpenSSH_9.6\r\n")) }, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checkDatabase(t, (*scanner).MySQLProbe, tt.serve, tt.want)
+		})
+	}
+}
+
+// writePostgresMessage sends a message of type typ carrying body.
+func writePostgresMessage(conn net.Conn, typ byte, body []byte) {
+	msg := append([]byte{typ}, binary.BigEndian.AppendUint32(nil, uint32(4+len(body)))...)
+	conn.Write(append(msg, body...))
+}
+
+func TestPostgreSQLProbe(t *testing.T) {
+	postgres := func(auth byte, body []byte) func(net.Conn) {
+		return func(conn net.Conn) {
+			var n [4]byte
+			if _, err := io.ReadFull(conn, n[:]); err != nil {
+				return
+			}
+			startup := make([]byte, binary.BigEndian.Uint32(n[:])-4)
+			if _, err := io.ReadFull(conn, startup); err != nil || !strings.Contains(string(startup), "user\x00postgres\x00") {
+				return
+			}
+			writePostgresMessage(conn, auth, body)
+			if auth != 'R' || binary.BigEndian.Uint32(body) != 0 {
+				return
+			}
+			writePostgresMessage(conn, 'S', []byte("client_encoding\x00UTF8\x00"))
+			writePostgresMessage(conn, 'S', []byte("server_version\x0016.2\x00"))
+			writePostgresMessage(conn, 'K', make([]byte, 8))
+			writePostgresMessage(conn, 'Z', []byte{'I'})
+			if typ, query, err := readPostgresMessage(conn); err != nil || typ != 'Q' || !strings.HasPrefix(string(query), "SELECT datname") {
+				return
+			}
+			writePostgresMessage(conn, 'T', make([]byte, 2))
+			for _, name := range []string{"postgres", "template1", "crm"} {
+				row := binary.BigEndian.AppendUint16(nil, 1)
+				row = binary.BigEndian.AppendUint32(row, uint32(len(name)))
+				writePostgresMessage(conn, 'D', append(row, name...))
+			}
+			writePostgresMessage(conn, 'C', []byte("SELECT 3\x00"))
+			writePostgresMessage(conn, 'Z', []byte{'I'})
+			io.Copy(io.Discard, conn)
+		}
+	}
+	tests := []struct {
+		name  string
+		serve func(net.Conn)
+		want  *DatabaseInfo
+	}{
+		{"trust", postgres('R', []byte{0, 0, 0, 0}), &DatabaseInfo{Type: DatabasePostgreSQL, Version: "16.2", AnonymousAccessAllowed: true, DatabaseList: []string{"postgres", "template1", "crm"}}},
+		{"password", postgres('R', []byte{0, 0, 0, 10}), &DatabaseInfo{Type: DatabasePostgreSQL, AuthRequired: true}},
+		{"no pg_hba.conf entry", postgres('E', []byte("SFATAL\x00C28000\x00\x00")), &DatabaseInfo{Type: DatabasePostgreSQL, AuthRequired: true}},
+		{"not postgres", func(conn net.Conn) { conn.Write([]byte("HTTP/1.1 400 Bad Request\r\n\r\n")) }, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checkDatabase(t, (*scanner).PostgreSQLProbe, tt.serve, tt.want)
+		})
+	}
+}
+
+// bsonRawDoc encodes a BSON document of the encoded elements.
+func bsonRawDoc(elements ...[]byte) []byte {
+	doc := []byte{0, 0, 0, 0}
+	for _, e := range elements {
+		doc = append(doc, e...)
+	}
+	doc = append(doc, 0)
+	binary.LittleEndian.PutUint32(doc, uint32(len(doc)))
+	return doc
+}
+
+// bsonNested encodes the element key holding doc, a document or, for typ
+// 0x04, an array.
+func bsonNested(typ byte, key string, doc []byte) []byte {
+	return append(append(append([]byte{typ}, key...), 0), doc...)
+}
+
+// bsonDouble encodes the double element key.
+func bsonDouble(key string, v float64) []byte {
+	return binary.LittleEndian.AppendUint64(append(append([]byte{0x01}, key...), 0), math.Float64bits(v))
+}
+
+// mongoServer returns a handler answering the commands of MongoDBProbe as a
+// server of wire version wire, which replies with listDatabases to the
+// listDatabases command.
+func mongoServer(wire int32, listDatabases []byte) func(net.Conn) {
+	return func(conn net.Conn) {
+		for {
+			var header [16]byte
+			if _, err := io.ReadFull(conn, header[:]); err != nil {
+				return
+			}
+			body := make([]byte, binary.LittleEndian.Uint32(header[:])-16)
+			if _, err := io.ReadFull(conn, body); err != nil {
+				return
+			}
+			opcode := binary.LittleEndian.Uint32(header[12:])
+			var cmd []byte
+			if opcode == mongoOpMsg {
+				cmd = body[5:]
+			} else {
+				_, rest, _ := strings.Cut(string(body[4:]), "\x00")
+				cmd = []byte(rest[8:])
+			}
+			doc, _, err := bsonDecode(cmd)
+			if err != nil {
+				return
+			}
+			var reply []byte
+			switch {
+			case doc["isMaster"] != nil:
+				reply = bsonRawDoc(bsonElement("ismaster", true), bsonElement("maxWireVersion", wire), bsonDouble("ok", 1))
+			case doc["buildInfo"] != nil:
+				reply = bsonRawDoc(bsonElement("version", "7.0.5"), bsonDouble("ok", 1))
+			case doc["listDatabases"] != nil:
+				reply = listDatabases
+			}
+			if opcode == mongoOpMsg && doc["$db"] != "admin" {
+				return
+			}
+
+			var msg []byte
+			if opcode == mongoOpMsg {
+				msg = append(binary.LittleEndian.AppendUint32(nil, 0), 0)
+			} else {
+				msg = make([]byte, 20)
+				binary.LittleEndian.PutUint32(msg[16:], 1)
+			}
+			msg = append(msg, reply...)
+			h := binary.LittleEndian.AppendUint32(nil, uint32(16+len(msg)))
+			h = binary.LittleEndian.AppendUint32(h, 2)
+			h = append(h, header[4:8]...)
+			if opcode == mongoOpMsg {
+				h = binary.LittleEndian.AppendUint32(h, mongoOpMsg)
+			} else {
+				h = binary.LittleEndian.AppendUint32(h, mongoOpReply)
+			}
+			conn.Write(append(h, msg...))
+		}
+	}
+}
+
+func TestMongoDBProbe(t *testing.T) {
+	databases := bsonRawDoc(
+		bsonNested(0x04, "databases", bsonRawDoc(
+			bsonNested(0x03, "0", bsonRawDoc(bsonElement("name", "admin"))),
+			bsonNested(0x03, "1", bsonRawDoc(bsonElement("name", "inventory"))),
+		)),
+		bsonDouble("ok", 1),
+	)
+	unauthorized := bsonRawDoc(bsonDouble("ok", 0), bsonElement("errmsg", "command listDatabases requires authentication"), bsonElement("code", int32(13)))
+	tests := []struct {
+		name  string
+		serve func(net.Conn)
+		want  *DatabaseInfo
+	}{
+		{"anonymous", mongoServer(21, databases), &DatabaseInfo{Type: DatabaseMongoDB, Version: "7.0.5", AnonymousAccessAllowed: true, DatabaseList: []string{"admin", "inventory"}}},
+		{"anonymous over OP_QUERY", mongoServer(5, databases), &DatabaseInfo{Type: DatabaseMongoDB, Version: "7.0.5", AnonymousAccessAllowed: true, DatabaseList: []string{"admin", "inventory"}}},
+		{"access control", mongoServer(21, unauthorized), &DatabaseInfo{Type: DatabaseMongoDB, Version: "7.0.5", AuthRequired: true}},
+		{"not mongodb", func(conn net.Conn) { conn.Write([]byte("HTTP/1.1 400 Bad Request\r\n\r\n")) }, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checkDatabase(t, (*scanner).MongoDBProbe, tt.serve, tt.want)
+		})
+	}
+}
+
+func TestBSONDecode(t *testing.T) {
+	doc := bsonRawDoc(
+		bsonDouble("ok", 1),
+		bsonElement("version", "7.0.5"),
+		bsonElement("wire", int32(21)),
+		bsonElement("readOnly", false),
+		append(append([]byte{0x12}, "size\x00"...), binary.LittleEndian.AppendUint64(nil, 1<<40)...),
+		append([]byte{0x07}, "_id\x00abcdefghijkl"...),
+		append([]byte{0x0a}, "none\x00"...),
+		bsonNested(0x03, "nested", bsonRawDoc(bsonElement("a", "b"))),
+	)
+	got, n, err := bsonDecode(append(doc, "trailing"...))
+	if err != nil || n != len(doc) {
+		t.Fatalf("bsonDecode() = %v, %d, %v, want a document of %d bytes", got, n, err, len(doc))
+	}
+	want := map[string]any{"ok": 1.0, "version": "7.0.5", "wire": int32(21), "readOnly": false, "size": int64(1 << 40), "_id": nil, "none": nil}
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("%s = %v (%T), want %v (%T)", k, got[k], got[k], v, v)
+		}
+	}
+	if nested, _ := got["nested"].(map[string]any); nested["a"] != "b" {
+		t.Errorf("nested = %v", got["nested"])
+	}
+	if !bsonOK(got) {
+		t.Error("bsonOK() = false")
+	}
+
+	for i := 0; i < len(doc)-1; i++ {
+		if _, _, err := bsonDecode(doc[:i]); err == nil {
+			t.Errorf("bsonDecode() of the first %d bytes: error = nil", i)
+		}
+	}
+	if _, _, err := bsonDecode(bsonRawDoc([]byte{0x42, 'k', 0})); err == nil {
+		t.Error("bsonDecode() of an unknown type: error = nil")
+	}
+}
+
+func TestElasticsearchProbe(t *testing.T) {
+	elasticsearch := func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Elastic-Product", "Elasticsearch")
+		switch r.URL.Path {
+		case "/":
+			fmt.Fprint(w, `{"name":"node-1","version":{"number":"8.12.2"},"tagline":"You Know, for Search"}`)
+		case "/_cat/indices":
+			fmt.Fprint(w, "logs-2024.03\n.kibana_1\n")
+		}
+	}
+	secured := func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("WWW-Authenticate", `Basic realm="security" charset="UTF-8"`)
+		w.WriteHeader(http.StatusUnauthorized)
+	}
+	notES := func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "<html>It works!</html>")
+	}
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		tls     bool
+		want    *DatabaseInfo
+	}{
+		{"anonymous", elasticsearch, false, &DatabaseInfo{Type: DatabaseElasticsearch, Version: "8.12.2", AnonymousAccessAllowed: true, DatabaseList: []string{"logs-2024.03", ".kibana_1"}}},
+		{"anonymous over HTTPS", elasticsearch, true, &DatabaseInfo{Type: DatabaseElasticsearch, Version: "8.12.2", AnonymousAccessAllowed: true, DatabaseList: []string{"logs-2024.03", ".kibana_1"}}},
+		{"security enabled", secured, true, &DatabaseInfo{Type: DatabaseElasticsearch, AuthRequired: true}},
+		{"not elasticsearch", notES, false, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewUnstartedServer(tt.handler)
+			if tt.tls {
+				srv.StartTLS()
+			} else {
+				srv.Start()
+			}
+			defer srv.Close()
+			u, _ := url.Parse(srv.URL)
+			port, _ := strconv.Atoi(u.Port())
+
+			s := newTestScanner()
+			s.dst = net.IPv4(127, 0, 0, 1)
+			info, err := s.ElasticsearchProbe(context.Background(), layers.TCPPort(port))
+			if tt.want == nil {
+				if !errors.Is(err, ErrNotDatabase) {
+					t.Errorf("ElasticsearchProbe() error = %v, want ErrNotDatabase", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ElasticsearchProbe() error = %v", err)
+			}
+			if info.Version != tt.want.Version || info.AuthRequired != tt.want.AuthRequired ||
+				info.AnonymousAccessAllowed != tt.want.AnonymousAccessAllowed || !slices.Equal(info.DatabaseList, tt.want.DatabaseList) {
+				t.Errorf("ElasticsearchProbe() = %+v, want %+v", info, tt.want)
+			}
+		})
+	}
+}
+
+func TestDatabaseServiceHint(t *testing.T) {
+	tests := []struct {
+		info DatabaseInfo
+		want string
+	}{
+		{DatabaseInfo{Type: DatabaseRedis, Version: "7.2.4", AnonymousAccessAllowed: true}, "redis-noauth"},
+		{DatabaseInfo{Type: DatabaseMySQL, Version: "8.0.36", AuthRequired: true}, "mysql 8.0.36"},
+		{DatabaseInfo{Type: DatabasePostgreSQL, AuthRequired: true}, "postgresql"},
+	}
+	for _, tt := range tests {
+		if got := tt.info.ServiceHint(); got != tt.want {
+			t.Errorf("ServiceHint() of %+v = %q, want %q", tt.info, got, tt.want)
+		}
+	}
+}
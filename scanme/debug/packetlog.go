@@ -0,0 +1,156 @@
+// Package debug records the packets a scanner exchanges with its target,
+// to find out why a port got the state it did, or to keep an audit trail of
+// what was sent.
+package debug
+
+import (
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"github.com/google/gopacket/pcapgo"
+)
+
+// DefaultMaxPacketLogSize is the number of packets a PacketLogger keeps
+// unless set with WithMaxPacketLogSize.
+const DefaultMaxPacketLogSize = 10000
+
+// snapLen is the snapshot length written to PCAP files, large enough for
+// any packet a scanner sends or captures.
+const snapLen = 65536
+
+// Directions of a PacketEvent.
+const (
+	DirectionTx = "tx"
+	DirectionRx = "rx"
+)
+
+// PacketEvent is a packet sent or received by a scanner.
+type PacketEvent struct {
+	// Direction is DirectionTx for packets sent, DirectionRx for packets
+	// received.
+	Direction string
+	Timestamp time.Time
+	// Layers lists the layers decoded from the packet, outermost first,
+	// such as ["Ethernet", "IPv4", "TCP"].
+	Layers   []string
+	RawBytes []byte
+}
+
+// Option configures a PacketLogger.
+type Option func(*PacketLogger)
+
+// WithMaxPacketLogSize sets how many packets the logger keeps, the oldest
+// being dropped first. A n lower than one is ignored.
+func WithMaxPacketLogSize(n int) Option {
+	return func(l *PacketLogger) {
+		if n > 0 {
+			l.max = n
+		}
+	}
+}
+
+// PacketLogger keeps the last packets recorded in a ring buffer. Pass it to
+// a scanner with scanme.WithPacketLogging. It is safe for concurrent use.
+type PacketLogger struct {
+	mu     sync.Mutex
+	max    int
+	events []PacketEvent
+	// next is the index the next event is stored at once events is full.
+	next    int
+	dropped int
+}
+
+// NewPacketLogger returns an empty logger keeping up to
+// DefaultMaxPacketLogSize packets, see WithMaxPacketLogSize.
+func NewPacketLogger(opts ...Option) *PacketLogger {
+	l := &PacketLogger{max: DefaultMaxPacketLogSize}
+	for _, opt := range opts {
+		opt(l)
+	}
+	return l
+}
+
+// Record logs a copy of the Ethernet frame data, sent or received at ts
+// according to direction.
+func (l *PacketLogger) Record(direction string, ts time.Time, data []byte) {
+	if l == nil {
+		return
+	}
+	e := PacketEvent{
+		Direction: direction,
+		Timestamp: ts,
+		RawBytes:  append([]byte(nil), data...),
+	}
+	packet := gopacket.NewPacket(e.RawBytes, layers.LayerTypeEthernet, gopacket.NoCopy)
+	for _, layer := range packet.Layers() {
+		e.Layers = append(e.Layers, layer.LayerType().String())
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if len(l.events) < l.max {
+		l.events = append(l.events, e)
+		return
+	}
+	l.events[l.next] = e
+	l.next = (l.next + 1) % l.max
+	l.dropped++
+}
+
+// Events returns the packets logged, oldest first.
+func (l *PacketLogger) Events() []PacketEvent {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	events := make([]PacketEvent, 0, len(l.events))
+	events = append(events, l.events[l.next:]...)
+	return append(events, l.events[:l.next]...)
+}
+
+// Dropped returns the number of packets dropped from the log to make room
+// for newer ones.
+func (l *PacketLogger) Dropped() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.dropped
+}
+
+// WriteText writes the packets logged to w, oldest first: a line with the
+// time, direction, length and layers of every packet, followed by its
+// bytes in hex.
+func (l *PacketLogger) WriteText(w io.Writer) error {
+	for _, e := range l.Events() {
+		_, err := fmt.Fprintf(w, "%s %s %d bytes %s\n%s",
+			e.Timestamp.Format("15:04:05.000000"), e.Direction, len(e.RawBytes),
+			strings.Join(e.Layers, "/"), hex.Dump(e.RawBytes))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WritePCAP writes the packets logged to w as a PCAP file, oldest first,
+// to be opened with Wireshark or tcpdump.
+func (l *PacketLogger) WritePCAP(w io.Writer) error {
+	pw := pcapgo.NewWriter(w)
+	if err := pw.WriteFileHeader(snapLen, layers.LinkTypeEthernet); err != nil {
+		return err
+	}
+	for _, e := range l.Events() {
+		ci := gopacket.CaptureInfo{
+			Timestamp:     e.Timestamp,
+			CaptureLength: len(e.RawBytes),
+			Length:        len(e.RawBytes),
+		}
+		if err := pw.WritePacket(ci, e.RawBytes); err != nil {
+			return err
+		}
+	}
+	return nil
+}
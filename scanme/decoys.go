@@ -0,0 +1,34 @@
+package scanme
+
+import (
+	"errors"
+	"math/rand"
+
+	"github.com/CyberRoute/scanme/scanme/queue"
+	"github.com/google/gopacket/layers"
+)
+
+// sendDecoys sends a copy of the tcp probe spoofed from every decoy address,
+// in random order and each from a random ephemeral source port. Decoys are
+// queued with low priority, so that they are dropped rather than holding up
+// the probes when the send queue is full. It returns the number of packets
+// sent and dropped.
+func (s *scanner) sendDecoys(eth *layers.Ethernet, ip4 layers.IPv4, tcp layers.TCP) (sent, dropped int) {
+	for _, i := range rand.Perm(len(s.decoys)) {
+		ip4.SrcIP = s.decoys[i]
+		tcp.SrcPort = layers.TCPPort(32768 + rand.Intn(28232))
+		if err := tcp.SetNetworkLayerForChecksum(&ip4); err != nil {
+			s.logger.Error("error preparing decoy packet", "decoy", s.decoys[i], "err", err)
+			continue
+		}
+		if err := s.sendProbe(queue.PriorityLow, eth, &ip4, &tcp); errors.Is(err, queue.ErrQueueFull) {
+			dropped++
+			continue
+		} else if err != nil {
+			s.logger.Error("error sending decoy packet", "decoy", s.decoys[i], "err", err)
+			continue
+		}
+		sent++
+	}
+	return sent, dropped
+}
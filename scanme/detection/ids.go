@@ -0,0 +1,133 @@
+// Package detection spots defensive systems reacting to a scan while it
+// runs, so that the scan can adapt.
+package detection
+
+import (
+	"sync"
+	"time"
+
+	"github.com/google/gopacket/layers"
+)
+
+const (
+	// WindowSize is the number of consecutive probes whose response rate
+	// IDSDetector compares with the baseline.
+	WindowSize = 100
+	// dropThreshold is the drop of the response rate from the baseline,
+	// as a fraction of it, above which an IDSEvent is sent.
+	dropThreshold = 0.5
+	// minBaseline is the baseline response rate below which drops are not
+	// looked for: a host answering few probes gives too few samples.
+	minBaseline = 0.1
+)
+
+// IDSEvent reports a sudden drop of the response rate of a scan, typical of
+// an IDS or IPS starting to rate limit or drop the replies of the target.
+type IDSEvent struct {
+	// Port is the last port of the window of probes where the drop was seen.
+	Port layers.TCPPort
+	// DropRate is the drop of the response rate from the baseline, as a
+	// fraction of it: 0.8 means 80% fewer responses.
+	DropRate  float64
+	Timestamp time.Time
+}
+
+// window counts the probes of a window and the responses they got.
+type window struct {
+	last     layers.TCPPort
+	sent     int
+	answered int
+}
+
+// IDSDetector compares the response rate of every window of WindowSize
+// probes with the baseline of the windows before it, and sends an IDSEvent
+// when it falls by more than half. A window is judged once the next one is
+// complete, leaving its late replies time to arrive. An IDSDetector is safe
+// for concurrent use; a nil *IDSDetector detects nothing.
+type IDSDetector struct {
+	events chan<- IDSEvent
+
+	mu       sync.Mutex
+	windowOf map[layers.TCPPort]int
+	windows  []window
+	// baseline is the mean response rate of the windows judged normal, of
+	// which there are normal.
+	baseline float64
+	normal   int
+}
+
+// NewIDSDetector returns a detector sending its events on events. Events
+// are dropped when events is full.
+func NewIDSDetector(events chan<- IDSEvent) *IDSDetector {
+	return &IDSDetector{
+		events:   events,
+		windowOf: make(map[layers.TCPPort]int),
+	}
+}
+
+// ProbeSent records a probe sent to port. It returns true when it completes
+// a window and the one before it saw its response rate drop, in which case
+// the scan should slow down.
+func (d *IDSDetector) ProbeSent(port layers.TCPPort) bool {
+	if d == nil {
+		return false
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if len(d.windows) == 0 || d.windows[len(d.windows)-1].sent == WindowSize {
+		d.windows = append(d.windows, window{})
+	}
+	current := len(d.windows) - 1
+	w := &d.windows[current]
+	w.sent++
+	w.last = port
+	d.windowOf[port] = current
+	if w.sent < WindowSize || current == 0 {
+		return false
+	}
+	return d.judge(current - 1)
+}
+
+// ResponseReceived records an answer from port to one of the probes, be it
+// a SYN-ACK, a RST or an ICMP unreachable.
+func (d *IDSDetector) ResponseReceived(port layers.TCPPort) {
+	if d == nil {
+		return
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if i, ok := d.windowOf[port]; ok {
+		d.windows[i].answered++
+		// Count a single answer per probe.
+		delete(d.windowOf, port)
+	}
+}
+
+// judge compares the response rate of window i with the baseline, sending
+// an IDSEvent and returning true when it dropped. d.mu must be held.
+func (d *IDSDetector) judge(i int) bool {
+	w := d.windows[i]
+	rate := float64(w.answered) / float64(w.sent)
+	if d.normal == 0 || d.baseline < minBaseline {
+		d.addToBaseline(rate)
+		return false
+	}
+	drop := 1 - rate/d.baseline
+	if drop <= dropThreshold {
+		d.addToBaseline(rate)
+		return false
+	}
+	select {
+	case d.events <- IDSEvent{Port: w.last, DropRate: drop, Timestamp: time.Now()}:
+	default:
+	}
+	return true
+}
+
+// addToBaseline folds the response rate of a normal window into the
+// baseline. d.mu must be held.
+func (d *IDSDetector) addToBaseline(rate float64) {
+	d.normal++
+	d.baseline += (rate - d.baseline) / float64(d.normal)
+}
@@ -0,0 +1,106 @@
+package scanme
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"time"
+
+	"github.com/google/gopacket/layers"
+)
+
+// PortChange is a port present in both scans whose state differs.
+type PortChange struct {
+	Port layers.TCPPort `json:"port"`
+	From string         `json:"from"`
+	To   string         `json:"to"`
+}
+
+// ScanDiff is the difference between two scans of the same target.
+type ScanDiff struct {
+	Target net.IP    `json:"target"`
+	From   time.Time `json:"from"`
+	To     time.Time `json:"to"`
+	// TimeBetween is the time elapsed between the start of both scans.
+	TimeBetween time.Duration `json:"time_between"`
+	// Opened holds the ports found in the newer scan only, Closed the ports
+	// found in the older scan only.
+	Opened  []layers.TCPPort `json:"opened"`
+	Closed  []layers.TCPPort `json:"closed"`
+	Changed []PortChange     `json:"changed"`
+}
+
+// Empty reports whether both scans found the same ports in the same state.
+func (d *ScanDiff) Empty() bool {
+	return len(d.Opened) == 0 && len(d.Closed) == 0 && len(d.Changed) == 0
+}
+
+// Diff compares the scan a with the later scan b of the same target. It is a
+// purely in-memory operation. Ports are listed in ascending order.
+func Diff(a, b *ScanResult) *ScanDiff {
+	d := &ScanDiff{
+		Target:      b.Target,
+		From:        a.StartTime,
+		To:          b.StartTime,
+		TimeBetween: b.StartTime.Sub(a.StartTime),
+	}
+
+	before := make(map[layers.TCPPort]string, len(a.Ports))
+	for _, p := range a.Ports {
+		before[p.Port] = p.State
+	}
+	after := make(map[layers.TCPPort]bool, len(b.Ports))
+	for _, p := range b.Ports {
+		after[p.Port] = true
+		state, found := before[p.Port]
+		switch {
+		case !found:
+			d.Opened = append(d.Opened, p.Port)
+		case state != p.State:
+			d.Changed = append(d.Changed, PortChange{Port: p.Port, From: state, To: p.State})
+		}
+	}
+	for _, p := range a.Ports {
+		if !after[p.Port] {
+			d.Closed = append(d.Closed, p.Port)
+		}
+	}
+
+	return d
+}
+
+// WriteDiffText writes d in a human readable form.
+func WriteDiffText(w io.Writer, d *ScanDiff) error {
+	if _, err := fmt.Fprintf(w, "Changes for %v between %s and %s (%s)\n",
+		d.Target, d.From.Format(time.RFC3339), d.To.Format(time.RFC3339), d.TimeBetween); err != nil {
+		return err
+	}
+	if d.Empty() {
+		_, err := fmt.Fprintln(w, "No changes")
+		return err
+	}
+	for _, p := range d.Opened {
+		if _, err := fmt.Fprintf(w, "+ %d opened\n", p); err != nil {
+			return err
+		}
+	}
+	for _, p := range d.Closed {
+		if _, err := fmt.Fprintf(w, "- %d closed\n", p); err != nil {
+			return err
+		}
+	}
+	for _, c := range d.Changed {
+		if _, err := fmt.Fprintf(w, "~ %d %s -> %s\n", c.Port, c.From, c.To); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteDiffJSON writes d as an indented JSON document.
+func WriteDiffJSON(w io.Writer, d *ScanDiff) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(d)
+}
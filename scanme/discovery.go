@@ -0,0 +1,37 @@
+package scanme
+
+import (
+	"net"
+	"time"
+
+	"github.com/CyberRoute/scanme/scanme/discovery"
+)
+
+// MDNSRecord is a resource record advertised via mDNS, see discovery.MDNSRecord.
+type MDNSRecord = discovery.MDNSRecord
+
+// MDNSDiscover enumerates the services advertised via mDNS on the local link
+// of iface. It is a convenience wrapper around discovery.MDNSDiscover.
+func MDNSDiscover(iface *net.Interface, timeout time.Duration) ([]MDNSRecord, error) {
+	return discovery.MDNSDiscover(iface, timeout)
+}
+
+// NetBIOSResolve returns the NetBIOS name of ip, see discovery.NetBIOSResolve.
+func NetBIOSResolve(ip net.IP, timeout time.Duration) (string, error) {
+	return discovery.NetBIOSResolve(ip, timeout)
+}
+
+// NetBIOSSweep resolves the NetBIOS names of every host in subnet, see
+// discovery.NetBIOSSweep.
+func NetBIOSSweep(subnet *net.IPNet, timeout time.Duration) (map[string]string, error) {
+	return discovery.NetBIOSSweep(subnet, timeout)
+}
+
+// UPnPDevice is a device found by UPnPDiscover, see discovery.UPnPDevice.
+type UPnPDevice = discovery.UPnPDevice
+
+// UPnPDiscover finds the UPnP devices on the local link of iface, see
+// discovery.UPnPDiscover.
+func UPnPDiscover(iface *net.Interface, timeout time.Duration) ([]UPnPDevice, error) {
+	return discovery.UPnPDiscover(iface, timeout)
+}
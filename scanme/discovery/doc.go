@@ -0,0 +1,4 @@
+// Package discovery implements service and device discovery on the local
+// network, such as mDNS/DNS-SD, which runs before (and independently of)
+// port scanning.
+package discovery
@@ -0,0 +1,104 @@
+package discovery
+
+import (
+	"errors"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/miekg/dns"
+	"golang.org/x/net/ipv4"
+)
+
+// mdnsGroup is the IPv4 multicast group and port used by mDNS (RFC 6762).
+var mdnsGroup = &net.UDPAddr{IP: net.IPv4(224, 0, 0, 251), Port: 5353}
+
+// mdnsServicesQuery is the DNS-SD meta-query that enumerates every service
+// type advertised on the link (RFC 6763, section 9).
+const mdnsServicesQuery = "_services._dns-sd._udp.local."
+
+// MDNSRecord is a single resource record advertised via mDNS, TTL holding
+// its time to live in seconds.
+type MDNSRecord struct {
+	Name string
+	Type string
+	TTL  string
+	Data string
+}
+
+// MDNSDiscover sends a DNS-SD service enumeration query to the mDNS multicast
+// group on iface and collects the PTR records answered within timeout.
+// Duplicate records sent by several responders are only returned once.
+func MDNSDiscover(iface *net.Interface, timeout time.Duration) ([]MDNSRecord, error) {
+	if iface == nil {
+		return nil, errors.New("mdns: no interface given")
+	}
+
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4zero, Port: 0})
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	pc := ipv4.NewPacketConn(conn)
+	if err := pc.SetMulticastInterface(iface); err != nil {
+		return nil, err
+	}
+	if err := pc.SetMulticastTTL(255); err != nil {
+		return nil, err
+	}
+
+	m := new(dns.Msg)
+	m.SetQuestion(mdnsServicesQuery, dns.TypePTR)
+	m.RecursionDesired = false
+	query, err := m.Pack()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := conn.WriteTo(query, mdnsGroup); err != nil {
+		return nil, err
+	}
+
+	if err := conn.SetReadDeadline(time.Now().Add(timeout)); err != nil {
+		return nil, err
+	}
+
+	var records []MDNSRecord
+	seen := make(map[MDNSRecord]bool)
+	buf := make([]byte, 9000)
+	for {
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			var netErr net.Error
+			if errors.As(err, &netErr) && netErr.Timeout() {
+				break
+			}
+			return records, err
+		}
+
+		in := new(dns.Msg)
+		if err := in.Unpack(buf[:n]); err != nil {
+			// Not a well formed DNS message, ignore it.
+			continue
+		}
+
+		for _, rr := range append(in.Answer, in.Extra...) {
+			ptr, ok := rr.(*dns.PTR)
+			if !ok {
+				continue
+			}
+			record := MDNSRecord{
+				Name: ptr.Hdr.Name,
+				Type: dns.TypeToString[ptr.Hdr.Rrtype],
+				TTL:  strconv.FormatUint(uint64(ptr.Hdr.Ttl), 10),
+				Data: ptr.Ptr,
+			}
+			if !seen[record] {
+				seen[record] = true
+				records = append(records, record)
+			}
+		}
+	}
+
+	return records, nil
+}
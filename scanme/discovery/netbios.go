@@ -0,0 +1,231 @@
+package discovery
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net"
+	"strings"
+	"time"
+
+	scannet "github.com/CyberRoute/scanme/scanme/net"
+)
+
+// netbiosPort is the NetBIOS Name Service port (RFC 1002).
+const netbiosPort = 137
+
+// minSweepPrefix is the shortest prefix NetBIOSSweep accepts: a /16 already
+// holds 65534 hosts, more than any broadcast domain NetBIOS names are found
+// in.
+const minSweepPrefix = 16
+
+// ErrNoNetBIOSName is returned when a host answers the node status request
+// without registering a unique workstation name.
+var ErrNoNetBIOSName = errors.New("netbios: no unique name in response")
+
+// NetBIOSResolve sends a NetBIOS Name Service node status request (NBSTAT)
+// for the wildcard name "*" to ip on UDP port 137, and returns the primary
+// NetBIOS name found in the reply. This is what "nbtstat -A" does on Windows.
+func NetBIOSResolve(ip net.IP, timeout time.Duration) (string, error) {
+	ip4 := ip.To4()
+	if ip4 == nil {
+		return "", fmt.Errorf("netbios: %v is not an IPv4 address", ip)
+	}
+
+	conn, err := net.ListenPacket("udp4", "")
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+
+	txid := uint16(rand.Intn(0xffff))
+	if _, err := conn.WriteTo(netbiosStatusRequest(txid), &net.UDPAddr{IP: ip4, Port: netbiosPort}); err != nil {
+		return "", err
+	}
+	if err := conn.SetReadDeadline(time.Now().Add(timeout)); err != nil {
+		return "", err
+	}
+
+	buf := make([]byte, 1500)
+	for {
+		n, addr, err := conn.ReadFrom(buf)
+		if err != nil {
+			return "", err
+		}
+		udpAddr, ok := addr.(*net.UDPAddr)
+		if !ok || !udpAddr.IP.Equal(ip4) {
+			continue
+		}
+		if n < 2 || binary.BigEndian.Uint16(buf[:2]) != txid {
+			continue
+		}
+		return parseNetBIOSStatus(buf[:n])
+	}
+}
+
+// NetBIOSSweep sends a node status request to every host address of subnet
+// from a single UDP socket and collects the replies received while sending,
+// then within timeout of the last request. The returned map is keyed by IP
+// address and holds the primary NetBIOS name. Hosts that do not answer are
+// not part of the map. Networks with a prefix shorter than /16 are rejected.
+func NetBIOSSweep(subnet *net.IPNet, timeout time.Duration) (map[string]string, error) {
+	hosts, err := sweepRange(subnet)
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := net.ListenPacket("udp4", "")
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	txid := uint16(rand.Intn(0xffff))
+	replies := make(chan sweepReplies, 1)
+	go readSweepReplies(conn, subnet, txid, replies)
+
+	request := netbiosStatusRequest(txid)
+	for ip, ok := hosts.Next(); ok; ip, ok = hosts.Next() {
+		if _, err := conn.WriteTo(request, &net.UDPAddr{IP: ip, Port: netbiosPort}); err != nil {
+			conn.Close()
+			<-replies
+			return nil, err
+		}
+	}
+
+	if err := conn.SetReadDeadline(time.Now().Add(timeout)); err != nil {
+		conn.Close()
+		<-replies
+		return nil, err
+	}
+	r := <-replies
+	return r.names, r.err
+}
+
+// sweepReplies holds the names collected by readSweepReplies and the error
+// that stopped it, nil once the read deadline expired.
+type sweepReplies struct {
+	names map[string]string
+	err   error
+}
+
+// readSweepReplies reads the node status replies to the requests of
+// transaction txid sent to the hosts of subnet, until the read deadline of conn expires
+// or conn is closed, and sends the names found on done.
+func readSweepReplies(conn net.PacketConn, subnet *net.IPNet, txid uint16, done chan<- sweepReplies) {
+	names := make(map[string]string)
+	buf := make([]byte, 1500)
+	for {
+		n, addr, err := conn.ReadFrom(buf)
+		if err != nil {
+			var netErr net.Error
+			if errors.As(err, &netErr) && netErr.Timeout() {
+				err = nil
+			}
+			done <- sweepReplies{names: names, err: err}
+			return
+		}
+		udpAddr, ok := addr.(*net.UDPAddr)
+		if !ok || !subnet.Contains(udpAddr.IP) {
+			continue
+		}
+		if n < 2 || binary.BigEndian.Uint16(buf[:2]) != txid {
+			continue
+		}
+		name, err := parseNetBIOSStatus(buf[:n])
+		if err != nil {
+			continue
+		}
+		names[udpAddr.IP.String()] = name
+	}
+}
+
+// netbiosStatusRequest builds an NBSTAT query for the wildcard name "*".
+func netbiosStatusRequest(txid uint16) []byte {
+	req := make([]byte, 0, 50)
+	req = binary.BigEndian.AppendUint16(req, txid)
+	req = append(req,
+		0x00, 0x00, // flags: query
+		0x00, 0x01, // questions
+		0x00, 0x00, // answer RRs
+		0x00, 0x00, // authority RRs
+		0x00, 0x00, // additional RRs
+	)
+
+	// The wildcard name "*" padded with NULs to 16 bytes, first-level encoded
+	// as described in RFC 1001 section 14.1.
+	name := make([]byte, 16)
+	name[0] = '*'
+	req = append(req, 0x20)
+	for _, b := range name {
+		req = append(req, 'A'+(b>>4), 'A'+(b&0x0f))
+	}
+	req = append(req, 0x00)
+
+	req = append(req,
+		0x00, 0x21, // type NBSTAT
+		0x00, 0x01, // class IN
+	)
+	return req
+}
+
+// parseNetBIOSStatus extracts the primary name from an NBSTAT response: the
+// first unique (non group) name with the workstation suffix 0x00.
+func parseNetBIOSStatus(data []byte) (string, error) {
+	const headerLen = 12
+	if len(data) < headerLen {
+		return "", errors.New("netbios: short response")
+	}
+	if binary.BigEndian.Uint16(data[6:8]) == 0 {
+		return "", errors.New("netbios: response has no answer")
+	}
+
+	// Skip the encoded question name echoed back in the answer.
+	off := headerLen
+	for off < len(data) && data[off] != 0 {
+		off += int(data[off]) + 1
+	}
+	off++ // terminating zero length label
+
+	// type (2), class (2), TTL (4), rdlength (2), number of names (1)
+	if off+11 > len(data) {
+		return "", errors.New("netbios: truncated answer")
+	}
+	count := int(data[off+10])
+	off += 11
+
+	for i := 0; i < count; i++ {
+		if off+18 > len(data) {
+			break
+		}
+		entry := data[off : off+18]
+		off += 18
+
+		suffix := entry[15]
+		flags := binary.BigEndian.Uint16(entry[16:18])
+		if suffix == 0x00 && flags&0x8000 == 0 {
+			return strings.TrimRight(string(entry[:15]), " \x00"), nil
+		}
+	}
+
+	return "", ErrNoNetBIOSName
+}
+
+// sweepRange returns an iterator over the host addresses of the IPv4
+// network subnet, which is rejected when its prefix is shorter than
+// minSweepPrefix.
+func sweepRange(subnet *net.IPNet) (*scannet.IPRange, error) {
+	if subnet == nil || subnet.IP.To4() == nil {
+		return nil, errors.New("netbios: subnet must be an IPv4 network")
+	}
+	ones, bits := subnet.Mask.Size()
+	if bits == 128 {
+		// IPv4 network expressed with a 16 byte mask.
+		ones, bits = ones-96, 32
+	}
+	if bits != 32 || ones < minSweepPrefix {
+		return nil, fmt.Errorf("netbios: %v: prefix shorter than /%d", subnet, minSweepPrefix)
+	}
+	return scannet.NewIPRange(fmt.Sprintf("%v/%d", subnet.IP.To4(), ones))
+}
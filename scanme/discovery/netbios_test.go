@@ -0,0 +1,100 @@
+package discovery
+
+import (
+	"encoding/binary"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// netbiosStatusReply answers request with a node status reply registering
+// name as a unique workstation name, after a group name.
+func netbiosStatusReply(request []byte, name string) []byte {
+	reply := append([]byte(nil), request[:2]...)
+	reply = append(reply,
+		0x84, 0x00, // flags: authoritative response
+		0x00, 0x00, // questions
+		0x00, 0x01, // answer RRs
+		0x00, 0x00, // authority RRs
+		0x00, 0x00, // additional RRs
+	)
+	reply = append(reply, request[12:12+34]...) // the encoded wildcard name
+	reply = append(reply,
+		0x00, 0x21, // type NBSTAT
+		0x00, 0x01, // class IN
+		0x00, 0x00, 0x00, 0x00, // TTL
+		0x00, 2*18+1, // rdlength
+		2, // number of names
+	)
+	entry := func(name string, suffix byte, flags uint16) []byte {
+		e := []byte(name + strings.Repeat(" ", 15-len(name)))
+		e = append(e, suffix)
+		return binary.BigEndian.AppendUint16(e, flags)
+	}
+	reply = append(reply, entry("WORKGROUP", 0x00, 0x8000)...)
+	return append(reply, entry(name, 0x00, 0)...)
+}
+
+func TestParseNetBIOSStatus(t *testing.T) {
+	request := netbiosStatusRequest(0x1234)
+	name, err := parseNetBIOSStatus(netbiosStatusReply(request, "FILESERVER"))
+	if err != nil || name != "FILESERVER" {
+		t.Errorf("parseNetBIOSStatus() = %q, %v, want FILESERVER", name, err)
+	}
+
+	reply := netbiosStatusReply(request, "FILESERVER")
+	if _, err := parseNetBIOSStatus(reply[:len(reply)-18]); err != ErrNoNetBIOSName {
+		t.Errorf("parseNetBIOSStatus() of a group name only: error = %v, want ErrNoNetBIOSName", err)
+	}
+	if _, err := parseNetBIOSStatus(reply[:20]); err == nil {
+		t.Error("parseNetBIOSStatus() of a truncated reply: error = nil")
+	}
+}
+
+func TestNetBIOSSweepPrefix(t *testing.T) {
+	for _, cidr := range []string{"10.0.0.0/8", "172.16.0.0/15", "2001:db8::/120"} {
+		_, subnet, _ := net.ParseCIDR(cidr)
+		if _, err := NetBIOSSweep(subnet, time.Millisecond); err == nil {
+			t.Errorf("NetBIOSSweep(%s) error = nil, want the network rejected", cidr)
+		}
+	}
+
+	_, subnet, _ := net.ParseCIDR("10.1.0.0/16")
+	hosts, err := sweepRange(subnet)
+	if err != nil || hosts.Len() != 65534 {
+		t.Fatalf("sweepRange(%v) = %v, %v, want 65534 hosts", subnet, hosts, err)
+	}
+	if first, _ := hosts.Next(); !first.Equal(net.IPv4(10, 1, 0, 1)) {
+		t.Errorf("first host = %v, want 10.1.0.1", first)
+	}
+}
+
+func TestNetBIOSSweep(t *testing.T) {
+	conn, err := net.ListenPacket("udp4", "127.0.0.2:137")
+	if err != nil {
+		t.Skipf("cannot bind the NetBIOS name service port: %v", err)
+	}
+	defer conn.Close()
+	go func() {
+		buf := make([]byte, 1500)
+		for {
+			n, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			if n >= 12+34 {
+				conn.WriteTo(netbiosStatusReply(buf[:n], "WIN-DC01"), addr) //nolint:errcheck // best effort
+			}
+		}
+	}()
+
+	_, subnet, _ := net.ParseCIDR("127.0.0.0/29")
+	names, err := NetBIOSSweep(subnet, 200*time.Millisecond)
+	if err != nil {
+		t.Fatalf("NetBIOSSweep() error = %v", err)
+	}
+	if len(names) != 1 || names["127.0.0.2"] != "WIN-DC01" {
+		t.Errorf("NetBIOSSweep() = %v, want 127.0.0.2 named WIN-DC01", names)
+	}
+}
@@ -0,0 +1,187 @@
+package discovery
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/ipv4"
+)
+
+// ssdpGroup is the IPv4 multicast group and port used by SSDP.
+var ssdpGroup = &net.UDPAddr{IP: net.IPv4(239, 255, 255, 250), Port: 1900}
+
+// ssdpSearch is the M-SEARCH request asking every UPnP device to answer.
+const ssdpSearch = "M-SEARCH * HTTP/1.1\r\n" +
+	"HOST: 239.255.255.250:1900\r\n" +
+	"MAN: \"ssdp:discover\"\r\n" +
+	"MX: 2\r\n" +
+	"ST: ssdp:all\r\n" +
+	"\r\n"
+
+// UPnPDevice is a device that answered an SSDP search. The SSDP headers are
+// always set, the fields coming from the XML device description are only set
+// when it could be fetched from Location.
+type UPnPDevice struct {
+	Location string
+	USN      string
+	Server   string
+	ST       string
+
+	FriendlyName string
+	Manufacturer string
+	ModelName    string
+}
+
+// UPnPDiscover multicasts an SSDP M-SEARCH for all services on iface and
+// collects the devices answering within timeout. The XML description of each
+// device is fetched from its LOCATION URL to fill in its friendly name,
+// manufacturer and model. Devices are identified by their USN, so a device
+// advertising several services is only returned once.
+func UPnPDiscover(iface *net.Interface, timeout time.Duration) ([]UPnPDevice, error) {
+	if iface == nil {
+		return nil, errors.New("upnp: no interface given")
+	}
+
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4zero, Port: 0})
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	pc := ipv4.NewPacketConn(conn)
+	if err := pc.SetMulticastInterface(iface); err != nil {
+		return nil, err
+	}
+	if err := pc.SetMulticastTTL(2); err != nil {
+		return nil, err
+	}
+	if _, err := conn.WriteTo([]byte(ssdpSearch), ssdpGroup); err != nil {
+		return nil, err
+	}
+
+	deadline := time.Now().Add(timeout)
+	if err := conn.SetReadDeadline(deadline); err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithDeadline(context.Background(), deadline)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	var devices []*UPnPDevice
+	seen := make(map[string]bool)
+	buf := make([]byte, 4096)
+	for {
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			var netErr net.Error
+			if errors.As(err, &netErr) && netErr.Timeout() {
+				break
+			}
+			return nil, err
+		}
+
+		device, ok := parseSSDPResponse(buf[:n])
+		if !ok || seen[device.USN] {
+			continue
+		}
+		seen[device.USN] = true
+		devices = append(devices, device)
+
+		if device.Location == "" {
+			continue
+		}
+		wg.Add(1)
+		go func(d *UPnPDevice) {
+			defer wg.Done()
+			desc, err := fetchUPnPDescription(ctx, d.Location)
+			if err != nil {
+				return
+			}
+			d.FriendlyName = desc.Device.FriendlyName
+			d.Manufacturer = desc.Device.Manufacturer
+			d.ModelName = desc.Device.ModelName
+		}(device)
+	}
+	wg.Wait()
+
+	result := make([]UPnPDevice, len(devices))
+	for i, d := range devices {
+		result[i] = *d
+	}
+	return result, nil
+}
+
+// parseSSDPResponse parses an HTTP 200 answer to an M-SEARCH or a NOTIFY
+// announcement into a device.
+func parseSSDPResponse(data []byte) (*UPnPDevice, bool) {
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	if !scanner.Scan() {
+		return nil, false
+	}
+	status := scanner.Text()
+	if !strings.HasPrefix(status, "HTTP/1.1 200") && !strings.HasPrefix(status, "NOTIFY ") {
+		return nil, false
+	}
+
+	device := &UPnPDevice{}
+	for scanner.Scan() {
+		key, value, found := strings.Cut(scanner.Text(), ":")
+		if !found {
+			continue
+		}
+		value = strings.TrimSpace(value)
+		switch strings.ToUpper(strings.TrimSpace(key)) {
+		case "LOCATION":
+			device.Location = value
+		case "USN":
+			device.USN = value
+		case "SERVER":
+			device.Server = value
+		case "ST", "NT":
+			device.ST = value
+		}
+	}
+	if device.USN == "" {
+		device.USN = device.Location
+	}
+	return device, device.USN != ""
+}
+
+// upnpDescription is the part of the UPnP device description we care about.
+type upnpDescription struct {
+	Device struct {
+		FriendlyName string `xml:"friendlyName"`
+		Manufacturer string `xml:"manufacturer"`
+		ModelName    string `xml:"modelName"`
+	} `xml:"device"`
+}
+
+func fetchUPnPDescription(ctx context.Context, location string) (*upnpDescription, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, location, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("upnp: fetching %s: %s", location, resp.Status)
+	}
+	desc := &upnpDescription{}
+	if err := xml.NewDecoder(resp.Body).Decode(desc); err != nil {
+		return nil, err
+	}
+	return desc, nil
+}
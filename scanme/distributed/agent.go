@@ -0,0 +1,176 @@
+package distributed
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/CyberRoute/scanme/scanme/pool"
+)
+
+const (
+	// heartbeatInterval is how often a running agent sends heartbeats, well
+	// within HeartbeatTimeout.
+	heartbeatInterval = 10 * time.Second
+	// pollInterval is how long an agent waits before asking for work again
+	// when every remaining shard is held by another agent.
+	pollInterval = 5 * time.Second
+	// requestTimeout bounds a single request to the coordinator.
+	requestTimeout = 30 * time.Second
+)
+
+// Agent fetches shards from a coordinator, scans their hosts with a
+// scanner pool and posts the results back.
+type Agent struct {
+	// ID identifies the agent to the coordinator, it must be unique.
+	ID string
+	// Logger receives the events of the agent, slog.Default when nil.
+	Logger *slog.Logger
+
+	coordinator string
+	pool        *pool.ScannerPool
+	client      *http.Client
+}
+
+// NewAgent creates an agent named id, working for the coordinator served at
+// coordinatorURL, e.g. "http://10.0.0.1:8080", and scanning with p.
+func NewAgent(id, coordinatorURL string, p *pool.ScannerPool) *Agent {
+	return &Agent{
+		ID:          id,
+		coordinator: strings.TrimSuffix(coordinatorURL, "/"),
+		pool:        p,
+		client:      &http.Client{Timeout: requestTimeout},
+	}
+}
+
+// logger returns the logger of a.
+func (a *Agent) logger() *slog.Logger {
+	if a.Logger == nil {
+		return slog.Default()
+	}
+	return a.Logger
+}
+
+// Run scans shards until the coordinator has none left, sending heartbeats
+// all along, or until ctx is done. Hosts that fail to scan are logged and
+// left out of the results.
+func (a *Agent) Run(ctx context.Context) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		ticker := time.NewTicker(heartbeatInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := a.Heartbeat(ctx); err != nil {
+					a.logger().Error("distributed: heartbeat failed", "agent", a.ID, "err", err)
+				}
+			}
+		}
+	}()
+
+	for {
+		shard, finished, err := a.nextShard(ctx)
+		if err != nil {
+			return err
+		}
+		if finished {
+			return nil
+		}
+		if shard == nil {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(pollInterval):
+			}
+			continue
+		}
+
+		a.logger().Info("distributed: scanning shard", "agent", a.ID, "shard", shard.ID, "first", shard.First, "last", shard.Last)
+		results, err := a.pool.ScanAll(ctx, shard.Hosts())
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err != nil {
+			a.logger().Error("distributed: error scanning shard", "agent", a.ID, "shard", shard.ID, "err", err)
+		}
+		found := results[:0]
+		for _, r := range results {
+			if r != nil {
+				found = append(found, r)
+			}
+		}
+		if err := a.post(ctx, "/results", agentRequest{Agent: a.ID, Shard: shard.ID, Results: found}, nil); err != nil {
+			return err
+		}
+	}
+}
+
+// Heartbeat tells the coordinator that the agent is alive, so that it keeps
+// its shards. Run sends heartbeats on its own.
+func (a *Agent) Heartbeat(ctx context.Context) error {
+	return a.post(ctx, "/heartbeat", agentRequest{Agent: a.ID}, nil)
+}
+
+// nextShard asks the coordinator for a shard. It returns a nil shard when
+// none is available yet, and finished when all shards are done.
+func (a *Agent) nextShard(ctx context.Context) (shard *Shard, finished bool, err error) {
+	var s Shard
+	err = a.post(ctx, "/shard", agentRequest{Agent: a.ID}, &s)
+	switch {
+	case err == errNoContent:
+		return nil, false, nil
+	case err == errGone:
+		return nil, true, nil
+	case err != nil:
+		return nil, false, err
+	}
+	return &s, false, nil
+}
+
+var (
+	errNoContent = errors.New("no content")
+	errGone      = errors.New("gone")
+)
+
+// post sends req to the coordinator endpoint path and decodes the answer
+// into resp, if not nil. A 204 or 410 status is returned as errNoContent or
+// errGone when resp is not nil.
+func (a *Agent) post(ctx context.Context, path string, req agentRequest, resp interface{}) error {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, a.coordinator+path, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpResp, err := a.client.Do(httpReq)
+	if err != nil {
+		return fmt.Errorf("error contacting coordinator: %v", err)
+	}
+	defer httpResp.Body.Close()
+
+	switch {
+	case httpResp.StatusCode == http.StatusGone && resp != nil:
+		return errGone
+	case httpResp.StatusCode == http.StatusNoContent && resp != nil:
+		return errNoContent
+	case httpResp.StatusCode/100 != 2:
+		return fmt.Errorf("coordinator answered %s to %s", httpResp.Status, path)
+	}
+	if resp == nil {
+		return nil
+	}
+	return json.NewDecoder(httpResp.Body).Decode(resp)
+}
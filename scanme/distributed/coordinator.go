@@ -0,0 +1,351 @@
+// Package distributed splits scans of large networks across several agents.
+// A Coordinator divides the host addresses of a network into shards, which
+// Agents, each running on its own machine, fetch, scan and report back over
+// HTTP with JSON bodies:
+//
+//	POST /shard      {"agent": id}                             the next shard to scan
+//	POST /results    {"agent": id, "shard": n, "results": [...]} the results of a shard
+//	POST /heartbeat  {"agent": id}                             the agent is alive
+//
+// Shards held by an agent silent for more than HeartbeatTimeout are handed
+// to the next agent asking for work.
+package distributed
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/CyberRoute/scanme/scanme"
+	scannet "github.com/CyberRoute/scanme/scanme/net"
+	"github.com/google/gopacket/layers"
+)
+
+// HeartbeatTimeout is how long an agent may stay silent before the shards
+// it holds are reassigned.
+const HeartbeatTimeout = 30 * time.Second
+
+// Shard is a range of consecutive host addresses, from First to Last
+// included, scanned by a single agent.
+type Shard struct {
+	ID    int    `json:"id"`
+	First net.IP `json:"first"`
+	Last  net.IP `json:"last"`
+}
+
+// Hosts returns the addresses of the shard.
+func (s Shard) Hosts() []net.IP {
+	var hosts []net.IP
+	last := s.Last.To16()
+	for ip := s.First.To16(); ip != nil; ip = nextIP(ip) {
+		hosts = append(hosts, ip)
+		if ip.Equal(last) {
+			break
+		}
+	}
+	return hosts
+}
+
+// nextIP returns ip + 1, or nil when ip is the last address.
+func nextIP(ip net.IP) net.IP {
+	next := append(net.IP(nil), ip...)
+	for i := len(next) - 1; i >= 0; i-- {
+		next[i]++
+		if next[i] != 0 {
+			return next
+		}
+	}
+	return nil
+}
+
+// shardState tracks the progress of a shard.
+type shardState struct {
+	shard Shard
+	agent string
+	done  bool
+}
+
+// Coordinator hands out the shards of a network to agents and collects
+// their results. It implements http.Handler, see the package documentation
+// for the protocol.
+type Coordinator struct {
+	// Logger receives the events of the coordinator, slog.Default when
+	// nil.
+	Logger *slog.Logger
+
+	mu       sync.Mutex
+	shards   []*shardState
+	lastSeen map[string]time.Time
+	results  []*scanme.ScanResult
+	pending  int
+	done     chan struct{}
+	mux      *http.ServeMux
+}
+
+// NewCoordinator splits the host addresses of cidr into shards ranges of
+// about the same size. There are fewer shards when cidr has fewer than
+// shards addresses.
+func NewCoordinator(cidr string, shards int) (*Coordinator, error) {
+	hosts, err := scannet.NewIPRange(cidr)
+	if err != nil {
+		return nil, err
+	}
+	if shards < 1 {
+		shards = 1
+	}
+	if shards > hosts.Len() {
+		shards = hosts.Len()
+	}
+	size := (hosts.Len() + shards - 1) / shards
+
+	c := &Coordinator{
+		lastSeen: make(map[string]time.Time),
+		done:     make(chan struct{}),
+		mux:      http.NewServeMux(),
+	}
+	var current *shardState
+	for i := 0; ; i++ {
+		ip, ok := hosts.Next()
+		if !ok {
+			break
+		}
+		if i%size == 0 {
+			current = &shardState{shard: Shard{ID: len(c.shards), First: ip}}
+			c.shards = append(c.shards, current)
+		}
+		current.shard.Last = ip
+	}
+	c.pending = len(c.shards)
+	if c.pending == 0 {
+		close(c.done)
+	}
+
+	c.mux.HandleFunc("/shard", c.handleShard)
+	c.mux.HandleFunc("/results", c.handleResults)
+	c.mux.HandleFunc("/heartbeat", c.handleHeartbeat)
+	return c, nil
+}
+
+// logger returns the logger of c.
+func (c *Coordinator) logger() *slog.Logger {
+	if c.Logger == nil {
+		return slog.Default()
+	}
+	return c.Logger
+}
+
+// ServeHTTP implements http.Handler.
+func (c *Coordinator) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	c.mux.ServeHTTP(w, r)
+}
+
+// Wait blocks until every shard has been scanned, or ctx is done.
+func (c *Coordinator) Wait(ctx context.Context) error {
+	select {
+	case <-c.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Results returns the results reported so far, one per host found up,
+// sorted by address. Results reported twice for the same host, e.g. by an
+// agent whose shard had been reassigned, are merged with Aggregate.
+func (c *Coordinator) Results() []*scanme.ScanResult {
+	c.mu.Lock()
+	byTarget := make(map[string][]*scanme.ScanResult)
+	for _, r := range c.results {
+		key := r.Target.String()
+		byTarget[key] = append(byTarget[key], r)
+	}
+	c.mu.Unlock()
+
+	results := make([]*scanme.ScanResult, 0, len(byTarget))
+	for _, rs := range byTarget {
+		results = append(results, c.Aggregate(rs))
+	}
+	sort.Slice(results, func(i, j int) bool {
+		return bytes.Compare(results[i].Target.To16(), results[j].Target.To16()) < 0
+	})
+	return results
+}
+
+// Aggregate merges results of the same target into one: its ports are the
+// union of their ports, a port open in any of them being open, it spans
+// from the earliest start to the latest end, its packet counters are summed
+// and its latency stats recomputed. Results of other targets than the first
+// are ignored.
+func (c *Coordinator) Aggregate(results []*scanme.ScanResult) *scanme.ScanResult {
+	var merged *scanme.ScanResult
+	ports := make(map[layers.TCPPort]int)
+	for _, r := range results {
+		if r == nil {
+			continue
+		}
+		if merged == nil {
+			m := *r
+			m.Ports = nil
+			m.Stats = scanme.ScanStats{}
+			merged = &m
+		} else if !r.Target.Equal(merged.Target) {
+			c.logger().Warn("distributed: not merging result", "target", r.Target, "into", merged.Target)
+			continue
+		}
+		if r.StartTime.Before(merged.StartTime) {
+			merged.StartTime = r.StartTime
+		}
+		if r.EndTime.After(merged.EndTime) {
+			merged.EndTime = r.EndTime
+		}
+		merged.Stats.PacketsSent += r.Stats.PacketsSent
+		merged.Stats.PacketsReceived += r.Stats.PacketsReceived
+		merged.Stats.RateLimitEvents += r.Stats.RateLimitEvents
+		merged.Stats.DNSTimeouts += r.Stats.DNSTimeouts
+		merged.Stats.PacketsDroppedQueue += r.Stats.PacketsDroppedQueue
+		for _, p := range r.Ports {
+			i, ok := ports[p.Port]
+			if !ok {
+				ports[p.Port] = len(merged.Ports)
+				merged.Ports = append(merged.Ports, p)
+			} else if p.State == "open" && merged.Ports[i].State != "open" {
+				merged.Ports[i] = p
+			}
+		}
+	}
+	if merged == nil {
+		return nil
+	}
+	sort.Slice(merged.Ports, func(i, j int) bool { return merged.Ports[i].Port < merged.Ports[j].Port })
+	merged.Stats.Duration = merged.EndTime.Sub(merged.StartTime)
+	var total time.Duration
+	var measured int
+	for _, p := range merged.Ports {
+		if p.Latency == 0 {
+			continue
+		}
+		total += p.Latency
+		measured++
+		if merged.Stats.MinLatency == 0 || p.Latency < merged.Stats.MinLatency {
+			merged.Stats.MinLatency = p.Latency
+		}
+		if p.Latency > merged.Stats.MaxLatency {
+			merged.Stats.MaxLatency = p.Latency
+		}
+	}
+	if measured > 0 {
+		merged.Stats.MeanLatency = total / time.Duration(measured)
+	}
+	return merged
+}
+
+// agentRequest is the body of the requests agents send.
+type agentRequest struct {
+	Agent   string               `json:"agent"`
+	Shard   int                  `json:"shard"`
+	Results []*scanme.ScanResult `json:"results,omitempty"`
+}
+
+// decodeRequest reads the agentRequest of r, answering with an error and
+// returning false when it is invalid.
+func decodeRequest(w http.ResponseWriter, r *http.Request) (*agentRequest, bool) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return nil, false
+	}
+	var req agentRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, fmt.Sprintf("invalid request: %v", err), http.StatusBadRequest)
+		return nil, false
+	}
+	if req.Agent == "" {
+		http.Error(w, "missing agent", http.StatusBadRequest)
+		return nil, false
+	}
+	return &req, true
+}
+
+// handleShard assigns the next shard to the agent. It answers with
+// 204 No Content when every remaining shard is held by a live agent, and
+// 410 Gone once all shards are done.
+func (c *Coordinator) handleShard(w http.ResponseWriter, r *http.Request) {
+	req, ok := decodeRequest(w, r)
+	if !ok {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now()
+	c.lastSeen[req.Agent] = now
+
+	if c.pending == 0 {
+		w.WriteHeader(http.StatusGone)
+		return
+	}
+	for _, st := range c.shards {
+		if st.done {
+			continue
+		}
+		if st.agent != "" {
+			if now.Sub(c.lastSeen[st.agent]) <= HeartbeatTimeout {
+				continue
+			}
+			c.logger().Warn("distributed: agent silent, reassigning shard", "agent", st.agent, "timeout", HeartbeatTimeout, "shard", st.shard.ID)
+		}
+		st.agent = req.Agent
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(st.shard); err != nil {
+			c.logger().Error("distributed: error sending shard", "shard", st.shard.ID, "agent", req.Agent, "err", err)
+		}
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleResults records the results of a shard.
+func (c *Coordinator) handleResults(w http.ResponseWriter, r *http.Request) {
+	req, ok := decodeRequest(w, r)
+	if !ok {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.lastSeen[req.Agent] = time.Now()
+
+	if req.Shard < 0 || req.Shard >= len(c.shards) {
+		http.Error(w, fmt.Sprintf("unknown shard %d", req.Shard), http.StatusNotFound)
+		return
+	}
+	for _, result := range req.Results {
+		if result != nil {
+			c.results = append(c.results, result)
+		}
+	}
+	if st := c.shards[req.Shard]; !st.done {
+		st.done = true
+		c.pending--
+		if c.pending == 0 {
+			close(c.done)
+		}
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleHeartbeat records that the agent is alive.
+func (c *Coordinator) handleHeartbeat(w http.ResponseWriter, r *http.Request) {
+	req, ok := decodeRequest(w, r)
+	if !ok {
+		return
+	}
+	c.mu.Lock()
+	c.lastSeen[req.Agent] = time.Now()
+	c.mu.Unlock()
+	w.WriteHeader(http.StatusNoContent)
+}
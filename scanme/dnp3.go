@@ -0,0 +1,101 @@
+package scanme
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// dnp3Port is the port of DNP3 over TCP.
+const dnp3Port = "20000"
+
+// dnp3MasterAddress is the link layer address the probes are sent from.
+const dnp3MasterAddress = 0xfffc
+
+// dnp3MaxAddress is the highest outstation address probed by DNP3Probe.
+// Outstations only answer requests sent to their own address and most use
+// low addresses.
+const dnp3MaxAddress = 100
+
+// ErrNotDNP3 is returned by DNP3Probe when the service on port 20000 does
+// not speak DNP3.
+var ErrNotDNP3 = errors.New("not a DNP3 service")
+
+// DNP3Info describes the DNP3 outstation of the target.
+type DNP3Info struct {
+	// Address is the link layer address of the outstation.
+	Address uint16
+	// LinkFunction is the function code of its reply, 11 for link status.
+	LinkFunction byte
+}
+
+// ServiceHint returns the classification of the outstation for
+// PortResult.ServiceHint.
+func (d *DNP3Info) ServiceHint() string {
+	return fmt.Sprintf("dnp3 (outstation %d)", d.Address)
+}
+
+// DNP3Probe sends DNP3 Request Link Status frames to port 20000 of the
+// target, one for every outstation address up to 100, and reads the address
+// of the outstation from the first valid link layer reply. As with
+// ModbusProbe, only probe devices you are authorized to audit.
+func (s *scanner) DNP3Probe(ctx context.Context) (*DNP3Info, error) {
+	conn, err := dialICS(ctx, s.dst, dnp3Port)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	var req []byte
+	for addr := uint16(0); addr <= dnp3MaxAddress; addr++ {
+		req = append(req, dnp3LinkStatusRequest(addr)...)
+	}
+	if _, err := conn.Write(req); err != nil {
+		return nil, err
+	}
+
+	// The reply starts with a link layer header: start bytes, length,
+	// control, destination and source, then the CRC of these 8 bytes.
+	var header [10]byte
+	if _, err := io.ReadFull(conn, header[:]); err != nil {
+		return nil, ErrNotDNP3
+	}
+	if header[0] != 0x05 || header[1] != 0x64 || binary.LittleEndian.Uint16(header[8:]) != dnp3CRC(header[:8]) {
+		return nil, ErrNotDNP3
+	}
+	return &DNP3Info{
+		Address:      binary.LittleEndian.Uint16(header[6:]),
+		LinkFunction: header[3] & 0x0f,
+	}, nil
+}
+
+// dnp3LinkStatusRequest returns a Request Link Status frame for the
+// outstation at addr.
+func dnp3LinkStatusRequest(addr uint16) []byte {
+	frame := []byte{
+		0x05, 0x64, // start bytes
+		0x05, // length of control, destination and source
+		0xc9, // DIR and PRM set, function 9: request link status
+	}
+	frame = binary.LittleEndian.AppendUint16(frame, addr)
+	frame = binary.LittleEndian.AppendUint16(frame, dnp3MasterAddress)
+	return binary.LittleEndian.AppendUint16(frame, dnp3CRC(frame))
+}
+
+// dnp3CRC computes the DNP3 CRC-16 of b.
+func dnp3CRC(b []byte) uint16 {
+	var crc uint16
+	for _, c := range b {
+		crc ^= uint16(c)
+		for i := 0; i < 8; i++ {
+			if crc&1 != 0 {
+				crc = crc>>1 ^ 0xa6bc
+			} else {
+				crc >>= 1
+			}
+		}
+	}
+	return ^crc
+}
@@ -0,0 +1,108 @@
+package scanme
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/google/gopacket/layers"
+)
+
+// ElasticsearchProbe reads the version of the Elasticsearch server on port
+// of the target from its root endpoint, then lists its indices with
+// /_cat/indices. HTTPS is tried when HTTP fails, as Elasticsearch 8 enables
+// it by default. Servers answering without credentials allow anyone to read
+// and write every index.
+func (s *scanner) ElasticsearchProbe(ctx context.Context, port layers.TCPPort) (*DatabaseInfo, error) {
+	ctx, cancel := withDatabaseTimeout(ctx)
+	defer cancel()
+	client := &http.Client{
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				return dialICS(ctx, s.dst, strconv.Itoa(int(port)))
+			},
+			TLSClientConfig: &tls.Config{
+				InsecureSkipVerify: true, // the certificate is of no interest here
+			},
+		},
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	defer client.CloseIdleConnections()
+
+	base := "http://" + net.JoinHostPort(s.dst.String(), strconv.Itoa(int(port)))
+	resp, err := esGet(ctx, client, base+"/")
+	if err != nil || resp.StatusCode == http.StatusBadRequest {
+		// HTTPS servers close plain connections, or refuse them with a
+		// bad request.
+		if err == nil {
+			resp.Body.Close()
+		}
+		base = "https" + strings.TrimPrefix(base, "http")
+		if resp, err = esGet(ctx, client, base+"/"); err != nil {
+			return nil, err
+		}
+	}
+	defer resp.Body.Close()
+
+	info := &DatabaseInfo{Type: DatabaseElasticsearch}
+	switch resp.StatusCode {
+	case http.StatusUnauthorized, http.StatusForbidden:
+		// Elasticsearch names its realm in the challenge.
+		if !strings.Contains(resp.Header.Get("WWW-Authenticate"), "security") &&
+			resp.Header.Get("X-Elastic-Product") != "Elasticsearch" {
+			return nil, ErrNotDatabase
+		}
+		info.AuthRequired = true
+		return info, nil
+	case http.StatusOK:
+	default:
+		return nil, fmt.Errorf("%w: HTTP %s", ErrNotDatabase, resp.Status)
+	}
+	var root struct {
+		Tagline string `json:"tagline"`
+		Version struct {
+			Number string `json:"number"`
+		} `json:"version"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&root); err != nil || root.Version.Number == "" {
+		return nil, ErrNotDatabase
+	}
+	info.Version = root.Version.Number
+
+	indices, err := esGet(ctx, client, base+"/_cat/indices?h=index")
+	if err != nil {
+		return info, nil
+	}
+	defer indices.Body.Close()
+	if indices.StatusCode != http.StatusOK {
+		info.AuthRequired = true
+		return info, nil
+	}
+	info.AnonymousAccessAllowed = true
+	lines := bufio.NewScanner(io.LimitReader(indices.Body, 1<<20))
+	for lines.Scan() {
+		if index := strings.TrimSpace(lines.Text()); index != "" {
+			info.DatabaseList = append(info.DatabaseList, index)
+		}
+	}
+	s.flagAnonymous(info, port)
+	return info, nil
+}
+
+// esGet sends a GET request for url with client.
+func esGet(ctx context.Context, client *http.Client, url string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	return client.Do(req)
+}
@@ -0,0 +1,160 @@
+package scanme
+
+import (
+	"fmt"
+	"net"
+)
+
+// The error types below describe the conditions callers commonly need to
+// tell apart, with errors.As:
+//
+//	var timeout *scanme.ErrARPTimeout
+//	if errors.As(err, &timeout) {
+//		log.Printf("%v is down", timeout.IP)
+//	}
+//
+// or, when the details are not needed, with errors.Is and a zero value:
+// errors.Is(err, &scanme.ErrARPTimeout{}). The underlying error, if any, is
+// available through errors.Unwrap.
+
+// ErrARPTimeout is returned when the next hop does not answer ARP requests,
+// usually because the target, or the gateway, is down.
+type ErrARPTimeout struct {
+	IP       net.IP
+	Attempts int
+}
+
+func (e *ErrARPTimeout) Error() string {
+	return fmt.Sprintf("no ARP reply from %v after %d attempts", e.IP, e.Attempts)
+}
+
+// Is reports whether target is an *ErrARPTimeout.
+func (e *ErrARPTimeout) Is(target error) bool {
+	_, ok := target.(*ErrARPTimeout)
+	return ok
+}
+
+// ErrScanTimeout is returned when the deadline of the context a scan runs
+// with expires before the scan completes. It unwraps to
+// context.DeadlineExceeded.
+type ErrScanTimeout struct {
+	Target net.IP
+	Err    error
+}
+
+func (e *ErrScanTimeout) Error() string {
+	return fmt.Sprintf("scan of %v timed out: %v", e.Target, e.Err)
+}
+
+func (e *ErrScanTimeout) Unwrap() error { return e.Err }
+
+// Is reports whether target is an *ErrScanTimeout.
+func (e *ErrScanTimeout) Is(target error) bool {
+	_, ok := target.(*ErrScanTimeout)
+	return ok
+}
+
+// ErrNoRoute is returned by NewScanner when no route to the target exists.
+type ErrNoRoute struct {
+	IP  net.IP
+	Err error
+}
+
+func (e *ErrNoRoute) Error() string {
+	return fmt.Sprintf("no route to %v: %v", e.IP, e.Err)
+}
+
+func (e *ErrNoRoute) Unwrap() error { return e.Err }
+
+// Is reports whether target is an *ErrNoRoute.
+func (e *ErrNoRoute) Is(target error) bool {
+	_, ok := target.(*ErrNoRoute)
+	return ok
+}
+
+// ErrPcapOpenFailed is returned when a capture handle cannot be opened on
+// Interface, typically for lack of privileges.
+type ErrPcapOpenFailed struct {
+	Interface string
+	Err       error
+}
+
+func (e *ErrPcapOpenFailed) Error() string {
+	return fmt.Sprintf("error opening pcap handle on %s: %v", e.Interface, e.Err)
+}
+
+func (e *ErrPcapOpenFailed) Unwrap() error { return e.Err }
+
+// Is reports whether target is an *ErrPcapOpenFailed.
+func (e *ErrPcapOpenFailed) Is(target error) bool {
+	_, ok := target.(*ErrPcapOpenFailed)
+	return ok
+}
+
+// ErrInvalidPortRange is returned by ParsePorts when Spec is not a valid
+// port or port range.
+type ErrInvalidPortRange struct {
+	Spec string
+}
+
+func (e *ErrInvalidPortRange) Error() string {
+	return fmt.Sprintf("invalid port range %q", e.Spec)
+}
+
+// Is reports whether target is an *ErrInvalidPortRange.
+func (e *ErrInvalidPortRange) Is(target error) bool {
+	_, ok := target.(*ErrInvalidPortRange)
+	return ok
+}
+
+// ErrInterfaceNotFound is returned when the network interface Name does not
+// exist.
+type ErrInterfaceNotFound struct {
+	Name string
+	Err  error
+}
+
+func (e *ErrInterfaceNotFound) Error() string {
+	return fmt.Sprintf("interface %s not found: %v", e.Name, e.Err)
+}
+
+func (e *ErrInterfaceNotFound) Unwrap() error { return e.Err }
+
+// Is reports whether target is an *ErrInterfaceNotFound.
+func (e *ErrInterfaceNotFound) Is(target error) bool {
+	_, ok := target.(*ErrInterfaceNotFound)
+	return ok
+}
+
+// ErrHostExcluded is returned by NewScanner when IP is excluded from scans
+// with WithExcludeHosts, WithExcludeCIDRs or WithExcludeFile.
+type ErrHostExcluded struct {
+	IP net.IP
+}
+
+func (e *ErrHostExcluded) Error() string {
+	return fmt.Sprintf("host %v is excluded from scans", e.IP)
+}
+
+// Is reports whether target is an *ErrHostExcluded.
+func (e *ErrHostExcluded) Is(target error) bool {
+	_, ok := target.(*ErrHostExcluded)
+	return ok
+}
+
+// ErrProxyNotSupported is returned by the scans sending raw packets, such as
+// Synscan, when the scanner was created with WithSOCKS5Proxy: only the TCP
+// connections of ConnScan can be relayed by the proxy at Proxy.
+type ErrProxyNotSupported struct {
+	Proxy string
+}
+
+func (e *ErrProxyNotSupported) Error() string {
+	return fmt.Sprintf("raw packet scans cannot go through the SOCKS5 proxy %s", e.Proxy)
+}
+
+// Is reports whether target is an *ErrProxyNotSupported.
+func (e *ErrProxyNotSupported) Is(target error) bool {
+	_, ok := target.(*ErrProxyNotSupported)
+	return ok
+}
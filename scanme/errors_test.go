@@ -0,0 +1,152 @@
+package scanme_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/CyberRoute/scanme/scanme"
+)
+
+// noRouter is a routing.Router without any route.
+type noRouter struct{}
+
+var errNoRoute = errors.New("network unreachable")
+
+func (noRouter) Route(net.IP) (*net.Interface, net.IP, net.IP, error) {
+	return nil, nil, nil, errNoRoute
+}
+
+func (noRouter) RouteWithSrc(net.HardwareAddr, net.IP, net.IP) (*net.Interface, net.IP, net.IP, error) {
+	return nil, nil, nil, errNoRoute
+}
+
+// allErrors holds a zero value of each error type, which errors.Is matches
+// against any error of that type.
+var allErrors = []error{
+	&scanme.ErrARPTimeout{},
+	&scanme.ErrScanTimeout{},
+	&scanme.ErrNoRoute{},
+	&scanme.ErrPcapOpenFailed{},
+	&scanme.ErrInvalidPortRange{},
+	&scanme.ErrInterfaceNotFound{},
+	&scanme.ErrHostExcluded{},
+	&scanme.ErrProxyNotSupported{},
+}
+
+// checkIs checks that err matches target, and none of the other error types,
+// with errors.Is.
+func checkIs(t *testing.T, err, target error) {
+	t.Helper()
+	for _, e := range allErrors {
+		want := fmt.Sprintf("%T", e) == fmt.Sprintf("%T", target)
+		if got := errors.Is(err, e); got != want {
+			t.Errorf("errors.Is(%v, %T) = %v, want %v", err, e, got, want)
+		}
+	}
+}
+
+func TestErrorsIsDistinguishesConditions(t *testing.T) {
+	ip := net.IPv4(192, 0, 2, 1)
+	cause := errors.New("cause")
+	tests := []struct {
+		err    error
+		target error
+	}{
+		{&scanme.ErrARPTimeout{IP: ip, Attempts: 3}, &scanme.ErrARPTimeout{}},
+		{&scanme.ErrScanTimeout{Target: ip, Err: context.DeadlineExceeded}, &scanme.ErrScanTimeout{}},
+		{&scanme.ErrNoRoute{IP: ip, Err: cause}, &scanme.ErrNoRoute{}},
+		{&scanme.ErrPcapOpenFailed{Interface: "eth0", Err: cause}, &scanme.ErrPcapOpenFailed{}},
+		{&scanme.ErrInvalidPortRange{Spec: "0-10"}, &scanme.ErrInvalidPortRange{}},
+		{&scanme.ErrInterfaceNotFound{Name: "eth9", Err: cause}, &scanme.ErrInterfaceNotFound{}},
+		{&scanme.ErrHostExcluded{IP: ip}, &scanme.ErrHostExcluded{}},
+		{&scanme.ErrProxyNotSupported{Proxy: "127.0.0.1:1080"}, &scanme.ErrProxyNotSupported{}},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%T", tt.target), func(t *testing.T) {
+			checkIs(t, tt.err, tt.target)
+			// Conditions stay recognizable once wrapped by callers.
+			checkIs(t, fmt.Errorf("scanning: %w", tt.err), tt.target)
+		})
+	}
+}
+
+func TestErrorsUnwrapToCause(t *testing.T) {
+	cause := errors.New("cause")
+	for _, err := range []error{
+		&scanme.ErrNoRoute{Err: cause},
+		&scanme.ErrPcapOpenFailed{Err: cause},
+		&scanme.ErrInterfaceNotFound{Err: cause},
+	} {
+		if !errors.Is(err, cause) {
+			t.Errorf("errors.Is(%T, cause) = false, want true", err)
+		}
+	}
+	err := &scanme.ErrScanTimeout{Err: context.DeadlineExceeded}
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Error("ErrScanTimeout does not unwrap to context.DeadlineExceeded")
+	}
+}
+
+func TestErrorsAsARPTimeout(t *testing.T) {
+	ip := net.IPv4(192, 0, 2, 1)
+	err := fmt.Errorf("scanning: %w", &scanme.ErrARPTimeout{IP: ip, Attempts: 3})
+	var timeout *scanme.ErrARPTimeout
+	if !errors.As(err, &timeout) {
+		t.Fatal("errors.As() = false, want true")
+	}
+	if !timeout.IP.Equal(ip) || timeout.Attempts != 3 {
+		t.Errorf("errors.As() = %+v, want IP %v after 3 attempts", timeout, ip)
+	}
+}
+
+func TestParsePortsInvalidRange(t *testing.T) {
+	_, err := scanme.ParsePorts("22,70000")
+	var invalid *scanme.ErrInvalidPortRange
+	if !errors.As(err, &invalid) {
+		t.Fatalf("ParsePorts() error = %v, want ErrInvalidPortRange", err)
+	}
+	if invalid.Spec != "70000" {
+		t.Errorf("Spec = %q, want %q", invalid.Spec, "70000")
+	}
+}
+
+func TestLoadPortListFromFileWrapsInvalidPort(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ports.txt")
+	if err := os.WriteFile(path, []byte("# web\n80\nhttp\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	_, err := scanme.LoadPortListFromFile(path)
+	var invalid *scanme.ErrInvalidPortRange
+	if !errors.As(err, &invalid) {
+		t.Fatalf("LoadPortListFromFile() error = %v, want ErrInvalidPortRange", err)
+	}
+	if invalid.Spec != "http" {
+		t.Errorf("Spec = %q, want %q", invalid.Spec, "http")
+	}
+}
+
+func TestNewScannerNoRoute(t *testing.T) {
+	ip := net.IPv4(192, 0, 2, 1)
+	s, err := scanme.NewScanner(ip, noRouter{})
+	if s != nil {
+		t.Errorf("NewScanner() = %v, want a nil Scanner with the error", s)
+	}
+	var noRoute *scanme.ErrNoRoute
+	if !errors.As(err, &noRoute) {
+		t.Fatalf("NewScanner() error = %v, want ErrNoRoute", err)
+	}
+	if !noRoute.IP.Equal(ip) || !errors.Is(err, errNoRoute) {
+		t.Errorf("NewScanner() error = %+v, want no route to %v caused by the router", noRoute, ip)
+	}
+}
+
+func TestNewScannerHostExcluded(t *testing.T) {
+	ip := net.IPv4(192, 0, 2, 1)
+	_, err := scanme.NewScanner(ip, noRouter{}, scanme.WithExcludeHosts([]net.IP{ip}))
+	checkIs(t, err, &scanme.ErrHostExcluded{})
+}
@@ -0,0 +1,48 @@
+package scanme
+
+import (
+	"net"
+	"time"
+
+	"github.com/google/gopacket/layers"
+)
+
+// ScanEventType tells what a ScanEvent reports.
+type ScanEventType int
+
+const (
+	// EventScanStarted is sent once the target answered ARP and probes are
+	// about to be sent. Total is the number of ports to probe.
+	EventScanStarted ScanEventType = iota
+	// EventProbeSent is sent after every probe. Sent counts the ports
+	// probed so far, out of Total.
+	EventProbeSent
+	// EventPortOpen is sent the first time Port answers with a SYN-ACK.
+	EventPortOpen
+	// EventScanDone is sent when the scan returns, completed or not.
+	EventScanDone
+)
+
+// ScanEvent reports the progress of a SYN scan, see WithEvents.
+type ScanEvent struct {
+	Type   ScanEventType
+	Target net.IP
+	Time   time.Time
+	Port   layers.TCPPort
+	Sent   int
+	Total  int
+}
+
+// emit sends an event on the channel set with WithEvents, if any. The event
+// is dropped rather than slowing the scan down when the channel is full.
+func (s *scanner) emit(e ScanEvent) {
+	if s.events == nil {
+		return
+	}
+	e.Target = s.dst
+	e.Time = time.Now()
+	select {
+	case s.events <- e:
+	default:
+	}
+}
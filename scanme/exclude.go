@@ -0,0 +1,95 @@
+package scanme
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+)
+
+// excludeList holds the hosts and networks never to scan, set with
+// WithExcludeHosts, WithExcludeCIDRs and WithExcludeFile.
+type excludeList struct {
+	hosts map[string]bool
+	nets  []*net.IPNet
+}
+
+// contains reports whether ip is one of the excluded hosts or in one of the
+// excluded networks.
+func (l *excludeList) contains(ip net.IP) bool {
+	if l.hosts[ip.String()] {
+		return true
+	}
+	for _, n := range l.nets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// excludeList returns the exclusions set on s, reading the exclude files.
+func (s *scanner) excludeList() (*excludeList, error) {
+	l := &excludeList{
+		hosts: make(map[string]bool, len(s.excludeHosts)),
+		nets:  append([]*net.IPNet(nil), s.excludeCIDRs...),
+	}
+	for _, ip := range s.excludeHosts {
+		l.hosts[ip.String()] = true
+	}
+	for _, path := range s.excludeFiles {
+		if err := l.load(path); err != nil {
+			return nil, err
+		}
+	}
+	return l, nil
+}
+
+// load adds the exclusions of the file at path, holding one address or CIDR
+// block per line. Blank lines and lines starting with # are ignored.
+func (l *excludeList) load(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		if strings.Contains(text, "/") {
+			_, n, err := net.ParseCIDR(text)
+			if err != nil {
+				return fmt.Errorf("%s:%d: invalid CIDR block %q", path, line, text)
+			}
+			l.nets = append(l.nets, n)
+			continue
+		}
+		ip := net.ParseIP(text)
+		if ip == nil {
+			return fmt.Errorf("%s:%d: invalid address %q", path, line, text)
+		}
+		l.hosts[ip.String()] = true
+	}
+	return scanner.Err()
+}
+
+// NewExcludeFilter returns a function reporting whether an address is
+// excluded by the WithExcludeHosts, WithExcludeCIDRs and WithExcludeFile
+// options among opts, for callers scanning many hosts to skip them before
+// creating their scanners. The exclude files are read once, here.
+func NewExcludeFilter(opts ...Option) (func(ip net.IP) bool, error) {
+	var cfg scanner
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	l, err := cfg.excludeList()
+	if err != nil {
+		return nil, err
+	}
+	return l.contains, nil
+}
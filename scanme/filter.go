@@ -0,0 +1,86 @@
+package scanme
+
+import (
+	"strings"
+
+	"github.com/google/gopacket/layers"
+)
+
+// ScanFilter selects ports of a ScanResult. A port matches when its State is
+// one of States, its number is within [MinPort, MaxPort] and its Service is
+// one of Services, compared case-insensitively. Empty fields match every
+// port, and a zero MaxPort means no upper bound.
+type ScanFilter struct {
+	States           []string
+	MinPort, MaxPort layers.TCPPort
+	Services         []string
+
+	// all holds the filters combined with And, which must all match too.
+	all []ScanFilter
+}
+
+// FilterOpenPorts matches the open ports.
+func FilterOpenPorts() ScanFilter {
+	return ScanFilter{States: []string{"open"}}
+}
+
+// FilterPortRange matches the ports in [min, max].
+func FilterPortRange(min, max layers.TCPPort) ScanFilter {
+	return ScanFilter{MinPort: min, MaxPort: max}
+}
+
+// FilterByService matches the ports running one of the named services, such
+// as "http" or "ssh".
+func FilterByService(names ...string) ScanFilter {
+	return ScanFilter{Services: names}
+}
+
+// And combines filters into a filter matching the ports all of them match.
+func And(filters ...ScanFilter) ScanFilter {
+	return ScanFilter{all: filters}
+}
+
+// Apply returns a copy of result holding only the ports f matches. Target,
+// times, stats and the other metadata are kept as they are.
+func (f ScanFilter) Apply(result *ScanResult) *ScanResult {
+	if result == nil {
+		return nil
+	}
+	filtered := *result
+	filtered.Ports = make([]PortResult, 0, len(result.Ports))
+	for _, p := range result.Ports {
+		if f.match(p) {
+			filtered.Ports = append(filtered.Ports, p)
+		}
+	}
+	return &filtered
+}
+
+// match reports whether f matches the port p.
+func (f ScanFilter) match(p PortResult) bool {
+	if len(f.States) > 0 && !containsFold(f.States, p.State) {
+		return false
+	}
+	if p.Port < f.MinPort || (f.MaxPort != 0 && p.Port > f.MaxPort) {
+		return false
+	}
+	if len(f.Services) > 0 && !containsFold(f.Services, p.Service) {
+		return false
+	}
+	for _, sub := range f.all {
+		if !sub.match(p) {
+			return false
+		}
+	}
+	return true
+}
+
+// containsFold reports whether list holds s, ignoring case.
+func containsFold(list []string, s string) bool {
+	for _, v := range list {
+		if strings.EqualFold(v, s) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,67 @@
+// Package filter holds filters applied to the packets captured during a
+// scan before they are handled.
+package filter
+
+import (
+	"container/list"
+	"hash/crc32"
+	"sync"
+)
+
+// DefaultDedupeCacheSize is the number of packets a DedupeFilter remembers
+// when created with a size lower than one.
+const DefaultDedupeCacheSize = 4096
+
+// dedupeBytes is the length of the prefix of the packets hashed: the
+// Ethernet and IPv4 headers, with the IP ID and checksum, and the ports and
+// the first half of the sequence number of TCP. Mirrored copies of a packet
+// share it, replies from different ports do not.
+const dedupeBytes = 40
+
+// DedupeFilter detects the packets captured more than once, such as those
+// copied by a switch mirroring ports or retransmitted by the target, so
+// that they are handled once. It remembers the CRC32 of the first 40 bytes
+// of the most recently seen packets, evicting the least recently seen
+// beyond its size. It is safe for concurrent use.
+type DedupeFilter struct {
+	mu    sync.Mutex
+	size  int
+	order *list.List // of uint32, most recently seen first
+	seen  map[uint32]*list.Element
+}
+
+// NewDedupeFilter returns a DedupeFilter remembering size packets, or
+// DefaultDedupeCacheSize when size is lower than one.
+func NewDedupeFilter(size int) *DedupeFilter {
+	if size < 1 {
+		size = DefaultDedupeCacheSize
+	}
+	return &DedupeFilter{
+		size:  size,
+		order: list.New(),
+		seen:  make(map[uint32]*list.Element, size),
+	}
+}
+
+// Duplicate reports whether a packet starting like data was seen recently,
+// and records data as the most recently seen packet.
+func (f *DedupeFilter) Duplicate(data []byte) bool {
+	if len(data) > dedupeBytes {
+		data = data[:dedupeBytes]
+	}
+	sum := crc32.ChecksumIEEE(data)
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if e, ok := f.seen[sum]; ok {
+		f.order.MoveToFront(e)
+		return true
+	}
+	f.seen[sum] = f.order.PushFront(sum)
+	if f.order.Len() > f.size {
+		oldest := f.order.Back()
+		f.order.Remove(oldest)
+		delete(f.seen, oldest.Value.(uint32))
+	}
+	return false
+}
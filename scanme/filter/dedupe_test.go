@@ -0,0 +1,119 @@
+package filter
+
+import (
+	"fmt"
+	"io"
+	"sync"
+	"testing"
+
+	"github.com/google/gopacket/pcap"
+)
+
+// readCapture returns the packets of the pcap file at path.
+func readCapture(t *testing.T, path string) [][]byte {
+	t.Helper()
+	handle, err := pcap.OpenOffline(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer handle.Close()
+	var packets [][]byte
+	for {
+		data, _, err := handle.ReadPacketData()
+		if err == io.EOF {
+			return packets
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		packets = append(packets, data)
+	}
+}
+
+// testdata/mirrored.pcap holds the 17 packets of a SYN scan and its replies
+// as seen on a mirrored switch port: each is captured twice, 10µs apart.
+func TestDedupeFilterMirroredCapture(t *testing.T) {
+	packets := readCapture(t, "testdata/mirrored.pcap")
+	if len(packets) != 34 {
+		t.Fatalf("%d packets in the capture, want 34", len(packets))
+	}
+	for _, size := range []int{0, 1, 64} {
+		f := NewDedupeFilter(size)
+		for i, data := range packets {
+			if got, want := f.Duplicate(data), i%2 == 1; got != want {
+				t.Errorf("size %d: Duplicate(packet %d) = %v, want %v", size, i, got, want)
+			}
+		}
+	}
+}
+
+func TestDedupeFilterEvictsLeastRecentlySeen(t *testing.T) {
+	f := NewDedupeFilter(2)
+	a, b, c := []byte("a"), []byte("b"), []byte("c")
+	f.Duplicate(a)
+	f.Duplicate(b)
+	// Seeing a again makes b the least recently seen.
+	if !f.Duplicate(a) {
+		t.Fatal("Duplicate(a) = false, want true")
+	}
+	f.Duplicate(c)
+	if f.Duplicate(b) {
+		t.Error("Duplicate(b) = true once evicted")
+	}
+	// b evicted a, c is still remembered.
+	if !f.Duplicate(c) {
+		t.Error("Duplicate(c) = false, want true")
+	}
+	if f.Duplicate(a) {
+		t.Error("Duplicate(a) = true once evicted")
+	}
+}
+
+func TestDedupeFilterHashesPrefix(t *testing.T) {
+	f := NewDedupeFilter(0)
+	packet := make([]byte, 60)
+	for i := range packet {
+		packet[i] = byte(i)
+	}
+	f.Duplicate(packet)
+
+	sameStart := append([]byte(nil), packet...)
+	sameStart[dedupeBytes] = 0xff
+	if !f.Duplicate(sameStart) {
+		t.Error("packet differing after the first 40 bytes is not a duplicate")
+	}
+	otherStart := append([]byte(nil), packet...)
+	otherStart[dedupeBytes-1] = 0xff
+	if f.Duplicate(otherStart) {
+		t.Error("packet differing in the first 40 bytes is a duplicate")
+	}
+	if f.Duplicate(packet[:10]) || !f.Duplicate(packet[:10]) {
+		t.Error("short packets are not deduplicated")
+	}
+}
+
+func TestDedupeFilterConcurrent(t *testing.T) {
+	f := NewDedupeFilter(0)
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	duplicates := 0
+	for g := 0; g < 4; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			n := 0
+			for i := 0; i < 1000; i++ {
+				if f.Duplicate([]byte(fmt.Sprint(i))) {
+					n++
+				}
+			}
+			mu.Lock()
+			duplicates += n
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+	// Each of the 1000 packets is seen once first, then 3 times again.
+	if duplicates != 3000 {
+		t.Errorf("%d duplicates, want 3000", duplicates)
+	}
+}
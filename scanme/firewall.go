@@ -0,0 +1,246 @@
+package scanme
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"github.com/google/gopacket/pcap"
+)
+
+// firewallSettle is how long FirewallFingerprint keeps listening for replies
+// after the last probe has been sent.
+const firewallSettle = 3 * time.Second
+
+// Response classes tallied by FirewallFingerprint. ICMP unreachables are
+// tallied as "icmp-3-<code>", e.g. "icmp-3-13" for administratively prohibited.
+const (
+	firewallRST     = "rst"
+	firewallSilence = "silence"
+)
+
+//go:embed fingerprints/firewalls.json
+var firewallDB []byte
+
+// ErrNoFilteredPorts is returned by FirewallFingerprint when every sampled
+// port answered with a SYN/ACK, leaving nothing to fingerprint.
+var ErrNoFilteredPorts = errors.New("no filtered ports among the sampled ports")
+
+// FirewallFP is the outcome of a firewall fingerprint. Confidence is in
+// [0, 1] and is the overlap between the observed and the expected response
+// pattern. Responses holds the raw tally the match was made on.
+type FirewallFP struct {
+	Vendor     string
+	Model      string
+	Confidence float64
+	Responses  map[string]int
+}
+
+// firewallProfile is an entry of the embedded fingerprint database. Responses
+// maps each response class to the expected fraction of blocked ports.
+type firewallProfile struct {
+	Vendor    string             `json:"vendor"`
+	Model     string             `json:"model"`
+	Responses map[string]float64 `json:"responses"`
+}
+
+// DefaultFirewallSamplePorts returns ports that are commonly blocked at the
+// perimeter and therefore likely to show the firewall's deny behaviour.
+func DefaultFirewallSamplePorts() []layers.TCPPort {
+	return []layers.TCPPort{23, 135, 137, 139, 445, 1433, 1434, 2323, 3389, 4444, 5900, 6000, 6667, 12345, 31337}
+}
+
+// FirewallFingerprint guesses the firewall in front of the target from how it
+// denies connections. It sends a SYN to every port in samplePorts, tallies
+// how each one is refused (TCP RST, ICMP destination unreachable with its
+// code, or silence) and matches the pattern against an embedded database of
+// vendor profiles. Ports that answer with a SYN/ACK are open and left out of
+// the tally. When samplePorts is empty, DefaultFirewallSamplePorts is used.
+//
+// ICMP unreachables are accepted from any source, as they usually come from
+// the firewall rather than the target, as long as they quote one of our
+// probes. A purely silent pattern is common to many products, so its match
+// should be read as "drop policy" rather than a specific vendor.
+func (s *scanner) FirewallFingerprint(ctx context.Context, samplePorts []layers.TCPPort) (*FirewallFP, error) {
+	if len(samplePorts) == 0 {
+		samplePorts = DefaultFirewallSamplePorts()
+	}
+
+	var profiles []firewallProfile
+	if err := json.Unmarshal(firewallDB, &profiles); err != nil {
+		return nil, fmt.Errorf("parsing firewall fingerprint database: %w", err)
+	}
+
+	mac, err := s.sendARPRequest(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	handle, err := openLive(s.iface.Name)
+	if err != nil {
+		return nil, err
+	}
+	defer handle.Close()
+
+	bpfFilter := fmt.Sprintf("(tcp and src host %s) or (icmp and icmp[0] == 3)", s.dst)
+	if err := handle.SetBPFFilter(s.bpfFilter(bpfFilter)); err != nil {
+		return nil, err
+	}
+
+	srcPort, err := getFreeTCPPort()
+	if err != nil {
+		return nil, err
+	}
+
+	eth := layers.Ethernet{
+		SrcMAC:       s.iface.HardwareAddr,
+		DstMAC:       mac,
+		EthernetType: layers.EthernetTypeIPv4,
+	}
+	ip4 := layers.IPv4{
+		SrcIP:    s.src,
+		DstIP:    s.dst,
+		Version:  4,
+		TTL:      s.ttl,
+		Protocol: layers.IPProtocolTCP,
+	}
+
+	responses := make(map[layers.TCPPort]string, len(samplePorts))
+	for _, port := range samplePorts {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		default:
+		}
+
+		responses[port] = firewallSilence
+		tcp := layers.TCP{
+			SrcPort: srcPort,
+			DstPort: port,
+			Seq:     s.nextSeq(),
+			Window:  1024,
+			SYN:     true,
+		}
+		if err := tcp.SetNetworkLayerForChecksum(&ip4); err != nil {
+			return nil, err
+		}
+		if err := s.send(&eth, &ip4, &tcp); err != nil {
+			s.logger.Error("error sending firewall probe", "port", port, "err", err)
+		}
+	}
+
+	var rEth layers.Ethernet
+	var rDot1Q layers.Dot1Q
+	var rIP4 layers.IPv4
+	var rTCP layers.TCP
+	var rICMP layers.ICMPv4
+	var payload gopacket.Payload
+	parser := gopacket.NewDecodingLayerParser(layers.LayerTypeEthernet, &rEth, &rDot1Q, &rIP4, &rTCP, &rICMP, &payload)
+	parser.IgnoreUnsupported = true
+	decoded := []gopacket.LayerType{}
+
+	deadline := time.Now().Add(firewallSettle)
+	for time.Now().Before(deadline) {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		default:
+		}
+
+		data, _, err := handle.ReadPacketData()
+		if err == pcap.NextErrorTimeoutExpired {
+			continue
+		} else if err != nil {
+			s.logger.Error("error reading packet", "err", err)
+			continue
+		}
+		s.metrics.PacketReceived(s.dst.String())
+
+		//nolint:staticcheck // SA9003 ignore this!
+		if err := parser.DecodeLayers(data, &decoded); err != nil {
+			// Errors here are due to the decoder, and not all layers are implemented.
+		}
+		for _, typ := range decoded {
+			switch typ {
+			case layers.LayerTypeTCP:
+				if rTCP.DstPort != srcPort || !rIP4.SrcIP.Equal(s.dst) {
+					continue
+				}
+				if _, probed := responses[rTCP.SrcPort]; !probed {
+					continue
+				}
+				if rTCP.SYN && rTCP.ACK {
+					delete(responses, rTCP.SrcPort)
+				} else if rTCP.RST {
+					responses[rTCP.SrcPort] = firewallRST
+				}
+			case layers.LayerTypeICMPv4:
+				if port, ok := quotedProbePort(rICMP.Payload, s.src, s.dst, srcPort); ok {
+					if _, probed := responses[port]; probed {
+						responses[port] = fmt.Sprintf("icmp-3-%d", rICMP.TypeCode.Code())
+					}
+				}
+			}
+		}
+	}
+
+	if len(responses) == 0 {
+		return nil, ErrNoFilteredPorts
+	}
+
+	fp := &FirewallFP{Responses: make(map[string]int)}
+	for _, class := range responses {
+		fp.Responses[class]++
+	}
+	for _, p := range profiles {
+		if c := p.match(fp.Responses, len(responses)); c > fp.Confidence {
+			fp.Vendor, fp.Model, fp.Confidence = p.Vendor, p.Model, c
+		}
+	}
+	return fp, nil
+}
+
+// quotedProbePort parses the datagram quoted in an ICMP error and, if it is
+// a TCP probe sent from src:srcPort to dst, returns the destination port it
+// was sent to.
+func quotedProbePort(quoted []byte, src, dst net.IP, srcPort layers.TCPPort) (layers.TCPPort, bool) {
+	var orig layers.IPv4
+	if err := orig.DecodeFromBytes(quoted, gopacket.NilDecodeFeedback); err != nil {
+		return 0, false
+	}
+	if !orig.SrcIP.Equal(src) || !orig.DstIP.Equal(dst) || orig.Protocol != layers.IPProtocolTCP {
+		return 0, false
+	}
+	// RFC 792 only guarantees the first 8 bytes of the original payload,
+	// which is enough for the TCP ports.
+	if len(orig.Payload) < 4 {
+		return 0, false
+	}
+	if layers.TCPPort(uint16(orig.Payload[0])<<8|uint16(orig.Payload[1])) != srcPort {
+		return 0, false
+	}
+	return layers.TCPPort(uint16(orig.Payload[2])<<8 | uint16(orig.Payload[3])), true
+}
+
+// match returns the overlap between the observed tally and the profile,
+// i.e. the sum over all response classes of the smaller of the observed and
+// the expected fraction.
+func (p firewallProfile) match(tally map[string]int, total int) float64 {
+	var overlap float64
+	for class, n := range tally {
+		observed := float64(n) / float64(total)
+		expected := p.Responses[class]
+		if observed < expected {
+			overlap += observed
+		} else {
+			overlap += expected
+		}
+	}
+	return overlap
+}
@@ -0,0 +1,186 @@
+// Package flow reassembles the TCP streams of captured packets, so that
+// data spanning several segments, such as banners or TLS handshakes, can be
+// analyzed as a whole.
+package flow
+
+import (
+	"bytes"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"github.com/google/gopacket/reassembly"
+)
+
+// TCPStream is a TCP connection followed by a FlowTracker. Net and
+// Transport are the flows of the first packet seen, normally the client's
+// SYN, from the client to the server.
+type TCPStream struct {
+	Net       gopacket.Flow
+	Transport gopacket.Flow
+	Start     time.Time
+
+	mu       sync.Mutex
+	lastSeen time.Time
+	closed   bool
+	client   bytes.Buffer
+	server   bytes.Buffer
+}
+
+// ClientData returns the reassembled data sent by the client so far.
+func (t *TCPStream) ClientData() []byte {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]byte(nil), t.client.Bytes()...)
+}
+
+// ServerData returns the reassembled data sent by the server so far.
+func (t *TCPStream) ServerData() []byte {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]byte(nil), t.server.Bytes()...)
+}
+
+// Banner returns the data sent by the server so far as text, trimmed of
+// surrounding whitespace.
+func (t *TCPStream) Banner() string {
+	return strings.TrimSpace(string(t.ServerData()))
+}
+
+// LastSeen returns the capture time of the last data reassembled.
+func (t *TCPStream) LastSeen() time.Time {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.lastSeen
+}
+
+// Closed reports whether the connection was closed, or timed out.
+func (t *TCPStream) Closed() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.closed
+}
+
+// FlowTracker reassembles the TCP streams of the packets given to Track,
+// keyed by (srcIP:srcPort, dstIP:dstPort) in either direction. Streams idle
+// for longer than the timeout, in capture time, are flushed and closed.
+// It is safe for concurrent use.
+type FlowTracker struct {
+	timeout time.Duration
+
+	mu        sync.Mutex
+	assembler *reassembly.Assembler
+	streams   []*TCPStream
+	lastFlush time.Time
+}
+
+// NewFlowTracker returns a FlowTracker closing streams idle for timeout.
+func NewFlowTracker(timeout time.Duration) *FlowTracker {
+	f := &FlowTracker{timeout: timeout}
+	f.assembler = reassembly.NewAssembler(reassembly.NewStreamPool(factory{f}))
+	return f
+}
+
+// Track feeds packet to the reassembly. Packets without an IP and a TCP
+// layer are ignored.
+func (f *FlowTracker) Track(packet gopacket.Packet) {
+	network := packet.NetworkLayer()
+	tcp, ok := packet.Layer(layers.LayerTypeTCP).(*layers.TCP)
+	if network == nil || !ok {
+		return
+	}
+	ci := packet.Metadata().CaptureInfo
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.assembler.AssembleWithContext(network.NetworkFlow(), tcp, captureContext(ci))
+	if f.lastFlush.IsZero() {
+		f.lastFlush = ci.Timestamp
+	}
+	if ci.Timestamp.Sub(f.lastFlush) >= f.timeout {
+		f.assembler.FlushCloseOlderThan(ci.Timestamp.Add(-f.timeout))
+		f.lastFlush = ci.Timestamp
+	}
+}
+
+// Flush hands over the data still waiting for missing segments and closes
+// every stream, typically once the capture is over.
+func (f *FlowTracker) Flush() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.assembler.FlushAll()
+}
+
+// Streams returns the streams seen so far, in the order they started.
+func (f *FlowTracker) Streams() []*TCPStream {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]*TCPStream(nil), f.streams...)
+}
+
+// factory implements reassembly.StreamFactory, recording every new stream
+// in the FlowTracker. It is called with the FlowTracker locked.
+type factory struct {
+	f *FlowTracker
+}
+
+func (fa factory) New(netFlow, tcpFlow gopacket.Flow, tcp *layers.TCP, ac reassembly.AssemblerContext) reassembly.Stream {
+	t := &TCPStream{
+		Net:       netFlow,
+		Transport: tcpFlow,
+		Start:     ac.GetCaptureInfo().Timestamp,
+		lastSeen:  ac.GetCaptureInfo().Timestamp,
+	}
+	fa.f.streams = append(fa.f.streams, t)
+	return stream{t}
+}
+
+// stream implements reassembly.Stream for a TCPStream.
+type stream struct {
+	t *TCPStream
+}
+
+// Accept takes every segment, also for connections whose handshake was not
+// captured.
+func (s stream) Accept(tcp *layers.TCP, ci gopacket.CaptureInfo, dir reassembly.TCPFlowDirection, nextSeq reassembly.Sequence, start *bool, ac reassembly.AssemblerContext) bool {
+	*start = true
+	return true
+}
+
+// ReassembledSG appends the reassembled data to the side it came from.
+func (s stream) ReassembledSG(sg reassembly.ScatterGather, ac reassembly.AssemblerContext) {
+	dir, _, _, _ := sg.Info()
+	length, _ := sg.Lengths()
+	if length == 0 {
+		return
+	}
+	data := sg.Fetch(length)
+
+	s.t.mu.Lock()
+	defer s.t.mu.Unlock()
+	s.t.lastSeen = sg.CaptureInfo(0).Timestamp
+	if dir == reassembly.TCPDirClientToServer {
+		s.t.client.Write(data)
+	} else {
+		s.t.server.Write(data)
+	}
+}
+
+// ReassemblyComplete marks the stream closed. The assembler keeps the
+// connection until it is flushed, so that the last ACK of the close is not
+// taken for a new stream.
+func (s stream) ReassemblyComplete(ac reassembly.AssemblerContext) bool {
+	s.t.mu.Lock()
+	defer s.t.mu.Unlock()
+	s.t.closed = true
+	return false
+}
+
+// captureContext implements reassembly.AssemblerContext.
+type captureContext gopacket.CaptureInfo
+
+func (c captureContext) GetCaptureInfo() gopacket.CaptureInfo {
+	return gopacket.CaptureInfo(c)
+}
@@ -0,0 +1,178 @@
+package flow
+
+import (
+	"crypto/x509"
+	"encoding/binary"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/pcap"
+)
+
+// TLS record and handshake message types, see RFC 5246.
+const (
+	recordChangeCipherSpec = 20
+	recordHandshake        = 22
+
+	handshakeClientHello       = 1
+	handshakeServerHello       = 2
+	handshakeCertificate       = 11
+	handshakeServerKeyExchange = 12
+	handshakeServerHelloDone   = 14
+	handshakeClientKeyExchange = 16
+)
+
+// handshake walks the TLS records of data up to ChangeCipherSpec, after
+// which they are encrypted, and returns the types of the handshake messages
+// and the first certificate sent. ok is false unless the records tile data
+// exactly.
+func handshake(data []byte) (types []byte, cert []byte, ok bool) {
+	for len(data) > 0 {
+		if len(data) < 5 {
+			return nil, nil, false
+		}
+		typ, length := data[0], int(binary.BigEndian.Uint16(data[3:5]))
+		if len(data) < 5+length {
+			return nil, nil, false
+		}
+		body := data[5 : 5+length]
+		data = data[5+length:]
+		switch typ {
+		case recordChangeCipherSpec:
+			// Everything after is encrypted, and must still be records.
+			for len(data) > 0 {
+				if len(data) < 5 || len(data) < 5+int(binary.BigEndian.Uint16(data[3:5])) {
+					return nil, nil, false
+				}
+				data = data[5+int(binary.BigEndian.Uint16(data[3:5])):]
+			}
+			return types, cert, true
+		case recordHandshake:
+			for len(body) >= 4 {
+				msgType := body[0]
+				msgLen := int(body[1])<<16 | int(body[2])<<8 | int(body[3])
+				if len(body) < 4+msgLen {
+					return nil, nil, false
+				}
+				msg := body[4 : 4+msgLen]
+				body = body[4+msgLen:]
+				types = append(types, msgType)
+				if msgType == handshakeCertificate && cert == nil && len(msg) >= 6 {
+					certLen := int(msg[3])<<16 | int(msg[4])<<8 | int(msg[5])
+					if len(msg) >= 6+certLen {
+						cert = msg[6 : 6+certLen]
+					}
+				}
+			}
+		}
+	}
+	return types, cert, true
+}
+
+// trackCapture feeds the packets of the pcap file at path to a FlowTracker.
+func trackCapture(t *testing.T, path string) *FlowTracker {
+	t.Helper()
+	handle, err := pcap.OpenOffline(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer handle.Close()
+	f := NewFlowTracker(time.Minute)
+	for packet := range gopacket.NewPacketSource(handle, handle.LinkType()).Packets() {
+		f.Track(packet)
+	}
+	f.Flush()
+	return f
+}
+
+// testdata/tls.pcap holds a TLS 1.2 handshake between 192.0.2.1:49152 and
+// 192.0.2.10:443, for the certificate of www.example.test, with segments of
+// at most 200 bytes. The server's first flight is sent in 4 segments, the
+// third arriving before the second and the first being retransmitted. It
+// is followed by a connection to 192.0.2.10:25 whose multi-line SMTP
+// greeting spans 2 segments.
+func TestFlowTrackerTLSHandshake(t *testing.T) {
+	streams := trackCapture(t, "testdata/tls.pcap").Streams()
+	if len(streams) != 2 {
+		t.Fatalf("%d streams, want 2", len(streams))
+	}
+	tls := streams[0]
+	if got := tls.Net.String(); got != "192.0.2.1->192.0.2.10" {
+		t.Errorf("Net = %s, want 192.0.2.1->192.0.2.10", got)
+	}
+	if got := tls.Transport.String(); got != "49152->443" {
+		t.Errorf("Transport = %s, want 49152->443", got)
+	}
+	if !tls.Closed() {
+		t.Error("TLS stream not closed after the FINs")
+	}
+
+	client, server := tls.ClientData(), tls.ServerData()
+	if len(client) != 329 || len(server) != 592 {
+		t.Errorf("reassembled %d client and %d server bytes, want 329 and 592", len(client), len(server))
+	}
+	types, _, ok := handshake(client)
+	if !ok {
+		t.Fatal("client data is not a sequence of TLS records")
+	}
+	if want := []byte{handshakeClientHello, handshakeClientKeyExchange}; !slices.Equal(types, want) {
+		t.Errorf("client handshake messages = %v, want %v", types, want)
+	}
+	types, der, ok := handshake(server)
+	if !ok {
+		t.Fatal("server data is not a sequence of TLS records")
+	}
+	if want := []byte{handshakeServerHello, handshakeCertificate, handshakeServerKeyExchange, handshakeServerHelloDone}; !slices.Equal(types, want) {
+		t.Errorf("server handshake messages = %v, want %v", types, want)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("certificate spanning several segments: %v", err)
+	}
+	if cert.Subject.CommonName != "www.example.test" {
+		t.Errorf("certificate CN = %q, want www.example.test", cert.Subject.CommonName)
+	}
+}
+
+func TestFlowTrackerBanner(t *testing.T) {
+	streams := trackCapture(t, "testdata/tls.pcap").Streams()
+	if len(streams) != 2 {
+		t.Fatalf("%d streams, want 2", len(streams))
+	}
+	smtp := streams[1]
+	if want := "220-mail.example.test ESMTP\r\n220-No UCE\r\n220 Ready"; smtp.Banner() != want {
+		t.Errorf("Banner() = %q, want %q", smtp.Banner(), want)
+	}
+	if len(smtp.ClientData()) != 0 {
+		t.Errorf("ClientData() = %q, want none", smtp.ClientData())
+	}
+}
+
+// TestFlowTrackerTimeout checks that a stream idle for longer than the
+// timeout is closed while tracking.
+func TestFlowTrackerTimeout(t *testing.T) {
+	handle, err := pcap.OpenOffline("testdata/tls.pcap")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer handle.Close()
+	f := NewFlowTracker(time.Millisecond)
+	var packets []gopacket.Packet
+	for packet := range gopacket.NewPacketSource(handle, handle.LinkType()).Packets() {
+		packets = append(packets, packet)
+	}
+	// Only the start of the TLS connection, then a packet much later.
+	for _, packet := range packets[:3] {
+		f.Track(packet)
+	}
+	last := packets[len(packets)-1]
+	last.Metadata().Timestamp = last.Metadata().Timestamp.Add(time.Hour)
+	f.Track(last)
+
+	streams := f.Streams()
+	if len(streams) == 0 || !streams[0].Closed() {
+		t.Error("idle stream not closed after the timeout")
+	}
+}
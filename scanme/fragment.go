@@ -0,0 +1,46 @@
+package scanme
+
+import (
+	"math/rand"
+
+	"github.com/CyberRoute/scanme/scanme/queue"
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+)
+
+// sendProbe queues a TCP probe with priority p, splitting it into IP
+// fragments when fragmentation has been enabled with WithFragmentation.
+func (s *scanner) sendProbe(p queue.Priority, eth *layers.Ethernet, ip4 *layers.IPv4, tcp *layers.TCP) error {
+	if s.fragmentSize == 0 {
+		return s.sendPriority(p, eth, ip4, tcp)
+	}
+	return s.sendFragmented(p, eth, ip4, tcp)
+}
+
+// sendFragmented serializes tcp on its own, checksum included, and sends it
+// as a sequence of IPv4 fragments of at most s.fragmentSize bytes of payload
+// sharing the same IP ID, queued with priority p.
+func (s *scanner) sendFragmented(p queue.Priority, eth *layers.Ethernet, ip4 *layers.IPv4, tcp *layers.TCP) error {
+	segment := gopacket.NewSerializeBuffer()
+	if err := gopacket.SerializeLayers(segment, s.opts, tcp); err != nil {
+		return err
+	}
+	payload := segment.Bytes()
+
+	frag := *ip4
+	frag.Id = uint16(1 + rand.Intn(0xfffe))
+	for offset := 0; offset < len(payload); offset += s.fragmentSize {
+		end := offset + s.fragmentSize
+		frag.Flags = ip4.Flags | layers.IPv4MoreFragments
+		if end >= len(payload) {
+			end = len(payload)
+			frag.Flags = ip4.Flags &^ layers.IPv4MoreFragments
+		}
+		frag.FragOffset = uint16(offset / 8)
+
+		if err := s.sendPriority(p, eth, &frag, gopacket.Payload(payload[offset:end])); err != nil {
+			return err
+		}
+	}
+	return nil
+}
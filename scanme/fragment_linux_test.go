@@ -0,0 +1,89 @@
+//go:build linux
+
+package scanme
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/CyberRoute/scanme/scanme/queue"
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"golang.org/x/net/ipv4"
+)
+
+// TestFragmentedSYNLoopback sends a SYN split in 8-byte fragments to a
+// listening port of 127.0.0.1, and checks that the kernel reassembles it
+// and answers with a SYN-ACK. Linux drops the Ethernet frames written to
+// the loopback interface with AF_PACKET before reassembly, so the IPv4
+// fragments are written to it with a raw socket instead, which needs root.
+func TestFragmentedSYNLoopback(t *testing.T) {
+	ln, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	port := layers.TCPPort(ln.Addr().(*net.TCPAddr).Port)
+
+	c, err := net.ListenPacket("ip4:tcp", "127.0.0.1")
+	if err != nil {
+		t.Skipf("opening a raw socket: %v (it needs root)", err)
+	}
+	defer c.Close()
+	raw, err := ipv4.NewRawConn(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := newTestScanner(WithFragmentation(8))
+	s.opts = gopacket.SerializeOptions{FixLengths: true, ComputeChecksums: true}
+	written := 0
+	s.queue = queue.New(func(frame []byte) error {
+		// Strip the Ethernet header and the padding of short frames.
+		h, err := ipv4.ParseHeader(frame[14:])
+		if err != nil {
+			return err
+		}
+		written++
+		return raw.WriteTo(h, frame[14+h.Len:14+h.TotalLen], nil)
+	}, 64, nil)
+
+	// Nothing listens on the source port: the kernel answers the SYN-ACK
+	// with a RST, leaving no connection behind.
+	loopback := net.IPv4(127, 0, 0, 1).To4()
+	srcPort := layers.TCPPort(49152 + s.rng.Intn(16384))
+	eth, ip4, tcp := fragmentedSYN(t, loopback, loopback, srcPort, port)
+	if err := s.sendFragmented(queue.PriorityNormal, eth, ip4, tcp); err != nil {
+		t.Fatal(err)
+	}
+	s.queue.Close()
+	if written != 3 {
+		t.Fatalf("%d fragments written, want 3", written)
+	}
+
+	// The raw socket receives the TCP segments delivered locally, once
+	// reassembled: the SYN, then the reply.
+	buf := make([]byte, 1500)
+	raw.SetReadDeadline(time.Now().Add(2 * time.Second))
+	for {
+		h, payload, _, err := raw.ReadFrom(buf)
+		if err != nil {
+			t.Fatalf("no SYN-ACK to the fragmented SYN: %v", err)
+		}
+		if !h.Src.Equal(loopback) {
+			continue
+		}
+		var reply layers.TCP
+		if err := reply.DecodeFromBytes(payload, gopacket.NilDecodeFeedback); err != nil {
+			continue
+		}
+		if reply.SrcPort != port || reply.DstPort != srcPort {
+			continue
+		}
+		if !reply.SYN || !reply.ACK || reply.Ack != tcp.Seq+1 {
+			t.Fatalf("reply %+v to the fragmented SYN, want a SYN-ACK of seq %d", reply, tcp.Seq)
+		}
+		return
+	}
+}
@@ -0,0 +1,121 @@
+package scanme
+
+import (
+	"bytes"
+	"net"
+	"sync"
+	"testing"
+
+	"github.com/CyberRoute/scanme/scanme/queue"
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/ip4defrag"
+	"github.com/google/gopacket/layers"
+)
+
+// fragmentedSYN returns the layers of a SYN probe from src to dst:port,
+// whose TCP header carries an MSS option, 24 bytes in all.
+func fragmentedSYN(t testing.TB, src, dst net.IP, srcPort, port layers.TCPPort) (*layers.Ethernet, *layers.IPv4, *layers.TCP) {
+	t.Helper()
+	eth := &layers.Ethernet{
+		SrcMAC:       net.HardwareAddr{0, 1, 2, 3, 4, 5},
+		DstMAC:       net.HardwareAddr{0, 1, 2, 3, 4, 6},
+		EthernetType: layers.EthernetTypeIPv4,
+	}
+	ip4 := &layers.IPv4{Version: 4, TTL: 64, Protocol: layers.IPProtocolTCP, SrcIP: src, DstIP: dst}
+	tcp := &layers.TCP{
+		SrcPort: srcPort,
+		DstPort: port,
+		Seq:     1000,
+		SYN:     true,
+		Window:  1024,
+		Options: []layers.TCPOption{{OptionType: layers.TCPOptionKindMSS, OptionLength: 4, OptionData: []byte{0x05, 0xb4}}},
+	}
+	if err := tcp.SetNetworkLayerForChecksum(ip4); err != nil {
+		t.Fatal(err)
+	}
+	return eth, ip4, tcp
+}
+
+// sentFragments sends the probe with sendFragmented and returns the frames
+// queued, in order.
+func sentFragments(t *testing.T, fragmentSize int) [][]byte {
+	t.Helper()
+	var mu sync.Mutex
+	var frames [][]byte
+	s := newTestScanner(WithFragmentation(fragmentSize))
+	s.opts = gopacket.SerializeOptions{FixLengths: true, ComputeChecksums: true}
+	s.queue = queue.New(func(data []byte) error {
+		mu.Lock()
+		frames = append(frames, append([]byte(nil), data...))
+		mu.Unlock()
+		return nil
+	}, 64, nil)
+	eth, ip4, tcp := fragmentedSYN(t, testLocal, testTarget, testLocalPort, 80)
+	if err := s.sendFragmented(queue.PriorityNormal, eth, ip4, tcp); err != nil {
+		t.Fatal(err)
+	}
+	s.queue.Close()
+	return frames
+}
+
+func TestSendFragmented(t *testing.T) {
+	_, _, tcp := fragmentedSYN(t, testLocal, testTarget, testLocalPort, 80)
+	segment := serialize(t, tcp)
+	if len(segment) != 24 {
+		t.Fatalf("TCP header of %d bytes, want 24", len(segment))
+	}
+
+	tests := []struct {
+		fragmentSize int
+		want         []int // payload length of each fragment
+	}{
+		{8, []int{8, 8, 8}},
+		{16, []int{16, 8}},
+		{13, []int{8, 8, 8}}, // rounded down to a multiple of 8
+		{1, []int{8, 8, 8}},  // raised to the minimum
+		{24, []int{24}},
+		{1500, []int{24}},
+	}
+	for _, tt := range tests {
+		frames := sentFragments(t, tt.fragmentSize)
+		if len(frames) != len(tt.want) {
+			t.Errorf("size %d: %d fragments, want %d", tt.fragmentSize, len(frames), len(tt.want))
+			continue
+		}
+		defrag := ip4defrag.NewIPv4Defragmenter()
+		var id uint16
+		var whole *layers.IPv4
+		for i, data := range frames {
+			packet := gopacket.NewPacket(data, layers.LayerTypeEthernet, gopacket.Default)
+			ip4, ok := packet.Layer(layers.LayerTypeIPv4).(*layers.IPv4)
+			if !ok {
+				t.Fatalf("size %d: fragment %d is not IPv4", tt.fragmentSize, i)
+			}
+			if i == 0 {
+				id = ip4.Id
+			} else if ip4.Id != id {
+				t.Errorf("size %d: fragment %d has IP ID %d, want %d", tt.fragmentSize, i, ip4.Id, id)
+			}
+			last := i == len(frames)-1
+			if more := ip4.Flags&layers.IPv4MoreFragments != 0; more == last {
+				t.Errorf("size %d: fragment %d has MF %v, want %v", tt.fragmentSize, i, more, !last)
+			}
+			if want := uint16(i * 8 * (tt.want[0] / 8)); ip4.FragOffset*8 != want {
+				t.Errorf("size %d: fragment %d at offset %d, want %d", tt.fragmentSize, i, ip4.FragOffset*8, want)
+			}
+			if len(ip4.Payload) != tt.want[i] {
+				t.Errorf("size %d: fragment %d carries %d bytes, want %d", tt.fragmentSize, i, len(ip4.Payload), tt.want[i])
+			}
+			// The defragmenter returns the datagram once complete.
+			var err error
+			if whole, err = defrag.DefragIPv4(ip4); err != nil {
+				t.Fatalf("size %d: reassembling fragment %d: %v", tt.fragmentSize, i, err)
+			} else if whole != nil && !last {
+				t.Errorf("size %d: reassembled before the last fragment", tt.fragmentSize)
+			}
+		}
+		if whole == nil || !bytes.Equal(whole.Payload, segment) {
+			t.Errorf("size %d: reassembled payload differs from the TCP header of the probe", tt.fragmentSize)
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package scanme
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/textproto"
+	"time"
+
+	"github.com/google/gopacket/layers"
+)
+
+// ftpTimeout bounds every FTP command of a bounce scan, including the LIST
+// during which the FTP server connects to the probed port.
+const ftpTimeout = 10 * time.Second
+
+// ErrFTPBounceRefused is returned by FTPBounceScan when the FTP server
+// rejects PORT commands pointing to a third-party host, as servers patched
+// against bounce attacks do.
+var ErrFTPBounceRefused = errors.New("FTP server refuses PORT to third-party hosts")
+
+// FTPBounceScan scans the target through an FTP server vulnerable to the FTP
+// bounce attack, like nmap -b. It logs in to ftpServer:ftpPort with creds
+// (user and password, e.g. "anonymous" and an e-mail address) and, for each
+// port to scan, sends a PORT command naming the target and port followed by a
+// LIST. The FTP server then connects to the port itself: a 125 or 150 reply
+// means it connected, so the port is open, while a 425 or other 4xx reply
+// means it could not. Only open ports are part of the returned ScanResult.
+//
+// This is a plain TCP client, no raw sockets are needed, and the target only
+// ever sees connections from the FTP server. Servers still allowing PORT to
+// third-party hosts (RFC 2577) are increasingly rare; when the first PORT is
+// refused ErrFTPBounceRefused is returned. When ctx is done, the ports found
+// so far are returned along with ctx.Err().
+func (s *scanner) FTPBounceScan(ctx context.Context, ftpServer net.IP, ftpPort layers.TCPPort, creds [2]string) (*ScanResult, error) {
+	target := s.dst.To4()
+	if target == nil {
+		return nil, fmt.Errorf("FTP bounce scan needs an IPv4 target, got %v", s.dst)
+	}
+	start := time.Now()
+
+	conn, err := net.DialTCP("tcp", nil, &net.TCPAddr{IP: ftpServer, Port: int(ftpPort)})
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	tp := textproto.NewConn(conn)
+
+	cmd := func(expect int, format string, args ...any) (int, string, error) {
+		deadline := time.Now().Add(ftpTimeout)
+		if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+			deadline = d
+		}
+		if err := conn.SetDeadline(deadline); err != nil {
+			return 0, "", err
+		}
+		if format != "" {
+			if _, err := tp.Cmd(format, args...); err != nil {
+				return 0, "", err
+			}
+		}
+		return tp.ReadResponse(expect)
+	}
+
+	if _, _, err := cmd(220, ""); err != nil {
+		return nil, fmt.Errorf("FTP greeting: %w", err)
+	}
+	code, _, err := cmd(0, "USER %s", creds[0])
+	if err != nil {
+		return nil, fmt.Errorf("FTP login: %w", err)
+	}
+	if code == 331 {
+		if _, _, err := cmd(230, "PASS %s", creds[1]); err != nil {
+			return nil, fmt.Errorf("FTP login: %w", err)
+		}
+	} else if code != 230 {
+		return nil, fmt.Errorf("FTP login: unexpected reply %d to USER", code)
+	}
+
+	openPorts := make(map[layers.TCPPort]string)
+	for i, port := range s.scanPorts() {
+		select {
+		case <-ctx.Done():
+			return newScanResult(s.dst, start, openPorts), ctx.Err()
+		default:
+		}
+
+		if _, _, err := cmd(200, "PORT %d,%d,%d,%d,%d,%d", target[0], target[1], target[2], target[3], port>>8, port&0xff); err != nil {
+			var tpErr *textproto.Error
+			if i == 0 && errors.As(err, &tpErr) && tpErr.Code >= 500 {
+				return nil, fmt.Errorf("%w: %v", ErrFTPBounceRefused, err)
+			}
+			return newScanResult(s.dst, start, openPorts), err
+		}
+
+		code, _, err := cmd(0, "LIST")
+		if err != nil {
+			return newScanResult(s.dst, start, openPorts), err
+		}
+		if code == 125 || code == 150 {
+			openPorts[port] = "open"
+			// The listing goes to the probed port, after which the server
+			// follows up with 226, or 426 when the port dropped it.
+			if _, _, err := cmd(0, ""); err != nil {
+				return newScanResult(s.dst, start, openPorts), err
+			}
+		}
+	}
+
+	_, _ = tp.Cmd("QUIT")
+	return newScanResult(s.dst, start, openPorts), nil
+}
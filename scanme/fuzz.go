@@ -0,0 +1,319 @@
+package scanme
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"github.com/google/gopacket/pcap"
+)
+
+// fuzzSettle is how long FuzzScan keeps listening for replies after the
+// last packet has been sent.
+const fuzzSettle = 3 * time.Second
+
+// Mutations applied by FuzzScan, in turn, to its SYN packets.
+const (
+	// MutationTruncatedHeader cuts the TCP header short, with an IP total
+	// length matching the truncated packet.
+	MutationTruncatedHeader = "truncated-header"
+	// MutationBadChecksum corrupts the TCP checksum.
+	MutationBadChecksum = "bad-checksum"
+	// MutationReservedBits sets some of the reserved bits of the TCP header.
+	MutationReservedBits = "reserved-bits"
+	// MutationOversizedLength claims an IP total length or a TCP data
+	// offset larger than the packet.
+	MutationOversizedLength = "oversized-length"
+	// MutationRandomOptions fills the TCP options with random bytes.
+	MutationRandomOptions = "random-options"
+)
+
+var fuzzMutations = []string{
+	MutationTruncatedHeader,
+	MutationBadChecksum,
+	MutationReservedBits,
+	MutationOversizedLength,
+	MutationRandomOptions,
+}
+
+// FuzzAnomaly is a malformed packet the target answered differently from a
+// well-formed SYN. Packet holds the IPv4 packet sent, and Response and
+// Expected the answer to it and to the well-formed SYN: "rst", "syn-ack",
+// "icmp-3-<code>", "silence" or "tcp-<flags>" for other TCP segments.
+type FuzzAnomaly struct {
+	Mutation string
+	Packet   []byte
+	Response string
+	Expected string
+}
+
+// FuzzResult is the outcome of FuzzScan.
+type FuzzResult struct {
+	Iterations int
+	Anomalies  []FuzzAnomaly
+}
+
+// FuzzScan tests how the network stack of the target copes with malformed
+// packets. It first sends a well-formed SYN to port to learn the expected
+// answer, usually a RST from a closed port, then iterations SYNs each broken
+// by one of the Mutation* mutations in turn: truncated headers, invalid
+// checksums, reserved bits set, oversized lengths and random TCP options.
+// Every packet is sent from its own source port, so that answers can be told
+// apart. Packets answered differently from the well-formed SYN are reported
+// as anomalies; note that silently dropping packets with broken checksums or
+// lengths is the correct behaviour, while a SYN-ACK to such a packet is not.
+//
+// FuzzScan is not part of the normal scan flow. Malformed packets may crash
+// or hang fragile devices: only run it against systems you own.
+func (s *scanner) FuzzScan(ctx context.Context, port layers.TCPPort, iterations int) (*FuzzResult, error) {
+	mac, err := s.sendARPRequest(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	handle, err := openLive(s.iface.Name)
+	if err != nil {
+		return nil, err
+	}
+	defer handle.Close()
+
+	bpfFilter := fmt.Sprintf("(tcp and src host %s) or (icmp and icmp[0] == 3)", s.dst)
+	if err := handle.SetBPFFilter(s.bpfFilter(bpfFilter)); err != nil {
+		return nil, err
+	}
+
+	basePort, err := getFreeTCPPort()
+	if err != nil {
+		return nil, err
+	}
+	// Packet i is sent from srcPort(i), the well-formed SYN being packet 0.
+	srcPort := func(i int) layers.TCPPort {
+		return layers.TCPPort(1024 + (int(basePort)-1024+i)%(65536-1024))
+	}
+
+	eth := layers.Ethernet{
+		SrcMAC:       s.iface.HardwareAddr,
+		DstMAC:       mac,
+		EthernetType: layers.EthernetTypeIPv4,
+	}
+
+	packets := make([][]byte, iterations+1)
+	for i := range packets {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		default:
+		}
+
+		packet, err := s.fuzzSYN(srcPort(i), port)
+		if err != nil {
+			return nil, err
+		}
+		if i > 0 {
+			packet = s.mutate(packet, fuzzMutations[(i-1)%len(fuzzMutations)])
+		}
+		packets[i] = packet
+		if err := s.send(&eth, gopacket.Payload(packet)); err != nil {
+			s.logger.Error("error sending fuzz packet", "packet", i, "err", err)
+		}
+	}
+
+	responses := make(map[layers.TCPPort]string, len(packets))
+	var rEth layers.Ethernet
+	var rDot1Q layers.Dot1Q
+	var rIP4 layers.IPv4
+	var rTCP layers.TCP
+	var rICMP layers.ICMPv4
+	var payload gopacket.Payload
+	parser := gopacket.NewDecodingLayerParser(layers.LayerTypeEthernet, &rEth, &rDot1Q, &rIP4, &rTCP, &rICMP, &payload)
+	parser.IgnoreUnsupported = true
+	decoded := []gopacket.LayerType{}
+
+	deadline := time.Now().Add(fuzzSettle)
+	for time.Now().Before(deadline) {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		default:
+		}
+
+		data, _, err := handle.ReadPacketData()
+		if err == pcap.NextErrorTimeoutExpired {
+			continue
+		} else if err != nil {
+			s.logger.Error("error reading packet", "err", err)
+			continue
+		}
+		s.metrics.PacketReceived(s.dst.String())
+
+		//nolint:staticcheck // SA9003 ignore this!
+		if err := parser.DecodeLayers(data, &decoded); err != nil {
+			// Errors here are due to the decoder, and not all layers are implemented.
+		}
+		for _, typ := range decoded {
+			switch typ {
+			case layers.LayerTypeTCP:
+				if rIP4.SrcIP.Equal(s.dst) && rTCP.SrcPort == port && responses[rTCP.DstPort] == "" {
+					responses[rTCP.DstPort] = tcpResponse(&rTCP)
+				}
+			case layers.LayerTypeICMPv4:
+				// The quoted IP header of one of our packets is followed by
+				// at least the TCP ports.
+				quoted := rICMP.Payload
+				if len(quoted) < 20 || !net.IP(quoted[12:16]).Equal(s.src) || !net.IP(quoted[16:20]).Equal(s.dst) {
+					continue
+				}
+				ihl := int(quoted[0]&0x0f) * 4
+				if len(quoted) < ihl+4 {
+					continue
+				}
+				from := layers.TCPPort(binary.BigEndian.Uint16(quoted[ihl:]))
+				if responses[from] == "" {
+					responses[from] = fmt.Sprintf("icmp-3-%d", rICMP.TypeCode.Code())
+				}
+			}
+		}
+	}
+
+	response := func(i int) string {
+		if r, ok := responses[srcPort(i)]; ok {
+			return r
+		}
+		return "silence"
+	}
+	result := &FuzzResult{Iterations: iterations}
+	expected := response(0)
+	for i := 1; i < len(packets); i++ {
+		if r := response(i); r != expected {
+			result.Anomalies = append(result.Anomalies, FuzzAnomaly{
+				Mutation: fuzzMutations[(i-1)%len(fuzzMutations)],
+				Packet:   packets[i],
+				Response: r,
+				Expected: expected,
+			})
+		}
+	}
+	return result, nil
+}
+
+// tcpResponse names the kind of TCP segment tcp is.
+func tcpResponse(tcp *layers.TCP) string {
+	switch {
+	case tcp.RST:
+		return "rst"
+	case tcp.SYN && tcp.ACK:
+		return "syn-ack"
+	}
+	return fmt.Sprintf("tcp-%#02x", tcpFlags(tcp))
+}
+
+// tcpFlags returns the flags byte of tcp.
+func tcpFlags(tcp *layers.TCP) byte {
+	var f byte
+	for i, set := range []bool{tcp.FIN, tcp.SYN, tcp.RST, tcp.PSH, tcp.ACK, tcp.URG, tcp.ECE, tcp.CWR} {
+		if set {
+			f |= 1 << i
+		}
+	}
+	return f
+}
+
+// fuzzSYN serializes a well-formed IPv4 SYN packet, without TCP options,
+// from srcPort to port on the target.
+func (s *scanner) fuzzSYN(srcPort, port layers.TCPPort) ([]byte, error) {
+	ip4 := layers.IPv4{
+		SrcIP:    s.src,
+		DstIP:    s.dst,
+		Version:  4,
+		TTL:      s.ttl,
+		Protocol: layers.IPProtocolTCP,
+	}
+	tcp := layers.TCP{
+		SrcPort: srcPort,
+		DstPort: port,
+		Seq:     s.nextSeq(),
+		Window:  1024,
+		SYN:     true,
+	}
+	if err := tcp.SetNetworkLayerForChecksum(&ip4); err != nil {
+		return nil, err
+	}
+	buf := gopacket.NewSerializeBuffer()
+	if err := gopacket.SerializeLayers(buf, s.opts, &ip4, &tcp); err != nil {
+		return nil, err
+	}
+	return append([]byte(nil), buf.Bytes()...), nil
+}
+
+// mutate applies mutation to the IPv4 SYN packet p, fixing up the checksums
+// unless breaking them is the point.
+func (s *scanner) mutate(p []byte, mutation string) []byte {
+	ihl := int(p[0]&0x0f) * 4
+	switch mutation {
+	case MutationTruncatedHeader:
+		p = p[:ihl+4+s.rng.Intn(16)]
+		binary.BigEndian.PutUint16(p[2:], uint16(len(p)))
+	case MutationBadChecksum:
+		sum := binary.BigEndian.Uint16(p[ihl+16:])
+		binary.BigEndian.PutUint16(p[ihl+16:], sum^uint16(1+s.rng.Intn(0xffff)))
+		return p
+	case MutationReservedBits:
+		p[ihl+12] |= byte(1+s.rng.Intn(7)) << 1
+		setTCPChecksum(p, ihl)
+	case MutationOversizedLength:
+		if s.rng.Intn(2) == 0 {
+			binary.BigEndian.PutUint16(p[2:], 0xffff)
+		} else {
+			p[ihl+12] = 0xf0 | p[ihl+12]&0x0f
+			setTCPChecksum(p, ihl)
+		}
+	case MutationRandomOptions:
+		opts := make([]byte, 4*(1+s.rng.Intn(10)))
+		for i := range opts {
+			opts[i] = byte(s.rng.Intn(256))
+		}
+		p = append(p[:ihl+20], opts...)
+		p[ihl+12] = byte((20+len(opts))/4)<<4 | p[ihl+12]&0x0f
+		binary.BigEndian.PutUint16(p[2:], uint16(len(p)))
+		setTCPChecksum(p, ihl)
+	}
+	setIPv4Checksum(p, ihl)
+	return p
+}
+
+// setIPv4Checksum computes the header checksum of the IPv4 packet p.
+func setIPv4Checksum(p []byte, ihl int) {
+	p[10], p[11] = 0, 0
+	binary.BigEndian.PutUint16(p[10:], internetChecksum(p[:ihl], 0))
+}
+
+// setTCPChecksum computes the checksum of the TCP segment of the IPv4
+// packet p, pseudo-header included.
+func setTCPChecksum(p []byte, ihl int) {
+	segment := p[ihl:]
+	segment[16], segment[17] = 0, 0
+	var pseudo uint32
+	for i := 12; i < 20; i += 2 {
+		pseudo += uint32(binary.BigEndian.Uint16(p[i:]))
+	}
+	pseudo += uint32(layers.IPProtocolTCP) + uint32(len(segment))
+	binary.BigEndian.PutUint16(segment[16:], internetChecksum(segment, pseudo))
+}
+
+// internetChecksum computes the RFC 1071 checksum of b, starting from sum.
+func internetChecksum(b []byte, sum uint32) uint16 {
+	for i := 0; i+1 < len(b); i += 2 {
+		sum += uint32(binary.BigEndian.Uint16(b[i:]))
+	}
+	if len(b)%2 == 1 {
+		sum += uint32(b[len(b)-1]) << 8
+	}
+	for sum > 0xffff {
+		sum = sum>>16 + sum&0xffff
+	}
+	return ^uint16(sum)
+}
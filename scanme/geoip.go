@@ -0,0 +1,82 @@
+package scanme
+
+import (
+	"errors"
+	"fmt"
+	"net"
+
+	"github.com/oschwald/geoip2-golang"
+)
+
+// GeoLocation holds the location and network owner of a scanned target, as
+// found in the MaxMind databases loaded with WithGeoIP. Fields missing from
+// the databases are left empty.
+type GeoLocation struct {
+	Country   string
+	City      string
+	Latitude  float64
+	Longitude float64
+	ASN       uint
+	ISP       string
+}
+
+// openGeoIP opens the MaxMind databases at paths.
+func openGeoIP(paths []string) ([]*geoip2.Reader, error) {
+	var readers []*geoip2.Reader
+	for _, path := range paths {
+		r, err := geoip2.Open(path)
+		if err != nil {
+			closeGeoIP(readers)
+			return nil, fmt.Errorf("error opening GeoIP database %s: %w", path, err)
+		}
+		readers = append(readers, r)
+	}
+	return readers, nil
+}
+
+// closeGeoIP closes the databases opened by openGeoIP.
+func closeGeoIP(readers []*geoip2.Reader) {
+	for _, r := range readers {
+		r.Close()
+	}
+}
+
+// geoLookup looks ip up in the loaded GeoIP databases, each contributing the
+// fields it holds. It returns nil when no database is loaded.
+func (s *scanner) geoLookup(ip net.IP) *GeoLocation {
+	if len(s.geoip) == 0 {
+		return nil
+	}
+
+	geo := &GeoLocation{}
+	for _, r := range s.geoip {
+		if city, err := r.City(ip); err == nil {
+			geo.Country = city.Country.IsoCode
+			geo.City = city.City.Names["en"]
+			geo.Latitude = city.Location.Latitude
+			geo.Longitude = city.Location.Longitude
+		} else if !isInvalidMethod(err) {
+			s.logger.Error("GeoIP city lookup failed", "ip", ip, "err", err)
+		}
+
+		if isp, err := r.ISP(ip); err == nil {
+			geo.ASN = isp.AutonomousSystemNumber
+			geo.ISP = isp.ISP
+		} else if asn, err := r.ASN(ip); err == nil {
+			geo.ASN = asn.AutonomousSystemNumber
+			if geo.ISP == "" {
+				geo.ISP = asn.AutonomousSystemOrganization
+			}
+		} else if !isInvalidMethod(err) {
+			s.logger.Error("GeoIP ASN lookup failed", "ip", ip, "err", err)
+		}
+	}
+	return geo
+}
+
+// isInvalidMethod reports whether err comes from looking up a record type
+// the database does not hold, e.g. a city in an ASN database.
+func isInvalidMethod(err error) bool {
+	var invalid geoip2.InvalidMethodError
+	return errors.As(err, &invalid)
+}
@@ -0,0 +1,88 @@
+package scanme
+
+import (
+	"errors"
+	"io/fs"
+	"net"
+	"strings"
+	"testing"
+)
+
+// The databases of testdata hold records in the layout of the MaxMind test
+// databases:
+//   - GeoIP2-City-Test.mmdb: London for 81.2.69.160/27, Boxford for
+//     2.125.160.216/29;
+//   - GeoLite2-ASN-Test.mmdb: AS1221 for 1.128.0.0/11, AS20712 for
+//     81.2.69.0/24;
+//   - GeoIP2-ISP-Test.mmdb: AS1221 and its ISP for 1.128.0.0/11.
+const (
+	testCityDB = "testdata/GeoIP2-City-Test.mmdb"
+	testASNDB  = "testdata/GeoLite2-ASN-Test.mmdb"
+	testISPDB  = "testdata/GeoIP2-ISP-Test.mmdb"
+)
+
+func TestGeoLookup(t *testing.T) {
+	tests := []struct {
+		name string
+		dbs  []string
+		ip   string
+		want GeoLocation
+	}{
+		{
+			"city", []string{testCityDB}, "81.2.69.160",
+			GeoLocation{Country: "GB", City: "London", Latitude: 51.5142, Longitude: -0.0931},
+		},
+		{
+			"city and asn", []string{testCityDB, testASNDB}, "81.2.69.170",
+			GeoLocation{Country: "GB", City: "London", Latitude: 51.5142, Longitude: -0.0931, ASN: 20712, ISP: "Andrews & Arnold Ltd"},
+		},
+		{
+			"asn", []string{testCityDB, testASNDB}, "1.128.0.1",
+			GeoLocation{ASN: 1221, ISP: "Telstra Pty Ltd"},
+		},
+		{
+			"isp", []string{testISPDB}, "1.128.0.1",
+			GeoLocation{ASN: 1221, ISP: "Telstra Internet"},
+		},
+		{
+			"ipv4-mapped", []string{testCityDB}, "::ffff:2.125.160.217",
+			GeoLocation{Country: "GB", City: "Boxford", Latitude: 51.75, Longitude: -1.25},
+		},
+		{
+			"unknown", []string{testCityDB, testASNDB}, "192.0.2.1",
+			GeoLocation{},
+		},
+	}
+	for _, tt := range tests {
+		readers, err := openGeoIP(tt.dbs)
+		if err != nil {
+			t.Fatalf("%s: openGeoIP() error = %v", tt.name, err)
+		}
+		s := newTestScanner()
+		s.geoip = readers
+		got := s.geoLookup(net.ParseIP(tt.ip))
+		closeGeoIP(readers)
+		if got == nil || *got != tt.want {
+			t.Errorf("%s: geoLookup(%s) = %+v, want %+v", tt.name, tt.ip, got, tt.want)
+		}
+	}
+}
+
+func TestGeoLookupWithoutDatabase(t *testing.T) {
+	if got := newTestScanner().geoLookup(net.ParseIP("81.2.69.160")); got != nil {
+		t.Errorf("geoLookup() = %+v without database, want nil", got)
+	}
+}
+
+func TestOpenGeoIPMissingDatabase(t *testing.T) {
+	_, err := openGeoIP([]string{testCityDB, "testdata/missing.mmdb"})
+	if !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("openGeoIP() error = %v, want fs.ErrNotExist", err)
+	}
+	if err != nil && !strings.Contains(err.Error(), "testdata/missing.mmdb") {
+		t.Errorf("error %q does not name the missing database", err)
+	}
+	if _, err := openGeoIP([]string{"testdata/nmap.xml"}); err == nil {
+		t.Error("openGeoIP() of a file that is not a database: error = nil")
+	}
+}
@@ -11,11 +11,23 @@ import (
 	"time"
 
 	"github.com/go-ldap/ldap/v3"
+	"github.com/google/gopacket/layers"
 	"github.com/miekg/dns"
 )
 
+const (
+	// bannerTimeout is the timeout used by the package level banner grabbers.
+	bannerTimeout = 1 * time.Second
+	// maxBannerSize bounds the size of the banners read.
+	maxBannerSize = 4096
+)
+
 func GetHeader(ipAddress string, port int) (string, error) {
-	conn, err := net.DialTimeout("tcp", ipAddress+":"+strconv.Itoa(port), 1*time.Second)
+	return getHeader(ipAddress, port, bannerTimeout)
+}
+
+func getHeader(ipAddress string, port int, timeout time.Duration) (string, error) {
+	conn, err := net.DialTimeout("tcp", ipAddress+":"+strconv.Itoa(port), timeout)
 	if err != nil {
 		return "", err
 	}
@@ -52,7 +64,11 @@ func GetHeader(ipAddress string, port int) (string, error) {
 }
 
 func GrabMysqlBanner(ipAddress string, port int) (string, error) {
-	req, err := net.DialTimeout("tcp", ipAddress+":"+strconv.Itoa(port), 1*time.Second)
+	return grabMysqlBanner(ipAddress, port, bannerTimeout)
+}
+
+func grabMysqlBanner(ipAddress string, port int, timeout time.Duration) (string, error) {
+	req, err := net.DialTimeout("tcp", ipAddress+":"+strconv.Itoa(port), timeout)
 	if err != nil {
 		return "", err
 	}
@@ -75,8 +91,15 @@ func GrabMysqlBanner(ipAddress string, port int) (string, error) {
 }
 
 func GetLDAPBanner(ipAddress string, port int) (string, error) {
+	return getLDAPBanner(ipAddress, port, bannerTimeout)
+}
+
+func getLDAPBanner(ipAddress string, port int, timeout time.Duration) (string, error) {
 	tlsConfig := &tls.Config{InsecureSkipVerify: true}
-	l, err := ldap.DialTLS("tcp", fmt.Sprintf("%s:%d", ipAddress, port), tlsConfig)
+	l, err := ldap.DialURL(fmt.Sprintf("ldaps://%s:%d", ipAddress, port),
+		ldap.DialWithTLSConfig(tlsConfig),
+		ldap.DialWithDialer(&net.Dialer{Timeout: timeout}),
+	)
 
 	if err != nil {
 		return "", err
@@ -113,7 +136,11 @@ func GetLDAPBanner(ipAddress string, port int) (string, error) {
 }
 
 func GetDNSBanner(ipAddress string, port int) (string, error) {
-	c := new(dns.Client)
+	return getDNSBanner(ipAddress, port, 0)
+}
+
+func getDNSBanner(ipAddress string, port int, timeout time.Duration) (string, error) {
+	c := &dns.Client{Timeout: timeout}
 	m := new(dns.Msg)
 	m.Question = make([]dns.Question, 1)
 	m.Question[0] = dns.Question{Name: "version.bind.", Qtype: dns.TypeTXT, Qclass: dns.ClassCHAOS}
@@ -137,6 +164,20 @@ func GetDNSBanner(ipAddress string, port int) (string, error) {
 }
 
 func GrabBanner(ipAddress string, port int) string {
+	banner, err := grabBanner(ipAddress, port, bannerTimeout)
+	if err != nil {
+		return ""
+	}
+	return banner
+}
+
+// GrabBanner grabs the banner of the service listening on port of the
+// target, waiting at most timeout to connect.
+func (s *scanner) GrabBanner(port layers.TCPPort, timeout time.Duration) (string, error) {
+	return grabBanner(s.dst.String(), int(port), timeout)
+}
+
+func grabBanner(ipAddress string, port int, timeout time.Duration) (string, error) {
 	switch port {
 	case 21: // FTP
 	case 22: // SSH
@@ -145,51 +186,48 @@ func GrabBanner(ipAddress string, port int) string {
 	case 119: // NNTP
 	case 143: // IMAP
 	case 636: // LDAPS
-		serverInfo, err := GetLDAPBanner(ipAddress, port)
-		if err != nil {
-			return ""
-		}
-		return serverInfo
+		return getLDAPBanner(ipAddress, port, timeout)
 	case 3306: // MYSQL
-		mysqlBanner, err := GrabMysqlBanner(ipAddress, port)
-		if err != nil {
-			return ""
-		}
-		return mysqlBanner
+		return grabMysqlBanner(ipAddress, port, timeout)
 	case 80: // HTTP
-		serverHeader, err := GetHeader(ipAddress, port)
-		if err != nil {
-			return ""
-		}
-		return serverHeader
+		return getHeader(ipAddress, port, timeout)
 	case 443: // HTTPS
-		serverHeader, err := GetHeader(ipAddress, port)
-		if err != nil {
-			return ""
-		}
-		return serverHeader
+		return getHeader(ipAddress, port, timeout)
 	case 6667: // IRC
 	case 53: // DNS
-		dnsBanner, err := GetDNSBanner(ipAddress, port)
-		if err != nil {
-			return ""
-		}
-		return dnsBanner
+		return getDNSBanner(ipAddress, port, timeout)
 	default:
-		return ""
+		return "", nil
 	}
 
-	req, err := net.DialTimeout("tcp", ipAddress+":"+strconv.Itoa(port), 1*time.Second)
+	req, err := net.DialTimeout("tcp", net.JoinHostPort(ipAddress, strconv.Itoa(port)), timeout)
 	if err != nil {
-		return ""
+		return "", err
 	}
 	defer req.Close()
 
-	read, err := bufio.NewReader(req).ReadString('\n')
+	banner, err := readBanner(req, timeout)
 	if err != nil {
-		return ""
+		return "", err
 	}
 
-	serviceBanner := strings.Trim(read, "\r\n\t ")
-	return serviceBanner
+	serviceBanner := strings.Trim(banner, "\r\n\t ")
+	return serviceBanner, nil
+}
+
+// readBanner returns what the service on conn sends upon connection, the
+// data of the first read returning any within timeout, up to maxBannerSize
+// bytes. The kernel reassembles the segments of the connection, so the
+// banners of a dialed connection do not go through the flow package, which
+// reassembles streams from captured packets.
+func readBanner(conn net.Conn, timeout time.Duration) (string, error) {
+	if err := conn.SetReadDeadline(time.Now().Add(timeout)); err != nil {
+		return "", err
+	}
+	buf := make([]byte, maxBannerSize)
+	n, err := conn.Read(buf)
+	if n == 0 {
+		return "", err
+	}
+	return string(buf[:n]), nil
 }
@@ -0,0 +1,51 @@
+package scanme
+
+import (
+	"errors"
+	"net"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestReadBannerReturnsFirstData(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	port := testServer(t, "127.0.0.1:0", func(conn net.Conn) {
+		conn.Write([]byte("220 mail.example.test ESMTP\r\n")) //nolint:errcheck // the test checks what is read
+		// Stay connected without sending anything else.
+		<-release
+	})
+
+	conn, err := net.Dial("tcp", net.JoinHostPort("127.0.0.1", strconv.Itoa(int(port))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	start := time.Now()
+	banner, err := readBanner(conn, 5*time.Second)
+	if err != nil || banner != "220 mail.example.test ESMTP\r\n" {
+		t.Fatalf("readBanner() = %q, %v, want the greeting", banner, err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("readBanner() took %v, want it to return with the first data", elapsed)
+	}
+}
+
+func TestReadBannerTimeout(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	port := testServer(t, "127.0.0.1:0", func(net.Conn) { <-release })
+
+	conn, err := net.Dial("tcp", net.JoinHostPort("127.0.0.1", strconv.Itoa(int(port))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	if _, err := readBanner(conn, 50*time.Millisecond); !errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Errorf("readBanner() of a silent service: error = %v, want a timeout", err)
+	}
+}
@@ -0,0 +1,80 @@
+package scanme
+
+import "github.com/google/gopacket/layers"
+
+// PortGroup is a category of services, such as "Web Services", and the
+// ports they are usually found on.
+type PortGroup struct {
+	Name  string
+	Ports []layers.TCPPort
+}
+
+// GroupedResult holds the open ports of a scan by PortGroup, see GroupPorts.
+type GroupedResult struct {
+	// Groups holds the open ports of each group by group name, groups
+	// without open ports are left out.
+	Groups map[string][]PortResult
+	// Ungrouped holds the open ports not part of any group.
+	Ungrouped []PortResult
+}
+
+// GroupPorts sorts the open ports of result into groups, such as the
+// StandardGroups, to be reviewed by category rather than one by one. A port
+// part of several groups is listed in each of them. Ports keep the order of
+// result.
+func GroupPorts(result *ScanResult, groups []PortGroup) *GroupedResult {
+	grouped := &GroupedResult{Groups: make(map[string][]PortResult)}
+	if result == nil {
+		return grouped
+	}
+	byPort := make(map[layers.TCPPort][]string)
+	for _, g := range groups {
+		for _, port := range g.Ports {
+			byPort[port] = append(byPort[port], g.Name)
+		}
+	}
+	for _, p := range result.Ports {
+		if p.State != "open" {
+			continue
+		}
+		names, found := byPort[p.Port]
+		if !found {
+			grouped.Ungrouped = append(grouped.Ungrouped, p)
+			continue
+		}
+		for _, name := range names {
+			grouped.Groups[name] = append(grouped.Groups[name], p)
+		}
+	}
+	return grouped
+}
+
+// StandardGroups returns the service categories of the SANS Top 20, with the
+// default ports of their common services. The slice is a fresh copy, callers
+// may modify or extend it.
+func StandardGroups() []PortGroup {
+	return []PortGroup{
+		{Name: "Web Services", Ports: groupPorts("80,81,443,591,8000,8008,8080,8081,8443,8888,9443")},
+		{Name: "Remote Access", Ports: groupPorts("22,23,512-514,3389,5800,5900-5909,5985,5986")},
+		{Name: "File Sharing", Ports: groupPorts("20,21,69,137-139,445,873,2049,3260")},
+		{Name: "Database", Ports: groupPorts("1433,1434,1521,3306,5432,5984,6379,7000,7001,9042,9200,9300,11211,27017,27018")},
+		{Name: "Mail", Ports: groupPorts("25,110,143,465,587,993,995")},
+		{Name: "Name Services", Ports: groupPorts("53,5353,5355")},
+		{Name: "Directory Services", Ports: groupPorts("88,389,464,636,3268,3269")},
+		{Name: "Network Management", Ports: groupPorts("161,162,199,623,830,10000")},
+		{Name: "Messaging", Ports: groupPorts("1883,4369,5222,5672,6667,8883,9092,61613,61616")},
+		{Name: "Printing", Ports: groupPorts("515,631,9100")},
+		{Name: "Voice and Video", Ports: groupPorts("554,1720,5060,5061")},
+		{Name: "Industrial Control", Ports: groupPorts("102,502,1911,2404,20000,44818,47808")},
+		{Name: "RPC Services", Ports: groupPorts("111,135,593,2103,2105,2107")},
+	}
+}
+
+// groupPorts parses the ports of a standard group, which are known valid.
+func groupPorts(spec string) []layers.TCPPort {
+	ports, err := ParsePorts(spec)
+	if err != nil {
+		panic(err)
+	}
+	return ports
+}
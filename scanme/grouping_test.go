@@ -0,0 +1,78 @@
+package scanme
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/google/gopacket/layers"
+)
+
+// groupedPorts returns the port numbers of ports.
+func groupedPorts(ports []PortResult) []layers.TCPPort {
+	var numbers []layers.TCPPort
+	for _, p := range ports {
+		numbers = append(numbers, p.Port)
+	}
+	return numbers
+}
+
+func TestGroupPorts(t *testing.T) {
+	result := &ScanResult{Ports: []PortResult{
+		{Port: 22, State: "open"},
+		{Port: 25, State: "closed"},
+		{Port: 80, State: "open"},
+		{Port: 443, State: "open"},
+		{Port: 3306, State: "open"},
+		{Port: 8080, State: "open"},
+		{Port: 31337, State: "open"},
+	}}
+	groups := []PortGroup{
+		{Name: "Web", Ports: []layers.TCPPort{80, 443, 8080}},
+		{Name: "Remote Access", Ports: []layers.TCPPort{22, 23, 3389}},
+		{Name: "Mail", Ports: []layers.TCPPort{25, 110}},
+		{Name: "Proxies", Ports: []layers.TCPPort{3128, 8080}},
+	}
+	grouped := GroupPorts(result, groups)
+
+	want := map[string][]layers.TCPPort{
+		"Web":           {80, 443, 8080},
+		"Remote Access": {22},
+		"Proxies":       {8080},
+	}
+	if len(grouped.Groups) != len(want) {
+		t.Errorf("Groups = %v, want the groups with open ports only", grouped.Groups)
+	}
+	for name, ports := range want {
+		if got := groupedPorts(grouped.Groups[name]); !slices.Equal(got, ports) {
+			t.Errorf("Groups[%q] = %v, want %v", name, got, ports)
+		}
+	}
+	if got := groupedPorts(grouped.Ungrouped); !slices.Equal(got, []layers.TCPPort{3306, 31337}) {
+		t.Errorf("Ungrouped = %v, want [3306 31337]", got)
+	}
+
+	if grouped := GroupPorts(nil, groups); grouped.Groups == nil || len(grouped.Groups) != 0 || grouped.Ungrouped != nil {
+		t.Errorf("GroupPorts(nil) = %+v, want an empty result", grouped)
+	}
+}
+
+func TestStandardGroups(t *testing.T) {
+	groups := StandardGroups()
+	result := &ScanResult{}
+	for _, port := range []layers.TCPPort{443, 3389, 445, 5432, 25, 53, 389, 161, 5672, 9100, 5060, 502, 111, 5905} {
+		result.Ports = append(result.Ports, PortResult{Port: port, State: "open"})
+	}
+	grouped := GroupPorts(result, groups)
+	if len(grouped.Groups) != 13 || len(grouped.Ungrouped) != 0 {
+		t.Errorf("%d groups and %v ungrouped, want every standard group and none", len(grouped.Groups), grouped.Ungrouped)
+	}
+	if got := groupedPorts(grouped.Groups["Remote Access"]); !slices.Equal(got, []layers.TCPPort{3389, 5905}) {
+		t.Errorf("Remote Access = %v, want [3389 5905]", got)
+	}
+
+	// Each call returns a fresh copy.
+	groups[0].Ports[0] = 1
+	if StandardGroups()[0].Ports[0] == 1 {
+		t.Error("StandardGroups() shares its ports between calls")
+	}
+}
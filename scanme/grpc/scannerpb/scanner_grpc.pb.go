@@ -0,0 +1,140 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.3.0
+// - protoc             (unknown)
+// source: scanner.proto
+
+package scannerpb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+const (
+	ScannerService_Scan_FullMethodName = "/scanme.v1.ScannerService/Scan"
+)
+
+// ScannerServiceClient is the client API for ScannerService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type ScannerServiceClient interface {
+	// Scan scans a single target. The server streams a PortEvent for every
+	// port found, then a final ScanResponse.
+	Scan(ctx context.Context, in *ScanRequest, opts ...grpc.CallOption) (ScannerService_ScanClient, error)
+}
+
+type scannerServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewScannerServiceClient(cc grpc.ClientConnInterface) ScannerServiceClient {
+	return &scannerServiceClient{cc}
+}
+
+func (c *scannerServiceClient) Scan(ctx context.Context, in *ScanRequest, opts ...grpc.CallOption) (ScannerService_ScanClient, error) {
+	stream, err := c.cc.NewStream(ctx, &ScannerService_ServiceDesc.Streams[0], ScannerService_Scan_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &scannerServiceScanClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type ScannerService_ScanClient interface {
+	Recv() (*ScanEvent, error)
+	grpc.ClientStream
+}
+
+type scannerServiceScanClient struct {
+	grpc.ClientStream
+}
+
+func (x *scannerServiceScanClient) Recv() (*ScanEvent, error) {
+	m := new(ScanEvent)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// ScannerServiceServer is the server API for ScannerService service.
+// All implementations must embed UnimplementedScannerServiceServer
+// for forward compatibility
+type ScannerServiceServer interface {
+	// Scan scans a single target. The server streams a PortEvent for every
+	// port found, then a final ScanResponse.
+	Scan(*ScanRequest, ScannerService_ScanServer) error
+	mustEmbedUnimplementedScannerServiceServer()
+}
+
+// UnimplementedScannerServiceServer must be embedded to have forward compatible implementations.
+type UnimplementedScannerServiceServer struct {
+}
+
+func (UnimplementedScannerServiceServer) Scan(*ScanRequest, ScannerService_ScanServer) error {
+	return status.Errorf(codes.Unimplemented, "method Scan not implemented")
+}
+func (UnimplementedScannerServiceServer) mustEmbedUnimplementedScannerServiceServer() {}
+
+// UnsafeScannerServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to ScannerServiceServer will
+// result in compilation errors.
+type UnsafeScannerServiceServer interface {
+	mustEmbedUnimplementedScannerServiceServer()
+}
+
+func RegisterScannerServiceServer(s grpc.ServiceRegistrar, srv ScannerServiceServer) {
+	s.RegisterService(&ScannerService_ServiceDesc, srv)
+}
+
+func _ScannerService_Scan_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(ScanRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(ScannerServiceServer).Scan(m, &scannerServiceScanServer{stream})
+}
+
+type ScannerService_ScanServer interface {
+	Send(*ScanEvent) error
+	grpc.ServerStream
+}
+
+type scannerServiceScanServer struct {
+	grpc.ServerStream
+}
+
+func (x *scannerServiceScanServer) Send(m *ScanEvent) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+// ScannerService_ServiceDesc is the grpc.ServiceDesc for ScannerService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var ScannerService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "scanme.v1.ScannerService",
+	HandlerType: (*ScannerServiceServer)(nil),
+	Methods:     []grpc.MethodDesc{},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Scan",
+			Handler:       _ScannerService_Scan_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "scanner.proto",
+}
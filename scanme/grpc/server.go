@@ -0,0 +1,191 @@
+// Package grpc serves scans over gRPC, so that a central service can run
+// scans on behalf of remote agents across network boundaries. The service is
+// defined in scannerpb/scanner.proto; scannerpb also holds the generated
+// client.
+package grpc
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/CyberRoute/scanme/scanme"
+	"github.com/CyberRoute/scanme/scanme/config"
+	"github.com/CyberRoute/scanme/scanme/grpc/scannerpb"
+	"github.com/google/gopacket/layers"
+	"github.com/google/gopacket/routing"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Server implements scannerpb.ScannerServiceServer, running at most a fixed
+// number of scans at once. Register it with
+// scannerpb.RegisterScannerServiceServer.
+type Server struct {
+	scannerpb.UnimplementedScannerServiceServer
+	sem    chan struct{}
+	router routing.Router
+}
+
+// NewServer creates a server running at most maxConcurrent scans at once,
+// routing packets with router. A maxConcurrent lower than one is treated as
+// one.
+func NewServer(maxConcurrent int, router routing.Router) *Server {
+	if maxConcurrent < 1 {
+		maxConcurrent = 1
+	}
+	return &Server{
+		sem:    make(chan struct{}, maxConcurrent),
+		router: router,
+	}
+}
+
+// Scan runs the scan described by req, then streams a PortEvent for every
+// port found followed by the ScanResponse. Scans are rejected with
+// codes.ResourceExhausted when the server is at capacity.
+func (s *Server) Scan(req *scannerpb.ScanRequest, stream scannerpb.ScannerService_ScanServer) error {
+	select {
+	case s.sem <- struct{}{}:
+	default:
+		return status.Errorf(codes.ResourceExhausted, "%d scans already running", cap(s.sem))
+	}
+	defer func() { <-s.sem }()
+
+	ip := net.ParseIP(req.GetTargetIp())
+	if ip == nil {
+		return status.Errorf(codes.InvalidArgument, "invalid target IP %q", req.GetTargetIp())
+	}
+	cfg, err := requestConfig(req)
+	if err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	var resp *scannerpb.ScanResponse
+	switch req.GetScanType() {
+	case scannerpb.ScanType_SCAN_TYPE_SYN:
+		if ip = ip.To4(); ip == nil {
+			return status.Errorf(codes.InvalidArgument, "SYN scans require an IPv4 target")
+		}
+		resp, err = s.synScan(ip, cfg)
+	case scannerpb.ScanType_SCAN_TYPE_CONNECT:
+		resp, err = s.connScan(ip, cfg)
+	default:
+		return status.Errorf(codes.InvalidArgument, "unknown scan type %v", req.GetScanType())
+	}
+	if err != nil {
+		return status.Errorf(codes.Internal, "scan of %v failed: %v", ip, err)
+	}
+
+	for _, port := range resp.Results {
+		event := &scannerpb.ScanEvent{Event: &scannerpb.ScanEvent_Port{Port: port}}
+		if err := stream.Send(event); err != nil {
+			return err
+		}
+	}
+	return stream.Send(&scannerpb.ScanEvent{Event: &scannerpb.ScanEvent_Done{Done: resp}})
+}
+
+// requestConfig maps the port range and options of req to a scan
+// configuration.
+func requestConfig(req *scannerpb.ScanRequest) (*config.Config, error) {
+	cfg := config.Default()
+	cfg.Ports = req.GetPortRange()
+	for name, value := range req.GetOptions() {
+		var err error
+		switch name {
+		case "rate_limit":
+			cfg.RateLimit, err = strconv.Atoi(value)
+		case "speed":
+			cfg.Speed = value
+		case "ttl":
+			var ttl uint64
+			ttl, err = strconv.ParseUint(value, 10, 8)
+			cfg.TTL = uint8(ttl)
+		case "random_order":
+			cfg.RandomOrder, err = strconv.ParseBool(value)
+		case "resolve_dns":
+			cfg.ResolveDNS, err = strconv.ParseBool(value)
+		default:
+			return nil, fmt.Errorf("unknown option %q", name)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid value %q of option %s", value, name)
+		}
+	}
+	return cfg, nil
+}
+
+func (s *Server) synScan(ip net.IP, cfg *config.Config) (*scannerpb.ScanResponse, error) {
+	opts, err := cfg.ScanOptions()
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	scanner, err := scanme.NewScanner(ip, s.router, opts...)
+	if err != nil {
+		return nil, err
+	}
+	defer scanner.Close()
+
+	result, err := scanner.Synscan()
+	if err != nil {
+		return nil, err
+	}
+	resp := &scannerpb.ScanResponse{
+		Stats: &scannerpb.ScanStats{
+			PacketsSent:     int64(result.Stats.PacketsSent),
+			PacketsReceived: int64(result.Stats.PacketsReceived),
+			DurationNs:      int64(result.Stats.Duration),
+			RateLimitEvents: int64(result.Stats.RateLimitEvents),
+		},
+	}
+	for _, p := range result.Ports {
+		resp.Results = append(resp.Results, &scannerpb.PortEvent{
+			Port:      uint32(p.Port),
+			State:     p.State,
+			Service:   p.Service,
+			LatencyNs: int64(p.Latency),
+		})
+	}
+	return resp, nil
+}
+
+// connScan runs a connect scan, which always probes every port, and keeps
+// the ports of the requested range.
+func (s *Server) connScan(ip net.IP, cfg *config.Config) (*scannerpb.ScanResponse, error) {
+	ports, err := scanme.ParsePorts(cfg.Ports)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	wanted := make(map[layers.TCPPort]bool, len(ports))
+	for _, p := range ports {
+		wanted[p] = true
+	}
+
+	scanner, err := scanme.NewScanner(ip, s.router)
+	if err != nil {
+		return nil, err
+	}
+	defer scanner.Close()
+
+	open, err := scanner.ConnScan()
+	if err != nil {
+		return nil, err
+	}
+	resp := &scannerpb.ScanResponse{Stats: &scannerpb.ScanStats{}}
+	for port, state := range open {
+		if len(wanted) > 0 && !wanted[port] {
+			continue
+		}
+		// ConnScan reports states as "<service> open".
+		service := strings.TrimSpace(strings.TrimSuffix(state, "open"))
+		resp.Results = append(resp.Results, &scannerpb.PortEvent{
+			Port:    uint32(port),
+			State:   "open",
+			Service: strings.Trim(service, "()"),
+		})
+	}
+	sort.Slice(resp.Results, func(i, j int) bool { return resp.Results[i].Port < resp.Results[j].Port })
+	return resp, nil
+}
@@ -0,0 +1,147 @@
+package scanme
+
+import (
+	"math"
+	"strings"
+)
+
+// The weights of the heuristics of HoneypotScore. They add up to more than
+// one: the score is capped at 1.
+const (
+	// honeypotAllOpenWeight is added when (nearly) every port is open:
+	// real hosts run a handful of services, while many honeypots accept
+	// connections on any port.
+	honeypotAllOpenWeight = 0.5
+	// honeypotSameBannerWeight is added when every open port carrying a
+	// banner carries the same one, as emulated services often do.
+	honeypotSameBannerWeight = 0.3
+	// honeypotUniformLatencyWeight is added when the latencies of the open
+	// ports hardly vary: a real network stack under load does not answer in
+	// exactly the same time every time.
+	honeypotUniformLatencyWeight = 0.2
+	// honeypotOSMismatchWeight is added when services claim to run on
+	// different operating systems, such as IIS next to an Ubuntu SSH server.
+	honeypotOSMismatchWeight = 0.1
+)
+
+const (
+	// honeypotOpenPorts is the number of open ports from which a host is
+	// considered to answer on every port, leaving room for a few probes
+	// lost on the way.
+	honeypotOpenPorts = 65000
+	// honeypotMinSamples is the number of banners or latencies needed for
+	// their heuristics to apply.
+	honeypotMinSamples = 3
+	// honeypotMaxLatencyCV is the coefficient of variation of latencies,
+	// their standard deviation over their mean, below which they are
+	// considered suspiciously uniform.
+	honeypotMaxLatencyCV = 0.01
+)
+
+// honeypotOSMarkers map operating system families to the words revealing
+// them in banners and HTTP Server headers.
+var honeypotOSMarkers = map[string][]string{
+	"windows": {"windows", "microsoft", "iis", "win32", "win64"},
+	"unix":    {"ubuntu", "debian", "centos", "red hat", "fedora", "linux", "freebsd", "openbsd", "unix"},
+}
+
+// HoneypotScore returns the probability, between 0 and 1, that the target
+// of result is a honeypot, from the sum of the weights of the following
+// heuristics:
+//
+//   - 0.5 when all ports are open, 65000 or more of them;
+//   - 0.3 when at least 3 open ports carry a banner, read from their
+//     ServiceHint (see the banner hook of config.ScanTemplate), and all
+//     banners are identical;
+//   - 0.2 when at least 3 open ports have a latency and the coefficient of
+//     variation of their latencies is below 0.01;
+//   - 0.1 when banners and HTTP Server headers mention both Windows and
+//     Unix-like operating systems.
+//
+// A high score is a hint, not a proof: load balancers and SYN proxies also
+// answer on every port.
+func HoneypotScore(result *ScanResult) float64 {
+	var score float64
+	var open []PortResult
+	for _, p := range result.Ports {
+		if p.State == "open" {
+			open = append(open, p)
+		}
+	}
+	if len(open) >= honeypotOpenPorts {
+		score += honeypotAllOpenWeight
+	}
+	if sameBanners(open) {
+		score += honeypotSameBannerWeight
+	}
+	if uniformLatency(open) {
+		score += honeypotUniformLatencyWeight
+	}
+	if osMismatch(open) {
+		score += honeypotOSMismatchWeight
+	}
+	return math.Min(score, 1)
+}
+
+// sameBanners reports whether enough ports carry a banner and all banners
+// are the same.
+func sameBanners(ports []PortResult) bool {
+	var banner string
+	var n int
+	for _, p := range ports {
+		if p.ServiceHint == "" {
+			continue
+		}
+		if n > 0 && p.ServiceHint != banner {
+			return false
+		}
+		banner = p.ServiceHint
+		n++
+	}
+	return n >= honeypotMinSamples
+}
+
+// uniformLatency reports whether enough ports have a latency and their
+// coefficient of variation is below honeypotMaxLatencyCV.
+func uniformLatency(ports []PortResult) bool {
+	var samples []float64
+	var sum float64
+	for _, p := range ports {
+		if p.Latency > 0 {
+			samples = append(samples, float64(p.Latency))
+			sum += float64(p.Latency)
+		}
+	}
+	if len(samples) < honeypotMinSamples {
+		return false
+	}
+	mean := sum / float64(len(samples))
+	var variance float64
+	for _, s := range samples {
+		variance += (s - mean) * (s - mean)
+	}
+	variance /= float64(len(samples))
+	return math.Sqrt(variance)/mean < honeypotMaxLatencyCV
+}
+
+// osMismatch reports whether the banners and HTTP Server headers of ports
+// point at more than one operating system family.
+func osMismatch(ports []PortResult) bool {
+	found := make(map[string]bool)
+	for _, p := range ports {
+		text := p.ServiceHint
+		if fp := p.HTTPFingerprint; fp != nil {
+			text += " " + fp.ServerHeader
+		}
+		text = strings.ToLower(text)
+		for family, markers := range honeypotOSMarkers {
+			for _, m := range markers {
+				if strings.Contains(text, m) {
+					found[family] = true
+					break
+				}
+			}
+		}
+	}
+	return len(found) > 1
+}
@@ -0,0 +1,143 @@
+package scanme
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/gopacket/layers"
+)
+
+// httpFingerprintTimeout bounds an HTTP fingerprinting request.
+const httpFingerprintTimeout = 5 * time.Second
+
+// HTTPFingerprint holds the response headers identifying a web server.
+type HTTPFingerprint struct {
+	StatusCode            int
+	ServerHeader          string
+	PoweredBy             string
+	SetCookie             string
+	ContentType           string
+	XFrameOptions         string
+	ContentSecurityPolicy string
+	ViaHeader             string
+	// HeaderOrder lists the response header names in the order they were
+	// sent, which is characteristic of the server software.
+	HeaderOrder []string
+	// HeaderOrderHash is the SHA-256 of HeaderOrder, in hex.
+	HeaderOrderHash string
+}
+
+// HTTPFingerprint sends a GET request for / to port on the target, over TLS
+// if useTLS is set, and collects the response headers identifying the web
+// server. The response is read raw so that header order is preserved.
+func (s *scanner) HTTPFingerprint(port layers.TCPPort, useTLS bool) (*HTTPFingerprint, error) {
+	addr := net.JoinHostPort(s.dst.String(), strconv.Itoa(int(port)))
+	conn, err := net.DialTimeout("tcp", addr, httpFingerprintTimeout)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	if err := conn.SetDeadline(time.Now().Add(httpFingerprintTimeout)); err != nil {
+		return nil, err
+	}
+
+	if useTLS {
+		tlsConn := tls.Client(conn, &tls.Config{
+			InsecureSkipVerify: true, // the certificate is of no interest here
+		})
+		if err := tlsConn.Handshake(); err != nil {
+			return nil, err
+		}
+		conn = tlsConn
+	}
+
+	request := fmt.Sprintf("GET / HTTP/1.1\r\nHost: %s\r\nUser-Agent: Mozilla/5.0\r\nAccept: */*\r\nConnection: close\r\n\r\n", addr)
+	if _, err := conn.Write([]byte(request)); err != nil {
+		return nil, err
+	}
+	return readHTTPFingerprint(bufio.NewReader(conn))
+}
+
+// readHTTPFingerprint parses the status line and headers of an HTTP response.
+func readHTTPFingerprint(r *bufio.Reader) (*HTTPFingerprint, error) {
+	tp := textproto.NewReader(r)
+	status, err := tp.ReadLine()
+	if err != nil {
+		return nil, err
+	}
+	proto, code, ok := strings.Cut(status, " ")
+	if !ok || !strings.HasPrefix(proto, "HTTP/") {
+		return nil, fmt.Errorf("not an HTTP response: %q", status)
+	}
+	code, _, _ = strings.Cut(code, " ")
+
+	fp := &HTTPFingerprint{}
+	if fp.StatusCode, err = strconv.Atoi(code); err != nil {
+		return nil, fmt.Errorf("invalid HTTP status line: %q", status)
+	}
+
+	for {
+		line, err := tp.ReadLine()
+		if err != nil {
+			return nil, err
+		}
+		if line == "" {
+			break
+		}
+		name, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		name = textproto.CanonicalMIMEHeaderKey(strings.TrimSpace(name))
+		value = strings.TrimSpace(value)
+		fp.HeaderOrder = append(fp.HeaderOrder, name)
+
+		switch name {
+		case "Server":
+			fp.ServerHeader = value
+		case "X-Powered-By":
+			fp.PoweredBy = value
+		case "Set-Cookie":
+			if fp.SetCookie == "" {
+				fp.SetCookie = value
+			}
+		case "Content-Type":
+			fp.ContentType = value
+		case "X-Frame-Options":
+			fp.XFrameOptions = value
+		case "Content-Security-Policy":
+			fp.ContentSecurityPolicy = value
+		case "Via":
+			fp.ViaHeader = value
+		}
+	}
+
+	sum := sha256.Sum256([]byte(strings.Join(fp.HeaderOrder, ",")))
+	fp.HeaderOrderHash = hex.EncodeToString(sum[:])
+	return fp, nil
+}
+
+// fingerprintHTTP fingerprints the web servers among the open ports of
+// result, over TLS for ports whose service name says so.
+func (s *scanner) fingerprintHTTP(result *ScanResult) {
+	for i := range result.Ports {
+		p := &result.Ports[i]
+		if p.State != "open" {
+			continue
+		}
+		useTLS := strings.Contains(p.Service, "https") || p.Port == 443 || p.Port == 8443
+		fp, err := s.HTTPFingerprint(p.Port, useTLS)
+		if err != nil {
+			continue
+		}
+		p.HTTPFingerprint = fp
+	}
+}
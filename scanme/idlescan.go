@@ -0,0 +1,355 @@
+package scanme
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"net"
+	"time"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"github.com/google/gopacket/pcap"
+)
+
+const (
+	// idleProbeTimeout is how long to wait for the zombie's RST to a probe.
+	idleProbeTimeout = time.Second
+	// idleSettle is how long to wait after spoofing a SYN for the target
+	// to answer the zombie and the zombie to react to that answer.
+	idleSettle = 200 * time.Millisecond
+	// idleRetries is how many times a port is retried when the zombie IP ID
+	// moved by more than expected, i.e. the zombie was not idle.
+	idleRetries = 3
+)
+
+// ErrZombieNoReply is returned when the zombie does not answer IP ID probes.
+var ErrZombieNoReply = errors.New("zombie host did not answer the IP ID probe")
+
+// IdleScan performs an idle (zombie) scan of the target, like nmap -sI,
+// without sending a single packet from the scanner's address to the target.
+//
+// For each port it:
+//  1. probes the IP ID of the zombie with an unsolicited SYN/ACK to
+//     zombiePort, which the zombie answers with a RST;
+//  2. sends the target a SYN spoofed from zombieIP:zombiePort. An open port
+//     answers the zombie with a SYN/ACK, making it send a RST and increment
+//     its IP ID; a closed port answers with a RST, which the zombie ignores;
+//  3. probes the zombie IP ID again. An increment of 2 means the port is
+//     open, an increment of 1 that it is closed or filtered.
+//
+// The zombie must be idle and use a global, incremental IP ID sequence,
+// otherwise the results are meaningless; CheckZombieSuitability verifies it. The scan is slow, as each port
+// needs three round trips, and by default covers ports [1, 65535].
+// Only open ports are part of the returned ScanResult.
+//
+// Legal and ethical use: an idle scan sends forged packets that make an
+// uninvolved third party appear as the source of a port scan. Only run it
+// against targets and zombie hosts you own or are explicitly authorized to
+// test. Port scanning and IP spoofing may be illegal in your jurisdiction
+// or breach the terms of service of your network provider, and the zombie's
+// owner may be held responsible for traffic they never generated.
+func (s *scanner) IdleScan(ctx context.Context, zombieIP net.IP, zombiePort layers.TCPPort) (*ScanResult, error) {
+	if zombieIP.To4() == nil {
+		return nil, fmt.Errorf("zombie %v is not an IPv4 address", zombieIP)
+	}
+	zombieIP = zombieIP.To4()
+	start := time.Now()
+
+	zombieMAC, err := s.nextHopMAC(zombieIP)
+	if err != nil {
+		return nil, err
+	}
+	targetMAC, err := s.sendARPRequest(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	handle, err := openLive(s.iface.Name)
+	if err != nil {
+		return nil, err
+	}
+	defer handle.Close()
+
+	bpfFilter := fmt.Sprintf("tcp and src host %s and dst host %s and tcp[13] & 0x04 != 0", zombieIP, s.src)
+	if err := handle.SetBPFFilter(s.bpfFilter(bpfFilter)); err != nil {
+		return nil, err
+	}
+
+	probePort, err := getFreeTCPPort()
+	if err != nil {
+		return nil, err
+	}
+
+	z := &zombie{
+		s:         s,
+		handle:    handle,
+		ip:        zombieIP,
+		mac:       zombieMAC,
+		port:      zombiePort,
+		probePort: probePort,
+		targetMAC: targetMAC,
+	}
+
+	openPorts := make(map[layers.TCPPort]string)
+	for _, port := range defaultPorts() {
+		select {
+		case <-ctx.Done():
+			return newScanResult(s.dst, start, openPorts), ctx.Err()
+		default:
+		}
+
+		for attempt := 0; attempt < idleRetries; attempt++ {
+			before, err := z.ipid()
+			if err != nil {
+				return nil, err
+			}
+			if err := z.spoofSYN(port); err != nil {
+				s.logger.Error("error sending spoofed SYN", "port", port, "err", err)
+			}
+			time.Sleep(idleSettle)
+			after, err := z.ipid()
+			if err != nil {
+				return nil, err
+			}
+
+			delta := after - before
+			if delta <= 2 {
+				if delta == 2 {
+					openPorts[port] = "open"
+				}
+				break
+			}
+			s.logger.Warn("zombie IP ID moved more than expected, it is not idle", "zombie", zombieIP, "delta", delta, "port", port)
+		}
+	}
+
+	result := newScanResult(s.dst, start, openPorts)
+	result.Stats.PacketsSent = z.packetsOut
+	return result, nil
+}
+
+// zombie holds the state needed to probe a zombie host during an idle scan.
+type zombie struct {
+	s          *scanner
+	handle     *pcap.Handle
+	ip         net.IP
+	mac        net.HardwareAddr
+	port       layers.TCPPort
+	probePort  layers.TCPPort
+	targetMAC  net.HardwareAddr
+	packetsOut int
+}
+
+// ipid sends an unsolicited SYN/ACK to the zombie and returns the IP ID of
+// the RST it answers with.
+func (z *zombie) ipid() (uint16, error) {
+	eth := layers.Ethernet{
+		SrcMAC:       z.s.iface.HardwareAddr,
+		DstMAC:       z.mac,
+		EthernetType: layers.EthernetTypeIPv4,
+	}
+	ip4 := layers.IPv4{
+		SrcIP:    z.s.src,
+		DstIP:    z.ip,
+		Version:  4,
+		TTL:      z.s.ttl,
+		Protocol: layers.IPProtocolTCP,
+	}
+	tcp := layers.TCP{
+		SrcPort: z.probePort,
+		DstPort: z.port,
+		Seq:     rand.Uint32(),
+		Ack:     rand.Uint32(),
+		Window:  1024,
+		SYN:     true,
+		ACK:     true,
+	}
+	if err := tcp.SetNetworkLayerForChecksum(&ip4); err != nil {
+		return 0, err
+	}
+	if err := z.s.send(&eth, &ip4, &tcp); err != nil {
+		return 0, err
+	}
+	z.packetsOut++
+
+	var rEth layers.Ethernet
+	var rDot1Q layers.Dot1Q
+	var rIP4 layers.IPv4
+	var rTCP layers.TCP
+	parser := gopacket.NewDecodingLayerParser(layers.LayerTypeEthernet, &rEth, &rDot1Q, &rIP4, &rTCP)
+	parser.IgnoreUnsupported = true
+	decoded := []gopacket.LayerType{}
+
+	deadline := time.Now().Add(idleProbeTimeout)
+	for time.Now().Before(deadline) {
+		data, _, err := z.handle.ReadPacketData()
+		if err == pcap.NextErrorTimeoutExpired {
+			continue
+		} else if err != nil {
+			return 0, err
+		}
+		z.s.metrics.PacketReceived(z.s.dst.String())
+
+		//nolint:staticcheck // SA9003 ignore this!
+		if err := parser.DecodeLayers(data, &decoded); err != nil {
+			// Errors here are due to the decoder, and not all layers are implemented.
+		}
+		for _, typ := range decoded {
+			if typ == layers.LayerTypeTCP && rTCP.RST && rTCP.SrcPort == z.port && rTCP.DstPort == z.probePort {
+				return rIP4.Id, nil
+			}
+		}
+	}
+
+	return 0, ErrZombieNoReply
+}
+
+// spoofSYN sends the target a SYN on port forged to come from the zombie.
+func (z *zombie) spoofSYN(port layers.TCPPort) error {
+	eth := layers.Ethernet{
+		SrcMAC:       z.s.iface.HardwareAddr,
+		DstMAC:       z.targetMAC,
+		EthernetType: layers.EthernetTypeIPv4,
+	}
+	ip4 := layers.IPv4{
+		SrcIP:    z.ip,
+		DstIP:    z.s.dst,
+		Version:  4,
+		TTL:      z.s.ttl,
+		Protocol: layers.IPProtocolTCP,
+	}
+	tcp := layers.TCP{
+		SrcPort: z.port,
+		DstPort: port,
+		Seq:     z.s.nextSeq(),
+		Window:  1024,
+		SYN:     true,
+	}
+	if err := tcp.SetNetworkLayerForChecksum(&ip4); err != nil {
+		return err
+	}
+	if err := z.s.send(&eth, &ip4, &tcp); err != nil {
+		return err
+	}
+	z.packetsOut++
+	return nil
+}
+
+// ZombieAnalysis is the outcome of CheckZombieSuitability. IPIDIncrements
+// holds the difference between the IP IDs of consecutive echo replies and
+// StdDev their standard deviation.
+type ZombieAnalysis struct {
+	Predictable    bool
+	IPIDIncrements []int
+	StdDev         float64
+}
+
+// CheckZombieSuitability tells whether zombieIP can serve as the zombie of
+// an IdleScan. It sends samples ICMP echo requests to the host, one at a
+// time, and reads the IP ID of every echo reply. The host is Predictable,
+// and therefore suitable, when every reply increments the IP ID by exactly
+// one, i.e. the host uses a global, incremental IP ID and sends nothing else
+// in between. Hosts with random, per-destination or zero IP IDs are not.
+// Requests left unanswered are skipped; ErrZombieNoReply is returned when
+// fewer than two replies are received.
+func (s *scanner) CheckZombieSuitability(ctx context.Context, zombieIP net.IP, samples int) (*ZombieAnalysis, error) {
+	if zombieIP.To4() == nil {
+		return nil, fmt.Errorf("zombie %v is not an IPv4 address", zombieIP)
+	}
+	zombieIP = zombieIP.To4()
+
+	mac, err := s.nextHopMAC(zombieIP)
+	if err != nil {
+		return nil, err
+	}
+	handle, err := openLive(s.iface.Name)
+	if err != nil {
+		return nil, err
+	}
+	defer handle.Close()
+
+	bpfFilter := fmt.Sprintf("icmp and src host %s and icmp[0] == %d", zombieIP, layers.ICMPv4TypeEchoReply)
+	if err := handle.SetBPFFilter(s.bpfFilter(bpfFilter)); err != nil {
+		return nil, err
+	}
+
+	eth := layers.Ethernet{
+		SrcMAC:       s.iface.HardwareAddr,
+		DstMAC:       mac,
+		EthernetType: layers.EthernetTypeIPv4,
+	}
+	ip4 := layers.IPv4{
+		SrcIP:    s.src,
+		DstIP:    zombieIP,
+		Version:  4,
+		TTL:      s.ttl,
+		Protocol: layers.IPProtocolICMPv4,
+	}
+
+	var rEth layers.Ethernet
+	var rDot1Q layers.Dot1Q
+	var rIP4 layers.IPv4
+	var rICMP layers.ICMPv4
+	parser := gopacket.NewDecodingLayerParser(layers.LayerTypeEthernet, &rEth, &rDot1Q, &rIP4, &rICMP)
+	parser.IgnoreUnsupported = true
+	decoded := []gopacket.LayerType{}
+
+	id := uint16(s.rng.Intn(0xffff))
+	var ipids []uint16
+	for seq := 1; seq <= samples; seq++ {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		icmp := layers.ICMPv4{
+			TypeCode: layers.CreateICMPv4TypeCode(layers.ICMPv4TypeEchoRequest, 0),
+			Id:       id,
+			Seq:      uint16(seq),
+		}
+		if err := s.send(&eth, &ip4, &icmp); err != nil {
+			return nil, err
+		}
+
+		deadline := time.Now().Add(idleProbeTimeout)
+		for time.Now().Before(deadline) {
+			data, _, err := handle.ReadPacketData()
+			if err == pcap.NextErrorTimeoutExpired {
+				continue
+			} else if err != nil {
+				return nil, err
+			}
+
+			//nolint:staticcheck // SA9003 ignore this!
+			if err := parser.DecodeLayers(data, &decoded); err != nil {
+				// Errors here are due to the decoder, and not all layers are implemented.
+			}
+			if rICMP.TypeCode.Type() == layers.ICMPv4TypeEchoReply && rICMP.Id == id && rICMP.Seq == uint16(seq) {
+				ipids = append(ipids, rIP4.Id)
+				break
+			}
+		}
+	}
+	if len(ipids) < 2 {
+		return nil, ErrZombieNoReply
+	}
+
+	analysis := &ZombieAnalysis{Predictable: true}
+	var sum float64
+	for i := 1; i < len(ipids); i++ {
+		// IP IDs are 16-bit counters, the subtraction wraps around with them.
+		inc := int(ipids[i] - ipids[i-1])
+		analysis.IPIDIncrements = append(analysis.IPIDIncrements, inc)
+		analysis.Predictable = analysis.Predictable && inc == 1
+		sum += float64(inc)
+	}
+	mean := sum / float64(len(analysis.IPIDIncrements))
+	var variance float64
+	for _, inc := range analysis.IPIDIncrements {
+		variance += (float64(inc) - mean) * (float64(inc) - mean)
+	}
+	analysis.StdDev = math.Sqrt(variance / float64(len(analysis.IPIDIncrements)))
+	return analysis, nil
+}
@@ -0,0 +1,98 @@
+package scanme
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/gopacket/layers"
+)
+
+// IncrementalScan rescans a target previously scanned into prev, typically
+// loaded from the scan history store. It first probes the ports that were
+// open or filtered in prev to confirm their state, then sweeps the remaining
+// ports in random order looking for newly opened ones.
+//
+// The returned result holds the ports from prev that are confirmed open,
+// the ports that were filtered in prev and still did not answer, and the
+// ports found open by the sweep. Use Diff to compare it with prev.
+// When ctx is done, the ports found so far are returned along with ctx.Err().
+func (s *scanner) IncrementalScan(ctx context.Context, prev *ScanResult) (*ScanResult, error) {
+	if prev == nil {
+		return nil, fmt.Errorf("incremental scan requires a previous result")
+	}
+	if prev.Target != nil && !prev.Target.Equal(s.dst) {
+		return nil, fmt.Errorf("previous result is for %v, not %v", prev.Target, s.dst)
+	}
+
+	var known []layers.TCPPort
+	for _, p := range prev.Ports {
+		if p.State == "open" || p.State == "filtered" {
+			known = append(known, p.Port)
+		}
+	}
+	known = uniquePorts(known)
+
+	result, err := s.synscan(ctx, known, nil)
+	if err != nil {
+		return result, err
+	}
+	// Synscan only reports open ports, so a filtered port that is still
+	// silent has not changed state.
+	var silent []PortResult
+	for _, p := range known {
+		if prevPort, _ := prev.Port(p); prevPort.State != "filtered" {
+			continue
+		}
+		if _, ok := result.Port(p); !ok {
+			silent = append(silent, PortResult{Port: p, State: "filtered", Service: serviceName(p)})
+		}
+	}
+	result.Ports = append(result.Ports, silent...)
+	result.sortPorts()
+
+	skip := make(map[layers.TCPPort]bool, len(known))
+	for _, p := range known {
+		skip[p] = true
+	}
+	var rest []layers.TCPPort
+	for _, p := range s.scanPorts() {
+		if !skip[p] {
+			rest = append(rest, p)
+		}
+	}
+	s.shufflePorts(rest)
+
+	sweep, err := s.synscan(ctx, rest, nil)
+	if sweep == nil {
+		return result, err
+	}
+	result.merge(sweep)
+	return result, err
+}
+
+// merge adds the ports of other to r and accumulates its stats, extending
+// r to cover both scans.
+func (r *ScanResult) merge(other *ScanResult) {
+	var added []PortResult
+	for _, p := range other.Ports {
+		if _, ok := r.Port(p.Port); !ok {
+			added = append(added, p)
+		}
+	}
+	r.Ports = append(r.Ports, added...)
+	r.sortPorts()
+	r.EndTime = other.EndTime
+	r.Stats.PacketsSent += other.Stats.PacketsSent
+	r.Stats.PacketsReceived += other.Stats.PacketsReceived
+	r.Stats.RateLimitEvents += other.Stats.RateLimitEvents
+	r.Stats.DNSTimeouts += other.Stats.DNSTimeouts
+	r.Stats.DuplicatesFiltered += other.Stats.DuplicatesFiltered
+	if r.Hostname == "" {
+		r.Hostname, r.Hostnames = other.Hostname, other.Hostnames
+	}
+	if r.ASNInfo == nil {
+		r.ASNInfo = other.ASNInfo
+	}
+	r.Stats.Duration = r.EndTime.Sub(r.StartTime)
+	r.updateLatencyStats()
+}
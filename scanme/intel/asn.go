@@ -0,0 +1,123 @@
+package intel
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// cymruWhois is the address of the Team Cymru IP to ASN whois service.
+const cymruWhois = "whois.cymru.com:43"
+
+// asnTimeout bounds a query to the whois service.
+const asnTimeout = 10 * time.Second
+
+// ErrNoASN is returned when the address is not announced by any AS.
+var ErrNoASN = errors.New("intel: address not announced")
+
+// ASNInfo describes the autonomous system announcing an address.
+type ASNInfo struct {
+	ASN       uint32
+	ASName    string
+	BGPPrefix string
+	Country   string
+	Registry  string
+	Allocated time.Time
+}
+
+var (
+	asnCacheMu sync.Mutex
+	asnCache   = make(map[string]*ASNInfo)
+)
+
+// LookupASN returns the autonomous system announcing ip, as reported by the
+// Team Cymru IP to ASN service. Results are cached for the lifetime of the
+// process.
+func LookupASN(ip net.IP) (*ASNInfo, error) {
+	key := ip.String()
+	asnCacheMu.Lock()
+	info, ok := asnCache[key]
+	asnCacheMu.Unlock()
+	if ok {
+		return info, nil
+	}
+
+	info, err := queryCymru(ip)
+	if err != nil {
+		return nil, err
+	}
+
+	asnCacheMu.Lock()
+	asnCache[key] = info
+	asnCacheMu.Unlock()
+	return info, nil
+}
+
+// queryCymru sends a bulk mode query for ip to the whois service.
+func queryCymru(ip net.IP) (*ASNInfo, error) {
+	conn, err := net.DialTimeout("tcp", cymruWhois, asnTimeout)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	if err := conn.SetDeadline(time.Now().Add(asnTimeout)); err != nil {
+		return nil, err
+	}
+
+	if _, err := fmt.Fprintf(conn, "begin\nverbose\n%s\nend\n", ip); err != nil {
+		return nil, err
+	}
+
+	scanner := bufio.NewScanner(conn)
+	for scanner.Scan() {
+		line := scanner.Text()
+		// Skip the "Bulk mode; ..." banner and the column headers.
+		if strings.HasPrefix(line, "Bulk mode") || strings.HasPrefix(line, "AS ") {
+			continue
+		}
+		return parseCymruLine(line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return nil, fmt.Errorf("intel: empty response from %s", cymruWhois)
+}
+
+// parseCymruLine parses a verbose bulk mode response line:
+//
+//	15169   | 8.8.8.8          | 8.8.8.0/24          | US | arin     | 1992-12-01 | GOOGLE, US
+func parseCymruLine(line string) (*ASNInfo, error) {
+	fields := strings.Split(line, "|")
+	if len(fields) != 7 {
+		return nil, fmt.Errorf("intel: unexpected whois response %q", line)
+	}
+	for i := range fields {
+		fields[i] = strings.TrimSpace(fields[i])
+	}
+	if fields[0] == "NA" {
+		return nil, ErrNoASN
+	}
+
+	asn, err := strconv.ParseUint(fields[0], 10, 32)
+	if err != nil {
+		return nil, fmt.Errorf("intel: invalid AS number %q", fields[0])
+	}
+	info := &ASNInfo{
+		ASN:       uint32(asn),
+		BGPPrefix: fields[2],
+		Country:   fields[3],
+		Registry:  fields[4],
+		ASName:    fields[6],
+	}
+	if fields[5] != "" {
+		if info.Allocated, err = time.Parse("2006-01-02", fields[5]); err != nil {
+			return nil, fmt.Errorf("intel: invalid allocation date %q", fields[5])
+		}
+	}
+	return info, nil
+}
@@ -0,0 +1,237 @@
+package intel
+
+import (
+	"context"
+	"crypto/tls"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// cdnTimeout bounds the HTTP request of DetectCDN.
+const cdnTimeout = 5 * time.Second
+
+// The weights of the evidence DetectCDN adds up, per provider, into the
+// confidence of its answer, capped at 1.
+const (
+	cdnRangeWeight  = 0.6
+	cdnRDNSWeight   = 0.3
+	cdnHeaderWeight = 0.4
+)
+
+// unknownCDN names the provider of caching proxies whose headers do not
+// tell who runs them.
+const unknownCDN = "unknown"
+
+// cdnRangesJSON maps CDN providers to the address ranges they publish.
+// Update it from the lists published by the providers, or from compilations
+// such as https://github.com/client9/ipcat.
+//
+//go:embed cdn_ranges.json
+var cdnRangesJSON []byte
+
+// cdnRange is an entry of cdnRangesJSON.
+type cdnRange struct {
+	Provider string   `json:"provider"`
+	CIDRs    []string `json:"cidrs"`
+}
+
+// cdnNet is a parsed range of a provider.
+type cdnNet struct {
+	provider string
+	net      *net.IPNet
+}
+
+var (
+	cdnNetsOnce sync.Once
+	cdnNets     []cdnNet
+	cdnNetsErr  error
+)
+
+// cdnSuffixes map the suffixes of the PTR records of CDN edge servers to
+// their provider.
+var cdnSuffixes = map[string]string{
+	".cloudflare.com":           "Cloudflare",
+	".fastly.net":               "Fastly",
+	".akamaitechnologies.com":   "Akamai",
+	".akamaiedge.net":           "Akamai",
+	".akamaized.net":            "Akamai",
+	".akamai.net":               "Akamai",
+	".cloudfront.net":           "Amazon CloudFront",
+	".incapdns.net":             "Imperva Incapsula",
+	".edgecastcdn.net":          "Edgio",
+	".llnw.net":                 "Edgio",
+	".cdn77.com":                "CDN77",
+	".bc.googleusercontent.com": "Google Cloud CDN",
+}
+
+// cdnHeaders map response headers set by CDN edge servers to their provider.
+var cdnHeaders = map[string]string{
+	"CF-RAY":               "Cloudflare",
+	"X-Fastly-Request-ID":  "Fastly",
+	"X-Amz-Cf-Id":          "Amazon CloudFront",
+	"X-Akamai-Transformed": "Akamai",
+	"X-Iinfo":              "Imperva Incapsula",
+	"X-Sucuri-ID":          "Sucuri",
+}
+
+// cdnServers map the Server headers of CDN edge servers, lowercased, to
+// their provider.
+var cdnServers = map[string]string{
+	"cloudflare":        "Cloudflare",
+	"akamaighost":       "Akamai",
+	"cloudfront":        "Amazon CloudFront",
+	"sucuri/cloudproxy": "Sucuri",
+}
+
+// CDNInfo is the outcome of DetectCDN. Provider is empty when no sign of a
+// CDN was found. Confidence is in [0, 1] and Evidence lists what points at
+// the provider, or at any CDN.
+type CDNInfo struct {
+	Provider   string
+	Confidence float64
+	Evidence   []string
+}
+
+// DetectCDN tells whether target is the edge server of a content delivery
+// network, in which case scanning it reveals the CDN rather than the origin
+// server. It checks target against the address ranges published by CDN
+// providers, the PTR records of target against the domains of their edge
+// servers, and the headers of an HTTP response of target against those CDNs
+// set, weighting these 0.6, 0.3 and 0.4. Failed lookups only count as
+// missing evidence: an error is returned when ctx is done first.
+func DetectCDN(ctx context.Context, target net.IP) (*CDNInfo, error) {
+	scores := make(map[string]float64)
+	var evidence []string
+	add := func(provider string, weight float64, format string, args ...interface{}) {
+		scores[provider] += weight
+		evidence = append(evidence, fmt.Sprintf(format, args...))
+	}
+
+	nets, err := loadCDNRanges()
+	if err != nil {
+		return nil, err
+	}
+	for _, n := range nets {
+		if n.net.Contains(target) {
+			add(n.provider, cdnRangeWeight, "%v is in %s range %v", target, n.provider, n.net)
+			break
+		}
+	}
+
+	names, _ := net.DefaultResolver.LookupAddr(ctx, target.String())
+	for _, name := range names {
+		name = strings.ToLower(strings.TrimSuffix(name, "."))
+		for suffix, provider := range cdnSuffixes {
+			if strings.HasSuffix(name, suffix) {
+				add(provider, cdnRDNSWeight, "PTR record %s", name)
+			}
+		}
+	}
+
+	if header, err := cdnResponseHeader(ctx, target); err == nil {
+		for name, provider := range cdnHeaders {
+			if v := header.Get(name); v != "" {
+				add(provider, cdnHeaderWeight, "%s header %q", name, v)
+			}
+		}
+		if provider, ok := cdnServers[strings.ToLower(header.Get("Server"))]; ok {
+			add(provider, cdnHeaderWeight, "Server header %q", header.Get("Server"))
+		}
+		// Caching proxies announce themselves without always saying who
+		// runs them.
+		for _, name := range []string{"Via", "X-Cache"} {
+			if v := header.Get(name); v != "" {
+				add(unknownCDN, cdnHeaderWeight/2, "%s header %q", name, v)
+			}
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	info := &CDNInfo{Evidence: evidence}
+	for provider, score := range scores {
+		if provider == unknownCDN {
+			continue
+		}
+		if score > info.Confidence || (score == info.Confidence && provider < info.Provider) {
+			info.Provider, info.Confidence = provider, score
+		}
+	}
+	if info.Provider == "" && scores[unknownCDN] > 0 {
+		info.Provider, info.Confidence = unknownCDN, scores[unknownCDN]
+	}
+	if info.Confidence > 1 {
+		info.Confidence = 1
+	}
+	sort.Strings(info.Evidence)
+	return info, nil
+}
+
+// loadCDNRanges parses cdnRangesJSON on first use.
+func loadCDNRanges() ([]cdnNet, error) {
+	cdnNetsOnce.Do(func() {
+		var ranges []cdnRange
+		if err := json.Unmarshal(cdnRangesJSON, &ranges); err != nil {
+			cdnNetsErr = fmt.Errorf("intel: invalid CDN ranges: %v", err)
+			return
+		}
+		for _, r := range ranges {
+			for _, cidr := range r.CIDRs {
+				_, n, err := net.ParseCIDR(cidr)
+				if err != nil {
+					cdnNetsErr = fmt.Errorf("intel: invalid CDN range %q of %s", cidr, r.Provider)
+					return
+				}
+				cdnNets = append(cdnNets, cdnNet{provider: r.Provider, net: n})
+			}
+		}
+	})
+	return cdnNets, cdnNetsErr
+}
+
+// cdnResponseHeader returns the headers of the response of target to a GET
+// request over HTTP, or HTTPS when HTTP fails. Redirects are not followed:
+// the headers of the edge server are what matters.
+func cdnResponseHeader(ctx context.Context, target net.IP) (http.Header, error) {
+	client := &http.Client{
+		Timeout: cdnTimeout,
+		Transport: &http.Transport{
+			// Edge servers present the certificates of the sites they
+			// front, never one for their address.
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+		},
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	defer client.CloseIdleConnections()
+
+	host := target.String()
+	if target.To4() == nil {
+		host = "[" + host + "]"
+	}
+	var err error
+	for _, scheme := range []string{"http", "https"} {
+		var req *http.Request
+		req, err = http.NewRequestWithContext(ctx, http.MethodGet, scheme+"://"+host+"/", nil)
+		if err != nil {
+			return nil, err
+		}
+		var resp *http.Response
+		resp, err = client.Do(req)
+		if err != nil {
+			continue
+		}
+		resp.Body.Close()
+		return resp.Header, nil
+	}
+	return nil, err
+}
@@ -0,0 +1,95 @@
+package intel
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// crtshURL is the endpoint of the crt.sh certificate transparency search.
+const crtshURL = "https://crt.sh/"
+
+// ctTimeout bounds a query to crt.sh, which can be slow for large domains.
+const ctTimeout = 30 * time.Second
+
+// ctMaxResponse bounds the size of the crt.sh response read.
+const ctMaxResponse = 64 << 20
+
+// CTOption configures CTLookup.
+type CTOption func(*ctConfig)
+
+type ctConfig struct {
+	wildcards bool
+}
+
+// WithWildcards makes CTLookup keep the wildcard names, such as
+// *.example.com, which are left out by default as they cannot be resolved.
+func WithWildcards() CTOption {
+	return func(c *ctConfig) {
+		c.wildcards = true
+	}
+}
+
+// crtshEntry is an entry of the JSON output of crt.sh. NameValue holds the
+// names of the certificate, one per line.
+type crtshEntry struct {
+	NameValue string `json:"name_value"`
+}
+
+// CTLookup returns the names under domain found in the certificates logged
+// to certificate transparency logs, as searched by crt.sh, revealing
+// subdomains without sending a packet to them. Names are lowercased,
+// deduplicated and sorted.
+func CTLookup(domain string, opts ...CTOption) ([]string, error) {
+	var c ctConfig
+	for _, opt := range opts {
+		opt(&c)
+	}
+	domain = strings.ToLower(strings.TrimSuffix(domain, "."))
+
+	query := url.Values{"q": {"%." + domain}, "output": {"json"}}
+	client := &http.Client{Timeout: ctTimeout}
+	resp, err := client.Get(crtshURL + "?" + query.Encode())
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("intel: crt.sh: unexpected status %s", resp.Status)
+	}
+	var entries []crtshEntry
+	if err := json.NewDecoder(io.LimitReader(resp.Body, ctMaxResponse)).Decode(&entries); err != nil {
+		return nil, fmt.Errorf("intel: crt.sh: %v", err)
+	}
+	return ctNames(entries, domain, c.wildcards), nil
+}
+
+// ctNames returns the unique names under domain listed by entries, sorted.
+func ctNames(entries []crtshEntry, domain string, wildcards bool) []string {
+	seen := make(map[string]bool)
+	var names []string
+	for _, e := range entries {
+		for _, name := range strings.Split(e.NameValue, "\n") {
+			name = strings.ToLower(strings.TrimSpace(name))
+			if name == "" || seen[name] {
+				continue
+			}
+			if !wildcards && strings.HasPrefix(name, "*.") {
+				continue
+			}
+			// Certificates may also cover names outside of domain.
+			if name != domain && !strings.HasSuffix(name, "."+domain) {
+				continue
+			}
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
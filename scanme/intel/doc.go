@@ -0,0 +1,4 @@
+// Package intel enriches scan targets with information from public
+// threat-intelligence and Internet registry services, such as the
+// autonomous system announcing an address.
+package intel
@@ -0,0 +1,181 @@
+package intel
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"time"
+
+	"github.com/google/gopacket/layers"
+)
+
+// shodanHostURL is the endpoint of the Shodan host information API.
+const shodanHostURL = "https://api.shodan.io/shodan/host/"
+
+// shodanTimeout bounds a query to the Shodan API when ctx has no deadline.
+const shodanTimeout = 15 * time.Second
+
+// shodanTimeLayout is the layout of the timestamps of the Shodan API, in UTC.
+const shodanTimeLayout = "2006-01-02T15:04:05.999999"
+
+// ErrNoShodanData is returned when Shodan has no information on an address.
+var ErrNoShodanData = errors.New("intel: no Shodan information for address")
+
+// ShodanInfo is what Shodan knows of a host. OpenPorts are the TCP ports
+// Shodan found open, sorted, and Vulns the CVEs it associates with the
+// services of the host.
+type ShodanInfo struct {
+	ISP        string
+	Country    string
+	Org        string
+	OpenPorts  []int
+	Vulns      []string
+	LastUpdate time.Time
+}
+
+// ShodanDiscrepancies compares the open ports of a scan with those Shodan
+// found on the same host. OnlyShodan holds the ports Shodan found open but
+// the scan did not, OnlyScan the ports the scan found open but Shodan did
+// not. Ports are listed in ascending order.
+type ShodanDiscrepancies struct {
+	OnlyShodan []layers.TCPPort
+	OnlyScan   []layers.TCPPort
+}
+
+// ScanResult is the part of a scan result ShodanEnrich reads and updates,
+// implemented by *scanme.ScanResult, whose package imports this one.
+type ScanResult interface {
+	// TargetIP returns the address scanned.
+	TargetIP() net.IP
+	// OpenPorts returns the ports found open, in ascending order.
+	OpenPorts() []layers.TCPPort
+	// SetShodanDiscrepancies records the comparison with Shodan.
+	SetShodanDiscrepancies(d *ShodanDiscrepancies)
+}
+
+// shodanHost is the part of the response of the host API ShodanHost reads.
+type shodanHost struct {
+	ISP         string   `json:"isp"`
+	CountryName string   `json:"country_name"`
+	Org         string   `json:"org"`
+	Ports       []int    `json:"ports"`
+	Vulns       []string `json:"vulns"`
+	LastUpdate  string   `json:"last_update"`
+	Data        []struct {
+		Port      int    `json:"port"`
+		Transport string `json:"transport"`
+	} `json:"data"`
+	Error string `json:"error"`
+}
+
+// ShodanHost returns what Shodan knows of ip, queried with apiKey. This
+// sends a request to a third party, and consumes query credits of apiKey.
+func ShodanHost(ctx context.Context, ip net.IP, apiKey string) (*ShodanInfo, error) {
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, shodanTimeout)
+		defer cancel()
+	}
+	query := url.Values{"key": {apiKey}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, shodanHostURL+ip.String()+"?"+query.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		// The URL in the error would disclose the API key.
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return nil, fmt.Errorf("intel: shodan: %v", err)
+	}
+	defer resp.Body.Close()
+
+	var host shodanHost
+	decodeErr := json.NewDecoder(resp.Body).Decode(&host)
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return nil, ErrNoShodanData
+	case resp.StatusCode != http.StatusOK && host.Error != "":
+		return nil, fmt.Errorf("intel: shodan: %s", host.Error)
+	case resp.StatusCode != http.StatusOK:
+		return nil, fmt.Errorf("intel: shodan: unexpected status %s", resp.Status)
+	case decodeErr != nil:
+		return nil, fmt.Errorf("intel: shodan: %v", decodeErr)
+	}
+	return host.info(), nil
+}
+
+// info converts the response of the host API.
+func (h *shodanHost) info() *ShodanInfo {
+	info := &ShodanInfo{
+		ISP:     h.ISP,
+		Country: h.CountryName,
+		Org:     h.Org,
+		Vulns:   h.Vulns,
+	}
+	info.LastUpdate, _ = time.Parse(shodanTimeLayout, h.LastUpdate)
+
+	// Ports lists UDP ports too, which only the banners in Data tell
+	// apart.
+	tcp := make(map[int]bool)
+	udp := make(map[int]bool)
+	for _, d := range h.Data {
+		if d.Transport == "udp" {
+			udp[d.Port] = true
+		} else {
+			tcp[d.Port] = true
+		}
+	}
+	for _, port := range h.Ports {
+		if tcp[port] || !udp[port] {
+			info.OpenPorts = append(info.OpenPorts, port)
+		}
+	}
+	sort.Ints(info.OpenPorts)
+	sort.Strings(info.Vulns)
+	return info
+}
+
+// ShodanEnrich looks up the target of result on Shodan with apiKey and
+// records in result how its open ports differ from those Shodan found.
+// Shodan crawls hosts over weeks, so services may have come and gone since,
+// and ports result did not probe show up in OnlyShodan. This sends a
+// request to a third party.
+func ShodanEnrich(ctx context.Context, result ScanResult, apiKey string) (*ShodanInfo, error) {
+	info, err := ShodanHost(ctx, result.TargetIP(), apiKey)
+	if err != nil {
+		return nil, err
+	}
+	result.SetShodanDiscrepancies(shodanDiscrepancies(result.OpenPorts(), info.OpenPorts))
+	return info, nil
+}
+
+// shodanDiscrepancies compares the sorted open ports of a scan with the
+// sorted ports Shodan found open.
+func shodanDiscrepancies(open []layers.TCPPort, shodanPorts []int) *ShodanDiscrepancies {
+	d := &ShodanDiscrepancies{}
+	scanned := make(map[layers.TCPPort]bool, len(open))
+	for _, port := range open {
+		scanned[port] = true
+	}
+	shodan := make(map[layers.TCPPort]bool, len(shodanPorts))
+	for _, port := range shodanPorts {
+		shodan[layers.TCPPort(port)] = true
+		if !scanned[layers.TCPPort(port)] {
+			d.OnlyShodan = append(d.OnlyShodan, layers.TCPPort(port))
+		}
+	}
+	for _, port := range open {
+		if !shodan[port] {
+			d.OnlyScan = append(d.OnlyScan, port)
+		}
+	}
+	return d
+}
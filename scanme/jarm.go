@@ -0,0 +1,415 @@
+package scanme
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/gopacket/layers"
+)
+
+// jarmTimeout bounds each of the ten JARM probes.
+const jarmTimeout = 5 * time.Second
+
+// KnownJARM maps JARM fingerprints to the server or malware family they are
+// known to identify. Fingerprints of web servers depend on their TLS library
+// and configuration, so add the ones seen in your environment to the table.
+var KnownJARM = map[string]string{
+	"07d14d16d21d21d07c42d41d00041d24a458a375eef0c576d23a7bab9a9fb1": "Cobalt Strike C2",
+	"07d14d16d21d21d00042d43d000000aa99ce74e2c6d013c745aa52b5cc042d": "Metasploit",
+	"29d21b20d29d29d21c41d21b21b41d494e0df9532e75299f15ba73156cee38": "Merlin C2",
+	"2ad2ad0002ad2ad00042d42d000000ad9bf51cc3f5a1e29eecb81d0c7b06eb": "Mythic C2",
+	"22b22b09b22b22b22b22b22b22b22b352842cd5d6b0278445702035e06875c": "TrickBot",
+	"1dd40d40d00040d1dc1dd40d1dd40d3df2d6a0c2caaa0dc59908f0d3602943": "AsyncRAT",
+}
+
+// jarmProbe describes one of the ten ClientHellos sent by JARM.
+type jarmProbe struct {
+	version      string // "TLS_1.1", "TLS_1.2" or "TLS_1.3"
+	ciphers      string // "ALL" or "NO1.3"
+	cipherOrder  string // "FORWARD", "REVERSE", "TOP_HALF", "BOTTOM_HALF" or "MIDDLE_OUT"
+	grease       bool
+	rareALPN     bool
+	support      string // "1.2_SUPPORT", "1.3_SUPPORT" or "NO_SUPPORT"
+	extensionOrd string // order of the ALPN and supported versions lists
+}
+
+// jarmProbes are the probes of the JARM specification, in order.
+var jarmProbes = []jarmProbe{
+	{"TLS_1.2", "ALL", "FORWARD", false, false, "1.2_SUPPORT", "REVERSE"},
+	{"TLS_1.2", "ALL", "REVERSE", false, false, "1.2_SUPPORT", "FORWARD"},
+	{"TLS_1.2", "ALL", "TOP_HALF", false, false, "NO_SUPPORT", "FORWARD"},
+	{"TLS_1.2", "ALL", "BOTTOM_HALF", false, true, "NO_SUPPORT", "FORWARD"},
+	{"TLS_1.2", "ALL", "MIDDLE_OUT", true, true, "NO_SUPPORT", "REVERSE"},
+	{"TLS_1.1", "ALL", "FORWARD", false, false, "NO_SUPPORT", "FORWARD"},
+	{"TLS_1.3", "ALL", "FORWARD", false, false, "1.3_SUPPORT", "REVERSE"},
+	{"TLS_1.3", "ALL", "REVERSE", false, false, "1.3_SUPPORT", "FORWARD"},
+	{"TLS_1.3", "NO1.3", "FORWARD", false, false, "1.3_SUPPORT", "FORWARD"},
+	{"TLS_1.3", "ALL", "MIDDLE_OUT", true, false, "1.3_SUPPORT", "REVERSE"},
+}
+
+// jarmCiphers are the cipher suites offered by the "ALL" probes.
+var jarmCiphers = []uint16{
+	0x0016, 0x0033, 0x0067, 0xc09e, 0xc0a2, 0x009e, 0x0039, 0x006b, 0xc09f, 0xc0a3,
+	0x009f, 0x0045, 0x00be, 0x0088, 0x00c4, 0x009a, 0xc008, 0xc009, 0xc023, 0xc0ac,
+	0xc0ae, 0xc02b, 0xc00a, 0xc024, 0xc0ad, 0xc0af, 0xc02c, 0xc072, 0xc073, 0xcca9,
+	0x1302, 0x1301, 0xcc14, 0xc007, 0xc012, 0xc013, 0xc027, 0xc02f, 0xc014, 0xc028,
+	0xc030, 0xc060, 0xc061, 0xc076, 0xc077, 0xcca8, 0x1305, 0x1304, 0x1303, 0xcc13,
+	0xc011, 0x000a, 0x002f, 0x003c, 0xc09c, 0xc0a0, 0x009c, 0x0035, 0x003d, 0xc09d,
+	0xc0a1, 0x009d, 0x0041, 0x00ba, 0x0084, 0x00c0, 0x0007, 0x0004, 0x0005,
+}
+
+// jarmCipherIndex lists the cipher suites in the order used to encode the
+// selected cipher in the fingerprint.
+var jarmCipherIndex = []uint16{
+	0x0004, 0x0005, 0x0007, 0x000a, 0x0016, 0x002f, 0x0033, 0x0035, 0x0039, 0x003c,
+	0x003d, 0x0041, 0x0045, 0x0067, 0x006b, 0x0084, 0x0088, 0x009a, 0x009c, 0x009d,
+	0x009e, 0x009f, 0x00ba, 0x00be, 0x00c0, 0x00c4, 0xc007, 0xc008, 0xc009, 0xc00a,
+	0xc011, 0xc012, 0xc013, 0xc014, 0xc023, 0xc024, 0xc027, 0xc028, 0xc02b, 0xc02c,
+	0xc02f, 0xc030, 0xc060, 0xc061, 0xc072, 0xc073, 0xc076, 0xc077, 0xc09c, 0xc09d,
+	0xc09e, 0xc09f, 0xc0a0, 0xc0a1, 0xc0a2, 0xc0a3, 0xc0ac, 0xc0ad, 0xc0ae, 0xc0af,
+	0xcc13, 0xcc14, 0xcca8, 0xcca9, 0x1301, 0x1302, 0x1303, 0x1304, 0x1305,
+}
+
+// JARMFingerprint computes the JARM fingerprint of the TLS server listening
+// on port of the target: ten crafted ClientHellos are sent and the replies
+// hashed into a 62 character fingerprint identifying the TLS implementation
+// and configuration of the server. Look it up in KnownJARM to name it.
+// A server never completing a handshake yields a fingerprint of zeros.
+func (s *scanner) JARMFingerprint(port layers.TCPPort) (string, error) {
+	addr := net.JoinHostPort(s.dst.String(), strconv.Itoa(int(port)))
+
+	answers := make([]string, 0, len(jarmProbes))
+	var dialErr error
+	for _, probe := range jarmProbes {
+		answer, err := jarmSend(addr, s.dst.String(), probe)
+		if err != nil {
+			dialErr = err
+		}
+		answers = append(answers, answer)
+	}
+	if dialErr != nil && strings.Count(strings.Join(answers, ","), "|||") == len(jarmProbes) {
+		return "", fmt.Errorf("JARM probes to %s failed: %v", addr, dialErr)
+	}
+	return jarmHash(answers), nil
+}
+
+// jarmSend sends probe to addr and returns the summary of the ServerHello,
+// "|||" when the server did not answer with one.
+func jarmSend(addr, host string, probe jarmProbe) (string, error) {
+	conn, err := net.DialTimeout("tcp", addr, jarmTimeout)
+	if err != nil {
+		return "|||", err
+	}
+	defer conn.Close()
+	if err := conn.SetDeadline(time.Now().Add(jarmTimeout)); err != nil {
+		return "|||", err
+	}
+
+	if _, err := conn.Write(jarmClientHello(host, probe)); err != nil {
+		return "|||", nil
+	}
+	buf := make([]byte, 1484)
+	n, err := conn.Read(buf)
+	if err != nil {
+		return "|||", nil
+	}
+	return jarmParseServerHello(buf[:n]), nil
+}
+
+// jarmClientHello builds the TLS record holding the ClientHello of probe.
+func jarmClientHello(host string, probe jarmProbe) []byte {
+	recordVersion, helloVersion := []byte{0x03, 0x03}, []byte{0x03, 0x03}
+	switch probe.version {
+	case "TLS_1.3":
+		recordVersion = []byte{0x03, 0x01}
+	case "TLS_1.1":
+		recordVersion, helloVersion = []byte{0x03, 0x02}, []byte{0x03, 0x02}
+	}
+
+	hello := append([]byte(nil), helloVersion...)
+	random := make([]byte, 64)
+	rand.Read(random) //nolint:errcheck // crypto/rand never fails on supported platforms
+	hello = append(hello, random[:32]...)
+	// A 32 byte session ID.
+	hello = append(hello, 32)
+	hello = append(hello, random[32:]...)
+
+	ciphers := jarmCipherList(probe)
+	hello = binary.BigEndian.AppendUint16(hello, uint16(2*len(ciphers)))
+	for _, c := range ciphers {
+		hello = binary.BigEndian.AppendUint16(hello, c)
+	}
+	hello = append(hello, 0x01, 0x00) // null compression
+	hello = append(hello, jarmExtensions(host, probe)...)
+
+	handshake := []byte{0x01, 0x00}
+	handshake = binary.BigEndian.AppendUint16(handshake, uint16(len(hello)))
+	handshake = append(handshake, hello...)
+
+	record := append([]byte{0x16}, recordVersion...)
+	record = binary.BigEndian.AppendUint16(record, uint16(len(handshake)))
+	return append(record, handshake...)
+}
+
+// jarmCipherList returns the cipher suites offered by probe.
+func jarmCipherList(probe jarmProbe) []uint16 {
+	var ciphers []uint16
+	for _, c := range jarmCiphers {
+		if probe.ciphers == "NO1.3" && c>>8 == 0x13 {
+			continue
+		}
+		ciphers = append(ciphers, c)
+	}
+	ciphers = jarmMung(ciphers, probe.cipherOrder)
+	if probe.grease {
+		ciphers = append([]uint16{jarmGrease()}, ciphers...)
+	}
+	return ciphers
+}
+
+// jarmExtensions returns the length prefixed extensions of probe.
+func jarmExtensions(host string, probe jarmProbe) []byte {
+	var exts []byte
+	if probe.grease {
+		exts = binary.BigEndian.AppendUint16(exts, jarmGrease())
+		exts = append(exts, 0x00, 0x00)
+	}
+
+	// server_name
+	exts = append(exts, 0x00, 0x00)
+	exts = binary.BigEndian.AppendUint16(exts, uint16(len(host)+5))
+	exts = binary.BigEndian.AppendUint16(exts, uint16(len(host)+3))
+	exts = append(exts, 0x00)
+	exts = binary.BigEndian.AppendUint16(exts, uint16(len(host)))
+	exts = append(exts, host...)
+
+	exts = append(exts, 0x00, 0x17, 0x00, 0x00)                                                             // extended_master_secret
+	exts = append(exts, 0x00, 0x01, 0x00, 0x01, 0x01)                                                       // max_fragment_length
+	exts = append(exts, 0xff, 0x01, 0x00, 0x01, 0x00)                                                       // renegotiation_info
+	exts = append(exts, 0x00, 0x0a, 0x00, 0x0a, 0x00, 0x08, 0x00, 0x1d, 0x00, 0x17, 0x00, 0x18, 0x00, 0x19) // supported_groups
+	exts = append(exts, 0x00, 0x0b, 0x00, 0x02, 0x01, 0x00)                                                 // ec_point_formats
+	exts = append(exts, 0x00, 0x23, 0x00, 0x00)                                                             // session_ticket
+
+	// application_layer_protocol_negotiation
+	alpns := []string{"http/0.9", "http/1.0", "http/1.1", "spdy/1", "spdy/2", "spdy/3", "h2", "h2c", "hq"}
+	if probe.rareALPN {
+		alpns = []string{"http/0.9", "http/1.0", "spdy/1", "spdy/2", "spdy/3", "h2c", "hq"}
+	}
+	alpns = jarmMung(alpns, probe.extensionOrd)
+	var alpnList []byte
+	for _, a := range alpns {
+		alpnList = append(alpnList, byte(len(a)))
+		alpnList = append(alpnList, a...)
+	}
+	exts = append(exts, 0x00, 0x10)
+	exts = binary.BigEndian.AppendUint16(exts, uint16(len(alpnList)+2))
+	exts = binary.BigEndian.AppendUint16(exts, uint16(len(alpnList)))
+	exts = append(exts, alpnList...)
+
+	// signature_algorithms
+	exts = append(exts, 0x00, 0x0d, 0x00, 0x14, 0x00, 0x12, 0x04, 0x03, 0x08, 0x04, 0x04, 0x01,
+		0x05, 0x03, 0x08, 0x05, 0x05, 0x01, 0x08, 0x06, 0x06, 0x01, 0x02, 0x01)
+
+	// key_share
+	var share []byte
+	if probe.grease {
+		share = binary.BigEndian.AppendUint16(share, jarmGrease())
+		share = append(share, 0x00, 0x01, 0x00)
+	}
+	key := make([]byte, 32)
+	rand.Read(key) //nolint:errcheck // crypto/rand never fails on supported platforms
+	share = append(share, 0x00, 0x1d, 0x00, 0x20)
+	share = append(share, key...)
+	exts = append(exts, 0x00, 0x33)
+	exts = binary.BigEndian.AppendUint16(exts, uint16(len(share)+2))
+	exts = binary.BigEndian.AppendUint16(exts, uint16(len(share)))
+	exts = append(exts, share...)
+
+	exts = append(exts, 0x00, 0x2d, 0x00, 0x02, 0x01, 0x01) // psk_key_exchange_modes
+
+	// supported_versions
+	if probe.version == "TLS_1.3" || probe.support == "1.2_SUPPORT" {
+		versions := []uint16{0x0301, 0x0302, 0x0303, 0x0304}
+		if probe.support == "1.2_SUPPORT" {
+			versions = versions[:3]
+		}
+		versions = jarmMung(versions, probe.extensionOrd)
+		if probe.grease {
+			versions = append([]uint16{jarmGrease()}, versions...)
+		}
+		exts = append(exts, 0x00, 0x2b)
+		exts = binary.BigEndian.AppendUint16(exts, uint16(2*len(versions)+1))
+		exts = append(exts, byte(2*len(versions)))
+		for _, v := range versions {
+			exts = binary.BigEndian.AppendUint16(exts, v)
+		}
+	}
+
+	return append(binary.BigEndian.AppendUint16(nil, uint16(len(exts))), exts...)
+}
+
+// jarmMung reorders list as requested by a probe.
+func jarmMung[T any](list []T, order string) []T {
+	n := len(list)
+	var out []T
+	switch order {
+	case "REVERSE":
+		for i := n - 1; i >= 0; i-- {
+			out = append(out, list[i])
+		}
+	case "BOTTOM_HALF":
+		if n%2 == 1 {
+			out = append(out, list[n/2+1:]...)
+		} else {
+			out = append(out, list[n/2:]...)
+		}
+	case "TOP_HALF":
+		if n%2 == 1 {
+			out = append(out, list[n/2])
+		}
+		out = append(out, jarmMung(jarmMung(list, "REVERSE"), "BOTTOM_HALF")...)
+	case "MIDDLE_OUT":
+		middle := n / 2
+		if n%2 == 1 {
+			out = append(out, list[middle])
+			for i := 1; i <= middle; i++ {
+				out = append(out, list[middle+i], list[middle-i])
+			}
+		} else {
+			for i := 1; i <= middle; i++ {
+				out = append(out, list[middle-1+i], list[middle-i])
+			}
+		}
+	default:
+		out = append(out, list...)
+	}
+	return out
+}
+
+// jarmGrease returns a random GREASE value (RFC 8701).
+func jarmGrease() uint16 {
+	b := make([]byte, 1)
+	rand.Read(b) //nolint:errcheck // crypto/rand never fails on supported platforms
+	g := uint16(b[0]&0xf0 | 0x0a)
+	return g<<8 | g
+}
+
+// jarmParseServerHello summarizes a ServerHello as
+// "cipher|version|alpn|extension types", or "|||" for anything else.
+func jarmParseServerHello(data []byte) string {
+	if len(data) < 44 || data[0] != 0x16 || data[5] != 0x02 {
+		return "|||"
+	}
+	sessionIDLen := int(data[43])
+	if len(data) < sessionIDLen+46 {
+		return "|||"
+	}
+	cipher := hex.EncodeToString(data[sessionIDLen+44 : sessionIDLen+46])
+	version := hex.EncodeToString(data[9:11])
+	helloLen := int(binary.BigEndian.Uint16(data[3:5]))
+	return cipher + "|" + version + "|" + jarmExtensionInfo(data, sessionIDLen, helloLen)
+}
+
+// jarmExtensionInfo returns the "alpn|extension types" part of the summary
+// of a ServerHello.
+func jarmExtensionInfo(data []byte, sessionIDLen, helloLen int) string {
+	at := func(i int) (byte, bool) {
+		if i < len(data) {
+			return data[i], true
+		}
+		return 0, false
+	}
+	if b, ok := at(sessionIDLen + 47); !ok || b == 11 {
+		return "|"
+	}
+	if len(data) >= sessionIDLen+53 && string(data[sessionIDLen+50:sessionIDLen+53]) == "\x0e\xac\x0b" ||
+		len(data) >= 85 && string(data[82:85]) == "\x0f\xf0\x0b" {
+		return "|"
+	}
+	if sessionIDLen+42 >= helloLen || len(data) < sessionIDLen+49 {
+		return "|"
+	}
+
+	count := sessionIDLen + 49
+	maximum := int(binary.BigEndian.Uint16(data[sessionIDLen+47:])) + count - 1
+	var types []string
+	var alpn string
+	for count < maximum {
+		if len(data) < count+4 {
+			return "|"
+		}
+		typ := data[count : count+2]
+		length := int(binary.BigEndian.Uint16(data[count+2:]))
+		if len(data) < count+4+length {
+			return "|"
+		}
+		value := data[count+4 : count+4+length]
+		if typ[0] == 0x00 && typ[1] == 0x10 && alpn == "" && len(value) > 3 {
+			alpn = string(value[3:])
+		}
+		types = append(types, hex.EncodeToString(typ))
+		count += 4 + length
+	}
+	return alpn + "|" + strings.Join(types, "-")
+}
+
+// jarmHash turns the summaries of the ten ServerHellos into the fingerprint:
+// the selected cipher and version of every reply, followed by a truncated
+// SHA-256 of their ALPNs and extensions.
+func jarmHash(answers []string) string {
+	var fuzzy, rest strings.Builder
+	empty := true
+	for _, answer := range answers {
+		parts := strings.SplitN(answer, "|", 4)
+		for len(parts) < 4 {
+			parts = append(parts, "")
+		}
+		if answer != "|||" {
+			empty = false
+		}
+		fuzzy.WriteString(jarmCipherByte(parts[0]))
+		fuzzy.WriteString(jarmVersionByte(parts[1]))
+		rest.WriteString(parts[2])
+		rest.WriteString(parts[3])
+	}
+	if empty {
+		return strings.Repeat("0", 62)
+	}
+	sum := sha256.Sum256([]byte(rest.String()))
+	return fuzzy.String() + hex.EncodeToString(sum[:])[:32]
+}
+
+// jarmCipherByte encodes a selected cipher as its position in
+// jarmCipherIndex, in two hex digits.
+func jarmCipherByte(cipher string) string {
+	if cipher == "" {
+		return "00"
+	}
+	count := 1
+	for _, c := range jarmCipherIndex {
+		if fmt.Sprintf("%04x", c) == cipher {
+			break
+		}
+		count++
+	}
+	return fmt.Sprintf("%02x", count)
+}
+
+// jarmVersionByte encodes a TLS version: "a" for SSLv3 to "e" for TLS 1.3.
+func jarmVersionByte(version string) string {
+	if len(version) < 4 {
+		return "0"
+	}
+	n := int(version[3] - '0')
+	if n < 0 || n > 5 {
+		return "0"
+	}
+	return string("abcdef"[n])
+}
@@ -0,0 +1,230 @@
+package scanme
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net"
+	"time"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"github.com/google/gopacket/pcap"
+)
+
+const (
+	// knockMaxGap is the longest pause between two SYNs from the same source
+	// for DetectKnocking to consider them part of the same sequence.
+	knockMaxGap = 5 * time.Second
+	// knockMinLength is the number of knocks a sequence needs to be reported.
+	knockMinLength = 3
+)
+
+// KnockEvent is a potential port knocking sequence seen by DetectKnocking:
+// Source sent SYNs to Ports on Target, in that order, none of which accepted
+// the connection. Opened is the port Source connected to right after the
+// sequence, or 0 when it did not.
+type KnockEvent struct {
+	Source net.IP
+	Target net.IP
+	Ports  []layers.TCPPort
+	Start  time.Time
+	End    time.Time
+	Opened layers.TCPPort
+}
+
+// KnockSequence knocks on the target by sending a single SYN to each port
+// in ports, in order, waiting delay between knocks. No answers are awaited,
+// as knock ports are usually dropped by the firewall. Use KnockAndVerify to
+// also check that the sequence opened a port.
+func (s *scanner) KnockSequence(ctx context.Context, ports []layers.TCPPort, delay time.Duration) error {
+	if len(ports) == 0 {
+		return fmt.Errorf("empty knock sequence")
+	}
+
+	mac, err := s.sendARPRequest(ctx)
+	if err != nil {
+		return err
+	}
+	srcPort, err := getFreeTCPPort()
+	if err != nil {
+		return err
+	}
+
+	eth := layers.Ethernet{
+		SrcMAC:       s.iface.HardwareAddr,
+		DstMAC:       mac,
+		EthernetType: layers.EthernetTypeIPv4,
+	}
+	ip4 := layers.IPv4{
+		SrcIP:    s.src,
+		DstIP:    s.dst,
+		Version:  4,
+		TTL:      s.ttl,
+		Protocol: layers.IPProtocolTCP,
+	}
+
+	for i, port := range ports {
+		if i > 0 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(delay):
+			}
+		}
+		tcp := layers.TCP{
+			SrcPort: srcPort,
+			DstPort: port,
+			Seq:     s.nextSeq(),
+			Window:  1024,
+			SYN:     true,
+		}
+		if err := tcp.SetNetworkLayerForChecksum(&ip4); err != nil {
+			return err
+		}
+		if err := s.send(&eth, &ip4, &tcp); err != nil {
+			return fmt.Errorf("knocking on port %v: %w", port, err)
+		}
+	}
+	return nil
+}
+
+// KnockAndVerify runs KnockSequence and then SYN scans target to report
+// whether the knock opened it.
+func (s *scanner) KnockAndVerify(ctx context.Context, ports []layers.TCPPort, delay time.Duration, target layers.TCPPort) (bool, error) {
+	if err := s.KnockSequence(ctx, ports, delay); err != nil {
+		return false, err
+	}
+	result, err := s.synscan(ctx, []layers.TCPPort{target}, nil)
+	if err != nil {
+		return false, err
+	}
+	p, ok := result.Port(target)
+	return ok && p.State == "open", nil
+}
+
+// knockSource tracks the SYNs a single source sent to a single target.
+type knockSource struct {
+	event   KnockEvent
+	pending map[layers.TCPPort]bool
+}
+
+// DetectKnocking captures the TCP traffic on iface for duration, or until
+// ctx is done, and reports potential port knocking sequences: runs of at
+// least three SYNs from one source to distinct ports of one target, less than
+// five seconds apart, that were never answered with a SYN-ACK. When the
+// source then completes a handshake with the target, the port is reported
+// as Opened. Nothing is sent on the network.
+//
+// The interface is put in promiscuous mode, so on a switched network this
+// only sees the traffic of other hosts when it is mirrored to the scanner.
+func DetectKnocking(ctx context.Context, iface *net.Interface, duration time.Duration) ([]KnockEvent, error) {
+	handle, err := openLive(iface.Name)
+	if err != nil {
+		return nil, err
+	}
+	defer handle.Close()
+
+	// tcp[13] & 0x02 != 0 matches SYN and SYN-ACK segments.
+	if err := handle.SetBPFFilter("tcp and tcp[13] & 0x02 != 0"); err != nil {
+		return nil, err
+	}
+
+	var events []KnockEvent
+	sources := make(map[string]*knockSource)
+	flush := func(key string) {
+		ks := sources[key]
+		delete(sources, key)
+		if len(ks.event.Ports) >= knockMinLength {
+			events = append(events, ks.event)
+		}
+	}
+	flushAll := func() []KnockEvent {
+		for key := range sources {
+			flush(key)
+		}
+		return events
+	}
+
+	var eth layers.Ethernet
+	var dot1q layers.Dot1Q
+	var ip4 layers.IPv4
+	var tcp layers.TCP
+	parser := gopacket.NewDecodingLayerParser(layers.LayerTypeEthernet, &eth, &dot1q, &ip4, &tcp)
+	parser.IgnoreUnsupported = true
+	decoded := []gopacket.LayerType{}
+
+	deadline := time.Now().Add(duration)
+	for time.Now().Before(deadline) {
+		select {
+		case <-ctx.Done():
+			return flushAll(), ctx.Err()
+		default:
+		}
+
+		data, ci, err := handle.ReadPacketData()
+		if err == pcap.NextErrorTimeoutExpired {
+			continue
+		} else if err != nil {
+			slog.Error("error reading packet", "err", err)
+			continue
+		}
+
+		//nolint:staticcheck // SA9003 ignore this!
+		if err := parser.DecodeLayers(data, &decoded); err != nil {
+			// Errors here are due to the decoder, and not all layers are implemented.
+		}
+		if len(decoded) == 0 || decoded[len(decoded)-1] != layers.LayerTypeTCP {
+			continue
+		}
+
+		if tcp.SYN && tcp.ACK {
+			// The target accepted a connection: the port was not a knock,
+			// and if the sequence was already complete it is what it opened.
+			key := ip4.DstIP.String() + ">" + ip4.SrcIP.String()
+			ks, ok := sources[key]
+			if !ok || !ks.pending[tcp.SrcPort] {
+				continue
+			}
+			delete(ks.pending, tcp.SrcPort)
+			ports := ks.event.Ports[:0]
+			for _, p := range ks.event.Ports {
+				if p != tcp.SrcPort {
+					ports = append(ports, p)
+				}
+			}
+			ks.event.Ports = ports
+			if len(ports) >= knockMinLength {
+				ks.event.Opened = tcp.SrcPort
+				flush(key)
+			}
+			continue
+		}
+
+		key := ip4.SrcIP.String() + ">" + ip4.DstIP.String()
+		ks, ok := sources[key]
+		if ok && ci.Timestamp.Sub(ks.event.End) > knockMaxGap {
+			flush(key)
+			ok = false
+		}
+		if !ok {
+			ks = &knockSource{
+				event: KnockEvent{
+					Source: append(net.IP(nil), ip4.SrcIP...),
+					Target: append(net.IP(nil), ip4.DstIP...),
+					Start:  ci.Timestamp,
+				},
+				pending: make(map[layers.TCPPort]bool),
+			}
+			sources[key] = ks
+		}
+		if ks.pending[tcp.DstPort] {
+			// Retransmission of a knock already recorded.
+			continue
+		}
+		ks.pending[tcp.DstPort] = true
+		ks.event.Ports = append(ks.event.Ports, tcp.DstPort)
+		ks.event.End = ci.Timestamp
+	}
+	return flushAll(), nil
+}
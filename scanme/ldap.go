@@ -0,0 +1,252 @@
+package scanme
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+)
+
+// ldapTimeout bounds LDAPProbe when ctx has no deadline.
+const ldapTimeout = 5 * time.Second
+
+// The LDAP protocol operations, as BER application tags, used by LDAPProbe.
+const (
+	ldapBindRequest   = 0x60
+	ldapBindResponse  = 0x61
+	ldapSearchRequest = 0x63
+	ldapSearchEntry   = 0x64
+	ldapSearchDone    = 0x65
+)
+
+// ldapRootDSEAttributes are the attributes of the root DSE LDAPProbe reads.
+var ldapRootDSEAttributes = []string{
+	"namingContexts", "supportedSASLMechanisms", "serverName", "currentTime",
+	"vendorName", "vendorVersion", "supportedCapabilities", "objectClass",
+}
+
+// ldapADCapability is the supportedCapabilities OID of Active Directory
+// domain controllers.
+const ldapADCapability = "1.2.840.113556.1.4.800"
+
+// ErrNotLDAP is returned by LDAPProbe when the service on port 389 does not
+// speak LDAP.
+var ErrNotLDAP = errors.New("not an LDAP service")
+
+// LDAPInfo describes the LDAP server of the target.
+type LDAPInfo struct {
+	// AnonymousBindAllowed is set when the server accepted a simple bind
+	// with an empty DN and password, a security finding: anonymous
+	// clients may then be able to read the directory.
+	AnonymousBindAllowed bool
+	// NamingContexts are the base DNs of the directory, such as
+	// "DC=example,DC=com".
+	NamingContexts []string
+	SASLMechanisms []string
+	// ServerType identifies the server, such as "Active Directory",
+	// "OpenLDAP" or the vendor of the server, "LDAP" when unknown.
+	ServerType string
+	// ServerName and CurrentTime are those reported by Active Directory.
+	ServerName  string
+	CurrentTime string
+}
+
+// ServiceHint returns the classification of the server for
+// PortResult.ServiceHint.
+func (l *LDAPInfo) ServiceHint() string {
+	if l.AnonymousBindAllowed {
+		return "LDAP-anonymous (" + l.ServerType + ")"
+	}
+	return "ldap (" + l.ServerType + ")"
+}
+
+// LDAPProbe connects to port 389 of the target, attempts an anonymous
+// bind and reads the root DSE, which describes the directory. The root DSE
+// is read even when the bind is refused, as most servers allow it to
+// unauthenticated clients.
+func (s *scanner) LDAPProbe(ctx context.Context) (*LDAPInfo, error) {
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, ldapTimeout)
+		defer cancel()
+	}
+	conn, err := dialICS(ctx, s.dst, "389")
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	// Version 3, empty DN, simple authentication with an empty password.
+	bind := append(berInt(3), derTLV(0x04, nil)...)
+	bind = append(bind, derTLV(0x80, nil)...)
+	if _, err := conn.Write(ldapMessage(1, derTLV(ldapBindRequest, bind))); err != nil {
+		return nil, err
+	}
+	op, value, err := readLDAPMessage(conn)
+	if err != nil || op != ldapBindResponse {
+		return nil, ErrNotLDAP
+	}
+	code, err := ldapResultCode(value)
+	if err != nil {
+		return nil, ErrNotLDAP
+	}
+	info := &LDAPInfo{AnonymousBindAllowed: code == 0, ServerType: "LDAP"}
+	if info.AnonymousBindAllowed {
+		s.logger.Warn("LDAP server allows anonymous bind", "target", s.dst)
+	}
+
+	search := derTLV(0x04, nil)               // base object, the root DSE
+	search = append(search, 0x0a, 0x01, 0x00) // scope base
+	search = append(search, 0x0a, 0x01, 0x00) // never dereference aliases
+	search = append(search, berInt(0)...)     // size limit
+	search = append(search, berInt(0)...)     // time limit
+	search = append(search, 0x01, 0x01, 0x00) // types only, false
+	search = append(search, derTLV(0x87, []byte("objectClass"))...)
+	var attrs []byte
+	for _, a := range ldapRootDSEAttributes {
+		attrs = append(attrs, derTLV(0x04, []byte(a))...)
+	}
+	search = append(search, derTLV(0x30, attrs)...)
+	if _, err := conn.Write(ldapMessage(2, derTLV(ldapSearchRequest, search))); err != nil {
+		return info, nil
+	}
+	for {
+		op, value, err := readLDAPMessage(conn)
+		if err != nil || op == ldapSearchDone {
+			break
+		}
+		if op == ldapSearchEntry {
+			if dse, err := parseLDAPEntry(value); err == nil {
+				info.fromRootDSE(dse)
+			}
+		}
+	}
+	return info, nil
+}
+
+// fromRootDSE fills in l from the attributes of the root DSE.
+func (l *LDAPInfo) fromRootDSE(dse map[string][]string) {
+	first := func(name string) string {
+		if v := dse[name]; len(v) > 0 {
+			return v[0]
+		}
+		return ""
+	}
+	l.NamingContexts = dse["namingcontexts"]
+	l.SASLMechanisms = dse["supportedsaslmechanisms"]
+	l.ServerName = first("servername")
+	l.CurrentTime = first("currenttime")
+	for _, c := range dse["supportedcapabilities"] {
+		if c == ldapADCapability {
+			l.ServerType = "Active Directory"
+			return
+		}
+	}
+	for _, c := range dse["objectclass"] {
+		if strings.EqualFold(c, "OpenLDAProotDSE") {
+			l.ServerType = "OpenLDAP"
+			return
+		}
+	}
+	if vendor := first("vendorname"); vendor != "" {
+		l.ServerType = strings.TrimSpace(vendor + " " + first("vendorversion"))
+	}
+}
+
+// ldapMessage wraps the protocol operation op in an LDAPMessage.
+func ldapMessage(id int, op []byte) []byte {
+	return derTLV(0x30, append(berInt(id), op...))
+}
+
+// readLDAPMessage reads an LDAPMessage from conn and returns the tag and the
+// value of its protocol operation.
+func readLDAPMessage(conn net.Conn) (byte, []byte, error) {
+	var header [2]byte
+	if _, err := io.ReadFull(conn, header[:]); err != nil {
+		return 0, nil, err
+	}
+	if header[0] != 0x30 {
+		return 0, nil, ErrNotLDAP
+	}
+	n := int(header[1])
+	if n&0x80 != 0 {
+		size := n & 0x7f
+		if size == 0 || size > 3 {
+			return 0, nil, ErrNotLDAP
+		}
+		length := make([]byte, size)
+		if _, err := io.ReadFull(conn, length); err != nil {
+			return 0, nil, err
+		}
+		n = berUint(length)
+	}
+	if n > 1<<20 {
+		return 0, nil, ErrNotLDAP
+	}
+	body := make([]byte, n)
+	if _, err := io.ReadFull(conn, body); err != nil {
+		return 0, nil, err
+	}
+	// The message ID, then the protocol operation.
+	_, _, rest, err := berNext(body)
+	if err != nil {
+		return 0, nil, ErrNotLDAP
+	}
+	op, value, _, err := berNext(rest)
+	if err != nil {
+		return 0, nil, ErrNotLDAP
+	}
+	return op, value, nil
+}
+
+// ldapResultCode returns the result code of an LDAPResult.
+func ldapResultCode(b []byte) (int, error) {
+	tag, value, _, err := berNext(b)
+	if err != nil {
+		return 0, err
+	}
+	if tag != 0x0a {
+		return 0, fmt.Errorf("ldap: unexpected tag 0x%02x", tag)
+	}
+	return berUint(value), nil
+}
+
+// parseLDAPEntry parses the attributes of a SearchResultEntry, by lowercased
+// attribute name.
+func parseLDAPEntry(b []byte) (map[string][]string, error) {
+	_, _, rest, err := berNext(b) // object name
+	if err != nil {
+		return nil, err
+	}
+	_, list, _, err := berNext(rest)
+	if err != nil {
+		return nil, err
+	}
+	attrs := make(map[string][]string)
+	for len(list) > 0 {
+		var attr []byte
+		if _, attr, list, err = berNext(list); err != nil {
+			return nil, err
+		}
+		_, name, rest, err := berNext(attr)
+		if err != nil {
+			return nil, err
+		}
+		_, vals, _, err := berNext(rest)
+		if err != nil {
+			return nil, err
+		}
+		key := strings.ToLower(string(name))
+		for len(vals) > 0 {
+			var v []byte
+			if _, v, vals, err = berNext(vals); err != nil {
+				return nil, err
+			}
+			attrs[key] = append(attrs[key], string(v))
+		}
+	}
+	return attrs, nil
+}
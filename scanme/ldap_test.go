@@ -0,0 +1,168 @@
+package scanme
+
+import (
+	"context"
+	"errors"
+	"net"
+	"slices"
+	"testing"
+)
+
+// ldapResult encodes an LDAPResult of code.
+func ldapResult(code byte) []byte {
+	result := []byte{0x0a, 0x01, code}
+	result = append(result, derTLV(0x04, nil)...)
+	return append(result, derTLV(0x04, nil)...)
+}
+
+// ldapEntry encodes the SearchResultEntry of the root DSE with the values
+// of attrs, by attribute name.
+func ldapEntry(attrs map[string][]string) []byte {
+	var list []byte
+	for name, values := range attrs {
+		var vals []byte
+		for _, v := range values {
+			vals = append(vals, derTLV(0x04, []byte(v))...)
+		}
+		attr := append(derTLV(0x04, []byte(name)), derTLV(0x31, vals)...)
+		list = append(list, derTLV(0x30, attr)...)
+	}
+	return derTLV(ldapSearchEntry, append(derTLV(0x04, nil), derTLV(0x30, list)...))
+}
+
+// ldapServer returns a handler answering the bind with code and the search
+// of the root DSE with dse. The operations of the client are sent on ops.
+func ldapServer(code byte, dse map[string][]string, ops chan<- byte) func(net.Conn) {
+	return func(conn net.Conn) {
+		op, _, err := readLDAPMessage(conn)
+		if err != nil {
+			return
+		}
+		ops <- op
+		conn.Write(ldapMessage(1, derTLV(ldapBindResponse, ldapResult(code))))
+		if op, _, err = readLDAPMessage(conn); err != nil {
+			return
+		}
+		ops <- op
+		conn.Write(append(ldapMessage(2, ldapEntry(dse)), ldapMessage(2, derTLV(ldapSearchDone, ldapResult(0)))...))
+	}
+}
+
+func TestLDAPProbe(t *testing.T) {
+	tests := []struct {
+		name string
+		code byte
+		dse  map[string][]string
+		want LDAPInfo
+		hint string
+	}{
+		{
+			name: "Active Directory",
+			code: 0,
+			dse: map[string][]string{
+				"namingContexts":          {"DC=corp,DC=example,DC=com", "CN=Configuration,DC=corp,DC=example,DC=com"},
+				"supportedSASLMechanisms": {"GSSAPI", "GSS-SPNEGO"},
+				"serverName":              {"CN=DC01,CN=Servers,CN=Default-First-Site-Name,CN=Sites,CN=Configuration,DC=corp,DC=example,DC=com"},
+				"currentTime":             {"20261016120000.0Z"},
+				"supportedCapabilities":   {ldapADCapability, "1.2.840.113556.1.4.1670"},
+			},
+			want: LDAPInfo{
+				AnonymousBindAllowed: true,
+				NamingContexts:       []string{"DC=corp,DC=example,DC=com", "CN=Configuration,DC=corp,DC=example,DC=com"},
+				SASLMechanisms:       []string{"GSSAPI", "GSS-SPNEGO"},
+				ServerType:           "Active Directory",
+				ServerName:           "CN=DC01,CN=Servers,CN=Default-First-Site-Name,CN=Sites,CN=Configuration,DC=corp,DC=example,DC=com",
+				CurrentTime:          "20261016120000.0Z",
+			},
+			hint: "LDAP-anonymous (Active Directory)",
+		},
+		{
+			name: "OpenLDAP refusing the bind",
+			code: 49, // invalidCredentials
+			dse: map[string][]string{
+				"namingContexts": {"dc=example,dc=org"},
+				"objectClass":    {"top", "OpenLDAProotDSE"},
+			},
+			want: LDAPInfo{NamingContexts: []string{"dc=example,dc=org"}, ServerType: "OpenLDAP"},
+			hint: "ldap (OpenLDAP)",
+		},
+		{
+			name: "vendor",
+			code: 48, // inappropriateAuthentication
+			dse: map[string][]string{
+				"vendorName":    {"389 Project"},
+				"vendorVersion": {"389-Directory/2.4.4"},
+			},
+			want: LDAPInfo{ServerType: "389 Project 389-Directory/2.4.4"},
+			hint: "ldap (389 Project 389-Directory/2.4.4)",
+		},
+		{
+			name: "empty root DSE",
+			want: LDAPInfo{AnonymousBindAllowed: true, ServerType: "LDAP"},
+			hint: "LDAP-anonymous (LDAP)",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ops := make(chan byte, 2)
+			testServer(t, "127.0.0.1:389", ldapServer(tt.code, tt.dse, ops))
+			s := newTestScanner()
+			s.dst = net.IPv4(127, 0, 0, 1)
+			info, err := s.LDAPProbe(context.Background())
+			if err != nil {
+				t.Fatalf("LDAPProbe() error = %v", err)
+			}
+			if info.AnonymousBindAllowed != tt.want.AnonymousBindAllowed || info.ServerType != tt.want.ServerType ||
+				info.ServerName != tt.want.ServerName || info.CurrentTime != tt.want.CurrentTime ||
+				!slices.Equal(info.NamingContexts, tt.want.NamingContexts) || !slices.Equal(info.SASLMechanisms, tt.want.SASLMechanisms) {
+				t.Errorf("LDAPProbe() = %+v, want %+v", info, tt.want)
+			}
+			if hint := info.ServiceHint(); hint != tt.hint {
+				t.Errorf("ServiceHint() = %q, want %q", hint, tt.hint)
+			}
+			if bind, search := <-ops, <-ops; bind != ldapBindRequest || search != ldapSearchRequest {
+				t.Errorf("client sent operations 0x%02x and 0x%02x, want a bind then a search", bind, search)
+			}
+		})
+	}
+}
+
+func TestLDAPProbeNotLDAP(t *testing.T) {
+	for _, tt := range []struct {
+		name  string
+		reply []byte
+	}{
+		{"HTTP", []byte("HTTP/1.1 400 Bad Request\r\n\r\n")},
+		{"search reply to the bind", ldapMessage(1, derTLV(ldapSearchDone, ldapResult(0)))},
+		{"closed", nil},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			testServer(t, "127.0.0.1:389", func(conn net.Conn) {
+				readLDAPMessage(conn)
+				conn.Write(tt.reply)
+			})
+			s := newTestScanner()
+			s.dst = net.IPv4(127, 0, 0, 1)
+			if _, err := s.LDAPProbe(context.Background()); !errors.Is(err, ErrNotLDAP) {
+				t.Errorf("LDAPProbe() error = %v, want ErrNotLDAP", err)
+			}
+		})
+	}
+}
+
+func TestParseLDAPEntry(t *testing.T) {
+	_, entry, _, err := berNext(ldapEntry(map[string][]string{"NamingContexts": {"dc=a", "dc=b"}, "empty": nil}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	attrs, err := parseLDAPEntry(entry)
+	if err != nil {
+		t.Fatalf("parseLDAPEntry() error = %v", err)
+	}
+	if len(attrs) != 1 || !slices.Equal(attrs["namingcontexts"], []string{"dc=a", "dc=b"}) {
+		t.Errorf("parseLDAPEntry() = %q", attrs)
+	}
+	if _, err := parseLDAPEntry(entry[:len(entry)-3]); err == nil {
+		t.Error("parseLDAPEntry() of a truncated entry: error = nil")
+	}
+}
@@ -0,0 +1,255 @@
+package scanme
+
+import (
+	"math"
+	"runtime"
+	"sync"
+	"time"
+)
+
+const (
+	// bandwidthBurst is the share of a second of traffic the bandwidth
+	// limit lets through at once.
+	bandwidthBurst = 0.1
+	// cpuMinSleep is the shortest pause the CPU limit makes, shorter ones
+	// being accumulated, as sleeps are not accurate below it.
+	cpuMinSleep = time.Millisecond
+	// memoryCheckInterval is how often the memory limit reads the heap size.
+	memoryCheckInterval = time.Second
+	// connScanWorkers is the number of ConnScan workers a memory limit
+	// starts from.
+	connScanWorkers = 1024
+)
+
+// bandwidthLimiter is a token bucket of bytes bounding the traffic written
+// to the pcap handle, set with WithBandwidthLimit. Frames larger than the
+// bucket go through once it is full, leaving it in debt.
+type bandwidthLimiter struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newBandwidthLimiter(bytesPerSecond int64) *bandwidthLimiter {
+	rate := float64(bytesPerSecond)
+	return &bandwidthLimiter{rate: rate, burst: rate * bandwidthBurst, tokens: rate * bandwidthBurst}
+}
+
+// wait blocks until n bytes may be written and reports whether it had to.
+// A nil *bandwidthLimiter never blocks.
+func (b *bandwidthLimiter) wait(n int) bool {
+	if b == nil {
+		return false
+	}
+	b.mu.Lock()
+	now := time.Now()
+	if !b.last.IsZero() {
+		b.tokens += now.Sub(b.last).Seconds() * b.rate
+		if b.tokens > b.burst {
+			b.tokens = b.burst
+		}
+	}
+	b.last = now
+	var delay time.Duration
+	if b.tokens <= 0 {
+		delay = time.Duration(-b.tokens / b.rate * float64(time.Second))
+	}
+	b.tokens -= float64(n)
+	b.mu.Unlock()
+
+	if delay <= 0 {
+		return false
+	}
+	time.Sleep(delay)
+	return true
+}
+
+// cpuLimiter bounds the CPU used by the goroutines of a scanner, set with
+// WithCPULimit: at most as many of them as the limit amounts to cores, rounded
+// up, work at once, each sleeping in proportion to its work when the limit
+// allows less than a whole core per slot. A nil *cpuLimiter does not limit
+// anything.
+type cpuLimiter struct {
+	slots chan struct{}
+	share float64
+}
+
+// newCPULimiter returns the limiter of maxPercent of the CPU capacity of the
+// machine, nil when no limit is set.
+func newCPULimiter(maxPercent float64) *cpuLimiter {
+	if maxPercent <= 0 {
+		return nil
+	}
+	cores := float64(runtime.NumCPU()) * maxPercent / 100
+	slots := int(math.Ceil(cores))
+	if slots < 1 {
+		slots = 1
+	}
+	share := cores / float64(slots)
+	if share > 1 {
+		share = 1
+	}
+	return &cpuLimiter{slots: make(chan struct{}, slots), share: share}
+}
+
+// worker returns the accounting of a goroutine working under c.
+func (c *cpuLimiter) worker() *cpuWorker {
+	if c == nil {
+		return nil
+	}
+	return &cpuWorker{limiter: c}
+}
+
+// cpuWorker accounts for the work of a single goroutine under a cpuLimiter.
+// A nil *cpuWorker does not limit anything.
+type cpuWorker struct {
+	limiter *cpuLimiter
+	start   time.Time
+	debt    time.Duration
+}
+
+// begin waits for a slot to be free, reporting whether it had to, and marks
+// the start of a unit of work.
+func (w *cpuWorker) begin() (waited bool) {
+	if w == nil {
+		return false
+	}
+	select {
+	case w.limiter.slots <- struct{}{}:
+	default:
+		w.limiter.slots <- struct{}{}
+		waited = true
+	}
+	w.start = time.Now()
+	return waited
+}
+
+// end frees the slot taken by begin and accounts for the work done since,
+// sleeping once enough idle time is owed, and reports whether it slept.
+// Otherwise, it yields the processor to other goroutines.
+func (w *cpuWorker) end() bool {
+	if w == nil {
+		return false
+	}
+	worked := time.Since(w.start)
+	<-w.limiter.slots
+	share := w.limiter.share
+	w.debt += time.Duration(float64(worked) * (1 - share) / share)
+	if w.debt < cpuMinSleep {
+		runtime.Gosched()
+		return false
+	}
+	time.Sleep(w.debt)
+	w.debt = 0
+	return true
+}
+
+// endCPUWork ends the unit of work of w, counting a CPU limit event when the
+// limit held it back, waiting for a slot as reported by begin or sleeping.
+func (s *scanner) endCPUWork(w *cpuWorker, waited bool) {
+	if w.end() || waited {
+		s.metrics.LimitTriggered(s.dst.String(), "cpu")
+	}
+}
+
+// memoryLimiter bounds the number of workers running at once, halving it
+// whenever the heap grows past the limit set with WithMemoryLimit, and
+// growing it back by one at every check while the heap stays under half of
+// the limit. A nil *memoryLimiter does not limit anything.
+type memoryLimiter struct {
+	mu       sync.Mutex
+	cond     *sync.Cond
+	limit    uint64
+	workers  int
+	capacity int
+	running  int
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+// newMemoryLimiter starts watching the heap, calling triggered whenever it
+// grows past limit, with workers running at most.
+func newMemoryLimiter(limit int64, workers int, triggered func(heap uint64, capacity int)) *memoryLimiter {
+	m := &memoryLimiter{
+		limit:    uint64(limit),
+		workers:  workers,
+		capacity: workers,
+		stop:     make(chan struct{}),
+	}
+	m.cond = sync.NewCond(&m.mu)
+	go func() {
+		ticker := time.NewTicker(memoryCheckInterval)
+		defer ticker.Stop()
+		var stats runtime.MemStats
+		for {
+			select {
+			case <-m.stop:
+				return
+			case <-ticker.C:
+			}
+			runtime.ReadMemStats(&stats)
+			m.mu.Lock()
+			switch {
+			case stats.HeapAlloc > m.limit:
+				if m.capacity > 1 {
+					m.capacity /= 2
+				}
+				capacity := m.capacity
+				m.mu.Unlock()
+				triggered(stats.HeapAlloc, capacity)
+				continue
+			case stats.HeapAlloc < m.limit/2 && m.capacity < m.workers:
+				m.capacity++
+				m.cond.Broadcast()
+			}
+			m.mu.Unlock()
+		}
+	}()
+	return m
+}
+
+// acquire blocks until a worker may start.
+func (m *memoryLimiter) acquire() {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	for m.running >= m.capacity {
+		m.cond.Wait()
+	}
+	m.running++
+	m.mu.Unlock()
+}
+
+// release signals that a worker is done.
+func (m *memoryLimiter) release() {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	m.running--
+	m.mu.Unlock()
+	m.cond.Signal()
+}
+
+// close stops watching the heap.
+func (m *memoryLimiter) close() {
+	if m == nil {
+		return
+	}
+	m.stopOnce.Do(func() { close(m.stop) })
+}
+
+// limitMemory returns the limiter of the workers of a scan, nil when no
+// memory limit is set.
+func (s *scanner) limitMemory(workers int) *memoryLimiter {
+	if s.memoryLimit <= 0 {
+		return nil
+	}
+	return newMemoryLimiter(s.memoryLimit, workers, func(heap uint64, capacity int) {
+		s.logger.Warn("memory limit exceeded", "limit", s.memoryLimit, "heap", heap, "workers", capacity)
+		s.metrics.LimitTriggered(s.dst.String(), "memory")
+	})
+}
@@ -0,0 +1,88 @@
+package scanme
+
+import (
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestNewCPULimiter(t *testing.T) {
+	if c := newCPULimiter(0); c != nil {
+		t.Errorf("newCPULimiter(0) = %+v, want nil", c)
+	}
+	if c := newCPULimiter(100); cap(c.slots) != runtime.NumCPU() || c.share != 1 {
+		t.Errorf("newCPULimiter(100) = %d slots with share %v, want %d with share 1", cap(c.slots), c.share, runtime.NumCPU())
+	}
+	// A tenth of a core of the machine.
+	c := newCPULimiter(10 / float64(runtime.NumCPU()))
+	if cap(c.slots) != 1 || c.share < 0.09 || c.share > 0.11 {
+		t.Errorf("newCPULimiter() = %d slots with share %v, want 1 with share 0.1", cap(c.slots), c.share)
+	}
+}
+
+func TestCPULimiterBoundsWorkers(t *testing.T) {
+	procs := runtime.GOMAXPROCS(0)
+	c := newCPULimiter(200 / float64(runtime.NumCPU()))
+	if got := runtime.GOMAXPROCS(0); got != procs {
+		t.Fatalf("GOMAXPROCS = %d after creating the limiter, want %d unchanged", got, procs)
+	}
+
+	var working, most atomic.Int32
+	var waited atomic.Bool
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			w := c.worker()
+			for j := 0; j < 5; j++ {
+				if w.begin() {
+					waited.Store(true)
+				}
+				n := working.Add(1)
+				for {
+					m := most.Load()
+					if n <= m || most.CompareAndSwap(m, n) {
+						break
+					}
+				}
+				time.Sleep(time.Millisecond)
+				working.Add(-1)
+				w.end()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if got := most.Load(); got > 2 {
+		t.Errorf("%d workers ran at once, want 2 at most", got)
+	}
+	if !waited.Load() {
+		t.Error("begin() never reported waiting for a slot")
+	}
+}
+
+func TestCPUWorkerSleepsForItsShare(t *testing.T) {
+	c := &cpuLimiter{slots: make(chan struct{}, 1), share: 0.5}
+	w := c.worker()
+	w.begin()
+	for start := time.Now(); time.Since(start) < 5*time.Millisecond; {
+	}
+	start := time.Now()
+	if !w.end() {
+		t.Fatal("end() did not sleep after 5ms of work at half a core")
+	}
+	if slept := time.Since(start); slept < 4*time.Millisecond {
+		t.Errorf("end() slept %v, want about 5ms", slept)
+	}
+	if len(c.slots) != 0 {
+		t.Error("end() did not free the slot")
+	}
+
+	var nilWorker *cpuWorker
+	if nilWorker.begin() || nilWorker.end() {
+		t.Error("a nil worker limited its work")
+	}
+}
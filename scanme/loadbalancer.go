@@ -0,0 +1,176 @@
+package scanme
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"github.com/google/gopacket/pcap"
+)
+
+const (
+	// minLBProbes is the number of echo requests DetectLoadBalancer needs
+	// for its analysis to be reliable.
+	minLBProbes = 10
+	// lbProbeTimeout is how long DetectLoadBalancer waits for each reply.
+	lbProbeTimeout = time.Second
+	// lbMaxIPIDGap is the largest increment between the IP IDs of two
+	// replies still taken as coming from the same counter.
+	lbMaxIPIDGap = 1000
+)
+
+// ErrLBNoReply is returned by DetectLoadBalancer when the target answers
+// too few echo requests to analyze.
+var ErrLBNoReply = errors.New("too few ICMP echo replies to detect a load balancer")
+
+// LBInfo is the outcome of DetectLoadBalancer. BackendCount is the number of
+// backend servers told apart, one when IsLB is false. TTLValues holds the
+// TTL of every reply, in the order received.
+type LBInfo struct {
+	IsLB         bool
+	BackendCount int
+	TTLValues    []uint8
+	Evidence     string
+}
+
+// DetectLoadBalancer tells whether the target address is served by several
+// hosts behind a load balancer. It sends probes ICMP echo requests, one at a
+// time, and records the TTL and IP ID of every echo reply. Backends running
+// different operating systems start from different TTLs, e.g. 64 and 128,
+// and backends with incremental IP IDs interleave distinct sequences: either
+// variation reveals several backends. Backends with random IP IDs are only
+// told apart by their TTL. At least 10 probes are required; requests left
+// unanswered are skipped and ErrLBNoReply is returned when fewer than half
+// of them are answered.
+func (s *scanner) DetectLoadBalancer(ctx context.Context, probes int) (*LBInfo, error) {
+	if probes < minLBProbes {
+		return nil, fmt.Errorf("load balancer detection requires at least %d probes, got %d", minLBProbes, probes)
+	}
+	eth, ip4, err := s.icmpLayers(ctx)
+	if err != nil {
+		return nil, err
+	}
+	handle, err := s.openICMPHandle(uint8(layers.ICMPv4TypeEchoReply))
+	if err != nil {
+		return nil, err
+	}
+	defer handle.Close()
+
+	var rEth layers.Ethernet
+	var rDot1Q layers.Dot1Q
+	var rIP4 layers.IPv4
+	var rICMP layers.ICMPv4
+	parser := gopacket.NewDecodingLayerParser(layers.LayerTypeEthernet, &rEth, &rDot1Q, &rIP4, &rICMP)
+	parser.IgnoreUnsupported = true
+	decoded := []gopacket.LayerType{}
+
+	id := uint16(s.rng.Intn(0xffff))
+	var ttls []uint8
+	var ipids []uint16
+	for seq := 1; seq <= probes; seq++ {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		icmp := layers.ICMPv4{
+			TypeCode: layers.CreateICMPv4TypeCode(layers.ICMPv4TypeEchoRequest, 0),
+			Id:       id,
+			Seq:      uint16(seq),
+		}
+		if err := s.send(&eth, &ip4, &icmp); err != nil {
+			return nil, err
+		}
+
+		deadline := time.Now().Add(lbProbeTimeout)
+		for time.Now().Before(deadline) {
+			data, _, err := handle.ReadPacketData()
+			if err == pcap.NextErrorTimeoutExpired {
+				continue
+			} else if err != nil {
+				return nil, err
+			}
+
+			//nolint:staticcheck // SA9003 ignore this!
+			if err := parser.DecodeLayers(data, &decoded); err != nil {
+				// Errors here are due to the decoder, and not all layers are implemented.
+			}
+			if rICMP.TypeCode.Type() == layers.ICMPv4TypeEchoReply && rICMP.Id == id && rICMP.Seq == uint16(seq) {
+				ttls = append(ttls, rIP4.TTL)
+				ipids = append(ipids, rIP4.Id)
+				break
+			}
+		}
+	}
+	if len(ttls) < probes/2 {
+		return nil, ErrLBNoReply
+	}
+	return analyzeLoadBalancer(ttls, ipids), nil
+}
+
+// analyzeLoadBalancer counts the backends answering with the given TTLs and
+// IP IDs, in the order received.
+func analyzeLoadBalancer(ttls []uint8, ipids []uint16) *LBInfo {
+	info := &LBInfo{TTLValues: ttls, BackendCount: 1}
+
+	distinct := make(map[uint8]bool)
+	for _, ttl := range ttls {
+		distinct[ttl] = true
+	}
+	var evidence []string
+	if len(distinct) > 1 {
+		values := make([]int, 0, len(distinct))
+		for ttl := range distinct {
+			values = append(values, int(ttl))
+		}
+		sort.Ints(values)
+		evidence = append(evidence, fmt.Sprintf("replies carry %d distinct TTLs %v", len(values), values))
+		info.BackendCount = len(values)
+	}
+
+	// A counter shared by the replies of a single host increments by a
+	// little between them. More counters than half the replies mean
+	// random IP IDs, which tell nothing.
+	if sequences := ipidSequences(ipids); sequences > 1 && sequences <= len(ipids)/2 {
+		evidence = append(evidence, fmt.Sprintf("IP IDs interleave %d incremental sequences", sequences))
+		if sequences > info.BackendCount {
+			info.BackendCount = sequences
+		}
+	}
+
+	info.IsLB = info.BackendCount > 1
+	if info.IsLB {
+		info.Evidence = strings.Join(evidence, "; ")
+	} else {
+		info.Evidence = fmt.Sprintf("%d replies with a single TTL and IP ID sequence", len(ttls))
+	}
+	return info
+}
+
+// ipidSequences returns the number of incremental IP ID counters the given
+// IP IDs, in the order received, are drawn from. Every IP ID continues the
+// counter it increments the least, or starts a new one when it is more than
+// lbMaxIPIDGap past all of them.
+func ipidSequences(ipids []uint16) int {
+	var last []uint16
+	for _, ipid := range ipids {
+		best := -1
+		for i, l := range last {
+			// IP IDs are 16-bit counters, the subtraction wraps around with them.
+			gap := ipid - l
+			if gap <= lbMaxIPIDGap && (best < 0 || gap < ipid-last[best]) {
+				best = i
+			}
+		}
+		if best < 0 {
+			last = append(last, ipid)
+		} else {
+			last[best] = ipid
+		}
+	}
+	return len(last)
+}
@@ -0,0 +1,403 @@
+package scanme
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// mailTimeout bounds the mail probes when ctx has no deadline.
+const mailTimeout = 10 * time.Second
+
+// mailPort is a port a mail probe tries, with TLS from the start when
+// implicitTLS is set.
+type mailPort struct {
+	port        int
+	implicitTLS bool
+}
+
+var (
+	smtpPorts = []mailPort{{25, false}, {587, false}}
+	imapPorts = []mailPort{{143, false}, {993, true}}
+	pop3Ports = []mailPort{{110, false}, {995, true}}
+)
+
+// smtpRelaySender and smtpRelayRecipient are the addresses of the relay
+// test of SMTPProbe, both outside the domains of the target.
+const (
+	smtpRelaySender    = "scanme@example.org"
+	smtpRelayRecipient = "test@external.com"
+)
+
+// smtpProducts maps substrings of SMTP greetings to the server software
+// they identify.
+var smtpProducts = []struct {
+	match, name string
+}{
+	{"postfix", "Postfix"},
+	{"exim", "Exim"},
+	{"sendmail", "Sendmail"},
+	{"microsoft esmtp", "Microsoft Exchange"},
+	{"exchange", "Microsoft Exchange"},
+	{"qmail", "qmail"},
+	{"opensmtpd", "OpenSMTPD"},
+	{"haraka", "Haraka"},
+	{"zimbra", "Zimbra"},
+	{"mdaemon", "MDaemon"},
+}
+
+// SMTPInfo describes the SMTP server of the target.
+type SMTPInfo struct {
+	// Port is the port the server answered on, 25 or 587.
+	Port   int
+	Banner string
+	// ServerSoftware is the server identified from the banner, such as
+	// "Postfix", empty when unknown.
+	ServerSoftware string
+	// SupportedExtensions lists the EHLO keywords of the server, such as
+	// "SIZE 35882577", read over TLS when STARTTLS succeeded.
+	SupportedExtensions []string
+	StartTLSSupported   bool
+	// OpenRelay is set when the server accepted a recipient outside its
+	// domains from an unauthenticated client: spammers can send mail
+	// through it.
+	OpenRelay bool
+}
+
+// ServiceHint returns the classification of the server for
+// PortResult.ServiceHint.
+func (i *SMTPInfo) ServiceHint() string {
+	hint := "smtp"
+	if i.ServerSoftware != "" {
+		hint += " (" + i.ServerSoftware + ")"
+	}
+	if i.OpenRelay {
+		hint += ", open relay"
+	}
+	return hint
+}
+
+// IMAPInfo describes the IMAP server of the target.
+type IMAPInfo struct {
+	// Port is the port the server answered on, 143 or 993, over TLS from
+	// the start when ImplicitTLS is set.
+	Port         int
+	ImplicitTLS  bool
+	Banner       string
+	Capabilities []string
+	// StartTLSSupported is set when the server upgraded the connection to
+	// TLS, LoginDisabled when it refuses plain text logins before.
+	StartTLSSupported bool
+	LoginDisabled     bool
+}
+
+// POP3Info describes the POP3 server of the target.
+type POP3Info struct {
+	// Port is the port the server answered on, 110 or 995, over TLS from
+	// the start when ImplicitTLS is set.
+	Port         int
+	ImplicitTLS  bool
+	Banner       string
+	Capabilities []string
+	// StartTLSSupported is set when the server upgraded the connection to
+	// TLS with STLS.
+	StartTLSSupported bool
+}
+
+// SMTPProbe connects to port 25 of the target, or 587 when closed, reads
+// the banner and the EHLO extensions, upgrades the connection with STARTTLS
+// when offered, and tests whether the server relays mail: a recipient of
+// an external domain is given, and the transaction reset before any mail
+// is sent. An open relay is a critical finding.
+func (s *scanner) SMTPProbe(ctx context.Context) (*SMTPInfo, error) {
+	return tryMailPorts(ctx, "smtp", smtpPorts, func(ctx context.Context, p mailPort) (*SMTPInfo, error) {
+		return s.smtpProbe(ctx, p)
+	})
+}
+
+// IMAPProbe connects to port 143 of the target, or 993 over TLS when
+// closed, reads the greeting and the capabilities of the server, and
+// upgrades the connection with STARTTLS when offered.
+func (s *scanner) IMAPProbe(ctx context.Context) (*IMAPInfo, error) {
+	return tryMailPorts(ctx, "imap", imapPorts, func(ctx context.Context, p mailPort) (*IMAPInfo, error) {
+		return s.imapProbe(ctx, p)
+	})
+}
+
+// POP3Probe connects to port 110 of the target, or 995 over TLS when
+// closed, reads the greeting and the capabilities of the server, and
+// upgrades the connection with STLS when offered.
+func (s *scanner) POP3Probe(ctx context.Context) (*POP3Info, error) {
+	return tryMailPorts(ctx, "pop3", pop3Ports, func(ctx context.Context, p mailPort) (*POP3Info, error) {
+		return s.pop3Probe(ctx, p)
+	})
+}
+
+// tryMailPorts runs probe on ports in turn and returns the result of the
+// first that succeeds, or the errors of all.
+func tryMailPorts[T any](ctx context.Context, proto string, ports []mailPort, probe func(context.Context, mailPort) (*T, error)) (*T, error) {
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, mailTimeout)
+		defer cancel()
+	}
+	var errs []error
+	for _, p := range ports {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		info, err := probe(ctx, p)
+		if err == nil {
+			return info, nil
+		}
+		errs = append(errs, fmt.Errorf("%s on port %d: %w", proto, p.port, err))
+	}
+	return nil, errors.Join(errs...)
+}
+
+// mailConn is the connection of a mail probe.
+type mailConn struct {
+	conn net.Conn
+	text *textproto.Conn
+}
+
+// dialMail connects to p on ip, over TLS when p says so.
+func dialMail(ctx context.Context, ip net.IP, p mailPort) (*mailConn, error) {
+	conn, err := dialICS(ctx, ip, strconv.Itoa(p.port))
+	if err != nil {
+		return nil, err
+	}
+	c := &mailConn{conn: conn, text: textproto.NewConn(conn)}
+	if p.implicitTLS {
+		if err := c.startTLS(); err != nil {
+			c.close()
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// startTLS completes a TLS handshake over the connection, once the server
+// agreed to it.
+func (c *mailConn) startTLS() error {
+	tlsConn := tls.Client(c.conn, &tls.Config{
+		InsecureSkipVerify: true, // the certificate is of no interest here
+	})
+	if err := tlsConn.Handshake(); err != nil {
+		return err
+	}
+	c.conn, c.text = tlsConn, textproto.NewConn(tlsConn)
+	return nil
+}
+
+// close closes the connection.
+func (c *mailConn) close() {
+	c.conn.Close()
+}
+
+// smtpProbe runs SMTPProbe against p.
+func (s *scanner) smtpProbe(ctx context.Context, p mailPort) (*SMTPInfo, error) {
+	c, err := dialMail(ctx, s.dst, p)
+	if err != nil {
+		return nil, err
+	}
+	defer c.close()
+
+	_, banner, err := c.text.ReadResponse(220)
+	if err != nil {
+		return nil, err
+	}
+	info := &SMTPInfo{
+		Port:           p.port,
+		Banner:         banner,
+		ServerSoftware: smtpProduct(banner),
+	}
+	if info.SupportedExtensions, err = smtpHello(c.text); err != nil {
+		return nil, err
+	}
+	if hasKeyword(info.SupportedExtensions, "STARTTLS") {
+		if _, _, err := smtpCmd(c.text, 220, "STARTTLS"); err == nil && c.startTLS() == nil {
+			info.StartTLSSupported = true
+			ext, err := smtpHello(c.text)
+			if err != nil {
+				return info, nil
+			}
+			info.SupportedExtensions = ext
+		}
+	}
+
+	// Relay test: a recipient outside the domains of the server must be
+	// refused to unauthenticated clients.
+	if _, _, err := smtpCmd(c.text, 250, "MAIL FROM:<%s>", smtpRelaySender); err == nil {
+		code, _, err := smtpCmd(c.text, 25, "RCPT TO:<%s>", smtpRelayRecipient)
+		info.OpenRelay = err == nil && (code == 250 || code == 251)
+		smtpCmd(c.text, 250, "RSET")
+	}
+	smtpCmd(c.text, 221, "QUIT")
+	return info, nil
+}
+
+// smtpHello sends EHLO, or HELO to servers not supporting it, and returns
+// the extensions the server listed.
+func smtpHello(text *textproto.Conn) ([]string, error) {
+	_, msg, err := smtpCmd(text, 250, "EHLO scanme.local")
+	if err != nil {
+		if _, _, err := smtpCmd(text, 250, "HELO scanme.local"); err != nil {
+			return nil, err
+		}
+		return nil, nil
+	}
+	// The first line greets the client, the others are the extensions.
+	lines := strings.Split(msg, "\n")
+	return lines[1:], nil
+}
+
+// smtpCmd sends a command and reads its reply, expecting a code starting
+// with expectCode, such as 25 for any 25x code.
+func smtpCmd(text *textproto.Conn, expectCode int, format string, args ...any) (int, string, error) {
+	id, err := text.Cmd(format, args...)
+	if err != nil {
+		return 0, "", err
+	}
+	text.StartResponse(id)
+	defer text.EndResponse(id)
+	return text.ReadResponse(expectCode)
+}
+
+// smtpProduct identifies the SMTP server from its banner.
+func smtpProduct(banner string) string {
+	banner = strings.ToLower(banner)
+	for _, p := range smtpProducts {
+		if strings.Contains(banner, p.match) {
+			return p.name
+		}
+	}
+	return ""
+}
+
+// imapProbe runs IMAPProbe against p.
+func (s *scanner) imapProbe(ctx context.Context, p mailPort) (*IMAPInfo, error) {
+	c, err := dialMail(ctx, s.dst, p)
+	if err != nil {
+		return nil, err
+	}
+	defer c.close()
+
+	greeting, err := c.text.ReadLine()
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(greeting, "* OK") && !strings.HasPrefix(greeting, "* PREAUTH") {
+		return nil, fmt.Errorf("not an IMAP greeting: %q", greeting)
+	}
+	info := &IMAPInfo{Port: p.port, ImplicitTLS: p.implicitTLS, Banner: greeting}
+
+	untagged, err := imapCmd(c.text, "a1", "CAPABILITY")
+	if err != nil {
+		return nil, err
+	}
+	for _, line := range untagged {
+		if caps, ok := strings.CutPrefix(line, "* CAPABILITY "); ok {
+			info.Capabilities = append(info.Capabilities, strings.Fields(caps)...)
+		}
+	}
+	info.LoginDisabled = hasKeyword(info.Capabilities, "LOGINDISABLED")
+	if !p.implicitTLS && hasKeyword(info.Capabilities, "STARTTLS") {
+		if _, err := imapCmd(c.text, "a2", "STARTTLS"); err == nil && c.startTLS() == nil {
+			info.StartTLSSupported = true
+		}
+	}
+	imapCmd(c.text, "a3", "LOGOUT")
+	return info, nil
+}
+
+// imapCmd sends the command cmd tagged with tag and returns the untagged
+// lines of the reply, or an error unless its status is OK.
+func imapCmd(text *textproto.Conn, tag, cmd string) ([]string, error) {
+	if err := text.PrintfLine("%s %s", tag, cmd); err != nil {
+		return nil, err
+	}
+	var untagged []string
+	for {
+		line, err := text.ReadLine()
+		if err != nil {
+			return untagged, err
+		}
+		status, ok := strings.CutPrefix(line, tag+" ")
+		if !ok {
+			untagged = append(untagged, line)
+			continue
+		}
+		if !strings.HasPrefix(status, "OK") {
+			return untagged, fmt.Errorf("imap %s: %s", cmd, status)
+		}
+		return untagged, nil
+	}
+}
+
+// pop3Probe runs POP3Probe against p.
+func (s *scanner) pop3Probe(ctx context.Context, p mailPort) (*POP3Info, error) {
+	c, err := dialMail(ctx, s.dst, p)
+	if err != nil {
+		return nil, err
+	}
+	defer c.close()
+
+	greeting, err := c.text.ReadLine()
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(greeting, "+OK") {
+		return nil, fmt.Errorf("not a POP3 greeting: %q", greeting)
+	}
+	info := &POP3Info{Port: p.port, ImplicitTLS: p.implicitTLS, Banner: greeting}
+
+	// Servers predating CAPA answer it with -ERR and have no capabilities
+	// worth knowing.
+	if _, err := pop3Cmd(c.text, "CAPA"); err == nil {
+		if info.Capabilities, err = c.text.ReadDotLines(); err != nil {
+			return nil, err
+		}
+	}
+	if !p.implicitTLS && hasKeyword(info.Capabilities, "STLS") {
+		if _, err := pop3Cmd(c.text, "STLS"); err == nil && c.startTLS() == nil {
+			info.StartTLSSupported = true
+		}
+	}
+	pop3Cmd(c.text, "QUIT")
+	return info, nil
+}
+
+// pop3Cmd sends cmd and returns the status line of the reply, or an error
+// unless it is +OK.
+func pop3Cmd(text *textproto.Conn, cmd string) (string, error) {
+	if err := text.PrintfLine("%s", cmd); err != nil {
+		return "", err
+	}
+	line, err := text.ReadLine()
+	if err != nil {
+		return "", err
+	}
+	if !strings.HasPrefix(line, "+OK") {
+		return line, fmt.Errorf("pop3 %s: %s", cmd, line)
+	}
+	return line, nil
+}
+
+// hasKeyword reports whether one of lines starts with the keyword kw,
+// compared case-insensitively.
+func hasKeyword(lines []string, kw string) bool {
+	for _, line := range lines {
+		word, _, _ := strings.Cut(line, " ")
+		if strings.EqualFold(word, kw) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,18 @@
+// Package metrics exposes Prometheus metrics about running scans.
+//
+// The following metrics are exported, all labelled with the scanned target:
+//
+//	scanme_packets_sent_total{target}       counter of packets injected
+//	scanme_packets_received_total{target}   counter of packets captured
+//	scanme_open_ports{target}               open ports found by the last scan
+//	scanme_scan_duration_seconds{target}    duration of the last scan
+//	scanme_limit_events_total{target,limit} times a resource limit throttled the scan
+//
+// Enable them on a scanner with scanme.WithMetricsRegistry and expose them
+// with ServeMetrics, then point Prometheus at the listening address:
+//
+//	scrape_configs:
+//	  - job_name: scanme
+//	    static_configs:
+//	      - targets: ['localhost:9090']
+package metrics
@@ -0,0 +1,143 @@
+package metrics
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Metrics holds the collectors updated by a scanner. A nil *Metrics is valid
+// and silently discards every update, so callers need not check for it.
+type Metrics struct {
+	packetsSent     *prometheus.CounterVec
+	packetsReceived *prometheus.CounterVec
+	openPorts       *prometheus.GaugeVec
+	scanDuration    *prometheus.GaugeVec
+	limitEvents     *prometheus.CounterVec
+}
+
+// New creates the scanme collectors and registers them with r, or with the
+// default Prometheus registry when r is nil. Registering twice against the
+// same registry is allowed: the already registered collectors are reused, so
+// several scanners can share one registry.
+func New(r prometheus.Registerer) (*Metrics, error) {
+	if r == nil {
+		r = prometheus.DefaultRegisterer
+	}
+
+	m := &Metrics{}
+	var err error
+	if m.packetsSent, err = registerCounter(r, prometheus.CounterOpts{
+		Name: "scanme_packets_sent_total",
+		Help: "Number of packets sent to the target.",
+	}); err != nil {
+		return nil, err
+	}
+	if m.packetsReceived, err = registerCounter(r, prometheus.CounterOpts{
+		Name: "scanme_packets_received_total",
+		Help: "Number of packets captured while scanning the target.",
+	}); err != nil {
+		return nil, err
+	}
+	if m.openPorts, err = registerGauge(r, prometheus.GaugeOpts{
+		Name: "scanme_open_ports",
+		Help: "Number of open ports found by the last scan of the target.",
+	}); err != nil {
+		return nil, err
+	}
+	if m.scanDuration, err = registerGauge(r, prometheus.GaugeOpts{
+		Name: "scanme_scan_duration_seconds",
+		Help: "Duration in seconds of the last scan of the target.",
+	}); err != nil {
+		return nil, err
+	}
+	if m.limitEvents, err = registerCounter(r, prometheus.CounterOpts{
+		Name: "scanme_limit_events_total",
+		Help: "Number of times a resource limit throttled the scan of the target.",
+	}, "limit"); err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
+
+func registerCounter(r prometheus.Registerer, opts prometheus.CounterOpts, labels ...string) (*prometheus.CounterVec, error) {
+	c := prometheus.NewCounterVec(opts, append([]string{"target"}, labels...))
+	if err := r.Register(c); err != nil {
+		var are prometheus.AlreadyRegisteredError
+		if errors.As(err, &are) {
+			if existing, ok := are.ExistingCollector.(*prometheus.CounterVec); ok {
+				return existing, nil
+			}
+		}
+		return nil, err
+	}
+	return c, nil
+}
+
+func registerGauge(r prometheus.Registerer, opts prometheus.GaugeOpts) (*prometheus.GaugeVec, error) {
+	g := prometheus.NewGaugeVec(opts, []string{"target"})
+	if err := r.Register(g); err != nil {
+		var are prometheus.AlreadyRegisteredError
+		if errors.As(err, &are) {
+			if existing, ok := are.ExistingCollector.(*prometheus.GaugeVec); ok {
+				return existing, nil
+			}
+		}
+		return nil, err
+	}
+	return g, nil
+}
+
+// PacketSent counts a packet sent while scanning target.
+func (m *Metrics) PacketSent(target string) {
+	if m == nil {
+		return
+	}
+	m.packetsSent.WithLabelValues(target).Inc()
+}
+
+// PacketReceived counts a packet captured while scanning target.
+func (m *Metrics) PacketReceived(target string) {
+	if m == nil {
+		return
+	}
+	m.packetsReceived.WithLabelValues(target).Inc()
+}
+
+// SetOpenPorts records the number of open ports found on target.
+func (m *Metrics) SetOpenPorts(target string, n int) {
+	if m == nil {
+		return
+	}
+	m.openPorts.WithLabelValues(target).Set(float64(n))
+}
+
+// ObserveScanDuration records how long the last scan of target took.
+func (m *Metrics) ObserveScanDuration(target string, d time.Duration) {
+	if m == nil {
+		return
+	}
+	m.scanDuration.WithLabelValues(target).Set(d.Seconds())
+}
+
+// LimitTriggered counts a time the resource limit named limit, "bandwidth",
+// "cpu" or "memory", throttled the scan of target.
+func (m *Metrics) LimitTriggered(target, limit string) {
+	if m == nil {
+		return
+	}
+	m.limitEvents.WithLabelValues(target, limit).Inc()
+}
+
+// ServeMetrics starts an HTTP server on addr exposing the default Prometheus
+// registry on /metrics. It blocks like http.ListenAndServe. Metrics
+// registered against a custom registry can be exposed with promhttp.HandlerFor.
+func ServeMetrics(addr string) error {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+	return http.ListenAndServe(addr, mux)
+}
@@ -0,0 +1,131 @@
+package scanme
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"time"
+)
+
+// modbusPort is the port of Modbus TCP.
+const modbusPort = "502"
+
+// icsTimeout bounds the Modbus and DNP3 exchanges when ctx has no deadline.
+const icsTimeout = 5 * time.Second
+
+// ErrNotModbus is returned by ModbusProbe when the service on port 502 does
+// not speak Modbus TCP.
+var ErrNotModbus = errors.New("not a Modbus TCP service")
+
+// ModbusInfo identifies the Modbus device of the target.
+type ModbusInfo struct {
+	// ExceptionCode is set when the device speaks Modbus but rejected the
+	// Read Device Identification request, leaving the other fields empty.
+	ExceptionCode      byte
+	VendorName         string
+	ProductCode        string
+	MajorMinorRevision string
+}
+
+// ServiceHint returns the classification of the device for
+// PortResult.ServiceHint.
+func (m *ModbusInfo) ServiceHint() string {
+	if m.VendorName == "" && m.ProductCode == "" {
+		return "modbus"
+	}
+	return fmt.Sprintf("modbus (%s %s %s)", m.VendorName, m.ProductCode, m.MajorMinorRevision)
+}
+
+// ModbusProbe sends a Modbus Read Device Identification request (function
+// code 43, MEI type 14) to port 502 of the target and reads the basic
+// identification objects of the reply. Only ever send it to devices you are
+// authorized to audit: fragile PLCs have been known to misbehave on
+// unexpected requests.
+func (s *scanner) ModbusProbe(ctx context.Context) (*ModbusInfo, error) {
+	conn, err := dialICS(ctx, s.dst, modbusPort)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	transactionID := uint16(s.rng.Intn(0xffff))
+	req := binary.BigEndian.AppendUint16(nil, transactionID)
+	req = binary.BigEndian.AppendUint16(req, 0) // protocol: Modbus
+	req = binary.BigEndian.AppendUint16(req, 5) // length of the unit ID and PDU
+	req = append(req,
+		0x00, // unit ID
+		0x2b, // encapsulated interface transport
+		0x0e, // MEI type: read device identification
+		0x01, // basic device identification
+		0x00, // starting object: VendorName
+	)
+	if _, err := conn.Write(req); err != nil {
+		return nil, err
+	}
+
+	var header [7]byte
+	if _, err := io.ReadFull(conn, header[:]); err != nil {
+		return nil, ErrNotModbus
+	}
+	length := binary.BigEndian.Uint16(header[4:])
+	if binary.BigEndian.Uint16(header[:]) != transactionID || binary.BigEndian.Uint16(header[2:]) != 0 ||
+		length < 3 || length > 254 {
+		return nil, ErrNotModbus
+	}
+	pdu := make([]byte, length-1)
+	if _, err := io.ReadFull(conn, pdu); err != nil {
+		return nil, ErrNotModbus
+	}
+	return parseModbusDeviceID(pdu)
+}
+
+// parseModbusDeviceID parses the PDU of a Read Device Identification reply.
+func parseModbusDeviceID(pdu []byte) (*ModbusInfo, error) {
+	switch {
+	case pdu[0] == 0xab:
+		return &ModbusInfo{ExceptionCode: pdu[1]}, nil
+	case pdu[0] != 0x2b || pdu[1] != 0x0e || len(pdu) < 7:
+		return nil, ErrNotModbus
+	}
+
+	info := &ModbusInfo{}
+	objects, count := pdu[7:], int(pdu[6])
+	for i := 0; i < count && len(objects) >= 2; i++ {
+		id, n := objects[0], int(objects[1])
+		if len(objects) < 2+n {
+			break
+		}
+		value := string(objects[2 : 2+n])
+		switch id {
+		case 0x00:
+			info.VendorName = value
+		case 0x01:
+			info.ProductCode = value
+		case 0x02:
+			info.MajorMinorRevision = value
+		}
+		objects = objects[2+n:]
+	}
+	return info, nil
+}
+
+// dialICS connects to port of ip, with a deadline from ctx.
+func dialICS(ctx context.Context, ip net.IP, port string) (net.Conn, error) {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(icsTimeout)
+	}
+	d := net.Dialer{Deadline: deadline}
+	conn, err := d.DialContext(ctx, "tcp", net.JoinHostPort(ip.String(), port))
+	if err != nil {
+		return nil, err
+	}
+	if err := conn.SetDeadline(deadline); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return conn, nil
+}
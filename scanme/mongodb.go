@@ -0,0 +1,281 @@
+package scanme
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+	"net"
+	"strconv"
+
+	"github.com/google/gopacket/layers"
+)
+
+// The opcodes of the MongoDB wire protocol used by MongoDBProbe.
+const (
+	mongoOpReply = 1
+	mongoOpQuery = 2004
+	mongoOpMsg   = 2013
+)
+
+// mongoOpMsgWireVersion is the first wire version supporting OP_MSG,
+// MongoDB 3.6. Since MongoDB 5.1, OP_QUERY is only accepted for the
+// isMaster handshake.
+const mongoOpMsgWireVersion = 6
+
+// MongoDBProbe sends an isMaster command to the MongoDB server on port of
+// the target, then reads its version with buildInfo and lists its databases
+// with listDatabases, which servers with access control enabled refuse
+// without credentials.
+func (s *scanner) MongoDBProbe(ctx context.Context, port layers.TCPPort) (*DatabaseInfo, error) {
+	ctx, cancel := withDatabaseTimeout(ctx)
+	defer cancel()
+	conn, err := dialICS(ctx, s.dst, strconv.Itoa(int(port)))
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	hello, err := mongoCommand(conn, false, bsonDoc("isMaster", int32(1)))
+	if err != nil {
+		return nil, ErrNotDatabase
+	}
+	if _, ok := hello["ismaster"]; !ok {
+		return nil, ErrNotDatabase
+	}
+	info := &DatabaseInfo{Type: DatabaseMongoDB}
+	wire, _ := hello["maxWireVersion"].(int32)
+	opMsg := wire >= mongoOpMsgWireVersion
+
+	if build, err := mongoCommand(conn, opMsg, bsonDoc("buildInfo", int32(1))); err == nil {
+		info.Version, _ = build["version"].(string)
+	}
+	list, err := mongoCommand(conn, opMsg, bsonDoc("listDatabases", int32(1), "nameOnly", true))
+	if err != nil {
+		return info, err
+	}
+	if !bsonOK(list) {
+		// Unauthorized, code 13, or requires authentication.
+		info.AuthRequired = true
+		return info, nil
+	}
+	info.AnonymousAccessAllowed = true
+	dbs, _ := list["databases"].([]any)
+	for _, db := range dbs {
+		if d, ok := db.(map[string]any); ok {
+			if name, ok := d["name"].(string); ok {
+				info.DatabaseList = append(info.DatabaseList, name)
+			}
+		}
+	}
+	s.flagAnonymous(info, port)
+	return info, nil
+}
+
+// mongoCommand runs the command cmd, a BSON document, against the admin
+// database of conn, over OP_MSG if opMsg is set and OP_QUERY otherwise,
+// and returns the reply document.
+func mongoCommand(conn net.Conn, opMsg bool, cmd []byte) (map[string]any, error) {
+	var body []byte
+	opcode := uint32(mongoOpQuery)
+	if opMsg {
+		opcode = mongoOpMsg
+		// The $db field is required in OP_MSG commands.
+		cmd = append(cmd[:len(cmd)-1], bsonElement("$db", "admin")...)
+		cmd = append(cmd, 0)
+		binary.LittleEndian.PutUint32(cmd, uint32(len(cmd)))
+		body = binary.LittleEndian.AppendUint32(nil, 0) // flags
+		body = append(body, 0)                          // body section
+		body = append(body, cmd...)
+	} else {
+		body = binary.LittleEndian.AppendUint32(nil, 0) // flags
+		body = append(body, "admin.$cmd\x00"...)
+		body = binary.LittleEndian.AppendUint32(body, 0) // skip
+		body = binary.LittleEndian.AppendUint32(body, 1) // return
+		body = append(body, cmd...)
+	}
+	msg := binary.LittleEndian.AppendUint32(nil, uint32(16+len(body)))
+	msg = binary.LittleEndian.AppendUint32(msg, 1) // request ID
+	msg = binary.LittleEndian.AppendUint32(msg, 0) // response to
+	msg = binary.LittleEndian.AppendUint32(msg, opcode)
+	if _, err := conn.Write(append(msg, body...)); err != nil {
+		return nil, err
+	}
+
+	var header [16]byte
+	if _, err := io.ReadFull(conn, header[:]); err != nil {
+		return nil, err
+	}
+	n := binary.LittleEndian.Uint32(header[:])
+	if n < 16 || n > 16<<20 {
+		return nil, ErrNotDatabase
+	}
+	reply := make([]byte, n-16)
+	if _, err := io.ReadFull(conn, reply); err != nil {
+		return nil, err
+	}
+	switch binary.LittleEndian.Uint32(header[12:]) {
+	case mongoOpReply:
+		// Flags, cursor ID, starting from and number returned.
+		if len(reply) < 20 {
+			return nil, ErrNotDatabase
+		}
+		reply = reply[20:]
+	case mongoOpMsg:
+		if len(reply) < 5 || reply[4] != 0 {
+			return nil, ErrNotDatabase
+		}
+		reply = reply[5:]
+	default:
+		return nil, ErrNotDatabase
+	}
+	doc, _, err := bsonDecode(reply)
+	return doc, err
+}
+
+// bsonDoc encodes a BSON document of the keys and values of kv, in order.
+// Values are strings, int32s or bools.
+func bsonDoc(kv ...any) []byte {
+	doc := []byte{0, 0, 0, 0}
+	for i := 0; i+1 < len(kv); i += 2 {
+		doc = append(doc, bsonElement(kv[i].(string), kv[i+1])...)
+	}
+	doc = append(doc, 0)
+	binary.LittleEndian.PutUint32(doc, uint32(len(doc)))
+	return doc
+}
+
+// bsonElement encodes the BSON element of key and value, a string, an
+// int32 or a bool.
+func bsonElement(key string, value any) []byte {
+	var e []byte
+	switch v := value.(type) {
+	case string:
+		e = append([]byte{0x02}, key...)
+		e = append(e, 0)
+		e = binary.LittleEndian.AppendUint32(e, uint32(len(v)+1))
+		e = append(e, v...)
+		e = append(e, 0)
+	case int32:
+		e = append([]byte{0x10}, key...)
+		e = append(e, 0)
+		e = binary.LittleEndian.AppendUint32(e, uint32(v))
+	case bool:
+		e = append([]byte{0x08}, key...)
+		e = append(e, 0)
+		if v {
+			e = append(e, 1)
+		} else {
+			e = append(e, 0)
+		}
+	}
+	return e
+}
+
+// bsonOK tells whether the reply doc of a command reports success, its ok
+// field being 1 as a double or, for some servers, an integer.
+func bsonOK(doc map[string]any) bool {
+	switch ok := doc["ok"].(type) {
+	case float64:
+		return ok == 1
+	case int32:
+		return ok == 1
+	case int64:
+		return ok == 1
+	}
+	return false
+}
+
+var errBSON = errors.New("mongodb: malformed BSON document")
+
+// bsonDecode decodes the BSON document at the start of b and returns it
+// with its size. Values of types MongoDBProbe does not need are decoded as
+// nil.
+func bsonDecode(b []byte) (map[string]any, int, error) {
+	if len(b) < 5 {
+		return nil, 0, errBSON
+	}
+	size := int(binary.LittleEndian.Uint32(b))
+	if size < 5 || size > len(b) {
+		return nil, 0, errBSON
+	}
+	doc := make(map[string]any)
+	for e := b[4 : size-1]; len(e) > 0; {
+		typ := e[0]
+		key, rest, ok := bytes.Cut(e[1:], []byte{0})
+		if !ok {
+			return nil, 0, errBSON
+		}
+		var value any
+		var n int
+		switch typ {
+		case 0x01: // double
+			n = 8
+			if len(rest) >= n {
+				value = math.Float64frombits(binary.LittleEndian.Uint64(rest))
+			}
+		case 0x02, 0x0d, 0x0e: // string, JavaScript, symbol
+			if len(rest) < 4 {
+				return nil, 0, errBSON
+			}
+			n = 4 + int(binary.LittleEndian.Uint32(rest))
+			if n > 4 && len(rest) >= n {
+				value = string(rest[4 : n-1])
+			}
+		case 0x03, 0x04: // document, array
+			sub, m, err := bsonDecode(rest)
+			if err != nil {
+				return nil, 0, err
+			}
+			n, value = m, sub
+			if typ == 0x04 {
+				array := make([]any, 0, len(sub))
+				for i := 0; ; i++ {
+					v, ok := sub[strconv.Itoa(i)]
+					if !ok {
+						break
+					}
+					array = append(array, v)
+				}
+				value = array
+			}
+		case 0x05: // binary
+			if len(rest) < 4 {
+				return nil, 0, errBSON
+			}
+			n = 5 + int(binary.LittleEndian.Uint32(rest))
+		case 0x06, 0x0a, 0x7f, 0xff: // undefined, null, max and min keys
+		case 0x07: // object ID
+			n = 12
+		case 0x08: // bool
+			n = 1
+			if len(rest) >= n {
+				value = rest[0] != 0
+			}
+		case 0x09, 0x11: // date, timestamp
+			n = 8
+		case 0x10: // int32
+			n = 4
+			if len(rest) >= n {
+				value = int32(binary.LittleEndian.Uint32(rest))
+			}
+		case 0x12: // int64
+			n = 8
+			if len(rest) >= n {
+				value = int64(binary.LittleEndian.Uint64(rest))
+			}
+		case 0x13: // decimal128
+			n = 16
+		default:
+			return nil, 0, errBSON
+		}
+		if n < 0 || len(rest) < n {
+			return nil, 0, errBSON
+		}
+		doc[string(key)] = value
+		e = rest[n:]
+	}
+	return doc, size, nil
+}
@@ -0,0 +1,91 @@
+package scanme
+
+import (
+	"encoding/binary"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/gopacket/layers"
+)
+
+const (
+	// mptcpOptionKind is the TCP option kind of Multipath TCP (RFC 8684).
+	mptcpOptionKind layers.TCPOptionKind = 30
+	// mptcpCapable is the MP_CAPABLE subtype, held in the high nibble of
+	// the first byte of the option data.
+	mptcpCapable = 0
+	// mptcpVersion is the MPTCP version advertised by the probes.
+	mptcpVersion = 1
+	// mptcpFlagHMACSHA256 is the H flag, selecting HMAC-SHA256, the only
+	// algorithm defined by RFC 8684.
+	mptcpFlagHMACSHA256 = 0x01
+)
+
+// mptcpCapableOption is the MP_CAPABLE option added to SYN probes by
+// WithMPTCPDetection. Servers only answer MP_CAPABLE to a SYN offering it.
+var mptcpCapableOption = layers.TCPOption{
+	OptionType:   mptcpOptionKind,
+	OptionLength: 4,
+	OptionData:   []byte{mptcpCapable<<4 | mptcpVersion, mptcpFlagHMACSHA256},
+}
+
+// parseMPTCPCapable looks for an MP_CAPABLE option in opts. It returns the
+// 64 bit key of the sender, zero when the option carries none, and whether
+// the option was found.
+func parseMPTCPCapable(opts []layers.TCPOption) (key uint64, ok bool) {
+	for _, opt := range opts {
+		if opt.OptionType != mptcpOptionKind || len(opt.OptionData) < 2 {
+			continue
+		}
+		if opt.OptionData[0]>>4 != mptcpCapable {
+			continue
+		}
+		// Subtype and version, flags, then the key of the sender in a
+		// SYN-ACK.
+		if len(opt.OptionData) >= 10 {
+			key = binary.BigEndian.Uint64(opt.OptionData[2:10])
+		}
+		return key, true
+	}
+	return 0, false
+}
+
+// setMPTCP records the MPTCP keys the receive loop found in SYN-ACKs on the
+// matching ports of r.
+func (r *ScanResult) setMPTCP(keys map[layers.TCPPort]uint64) {
+	for i := range r.Ports {
+		if key, ok := keys[r.Ports[i].Port]; ok {
+			r.Ports[i].MPTCP = true
+			r.Ports[i].MPTCPKey = key
+			r.MPTCPEnabled = true
+		}
+	}
+}
+
+// GrabMPTCPBanner connects to port on the target with Multipath TCP and
+// returns what the service sends upon connection, waiting at most timeout.
+// mptcp reports whether the connection actually uses MPTCP: the kernel
+// falls back to plain TCP when the server, or a middlebox on the way,
+// strips the MP_CAPABLE option. MPTCP connections require Linux 5.6 or
+// later; elsewhere they always fall back.
+func (s *scanner) GrabMPTCPBanner(port layers.TCPPort, timeout time.Duration) (banner string, mptcp bool, err error) {
+	var d net.Dialer
+	d.Timeout = timeout
+	d.SetMultipathTCP(true)
+	conn, err := d.Dial("tcp", net.JoinHostPort(s.dst.String(), strconv.Itoa(int(port))))
+	if err != nil {
+		return "", false, err
+	}
+	defer conn.Close()
+
+	if tcp, ok := conn.(*net.TCPConn); ok {
+		mptcp, _ = tcp.MultipathTCP()
+	}
+	banner, err = readBanner(conn, timeout)
+	if err != nil {
+		return "", mptcp, err
+	}
+	return strings.Trim(banner, "\r\n\t "), mptcp, nil
+}
@@ -0,0 +1,177 @@
+package scanme
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"strconv"
+
+	"github.com/google/gopacket/layers"
+)
+
+// The capability flags of the handshake response of MySQLProbe.
+const (
+	mysqlLongPassword     = 0x00000001
+	mysqlProtocol41       = 0x00000200
+	mysqlSecureConnection = 0x00008000
+	mysqlPluginAuth       = 0x00080000
+)
+
+// MySQLProbe reads the version of the MySQL or MariaDB server on port of
+// the target from its handshake, then logs in as the anonymous user with an
+// empty password. When the server accepts, its databases are listed with
+// SHOW DATABASES.
+func (s *scanner) MySQLProbe(ctx context.Context, port layers.TCPPort) (*DatabaseInfo, error) {
+	ctx, cancel := withDatabaseTimeout(ctx)
+	defer cancel()
+	conn, err := dialICS(ctx, s.dst, strconv.Itoa(int(port)))
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	handshake, _, err := readMySQLPacket(conn)
+	if err != nil || len(handshake) == 0 {
+		return nil, ErrNotDatabase
+	}
+	info := &DatabaseInfo{Type: DatabaseMySQL}
+	switch handshake[0] {
+	case 10: // protocol version
+	case 0xff:
+		// Servers refusing the host of the scanner, such as with "Host is
+		// not allowed to connect", send an error instead of a handshake.
+		if len(handshake) < 3 {
+			return nil, ErrNotDatabase
+		}
+		info.AuthRequired = true
+		return info, nil
+	default:
+		return nil, ErrNotDatabase
+	}
+	version, _, ok := bytes.Cut(handshake[1:], []byte{0})
+	if !ok {
+		return nil, ErrNotDatabase
+	}
+	info.Version = string(version)
+
+	login := binary.LittleEndian.AppendUint32(nil, mysqlLongPassword|mysqlProtocol41|mysqlSecureConnection|mysqlPluginAuth)
+	login = binary.LittleEndian.AppendUint32(login, 1<<24) // max packet size
+	login = append(login, 33)                              // utf8 charset
+	login = append(login, make([]byte, 23)...)
+	login = append(login, 0) // empty user name
+	login = append(login, 0) // empty auth response
+	login = append(login, "mysql_native_password\x00"...)
+	if err := writeMySQLPacket(conn, 1, login); err != nil {
+		return info, err
+	}
+	reply, _, err := readMySQLPacket(conn)
+	if err != nil || len(reply) == 0 {
+		return info, err
+	}
+	if reply[0] != 0x00 {
+		// An error, or a switch to another authentication method, which
+		// a password-less account would not need.
+		info.AuthRequired = true
+		return info, nil
+	}
+	info.AnonymousAccessAllowed = true
+	s.flagAnonymous(info, port)
+
+	query := append([]byte{0x03}, "SHOW DATABASES"...) // COM_QUERY
+	if err := writeMySQLPacket(conn, 0, query); err != nil {
+		return info, nil
+	}
+	info.DatabaseList, _ = readMySQLColumn(conn)
+	return info, nil
+}
+
+// readMySQLPacket reads a packet of the MySQL protocol from conn and returns
+// its payload and sequence number.
+func readMySQLPacket(conn net.Conn) ([]byte, byte, error) {
+	var header [4]byte
+	if _, err := io.ReadFull(conn, header[:]); err != nil {
+		return nil, 0, err
+	}
+	n := int(header[0]) | int(header[1])<<8 | int(header[2])<<16
+	if n > 1<<20 {
+		return nil, 0, ErrNotDatabase
+	}
+	payload := make([]byte, n)
+	if _, err := io.ReadFull(conn, payload); err != nil {
+		return nil, 0, err
+	}
+	return payload, header[3], nil
+}
+
+// writeMySQLPacket sends payload as the packet seq of the MySQL protocol.
+func writeMySQLPacket(conn net.Conn, seq byte, payload []byte) error {
+	n := len(payload)
+	packet := append([]byte{byte(n), byte(n >> 8), byte(n >> 16), seq}, payload...)
+	_, err := conn.Write(packet)
+	return err
+}
+
+// readMySQLColumn reads the result set of a query returning a single
+// column, such as SHOW DATABASES, and returns its values.
+func readMySQLColumn(conn net.Conn) ([]string, error) {
+	header, _, err := readMySQLPacket(conn)
+	if err != nil {
+		return nil, err
+	}
+	if len(header) == 0 || header[0] == 0xff {
+		return nil, errors.New("mysql: query failed")
+	}
+	// The column definitions, then an EOF packet.
+	for {
+		p, _, err := readMySQLPacket(conn)
+		if err != nil {
+			return nil, err
+		}
+		if len(p) > 0 && len(p) < 9 && p[0] == 0xfe {
+			break
+		}
+	}
+	var values []string
+	for {
+		row, _, err := readMySQLPacket(conn)
+		if err != nil {
+			return values, err
+		}
+		if len(row) == 0 || row[0] == 0xff || (row[0] == 0xfe && len(row) < 9) {
+			return values, nil
+		}
+		value, err := mysqlLenencString(row)
+		if err != nil {
+			return values, err
+		}
+		values = append(values, value)
+	}
+}
+
+// mysqlLenencString decodes the length-encoded string at the start of b.
+func mysqlLenencString(b []byte) (string, error) {
+	n, size := uint64(b[0]), 1
+	switch b[0] {
+	case 0xfc:
+		size = 3
+	case 0xfd:
+		size = 4
+	case 0xfe:
+		size = 9
+	}
+	if len(b) < size {
+		return "", errors.New("mysql: truncated row")
+	}
+	if size > 1 {
+		var buf [8]byte
+		copy(buf[:], b[1:size])
+		n = binary.LittleEndian.Uint64(buf[:])
+	}
+	if uint64(len(b)-size) < n {
+		return "", errors.New("mysql: truncated row")
+	}
+	return string(b[size : size+int(n)]), nil
+}
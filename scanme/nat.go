@@ -0,0 +1,70 @@
+package scanme
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// publicIPURL is an IP echo service answering with the address of the client
+// in plain text.
+const publicIPURL = "https://api.ipify.org"
+
+// natTimeout bounds the public IP lookup when ctx has no deadline.
+const natTimeout = 10 * time.Second
+
+// NATInfo tells whether the scanner is behind a NAT.
+type NATInfo struct {
+	IsNAT bool
+	// PublicIP is the address the scanner appears from on the Internet,
+	// PrivateIP the source address of its probes.
+	PublicIP  net.IP
+	PrivateIP net.IP
+}
+
+// DetectNAT finds the public address of the scanner with an IP echo service
+// and compares it with the source address of its probes. Behind a NAT, the
+// target sees the public address, and replies to SYN probes sent from raw
+// sockets may not be translated back to the scanner. This sends a request
+// to a third party.
+func (s *scanner) DetectNAT(ctx context.Context) (*NATInfo, error) {
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, natTimeout)
+		defer cancel()
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, publicIPURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("public IP lookup: unexpected status %s", resp.Status)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 64))
+	if err != nil {
+		return nil, err
+	}
+	public := net.ParseIP(strings.TrimSpace(string(body)))
+	if public == nil {
+		return nil, fmt.Errorf("public IP lookup: invalid address %q", body)
+	}
+
+	info := &NATInfo{
+		IsNAT:     !public.Equal(s.src),
+		PublicIP:  public,
+		PrivateIP: s.src,
+	}
+	if info.IsNAT {
+		s.logger.Warn("scanning behind a NAT, SYN scan replies may not be routed back", "src", s.src, "public_ip", public)
+	}
+	return info, nil
+}
@@ -0,0 +1,177 @@
+// Package net iterates over the host addresses of networks too large to be
+// held in memory, such as IPv4 /8 blocks, in order or in random order.
+package net
+
+import (
+	"context"
+	"fmt"
+	"math/bits"
+	"math/rand"
+	"net"
+)
+
+// maxHostBits bounds the size of an IPRange to that of the whole IPv4
+// address space.
+const maxHostBits = 32
+
+// IPRange iterates over the host addresses of a CIDR block without storing
+// them. For IPv4 blocks larger than /31, the network and broadcast addresses
+// are skipped. An IPRange is not safe for concurrent use, except for Iter
+// and RandomIter which keep their own position.
+type IPRange struct {
+	ipnet *net.IPNet
+	first net.IP
+	n     uint64
+	next  uint64
+}
+
+// NewIPRange returns an iterator over the host addresses of cidr, such as
+// "10.0.0.0/8". IPv6 blocks are limited to 2^32 addresses.
+func NewIPRange(cidr string) (*IPRange, error) {
+	_, ipnet, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return nil, err
+	}
+	ones, size := ipnet.Mask.Size()
+	hostBits := size - ones
+	if hostBits > maxHostBits {
+		return nil, fmt.Errorf("%s holds more than 2^%d addresses", cidr, maxHostBits)
+	}
+
+	first := ipnet.IP.Mask(ipnet.Mask)
+	if size == 32 {
+		first = first.To4()
+	}
+	n := uint64(1) << hostBits
+	if size == 32 && hostBits > 1 {
+		first = addIP(first, 1)
+		n -= 2
+	}
+	return &IPRange{ipnet: ipnet, first: first, n: n}, nil
+}
+
+// Next returns the next address of the range, and false once all of them
+// have been returned.
+func (r *IPRange) Next() (net.IP, bool) {
+	if r.next >= r.n {
+		return nil, false
+	}
+	ip := addIP(r.first, r.next)
+	r.next++
+	return ip, true
+}
+
+// Len returns the number of addresses in the range.
+func (r *IPRange) Len() int {
+	return int(r.n)
+}
+
+// Reset makes Next start over from the first address.
+func (r *IPRange) Reset() {
+	r.next = 0
+}
+
+// Contains reports whether ip is one of the addresses of the range.
+func (r *IPRange) Contains(ip net.IP) bool {
+	if !r.ipnet.Contains(ip) {
+		return false
+	}
+	offset, ok := subIP(ip, r.first)
+	return ok && offset < r.n
+}
+
+// Iter sends every address of the range, in order, on the returned channel,
+// which is closed once all have been sent or ctx is done. It does not move
+// the position of Next.
+func (r *IPRange) Iter(ctx context.Context) <-chan net.IP {
+	ips := make(chan net.IP)
+	go func() {
+		defer close(ips)
+		for i := uint64(0); i < r.n; i++ {
+			select {
+			case ips <- addIP(r.first, i):
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return ips
+}
+
+// RandomIter is like Iter but sends the addresses in a random order derived
+// from seed, so that consecutive probes hit different parts of the network.
+// Every address is sent exactly once. The order comes from a full-period
+// linear congruential generator over the next power of two, skipping the
+// values past the end of the range, so no permutation is ever stored.
+func (r *IPRange) RandomIter(ctx context.Context, seed int64) <-chan net.IP {
+	ips := make(chan net.IP)
+	go func() {
+		defer close(ips)
+		if r.n == 0 {
+			return
+		}
+		// x' = (a*x + c) mod m has period m when m is a power of two,
+		// c is odd and a-1 is a multiple of 4 (Hull-Dobell theorem).
+		m := uint64(1) << bits.Len64(r.n-1)
+		rng := rand.New(rand.NewSource(seed))
+		a := 4*rng.Uint64() + 1
+		c := 2*rng.Uint64() + 1
+		x := rng.Uint64() & (m - 1)
+		for sent := uint64(0); sent < r.n; {
+			x = (a*x + c) & (m - 1)
+			if x >= r.n {
+				continue
+			}
+			select {
+			case ips <- addIP(r.first, x):
+				sent++
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return ips
+}
+
+// addIP returns ip + offset.
+func addIP(ip net.IP, offset uint64) net.IP {
+	out := make(net.IP, len(ip))
+	carry := offset
+	for i := len(ip) - 1; i >= 0; i-- {
+		sum := uint64(ip[i]) + carry&0xff
+		out[i] = byte(sum)
+		carry = carry>>8 + sum>>8
+	}
+	return out
+}
+
+// subIP returns ip - base, and false when ip is lower than base or too far
+// above it.
+func subIP(ip, base net.IP) (uint64, bool) {
+	if len(base) == net.IPv4len {
+		ip = ip.To4()
+	} else {
+		ip = ip.To16()
+	}
+	if ip == nil {
+		return 0, false
+	}
+	var diff uint64
+	var borrow int
+	for i := len(ip) - 1; i >= 0; i-- {
+		d := int(ip[i]) - int(base[i]) - borrow
+		borrow = 0
+		if d < 0 {
+			d += 256
+			borrow = 1
+		}
+		shift := uint(len(ip)-1-i) * 8
+		if d != 0 && shift >= 64 {
+			return 0, false
+		}
+		if shift < 64 {
+			diff |= uint64(d) << shift
+		}
+	}
+	return diff, borrow == 0
+}
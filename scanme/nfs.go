@@ -0,0 +1,268 @@
+package scanme
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"time"
+)
+
+// nfsTimeout bounds NFSProbe when ctx has no deadline.
+const nfsTimeout = 10 * time.Second
+
+// The ONC RPC programs NFSProbe calls.
+const (
+	rpcPortmapper = 100000
+	rpcNFS        = 100003
+	rpcMount      = 100005
+)
+
+// The ports of the portmapper and of NFS, the latter used when the
+// portmapper does not tell.
+const (
+	portmapperPort = "111"
+	nfsPort        = "2049"
+)
+
+// ErrNotNFS is returned by NFSProbe when no NFS server answers on the
+// target.
+var ErrNotNFS = errors.New("not an NFS service")
+
+// NFSExport is a file system exported by an NFS server.
+type NFSExport struct {
+	Path string
+	// AllowedHosts are the hosts, networks and netgroups allowed to mount
+	// the export; "*" when the server allows every host.
+	AllowedHosts []string
+	// Options are the export options, which the MOUNT protocol does not
+	// report: NFSProbe leaves them empty.
+	Options string
+}
+
+// WorldAccessible tells whether every host may mount e.
+func (e NFSExport) WorldAccessible() bool {
+	for _, h := range e.AllowedHosts {
+		if h == "*" || h == "0.0.0.0/0" || h == "::/0" || h == "(everyone)" {
+			return true
+		}
+	}
+	return false
+}
+
+// NFSInfo describes the NFS server of the target.
+type NFSInfo struct {
+	// Version is the highest version of NFS the server answers, among 2,
+	// 3 and 4.
+	Version uint32
+	// Exports are the file systems the server exports, as listed by its
+	// mount daemon; NFSv4-only servers list none.
+	Exports []NFSExport
+}
+
+// NFSProbe finds the NFS server of the target and lists its exports. The
+// ports of NFS and of the mount daemon are asked to the portmapper on TCP
+// port 111, NFS defaulting to port 2049 without one. The NFS version is
+// found with RPC NULL calls, and the exports with the EXPORT procedure of
+// the MOUNT program (100005, version 3, procedure 5). Exports any host may
+// mount are logged as warnings, see NFSExport.WorldAccessible.
+func (s *scanner) NFSProbe(ctx context.Context) (*NFSInfo, error) {
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, nfsTimeout)
+		defer cancel()
+	}
+
+	port, mountPort := nfsPort, ""
+	if p, err := s.rpcGetPort(ctx, rpcNFS, 3); err == nil && p != 0 {
+		port = strconv.Itoa(int(p))
+	}
+	if p, err := s.rpcGetPort(ctx, rpcMount, 3); err == nil && p != 0 {
+		mountPort = strconv.Itoa(int(p))
+	}
+
+	info := &NFSInfo{}
+	for _, version := range []uint32{4, 3, 2} {
+		if _, err := s.rpcCall(ctx, port, rpcNFS, version, 0, nil); err == nil {
+			info.Version = version
+			break
+		}
+	}
+	if info.Version == 0 {
+		return nil, ErrNotNFS
+	}
+	if mountPort == "" {
+		return info, nil
+	}
+
+	reply, err := s.rpcCall(ctx, mountPort, rpcMount, 3, 5, nil)
+	if err != nil {
+		return info, err
+	}
+	if info.Exports, err = parseNFSExports(reply); err != nil {
+		return info, err
+	}
+	for _, e := range info.Exports {
+		if e.WorldAccessible() {
+			s.logger.Warn("NFS export accessible to every host", "target", s.dst, "path", e.Path)
+		}
+	}
+	return info, nil
+}
+
+// rpcGetPort asks the portmapper of the target for the TCP port of version
+// of program, zero when it is not registered.
+func (s *scanner) rpcGetPort(ctx context.Context, program, version uint32) (uint32, error) {
+	args := binary.BigEndian.AppendUint32(nil, program)
+	args = binary.BigEndian.AppendUint32(args, version)
+	args = binary.BigEndian.AppendUint32(args, 6) // TCP
+	args = binary.BigEndian.AppendUint32(args, 0)
+	reply, err := s.rpcCall(ctx, portmapperPort, rpcPortmapper, 2, 3, args)
+	if err != nil {
+		return 0, err
+	}
+	if len(reply) < 4 {
+		return 0, errors.New("rpc: truncated GETPORT reply")
+	}
+	return binary.BigEndian.Uint32(reply), nil
+}
+
+// rpcCall calls procedure of version of program over TCP on port of the
+// target with the XDR encoded args, without credentials, and returns the
+// XDR encoded results.
+func (s *scanner) rpcCall(ctx context.Context, port string, program, version, procedure uint32, args []byte) ([]byte, error) {
+	conn, err := dialICS(ctx, s.dst, port)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	const xid = 0x5ca11ed
+	call := binary.BigEndian.AppendUint32(nil, 0) // record mark, set below
+	for _, v := range []uint32{
+		xid,
+		0, // CALL
+		2, // RPC version
+		program, version, procedure,
+		0, 0, // AUTH_NULL credentials
+		0, 0, // AUTH_NULL verifier
+	} {
+		call = binary.BigEndian.AppendUint32(call, v)
+	}
+	call = append(call, args...)
+	binary.BigEndian.PutUint32(call, 0x80000000|uint32(len(call)-4))
+	if _, err := conn.Write(call); err != nil {
+		return nil, err
+	}
+
+	reply, err := readRPCRecord(conn)
+	if err != nil {
+		return nil, err
+	}
+	if len(reply) < 12 || binary.BigEndian.Uint32(reply) != xid || binary.BigEndian.Uint32(reply[4:]) != 1 {
+		return nil, errors.New("rpc: malformed reply")
+	}
+	if binary.BigEndian.Uint32(reply[8:]) != 0 {
+		return nil, errors.New("rpc: call denied")
+	}
+	// The verifier, then the acceptance status.
+	reply = reply[12:]
+	if len(reply) < 8 {
+		return nil, errors.New("rpc: malformed reply")
+	}
+	n := binary.BigEndian.Uint32(reply[4:])
+	if uint32(len(reply)-8) < (n+3)&^3+4 {
+		return nil, errors.New("rpc: malformed reply")
+	}
+	reply = reply[8+(n+3)&^3:]
+	if status := binary.BigEndian.Uint32(reply); status != 0 {
+		return nil, fmt.Errorf("rpc: call not accepted, status %d", status)
+	}
+	return reply[4:], nil
+}
+
+// readRPCRecord reads the fragments of an RPC record from conn.
+func readRPCRecord(conn net.Conn) ([]byte, error) {
+	var record []byte
+	for {
+		var mark [4]byte
+		if _, err := io.ReadFull(conn, mark[:]); err != nil {
+			return nil, err
+		}
+		n := binary.BigEndian.Uint32(mark[:]) & 0x7fffffff
+		if len(record)+int(n) > 1<<20 {
+			return nil, errors.New("rpc: record too large")
+		}
+		fragment := make([]byte, n)
+		if _, err := io.ReadFull(conn, fragment); err != nil {
+			return nil, err
+		}
+		record = append(record, fragment...)
+		if mark[0]&0x80 != 0 {
+			return record, nil
+		}
+	}
+}
+
+// parseNFSExports parses the exports list of a MOUNTPROC3_EXPORT reply. An
+// export without groups is exported to every host, it is reported as "*".
+func parseNFSExports(b []byte) ([]NFSExport, error) {
+	var exports []NFSExport
+	for {
+		follows, rest, err := xdrUint32(b)
+		if err != nil {
+			return exports, err
+		}
+		if follows == 0 {
+			return exports, nil
+		}
+		var e NFSExport
+		if e.Path, rest, err = xdrString(rest); err != nil {
+			return exports, err
+		}
+		for {
+			if follows, rest, err = xdrUint32(rest); err != nil {
+				return exports, err
+			}
+			if follows == 0 {
+				break
+			}
+			var group string
+			if group, rest, err = xdrString(rest); err != nil {
+				return exports, err
+			}
+			e.AllowedHosts = append(e.AllowedHosts, group)
+		}
+		if len(e.AllowedHosts) == 0 {
+			e.AllowedHosts = []string{"*"}
+		}
+		exports = append(exports, e)
+		b = rest
+	}
+}
+
+var errXDR = errors.New("rpc: truncated XDR data")
+
+// xdrUint32 decodes the XDR unsigned integer at the start of b.
+func xdrUint32(b []byte) (uint32, []byte, error) {
+	if len(b) < 4 {
+		return 0, nil, errXDR
+	}
+	return binary.BigEndian.Uint32(b), b[4:], nil
+}
+
+// xdrString decodes the XDR string at the start of b.
+func xdrString(b []byte) (string, []byte, error) {
+	n, b, err := xdrUint32(b)
+	if err != nil {
+		return "", nil, err
+	}
+	padded := (n + 3) &^ 3
+	if uint32(len(b)) < padded {
+		return "", nil, errXDR
+	}
+	return string(b[:n]), b[padded:], nil
+}
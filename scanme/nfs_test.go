@@ -0,0 +1,169 @@
+package scanme
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"net"
+	"slices"
+	"testing"
+)
+
+// xdrStrings encodes the XDR strings of s.
+func xdrStrings(s ...string) []byte {
+	var b []byte
+	for _, v := range s {
+		b = binary.BigEndian.AppendUint32(b, uint32(len(v)))
+		b = append(b, v...)
+		b = append(b, make([]byte, (4-len(v)%4)%4)...)
+	}
+	return b
+}
+
+// exportsReply encodes the MOUNTPROC3_EXPORT reply listing exports.
+func exportsReply(exports ...NFSExport) []byte {
+	var b []byte
+	for _, e := range exports {
+		b = binary.BigEndian.AppendUint32(b, 1)
+		b = append(b, xdrStrings(e.Path)...)
+		for _, h := range e.AllowedHosts {
+			b = binary.BigEndian.AppendUint32(b, 1)
+			b = append(b, xdrStrings(h)...)
+		}
+		b = binary.BigEndian.AppendUint32(b, 0)
+	}
+	return binary.BigEndian.AppendUint32(b, 0)
+}
+
+// rpcServer returns a handler answering the RPC calls of a connection with
+// the acceptance status and results returned by call. Replies are split in
+// two record fragments.
+func rpcServer(call func(program, version, procedure uint32, args []byte) (uint32, []byte)) func(net.Conn) {
+	return func(conn net.Conn) {
+		for {
+			record, err := readRPCRecord(conn)
+			if err != nil || len(record) < 40 {
+				return
+			}
+			field := func(i int) uint32 { return binary.BigEndian.Uint32(record[4*i:]) }
+			status, results := call(field(3), field(4), field(5), record[40:])
+			reply := binary.BigEndian.AppendUint32(nil, field(0))
+			for _, v := range []uint32{1, 0, 0, 0, status} { // REPLY, MSG_ACCEPTED, AUTH_NULL verifier
+				reply = binary.BigEndian.AppendUint32(reply, v)
+			}
+			reply = append(reply, results...)
+			half := len(reply) / 2
+			conn.Write(binary.BigEndian.AppendUint32(nil, uint32(half)))
+			conn.Write(reply[:half])
+			conn.Write(binary.BigEndian.AppendUint32(nil, 0x80000000|uint32(len(reply)-half)))
+			conn.Write(reply[half:])
+		}
+	}
+}
+
+// nfsServer returns the handler of an NFS server of version, and of its
+// mount daemon listing exports.
+func nfsServer(version uint32, exports []byte) func(net.Conn) {
+	return rpcServer(func(program, v, procedure uint32, _ []byte) (uint32, []byte) {
+		switch {
+		case program == rpcNFS && v == version && procedure == 0:
+			return 0, nil
+		case program == rpcNFS:
+			return 2, binary.BigEndian.AppendUint32(binary.BigEndian.AppendUint32(nil, version), version) // PROG_MISMATCH
+		case program == rpcMount && v == 3 && procedure == 5:
+			return 0, exports
+		}
+		return 1, nil // PROG_UNAVAIL
+	})
+}
+
+// portmapper serves the portmapper on port 111 of 127.0.0.1, where NFSProbe
+// asks for the ports of NFS and of the mount daemon, which needs root.
+func portmapper(t *testing.T, ports map[uint32]uint32) {
+	testServer(t, "127.0.0.1:"+portmapperPort, rpcServer(func(program, version, procedure uint32, args []byte) (uint32, []byte) {
+		if program != rpcPortmapper || version != 2 || procedure != 3 || len(args) != 16 {
+			return 1, nil
+		}
+		return 0, binary.BigEndian.AppendUint32(nil, ports[binary.BigEndian.Uint32(args)])
+	}))
+}
+
+func TestNFSProbe(t *testing.T) {
+	exports := []NFSExport{
+		{Path: "/export/home", AllowedHosts: []string{"10.0.0.0/24", "@admins"}},
+		{Path: "/srv/public"},
+		{Path: "/srv/backup", AllowedHosts: []string{"*"}},
+	}
+	port := testServer(t, "127.0.0.1:0", nfsServer(3, exportsReply(exports...)))
+	portmapper(t, map[uint32]uint32{rpcNFS: uint32(port), rpcMount: uint32(port)})
+
+	s := newTestScanner()
+	s.dst = net.IPv4(127, 0, 0, 1)
+	info, err := s.NFSProbe(context.Background())
+	if err != nil {
+		t.Fatalf("NFSProbe() error = %v", err)
+	}
+	if info.Version != 3 {
+		t.Errorf("Version = %d, want 3", info.Version)
+	}
+	want := []struct {
+		path  string
+		hosts []string
+		world bool
+	}{
+		{"/export/home", []string{"10.0.0.0/24", "@admins"}, false},
+		{"/srv/public", []string{"*"}, true},
+		{"/srv/backup", []string{"*"}, true},
+	}
+	if len(info.Exports) != len(want) {
+		t.Fatalf("Exports = %+v, want %d exports", info.Exports, len(want))
+	}
+	for i, w := range want {
+		e := info.Exports[i]
+		if e.Path != w.path || !slices.Equal(e.AllowedHosts, w.hosts) || e.WorldAccessible() != w.world {
+			t.Errorf("Exports[%d] = %+v, world accessible %v, want %s %v %v", i, e, e.WorldAccessible(), w.path, w.hosts, w.world)
+		}
+	}
+}
+
+func TestNFSProbeWithoutMountd(t *testing.T) {
+	port := testServer(t, "127.0.0.1:0", nfsServer(4, nil))
+	portmapper(t, map[uint32]uint32{rpcNFS: uint32(port)})
+	s := newTestScanner()
+	s.dst = net.IPv4(127, 0, 0, 1)
+	info, err := s.NFSProbe(context.Background())
+	if err != nil {
+		t.Fatalf("NFSProbe() error = %v", err)
+	}
+	if info.Version != 4 || info.Exports != nil {
+		t.Errorf("NFSProbe() = %+v, want version 4 without exports", info)
+	}
+}
+
+func TestNFSProbeNotNFS(t *testing.T) {
+	port := testServer(t, "127.0.0.1:0", rpcServer(func(uint32, uint32, uint32, []byte) (uint32, []byte) {
+		return 1, nil
+	}))
+	portmapper(t, map[uint32]uint32{rpcNFS: uint32(port)})
+	s := newTestScanner()
+	s.dst = net.IPv4(127, 0, 0, 1)
+	if _, err := s.NFSProbe(context.Background()); !errors.Is(err, ErrNotNFS) {
+		t.Errorf("NFSProbe() error = %v, want ErrNotNFS", err)
+	}
+}
+
+func TestParseNFSExports(t *testing.T) {
+	reply := exportsReply(NFSExport{Path: "/a", AllowedHosts: []string{"host1"}}, NFSExport{Path: "/bb"})
+	exports, err := parseNFSExports(reply)
+	if err != nil || len(exports) != 2 || exports[0].Path != "/a" || exports[1].AllowedHosts[0] != "*" {
+		t.Fatalf("parseNFSExports() = %+v, %v", exports, err)
+	}
+	for i := 0; i < len(reply); i += 4 {
+		if _, err := parseNFSExports(reply[:i]); !errors.Is(err, errXDR) {
+			t.Errorf("parseNFSExports() of the first %d bytes: error = %v, want errXDR", i, err)
+		}
+	}
+	if exports, err := parseNFSExports([]byte{0, 0, 0, 0}); err != nil || exports != nil {
+		t.Errorf("parseNFSExports() of an empty list = %+v, %v", exports, err)
+	}
+}
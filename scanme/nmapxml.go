@@ -0,0 +1,201 @@
+package scanme
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/CyberRoute/scanme/version"
+	"github.com/google/gopacket/layers"
+)
+
+// The nmap* types map the subset of the nmap XML output format (see
+// https://nmap.org/book/nmap-dtd.html) needed to represent a ScanResult.
+type nmapRun struct {
+	XMLName          xml.Name     `xml:"nmaprun"`
+	Scanner          string       `xml:"scanner,attr"`
+	Args             string       `xml:"args,attr,omitempty"`
+	Start            int64        `xml:"start,attr"`
+	StartStr         string       `xml:"startstr,attr,omitempty"`
+	Version          string       `xml:"version,attr"`
+	XMLOutputVersion string       `xml:"xmloutputversion,attr"`
+	Hosts            []nmapHost   `xml:"host"`
+	RunStats         nmapRunStats `xml:"runstats"`
+}
+
+type nmapHost struct {
+	StartTime int64         `xml:"starttime,attr,omitempty"`
+	EndTime   int64         `xml:"endtime,attr,omitempty"`
+	Status    nmapStatus    `xml:"status"`
+	Addresses []nmapAddress `xml:"address"`
+	Ports     nmapPorts     `xml:"ports"`
+}
+
+type nmapStatus struct {
+	State  string `xml:"state,attr"`
+	Reason string `xml:"reason,attr"`
+}
+
+type nmapAddress struct {
+	Addr     string `xml:"addr,attr"`
+	AddrType string `xml:"addrtype,attr"`
+}
+
+type nmapPorts struct {
+	Ports []nmapPort `xml:"port"`
+}
+
+type nmapPort struct {
+	Protocol string       `xml:"protocol,attr"`
+	PortID   int          `xml:"portid,attr"`
+	State    nmapState    `xml:"state"`
+	Service  *nmapService `xml:"service"`
+}
+
+type nmapState struct {
+	State  string `xml:"state,attr"`
+	Reason string `xml:"reason,attr"`
+}
+
+type nmapService struct {
+	Name   string `xml:"name,attr"`
+	Method string `xml:"method,attr"`
+	Conf   int    `xml:"conf,attr"`
+}
+
+type nmapRunStats struct {
+	Finished nmapFinished `xml:"finished"`
+	Hosts    nmapHosts    `xml:"hosts"`
+}
+
+type nmapFinished struct {
+	Time    int64  `xml:"time,attr"`
+	TimeStr string `xml:"timestr,attr,omitempty"`
+	Elapsed string `xml:"elapsed,attr"`
+	Exit    string `xml:"exit,attr"`
+}
+
+type nmapHosts struct {
+	Up    int `xml:"up,attr"`
+	Down  int `xml:"down,attr"`
+	Total int `xml:"total,attr"`
+}
+
+// nmapReasons maps port states to the reason nmap reports for a SYN scan.
+var nmapReasons = map[string]string{
+	"open":     "syn-ack",
+	"closed":   "reset",
+	"filtered": "no-response",
+}
+
+// WriteNmapXML writes result as an nmap XML document (the format produced by
+// nmap -oX), so that it can be consumed by tools of the nmap ecosystem.
+func WriteNmapXML(w io.Writer, result *ScanResult) error {
+	addrType := "ipv4"
+	if result.Target.To4() == nil {
+		addrType = "ipv6"
+	}
+
+	host := nmapHost{
+		StartTime: result.StartTime.Unix(),
+		EndTime:   result.EndTime.Unix(),
+		Status:    nmapStatus{State: "up", Reason: "user-set"},
+		Addresses: []nmapAddress{{Addr: result.Target.String(), AddrType: addrType}},
+	}
+	for _, p := range result.Ports {
+		port := nmapPort{
+			Protocol: "tcp",
+			PortID:   int(p.Port),
+			State:    nmapState{State: p.State, Reason: nmapReasons[p.State]},
+		}
+		if p.Service != "" {
+			port.Service = &nmapService{Name: p.Service, Method: "table", Conf: 3}
+		}
+		host.Ports.Ports = append(host.Ports.Ports, port)
+	}
+
+	run := nmapRun{
+		Scanner:          "scanme",
+		Start:            result.StartTime.Unix(),
+		StartStr:         result.StartTime.Format(time.ANSIC),
+		Version:          version.Version,
+		XMLOutputVersion: "1.05",
+		Hosts:            []nmapHost{host},
+		RunStats: nmapRunStats{
+			Finished: nmapFinished{
+				Time:    result.EndTime.Unix(),
+				TimeStr: result.EndTime.Format(time.ANSIC),
+				Elapsed: strconv.FormatFloat(result.EndTime.Sub(result.StartTime).Seconds(), 'f', 2, 64),
+				Exit:    "success",
+			},
+			Hosts: nmapHosts{Up: 1, Total: 1},
+		},
+	}
+
+	if _, err := io.WriteString(w, xml.Header+"<!DOCTYPE nmaprun>\n"); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(run); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// ReadNmapXML imports the first host of an nmap XML document, such as one
+// written by nmap -oX or WriteNmapXML. Only TCP ports are imported.
+func ReadNmapXML(r io.Reader) (*ScanResult, error) {
+	var run nmapRun
+	if err := xml.NewDecoder(r).Decode(&run); err != nil {
+		return nil, err
+	}
+	if len(run.Hosts) == 0 {
+		return nil, errors.New("nmap xml: no host in document")
+	}
+	host := run.Hosts[0]
+
+	result := &ScanResult{}
+	for _, addr := range host.Addresses {
+		if addr.AddrType == "ipv4" || addr.AddrType == "ipv6" {
+			result.Target = net.ParseIP(addr.Addr)
+			break
+		}
+	}
+	if result.Target == nil {
+		return nil, errors.New("nmap xml: host has no IP address")
+	}
+
+	start, end := host.StartTime, host.EndTime
+	if start == 0 {
+		start = run.Start
+	}
+	if end == 0 {
+		end = run.RunStats.Finished.Time
+	}
+	result.StartTime = time.Unix(start, 0)
+	result.EndTime = time.Unix(end, 0)
+	result.Stats.Duration = result.EndTime.Sub(result.StartTime)
+
+	for _, p := range host.Ports.Ports {
+		if p.Protocol != "tcp" {
+			continue
+		}
+		if p.PortID < 1 || p.PortID > 65535 {
+			return nil, fmt.Errorf("nmap xml: invalid port %d", p.PortID)
+		}
+		port := PortResult{Port: layers.TCPPort(p.PortID), State: p.State.State}
+		if p.Service != nil {
+			port.Service = p.Service.Name
+		}
+		result.Ports = append(result.Ports, port)
+	}
+	result.sortPorts()
+
+	return result, nil
+}
@@ -0,0 +1,127 @@
+package scanme
+
+import (
+	"bytes"
+	"net"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNmapXMLRoundTrip(t *testing.T) {
+	start := time.Unix(1709633521, 0)
+	tests := []struct {
+		name   string
+		result *ScanResult
+	}{
+		{"ipv4", &ScanResult{
+			Target:    net.ParseIP("192.0.2.10").To4(),
+			StartTime: start,
+			EndTime:   start.Add(3 * time.Second),
+			Ports: []PortResult{
+				{Port: 22, State: "open", Service: "ssh"},
+				{Port: 80, State: "open", Service: "http"},
+				{Port: 113, State: "closed"},
+				{Port: 8080, State: "filtered", Service: "http-proxy"},
+			},
+		}},
+		{"ipv6", &ScanResult{
+			Target:    net.ParseIP("2001:db8::10"),
+			StartTime: start,
+			EndTime:   start.Add(time.Minute),
+			Ports:     []PortResult{{Port: 443, State: "open", Service: "https"}},
+		}},
+		{"no ports", &ScanResult{
+			Target:    net.ParseIP("198.51.100.1").To4(),
+			StartTime: start,
+			EndTime:   start,
+		}},
+	}
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		if err := WriteNmapXML(&buf, tt.result); err != nil {
+			t.Fatalf("%s: WriteNmapXML() error = %v", tt.name, err)
+		}
+		got, err := ReadNmapXML(&buf)
+		if err != nil {
+			t.Fatalf("%s: ReadNmapXML() error = %v", tt.name, err)
+		}
+		if !got.Target.Equal(tt.result.Target) {
+			t.Errorf("%s: Target = %v, want %v", tt.name, got.Target, tt.result.Target)
+		}
+		if !got.StartTime.Equal(tt.result.StartTime) || !got.EndTime.Equal(tt.result.EndTime) {
+			t.Errorf("%s: times = %v-%v, want %v-%v", tt.name, got.StartTime, got.EndTime, tt.result.StartTime, tt.result.EndTime)
+		}
+		if want := tt.result.EndTime.Sub(tt.result.StartTime); got.Stats.Duration != want {
+			t.Errorf("%s: Duration = %v, want %v", tt.name, got.Stats.Duration, want)
+		}
+		if !reflect.DeepEqual(got.Ports, tt.result.Ports) {
+			t.Errorf("%s: Ports = %+v, want %+v", tt.name, got.Ports, tt.result.Ports)
+		}
+	}
+}
+
+// TestNmapXMLTruncatesToSeconds checks that times, stored as Unix seconds
+// by nmap, lose their fractional part.
+func TestNmapXMLTruncatesToSeconds(t *testing.T) {
+	start := time.Unix(1709633521, 750*int64(time.Millisecond))
+	result := &ScanResult{Target: net.IPv4(192, 0, 2, 1), StartTime: start, EndTime: start.Add(time.Second)}
+	var buf bytes.Buffer
+	if err := WriteNmapXML(&buf, result); err != nil {
+		t.Fatal(err)
+	}
+	got, err := ReadNmapXML(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := start.Truncate(time.Second); !got.StartTime.Equal(want) {
+		t.Errorf("StartTime = %v, want %v", got.StartTime, want)
+	}
+}
+
+// TestReadNmapXMLFromNmap imports testdata/nmap.xml, written by nmap -sS -oX.
+func TestReadNmapXMLFromNmap(t *testing.T) {
+	f, err := os.Open("testdata/nmap.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	got, err := ReadNmapXML(f)
+	if err != nil {
+		t.Fatalf("ReadNmapXML() error = %v", err)
+	}
+	if !got.Target.Equal(net.ParseIP("192.0.2.10")) {
+		t.Errorf("Target = %v, want 192.0.2.10", got.Target)
+	}
+	if got.Stats.Duration != 2*time.Second {
+		t.Errorf("Duration = %v, want 2s", got.Stats.Duration)
+	}
+	// The UDP port is skipped.
+	want := []PortResult{
+		{Port: 22, State: "open", Service: "ssh"},
+		{Port: 80, State: "open", Service: "http"},
+		{Port: 443, State: "closed", Service: "https"},
+		{Port: 8080, State: "filtered", Service: "http-proxy"},
+	}
+	if !reflect.DeepEqual(got.Ports, want) {
+		t.Errorf("Ports = %+v, want %+v", got.Ports, want)
+	}
+}
+
+func TestReadNmapXMLErrors(t *testing.T) {
+	tests := []struct {
+		name, doc string
+	}{
+		{"malformed", `<nmaprun><host>`},
+		{"no host", `<nmaprun scanner="nmap"></nmaprun>`},
+		{"no ip address", `<nmaprun><host><address addr="52:54:00:12:34:56" addrtype="mac"/></host></nmaprun>`},
+		{"invalid port", `<nmaprun><host><address addr="192.0.2.1" addrtype="ipv4"/><ports><port protocol="tcp" portid="70000"><state state="open"/></port></ports></host></nmaprun>`},
+	}
+	for _, tt := range tests {
+		if _, err := ReadNmapXML(strings.NewReader(tt.doc)); err == nil {
+			t.Errorf("%s: ReadNmapXML() error = nil", tt.name)
+		}
+	}
+}
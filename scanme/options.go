@@ -0,0 +1,562 @@
+package scanme
+
+import (
+	"log/slog"
+	"math/rand"
+	"net"
+	"time"
+
+	"github.com/CyberRoute/scanme/scanme/debug"
+	"github.com/CyberRoute/scanme/scanme/detection"
+	"github.com/google/gopacket/layers"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Option configures optional behaviour of a scanner created by NewScanner.
+type Option func(*scanner)
+
+// WithMetricsRegistry enables Prometheus metrics for the scanner and
+// registers them against r. When r is nil the default registry is used.
+// See the metrics package for the list of exported metrics.
+func WithMetricsRegistry(r prometheus.Registerer) Option {
+	return func(s *scanner) {
+		s.metricsEnabled = true
+		s.metricsRegistry = r
+	}
+}
+
+// WithDecoys makes Synscan send, alongside every real probe, one SYN packet
+// spoofed from each of the decoy addresses, each from a random source port,
+// so that the target sees the scan coming from many hosts at once. Replies
+// to decoy packets go to the decoys and are ignored.
+//
+// Spoofing source addresses may violate the terms of service of your ISP,
+// and only works on networks not performing BCP 38 egress filtering.
+func WithDecoys(decoys []net.IP) Option {
+	return func(s *scanner) {
+		s.decoys = decoys
+	}
+}
+
+// WithFragmentation makes Synscan split the TCP header of every probe over
+// several IP fragments carrying at most fragmentSize bytes of IP payload each,
+// so that firewalls inspecting only the first fragment do not see the whole
+// TCP header. fragmentSize is rounded down to a multiple of 8, as required by
+// the IP fragment offset, with a minimum of 8. The target reassembles them.
+//
+// Many modern firewalls and IDSs reassemble fragments before inspecting them,
+// so this is no guarantee of evasion. Try it against the loopback interface
+// or a host you control first.
+func WithFragmentation(fragmentSize int) Option {
+	return func(s *scanner) {
+		if fragmentSize < 8 {
+			fragmentSize = 8
+		}
+		s.fragmentSize = fragmentSize &^ 7
+	}
+}
+
+// WithTTL sets the TTL of the IPv4 packets sent by the scanner, 64 by
+// default. A low TTL restricts probes to hosts at most ttl hops away.
+func WithTTL(ttl uint8) Option {
+	return func(s *scanner) {
+		s.ttl = ttl
+	}
+}
+
+// WithPortList restricts Synscan to the given ports instead of [1, 65535].
+// Duplicates are probed once.
+func WithPortList(ports []layers.TCPPort) Option {
+	return func(s *scanner) {
+		s.ports = uniquePorts(ports)
+	}
+}
+
+// WithPortIterator makes Synscan probe the ports it yields, in that order,
+// instead of [1, 65535]. It takes precedence over WithPortList and
+// WithRandomOrder; ports denied by WithAllowList or WithDenyList are still
+// skipped. The iterator is consumed by the first scan, later scans probe
+// the same ports in the same order.
+func WithPortIterator(it PortIterator) Option {
+	return func(s *scanner) {
+		s.portIterator = it
+	}
+}
+
+// WithRandomOrder makes Synscan probe ports in a random order rather than
+// sequentially, which is less conspicuous to simple port scan detectors.
+func WithRandomOrder() Option {
+	return func(s *scanner) {
+		s.randomOrder = true
+	}
+}
+
+// WithRateLimit caps the number of probes Synscan sends per second. By
+// default packets are sent as fast as possible.
+func WithRateLimit(packetsPerSecond int) Option {
+	return func(s *scanner) {
+		s.sendInterval = 0
+		if packetsPerSecond > 0 {
+			s.sendInterval = time.Second / time.Duration(packetsPerSecond)
+		}
+	}
+}
+
+// WithAllowList restricts Synscan to the given ports. Ports also in the
+// deny list set with WithDenyList are still skipped.
+func WithAllowList(ports []layers.TCPPort) Option {
+	return func(s *scanner) {
+		s.allowList = portSet(ports)
+	}
+}
+
+// WithDenyList makes Synscan skip the given ports, for instance port 53 on
+// DNS resolvers, which always appears open.
+func WithDenyList(ports []layers.TCPPort) Option {
+	return func(s *scanner) {
+		s.denyList = portSet(ports)
+	}
+}
+
+// WithSpeed applies the timing template speed, setting the rate limit, the
+// time to wait for late replies, the number of ARP retries and the jitter
+// between probes at once. Options given after WithSpeed override the
+// corresponding setting of the template. Unknown speeds are ignored.
+//
+// SpeedAggressive and especially SpeedInsane are likely to trigger intrusion
+// detection systems, can saturate slow links and may miss ports whose
+// replies are dropped or arrive late.
+func WithSpeed(speed ScanSpeed) Option {
+	return func(s *scanner) {
+		preset, ok := speedPresets[speed]
+		if !ok {
+			return
+		}
+		s.sendInterval = preset.interval
+		s.settle = preset.settle
+		s.arpRetries = preset.arpRetries
+		s.jitter = preset.jitter
+	}
+}
+
+// WithJitter varies the interval between probes at random by up to ±fraction
+// of the rate limit interval, e.g. 0.5 for ±50%, so that scan traffic is less
+// obviously machine generated. Jitter has no effect without a rate limit set
+// with WithRateLimit or WithSpeed. WithJitter panics if fraction is not in
+// [0, 1].
+func WithJitter(fraction float64) Option {
+	if fraction < 0 || fraction > 1 {
+		panic("scanme: jitter fraction must be between 0 and 1")
+	}
+	return func(s *scanner) {
+		s.jitter = fraction
+	}
+}
+
+// WithRandomSeed seeds the generator used to shuffle ports with
+// WithRandomOrder, making the probe order reproducible. By default the
+// generator is seeded from crypto/rand.
+func WithRandomSeed(seed int64) Option {
+	return func(s *scanner) {
+		s.rng = rand.New(rand.NewSource(seed))
+	}
+}
+
+// WithGeoIP loads the MaxMind GeoIP2 or GeoLite2 database at dbPath, used to
+// fill in ScanResult.GeoInfo once a scan completes. City databases provide
+// the location and ASN or ISP databases the network owner, so WithGeoIP may
+// be given several times to combine them. NewScanner fails if a database
+// cannot be opened.
+func WithGeoIP(dbPath string) Option {
+	return func(s *scanner) {
+		s.geoipPaths = append(s.geoipPaths, dbPath)
+	}
+}
+
+// WithDNSResolution resolves the PTR records of the target while it is
+// scanned, filling in ScanResult.Hostname and ScanResult.Hostnames. The
+// lookup gives up after 5 seconds, leaving them empty.
+func WithDNSResolution() Option {
+	return func(s *scanner) {
+		s.resolveDNS = true
+	}
+}
+
+// WithASNLookup looks up the autonomous system announcing the target with
+// the Team Cymru whois service while it is scanned, filling in
+// ScanResult.ASNInfo. This sends the target address to a third party.
+func WithASNLookup() Option {
+	return func(s *scanner) {
+		s.lookupASN = true
+	}
+}
+
+// WithVLAN tags every packet sent with an 802.1Q header carrying the VLAN
+// identifier vid and priority code point pcp, and only captures packets of
+// that VLAN, for scanning from switch trunk ports. Sub-interfaces such as
+// eth0.100 on Linux already tag packets and don't need it.
+func WithVLAN(vid uint16, pcp uint8) Option {
+	return func(s *scanner) {
+		s.vlan = true
+		s.vlanID = vid & 0x0fff
+		s.vlanPCP = pcp & 0x07
+	}
+}
+
+// WithTCPOptions sets the TCP options of the SYN probes, which by default
+// only carry an MSS option. TCPOptionsLinux, TCPOptionsWindows10 and
+// TCPOptionsMacOS mimic the SYNs of real operating systems, so that probes
+// don't stand out to passive fingerprinting.
+//
+// Options also shape the reply of the target: for instance, a SYN-ACK
+// without the timestamps option offered still counts as open, but options
+// the target rejects may make it drop the probe altogether.
+func WithTCPOptions(opts []layers.TCPOption) Option {
+	return func(s *scanner) {
+		s.tcpOptions = opts
+	}
+}
+
+// WithHTTPFingerprinting sends an HTTP request to every open port once a
+// scan completes and records the identifying headers of the web servers
+// answering in PortResult.HTTPFingerprint.
+func WithHTTPFingerprinting() Option {
+	return func(s *scanner) {
+		s.httpFingerprint = true
+	}
+}
+
+// WithWebhook posts every ScanResult as JSON to url once Synscan completes,
+// with the given extra request headers. Deliveries are retried up to 3
+// times with exponential backoff; failures are logged and don't fail the
+// scan.
+func WithWebhook(url string, headers map[string]string) Option {
+	return func(s *scanner) {
+		s.webhookURL = url
+		s.webhookHeaders = headers
+	}
+}
+
+// WithWebhookHMACSecret signs webhook requests set up with WithWebhook: the
+// X-Scanme-Signature header carries the HMAC-SHA256 of the request body
+// keyed with secret, in hex, so that receivers can authenticate them.
+func WithWebhookHMACSecret(secret string) Option {
+	return func(s *scanner) {
+		s.webhookSecret = secret
+	}
+}
+
+// WithPlugin enables plugin p, called once every scan completes. Plugins
+// are called in the order they were given; an error returned by one is
+// logged and fails neither the other plugins nor the scan.
+func WithPlugin(p ScanPlugin) Option {
+	return func(s *scanner) {
+		s.plugins = append(s.plugins, p)
+	}
+}
+
+// WithAFPacket makes Synscan read replies from an AF_PACKET memory-mapped
+// ring buffer instead of libpcap, saving a copy of every packet captured.
+// It only works on Linux; elsewhere Synscan fails to start.
+func WithAFPacket() Option {
+	return func(s *scanner) {
+		s.afPacket = true
+	}
+}
+
+// WithRandomISN gives every SYN probe its own initial sequence number, drawn
+// from crypto/rand, like real operating systems do. By default probes carry
+// the values of a linear sequencer, the same for every port of a scan, which
+// makes them easy to tell apart from genuine connection attempts. The
+// acknowledgment number of SYN probes is always zero.
+func WithRandomISN() Option {
+	return func(s *scanner) {
+		s.randomISN = true
+	}
+}
+
+// WithCheckpointFile makes Synscan save its progress to the JSON file at
+// path: the last port probed, the open ports found so far and the scan
+// options, every WithCheckpointInterval ports and when the process receives
+// SIGTERM, in which case Synscan returns ErrScanInterrupted. If the file
+// exists when the scanner is created and belongs to a scan of the same
+// target, Synscan resumes after the last checkpointed port. The file is
+// removed once a scan completes. With WithRandomOrder, use WithRandomSeed
+// too so that the resumed scan shuffles ports the same way.
+func WithCheckpointFile(path string) Option {
+	return func(s *scanner) {
+		s.checkpointPath = path
+	}
+}
+
+// WithCheckpointInterval sets how many ports Synscan probes between two
+// checkpoints saved with WithCheckpointFile, 1000 by default.
+func WithCheckpointInterval(n int) Option {
+	return func(s *scanner) {
+		if n > 0 {
+			s.checkpointInterval = n
+		}
+	}
+}
+
+// WithBandwidthLimit caps the traffic written to the network interface at
+// bytesPerSecond, counting whole Ethernet frames, headers included. Unlike
+// WithRateLimit, which counts probes, it also covers ARP, ICMP and decoy
+// packets.
+func WithBandwidthLimit(bytesPerSecond int64) Option {
+	return func(s *scanner) {
+		if bytesPerSecond > 0 {
+			s.bandwidth = newBandwidthLimiter(bytesPerSecond)
+		}
+	}
+}
+
+// WithBandwidthMonitor measures the traffic of SYN scans: the send and
+// receive rates of the last second are returned by CurrentBandwidth while
+// the scan runs, and the peak and average rates are recorded in the
+// ScanStats of its result.
+func WithBandwidthMonitor() Option {
+	return func(s *scanner) {
+		s.monitorBandwidth = true
+	}
+}
+
+// WithCPULimit bounds the CPU used by the scans of the scanner to maxPercent
+// of the capacity of the machine, e.g. 25 for two cores out of eight: the
+// SYN scan send and receive loops of all its scans take turns on that many
+// cores, rounded up, and pause in proportion to their work when the limit
+// is not a whole number of cores. The rest of the process is not limited.
+func WithCPULimit(maxPercent float64) Option {
+	return func(s *scanner) {
+		s.cpuLimit = maxPercent
+	}
+}
+
+// WithMemoryLimit bounds the number of connections ConnScan attempts in
+// parallel, 1024 at first, and checks the heap size every second: whenever
+// it exceeds bytes the number is halved, to recover gradually once the heap
+// drops under half of the limit.
+func WithMemoryLimit(bytes int64) Option {
+	return func(s *scanner) {
+		s.memoryLimit = bytes
+	}
+}
+
+// WithARPCache makes the scanner look up next hop MAC addresses in c before
+// sending ARP requests, and record those it resolves there.
+func WithARPCache(c *ARPCache) Option {
+	return func(s *scanner) {
+		s.arpCache = c
+	}
+}
+
+// WithHandlePool makes the scanner inject packets through the handle of
+// its interface in p, shared with the other scanners of the pool, rather
+// than opening its own. Close leaves the handle open: close the pool once
+// done with all its scanners. Traceroute, which reads replies from that
+// handle, must not run on several scanners of a pool at once.
+func WithHandlePool(p *HandlePool) Option {
+	return func(s *scanner) {
+		s.handles = p
+	}
+}
+
+// WithSharedRateLimit makes Synscan wait for l before every probe, so that
+// the scanners sharing l do not send more probes per second than it allows
+// together.
+func WithSharedRateLimit(l *RateLimiter) Option {
+	return func(s *scanner) {
+		s.sharedLimit = l
+	}
+}
+
+// WithQueueSize sets how many packets of each priority the send queue
+// holds, 4096 by default. Packets are serialized by the scan and written to
+// the network by a dedicated goroutine, control packets such as ARP and ICMP
+// first. Probes finding the queue full wait for room, slowing the scan down
+// to the rate packets are written at, while decoy packets are dropped and
+// counted in ScanStats.PacketsDroppedQueue.
+func WithQueueSize(n int) Option {
+	return func(s *scanner) {
+		if n > 0 {
+			s.queueSize = n
+		}
+	}
+}
+
+// WithEvents makes Synscan report its progress on ch: the number of ports
+// probed and the open ports as they are found, for instance to display a
+// live view of the scan. Events are dropped when ch is full, so that a slow
+// reader does not slow the scan down.
+func WithEvents(ch chan<- ScanEvent) Option {
+	return func(s *scanner) {
+		s.events = ch
+	}
+}
+
+// WithHoneypotDetection sets ScanResult.HoneypotScore once Synscan
+// completes, see HoneypotScore for the heuristics used.
+func WithHoneypotDetection() Option {
+	return func(s *scanner) {
+		s.detectHoneypots = true
+	}
+}
+
+// WithIDSDetection makes Synscan watch the rate at which probes are
+// answered, window of detection.WindowSize probes after window, and send a
+// detection.IDSEvent on ch when it drops by more than half from the
+// baseline, as when an IDS or IPS starts rate limiting the replies of the
+// target. The send rate is then halved, recovering over time. Events are
+// dropped when ch is full.
+func WithIDSDetection(ch chan<- detection.IDSEvent) Option {
+	return func(s *scanner) {
+		s.idsEvents = ch
+	}
+}
+
+// WithPacketLogging records every packet the scanner sends, and every
+// packet Synscan captures, in logger, to see exactly what was exchanged
+// with the target. Write the log out with logger.WriteText or
+// logger.WritePCAP.
+func WithPacketLogging(logger *debug.PacketLogger) Option {
+	return func(s *scanner) {
+		s.packetLog = logger
+	}
+}
+
+// WithMPTCPDetection offers Multipath TCP in the SYN probes, with an
+// MP_CAPABLE option, and records the ports whose SYN-ACK accepts it in
+// PortResult.MPTCP, revealing multi-homed servers. Use GrabMPTCPBanner to
+// connect to them over MPTCP.
+func WithMPTCPDetection() Option {
+	return func(s *scanner) {
+		s.detectMPTCP = true
+	}
+}
+
+// WithAdaptiveRSTRateLimit makes the first Synscan run RSTRateLimitDetect
+// before sending its probes, lowering the send rate below the rate at which
+// the target sends RSTs when it is limited, so that closed ports are not
+// taken for filtered ones.
+func WithAdaptiveRSTRateLimit() Option {
+	return func(s *scanner) {
+		s.adaptiveRST = true
+	}
+}
+
+// WithSubdomainScan makes ResolveAndScan also resolve and scan the
+// subdomains of its domain found in certificate transparency logs. It has
+// no effect on a scanner created with NewScanner.
+func WithSubdomainScan() Option {
+	return func(s *scanner) {
+		s.scanSubdomains = true
+	}
+}
+
+// WithExtraPorts makes Synscan probe ports after the ports it would probe
+// otherwise, such as the high ports backdoors listen on when scanning a
+// port list, and merge them into its result. Ports already probed are not
+// probed twice, and the allow and deny lists still apply.
+func WithExtraPorts(ports []layers.TCPPort) Option {
+	return func(s *scanner) {
+		s.extra = append(s.extra, ports...)
+	}
+}
+
+// WithBackdoorPortList adds the default ports of well-known backdoors and
+// trojans, such as 31337 for Back Orifice or 12345 for NetBus, to the ports
+// probed last as with WithExtraPorts, and flags them PortResult.Suspicious
+// when open, whether they were probed as extra ports or not.
+func WithBackdoorPortList() Option {
+	return func(s *scanner) {
+		ports := backdoorPorts()
+		s.extra = append(s.extra, ports...)
+		if s.suspicious == nil {
+			s.suspicious = make(map[layers.TCPPort]bool, len(ports))
+		}
+		for _, p := range ports {
+			s.suspicious[p] = true
+		}
+	}
+}
+
+// WithLogger sends the diagnostic output of the scanner to l instead of
+// slog.Default, which writes through the standard log package at the Info
+// level. Per-packet messages, such as filtered ports, are logged at the
+// Debug level. Pass a logger with a handler writing to io.Discard for the
+// scanner to print nothing.
+func WithLogger(l *slog.Logger) Option {
+	return func(s *scanner) {
+		if l != nil {
+			s.logger = l
+		}
+	}
+}
+
+// WithExcludeHosts excludes hosts from scans: NewScanner returns an
+// ErrHostExcluded for them, and pool.ScanNetwork skips them.
+func WithExcludeHosts(hosts []net.IP) Option {
+	return func(s *scanner) {
+		s.excludeHosts = append(s.excludeHosts, hosts...)
+	}
+}
+
+// WithExcludeCIDRs excludes the hosts of networks from scans, as
+// WithExcludeHosts does.
+func WithExcludeCIDRs(cidrs []*net.IPNet) Option {
+	return func(s *scanner) {
+		s.excludeCIDRs = append(s.excludeCIDRs, cidrs...)
+	}
+}
+
+// WithExcludeFile excludes the hosts listed in the file at path from scans,
+// as WithExcludeHosts does. The file holds one address or CIDR block per
+// line; blank lines and lines starting with # are ignored. It is read by
+// NewScanner, which fails when it cannot be.
+func WithExcludeFile(path string) Option {
+	return func(s *scanner) {
+		s.excludeFiles = append(s.excludeFiles, path)
+	}
+}
+
+// WithSOCKS5Proxy makes ConnScan connect to the target through the SOCKS5
+// proxy at addr, such as "127.0.0.1:1080", authenticating with creds unless
+// nil. Scans sending raw packets, such as Synscan or IdleScan, cannot be
+// proxied and return an ErrProxyNotSupported instead of leaking packets
+// from the local host.
+func WithSOCKS5Proxy(addr string, creds *ProxyCreds) Option {
+	return func(s *scanner) {
+		s.socks5Addr = addr
+		s.socks5Creds = creds
+	}
+}
+
+// WithDeduplication makes Synscan handle once the packets captured more
+// than once, such as copies from a switch mirroring ports, which could
+// otherwise flip the state of ports. The last cacheSize packets are
+// remembered, 4096 when cacheSize is lower than one; duplicates are counted
+// in ScanStats.DuplicatesFiltered.
+func WithDeduplication(cacheSize int) Option {
+	return func(s *scanner) {
+		s.dedupe = true
+		s.dedupeSize = cacheSize
+	}
+}
+
+// WithDNSZoneTransfer makes Synscan attempt a transfer of the zone domain,
+// with DNSZoneTransfer, when TCP port 53 of the target is found open. The
+// records of a successful transfer are recorded in ScanResult.ZoneTransfer.
+func WithDNSZoneTransfer(domain string) Option {
+	return func(s *scanner) {
+		s.axfrDomain = domain
+	}
+}
+
+// WithVNCProbe makes Synscan run VNCProbe on the VNC ports 5900 to 5909 of
+// the target found open, setting their ServiceHint to the security types
+// offered, or to "VNC-noauth" for servers requiring no password.
+func WithVNCProbe() Option {
+	return func(s *scanner) {
+		s.vncProbe = true
+	}
+}
@@ -0,0 +1,90 @@
+package scanme
+
+import (
+	"net"
+	"os"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// builtinTemplates are the templates named by BuiltinTemplate.
+var builtinTemplates = map[string]string{
+	"brief": `{{.Target}} open:{{range portsByState "open"}} {{printf "%d" .Port}}{{end}} ({{formatDuration .Stats.Duration}})
+`,
+	"full": `Target:   {{.Target}}{{with .Hostname}} ({{.}}){{end}}
+Country:  {{with geoCountry .Target}}{{.}}{{else}}unknown{{end}}
+Started:  {{.StartTime.Format "2006-01-02 15:04:05"}}
+Duration: {{formatDuration .Stats.Duration}}
+Packets:  {{.Stats.PacketsSent}} sent, {{.Stats.PacketsReceived}} received
+{{range .Ports}}{{printf "%-9s %-9s %s" (printf "%d/tcp" .Port) .State .Service}}{{with .ServiceHint}}  {{.}}{{end}}
+{{end}}`,
+	"csv": `target,port,state,service
+{{range .Ports}}{{$.Target}},{{printf "%d" .Port}},{{.State}},{{.Service}}
+{{end}}`,
+}
+
+// BuiltinTemplate returns the text of the built-in output template name,
+// for RenderTemplate: "brief" lists the open ports on one line, "full"
+// describes the scan and every port, and "csv" writes a line per port. ok
+// is false for other names.
+func BuiltinTemplate(name string) (tmpl string, ok bool) {
+	tmpl, ok = builtinTemplates[name]
+	return tmpl, ok
+}
+
+// RenderTemplate evaluates the text/template tmpl with result as data, to
+// format results in any text format, such as the one a SIEM imports.
+// Besides the text/template builtins, tmpl may call:
+//
+//   - portsByState state: the ports of result in the given state, such as
+//     {{range portsByState "open"}};
+//   - formatDuration d: d rounded to the millisecond, such as
+//     {{formatDuration .Stats.Duration}};
+//   - geoCountry ip: the ISO code of the country of the target, such as
+//     {{geoCountry .Target}}, empty unless located with WithGeoIP.
+func RenderTemplate(tmpl string, result *ScanResult) (string, error) {
+	t, err := template.New("result").Funcs(templateFuncs(result)).Parse(tmpl)
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	if err := t.Execute(&b, result); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// RenderTemplateFile is RenderTemplate with the template read from the
+// file at path.
+func RenderTemplateFile(path string, result *ScanResult) (string, error) {
+	tmpl, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return RenderTemplate(string(tmpl), result)
+}
+
+// templateFuncs returns the functions of the templates rendering result.
+func templateFuncs(result *ScanResult) template.FuncMap {
+	return template.FuncMap{
+		"portsByState": func(state string) []PortResult {
+			var ports []PortResult
+			for _, p := range result.Ports {
+				if p.State == state {
+					ports = append(ports, p)
+				}
+			}
+			return ports
+		},
+		"formatDuration": func(d time.Duration) string {
+			return d.Round(time.Millisecond).String()
+		},
+		"geoCountry": func(ip net.IP) string {
+			if result.GeoInfo == nil || !ip.Equal(result.Target) {
+				return ""
+			}
+			return result.GeoInfo.Country
+		},
+	}
+}
@@ -0,0 +1,88 @@
+package scanme
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"github.com/google/gopacket/pcap"
+)
+
+// PassiveCapture listens for duration, or until ctx is done, to the traffic
+// of the target without sending a single packet, and infers the state of
+// its ports from the replies it sends to other hosts: ports answering with
+// a SYN-ACK are "observed-open", ports answering only with resets
+// "observed-closed". Only ports the target talked on during the capture are
+// part of the result, which is returned even when ctx is done early.
+//
+// The interface is put in promiscuous mode, so on a switched network this
+// only sees traffic mirrored to the scanner, e.g. through a SPAN port.
+func (s *scanner) PassiveCapture(ctx context.Context, duration time.Duration) (*ScanResult, error) {
+	handle, err := openLive(s.iface.Name)
+	if err != nil {
+		return nil, err
+	}
+	defer handle.Close()
+
+	// tcp[13] & 0x12 == 0x12 checks for SYN-ACK, tcp[13] & 0x04 != 0 for RST.
+	bpfFilter := fmt.Sprintf("src host %s and tcp and (tcp[13] & 0x12 == 0x12 or tcp[13] & 0x04 != 0)", s.dst)
+	if err := handle.SetBPFFilter(s.bpfFilter(bpfFilter)); err != nil {
+		return nil, err
+	}
+
+	start := time.Now()
+	states := make(map[layers.TCPPort]string)
+	var received int
+	finish := func() *ScanResult {
+		result := newScanResult(s.dst, start, states)
+		result.Stats.PacketsReceived = received
+		return result
+	}
+
+	var eth layers.Ethernet
+	var dot1q layers.Dot1Q
+	var ip4 layers.IPv4
+	var tcp layers.TCP
+	parser := gopacket.NewDecodingLayerParser(layers.LayerTypeEthernet, &eth, &dot1q, &ip4, &tcp)
+	parser.IgnoreUnsupported = true
+	decoded := []gopacket.LayerType{}
+
+	deadline := start.Add(duration)
+	for time.Now().Before(deadline) {
+		select {
+		case <-ctx.Done():
+			return finish(), ctx.Err()
+		default:
+		}
+
+		data, _, err := handle.ReadPacketData()
+		if err == pcap.NextErrorTimeoutExpired {
+			continue
+		} else if err != nil {
+			s.logger.Error("error reading packet", "err", err)
+			continue
+		}
+		received++
+		s.metrics.PacketReceived(s.dst.String())
+
+		//nolint:staticcheck // SA9003 ignore this!
+		if err := parser.DecodeLayers(data, &decoded); err != nil {
+			// Errors here are due to the decoder, and not all layers are implemented.
+		}
+		if len(decoded) == 0 || decoded[len(decoded)-1] != layers.LayerTypeTCP || !ip4.SrcIP.Equal(s.dst) {
+			continue
+		}
+		switch {
+		case tcp.SYN && tcp.ACK:
+			states[tcp.SrcPort] = "observed-open"
+		case tcp.RST:
+			// Open ports also reset connections, so a SYN-ACK wins.
+			if states[tcp.SrcPort] == "" {
+				states[tcp.SrcPort] = "observed-closed"
+			}
+		}
+	}
+	return finish(), nil
+}
@@ -0,0 +1,63 @@
+package scanme
+
+import (
+	"sync"
+)
+
+// ScanPlugin extends a scanner with custom processing of its results, such
+// as alerting when a given port opens. Enable plugins with WithPlugin.
+type ScanPlugin interface {
+	// Name identifies the plugin in the registry and in logs.
+	Name() string
+	// OnPortOpen is called for every open port once a scan completes,
+	// before OnScanComplete. scanner is the scanner that found the port,
+	// for instance to grab its banner.
+	OnPortOpen(result *PortResult, scanner Scanner) error
+	// OnScanComplete is called with the result of every scan.
+	OnScanComplete(result *ScanResult) error
+}
+
+// runPlugins calls the plugins of the scanner in order on result. Errors are
+// logged and don't stop the other plugins.
+func (s *scanner) runPlugins(result *ScanResult) {
+	for _, p := range s.plugins {
+		for i := range result.Ports {
+			if result.Ports[i].State != "open" {
+				continue
+			}
+			if err := p.OnPortOpen(&result.Ports[i], s); err != nil {
+				s.logger.Error("plugin error", "plugin", p.Name(), "port", result.Ports[i].Port, "err", err)
+			}
+		}
+	}
+	for _, p := range s.plugins {
+		if err := p.OnScanComplete(result); err != nil {
+			s.logger.Error("plugin error", "plugin", p.Name(), "err", err)
+		}
+	}
+}
+
+// PluginRegistry lists the plugins available to programs, so that they can
+// be enabled by name, e.g. from a configuration file. Plugin packages
+// typically register themselves in an init function; registering a plugin
+// does not enable it.
+var PluginRegistry = &pluginRegistry{}
+
+type pluginRegistry struct {
+	mu      sync.Mutex
+	plugins []ScanPlugin
+}
+
+// Register adds p to the registry.
+func (r *pluginRegistry) Register(p ScanPlugin) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.plugins = append(r.plugins, p)
+}
+
+// Registered returns the registered plugins, in registration order.
+func (r *pluginRegistry) Registered() []ScanPlugin {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]ScanPlugin(nil), r.plugins...)
+}
@@ -0,0 +1,52 @@
+// Package logger is an example scan plugin printing the open ports found by
+// a scanner as a table. Importing it registers it with
+// scanme.PluginRegistry; enable it with
+//
+//	scanme.NewScanner(ip, router, scanme.WithPlugin(logger.New(os.Stdout)))
+package logger
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+
+	"github.com/CyberRoute/scanme/scanme"
+)
+
+func init() {
+	scanme.PluginRegistry.Register(New(os.Stdout))
+}
+
+// Plugin prints open ports to a writer.
+type Plugin struct {
+	w *tabwriter.Writer
+}
+
+// New creates a plugin printing to w.
+func New(w io.Writer) *Plugin {
+	return &Plugin{w: tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)}
+}
+
+// Name implements scanme.ScanPlugin.
+func (p *Plugin) Name() string {
+	return "logger"
+}
+
+// OnPortOpen implements scanme.ScanPlugin.
+func (p *Plugin) OnPortOpen(result *scanme.PortResult, _ scanme.Scanner) error {
+	_, err := fmt.Fprintf(p.w, "%d/tcp\t%s\t%s\t%v\n", result.Port, result.State, result.Service, result.Latency)
+	return err
+}
+
+// OnScanComplete implements scanme.ScanPlugin.
+func (p *Plugin) OnScanComplete(result *scanme.ScanResult) error {
+	if err := p.w.Flush(); err != nil {
+		return err
+	}
+	_, err := fmt.Fprintf(p.w, "%v: %d open ports in %v\n", result.Target, len(result.OpenPorts()), result.Stats.Duration)
+	if err != nil {
+		return err
+	}
+	return p.w.Flush()
+}
@@ -0,0 +1,151 @@
+// Package pool scans many hosts in parallel while bounding the number of
+// scans running at the same time.
+package pool
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/CyberRoute/scanme/scanme"
+	scannet "github.com/CyberRoute/scanme/scanme/net"
+	"github.com/google/gopacket/routing"
+)
+
+// ScannerPool runs SYN scans against many targets with at most maxConcurrent
+// scanners (and pcap handles) open at any time.
+type ScannerPool struct {
+	sem    chan struct{}
+	router routing.Router
+	opts   []scanme.Option
+	// scanHost scans a target, it is replaced by the tests.
+	scanHost func(ctx context.Context, target net.IP) (*scanme.ScanResult, error)
+}
+
+// NewScannerPool creates a pool running at most maxConcurrent scans at once.
+// Every scanner is created with router and opts. A maxConcurrent lower than
+// one is treated as one.
+func NewScannerPool(maxConcurrent int, router routing.Router, opts ...scanme.Option) *ScannerPool {
+	if maxConcurrent < 1 {
+		maxConcurrent = 1
+	}
+	p := &ScannerPool{
+		sem:    make(chan struct{}, maxConcurrent),
+		router: router,
+		opts:   opts,
+	}
+	p.scanHost = p.scan
+	return p
+}
+
+// ScanAll scans every target and returns the results in the same order as
+// targets. A failed scan does not stop the others: its entry is left nil and
+// its error, annotated with the target, is part of the returned error.
+// Targets not started yet when ctx is cancelled are skipped, and the scans
+// in progress stop early.
+func (p *ScannerPool) ScanAll(ctx context.Context, targets []net.IP) ([]*scanme.ScanResult, error) {
+	results := make([]*scanme.ScanResult, len(targets))
+	errs := make([]error, len(targets))
+
+	var wg sync.WaitGroup
+	for i, target := range targets {
+		select {
+		case p.sem <- struct{}{}:
+		case <-ctx.Done():
+			errs[i] = fmt.Errorf("%v: %w", target, ctx.Err())
+			continue
+		}
+
+		wg.Add(1)
+		go func(i int, target net.IP) {
+			defer wg.Done()
+			defer func() { <-p.sem }()
+
+			result, err := p.scanHost(ctx, target)
+			if err != nil {
+				errs[i] = fmt.Errorf("%v: %w", target, err)
+				return
+			}
+			results[i] = result
+		}(i, target)
+	}
+	wg.Wait()
+
+	return results, errors.Join(errs...)
+}
+
+// scan SYN scans target with a scanner of its own until done or ctx is.
+func (p *ScannerPool) scan(ctx context.Context, target net.IP) (*scanme.ScanResult, error) {
+	s, err := scanme.NewScanner(target, p.router, p.opts...)
+	if err != nil {
+		return nil, err
+	}
+	defer s.Close()
+
+	return s.SynscanContext(ctx)
+}
+
+// ScanNetwork scans every host address of cidr, in random order, without
+// ever holding the list of addresses in memory. It returns the results of
+// the hosts found up, sorted by address. Hosts excluded with the
+// scanme.WithExcludeHosts, WithExcludeCIDRs and WithExcludeFile options of
+// the pool are skipped, as are hosts that do not answer ARP, considered
+// down; other failures are part of the returned error, annotated with the
+// target.
+func (p *ScannerPool) ScanNetwork(ctx context.Context, cidr string) ([]*scanme.ScanResult, error) {
+	hosts, err := scannet.NewIPRange(cidr)
+	if err != nil {
+		return nil, err
+	}
+	excluded, err := scanme.NewExcludeFilter(p.opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	var mu sync.Mutex
+	var results []*scanme.ScanResult
+	var errs []error
+
+	var wg sync.WaitGroup
+	for target := range hosts.RandomIter(ctx, time.Now().UnixNano()) {
+		if excluded(target) {
+			continue
+		}
+		select {
+		case p.sem <- struct{}{}:
+		case <-ctx.Done():
+			continue
+		}
+
+		wg.Add(1)
+		go func(target net.IP) {
+			defer wg.Done()
+			defer func() { <-p.sem }()
+
+			result, err := p.scanHost(ctx, target)
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				if !errors.Is(err, &scanme.ErrARPTimeout{}) {
+					errs = append(errs, fmt.Errorf("%v: %w", target, err))
+				}
+				return
+			}
+			results = append(results, result)
+		}(target)
+	}
+	wg.Wait()
+
+	sort.Slice(results, func(i, j int) bool {
+		return bytes.Compare(results[i].Target.To16(), results[j].Target.To16()) < 0
+	})
+	if ctx.Err() != nil {
+		errs = append(errs, ctx.Err())
+	}
+	return results, errors.Join(errs...)
+}
@@ -0,0 +1,105 @@
+package pool
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/CyberRoute/scanme/scanme"
+)
+
+// hosts returns n consecutive addresses of 10.0.0.0/8.
+func hosts(n int) []net.IP {
+	targets := make([]net.IP, n)
+	for i := range targets {
+		targets[i] = net.IPv4(10, 0, byte(i>>8), byte(i))
+	}
+	return targets
+}
+
+func TestScanAllBoundsConcurrency(t *testing.T) {
+	const size = 4
+	p := NewScannerPool(size, nil)
+	var running, peak atomic.Int32
+	p.scanHost = func(ctx context.Context, target net.IP) (*scanme.ScanResult, error) {
+		n := running.Add(1)
+		defer running.Add(-1)
+		for {
+			old := peak.Load()
+			if n <= old || peak.CompareAndSwap(old, n) {
+				break
+			}
+		}
+		time.Sleep(time.Millisecond)
+		if target.To4()[3] == 7 {
+			return nil, errors.New("unreachable")
+		}
+		return &scanme.ScanResult{Target: target}, nil
+	}
+
+	targets := hosts(32)
+	results, err := p.ScanAll(context.Background(), targets)
+	if peak.Load() > size {
+		t.Errorf("%d scans ran at once, want at most %d", peak.Load(), size)
+	}
+	if err == nil {
+		t.Error("ScanAll() error = nil, want the error of 10.0.0.7")
+	}
+	for i, r := range results {
+		if i == 7 {
+			if r != nil {
+				t.Errorf("results[7] = %v, want nil for the failed scan", r)
+			}
+			continue
+		}
+		if r == nil || !r.Target.Equal(targets[i]) {
+			t.Errorf("results[%d] = %v, want the result of %v", i, r, targets[i])
+		}
+	}
+}
+
+func TestScanAllCancelsRunningScans(t *testing.T) {
+	p := NewScannerPool(2, nil)
+	p.scanHost = func(ctx context.Context, target net.IP) (*scanme.ScanResult, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	done := make(chan error, 1)
+	go func() {
+		_, err := p.ScanAll(ctx, hosts(8))
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("ScanAll() error = %v, want context.DeadlineExceeded", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("ScanAll() did not return once ctx was done")
+	}
+}
+
+// BenchmarkPool256Hosts scans a /24 with a pool of 16, each scan taking a
+// millisecond, to measure the overhead of the pool itself.
+func BenchmarkPool256Hosts(b *testing.B) {
+	p := NewScannerPool(16, nil)
+	p.scanHost = func(ctx context.Context, target net.IP) (*scanme.ScanResult, error) {
+		time.Sleep(time.Millisecond)
+		return &scanme.ScanResult{Target: target}, nil
+	}
+	targets := hosts(256)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := p.ScanAll(context.Background(), targets); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,133 @@
+package scanme
+
+import (
+	"math/rand"
+
+	"github.com/google/gopacket/layers"
+)
+
+// PortIterator yields the ports a scan probes, in order, until Next returns
+// false. Set one with WithPortIterator to scan ports in a custom order.
+type PortIterator interface {
+	Next() (layers.TCPPort, bool)
+}
+
+// topPorts are the 100 TCP ports found open most often on the Internet,
+// most frequent first, from nmap's port frequency data.
+var topPorts = []layers.TCPPort{
+	80, 23, 443, 21, 22, 25, 3389, 110, 445, 139,
+	143, 53, 135, 3306, 8080, 1723, 111, 995, 993, 5900,
+	1025, 587, 8888, 199, 1720, 465, 548, 113, 81, 6001,
+	10000, 514, 5060, 179, 1026, 2000, 8443, 8000, 32768, 554,
+	26, 1433, 49152, 2001, 515, 8008, 49154, 1027, 5666, 646,
+	5000, 5631, 631, 49153, 8081, 2049, 88, 79, 5800, 106,
+	2121, 1110, 49155, 6000, 513, 990, 5357, 427, 49156, 543,
+	544, 5101, 144, 7, 389, 8009, 3128, 444, 9999, 5009,
+	7070, 5190, 3000, 5432, 1900, 3986, 13, 1029, 9, 5051,
+	6646, 49157, 1028, 873, 1755, 2717, 4899, 9100, 119, 37,
+}
+
+// SequentialIterator yields the ports of a range in increasing order.
+type SequentialIterator struct {
+	next, last int
+}
+
+// NewSequentialIterator returns an iterator over the ports in [first, last].
+func NewSequentialIterator(first, last layers.TCPPort) *SequentialIterator {
+	return &SequentialIterator{next: int(first), last: int(last)}
+}
+
+// Next implements PortIterator.
+func (it *SequentialIterator) Next() (layers.TCPPort, bool) {
+	if it.next > it.last {
+		return 0, false
+	}
+	it.next++
+	return layers.TCPPort(it.next - 1), true
+}
+
+// ListIterator yields the ports of a list in the order they were given.
+type ListIterator struct {
+	ports []layers.TCPPort
+}
+
+// NewListIterator returns an iterator over ports, which it keeps a copy of.
+func NewListIterator(ports []layers.TCPPort) *ListIterator {
+	return &ListIterator{ports: append([]layers.TCPPort(nil), ports...)}
+}
+
+// Next implements PortIterator.
+func (it *ListIterator) Next() (layers.TCPPort, bool) {
+	if len(it.ports) == 0 {
+		return 0, false
+	}
+	p := it.ports[0]
+	it.ports = it.ports[1:]
+	return p, true
+}
+
+// RandomIterator yields the ports of a list in a random order.
+type RandomIterator struct {
+	ListIterator
+}
+
+// NewRandomIterator returns an iterator over ports in an order shuffled
+// with a generator seeded with seed, so that the same seed gives the same
+// order.
+func NewRandomIterator(ports []layers.TCPPort, seed int64) *RandomIterator {
+	it := &RandomIterator{ListIterator: *NewListIterator(ports)}
+	rand.New(rand.NewSource(seed)).Shuffle(len(it.ports), func(i, j int) {
+		it.ports[i], it.ports[j] = it.ports[j], it.ports[i]
+	})
+	return it
+}
+
+// TopNIterator yields the ports found open most often on the Internet, most
+// frequent first, like nmap's --top-ports.
+type TopNIterator struct {
+	ListIterator
+}
+
+// NewTopNIterator returns an iterator over the n most frequently open
+// ports. At most 100 ports are known.
+func NewTopNIterator(n int) *TopNIterator {
+	if n > len(topPorts) {
+		n = len(topPorts)
+	}
+	if n < 0 {
+		n = 0
+	}
+	return &TopNIterator{ListIterator: *NewListIterator(topPorts[:n])}
+}
+
+// InterleavedIterator splits a range of ports into a low and a high half and
+// alternates between them, so that consecutive probes do not hit
+// neighbouring ports, which some rate limiters and scan detectors watch for.
+type InterleavedIterator struct {
+	low, high *SequentialIterator
+	fromHigh  bool
+}
+
+// NewInterleavedIterator returns an iterator over the ports in [first,
+// last], yielding first, then the first port of the high half, then
+// first+1, and so on.
+func NewInterleavedIterator(first, last layers.TCPPort) *InterleavedIterator {
+	mid := (int(first) + int(last)) / 2
+	return &InterleavedIterator{
+		low:  &SequentialIterator{next: int(first), last: mid},
+		high: &SequentialIterator{next: mid + 1, last: int(last)},
+	}
+}
+
+// Next implements PortIterator.
+func (it *InterleavedIterator) Next() (layers.TCPPort, bool) {
+	first, second := it.low, it.high
+	if it.fromHigh {
+		first, second = it.high, it.low
+	}
+	it.fromHigh = !it.fromHigh
+	if p, ok := first.Next(); ok {
+		return p, true
+	}
+	return second.Next()
+}
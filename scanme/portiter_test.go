@@ -0,0 +1,96 @@
+package scanme
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/google/gopacket/layers"
+)
+
+// drain returns the ports it yields.
+func drain(it PortIterator) []layers.TCPPort {
+	var ports []layers.TCPPort
+	for p, ok := it.Next(); ok; p, ok = it.Next() {
+		ports = append(ports, p)
+	}
+	return ports
+}
+
+func TestSequentialIterator(t *testing.T) {
+	if got, want := drain(NewSequentialIterator(20, 25)), []layers.TCPPort{20, 21, 22, 23, 24, 25}; !slices.Equal(got, want) {
+		t.Errorf("ports = %v, want %v", got, want)
+	}
+	if got := drain(NewSequentialIterator(65534, 65535)); !slices.Equal(got, []layers.TCPPort{65534, 65535}) {
+		t.Errorf("ports = %v, want [65534 65535]", got)
+	}
+	if got := drain(NewSequentialIterator(10, 9)); len(got) != 0 {
+		t.Errorf("empty range yields %v", got)
+	}
+}
+
+func TestListIterator(t *testing.T) {
+	list := []layers.TCPPort{443, 22, 80}
+	it := NewListIterator(list)
+	list[0] = 1
+	if got, want := drain(it), []layers.TCPPort{443, 22, 80}; !slices.Equal(got, want) {
+		t.Errorf("ports = %v, want %v", got, want)
+	}
+	if _, ok := it.Next(); ok {
+		t.Error("Next() = true once drained")
+	}
+}
+
+func TestRandomIterator(t *testing.T) {
+	list := []layers.TCPPort{21, 22, 23, 25, 53, 80, 110, 143, 443, 8080}
+	first := drain(NewRandomIterator(list, 7))
+	if second := drain(NewRandomIterator(list, 7)); !slices.Equal(first, second) {
+		t.Errorf("same seed, different orders: %v and %v", first, second)
+	}
+	if slices.Equal(first, list) {
+		t.Errorf("ports were not shuffled: %v", first)
+	}
+	sorted := slices.Clone(first)
+	slices.Sort(sorted)
+	if !slices.Equal(sorted, list) {
+		t.Errorf("ports %v are not a permutation of %v", first, list)
+	}
+}
+
+func TestTopNIterator(t *testing.T) {
+	if got, want := drain(NewTopNIterator(5)), []layers.TCPPort{80, 23, 443, 21, 22}; !slices.Equal(got, want) {
+		t.Errorf("top 5 = %v, want %v", got, want)
+	}
+	if got := drain(NewTopNIterator(1000)); len(got) != len(topPorts) {
+		t.Errorf("top 1000 yields %d ports, want the %d known", len(got), len(topPorts))
+	}
+	if got := drain(NewTopNIterator(-1)); len(got) != 0 {
+		t.Errorf("top -1 yields %v", got)
+	}
+}
+
+func TestInterleavedIterator(t *testing.T) {
+	tests := []struct {
+		first, last layers.TCPPort
+		want        []layers.TCPPort
+	}{
+		{1, 6, []layers.TCPPort{1, 4, 2, 5, 3, 6}},
+		{1, 5, []layers.TCPPort{1, 4, 2, 5, 3}},
+		{80, 80, []layers.TCPPort{80}},
+	}
+	for _, tt := range tests {
+		if got := drain(NewInterleavedIterator(tt.first, tt.last)); !slices.Equal(got, tt.want) {
+			t.Errorf("[%d, %d] = %v, want %v", tt.first, tt.last, got, tt.want)
+		}
+	}
+}
+
+func TestPortIteratorReusedAcrossScans(t *testing.T) {
+	s := newTestScanner(WithPortIterator(NewInterleavedIterator(1, 4)), WithDenyList([]layers.TCPPort{2}))
+	want := []layers.TCPPort{1, 3, 4}
+	if got := s.scanPorts(); !slices.Equal(got, want) {
+		t.Fatalf("first scan probes %v, want %v", got, want)
+	}
+	if got := s.scanPorts(); !slices.Equal(got, want) {
+		t.Errorf("second scan probes %v, want %v", got, want)
+	}
+}
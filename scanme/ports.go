@@ -0,0 +1,189 @@
+package scanme
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/google/gopacket/layers"
+)
+
+// defaultPorts returns the ports [1, 65535] probed by a full scan.
+func defaultPorts() []layers.TCPPort {
+	ports := make([]layers.TCPPort, 0, 65535)
+	for p := 1; p <= 65535; p++ {
+		ports = append(ports, layers.TCPPort(p))
+	}
+	return ports
+}
+
+// ParsePorts parses a comma separated list of ports and port ranges, such
+// as "22,80,8000-8100". The returned ports are sorted and unique. An empty
+// spec yields no ports.
+func ParsePorts(spec string) ([]layers.TCPPort, error) {
+	var ports []layers.TCPPort
+	for _, field := range strings.Split(spec, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		lo, hi, isRange := strings.Cut(field, "-")
+		first, err := parsePort(lo)
+		if err != nil {
+			return nil, err
+		}
+		last := first
+		if isRange {
+			if last, err = parsePort(hi); err != nil {
+				return nil, err
+			}
+			if last < first {
+				return nil, &ErrInvalidPortRange{Spec: field}
+			}
+		}
+		for p := first; p <= last; p++ {
+			ports = append(ports, layers.TCPPort(p))
+		}
+	}
+	return uniquePorts(ports), nil
+}
+
+// parsePort parses a port number in [1, 65535].
+func parsePort(s string) (int, error) {
+	p, err := strconv.Atoi(strings.TrimSpace(s))
+	if err != nil || p < 1 || p > 65535 {
+		return 0, &ErrInvalidPortRange{Spec: s}
+	}
+	return p, nil
+}
+
+// LoadPortListFromFile reads a list of ports from a text file holding one
+// port per line, as accepted by WithAllowList and WithDenyList. Blank lines
+// and lines starting with # are ignored.
+func LoadPortListFromFile(path string) ([]layers.TCPPort, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var ports []layers.TCPPort
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		p, err := parsePort(text)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, line, err)
+		}
+		ports = append(ports, layers.TCPPort(p))
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return ports, nil
+}
+
+// scanPorts returns the ports Synscan probes, in the order it probes them.
+func (s *scanner) scanPorts() []layers.TCPPort {
+	var ports []layers.TCPPort
+	switch {
+	case s.portIterator != nil:
+		// Iterators cannot be rewound: the ports of the first scan are
+		// kept for the following ones.
+		if s.iteratedPorts == nil {
+			s.iteratedPorts = []layers.TCPPort{}
+			for p, ok := s.portIterator.Next(); ok; p, ok = s.portIterator.Next() {
+				if p != 0 {
+					s.iteratedPorts = append(s.iteratedPorts, p)
+				}
+			}
+		}
+		ports = append(ports, s.iteratedPorts...)
+	case len(s.ports) > 0:
+		ports = append(ports, s.ports...)
+	case len(s.allowList) > 0:
+		for p := range s.allowList {
+			ports = append(ports, p)
+		}
+		ports = uniquePorts(ports)
+	default:
+		ports = defaultPorts()
+	}
+
+	filtered := ports[:0]
+	for _, p := range ports {
+		if s.allowed(p) {
+			filtered = append(filtered, p)
+		}
+	}
+	ports = filtered
+
+	if s.randomOrder && s.portIterator == nil {
+		s.shufflePorts(ports)
+	}
+	return append(ports, s.extraPorts(ports)...)
+}
+
+// allowed reports whether port passes the allow and deny lists.
+func (s *scanner) allowed(port layers.TCPPort) bool {
+	if s.denyList[port] {
+		return false
+	}
+	return len(s.allowList) == 0 || s.allowList[port]
+}
+
+// portSet returns the set of ports in ports.
+func portSet(ports []layers.TCPPort) map[layers.TCPPort]bool {
+	set := make(map[layers.TCPPort]bool, len(ports))
+	for _, p := range ports {
+		set[p] = true
+	}
+	return set
+}
+
+// uniquePorts returns a sorted copy of ports with duplicates and port 0 removed.
+func uniquePorts(ports []layers.TCPPort) []layers.TCPPort {
+	seen := make(map[layers.TCPPort]bool, len(ports))
+	unique := make([]layers.TCPPort, 0, len(ports))
+	for _, p := range ports {
+		if p == 0 || seen[p] {
+			continue
+		}
+		seen[p] = true
+		unique = append(unique, p)
+	}
+	sort.Slice(unique, func(i, j int) bool { return unique[i] < unique[j] })
+	return unique
+}
+
+// shufflePorts randomizes the order of ports in place with a Fisher-Yates
+// shuffle, drawing from the generator seeded with WithRandomSeed if any.
+func (s *scanner) shufflePorts(ports []layers.TCPPort) {
+	for i := len(ports) - 1; i > 0; i-- {
+		j := s.rng.Intn(i + 1)
+		ports[i], ports[j] = ports[j], ports[i]
+	}
+}
+
+// describePorts summarizes ports as "min-max" for tracing.
+func describePorts(ports []layers.TCPPort) string {
+	if len(ports) == 0 {
+		return ""
+	}
+	lo, hi := ports[0], ports[0]
+	for _, p := range ports {
+		if p < lo {
+			lo = p
+		}
+		if p > hi {
+			hi = p
+		}
+	}
+	return fmt.Sprintf("%d-%d", lo, hi)
+}
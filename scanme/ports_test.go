@@ -0,0 +1,63 @@
+package scanme
+
+import (
+	"log/slog"
+	"slices"
+	"testing"
+
+	"github.com/google/gopacket/layers"
+)
+
+// newTestScanner returns a scanner configured with options, without
+// opening anything.
+func newTestScanner(options ...Option) *scanner {
+	s := &scanner{
+		tcpsequencer: NewTCPSequencer(),
+		done:         make(chan struct{}),
+		draining:     make(chan struct{}),
+		logger:       slog.Default(),
+	}
+	for _, option := range options {
+		option(s)
+	}
+	if s.rng == nil {
+		s.rng = newRand()
+	}
+	return s
+}
+
+func TestRandomOrderIsReproducible(t *testing.T) {
+	list := []layers.TCPPort{22, 25, 53, 80, 110, 143, 443, 993, 3306, 8080}
+	first := newTestScanner(WithPortList(list), WithRandomOrder(), WithRandomSeed(42)).scanPorts()
+	second := newTestScanner(WithPortList(list), WithRandomOrder(), WithRandomSeed(42)).scanPorts()
+	if !slices.Equal(first, second) {
+		t.Errorf("same seed, different orders: %v and %v", first, second)
+	}
+	if slices.Equal(first, list) {
+		t.Errorf("ports were not shuffled: %v", first)
+	}
+	sorted := slices.Clone(first)
+	slices.Sort(sorted)
+	if !slices.Equal(sorted, list) {
+		t.Errorf("shuffled ports %v are not a permutation of %v", first, list)
+	}
+}
+
+// BenchmarkScanPorts compares listing the 65535 ports in order with
+// shuffling them for WithRandomOrder.
+func BenchmarkScanPorts(b *testing.B) {
+	b.Run("sequential", func(b *testing.B) {
+		s := newTestScanner()
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			s.scanPorts()
+		}
+	})
+	b.Run("shuffled", func(b *testing.B) {
+		s := newTestScanner(WithRandomOrder(), WithRandomSeed(1))
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			s.scanPorts()
+		}
+	})
+}
@@ -0,0 +1,103 @@
+package scanme
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"strconv"
+
+	"github.com/google/gopacket/layers"
+)
+
+// postgresUser is the user PostgreSQLProbe connects as, the superuser of
+// default installations.
+const postgresUser = "postgres"
+
+// PostgreSQLProbe sends a startup message for the postgres user to the
+// PostgreSQL server on port of the target. A server asking for no password,
+// such as with trust authentication, allows anonymous access: its version
+// and databases are then read.
+func (s *scanner) PostgreSQLProbe(ctx context.Context, port layers.TCPPort) (*DatabaseInfo, error) {
+	ctx, cancel := withDatabaseTimeout(ctx)
+	defer cancel()
+	conn, err := dialICS(ctx, s.dst, strconv.Itoa(int(port)))
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	startup := binary.BigEndian.AppendUint32(nil, 0)
+	startup = binary.BigEndian.AppendUint32(startup, 3<<16) // protocol 3.0
+	startup = append(startup, "user\x00"+postgresUser+"\x00database\x00postgres\x00\x00"...)
+	binary.BigEndian.PutUint32(startup, uint32(len(startup)))
+	if _, err := conn.Write(startup); err != nil {
+		return nil, err
+	}
+
+	typ, msg, err := readPostgresMessage(conn)
+	if err != nil || (typ != 'R' && typ != 'E') {
+		return nil, ErrNotDatabase
+	}
+	info := &DatabaseInfo{Type: DatabasePostgreSQL}
+	// An error, such as no pg_hba.conf entry for the host, or a request
+	// for a password.
+	if typ == 'E' || len(msg) < 4 || binary.BigEndian.Uint32(msg) != 0 {
+		info.AuthRequired = true
+		return info, nil
+	}
+	info.AnonymousAccessAllowed = true
+	s.flagAnonymous(info, port)
+
+	// Parameters, among which the version, until the server is ready.
+	for typ != 'Z' {
+		if typ, msg, err = readPostgresMessage(conn); err != nil {
+			return info, nil
+		}
+		if typ == 'S' {
+			if name, value, ok := bytes.Cut(msg, []byte{0}); ok && string(name) == "server_version" {
+				info.Version = string(bytes.TrimRight(value, "\x00"))
+			}
+		}
+	}
+
+	query := append([]byte("SELECT datname FROM pg_database"), 0)
+	q := append([]byte{'Q'}, binary.BigEndian.AppendUint32(nil, uint32(4+len(query)))...)
+	if _, err := conn.Write(append(q, query...)); err != nil {
+		return info, nil
+	}
+	for typ = 0; typ != 'Z'; {
+		if typ, msg, err = readPostgresMessage(conn); err != nil {
+			break
+		}
+		// DataRow: the number of columns, then the length and value of
+		// each.
+		if typ == 'D' && len(msg) >= 6 {
+			n := int32(binary.BigEndian.Uint32(msg[2:]))
+			if n >= 0 && int(n) <= len(msg)-6 {
+				info.DatabaseList = append(info.DatabaseList, string(msg[6:6+n]))
+			}
+		}
+	}
+	conn.Write([]byte{'X', 0, 0, 0, 4}) // Terminate
+	return info, nil
+}
+
+// readPostgresMessage reads a message of the PostgreSQL protocol from conn
+// and returns its type and body.
+func readPostgresMessage(conn net.Conn) (byte, []byte, error) {
+	var header [5]byte
+	if _, err := io.ReadFull(conn, header[:]); err != nil {
+		return 0, nil, err
+	}
+	n := binary.BigEndian.Uint32(header[1:])
+	if n < 4 || n > 1<<20 {
+		return 0, nil, ErrNotDatabase
+	}
+	body := make([]byte, n-4)
+	if _, err := io.ReadFull(conn, body); err != nil {
+		return 0, nil, err
+	}
+	return header[0], body, nil
+}
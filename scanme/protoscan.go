@@ -0,0 +1,156 @@
+package scanme
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"github.com/google/gopacket/pcap"
+)
+
+// protoScanSettle is how long ProtoScan keeps listening for ICMP
+// protocol-unreachable replies after the last probe has been sent.
+const protoScanSettle = 2 * time.Second
+
+// ProtoScanResult holds the outcome of an IP protocol scan. Protocols maps
+// each probed IP protocol number to its state: "open" when no ICMP
+// protocol-unreachable was received, "closed" when one was.
+type ProtoScanResult struct {
+	Protocols map[uint8]string
+}
+
+// DefaultProtocols returns the IP protocol numbers 1 to 255.
+func DefaultProtocols() []uint8 {
+	protocols := make([]uint8, 0, 255)
+	for p := 1; p <= 255; p++ {
+		protocols = append(protocols, uint8(p))
+	}
+	return protocols
+}
+
+// ProtoScan performs an IP protocol scan (nmap -sO). For every protocol number
+// it sends a bare IPv4 packet with an empty payload and listens for ICMP
+// destination unreachable messages with code 2 (protocol unreachable).
+// Protocols that never trigger such a reply are reported as "open".
+// When protocols is empty, DefaultProtocols is used.
+func (s *scanner) ProtoScan(ctx context.Context, protocols []uint8) (*ProtoScanResult, error) {
+	if len(protocols) == 0 {
+		protocols = DefaultProtocols()
+	}
+
+	mac, err := s.sendARPRequest(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	handle, err := openLive(s.iface.Name)
+	if err != nil {
+		return nil, err
+	}
+	defer handle.Close()
+
+	// Only ICMP destination unreachable messages (type 3) are interesting here.
+	bpfFilter := "icmp and icmp[0] == 3"
+	if err := handle.SetBPFFilter(s.bpfFilter(bpfFilter)); err != nil {
+		return nil, err
+	}
+
+	result := &ProtoScanResult{Protocols: make(map[uint8]string, len(protocols))}
+	for _, p := range protocols {
+		result.Protocols[p] = "open"
+	}
+
+	eth := layers.Ethernet{
+		SrcMAC:       s.iface.HardwareAddr,
+		DstMAC:       mac,
+		EthernetType: layers.EthernetTypeIPv4,
+	}
+
+	for _, p := range protocols {
+		select {
+		case <-ctx.Done():
+			return result, ctx.Err()
+		default:
+		}
+
+		ip4 := layers.IPv4{
+			SrcIP:    s.src,
+			DstIP:    s.dst,
+			Version:  4,
+			TTL:      s.ttl,
+			Protocol: layers.IPProtocol(p),
+		}
+		if err := s.send(&eth, &ip4); err != nil {
+			s.logger.Error("error sending protocol probe", "protocol", p, "err", err)
+		}
+	}
+
+	deadline := time.Now().Add(protoScanSettle)
+	for time.Now().Before(deadline) {
+		select {
+		case <-ctx.Done():
+			return result, ctx.Err()
+		default:
+		}
+
+		data, _, err := handle.ReadPacketData()
+		if err == pcap.NextErrorTimeoutExpired {
+			continue
+		} else if err != nil {
+			s.logger.Error("error reading packet", "err", err)
+			continue
+		}
+		s.metrics.PacketReceived(s.dst.String())
+
+		if proto, ok := s.protoUnreachable(data); ok {
+			if _, probed := result.Protocols[proto]; probed {
+				result.Protocols[proto] = "closed"
+			}
+		}
+	}
+
+	return result, nil
+}
+
+// protoUnreachable reports whether data is an ICMP protocol-unreachable
+// message sent by the target in response to one of our probes, and if so
+// returns the protocol number of the original datagram.
+func (s *scanner) protoUnreachable(data []byte) (uint8, bool) {
+	var eth layers.Ethernet
+	var dot1q layers.Dot1Q
+	var ip4 layers.IPv4
+	var icmp layers.ICMPv4
+	var payload gopacket.Payload
+	parser := gopacket.NewDecodingLayerParser(layers.LayerTypeEthernet, &eth, &dot1q, &ip4, &icmp, &payload)
+	parser.IgnoreUnsupported = true
+	decoded := []gopacket.LayerType{}
+
+	//nolint:staticcheck // SA9003 ignore this!
+	if err := parser.DecodeLayers(data, &decoded); err != nil {
+		// Errors here are due to the decoder, and not all layers are implemented.
+	}
+
+	var sawICMP bool
+	for _, typ := range decoded {
+		if typ == layers.LayerTypeICMPv4 {
+			sawICMP = true
+		}
+	}
+	if !sawICMP || !ip4.SrcIP.Equal(s.dst) {
+		return 0, false
+	}
+	if icmp.TypeCode != layers.CreateICMPv4TypeCode(layers.ICMPv4TypeDestinationUnreachable, layers.ICMPv4CodeProtocol) {
+		return 0, false
+	}
+
+	// The ICMP payload carries the IP header of the datagram that triggered it.
+	var orig layers.IPv4
+	if err := orig.DecodeFromBytes(icmp.Payload, gopacket.NilDecodeFeedback); err != nil {
+		return 0, false
+	}
+	if !orig.SrcIP.Equal(s.src) || !orig.DstIP.Equal(s.dst) {
+		return 0, false
+	}
+	return uint8(orig.Protocol), true
+}
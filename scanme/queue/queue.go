@@ -0,0 +1,170 @@
+// Package queue decouples the serialization of packets from their
+// injection: packets are queued by priority and written to the network by a
+// dedicated goroutine, so that control packets such as ARP requests are not
+// held up behind a burst of scan probes.
+package queue
+
+import (
+	"errors"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+)
+
+// Priority orders the packets of a SendQueue.
+type Priority int
+
+const (
+	// PriorityLow is the priority of expendable packets, such as decoy
+	// probes, dropped when the queue is full.
+	PriorityLow Priority = iota
+	// PriorityNormal is the priority of scan probes, which wait for room
+	// when the queue is full.
+	PriorityNormal
+	// PriorityHigh is the priority of control packets, such as ARP and ICMP,
+	// sent before any queued probe and never dropped.
+	PriorityHigh
+)
+
+var (
+	// ErrQueueFull is returned by Enqueue when a packet of low priority is
+	// dropped because the queue is full.
+	ErrQueueFull = errors.New("send queue full")
+	// ErrQueueClosed is returned by Enqueue once the queue is closed.
+	ErrQueueClosed = errors.New("send queue closed")
+)
+
+// SendQueue holds packets waiting to be written, up to its size of high
+// priority and as many of lower priorities, and writes them from its own
+// goroutine, highest priority first.
+type SendQueue struct {
+	high, normal chan []byte
+	write        func([]byte) error
+	logger       *slog.Logger
+	dropped      atomic.Int64
+
+	mu       sync.RWMutex
+	closed   bool
+	done     chan struct{}
+	finished chan struct{}
+}
+
+// New returns a queue holding up to size packets of high priority and as
+// many of lower priorities, and starts the goroutine passing them to write.
+// Write errors are logged to logger, slog.Default when nil.
+func New(write func([]byte) error, size int, logger *slog.Logger) *SendQueue {
+	if size < 1 {
+		size = 1
+	}
+	if logger == nil {
+		logger = slog.Default()
+	}
+	q := &SendQueue{
+		high:     make(chan []byte, size),
+		normal:   make(chan []byte, size),
+		write:    write,
+		logger:   logger,
+		done:     make(chan struct{}),
+		finished: make(chan struct{}),
+	}
+	go q.run()
+	return q
+}
+
+// Enqueue queues a copy of data. When the queue is full, packets of normal
+// and high priority wait for room, which holds back the caller until the
+// packets before them are written, while packets of low priority are
+// dropped and ErrQueueFull returned.
+func (q *SendQueue) Enqueue(data []byte, p Priority) error {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	if q.closed {
+		return ErrQueueClosed
+	}
+	packet := append([]byte(nil), data...)
+	queue := q.normal
+	switch p {
+	case PriorityLow:
+		select {
+		case q.normal <- packet:
+			return nil
+		default:
+			q.dropped.Add(1)
+			return ErrQueueFull
+		}
+	case PriorityHigh:
+		queue = q.high
+	}
+	select {
+	case queue <- packet:
+		return nil
+	case <-q.done:
+		return ErrQueueClosed
+	}
+}
+
+// Dropped returns the number of packets dropped because the queue was full.
+func (q *SendQueue) Dropped() int64 {
+	return q.dropped.Load()
+}
+
+// Close writes the packets still queued and stops the queue.
+func (q *SendQueue) Close() {
+	q.mu.Lock()
+	if q.closed {
+		q.mu.Unlock()
+		<-q.finished
+		return
+	}
+	q.closed = true
+	close(q.done)
+	q.mu.Unlock()
+	<-q.finished
+}
+
+// run writes queued packets until the queue is closed, then flushes it.
+func (q *SendQueue) run() {
+	defer close(q.finished)
+	for {
+		// Packets of high priority always go first.
+		select {
+		case packet := <-q.high:
+			q.send(packet)
+			continue
+		default:
+		}
+		select {
+		case packet := <-q.high:
+			q.send(packet)
+		case packet := <-q.normal:
+			q.send(packet)
+		case <-q.done:
+			q.flush()
+			return
+		}
+	}
+}
+
+// flush writes the packets left once the queue is closed, which no longer
+// accepts any.
+func (q *SendQueue) flush() {
+	for {
+		select {
+		case packet := <-q.high:
+			q.send(packet)
+		default:
+			select {
+			case packet := <-q.normal:
+				q.send(packet)
+			default:
+				return
+			}
+		}
+	}
+}
+
+func (q *SendQueue) send(packet []byte) {
+	if err := q.write(packet); err != nil {
+		q.logger.Error("error sending packet", "err", err)
+	}
+}
@@ -0,0 +1,118 @@
+package queue
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// gatedWriter records the packets written, each write waiting for a value
+// on release first.
+type gatedWriter struct {
+	release chan struct{}
+	mu      sync.Mutex
+	written []string
+}
+
+func newGatedWriter() *gatedWriter {
+	return &gatedWriter{release: make(chan struct{})}
+}
+
+func (w *gatedWriter) write(data []byte) error {
+	<-w.release
+	w.mu.Lock()
+	w.written = append(w.written, string(data))
+	w.mu.Unlock()
+	return nil
+}
+
+func (w *gatedWriter) packets() []string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return append([]string(nil), w.written...)
+}
+
+// fill queues packets of normal priority until the queue of size packets is
+// full, one of them being held by the writer.
+func fill(t *testing.T, q *SendQueue, size int) {
+	t.Helper()
+	for i := 0; i <= size; i++ {
+		if err := q.Enqueue([]byte("fill"), PriorityNormal); err != nil {
+			t.Fatalf("Enqueue() error = %v", err)
+		}
+	}
+	// Wait for the writer to take the first packet, leaving size queued.
+	for len(q.normal) != size {
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestEnqueueNormalWaitsForRoom(t *testing.T) {
+	w := newGatedWriter()
+	q := New(w.write, 2, nil)
+	fill(t, q, 2)
+
+	queued := make(chan error, 1)
+	go func() { queued <- q.Enqueue([]byte("probe"), PriorityNormal) }()
+	select {
+	case err := <-queued:
+		t.Fatalf("Enqueue() on a full queue returned %v, want it to wait", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	w.release <- struct{}{}
+	if err := <-queued; err != nil {
+		t.Fatalf("Enqueue() error = %v", err)
+	}
+	close(w.release)
+	q.Close()
+
+	if got := w.packets(); len(got) != 4 || got[3] != "probe" {
+		t.Errorf("written = %q, want the probe last", got)
+	}
+	if q.Dropped() != 0 {
+		t.Errorf("Dropped() = %d, want 0", q.Dropped())
+	}
+}
+
+func TestEnqueueLowDroppedWhenFull(t *testing.T) {
+	w := newGatedWriter()
+	q := New(w.write, 2, nil)
+	fill(t, q, 2)
+
+	if err := q.Enqueue([]byte("decoy"), PriorityLow); !errors.Is(err, ErrQueueFull) {
+		t.Errorf("Enqueue() error = %v, want ErrQueueFull", err)
+	}
+	if q.Dropped() != 1 {
+		t.Errorf("Dropped() = %d, want 1", q.Dropped())
+	}
+	close(w.release)
+	q.Close()
+}
+
+func TestEnqueueHighFirst(t *testing.T) {
+	w := newGatedWriter()
+	q := New(w.write, 4, nil)
+	fill(t, q, 4)
+	if err := q.Enqueue([]byte("arp"), PriorityHigh); err != nil {
+		t.Fatalf("Enqueue() error = %v", err)
+	}
+	close(w.release)
+	q.Close()
+
+	// The packet held by the writer goes first, then the ARP request.
+	if got := w.packets(); len(got) != 6 || got[1] != "arp" {
+		t.Errorf("written = %q, want the ARP request second", got)
+	}
+}
+
+func TestEnqueueAfterClose(t *testing.T) {
+	q := New(func([]byte) error { return nil }, 1, nil)
+	q.Close()
+	for _, p := range []Priority{PriorityLow, PriorityNormal, PriorityHigh} {
+		if err := q.Enqueue([]byte("late"), p); !errors.Is(err, ErrQueueClosed) {
+			t.Errorf("Enqueue() with priority %d error = %v, want ErrQueueClosed", p, err)
+		}
+	}
+}
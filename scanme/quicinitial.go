@@ -0,0 +1,179 @@
+package scanme
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+)
+
+// quicV1 is the version number of QUIC version 1 (RFC 9000).
+const quicV1 = 0x00000001
+
+// quicMinInitialSize is the size clients must pad Initial packets to.
+const quicMinInitialSize = 1200
+
+// quicV1InitialSalt is the salt deriving the Initial secrets (RFC 9001 5.2).
+var quicV1InitialSalt = []byte{
+	0x38, 0x76, 0x2c, 0xf7, 0xf5, 0x59, 0x34, 0xb3, 0x4d, 0x17,
+	0x9a, 0xe6, 0xa4, 0xc8, 0x0c, 0xad, 0xcc, 0xbb, 0x7f, 0x0a,
+}
+
+// quicInitial builds a QUIC v1 Initial packet carrying a TLS 1.3
+// ClientHello offering the "h3" ALPN, protected with the Initial keys
+// derived from dcid as described in RFC 9001.
+func quicInitial(dcid, scid []byte) ([]byte, error) {
+	hello, err := quicClientHello(scid)
+	if err != nil {
+		return nil, err
+	}
+
+	// CRYPTO frame holding the ClientHello at offset 0.
+	payload := []byte{0x06, 0x00}
+	payload = appendVarint(payload, uint64(len(hello)))
+	payload = append(payload, hello...)
+
+	const pnLen = 4
+	header := []byte{0xc0 | (pnLen - 1)}
+	header = binary.BigEndian.AppendUint32(header, quicV1)
+	header = append(header, byte(len(dcid)))
+	header = append(header, dcid...)
+	header = append(header, byte(len(scid)))
+	header = append(header, scid...)
+	header = append(header, 0x00) // token length
+
+	// Pad the payload with PADDING frames so the datagram reaches the
+	// minimum size, leaving room for the length field, packet number and
+	// AEAD tag.
+	if pad := quicMinInitialSize - (len(header) + 2 + pnLen + len(payload) + 16); pad > 0 {
+		payload = append(payload, make([]byte, pad)...)
+	}
+	header = binary.BigEndian.AppendUint16(header, 0x4000|uint16(pnLen+len(payload)+16))
+	pnOffset := len(header)
+	header = append(header, 0, 0, 0, 0) // packet number 0
+
+	key, iv, hp := quicClientInitialKeys(dcid)
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	// The nonce is the IV XORed with the packet number, here 0.
+	packet := aead.Seal(header, iv, payload, header)
+
+	// Header protection masks the low bits of the first byte and the
+	// packet number with a sample of the ciphertext.
+	hpBlock, err := aes.NewCipher(hp)
+	if err != nil {
+		return nil, err
+	}
+	mask := make([]byte, aes.BlockSize)
+	hpBlock.Encrypt(mask, packet[pnOffset+4:pnOffset+4+aes.BlockSize])
+	packet[0] ^= mask[0] & 0x0f
+	for i := 0; i < pnLen; i++ {
+		packet[pnOffset+i] ^= mask[1+i]
+	}
+	return packet, nil
+}
+
+// quicClientInitialKeys derives the client Initial packet protection key,
+// IV and header protection key from the destination connection ID.
+func quicClientInitialKeys(dcid []byte) (key, iv, hp []byte) {
+	initial := hkdfExtract(quicV1InitialSalt, dcid)
+	client := hkdfExpandLabel(initial, "client in", 32)
+	return hkdfExpandLabel(client, "quic key", 16),
+		hkdfExpandLabel(client, "quic iv", 12),
+		hkdfExpandLabel(client, "quic hp", 16)
+}
+
+func hkdfExtract(salt, ikm []byte) []byte {
+	mac := hmac.New(sha256.New, salt)
+	mac.Write(ikm)
+	return mac.Sum(nil)
+}
+
+// hkdfExpandLabel implements the TLS 1.3 HKDF-Expand-Label with an empty
+// context, for lengths up to one SHA-256 block.
+func hkdfExpandLabel(secret []byte, label string, length int) []byte {
+	label = "tls13 " + label
+	info := binary.BigEndian.AppendUint16(nil, uint16(length))
+	info = append(info, byte(len(label)))
+	info = append(info, label...)
+	info = append(info, 0x00)
+
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(info)
+	mac.Write([]byte{0x01})
+	return mac.Sum(nil)[:length]
+}
+
+// quicClientHello returns a minimal TLS 1.3 ClientHello handshake message
+// for QUIC, advertising scid in the transport parameters.
+func quicClientHello(scid []byte) ([]byte, error) {
+	priv, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	random := make([]byte, 32)
+	if _, err := rand.Read(random); err != nil {
+		return nil, err
+	}
+
+	var exts []byte
+	// supported_groups: x25519
+	exts = appendTLSExtension(exts, 10, []byte{0x00, 0x02, 0x00, 0x1d})
+	// signature_algorithms: ecdsa_secp256r1_sha256, rsa_pss_rsae_sha256, rsa_pkcs1_sha256
+	exts = appendTLSExtension(exts, 13, []byte{0x00, 0x06, 0x04, 0x03, 0x08, 0x04, 0x04, 0x01})
+	// application_layer_protocol_negotiation: h3
+	exts = appendTLSExtension(exts, 16, []byte{0x00, 0x03, 0x02, 'h', '3'})
+	// supported_versions: TLS 1.3
+	exts = appendTLSExtension(exts, 43, []byte{0x02, 0x03, 0x04})
+	// key_share: x25519
+	pub := priv.PublicKey().Bytes()
+	share := binary.BigEndian.AppendUint16(nil, uint16(4+len(pub)))
+	share = append(share, 0x00, 0x1d)
+	share = binary.BigEndian.AppendUint16(share, uint16(len(pub)))
+	share = append(share, pub...)
+	exts = appendTLSExtension(exts, 51, share)
+	// quic_transport_parameters: initial_source_connection_id
+	params := appendVarint(nil, 0x0f)
+	params = appendVarint(params, uint64(len(scid)))
+	params = append(params, scid...)
+	exts = appendTLSExtension(exts, 57, params)
+
+	body := []byte{0x03, 0x03} // legacy_version
+	body = append(body, random...)
+	body = append(body, 0x00)                   // legacy_session_id
+	body = append(body, 0x00, 0x02, 0x13, 0x01) // TLS_AES_128_GCM_SHA256
+	body = append(body, 0x01, 0x00)             // null compression
+	body = binary.BigEndian.AppendUint16(body, uint16(len(exts)))
+	body = append(body, exts...)
+
+	msg := []byte{0x01, byte(len(body) >> 16), byte(len(body) >> 8), byte(len(body))}
+	return append(msg, body...), nil
+}
+
+func appendTLSExtension(b []byte, typ uint16, data []byte) []byte {
+	b = binary.BigEndian.AppendUint16(b, typ)
+	b = binary.BigEndian.AppendUint16(b, uint16(len(data)))
+	return append(b, data...)
+}
+
+// appendVarint appends v in the QUIC variable-length integer encoding.
+func appendVarint(b []byte, v uint64) []byte {
+	switch {
+	case v < 1<<6:
+		return append(b, byte(v))
+	case v < 1<<14:
+		return binary.BigEndian.AppendUint16(b, uint16(v)|0x4000)
+	case v < 1<<30:
+		return binary.BigEndian.AppendUint32(b, uint32(v)|0x80000000)
+	}
+	return binary.BigEndian.AppendUint64(b, v|0xc000000000000000)
+}
@@ -0,0 +1,206 @@
+package scanme
+
+import (
+	"context"
+	"encoding/binary"
+	"time"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"github.com/google/gopacket/pcap"
+)
+
+// quicScanSettle is how long QUICScan keeps listening for replies after the
+// last probe has been sent.
+const quicScanSettle = 2 * time.Second
+
+// QUICScanResult holds the outcome of a QUIC scan. Ports maps each probed
+// UDP port to its state: "quic-open" when a QUIC server answered,
+// "closed" when an ICMP port unreachable was received and "open|filtered"
+// when nothing came back. Versions lists the QUIC versions seen in the
+// replies of each "quic-open" port, i.e. the versions offered in a Version
+// Negotiation packet or the version of the packets sent by the server.
+type QUICScanResult struct {
+	Ports    map[layers.UDPPort]string
+	Versions map[layers.UDPPort][]uint32
+}
+
+// DefaultQUICPorts returns the UDP ports QUIC is commonly served on: HTTPS,
+// DNS over QUIC and the usual alternative HTTPS ports.
+func DefaultQUICPorts() []layers.UDPPort {
+	return []layers.UDPPort{443, 853, 4433, 8443}
+}
+
+// QUICScan detects QUIC servers, which a TCP SYN scan cannot see, by sending
+// a QUIC version 1 Initial packet carrying a TLS ClientHello to every port
+// and listening for a server Initial or a Version Negotiation packet. When
+// ports is empty, DefaultQUICPorts is used.
+func (s *scanner) QUICScan(ctx context.Context, ports []layers.UDPPort) (*QUICScanResult, error) {
+	if len(ports) == 0 {
+		ports = DefaultQUICPorts()
+	}
+
+	mac, err := s.sendARPRequest(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	handle, err := openLive(s.iface.Name)
+	if err != nil {
+		return nil, err
+	}
+	defer handle.Close()
+
+	bpfFilter := "src host " + s.dst.String() + " and (udp or (icmp and icmp[0] == 3))"
+	if err := handle.SetBPFFilter(s.bpfFilter(bpfFilter)); err != nil {
+		return nil, err
+	}
+
+	result := &QUICScanResult{
+		Ports:    make(map[layers.UDPPort]string, len(ports)),
+		Versions: make(map[layers.UDPPort][]uint32),
+	}
+	for _, p := range ports {
+		result.Ports[p] = "open|filtered"
+	}
+
+	srcPort := layers.UDPPort(49152 + s.rng.Intn(16384))
+	eth := layers.Ethernet{
+		SrcMAC:       s.iface.HardwareAddr,
+		DstMAC:       mac,
+		EthernetType: layers.EthernetTypeIPv4,
+	}
+
+	for _, p := range ports {
+		select {
+		case <-ctx.Done():
+			return result, ctx.Err()
+		default:
+		}
+
+		dcid := make([]byte, 8)
+		scid := make([]byte, 8)
+		s.rng.Read(dcid)
+		s.rng.Read(scid)
+		initial, err := quicInitial(dcid, scid)
+		if err != nil {
+			return nil, err
+		}
+
+		ip4 := layers.IPv4{
+			SrcIP:    s.src,
+			DstIP:    s.dst,
+			Version:  4,
+			TTL:      s.ttl,
+			Protocol: layers.IPProtocolUDP,
+		}
+		udp := layers.UDP{SrcPort: srcPort, DstPort: p}
+		if err := udp.SetNetworkLayerForChecksum(&ip4); err != nil {
+			return nil, err
+		}
+		payload := gopacket.Payload(initial)
+		if err := s.send(&eth, &ip4, &udp, &payload); err != nil {
+			s.logger.Error("error sending QUIC probe", "port", p, "err", err)
+		}
+	}
+
+	deadline := time.Now().Add(quicScanSettle)
+	for time.Now().Before(deadline) {
+		select {
+		case <-ctx.Done():
+			return result, ctx.Err()
+		default:
+		}
+
+		data, _, err := handle.ReadPacketData()
+		if err == pcap.NextErrorTimeoutExpired {
+			continue
+		} else if err != nil {
+			s.logger.Error("error reading packet", "err", err)
+			continue
+		}
+		s.metrics.PacketReceived(s.dst.String())
+		s.handleQUICReply(data, srcPort, result)
+	}
+
+	return result, nil
+}
+
+// handleQUICReply records in result what data, captured in reply to the
+// probes sent from srcPort, says about the probed port.
+func (s *scanner) handleQUICReply(data []byte, srcPort layers.UDPPort, result *QUICScanResult) {
+	var eth layers.Ethernet
+	var dot1q layers.Dot1Q
+	var ip4 layers.IPv4
+	var udp layers.UDP
+	var icmp layers.ICMPv4
+	var payload gopacket.Payload
+	parser := gopacket.NewDecodingLayerParser(layers.LayerTypeEthernet, &eth, &dot1q, &ip4, &udp, &icmp, &payload)
+	parser.IgnoreUnsupported = true
+	decoded := []gopacket.LayerType{}
+
+	//nolint:staticcheck // SA9003 ignore this!
+	if err := parser.DecodeLayers(data, &decoded); err != nil {
+		// Errors here are due to the decoder, and not all layers are implemented.
+	}
+
+	for _, typ := range decoded {
+		switch typ {
+		case layers.LayerTypeUDP:
+			if udp.DstPort != srcPort {
+				continue
+			}
+			if _, probed := result.Ports[udp.SrcPort]; !probed {
+				continue
+			}
+			if versions, ok := quicVersions(udp.Payload); ok {
+				result.Ports[udp.SrcPort] = "quic-open"
+				result.Versions[udp.SrcPort] = versions
+			}
+		case layers.LayerTypeICMPv4:
+			if icmp.TypeCode != layers.CreateICMPv4TypeCode(layers.ICMPv4TypeDestinationUnreachable, layers.ICMPv4CodePort) {
+				continue
+			}
+			// The ICMP payload carries the IP header of the datagram that
+			// triggered it, followed by the start of its UDP header.
+			var orig layers.IPv4
+			if err := orig.DecodeFromBytes(icmp.Payload, gopacket.NilDecodeFeedback); err != nil || len(orig.Payload) < 4 {
+				continue
+			}
+			if binary.BigEndian.Uint16(orig.Payload) != uint16(srcPort) {
+				continue
+			}
+			port := layers.UDPPort(binary.BigEndian.Uint16(orig.Payload[2:]))
+			if state, probed := result.Ports[port]; probed && state != "quic-open" {
+				result.Ports[port] = "closed"
+			}
+		}
+	}
+}
+
+// quicVersions parses the long header of a QUIC packet. For a Version
+// Negotiation packet it returns the versions offered by the server,
+// otherwise the version of the packet.
+func quicVersions(b []byte) ([]uint32, bool) {
+	// Long header: form bit set, version, then the connection IDs.
+	if len(b) < 7 || b[0]&0x80 == 0 {
+		return nil, false
+	}
+	version := binary.BigEndian.Uint32(b[1:])
+	if version != 0 {
+		return []uint32{version}, true
+	}
+
+	rest := b[5:]
+	for i := 0; i < 2; i++ {
+		if len(rest) < 1 || len(rest) < 1+int(rest[0]) {
+			return nil, false
+		}
+		rest = rest[1+int(rest[0]):]
+	}
+	var versions []uint32
+	for ; len(rest) >= 4; rest = rest[4:] {
+		versions = append(versions, binary.BigEndian.Uint32(rest))
+	}
+	return versions, len(versions) > 0
+}
@@ -0,0 +1,70 @@
+//go:build integration
+
+package scanme
+
+import (
+	"context"
+	"net"
+	"os"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/google/gopacket/layers"
+	"github.com/google/gopacket/routing"
+)
+
+// TestQUICScanKnownServer probes UDP port 443 of a public QUIC server,
+// cloudflare-quic.com unless SCANME_QUIC_TARGET names another one. It
+// needs root and Internet access, and only runs with the integration build
+// tag:
+//
+//	sudo go test -tags integration -run QUICScanKnownServer ./scanme/
+func TestQUICScanKnownServer(t *testing.T) {
+	if testing.Short() {
+		t.Skip("probing a server over the Internet")
+	}
+	if os.Geteuid() != 0 {
+		t.Skip("sending raw packets needs root")
+	}
+	host := os.Getenv("SCANME_QUIC_TARGET")
+	if host == "" {
+		host = "cloudflare-quic.com"
+	}
+	ips, err := net.LookupIP(host)
+	if err != nil {
+		t.Skipf("resolving %s: %v", host, err)
+	}
+	var ip net.IP
+	for _, a := range ips {
+		if ip = a.To4(); ip != nil {
+			break
+		}
+	}
+	if ip == nil {
+		t.Skipf("%s has no IPv4 address", host)
+	}
+
+	router, err := routing.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := newScanner(ip, router)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	result, err := s.QUICScan(ctx, []layers.UDPPort{443})
+	if err != nil {
+		t.Fatalf("QUICScan() error = %v", err)
+	}
+	if result.Ports[443] != "quic-open" {
+		t.Fatalf("port 443 of %s (%s) is %q, want quic-open", host, ip, result.Ports[443])
+	}
+	if !slices.Contains(result.Versions[443], quicV1) {
+		t.Errorf("Versions[443] = %#x, want QUIC v1 among them", result.Versions[443])
+	}
+}
@@ -0,0 +1,245 @@
+package scanme
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/binary"
+	"encoding/hex"
+	"net"
+	"slices"
+	"testing"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+)
+
+const testQUICPort layers.UDPPort = 50000
+
+// versionNegotiation returns a QUIC Version Negotiation packet offering
+// versions, as sent by a server not supporting the version of a probe.
+func versionNegotiation(dcid, scid []byte, versions ...uint32) []byte {
+	b := []byte{0x80 | 0x4a, 0, 0, 0, 0}
+	b = append(b, byte(len(dcid)))
+	b = append(b, dcid...)
+	b = append(b, byte(len(scid)))
+	b = append(b, scid...)
+	for _, v := range versions {
+		b = binary.BigEndian.AppendUint32(b, v)
+	}
+	return b
+}
+
+func TestQUICVersions(t *testing.T) {
+	dcid, scid := []byte{1, 2, 3, 4, 5, 6, 7, 8}, []byte{9, 10, 11, 12}
+	vn := versionNegotiation(dcid, scid, 0x6b3343cf, quicV1, 0x1a2a3a4a)
+	tests := []struct {
+		name   string
+		packet []byte
+		want   []uint32
+		wantOK bool
+	}{
+		{"version negotiation", vn, []uint32{0x6b3343cf, quicV1, 0x1a2a3a4a}, true},
+		{"version negotiation without connection IDs", versionNegotiation(nil, nil, quicV1), []uint32{quicV1}, true},
+		{"server initial", []byte{0xc3, 0, 0, 0, 1, 8, 1, 2, 3, 4, 5, 6, 7, 8, 0}, []uint32{quicV1}, true},
+		{"draft-29 handshake", []byte{0xe0, 0xff, 0, 0, 29, 0, 0}, []uint32{0xff00001d}, true},
+		{"short header", []byte{0x40, 0, 0, 0, 1, 0, 0, 0}, nil, false},
+		{"too short", []byte{0xc0, 0, 0, 0}, nil, false},
+		{"version negotiation without versions", versionNegotiation(dcid, scid), nil, false},
+		{"truncated connection ID", vn[:8], nil, false},
+		{"DNS reply", []byte{0x12, 0x34, 0x81, 0x80, 0, 1, 0, 1}, nil, false},
+	}
+	for _, tt := range tests {
+		got, ok := quicVersions(tt.packet)
+		if ok != tt.wantOK || !slices.Equal(got, tt.want) {
+			t.Errorf("%s: quicVersions() = %#x, %v, want %#x, %v", tt.name, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+// quicReply crafts a UDP datagram from port of the target to the probe's
+// source port carrying payload.
+func quicReply(t *testing.T, port layers.UDPPort, payload []byte) []byte {
+	t.Helper()
+	ip4 := layers.IPv4{Version: 4, TTL: 64, Protocol: layers.IPProtocolUDP, SrcIP: testTarget, DstIP: testLocal}
+	udp := layers.UDP{SrcPort: port, DstPort: testQUICPort}
+	if err := udp.SetNetworkLayerForChecksum(&ip4); err != nil {
+		t.Fatal(err)
+	}
+	return serialize(t,
+		&layers.Ethernet{
+			SrcMAC:       net.HardwareAddr{0, 1, 2, 3, 4, 6},
+			DstMAC:       net.HardwareAddr{0, 1, 2, 3, 4, 5},
+			EthernetType: layers.EthernetTypeIPv4,
+		},
+		&ip4, &udp, gopacket.Payload(payload),
+	)
+}
+
+// udpUnreachable crafts the ICMP port unreachable the target sends back for
+// a probe from srcPort to dstPort.
+func udpUnreachable(t *testing.T, srcPort, dstPort layers.UDPPort) []byte {
+	t.Helper()
+	probeIP := layers.IPv4{Version: 4, TTL: 64, Protocol: layers.IPProtocolUDP, SrcIP: testLocal, DstIP: testTarget}
+	probeUDP := layers.UDP{SrcPort: srcPort, DstPort: dstPort}
+	if err := probeUDP.SetNetworkLayerForChecksum(&probeIP); err != nil {
+		t.Fatal(err)
+	}
+	quoted := serialize(t, &probeIP, &probeUDP, gopacket.Payload(make([]byte, 32)))[:20+8]
+	return serialize(t,
+		&layers.Ethernet{
+			SrcMAC:       net.HardwareAddr{0, 1, 2, 3, 4, 6},
+			DstMAC:       net.HardwareAddr{0, 1, 2, 3, 4, 5},
+			EthernetType: layers.EthernetTypeIPv4,
+		},
+		&layers.IPv4{Version: 4, TTL: 64, Protocol: layers.IPProtocolICMPv4, SrcIP: testTarget, DstIP: testLocal},
+		&layers.ICMPv4{TypeCode: layers.CreateICMPv4TypeCode(layers.ICMPv4TypeDestinationUnreachable, layers.ICMPv4CodePort)},
+		gopacket.Payload(quoted),
+	)
+}
+
+func TestHandleQUICReply(t *testing.T) {
+	s := newTestScanner()
+	result := &QUICScanResult{
+		Ports:    map[layers.UDPPort]string{443: "open|filtered", 853: "open|filtered", 4433: "open|filtered", 8443: "open|filtered"},
+		Versions: make(map[layers.UDPPort][]uint32),
+	}
+	vn := versionNegotiation([]byte{1, 2, 3, 4}, []byte{5, 6, 7, 8}, 0xff00001d, quicV1)
+	for _, packet := range [][]byte{
+		quicReply(t, 443, vn),
+		quicReply(t, 853, []byte{0xc0, 0, 0, 0, 1, 0, 0, 0}),
+		udpUnreachable(t, testQUICPort, 4433),
+		// Not ours: another source port, a port not probed, and an ICMP
+		// error for a port already found open.
+		udpUnreachable(t, testQUICPort+1, 8443),
+		quicReply(t, 9999, vn),
+		udpUnreachable(t, testQUICPort, 443),
+	} {
+		s.handleQUICReply(packet, testQUICPort, result)
+	}
+
+	want := map[layers.UDPPort]string{443: "quic-open", 853: "quic-open", 4433: "closed", 8443: "open|filtered"}
+	for port, state := range want {
+		if result.Ports[port] != state {
+			t.Errorf("port %d: state %q, want %q", port, result.Ports[port], state)
+		}
+	}
+	if len(result.Ports) != len(want) {
+		t.Errorf("Ports = %v, want only the probed ports", result.Ports)
+	}
+	if got := result.Versions[443]; !slices.Equal(got, []uint32{0xff00001d, quicV1}) {
+		t.Errorf("Versions[443] = %#x, want the versions negotiated", got)
+	}
+	if got := result.Versions[853]; !slices.Equal(got, []uint32{quicV1}) {
+		t.Errorf("Versions[853] = %#x, want [0x1]", got)
+	}
+}
+
+// TestQUICClientInitialKeys checks the keys derived from the connection ID
+// of the example of RFC 9001, appendix A.1.
+func TestQUICClientInitialKeys(t *testing.T) {
+	dcid, _ := hex.DecodeString("8394c8f03e515708")
+	key, iv, hp := quicClientInitialKeys(dcid)
+	for _, k := range []struct {
+		name string
+		got  []byte
+		want string
+	}{
+		{"key", key, "1f369613dd76d5467730efcbe3b1a22d"},
+		{"iv", iv, "fa044b2f42a3fd3b46fb255c"},
+		{"hp", hp, "9f50449e04a0e810283a1e9933adedd2"},
+	} {
+		if hex.EncodeToString(k.got) != k.want {
+			t.Errorf("client %s = %x, want %s", k.name, k.got, k.want)
+		}
+	}
+}
+
+// TestQUICInitial removes the protection of an Initial packet as a server
+// would, and checks that it carries a ClientHello offering h3.
+func TestQUICInitial(t *testing.T) {
+	dcid, scid := []byte{0x83, 0x94, 0xc8, 0xf0, 0x3e, 0x51, 0x57, 0x08}, []byte{1, 2, 3, 4, 5, 6, 7, 8}
+	packet, err := quicInitial(dcid, scid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(packet) < quicMinInitialSize {
+		t.Errorf("Initial of %d bytes, want at least %d", len(packet), quicMinInitialSize)
+	}
+	if packet[0]&0xf0 != 0xc0 {
+		t.Errorf("first byte %#x, want a long header Initial", packet[0])
+	}
+	if versions, ok := quicVersions(packet); !ok || !slices.Equal(versions, []uint32{quicV1}) {
+		t.Errorf("quicVersions(Initial) = %#x, %v, want [0x1]", versions, ok)
+	}
+
+	// Long header: first byte, version, DCID, SCID, token, length.
+	off := 5
+	if !bytes.Equal(packet[off+1:off+1+int(packet[off])], dcid) {
+		t.Fatalf("DCID %x, want %x", packet[off+1:off+1+int(packet[off])], dcid)
+	}
+	off += 1 + len(dcid)
+	if !bytes.Equal(packet[off+1:off+1+int(packet[off])], scid) {
+		t.Fatalf("SCID %x, want %x", packet[off+1:off+1+int(packet[off])], scid)
+	}
+	off += 1 + len(scid)
+	if packet[off] != 0 {
+		t.Fatalf("token length %d, want 0", packet[off])
+	}
+	off++
+	length := int(binary.BigEndian.Uint16(packet[off:]) & 0x3fff)
+	off += 2
+	if off+length != len(packet) {
+		t.Fatalf("length field %d, want %d", length, len(packet)-off)
+	}
+
+	key, iv, hp := quicClientInitialKeys(dcid)
+	hpBlock, err := aes.NewCipher(hp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mask := make([]byte, aes.BlockSize)
+	hpBlock.Encrypt(mask, packet[off+4:off+4+aes.BlockSize])
+	header := append([]byte(nil), packet[:off]...)
+	header[0] ^= mask[0] & 0x0f
+	pnLen := int(header[0]&0x03) + 1
+	var pn uint32
+	for i := 0; i < pnLen; i++ {
+		b := packet[off+i] ^ mask[1+i]
+		header = append(header, b)
+		pn = pn<<8 | uint32(b)
+	}
+	if pn != 0 {
+		t.Errorf("packet number %d, want 0", pn)
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		t.Fatal(err)
+	}
+	payload, err := aead.Open(nil, iv, packet[off+pnLen:], header)
+	if err != nil {
+		t.Fatalf("decrypting the Initial: %v", err)
+	}
+	// A CRYPTO frame at offset 0 holding the ClientHello.
+	if len(payload) < 8 || payload[0] != 0x06 || payload[1] != 0x00 {
+		t.Fatalf("payload starts with %x, want a CRYPTO frame at offset 0", payload[:min(8, len(payload))])
+	}
+	hello := payload[2+quicVarintLen(payload[2]):]
+	if hello[0] != 0x01 {
+		t.Errorf("handshake message type %d, want ClientHello", hello[0])
+	}
+	if !bytes.Contains(hello, []byte{0x00, 0x10, 0x00, 0x05, 0x00, 0x03, 0x02, 'h', '3'}) {
+		t.Error("ClientHello does not offer the h3 ALPN")
+	}
+}
+
+// quicVarintLen returns the length of the QUIC variable-length integer
+// starting with b.
+func quicVarintLen(b byte) int {
+	return 1 << (b >> 6)
+}
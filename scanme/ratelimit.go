@@ -0,0 +1,192 @@
+package scanme
+
+import (
+	"context"
+	cryptorand "crypto/rand"
+	"encoding/binary"
+	"log/slog"
+	"math"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+const (
+	// quenchStartInterval is the interval between probes a scan without rate
+	// limit falls back to on its first ICMP source quench, before halving.
+	quenchStartInterval = 100 * time.Microsecond
+	// quenchFloorInterval is the largest interval between probes adaptive
+	// timing backs off to, i.e. the floor of the send rate.
+	quenchFloorInterval = time.Second
+	// quenchRecovery is the time constant of the exponential recovery of the
+	// send rate after an ICMP source quench.
+	quenchRecovery = 5 * time.Second
+	// quenchMaxSettle bounds the settle time doubled by source quenches.
+	quenchMaxSettle = time.Minute
+)
+
+// pacer spaces out the probes sent by a scan to honour the rate limit set
+// with WithRateLimit.
+//
+// It acts as a token bucket holding a single token, refilled every interval.
+// When the target signals congestion with an ICMP source quench, quench
+// halves the refill rate, which then regains the configured rate
+// exponentially over time.
+type pacer struct {
+	mu       sync.Mutex
+	base     time.Duration
+	interval time.Duration
+	jitter   float64
+	rng      *rand.Rand
+	next     time.Time
+	updated  time.Time
+	events   int
+	atFloor  bool
+	logger   *slog.Logger
+}
+
+// newPacer returns a pacer sending a probe every interval, varied at random
+// by up to ±jitter of it, or without any limit when interval is 0. Warnings
+// go to logger.
+func newPacer(interval time.Duration, jitter float64, logger *slog.Logger) *pacer {
+	return &pacer{base: interval, interval: interval, jitter: jitter, rng: newRand(), logger: logger}
+}
+
+// wait blocks until the next probe may be sent or ctx is done.
+func (p *pacer) wait(ctx context.Context) error {
+	p.mu.Lock()
+	now := time.Now()
+	p.recover(now)
+	if p.interval == 0 {
+		p.mu.Unlock()
+		return ctx.Err()
+	}
+	if p.next.IsZero() || !p.next.After(now) {
+		p.next = now.Add(p.nextInterval())
+		p.mu.Unlock()
+		return ctx.Err()
+	}
+	delay := p.next.Sub(now)
+	p.next = p.next.Add(p.nextInterval())
+	p.mu.Unlock()
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+	}
+	return nil
+}
+
+// quench halves the send rate in response to an ICMP source quench. Once the
+// rate reaches its floor, a warning is logged and the scan goes on at the floor.
+func (p *pacer) quench() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.events++
+	p.halve("ICMP source quench")
+}
+
+// backoff halves the send rate once an IDS seems to rate limit the replies
+// of the target, see WithIDSDetection. Unlike quench, it does not lengthen
+// the settle time.
+func (p *pacer) backoff() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.halve("IDS rate limiting")
+}
+
+// halve halves the send rate, down to its floor, after reason. p.mu must be
+// held.
+func (p *pacer) halve(reason string) {
+	p.recover(time.Now())
+	interval := p.interval
+	if interval == 0 {
+		interval = quenchStartInterval
+	}
+	interval *= 2
+	if floor := p.floor(); interval >= floor {
+		interval = floor
+		if !p.atFloor {
+			p.logger.Warn("send rate reduced to its floor", "packets_per_second", packetRate(floor), "reason", reason)
+		}
+		p.atFloor = true
+	}
+	p.interval = interval
+}
+
+// recover moves the interval back towards the configured one, closing the
+// gap exponentially with the time elapsed since the last update.
+func (p *pacer) recover(now time.Time) {
+	if !p.updated.IsZero() && p.interval > p.base {
+		decay := math.Exp(-float64(now.Sub(p.updated)) / float64(quenchRecovery))
+		p.interval = p.base + time.Duration(float64(p.interval-p.base)*decay)
+		if p.interval-p.base < time.Microsecond {
+			p.interval = p.base
+		}
+		if p.interval < p.floor() {
+			p.atFloor = false
+		}
+	}
+	p.updated = now
+}
+
+// floor returns the largest interval adaptive timing backs off to.
+func (p *pacer) floor() time.Duration {
+	if p.base > quenchFloorInterval {
+		return p.base
+	}
+	return quenchFloorInterval
+}
+
+// rateLimitEvents returns the number of times the send rate was reduced.
+func (p *pacer) rateLimitEvents() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.events
+}
+
+// settle returns base doubled for every source quench received, bounded by
+// quenchMaxSettle.
+func (p *pacer) settle(base time.Duration) time.Duration {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	settle := base
+	for i := 0; i < p.events && settle < quenchMaxSettle; i++ {
+		settle *= 2
+	}
+	if settle > quenchMaxSettle && base < quenchMaxSettle {
+		settle = quenchMaxSettle
+	}
+	return settle
+}
+
+// nextInterval returns the interval before the following probe.
+func (p *pacer) nextInterval() time.Duration {
+	if p.jitter == 0 {
+		return p.interval
+	}
+	offset := p.jitter * (2*p.rng.Float64() - 1)
+	return time.Duration(float64(p.interval) * (1 + offset))
+}
+
+// packetRate returns the number of probes per second sent every interval,
+// 0 meaning no limit.
+func packetRate(interval time.Duration) float64 {
+	if interval == 0 {
+		return 0
+	}
+	return float64(time.Second) / float64(interval)
+}
+
+// newRand returns a math/rand generator seeded from crypto/rand, so that
+// the timing of a scan cannot be predicted from its start time.
+func newRand() *rand.Rand {
+	var seed [8]byte
+	if _, err := cryptorand.Read(seed[:]); err != nil {
+		return rand.New(rand.NewSource(time.Now().UnixNano()))
+	}
+	return rand.New(rand.NewSource(int64(binary.LittleEndian.Uint64(seed[:]))))
+}
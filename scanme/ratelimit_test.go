@@ -0,0 +1,82 @@
+package scanme
+
+import (
+	"log/slog"
+	"math"
+	"math/rand"
+	"testing"
+	"time"
+)
+
+// intervalStats returns the mean, coefficient of variation and extremes of
+// n intervals drawn from p.
+func intervalStats(p *pacer, n int) (mean, cv float64, shortest, longest time.Duration) {
+	shortest = time.Duration(math.MaxInt64)
+	intervals := make([]float64, n)
+	for i := range intervals {
+		d := p.nextInterval()
+		shortest = min(shortest, d)
+		longest = max(longest, d)
+		intervals[i] = float64(d)
+		mean += float64(d)
+	}
+	mean /= float64(n)
+	var variance float64
+	for _, d := range intervals {
+		variance += (d - mean) * (d - mean)
+	}
+	variance /= float64(n - 1)
+	return mean, math.Sqrt(variance) / mean, shortest, longest
+}
+
+func TestPacerJitterVariation(t *testing.T) {
+	const interval = time.Millisecond
+	tests := []struct {
+		jitter float64
+	}{
+		{0}, {0.1}, {0.5}, {1},
+	}
+	for _, tt := range tests {
+		p := newPacer(interval, tt.jitter, slog.Default())
+		p.rng = rand.New(rand.NewSource(1))
+
+		mean, cv, shortest, longest := intervalStats(p, 10000)
+		// Offsets are uniform over ±jitter, whose standard deviation is
+		// jitter/√3.
+		wantCV := tt.jitter / math.Sqrt(3)
+		if math.Abs(cv-wantCV) > 0.01 {
+			t.Errorf("jitter %v: coefficient of variation = %.4f, want %.4f", tt.jitter, cv, wantCV)
+		}
+		if math.Abs(mean-float64(interval)) > 0.02*float64(interval) {
+			t.Errorf("jitter %v: mean interval = %v, want %v", tt.jitter, time.Duration(mean), interval)
+		}
+		lo := time.Duration(float64(interval) * (1 - tt.jitter))
+		hi := time.Duration(float64(interval) * (1 + tt.jitter))
+		if shortest < lo || longest > hi {
+			t.Errorf("jitter %v: intervals within [%v, %v], want within [%v, %v]", tt.jitter, shortest, longest, lo, hi)
+		}
+	}
+}
+
+func TestPacerJitterWithoutRateLimit(t *testing.T) {
+	p := newPacer(0, 0.5, slog.Default())
+	p.rng = rand.New(rand.NewSource(1))
+	for i := 0; i < 100; i++ {
+		if d := p.nextInterval(); d != 0 {
+			t.Fatalf("nextInterval() = %v without rate limit, want 0", d)
+		}
+	}
+}
+
+func TestWithJitterPanicsOutOfRange(t *testing.T) {
+	for _, fraction := range []float64{-0.1, 1.1} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("WithJitter(%v) did not panic", fraction)
+				}
+			}()
+			WithJitter(fraction)
+		}()
+	}
+}
@@ -0,0 +1,52 @@
+package scanme
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"time"
+)
+
+// dnsTimeout bounds the reverse DNS lookup of the target.
+const dnsTimeout = 5 * time.Second
+
+// reverseDNS is the outcome of the reverse DNS lookup of the target.
+type reverseDNS struct {
+	names   []string
+	timeout bool
+}
+
+// lookupAddr resolves the PTR records of ip in the background, so that the
+// lookup overlaps with the scan. The returned channel receives the names
+// found, without trailing dots, once the lookup completes or times out.
+func lookupAddr(ctx context.Context, ip net.IP) <-chan reverseDNS {
+	ch := make(chan reverseDNS, 1)
+	go func() {
+		ctx, cancel := context.WithTimeout(ctx, dnsTimeout)
+		defer cancel()
+
+		var r reverseDNS
+		names, err := net.DefaultResolver.LookupAddr(ctx, ip.String())
+		if err != nil {
+			var dnsErr *net.DNSError
+			r.timeout = errors.Is(err, context.DeadlineExceeded) || errors.As(err, &dnsErr) && dnsErr.IsTimeout
+		}
+		for _, name := range names {
+			r.names = append(r.names, strings.TrimSuffix(name, "."))
+		}
+		ch <- r
+	}()
+	return ch
+}
+
+// setHostnames records the outcome of the reverse DNS lookup in r.
+func (r *ScanResult) setHostnames(rdns reverseDNS) {
+	r.Hostnames = rdns.names
+	if len(rdns.names) > 0 {
+		r.Hostname = rdns.names[0]
+	}
+	if rdns.timeout {
+		r.Stats.DNSTimeouts++
+	}
+}
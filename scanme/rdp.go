@@ -0,0 +1,275 @@
+package scanme
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"time"
+)
+
+// rdpPort is the port of RDP servers.
+const rdpPort = "3389"
+
+// rdpTimeout bounds RDPProbe when ctx has no deadline.
+const rdpTimeout = 10 * time.Second
+
+// The security protocols of RDP, as requested in and selected by the
+// negotiation of the X.224 connection.
+const (
+	rdpProtocolRDP      = 0x0
+	rdpProtocolSSL      = 0x1
+	rdpProtocolHybrid   = 0x2
+	rdpProtocolRDSTLS   = 0x4
+	rdpProtocolHybridEx = 0x8
+)
+
+// The failure codes of RDP_NEG_FAILURE telling which protocol the server
+// requires.
+const (
+	rdpSSLRequired    = 1
+	rdpSSLNotAllowed  = 2
+	rdpHybridRequired = 5
+)
+
+// ErrNotRDP is returned by RDPProbe when the service on port 3389 does not
+// speak RDP.
+var ErrNotRDP = errors.New("not an RDP service")
+
+// RDPInfo describes the RDP server of the target.
+type RDPInfo struct {
+	// NLARequired is set when the server only accepts clients using
+	// Network Level Authentication (CredSSP), which authenticate before a
+	// session is created. Without it, anyone reaching the server gets a
+	// logon screen, exposed to brute force and pre-authentication flaws.
+	NLARequired bool
+	// TLSRequired is set when the server refuses Standard RDP Security.
+	TLSRequired bool
+	// ServerVersion is the Windows version of the server, such as
+	// "10.0.17763", from its NTLM challenge, only known when CredSSP was
+	// negotiated.
+	ServerVersion string
+	// SecurityProtocol is the protocol the server selected among all of
+	// them: "Standard RDP", "TLS", "CredSSP", "RDSTLS" or "CredSSP-EX".
+	SecurityProtocol string
+}
+
+// ServiceHint returns the classification of the server for
+// PortResult.ServiceHint.
+func (r *RDPInfo) ServiceHint() string {
+	if r.NLARequired {
+		return "rdp (NLA)"
+	}
+	return "rdp (NLA not required)"
+}
+
+// RDPProbe connects to port 3389 of the target and negotiates the security
+// of RDP connections: the protocol the server prefers is that selected
+// when every protocol is offered, then connections offering only TLS and
+// only Standard RDP Security tell whether it requires NLA and TLS. When
+// CredSSP is selected, the version of Windows is read from the NTLM
+// challenge of the server; no credentials are sent. The probe uses up to
+// three connections, all completed before the deadline of ctx.
+func (s *scanner) RDPProbe(ctx context.Context) (*RDPInfo, error) {
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, rdpTimeout)
+		defer cancel()
+	}
+
+	all := uint32(rdpProtocolSSL | rdpProtocolHybrid | rdpProtocolHybridEx)
+	neg, conn, err := s.rdpNegotiate(ctx, all)
+	if err != nil {
+		return nil, err
+	}
+	info := &RDPInfo{SecurityProtocol: neg.protocolName()}
+	if neg.ok && (neg.protocol == rdpProtocolHybrid || neg.protocol == rdpProtocolHybridEx) {
+		info.ServerVersion = rdpServerVersion(conn)
+	}
+	conn.Close()
+
+	// Servers not negotiating at all only speak Standard RDP Security.
+	if !neg.present {
+		return info, nil
+	}
+
+	tlsOnly, conn, err := s.rdpNegotiate(ctx, rdpProtocolSSL)
+	if err != nil {
+		return info, nil
+	}
+	conn.Close()
+	info.NLARequired = !tlsOnly.ok && tlsOnly.failure == rdpHybridRequired
+	if info.NLARequired {
+		info.TLSRequired = true
+		return info, nil
+	}
+	if !tlsOnly.ok && tlsOnly.failure == rdpSSLNotAllowed {
+		return info, nil
+	}
+
+	rdpOnly, conn, err := s.rdpNegotiate(ctx, rdpProtocolRDP)
+	if err != nil {
+		return info, nil
+	}
+	conn.Close()
+	info.TLSRequired = !rdpOnly.ok && (rdpOnly.failure == rdpSSLRequired || rdpOnly.failure == rdpHybridRequired)
+	return info, nil
+}
+
+// rdpNegotiation is the outcome of the negotiation of an X.224 connection.
+type rdpNegotiation struct {
+	// present is unset when the Connection Confirm held no negotiation
+	// response, as sent by servers predating it.
+	present bool
+	// ok is set when the server selected protocol, unset when it failed
+	// the negotiation with failure.
+	ok       bool
+	protocol uint32
+	failure  uint32
+}
+
+// protocolName names the protocol selected in n.
+func (n rdpNegotiation) protocolName() string {
+	// Servers refusing every protocol but Standard RDP Security fail the
+	// negotiation with SSL_NOT_ALLOWED_BY_SERVER.
+	if !n.present || (!n.ok && n.failure == rdpSSLNotAllowed) {
+		return "Standard RDP"
+	}
+	if !n.ok {
+		return ""
+	}
+	switch n.protocol {
+	case rdpProtocolRDP:
+		return "Standard RDP"
+	case rdpProtocolSSL:
+		return "TLS"
+	case rdpProtocolHybrid:
+		return "CredSSP"
+	case rdpProtocolRDSTLS:
+		return "RDSTLS"
+	case rdpProtocolHybridEx:
+		return "CredSSP-EX"
+	}
+	return fmt.Sprintf("unknown (%#x)", n.protocol)
+}
+
+// rdpNegotiate sends an X.224 Connection Request offering protocols and
+// returns the negotiation of the Connection Confirm, along with the
+// connection, to be closed by the caller.
+func (s *scanner) rdpNegotiate(ctx context.Context, protocols uint32) (rdpNegotiation, net.Conn, error) {
+	conn, err := dialICS(ctx, s.dst, rdpPort)
+	if err != nil {
+		return rdpNegotiation{}, nil, err
+	}
+	if _, err := conn.Write(rdpConnectionRequest(protocols)); err != nil {
+		conn.Close()
+		return rdpNegotiation{}, nil, err
+	}
+	pdu, err := readTPKT(conn)
+	if err != nil {
+		conn.Close()
+		return rdpNegotiation{}, nil, ErrNotRDP
+	}
+	neg, err := parseRDPConnectionConfirm(pdu)
+	if err != nil {
+		conn.Close()
+		return rdpNegotiation{}, nil, err
+	}
+	return neg, conn, nil
+}
+
+// rdpConnectionRequest returns a TPKT holding an X.224 Connection Request
+// with an RDP Negotiation Request offering protocols.
+func rdpConnectionRequest(protocols uint32) []byte {
+	cookie := []byte("Cookie: mstshash=scanme\r\n")
+	x224 := []byte{
+		0,          // length indicator, set below
+		0xe0,       // connection request
+		0x00, 0x00, // destination reference
+		0x00, 0x00, // source reference
+		0x00, // class 0
+	}
+	x224 = append(x224, cookie...)
+	x224 = append(x224, 0x01, 0x00, 0x08, 0x00) // RDP_NEG_REQ, flags, length 8
+	x224 = binary.LittleEndian.AppendUint32(x224, protocols)
+	x224[0] = byte(len(x224) - 1)
+	return tpkt(x224)
+}
+
+// tpkt wraps payload in a TPKT header.
+func tpkt(payload []byte) []byte {
+	b := []byte{0x03, 0x00, 0, 0}
+	binary.BigEndian.PutUint16(b[2:], uint16(4+len(payload)))
+	return append(b, payload...)
+}
+
+// readTPKT reads a TPKT from r and returns its payload.
+func readTPKT(r io.Reader) ([]byte, error) {
+	var header [4]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return nil, err
+	}
+	n := int(binary.BigEndian.Uint16(header[2:]))
+	if header[0] != 0x03 || n < 4 {
+		return nil, ErrNotRDP
+	}
+	payload := make([]byte, n-4)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return nil, err
+	}
+	return payload, nil
+}
+
+// parseRDPConnectionConfirm parses the X.224 Connection Confirm pdu and the
+// RDP Negotiation Response or Failure it carries.
+func parseRDPConnectionConfirm(pdu []byte) (rdpNegotiation, error) {
+	// Length indicator, CC code, destination and source references, class.
+	if len(pdu) < 7 || pdu[1]&0xf0 != 0xd0 || int(pdu[0]) < 6 || int(pdu[0]) >= len(pdu) {
+		return rdpNegotiation{}, ErrNotRDP
+	}
+	neg := pdu[7 : 1+int(pdu[0])]
+	if len(neg) < 8 {
+		return rdpNegotiation{}, nil
+	}
+	value := binary.LittleEndian.Uint32(neg[4:])
+	switch neg[0] {
+	case 0x02: // RDP_NEG_RSP
+		return rdpNegotiation{present: true, ok: true, protocol: value}, nil
+	case 0x03: // RDP_NEG_FAILURE
+		return rdpNegotiation{present: true, failure: value}, nil
+	}
+	return rdpNegotiation{}, ErrNotRDP
+}
+
+// rdpServerVersion completes the TLS handshake of CredSSP on conn, sends an
+// NTLM negotiate message in a TSRequest and returns the version of Windows
+// from the challenge of the server, empty when it cannot be read.
+func rdpServerVersion(conn net.Conn) string {
+	tlsConn := tls.Client(conn, &tls.Config{
+		InsecureSkipVerify: true, // the certificate is of no interest here
+	})
+	if err := tlsConn.Handshake(); err != nil {
+		return ""
+	}
+	// TSRequest ::= SEQUENCE { version [0] INTEGER, negoTokens [1] NegoData }
+	// NegoData ::= SEQUENCE OF SEQUENCE { negoToken [0] OCTET STRING }
+	token := derTLV(0x30, derTLV(0x30, derTLV(0xa0, derTLV(0x04, ntlmNegotiate()))))
+	req := derTLV(0x30, append(derTLV(0xa0, berInt(2)), derTLV(0xa1, token)...))
+	if _, err := tlsConn.Write(req); err != nil {
+		return ""
+	}
+	buf := make([]byte, 4096)
+	n, err := tlsConn.Read(buf)
+	if err != nil {
+		return ""
+	}
+	i := bytes.Index(buf[:n], []byte("NTLMSSP\x00\x02\x00\x00\x00"))
+	if i < 0 {
+		return ""
+	}
+	return ntlmVersion(buf[i:n])
+}
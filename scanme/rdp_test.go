@@ -0,0 +1,232 @@
+package scanme
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/binary"
+	"errors"
+	"math/big"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// testCertificate returns a self-signed certificate for test servers.
+func testCertificate(t *testing.T) tls.Certificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "scanme test"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+// rdpConnectionConfirm returns a TPKT holding an X.224 Connection Confirm
+// carrying neg, an RDP Negotiation Response or Failure, if any.
+func rdpConnectionConfirm(neg []byte) []byte {
+	x224 := []byte{0, 0xd0, 0x00, 0x00, 0x12, 0x34, 0x00}
+	x224 = append(x224, neg...)
+	x224[0] = byte(len(x224) - 1)
+	return tpkt(x224)
+}
+
+// rdpNeg returns an RDP Negotiation Response (typ 2) or Failure (typ 3)
+// carrying value.
+func rdpNeg(typ byte, value uint32) []byte {
+	return binary.LittleEndian.AppendUint32([]byte{typ, 0x00, 0x08, 0x00}, value)
+}
+
+// rdpServer listens on port 3389 of 127.0.0.1, where RDPProbe connects,
+// which needs root, and answers the Connection Request of each connection
+// with the Connection Confirm returned by confirm for the protocols it
+// offers. When confirm selects CredSSP, the server completes the TLS
+// handshake and answers with the NTLM challenge of Windows 10.0.17763. It
+// returns the number of connections accepted.
+func rdpServer(t *testing.T, confirm func(protocols uint32) []byte) *atomic.Int32 {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:"+rdpPort)
+	if err != nil {
+		t.Skipf("listening on port %s: %v", rdpPort, err)
+	}
+	cert := testCertificate(t)
+
+	accepted := new(atomic.Int32)
+	done := make(chan struct{})
+	t.Cleanup(func() { ln.Close(); <-done })
+	go func() {
+		defer close(done)
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			accepted.Add(1)
+			serveRDP(conn, confirm, cert)
+		}
+	}()
+	return accepted
+}
+
+func serveRDP(conn net.Conn, confirm func(protocols uint32) []byte, cert tls.Certificate) {
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	req, err := readTPKT(conn)
+	if err != nil || len(req) < 8 {
+		return
+	}
+	reply := confirm(binary.LittleEndian.Uint32(req[len(req)-4:]))
+	if _, err := conn.Write(reply); err != nil {
+		return
+	}
+	if neg := reply[len(reply)-8:]; neg[0] != 0x02 || binary.LittleEndian.Uint32(neg[4:]) != rdpProtocolHybrid {
+		return
+	}
+
+	tlsConn := tls.Server(conn, &tls.Config{Certificates: []tls.Certificate{cert}})
+	buf := make([]byte, 4096)
+	if _, err := tlsConn.Read(buf); err != nil {
+		return
+	}
+	challenge := make([]byte, 56)
+	copy(challenge, "NTLMSSP\x00")
+	binary.LittleEndian.PutUint32(challenge[8:], 2)
+	binary.LittleEndian.PutUint32(challenge[20:], 0x02000000) // NTLMSSP_NEGOTIATE_VERSION
+	challenge[48], challenge[49] = 10, 0
+	binary.LittleEndian.PutUint16(challenge[50:], 17763)
+	token := derTLV(0x30, derTLV(0x30, derTLV(0xa0, derTLV(0x04, challenge))))
+	tlsConn.Write(derTLV(0x30, append(derTLV(0xa0, berInt(6)), derTLV(0xa1, token)...)))
+}
+
+func TestRDPConnectionRequest(t *testing.T) {
+	req := rdpConnectionRequest(rdpProtocolSSL | rdpProtocolHybrid)
+	if len(req) != 4+7+len("Cookie: mstshash=scanme\r\n")+8 {
+		t.Fatalf("Connection Request of %d bytes", len(req))
+	}
+	if req[0] != 0x03 || int(binary.BigEndian.Uint16(req[2:])) != len(req) {
+		t.Errorf("TPKT header %x, want version 3 and length %d", req[:4], len(req))
+	}
+	if req[4] != byte(len(req)-5) || req[5] != 0xe0 {
+		t.Errorf("X.224 header %x, want a Connection Request", req[4:6])
+	}
+	neg := req[len(req)-8:]
+	if neg[0] != 0x01 || binary.LittleEndian.Uint16(neg[2:]) != 8 || binary.LittleEndian.Uint32(neg[4:]) != 0x3 {
+		t.Errorf("RDP_NEG_REQ %x, want TLS and CredSSP requested", neg)
+	}
+}
+
+func TestParseRDPConnectionConfirm(t *testing.T) {
+	tests := []struct {
+		name    string
+		confirm []byte
+		want    rdpNegotiation
+		wantErr error
+	}{
+		{"response", rdpConnectionConfirm(rdpNeg(0x02, rdpProtocolHybrid)), rdpNegotiation{present: true, ok: true, protocol: rdpProtocolHybrid}, nil},
+		{"failure", rdpConnectionConfirm(rdpNeg(0x03, rdpHybridRequired)), rdpNegotiation{present: true, failure: rdpHybridRequired}, nil},
+		{"no negotiation", rdpConnectionConfirm(nil), rdpNegotiation{}, nil},
+		{"unknown negotiation type", rdpConnectionConfirm(rdpNeg(0x07, 0)), rdpNegotiation{}, ErrNotRDP},
+		{"connection request", rdpConnectionRequest(rdpProtocolSSL), rdpNegotiation{}, ErrNotRDP},
+		{"truncated", rdpConnectionConfirm(nil)[:8], rdpNegotiation{}, ErrNotRDP},
+	}
+	for _, tt := range tests {
+		neg, err := parseRDPConnectionConfirm(tt.confirm[4:])
+		if neg != tt.want || !errors.Is(err, tt.wantErr) {
+			t.Errorf("%s: parseRDPConnectionConfirm() = %+v, %v, want %+v, %v", tt.name, neg, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestRDPProbe(t *testing.T) {
+	tests := []struct {
+		name    string
+		confirm func(protocols uint32) []byte
+		want    RDPInfo
+		conns   int32
+	}{
+		{
+			name: "NLA required",
+			confirm: func(protocols uint32) []byte {
+				if protocols&rdpProtocolHybrid == 0 {
+					return rdpConnectionConfirm(rdpNeg(0x03, rdpHybridRequired))
+				}
+				return rdpConnectionConfirm(rdpNeg(0x02, rdpProtocolHybrid))
+			},
+			want:  RDPInfo{NLARequired: true, TLSRequired: true, ServerVersion: "10.0.17763", SecurityProtocol: "CredSSP"},
+			conns: 2,
+		},
+		{
+			name: "TLS required",
+			confirm: func(protocols uint32) []byte {
+				if protocols&rdpProtocolSSL == 0 {
+					return rdpConnectionConfirm(rdpNeg(0x03, rdpSSLRequired))
+				}
+				return rdpConnectionConfirm(rdpNeg(0x02, rdpProtocolSSL))
+			},
+			want:  RDPInfo{TLSRequired: true, SecurityProtocol: "TLS"},
+			conns: 3,
+		},
+		{
+			name: "Standard RDP only",
+			confirm: func(protocols uint32) []byte {
+				if protocols != rdpProtocolRDP {
+					return rdpConnectionConfirm(rdpNeg(0x03, rdpSSLNotAllowed))
+				}
+				return rdpConnectionConfirm(rdpNeg(0x02, rdpProtocolRDP))
+			},
+			want:  RDPInfo{SecurityProtocol: "Standard RDP"},
+			conns: 2,
+		},
+		{
+			name: "no negotiation",
+			confirm: func(uint32) []byte {
+				return rdpConnectionConfirm(nil)
+			},
+			want:  RDPInfo{SecurityProtocol: "Standard RDP"},
+			conns: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			accepted := rdpServer(t, tt.confirm)
+			s := newTestScanner()
+			s.dst = net.IPv4(127, 0, 0, 1)
+			info, err := s.RDPProbe(context.Background())
+			if err != nil {
+				t.Fatalf("RDPProbe() error = %v", err)
+			}
+			if *info != tt.want {
+				t.Errorf("RDPProbe() = %+v, want %+v", *info, tt.want)
+			}
+			if n := accepted.Load(); n != tt.conns {
+				t.Errorf("%d connections, want %d", n, tt.conns)
+			}
+		})
+	}
+}
+
+func TestRDPProbeNotRDP(t *testing.T) {
+	rdpServer(t, func(uint32) []byte {
+		return []byte("SSH-2.0-OpenSSH_9.6\r\n")
+	})
+	s := newTestScanner()
+	s.dst = net.IPv4(127, 0, 0, 1)
+	if _, err := s.RDPProbe(context.Background()); !errors.Is(err, ErrNotRDP) {
+		t.Errorf("RDPProbe() error = %v, want ErrNotRDP", err)
+	}
+}
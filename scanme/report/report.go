@@ -0,0 +1,110 @@
+// Package report renders scan results as a self-contained HTML page meant to
+// be shared with people who do not read JSON.
+package report
+
+import (
+	_ "embed"
+	"html/template"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/CyberRoute/scanme/scanme"
+	"github.com/google/gopacket/layers"
+)
+
+//go:embed report.html
+var reportHTML string
+
+var reportTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"time": func(t time.Time) string { return t.Format(time.RFC3339) },
+}).Parse(reportHTML))
+
+// Risk is the risk level of a host, from its open ports.
+type Risk string
+
+const (
+	RiskLow    Risk = "low"
+	RiskMedium Risk = "medium"
+	RiskHigh   Risk = "high"
+)
+
+// highRiskPorts are services frequently exploited or never meant to be
+// exposed: cleartext logins, Windows file sharing and remote desktop,
+// databases and container APIs.
+var highRiskPorts = map[layers.TCPPort]bool{
+	21:    true, // ftp
+	23:    true, // telnet
+	135:   true, // msrpc
+	139:   true, // netbios-ssn
+	445:   true, // microsoft-ds
+	1433:  true, // ms-sql
+	2375:  true, // docker
+	3306:  true, // mysql
+	3389:  true, // rdp
+	5432:  true, // postgresql
+	5900:  true, // vnc
+	6379:  true, // redis
+	9200:  true, // elasticsearch
+	11211: true, // memcached
+	27017: true, // mongodb
+}
+
+// HostRisk rates result: high when a port of a commonly exploited service is
+// open, medium when any other port below 1024 is open, low otherwise.
+func HostRisk(result *scanme.ScanResult) Risk {
+	risk := RiskLow
+	for _, port := range result.OpenPorts() {
+		if highRiskPorts[port] {
+			return RiskHigh
+		}
+		if port < 1024 {
+			risk = RiskMedium
+		}
+	}
+	return risk
+}
+
+type host struct {
+	Result    *scanme.ScanResult
+	OpenPorts int
+	Risk      Risk
+	Diffs     []*scanme.ScanDiff
+}
+
+// WriteHTMLReport writes an HTML report of results to w. It has a summary of
+// the open ports and risk of every host, a section per host with the ports of
+// its latest scan and, for hosts scanned more than once, the changes between
+// consecutive scans.
+func WriteHTMLReport(w io.Writer, results []*scanme.ScanResult) error {
+	byTarget := make(map[string][]*scanme.ScanResult)
+	var targets []string
+	for _, r := range results {
+		key := r.Target.String()
+		if _, ok := byTarget[key]; !ok {
+			targets = append(targets, key)
+		}
+		byTarget[key] = append(byTarget[key], r)
+	}
+
+	hosts := make([]host, 0, len(targets))
+	for _, target := range targets {
+		scans := byTarget[target]
+		sort.SliceStable(scans, func(i, j int) bool { return scans[i].StartTime.Before(scans[j].StartTime) })
+		latest := scans[len(scans)-1]
+		h := host{
+			Result:    latest,
+			OpenPorts: len(latest.OpenPorts()),
+			Risk:      HostRisk(latest),
+		}
+		for i := 1; i < len(scans); i++ {
+			h.Diffs = append(h.Diffs, scanme.Diff(scans[i-1], scans[i]))
+		}
+		hosts = append(hosts, h)
+	}
+
+	return reportTemplate.Execute(w, struct {
+		Generated time.Time
+		Hosts     []host
+	}{time.Now(), hosts})
+}
@@ -0,0 +1,90 @@
+package scanme
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+
+	"github.com/CyberRoute/scanme/scanme/intel"
+	"github.com/google/gopacket/routing"
+)
+
+// ResolveAndScan resolves domain to its IPv4 addresses and SYN scans each of
+// them, one after the other, with a scanner created with opts and packets
+// routed with router. With WithSubdomainScan, the subdomains found in
+// certificate transparency logs with intel.CTLookup are resolved and
+// scanned too, every address once. Names that do not resolve are skipped,
+// as are hosts not answering ARP requests; other errors do not stop the
+// scans of the remaining addresses and are returned, annotated with their
+// target, along with the results.
+func ResolveAndScan(ctx context.Context, domain string, router routing.Router, opts ...Option) ([]*ScanResult, error) {
+	// The options are only applied here to find out whether subdomains
+	// are wanted; every scanner applies them again.
+	cfg := scanner{logger: slog.Default()}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	names := []string{domain}
+	if cfg.scanSubdomains {
+		subdomains, err := intel.CTLookup(domain)
+		if err != nil {
+			cfg.logger.Error("certificate transparency lookup failed", "domain", domain, "err", err)
+		}
+		names = append(names, subdomains...)
+	}
+
+	var targets []net.IP
+	seen := make(map[string]bool)
+	for _, name := range names {
+		addrs, err := net.DefaultResolver.LookupIP(ctx, "ip4", name)
+		if err != nil {
+			if ctxErr := ctx.Err(); ctxErr != nil {
+				return nil, ctxErr
+			}
+			cfg.logger.Info("unable to resolve", "name", name, "err", err)
+			continue
+		}
+		for _, ip := range addrs {
+			if !seen[ip.String()] {
+				seen[ip.String()] = true
+				targets = append(targets, ip)
+			}
+		}
+	}
+	if len(targets) == 0 {
+		return nil, fmt.Errorf("no IPv4 address found for %s", domain)
+	}
+
+	var results []*ScanResult
+	var errs []error
+	for _, target := range targets {
+		if err := ctx.Err(); err != nil {
+			errs = append(errs, err)
+			break
+		}
+		result, err := resolvedScan(ctx, target, router, opts)
+		if errors.Is(err, &ErrARPTimeout{}) {
+			continue
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%v: %w", target, err))
+		}
+		if result != nil {
+			results = append(results, result)
+		}
+	}
+	return results, errors.Join(errs...)
+}
+
+// resolvedScan SYN scans a target of ResolveAndScan.
+func resolvedScan(ctx context.Context, target net.IP, router routing.Router, opts []Option) (*ScanResult, error) {
+	s, err := newScanner(target, router, opts...)
+	if err != nil {
+		return nil, err
+	}
+	defer s.Close()
+	return s.synscan(ctx, s.scanPorts(), nil)
+}
@@ -0,0 +1,220 @@
+package scanme
+
+import (
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/CyberRoute/scanme/scanme/intel"
+	"github.com/CyberRoute/scanme/utils"
+	"github.com/google/gopacket/layers"
+)
+
+// PortResult is the outcome of probing a single TCP port.
+type PortResult struct {
+	Port    layers.TCPPort
+	State   string
+	Service string
+	// Latency is the round-trip time between sending the SYN probe and
+	// receiving the SYN-ACK, zero for ports that did not answer.
+	Latency time.Duration
+	// HTTPFingerprint identifies the web server on the port when the
+	// scanner was created with WithHTTPFingerprinting.
+	HTTPFingerprint *HTTPFingerprint
+	// ServiceHint classifies the service found on the port by a follow-up
+	// probe, such as "SNMP-open" for SNMP agents accepting a community.
+	ServiceHint string
+	// MPTCP is set when the SYN-ACK offered Multipath TCP, with the key
+	// of the server in MPTCPKey, see WithMPTCPDetection.
+	MPTCP    bool
+	MPTCPKey uint64
+	// Suspicious is set on open ports that are the default port of a
+	// known backdoor, see WithBackdoorPortList.
+	Suspicious bool
+	// CertExpiry is the expiry date of the certificate of the TLS server
+	// on the port, recorded by the TLS hook of config.ScanTemplate; zero
+	// when unknown.
+	CertExpiry time.Time
+}
+
+// ScanStats holds counters collected while a scan runs.
+type ScanStats struct {
+	PacketsSent     int
+	PacketsReceived int
+	Duration        time.Duration
+	// RateLimitEvents counts the times the send rate was halved after an
+	// ICMP source quench from the target.
+	RateLimitEvents int
+	// MinLatency, MaxLatency and MeanLatency summarize the Latency of the
+	// ports that answered.
+	MinLatency  time.Duration
+	MaxLatency  time.Duration
+	MeanLatency time.Duration
+	// DNSTimeouts counts the reverse DNS lookups of the target that timed out.
+	DNSTimeouts int
+	// PacketsDroppedQueue counts the decoy packets dropped because the send
+	// queue was full, see WithQueueSize and WithDecoys.
+	PacketsDroppedQueue int
+	// DuplicatesFiltered counts the packets captured more than once and
+	// handled once, see WithDeduplication.
+	DuplicatesFiltered int
+	// PeakTxKBps, PeakRxKBps and AvgTxKBps are the highest send and
+	// receive rates over a second and the average send rate of the scan,
+	// in KB/s, see WithBandwidthMonitor.
+	PeakTxKBps float64
+	PeakRxKBps float64
+	AvgTxKBps  float64
+}
+
+// ScanResult is the outcome of scanning a single target. Ports are sorted by
+// port number.
+type ScanResult struct {
+	Target    net.IP
+	StartTime time.Time
+	EndTime   time.Time
+	Ports     []PortResult
+	Stats     ScanStats
+	// Hostname is the first of Hostnames, kept for convenience.
+	Hostname string
+	// Hostnames are the PTR records of Target, resolved when the scanner
+	// was created with WithDNSResolution.
+	Hostnames []string
+	// GeoInfo locates the target when GeoIP databases are loaded with
+	// WithGeoIP, nil otherwise.
+	GeoInfo *GeoLocation
+	// ASNInfo is the autonomous system announcing Target, looked up when
+	// the scanner was created with WithASNLookup.
+	ASNInfo *intel.ASNInfo
+	// MPTCPEnabled is set when any port offered Multipath TCP.
+	MPTCPEnabled bool
+	// HoneypotScore is the probability that Target is a honeypot, computed
+	// with HoneypotScore when the scanner was created with
+	// WithHoneypotDetection.
+	HoneypotScore float64
+	// ShodanDiscrepancies compares the open ports with those Shodan found,
+	// set by intel.ShodanEnrich.
+	ShodanDiscrepancies *intel.ShodanDiscrepancies
+	// ZoneTransfer holds the records of the zone the DNS server of the
+	// target transferred, see WithDNSZoneTransfer.
+	ZoneTransfer []DNSRecord
+}
+
+// newScanResult builds a ScanResult from the port to state map filled in by
+// the receive loop.
+func newScanResult(target net.IP, start time.Time, states map[layers.TCPPort]string) *ScanResult {
+	result := &ScanResult{
+		Target:    target,
+		StartTime: start,
+		EndTime:   time.Now(),
+		Ports:     make([]PortResult, 0, len(states)),
+	}
+	for port, state := range states {
+		result.Ports = append(result.Ports, PortResult{
+			Port:    port,
+			State:   state,
+			Service: serviceName(port),
+		})
+	}
+	result.sortPorts()
+	result.Stats.Duration = result.EndTime.Sub(result.StartTime)
+	return result
+}
+
+// setLatencies sets the Latency of the ports measured by the receive loop
+// and updates the latency stats.
+func (r *ScanResult) setLatencies(latencies map[layers.TCPPort]time.Duration) {
+	for i := range r.Ports {
+		if l, ok := latencies[r.Ports[i].Port]; ok {
+			r.Ports[i].Latency = l
+		}
+	}
+	r.updateLatencyStats()
+}
+
+// updateLatencyStats computes the latency stats from the Latency of Ports.
+func (r *ScanResult) updateLatencyStats() {
+	var total time.Duration
+	var n int
+	r.Stats.MinLatency, r.Stats.MaxLatency, r.Stats.MeanLatency = 0, 0, 0
+	for _, p := range r.Ports {
+		if p.Latency == 0 {
+			continue
+		}
+		if n == 0 || p.Latency < r.Stats.MinLatency {
+			r.Stats.MinLatency = p.Latency
+		}
+		if p.Latency > r.Stats.MaxLatency {
+			r.Stats.MaxLatency = p.Latency
+		}
+		total += p.Latency
+		n++
+	}
+	if n > 0 {
+		r.Stats.MeanLatency = total / time.Duration(n)
+	}
+}
+
+// sortPorts orders Ports by port number.
+func (r *ScanResult) sortPorts() {
+	sort.Slice(r.Ports, func(i, j int) bool { return r.Ports[i].Port < r.Ports[j].Port })
+}
+
+// Port returns the result for port, if it is part of r.
+func (r *ScanResult) Port(port layers.TCPPort) (*PortResult, bool) {
+	i := sort.Search(len(r.Ports), func(i int) bool { return r.Ports[i].Port >= port })
+	if i < len(r.Ports) && r.Ports[i].Port == port {
+		return &r.Ports[i], true
+	}
+	return nil, false
+}
+
+// SetServiceHint sets the ServiceHint of port, adding it as open when it
+// is not part of r, for instance for services found over UDP.
+func (r *ScanResult) SetServiceHint(port layers.TCPPort, hint string) {
+	if p, ok := r.Port(port); ok {
+		p.ServiceHint = hint
+		return
+	}
+	r.Ports = append(r.Ports, PortResult{
+		Port:        port,
+		State:       "open",
+		Service:     serviceName(port),
+		ServiceHint: hint,
+	})
+	r.sortPorts()
+}
+
+var _ intel.ScanResult = (*ScanResult)(nil)
+
+// TargetIP returns Target, for intel.ShodanEnrich.
+func (r *ScanResult) TargetIP() net.IP {
+	return r.Target
+}
+
+// SetShodanDiscrepancies sets ShodanDiscrepancies, for intel.ShodanEnrich.
+func (r *ScanResult) SetShodanDiscrepancies(d *intel.ShodanDiscrepancies) {
+	r.ShodanDiscrepancies = d
+}
+
+// OpenPorts returns the ports found in the "open" state.
+func (r *ScanResult) OpenPorts() []layers.TCPPort {
+	var ports []layers.TCPPort
+	for _, p := range r.Ports {
+		if p.State == "open" {
+			ports = append(ports, p.Port)
+		}
+	}
+	return ports
+}
+
+// serviceName returns the IANA service name registered for a TCP port, or an
+// empty string when it is unknown.
+func serviceName(port layers.TCPPort) string {
+	name, err := utils.GetServiceName(strconv.Itoa(int(port)), "tcp")
+	if err != nil {
+		return ""
+	}
+	return strings.Trim(name, "()")
+}
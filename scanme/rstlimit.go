@@ -0,0 +1,199 @@
+package scanme
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"github.com/google/gopacket/pcap"
+)
+
+const (
+	// rstProbePorts is the highest port RSTRateLimitDetect probes, from
+	// port 0, as these are closed on nearly every host.
+	rstProbePorts = 10
+	// rstStep is how long RSTRateLimitDetect probes at each rate.
+	rstStep = 500 * time.Millisecond
+	// rstGrace is how long RSTRateLimitDetect waits for the RSTs in flight
+	// at the end of each step.
+	rstGrace = 200 * time.Millisecond
+	// rstMargin is the fraction of the RST rate limit the send rate is
+	// lowered to.
+	rstMargin = 0.9
+)
+
+// rstProbeRates are the rates, in probes per second, RSTRateLimitDetect
+// probes at in turn.
+var rstProbeRates = []int{100, 200, 400, 800, 1600, 3200}
+
+// ErrRSTNoReply is returned by RSTRateLimitDetect when the target answers
+// none of the probes to its closed ports, as when a firewall drops them.
+var ErrRSTNoReply = errors.New("no TCP RST received from closed ports")
+
+// RSTInfo is the outcome of RSTRateLimitDetect. RateLimit is the highest
+// number of RSTs per second the target was seen sending when Detected.
+type RSTInfo struct {
+	RateLimit int
+	Detected  bool
+}
+
+// RSTRateLimitDetect finds whether the target, or a firewall in front of it,
+// limits the rate of the TCP RSTs answering probes to closed ports, as
+// Linux iptables and BSD pf can. Past the limit closed ports stay silent,
+// and Synscan takes them for filtered. SYN probes are sent to ports 0 to 10
+// at 100 probes per second, then at rates doubling up to 3200, for half a
+// second each. The limit is detected when the share of probes answered
+// drops below half of that at the lowest rate; the send rate of the scanner
+// is then lowered below it.
+func (s *scanner) RSTRateLimitDetect(ctx context.Context) (*RSTInfo, error) {
+	mac, err := s.sendARPRequest(ctx)
+	if err != nil {
+		return nil, err
+	}
+	srcPort, err := getFreeTCPPort()
+	if err != nil {
+		return nil, err
+	}
+	handle, err := openLive(s.iface.Name)
+	if err != nil {
+		return nil, err
+	}
+	defer handle.Close()
+	bpfFilter := fmt.Sprintf("tcp and src host %s and dst port %d and tcp[13] & 0x04 != 0", s.dst, srcPort)
+	if err := handle.SetBPFFilter(s.bpfFilter(bpfFilter)); err != nil {
+		return nil, err
+	}
+
+	var rsts atomic.Int64
+	stop := make(chan struct{})
+	readerDone := make(chan struct{})
+	s.readers.Add(1)
+	go func() {
+		defer s.readers.Done()
+		defer close(readerDone)
+		s.countRSTs(handle, stop, &rsts)
+	}()
+	defer func() {
+		close(stop)
+		<-readerDone
+	}()
+
+	eth := layers.Ethernet{
+		SrcMAC:       s.iface.HardwareAddr,
+		DstMAC:       mac,
+		EthernetType: layers.EthernetTypeIPv4,
+	}
+	ip4 := layers.IPv4{
+		SrcIP:    s.src,
+		DstIP:    s.dst,
+		Version:  4,
+		TTL:      s.ttl,
+		Protocol: layers.IPProtocolTCP,
+	}
+
+	info := &RSTInfo{}
+	var baseline float64
+	for i, rate := range rstProbeRates {
+		pace := newPacer(time.Second/time.Duration(rate), 0, s.logger)
+		probes := int(float64(rate) * rstStep.Seconds())
+		rsts.Store(0)
+		for n := 0; n < probes; n++ {
+			if err := pace.wait(ctx); err != nil {
+				return nil, err
+			}
+			tcp := layers.TCP{
+				SrcPort: layers.TCPPort(srcPort),
+				DstPort: layers.TCPPort(n % (rstProbePorts + 1)),
+				Window:  1024,
+				Seq:     s.nextSeq(),
+				SYN:     true,
+			}
+			if err := tcp.SetNetworkLayerForChecksum(&ip4); err != nil {
+				return nil, err
+			}
+			if err := s.send(&eth, &ip4, &tcp); err != nil {
+				return nil, err
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(rstGrace):
+		}
+
+		answered := rsts.Load()
+		ratio := float64(answered) / float64(probes)
+		if rstRate := int(float64(answered) / rstStep.Seconds()); rstRate > info.RateLimit {
+			info.RateLimit = rstRate
+		}
+		if i == 0 {
+			if answered == 0 {
+				return nil, ErrRSTNoReply
+			}
+			baseline = ratio
+			continue
+		}
+		if ratio < baseline/2 {
+			info.Detected = true
+			break
+		}
+	}
+	if !info.Detected {
+		info.RateLimit = 0
+		return info, nil
+	}
+
+	limit := int(float64(info.RateLimit) * rstMargin)
+	if limit < 1 {
+		limit = 1
+	}
+	if interval := time.Second / time.Duration(limit); s.sendInterval < interval {
+		s.sendInterval = interval
+		s.logger.Info("RST rate limit detected, send rate lowered", "target", s.dst, "rst_per_second", info.RateLimit, "packets_per_second", limit)
+	}
+	return info, nil
+}
+
+// countRSTs counts in n the TCP RSTs read from handle until stop is closed.
+// The BPF filter of handle only lets the RSTs answering the probes through.
+func (s *scanner) countRSTs(handle *pcap.Handle, stop <-chan struct{}, n *atomic.Int64) {
+	var eth layers.Ethernet
+	var dot1q layers.Dot1Q
+	var ip4 layers.IPv4
+	var tcp layers.TCP
+	parser := gopacket.NewDecodingLayerParser(layers.LayerTypeEthernet, &eth, &dot1q, &ip4, &tcp)
+	parser.IgnoreUnsupported = true
+	decoded := []gopacket.LayerType{}
+	for {
+		select {
+		case <-stop:
+			return
+		case <-s.done:
+			return
+		default:
+		}
+
+		data, _, err := handle.ReadPacketData()
+		if err == pcap.NextErrorTimeoutExpired {
+			continue
+		} else if err != nil {
+			s.logger.Error("error reading packet", "err", err)
+			return
+		}
+
+		//nolint:staticcheck // SA9003 ignore this!
+		if err := parser.DecodeLayers(data, &decoded); err != nil {
+			// Errors here are due to the decoder, and not all layers are implemented.
+		}
+		if len(decoded) == 0 || decoded[len(decoded)-1] != layers.LayerTypeTCP {
+			continue
+		}
+		if tcp.RST && tcp.SrcPort <= rstProbePorts {
+			n.Add(1)
+		}
+	}
+}
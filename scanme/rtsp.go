@@ -0,0 +1,300 @@
+package scanme
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// rtspPort is the port of RTSP servers.
+const rtspPort = 554
+
+// rtspTimeout bounds RTSPProbe when ctx has no deadline.
+const rtspTimeout = 10 * time.Second
+
+// rtspMaxBody bounds the SDP descriptions read.
+const rtspMaxBody = 64 << 10
+
+// rtspPaths are the stream paths DESCRIBE is sent for, the default of the
+// server first, then those of common camera vendors.
+var rtspPaths = []string{
+	"/",
+	"/live",
+	"/live.sdp",
+	"/stream1",
+	"/h264",
+	"/11",
+	"/Streaming/Channels/101",
+	"/cam/realmonitor?channel=1&subtype=0",
+	"/axis-media/media.amp",
+	"/videoMain",
+	"/media/video1",
+}
+
+// rtspStaticCodecs names the codecs of the static RTP payload types, used
+// by SDP descriptions without rtpmap attribute.
+var rtspStaticCodecs = map[string]string{
+	"0":  "PCMU",
+	"8":  "PCMA",
+	"14": "MPA",
+	"26": "JPEG",
+	"32": "MPV",
+}
+
+// RTSPStream is a media stream described by an RTSP server.
+type RTSPStream struct {
+	URL string
+	// Codec is the encoding of the stream, such as "H264", Resolution its
+	// size, such as "1920x1080", when the description tells it.
+	Codec      string
+	Resolution string
+	// NoAuth is set on streams described without credentials, which anyone
+	// reaching the server can watch.
+	NoAuth bool
+}
+
+// RTSPInfo describes the RTSP server of the target.
+type RTSPInfo struct {
+	Server    string
+	UserAgent string
+	// Methods lists the methods supported, from the Public header of the
+	// reply to OPTIONS.
+	Methods []string
+	Streams []RTSPStream
+}
+
+// ServiceHint returns the classification of the server for
+// PortResult.ServiceHint.
+func (r *RTSPInfo) ServiceHint() string {
+	hint := "rtsp"
+	if r.Server != "" {
+		hint += " (" + r.Server + ")"
+	}
+	for _, s := range r.Streams {
+		if s.NoAuth {
+			return hint + ", unauthenticated streams"
+		}
+	}
+	return hint
+}
+
+// RTSPProbe connects to port 554 of the target, sends an RTSP OPTIONS
+// request to read the server software and supported methods, then DESCRIBE
+// requests for the default stream and the paths common IP cameras use, and
+// lists the streams of the SDP descriptions returned. Streams described
+// without credentials have NoAuth set. Servers requiring authentication
+// answer every path alike, so enumeration stops at the first such answer,
+// leaving a single stream with NoAuth unset.
+func (s *scanner) RTSPProbe(ctx context.Context) (*RTSPInfo, error) {
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, rtspTimeout)
+		defer cancel()
+	}
+	base := "rtsp://" + net.JoinHostPort(s.dst.String(), strconv.Itoa(rtspPort))
+	c := &rtspConn{ip: s.dst}
+	defer c.close()
+
+	resp, err := c.do(ctx, "OPTIONS", base+"/")
+	if err != nil {
+		return nil, err
+	}
+	info := &RTSPInfo{
+		Server:    resp.header.Get("Server"),
+		UserAgent: resp.header.Get("User-Agent"),
+		Methods:   sipList(resp.header.Values("Public")),
+	}
+
+	seen := make(map[string]bool)
+	for _, path := range rtspPaths {
+		url := base + path
+		resp, err := c.do(ctx, "DESCRIBE", url)
+		if err != nil {
+			if ctx.Err() != nil {
+				break
+			}
+			continue
+		}
+		if resp.status == 401 {
+			info.Streams = append(info.Streams, RTSPStream{URL: url})
+			break
+		}
+		if resp.status != 200 {
+			continue
+		}
+		contentBase := resp.header.Get("Content-Base")
+		if contentBase == "" {
+			contentBase = url
+		}
+		for _, stream := range parseSDPStreams(contentBase, resp.body) {
+			if !seen[stream.URL] {
+				seen[stream.URL] = true
+				stream.NoAuth = true
+				info.Streams = append(info.Streams, stream)
+			}
+		}
+	}
+	return info, nil
+}
+
+// rtspConn is an RTSP connection, dialled again when the server closes it.
+type rtspConn struct {
+	ip   net.IP
+	conn net.Conn
+	r    *textproto.Reader
+	cseq int
+}
+
+// rtspResponse is the status, headers and body of an RTSP response.
+type rtspResponse struct {
+	status int
+	header textproto.MIMEHeader
+	body   string
+}
+
+// do sends a request without body and reads the response.
+func (c *rtspConn) do(ctx context.Context, method, url string) (*rtspResponse, error) {
+	if c.conn == nil {
+		conn, err := dialICS(ctx, c.ip, strconv.Itoa(rtspPort))
+		if err != nil {
+			return nil, err
+		}
+		c.conn, c.r = conn, textproto.NewReader(bufio.NewReader(conn))
+	}
+	c.cseq++
+	req := fmt.Sprintf("%s %s RTSP/1.0\r\nCSeq: %d\r\n", method, url, c.cseq)
+	if method == "DESCRIBE" {
+		req += "Accept: application/sdp\r\n"
+	}
+	req += "User-Agent: scanme\r\n\r\n"
+	resp, err := c.exchange(req)
+	if err != nil {
+		c.close()
+	}
+	return resp, err
+}
+
+// exchange writes req and reads the response to it.
+func (c *rtspConn) exchange(req string) (*rtspResponse, error) {
+	if _, err := io.WriteString(c.conn, req); err != nil {
+		return nil, err
+	}
+	status, err := c.r.ReadLine()
+	if err != nil {
+		return nil, err
+	}
+	proto, code, ok := strings.Cut(status, " ")
+	if !ok || !strings.HasPrefix(proto, "RTSP/") {
+		return nil, fmt.Errorf("not an RTSP response: %q", status)
+	}
+	code, _, _ = strings.Cut(code, " ")
+	resp := &rtspResponse{}
+	if resp.status, err = strconv.Atoi(code); err != nil {
+		return nil, fmt.Errorf("invalid RTSP status line: %q", status)
+	}
+	if resp.header, err = c.r.ReadMIMEHeader(); err != nil {
+		return nil, err
+	}
+	if length := resp.header.Get("Content-Length"); length != "" {
+		n, err := strconv.Atoi(length)
+		if err != nil || n < 0 || n > rtspMaxBody {
+			return nil, errors.New("invalid RTSP Content-Length " + strconv.Quote(length))
+		}
+		body := make([]byte, n)
+		if _, err := io.ReadFull(c.r.R, body); err != nil {
+			return nil, err
+		}
+		resp.body = string(body)
+	}
+	return resp, nil
+}
+
+// close closes the connection, if open.
+func (c *rtspConn) close() {
+	if c.conn != nil {
+		c.conn.Close()
+		c.conn = nil
+	}
+}
+
+// parseSDPStreams returns the streams of the media sections of the SDP
+// description sdp, whose relative control URLs are relative to base.
+func parseSDPStreams(base, sdp string) []RTSPStream {
+	var streams []RTSPStream
+	var stream *RTSPStream
+	var payload string
+	codecs := make(map[string]string)
+	flush := func() {
+		if stream == nil {
+			return
+		}
+		if stream.Codec == "" {
+			if stream.Codec = codecs[payload]; stream.Codec == "" {
+				stream.Codec = rtspStaticCodecs[payload]
+			}
+		}
+		streams = append(streams, *stream)
+	}
+
+	for _, line := range strings.Split(sdp, "\n") {
+		line = strings.TrimSpace(line)
+		switch {
+		case strings.HasPrefix(line, "m="):
+			flush()
+			// m=<media> <port> <proto> <payload types...>
+			fields := strings.Fields(line[2:])
+			payload = ""
+			if len(fields) > 3 {
+				payload = fields[3]
+			}
+			codecs = make(map[string]string)
+			stream = &RTSPStream{URL: base}
+		case stream == nil:
+			// Session level attributes are not of interest.
+		case strings.HasPrefix(line, "a=control:"):
+			stream.URL = sdpControlURL(base, strings.TrimPrefix(line, "a=control:"))
+		case strings.HasPrefix(line, "a=rtpmap:"):
+			// a=rtpmap:<payload type> <encoding>/<clock rate>
+			pt, enc, ok := strings.Cut(strings.TrimPrefix(line, "a=rtpmap:"), " ")
+			if ok {
+				codecs[pt], _, _ = strings.Cut(enc, "/")
+			}
+		case strings.HasPrefix(line, "a=framesize:"):
+			// a=framesize:<payload type> <width>-<height>
+			if _, size, ok := strings.Cut(strings.TrimPrefix(line, "a=framesize:"), " "); ok {
+				stream.Resolution = strings.Replace(size, "-", "x", 1)
+			}
+		case strings.HasPrefix(line, "a=x-dimensions:"):
+			stream.Resolution = strings.Replace(strings.TrimPrefix(line, "a=x-dimensions:"), ",", "x", 1)
+		case strings.HasPrefix(line, "a=cliprect:") && stream.Resolution == "":
+			// a=cliprect:<top>,<left>,<bottom>,<right>
+			if r := strings.Split(strings.TrimPrefix(line, "a=cliprect:"), ","); len(r) == 4 {
+				stream.Resolution = r[3] + "x" + r[2]
+			}
+		}
+	}
+	flush()
+	return streams
+}
+
+// sdpControlURL resolves the control attribute of a media section against
+// the base URL of the description.
+func sdpControlURL(base, control string) string {
+	switch {
+	case control == "" || control == "*":
+		return base
+	case strings.HasPrefix(strings.ToLower(control), "rtsp://"):
+		return control
+	case strings.HasSuffix(base, "/"):
+		return base + control
+	default:
+		return base + "/" + control
+	}
+}
@@ -1,90 +1,469 @@
 package scanme
 
 import (
+	"context"
+	crand "crypto/rand"
+	"encoding/binary"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
+	"math/rand"
 	"net"
+	"os"
+	"os/signal"
 	"strconv"
 	"sync"
+	"sync/atomic"
+	"syscall"
 	"time"
 
+	"github.com/CyberRoute/scanme/scanme/capture"
+	"github.com/CyberRoute/scanme/scanme/debug"
+	"github.com/CyberRoute/scanme/scanme/detection"
+	"github.com/CyberRoute/scanme/scanme/filter"
+	"github.com/CyberRoute/scanme/scanme/intel"
+	"github.com/CyberRoute/scanme/scanme/metrics"
+	"github.com/CyberRoute/scanme/scanme/queue"
 	"github.com/CyberRoute/scanme/utils"
 	"github.com/google/gopacket"
 	"github.com/google/gopacket/layers"
 	"github.com/google/gopacket/pcap"
 	"github.com/google/gopacket/routing"
+	"github.com/oschwald/geoip2-golang"
+	"github.com/prometheus/client_golang/prometheus"
 )
 
+const (
+	// defaultTTL is the TTL of the IPv4 packets sent, unless set with WithTTL.
+	defaultTTL = 64
+	// pcapReadTimeout bounds how long a read from a pcap handle blocks, so
+	// that reading loops can check for cancellation and deadlines.
+	pcapReadTimeout = 100 * time.Millisecond
+	// defaultSettle is how long Synscan keeps listening for replies after
+	// the last probe has been sent.
+	defaultSettle = 2 * time.Second
+	// defaultARPRetries is how many times an unanswered ARP request is sent
+	// again, unless set with WithSpeed.
+	defaultARPRetries = 3
+	// arpTimeout is how long to wait for a reply to an ARP request.
+	arpTimeout = time.Second
+	// defaultQueueSize is how many packets of each priority the send queue
+	// holds, unless set with WithQueueSize.
+	defaultQueueSize = 4096
+	// drainQuiet is how long no reply must be received for GracefulClose to
+	// consider the replies drained, which it checks every drainPoll.
+	drainQuiet = 50 * time.Millisecond
+	drainPoll  = 10 * time.Millisecond
+)
+
+// ErrScannerClosed is returned by scans started or still running when the
+// scanner is closed.
+var ErrScannerClosed = errors.New("scanner closed")
+
+// ErrDrainTimeout is returned by GracefulClose when replies still arrive
+// once its timeout expires.
+var ErrDrainTimeout = errors.New("timeout draining replies")
+
+// ErrLocalTarget is returned by Synscan for local addresses, which cannot be
+// reached with raw packets: scan them with ConnScan.
+var ErrLocalTarget = errors.New("scanning a local address requires an open socket")
+
+// Scanner is the interface implemented by the scanner returned by NewScanner.
+// Code depending on it rather than on the concrete type can be tested
+// without opening a pcap handle, see the scanme/testing package.
+//
+// The scanner also has the methods of the protocol probes and fingerprints,
+// such as SSHProbe or HTTPFingerprint, which callers reach by asserting an
+// interface type holding the methods they use:
+//
+//	fp, ok := scanner.(interface {
+//		HTTPFingerprint(port layers.TCPPort, useTLS bool) (*scanme.HTTPFingerprint, error)
+//	})
+type Scanner interface {
+	// Synscan performs a SYN scan of the target.
+	Synscan() (*ScanResult, error)
+	// SynscanContext is Synscan, stopping early when ctx is done.
+	SynscanContext(ctx context.Context) (*ScanResult, error)
+	// ConnScan performs a full TCP handshake on each port and returns the
+	// open ports with their service names.
+	ConnScan() (map[layers.TCPPort]string, error)
+	// GrabBanner connects to port on the target and returns its banner.
+	GrabBanner(port layers.TCPPort, timeout time.Duration) (string, error)
+	// Close releases the resources held by the scanner and stops the scans
+	// in progress.
+	Close()
+	// GracefulClose stops sending probes and waits up to timeout for the
+	// replies in flight to be received before closing the scanner.
+	GracefulClose(timeout time.Duration) error
+	// Wait blocks until the receive goroutines of the scans in progress have
+	// exited and returns the capture error that stopped one of them, if any.
+	Wait() error
+}
+
+var _ Scanner = (*scanner)(nil)
+
 // The type scanner handles scanning a single IP address and is only shared with the packet injector
 // iface is the interface to send packets on.
 // destination, gateway (if applicable), and source IP addresses to use.
-// opts and buf allow us to easily serialize packets in the send()
+// opts allows us to easily serialize packets in the send()
 // method.
-type Scanner struct {
+// The remaining fields hold the optional behaviour configured through Option values.
+type scanner struct {
 	iface        *net.Interface
 	dst, gw, src net.IP
 	handle       *pcap.Handle
 	opts         gopacket.SerializeOptions
-	buf          gopacket.SerializeBuffer
 	tcpsequencer *TCPSequencer
+
+	metricsEnabled  bool
+	metricsRegistry prometheus.Registerer
+	metrics         *metrics.Metrics
+	tracer          *tracer
+	decoys          []net.IP
+	fragmentSize    int
+	ttl             uint8
+	ports           []layers.TCPPort
+	portIterator    PortIterator
+	iteratedPorts   []layers.TCPPort
+	randomOrder     bool
+	settle          time.Duration
+	sendInterval    time.Duration
+	allowList       map[layers.TCPPort]bool
+	denyList        map[layers.TCPPort]bool
+	arpRetries      int
+	jitter          float64
+	rng             *rand.Rand
+	geoipPaths      []string
+	geoip           []*geoip2.Reader
+	resolveDNS      bool
+	lookupASN       bool
+	vlan            bool
+	vlanID          uint16
+	vlanPCP         uint8
+	tcpOptions      []layers.TCPOption
+	httpFingerprint bool
+	webhookURL      string
+	webhookHeaders  map[string]string
+	webhookSecret   string
+	plugins         []ScanPlugin
+	afPacket        bool
+	randomISN       bool
+	detectHoneypots bool
+	idsEvents       chan<- detection.IDSEvent
+	packetLog       *debug.PacketLogger
+	detectMPTCP     bool
+	adaptiveRST     bool
+	rstChecked      bool
+	scanSubdomains  bool
+	extra           []layers.TCPPort
+	suspicious      map[layers.TCPPort]bool
+	logger          *slog.Logger
+	excludeHosts    []net.IP
+	excludeCIDRs    []*net.IPNet
+	excludeFiles    []string
+	socks5Addr      string
+	socks5Creds     *ProxyCreds
+	dedupe          bool
+	dedupeSize      int
+	axfrDomain      string
+	vncProbe        bool
+
+	queue       *queue.SendQueue
+	queueSize   int
+	bandwidth   *bandwidthLimiter
+	cpuLimit    float64
+	cpu         *cpuLimiter
+	memoryLimit int64
+
+	// monitorBandwidth is set with WithBandwidthMonitor, monitor holds the
+	// monitor of the SYN scan in progress.
+	monitorBandwidth bool
+	monitor          atomic.Pointer[bandwidthMonitor]
+
+	arpCache    *ARPCache
+	handles     *HandlePool
+	sharedLimit *RateLimiter
+
+	checkpointPath     string
+	checkpointInterval int
+
+	events chan<- ScanEvent
+	// resume is the checkpoint the next Synscan picks up from.
+	resume *checkpoint
+
+	// done is closed by Close to stop the receive goroutines, which readers
+	// tracks so that Wait can block until they have exited.
+	done      chan struct{}
+	closeOnce sync.Once
+	// draining is closed by GracefulClose to stop sending probes, and
+	// received counts the replies read, for it to tell when they stop.
+	draining  chan struct{}
+	drainOnce sync.Once
+	received  atomic.Int64
+	readers   sync.WaitGroup
+	readMu    sync.Mutex
+	readErr   error
 }
 
-// newScanner creates a new scanner for a given destination IP address, using
+// NewScanner creates a new scanner for a given destination IP address, using
 // router to determine how to route packets to that IP.
-func NewScanner(ip net.IP, router routing.Router) (*Scanner, error) {
-	s := &Scanner{
+// Optional behaviour can be enabled by passing one or more Option values.
+func NewScanner(ip net.IP, router routing.Router, options ...Option) (Scanner, error) {
+	s, err := newScanner(ip, router, options...)
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// newScanner creates the scanner returned by NewScanner.
+func newScanner(ip net.IP, router routing.Router, options ...Option) (*scanner, error) {
+	s := &scanner{
 		dst: ip,
 		opts: gopacket.SerializeOptions{
 			FixLengths:       true,
 			ComputeChecksums: true,
 		},
-		buf:          gopacket.NewSerializeBuffer(),
 		tcpsequencer: NewTCPSequencer(),
+		done:         make(chan struct{}),
+		draining:     make(chan struct{}),
+		ttl:          defaultTTL,
+		settle:       defaultSettle,
+		arpRetries:   defaultARPRetries,
+		queueSize:    defaultQueueSize,
+		logger:       slog.Default(),
+
+		checkpointInterval: defaultCheckpointInterval,
+	}
+	for _, option := range options {
+		option(s)
+	}
+	if s.rng == nil {
+		s.rng = newRand()
+	}
+	exclude, err := s.excludeList()
+	if err != nil {
+		return nil, err
 	}
+	if exclude.contains(ip) {
+		return nil, &ErrHostExcluded{IP: ip}
+	}
+	_, endSpan := s.tracer.start(context.Background(), "NewScanner", spanAttr{"net.peer.ip", ip.String()})
+	defer endSpan()
+
+	if s.metricsEnabled {
+		m, err := metrics.New(s.metricsRegistry)
+		if err != nil {
+			return nil, fmt.Errorf("error registering metrics: %w", err)
+		}
+		s.metrics = m
+	}
+	s.cpu = newCPULimiter(s.cpuLimit)
 
 	iface, gw, src, err := router.Route(ip)
 	if err != nil {
-		return nil, err
+		return nil, &ErrNoRoute{IP: ip, Err: err}
 	}
 
 	// If scanning localhost, set the interface to loopback
 	if ip.Equal(src) {
 		iface, err = net.InterfaceByName("lo")
 		if err != nil {
-			return nil, fmt.Errorf("error getting loopback interface: %v", err)
+			return nil, &ErrInterfaceNotFound{Name: "lo", Err: err}
 		}
 	}
 
-	log.Printf("scanning ip %v with interface %v, gateway %v, src %v", ip, iface.Name, gw, src)
+	if s.checkpointPath != "" {
+		cp, err := loadCheckpoint(s.checkpointPath)
+		switch {
+		case err == nil && cp.Target == ip.String():
+			s.resume = cp
+		case err == nil:
+			s.logger.Warn("ignoring checkpoint of another target", "path", s.checkpointPath, "checkpoint_target", cp.Target, "target", ip)
+		case !os.IsNotExist(err):
+			return nil, err
+		}
+	}
+
+	s.logger.Info("scanning", "ip", ip, "interface", iface.Name, "gateway", gw, "src", src)
+	if s.vlan && s.vlanID > 0 && !isVLANInterface(iface.Name) {
+		s.logger.Warn("tagging packets with a VLAN on an interface that is not a VLAN sub-interface", "vlan", s.vlanID, "interface", iface.Name)
+	}
 	s.gw, s.src, s.iface = gw, src, iface
 
-	handle, err := pcap.OpenLive(iface.Name, 65535, true, pcap.BlockForever)
+	// The handle is mostly used to inject packets, but Traceroute also reads
+	// from it, so reads must not block forever.
+	var handle *pcap.Handle
+	if s.handles != nil {
+		handle, err = s.handles.get(iface.Name)
+	} else {
+		handle, err = openLive(iface.Name)
+	}
 	if err != nil {
-		return nil, fmt.Errorf("error opening pcap handle: %v", err)
+		return nil, err
 	}
 	s.handle = handle
 
+	if len(s.geoipPaths) > 0 {
+		readers, err := openGeoIP(s.geoipPaths)
+		if err != nil {
+			if s.handles == nil {
+				handle.Close()
+			}
+			return nil, err
+		}
+		s.geoip = readers
+	}
+	s.queue = queue.New(s.writePacket, s.queueSize, s.logger)
+
 	return s, nil
 }
 
-// Closes the pcap handle
-func (s *Scanner) Close() {
-	if s.handle != nil {
+// Closes the pcap handle and stops the scans in progress. Their receive
+// goroutines exit within pcapReadTimeout, use Wait to block until they have.
+func (s *scanner) Close() {
+	_, endSpan := s.tracer.start(context.Background(), "Close")
+	defer endSpan()
+	s.closeOnce.Do(func() { close(s.done) })
+	if s.queue != nil {
+		s.queue.Close()
+	}
+	if s.handle != nil && s.handles == nil {
 		s.handle.Close()
 	}
+	closeGeoIP(s.geoip)
+}
+
+// GracefulClose stops sending probes and lets the scans in progress receive
+// the replies still in flight: once no reply has been received for 50ms, or
+// timeout expires, the scanner is closed as with Close. ErrDrainTimeout is
+// returned when replies were still arriving.
+func (s *scanner) GracefulClose(timeout time.Duration) error {
+	s.drainOnce.Do(func() { close(s.draining) })
+	defer s.Close()
+
+	deadline := time.Now().Add(timeout)
+	last := s.received.Load()
+	quietSince := time.Now()
+	ticker := time.NewTicker(drainPoll)
+	defer ticker.Stop()
+	for now := range ticker.C {
+		if n := s.received.Load(); n != last {
+			last, quietSince = n, now
+		} else if now.Sub(quietSince) >= drainQuiet {
+			return nil
+		}
+		if now.After(deadline) {
+			return ErrDrainTimeout
+		}
+	}
+	return nil
+}
+
+// Wait blocks until the receive goroutines of the scans in progress have
+// exited, typically after Close, and returns the error that stopped one of
+// them reading from its capture handle, if any.
+func (s *scanner) Wait() error {
+	s.readers.Wait()
+	s.readMu.Lock()
+	defer s.readMu.Unlock()
+	return s.readErr
+}
+
+// openCapture opens the capture handle Synscan reads replies from, using
+// AF_PACKET when enabled with WithAFPacket and libpcap otherwise.
+func (s *scanner) openCapture() (capture.PacketSource, error) {
+	open := capture.OpenPcap
+	if s.afPacket {
+		open = capture.OpenAFPacket
+	}
+	src, err := open(s.iface.Name, 65535, pcapReadTimeout)
+	if err != nil {
+		return nil, &ErrPcapOpenFailed{Interface: s.iface.Name, Err: err}
+	}
+	return src, nil
+}
+
+// nextSeq returns the sequence number of the next SYN probe: a random one
+// when enabled with WithRandomISN, the next value of the linear sequencer
+// otherwise.
+func (s *scanner) nextSeq() uint32 {
+	if !s.randomISN {
+		return s.tcpsequencer.Next()
+	}
+	var b [4]byte
+	if _, err := crand.Read(b[:]); err != nil {
+		return rand.Uint32()
+	}
+	return binary.BigEndian.Uint32(b[:])
+}
+
+// setProbePort readies the SYN probe tcp for port, giving it a sequence
+// number of its own when enabled with WithRandomISN.
+func (s *scanner) setProbePort(tcp *layers.TCP, port layers.TCPPort) {
+	tcp.DstPort = port
+	if s.randomISN {
+		tcp.Seq = s.nextSeq()
+	}
 }
 
-// send sends the given layers as a single packet on the network.
-func (s *Scanner) send(l ...gopacket.SerializableLayer) error {
-	if err := gopacket.SerializeLayers(s.buf, s.opts, l...); err != nil {
+// openLive opens a promiscuous capture handle on the interface name.
+func openLive(name string) (*pcap.Handle, error) {
+	handle, err := pcap.OpenLive(name, 65535, true, pcapReadTimeout)
+	if err != nil {
+		return nil, &ErrPcapOpenFailed{Interface: name, Err: err}
+	}
+	return handle, nil
+}
+
+// scanTimeout wraps err in an ErrScanTimeout when it is the deadline of the
+// scan's context expiring.
+func (s *scanner) scanTimeout(err error) error {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return &ErrScanTimeout{Target: s.dst, Err: err}
+	}
+	return err
+}
+
+// bufferPool recycles the buffers packets are serialized into, so that
+// probes sent from several goroutines neither share a buffer nor allocate
+// a new one each. SerializeLayers clears a buffer before reusing it.
+var bufferPool = sync.Pool{
+	New: func() any { return gopacket.NewSerializeBuffer() },
+}
+
+// send queues the given layers as a single packet of high priority, sent
+// before any queued probe.
+func (s *scanner) send(l ...gopacket.SerializableLayer) error {
+	return s.sendPriority(queue.PriorityHigh, l...)
+}
+
+// sendPriority serializes the given layers as a single packet and queues it
+// with priority p, to be written to the network by the send queue.
+func (s *scanner) sendPriority(p queue.Priority, l ...gopacket.SerializableLayer) error {
+	l = s.tagVLAN(l)
+	buf := bufferPool.Get().(gopacket.SerializeBuffer)
+	defer bufferPool.Put(buf)
+	if err := gopacket.SerializeLayers(buf, s.opts, l...); err != nil {
 		return err
 	}
+	return s.queue.Enqueue(buf.Bytes(), p)
+}
+
+// writePacket writes data to the network, called by the send queue.
+func (s *scanner) writePacket(data []byte) error {
 	var err error
 	retries := 10
 
 	for retries > 0 {
-		err = s.handle.WritePacketData(s.buf.Bytes())
+		if s.bandwidth.wait(len(data)) {
+			s.metrics.LimitTriggered(s.dst.String(), "bandwidth")
+		}
+		err = s.handle.WritePacketData(data)
 		if err == nil {
+			s.monitor.Load().sent(len(data))
+			s.metrics.PacketSent(s.dst.String())
+			s.packetLog.Record(debug.DirectionTx, time.Now(), data)
 			break // Successfully sent, exit the loop
 		}
 
@@ -95,19 +474,57 @@ func (s *Scanner) send(l ...gopacket.SerializableLayer) error {
 	return err
 }
 
-func (s *Scanner) sendARPRequest() (net.HardwareAddr, error) {
+// sendARPRequest resolves the MAC address of the next hop to the target,
+// tracing the resolution as a child of the span of ctx.
+func (s *scanner) sendARPRequest(ctx context.Context) (net.HardwareAddr, error) {
+	if err := s.checkRawPackets(); err != nil {
+		return nil, err
+	}
+	_, endSpan := s.tracer.start(ctx, "ARP")
+	defer endSpan()
 	arpDst := s.dst
 	if s.gw != nil {
 		arpDst = s.gw
 	}
-	handle, err := pcap.OpenLive(s.iface.Name, 65536, true, pcap.BlockForever)
+	return s.arpResolve(arpDst)
+}
+
+// nextHopMAC returns the MAC address packets for ip must be sent to: the MAC
+// of ip itself when it is on the same link as the scanner's interface, the
+// MAC of the gateway otherwise.
+func (s *scanner) nextHopMAC(ip net.IP) (net.HardwareAddr, error) {
+	if err := s.checkRawPackets(); err != nil {
+		return nil, err
+	}
+	addrs, err := s.iface.Addrs()
+	if err != nil {
+		return nil, err
+	}
+	for _, addr := range addrs {
+		if ipnet, ok := addr.(*net.IPNet); ok && ipnet.Contains(ip) {
+			return s.arpResolve(ip)
+		}
+	}
+	if s.gw == nil {
+		return s.arpResolve(ip)
+	}
+	return s.arpResolve(s.gw)
+}
+
+// arpResolve sends an ARP request for arpDst and waits for its reply, unless
+// the MAC address of arpDst is in the cache set with WithARPCache.
+func (s *scanner) arpResolve(arpDst net.IP) (net.HardwareAddr, error) {
+	if mac, ok := s.arpCache.Lookup(arpDst); ok {
+		return mac, nil
+	}
+	handle, err := openLive(s.iface.Name)
 	if err != nil {
 		return nil, err
 	}
 
 	// Set a BPF filter to capture only ARP replies destined for our source IP
 	bpfFilter := fmt.Sprintf("arp and ether dst %s", s.iface.HardwareAddr)
-	if err := handle.SetBPFFilter(bpfFilter); err != nil {
+	if err := handle.SetBPFFilter(s.bpfFilter(bpfFilter)); err != nil {
 		return nil, err
 	}
 
@@ -130,25 +547,43 @@ func (s *Scanner) sendARPRequest() (net.HardwareAddr, error) {
 		DstProtAddress:    []byte(arpDst),
 	}
 
-	// Send a single ARP request packet (we never retry a send, since this
-	// SerializeLayers clears the given write buffer, then writes all layers
-	// into it so they correctly wrap each other. Note that by clearing the buffer,
-	// it invalidates all slices previously returned by w.Bytes()
-
-	if err := s.send(&eth, &arp); err != nil {
-		return nil, err
+	// The request is sent again, up to arpRetries times, when no reply is
+	// received within arpTimeout.
+	for attempt := 0; attempt <= s.arpRetries; attempt++ {
+		// SerializeLayers clears the given write buffer, then writes all layers
+		// into it so they correctly wrap each other. Note that by clearing the buffer,
+		// it invalidates all slices previously returned by w.Bytes()
+		if err := s.send(&eth, &arp); err != nil {
+			return nil, err
+		}
+		if mac, ok, err := readARPReply(handle, arpDst, time.Now().Add(arpTimeout)); err != nil || ok {
+			if ok {
+				s.arpCache.Add(arpDst, mac)
+			}
+			return mac, err
+		}
 	}
-	for {
+	return nil, &ErrARPTimeout{IP: arpDst, Attempts: s.arpRetries + 1}
+}
+
+// readARPReply reads packets from handle until an ARP reply from arpDst is
+// received or the deadline passes.
+func readARPReply(handle *pcap.Handle, arpDst net.IP, deadline time.Time) (net.HardwareAddr, bool, error) {
+	var eth layers.Ethernet
+	var dot1q layers.Dot1Q
+	var arp layers.ARP
+	parser := gopacket.NewDecodingLayerParser(layers.LayerTypeEthernet, &eth, &dot1q, &arp)
+	parser.IgnoreUnsupported = true
+	decoded := []gopacket.LayerType{}
+
+	for time.Now().Before(deadline) {
 		data, _, err := handle.ReadPacketData()
 		if err == pcap.NextErrorTimeoutExpired {
 			continue
 		} else if err != nil {
-			return net.HardwareAddr{}, err
+			return net.HardwareAddr{}, false, err
 		}
 
-		parser := gopacket.NewDecodingLayerParser(layers.LayerTypeEthernet, &eth, &arp)
-		parser.IgnoreUnsupported = true
-		decoded := []gopacket.LayerType{}
 		//nolint:staticcheck // SA9003 ignore this!
 		if err := parser.DecodeLayers(data, &decoded); err != nil {
 			// This branch is intentionally left empty (SA9003).
@@ -158,13 +593,14 @@ func (s *Scanner) sendARPRequest() (net.HardwareAddr, error) {
 
 		for _, layerType := range decoded {
 			switch layerType {
-			case layers.LayerTypeEthernet:
+			case layers.LayerTypeARP:
 				if net.IP(arp.SourceProtAddress).Equal(net.IP(arpDst)) {
-					return net.HardwareAddr(arp.SourceHwAddress), nil
+					return net.HardwareAddr(arp.SourceHwAddress), true, nil
 				}
 			}
 		}
 	}
+	return nil, false, nil
 }
 
 func getFreeTCPPort() (layers.TCPPort, error) {
@@ -176,14 +612,34 @@ func getFreeTCPPort() (layers.TCPPort, error) {
 	return layers.TCPPort(tcpport), nil
 }
 
-func (s *Scanner) sendICMPEchoRequest() error {
-	mac, err := s.sendARPRequest()
+func (s *scanner) sendICMPEchoRequest(ctx context.Context) error {
+	eth, ip4, err := s.icmpLayers(ctx)
 	if err != nil {
 		return err
 	}
+
+	// Prepare ICMP layer for Echo Request
+	icmp := layers.ICMPv4{
+		TypeCode: layers.CreateICMPv4TypeCode(layers.ICMPv4TypeEchoRequest, 0),
+		Id:       1, // You can set any ID
+		Seq:      1, // You can set any sequence number
+	}
+	if err := s.send(&eth, &ip4, &icmp); err != nil {
+		s.logger.Error("error sending ping", "err", err)
+	}
+	return nil
+}
+
+// icmpLayers resolves the next hop and returns the Ethernet and IPv4 layers
+// of an ICMP message to the target.
+func (s *scanner) icmpLayers(ctx context.Context) (layers.Ethernet, layers.IPv4, error) {
+	mac, err := s.sendARPRequest(ctx)
+	if err != nil {
+		return layers.Ethernet{}, layers.IPv4{}, err
+	}
 	eth := layers.Ethernet{
-		SrcMAC:       s.iface.HardwareAddr, // Replace with your source MAC address
-		DstMAC:       mac,                  // Broadcast MAC for ICMP
+		SrcMAC:       s.iface.HardwareAddr,
+		DstMAC:       mac,
 		EthernetType: layers.EthernetTypeIPv4,
 	}
 
@@ -192,20 +648,25 @@ func (s *Scanner) sendICMPEchoRequest() error {
 		SrcIP:    s.src,
 		DstIP:    s.dst,
 		Version:  4,
-		TTL:      64,
+		TTL:      s.ttl,
 		Protocol: layers.IPProtocolICMPv4,
 	}
+	return eth, ip4, nil
+}
 
-	// Prepare ICMP layer for Echo Request
-	icmp := layers.ICMPv4{
-		TypeCode: layers.CreateICMPv4TypeCode(layers.ICMPv4TypeEchoRequest, 0),
-		Id:       1, // You can set any ID
-		Seq:      1, // You can set any sequence number
+// openICMPHandle opens a capture handle receiving the ICMP messages of the
+// given type sent by the target.
+func (s *scanner) openICMPHandle(icmpType uint8) (*pcap.Handle, error) {
+	handle, err := openLive(s.iface.Name)
+	if err != nil {
+		return nil, err
 	}
-	if err := s.send(&eth, &ip4, &icmp); err != nil {
-		log.Printf("error %v sending ping", err)
+	bpfFilter := fmt.Sprintf("icmp and src host %s and icmp[0] == %d", s.dst, icmpType)
+	if err := handle.SetBPFFilter(s.bpfFilter(bpfFilter)); err != nil {
+		handle.Close()
+		return nil, err
 	}
-	return nil
+	return handle, nil
 }
 
 // HandlePacket processes a packet, extracting and analyzing its layers to determine
@@ -220,58 +681,183 @@ func (s *Scanner) sendICMPEchoRequest() error {
 // The function uses the gopacket library to decode the packet layers, filtering
 // based on Ethernet, IPv4, TCP, and ICMPv4 layers. If a SYN-ACK is detected on the
 // specified source port, it updates the openPorts map accordingly.
-func (s *Scanner) HandlePacket(data []byte, srcport layers.TCPPort, openPorts map[layers.TCPPort]string) {
+func (s *scanner) HandlePacket(data []byte, srcport layers.TCPPort, openPorts map[layers.TCPPort]string) {
+	s.handlePacket(data, srcport, openPorts)
+}
+
+// reply describes what handlePacket found in a captured packet.
+type reply struct {
+	// synAck is the port of a SYN-ACK answering one of our probes, 0 otherwise.
+	synAck layers.TCPPort
+	// quench is set for ICMP source quench messages from the target.
+	quench bool
+	// answered is the port of any answer to one of our probes, 0 otherwise.
+	answered layers.TCPPort
+	// mptcp is set when the SYN-ACK carries an MP_CAPABLE option, with the
+	// key of the server in mptcpKey.
+	mptcp    bool
+	mptcpKey uint64
+}
+
+// handlePacket implements HandlePacket, additionally reporting what the
+// packet was to the receive loop.
+func (s *scanner) handlePacket(data []byte, srcport layers.TCPPort, openPorts map[layers.TCPPort]string) reply {
+	var r reply
+	c := classifyReply(data, s.dst, s.src, srcport)
+	if c.state != "" {
+		r.answered = c.port
+	}
+	switch {
+	case c.state == "open":
+		openPorts[c.port] = "open"
+		r.synAck = c.port
+		r.mptcp, r.mptcpKey = c.mptcp, c.mptcpKey
+	case c.state == "filtered":
+		s.logger.Debug("port filtered", "port", c.port)
+	case c.echoReply:
+		s.logger.Debug("ICMP echo reply received", "target", s.dst)
+	case c.quench:
+		s.logger.Info("ICMP source quench received", "target", s.dst)
+		r.quench = true
+	}
+	return r
+}
+
+// classification is what classifyReply found in a captured packet.
+type classification struct {
+	// port and state are set for answers to a probe: "open" for a SYN-ACK,
+	// "closed" for a RST, "filtered" for an ICMP destination unreachable.
+	port  layers.TCPPort
+	state string
+	// echoReply and quench are set for ICMP echo replies and source quench
+	// messages from the target.
+	echoReply bool
+	quench    bool
+	// mptcp is set for SYN-ACKs carrying an MP_CAPABLE option, mptcpKey
+	// holding the key it advertises.
+	mptcp    bool
+	mptcpKey uint64
+}
+
+// ClassifyReply decodes a packet captured while SYN scanning target from
+// local:localPort, the way the Synscan receive loop does, and reports the
+// probed port it answers and the state it implies: "open" for a SYN-ACK,
+// "closed" for a RST and "filtered" for an ICMP destination unreachable
+// quoting the probe. ok is false for any other packet.
+func ClassifyReply(data []byte, target, local net.IP, localPort layers.TCPPort) (port layers.TCPPort, state string, ok bool) {
+	c := classifyReply(data, target, local, localPort)
+	return c.port, c.state, c.state != ""
+}
+
+// classifyReply implements ClassifyReply.
+func classifyReply(data []byte, target, local net.IP, localPort layers.TCPPort) classification {
+	var c classification
 	var eth layers.Ethernet
+	var dot1q layers.Dot1Q
 	var ip4 layers.IPv4
 	var tcp layers.TCP
 	var icmp layers.ICMPv4
 	var payload gopacket.Payload
-	parser := gopacket.NewDecodingLayerParser(layers.LayerTypeEthernet, &eth, &ip4, &tcp, &icmp, &payload)
+	parser := gopacket.NewDecodingLayerParser(layers.LayerTypeEthernet, &eth, &dot1q, &ip4, &tcp, &icmp, &payload)
 	parser.IgnoreUnsupported = true
 	decoded := []gopacket.LayerType{}
 
-	err := parser.DecodeLayers(data, &decoded)
-	if err != nil {
-		log.Printf("Decoding error:%v\n", err)
+	//nolint:staticcheck // SA9003 ignore this!
+	if err := parser.DecodeLayers(data, &decoded); err != nil {
+		// Errors here are due to the decoder, and not all layers are implemented.
 	}
-	ipFlow := gopacket.NewFlow(layers.EndpointIPv4, s.dst, s.src)
 
 	for _, typ := range decoded {
 		switch typ {
-
-		case layers.LayerTypeEthernet:
-			continue
-		case layers.LayerTypeIPv4:
-			if ip4.NetworkFlow() != ipFlow {
-				continue
-			}
 		case layers.LayerTypeTCP:
-			if tcp.DstPort != srcport {
+			if !ip4.SrcIP.Equal(target) || !ip4.DstIP.Equal(local) || tcp.DstPort != localPort {
 				continue
-
+			}
+			if tcp.SYN && tcp.ACK {
+				c.port, c.state = tcp.SrcPort, "open"
+				c.mptcpKey, c.mptcp = parseMPTCPCapable(tcp.Options)
 			} else if tcp.RST {
-				continue
-			} else if tcp.SYN && tcp.ACK {
-				openPorts[(tcp.SrcPort)] = "open"
-				continue
+				c.port, c.state = tcp.SrcPort, "closed"
 			}
 		case layers.LayerTypeICMPv4:
 			switch icmp.TypeCode.Type() {
 			case layers.ICMPv4TypeEchoReply:
-				log.Printf("ICMP Echo Reply received from %v", ip4.SrcIP)
+				c.echoReply = ip4.SrcIP.Equal(target)
 			case layers.ICMPv4TypeDestinationUnreachable:
-				log.Printf(" port %v filtered", tcp.SrcPort)
+				// Unreachables often come from a router or firewall on the
+				// way, what matters is that they quote one of our probes.
+				if port, ok := quotedProbePort(icmp.Payload, local, target, localPort); ok {
+					c.port, c.state = port, "filtered"
+				}
+			case layers.ICMPv4TypeSourceQuench:
+				c.quench = ip4.SrcIP.Equal(target)
 			}
 		}
 	}
+	return c
 }
 
 // Synscan performs a SYN port scan on the specified destination IP address using the provided network interface.
-// It sends SYN packets to ports [1, 65535] and records open ports in a map. The function employs ARP requests,
-// ICMP Echo Requests, and packet capturing to identify open, closed, or filtered ports.
-// The function returns a map of open ports along with their status or an error if any occurs during the scan.
-func (s *Scanner) Synscan() (map[layers.TCPPort]string, error) {
+// It sends SYN packets to ports [1, 65535], or to the ports set with WithPortList, and records open ports in a map.
+// The function employs ARP requests, ICMP Echo Requests, and packet capturing to identify open, closed, or filtered ports.
+// The function returns a ScanResult holding the open ports or an error if any occurs during the scan.
+func (s *scanner) Synscan() (*ScanResult, error) {
+	return s.SynscanContext(context.Background())
+}
+
+// SynscanContext is Synscan, stopping early when ctx is done: the ports found
+// open so far are then returned along with the error of ctx, wrapped in an
+// ErrScanTimeout when its deadline expired.
+func (s *scanner) SynscanContext(ctx context.Context) (*ScanResult, error) {
+	ports := s.scanPorts()
+	var cp *checkpointer
+	if s.checkpointPath != "" {
+		cp = s.newCheckpointer(ports)
+	}
+	return s.synscan(ctx, ports, cp)
+}
+
+// synscan implements Synscan, probing the given ports in order. It stops
+// early, returning the ports found so far, when ctx is done. When cp is not
+// nil, the progress of the scan is checkpointed.
+func (s *scanner) synscan(ctx context.Context, ports []layers.TCPPort, cp *checkpointer) (*ScanResult, error) {
+	select {
+	case <-s.done:
+		return nil, ErrScannerClosed
+	default:
+	}
+	if err := s.checkRawPackets(); err != nil {
+		return nil, err
+	}
+	if s.adaptiveRST && !s.rstChecked {
+		s.rstChecked = true
+		if _, err := s.RSTRateLimitDetect(ctx); err != nil {
+			s.logger.Error("RST rate limit detection failed", "target", s.dst, "err", err)
+		}
+	}
 	openPorts := make(map[layers.TCPPort]string)
+	// sigterm is only watched when checkpointing, to save the progress of
+	// the scan before exiting.
+	var sigterm chan os.Signal
+	if cp != nil {
+		ports = cp.remaining(ports)
+		for _, port := range cp.state.OpenPorts {
+			openPorts[port] = "open"
+		}
+		sigterm = make(chan os.Signal, 1)
+		signal.Notify(sigterm, syscall.SIGTERM)
+		defer signal.Stop(sigterm)
+	}
+	start := time.Now()
+	var stats ScanStats
+
+	// Unless a rate limit is set, packets are sent as fast as possible.
+	ctx, endSpan := s.tracer.start(ctx, "Synscan",
+		spanAttr{"net.peer.ip", s.dst.String()},
+		spanAttr{"scan.port_range", describePorts(ports)},
+		spanAttr{"scan.packet_rate", packetRate(s.sendInterval)},
+	)
+	defer endSpan()
 
 	var srcMAC, dstMAC net.HardwareAddr
 
@@ -280,10 +866,10 @@ func (s *Scanner) Synscan() (map[layers.TCPPort]string, error) {
 		// Use loopback MAC address for both source and destination
 		// srcMAC = net.HardwareAddr{0, 0, 0, 0, 0, 0}
 		// dstMAC = net.HardwareAddr{0, 0, 0, 0, 0, 0}
-		log.Fatal("You are trying to scan local address which require an open socket")
+		return nil, ErrLocalTarget
 	} else {
 		// Obtain MAC address from ARP request
-		mac, err := s.sendARPRequest()
+		mac, err := s.sendARPRequest(ctx)
 		if err != nil {
 			return nil, err
 		}
@@ -305,7 +891,7 @@ func (s *Scanner) Synscan() (map[layers.TCPPort]string, error) {
 		SrcIP:    s.src,
 		DstIP:    s.dst,
 		Version:  4,
-		TTL:      64,
+		TTL:      s.ttl,
 		Protocol: layers.IPProtocolTCP,
 	}
 	tcpOption := layers.TCPOption{
@@ -319,15 +905,21 @@ func (s *Scanner) Synscan() (map[layers.TCPPort]string, error) {
 		DstPort: 0,
 		Window:  1024,
 		Options: []layers.TCPOption{tcpOption},
-		Seq:     s.tcpsequencer.Next(),
+		Seq:     s.nextSeq(),
 		SYN:     true,
 	}
+	if s.tcpOptions != nil {
+		tcp.Options = s.tcpOptions
+	}
+	if s.detectMPTCP {
+		tcp.Options = append(tcp.Options[:len(tcp.Options):len(tcp.Options)], mptcpCapableOption)
+	}
 
 	err = tcp.SetNetworkLayerForChecksum(&ip4)
 	if err != nil {
 		return nil, err
 	}
-	handle, err := pcap.OpenLive(s.iface.Name, 65535, true, pcap.BlockForever)
+	handle, err := s.openCapture()
 	if err != nil {
 		return nil, err
 	}
@@ -337,69 +929,301 @@ func (s *Scanner) Synscan() (map[layers.TCPPort]string, error) {
 	// this rule should decrease the number of packets captured, still experimenting with this :D
 	bpfFilter := "icmp or (tcp and (tcp[13] & 0x02 != 0 or tcp[13] & 0x10 != 0 or tcp[13] & 0x04 != 0))"
 
-	err = handle.SetBPFFilter(bpfFilter)
+	err = handle.SetBPFFilter(s.bpfFilter(bpfFilter))
 	if err != nil {
 		return nil, err
 	}
 
 	defer handle.Close()
 
-	err = s.sendICMPEchoRequest()
+	err = s.sendICMPEchoRequest(ctx)
 	if err != nil {
 		return nil, err
 	}
 
-	for {
-		// Send one packet per loop iteration until we've sent packets
-		// to all of ports [1, 65535].
+	pace := newPacer(s.sendInterval, s.jitter, s.logger)
+	var ids *detection.IDSDetector
+	if s.idsEvents != nil {
+		ids = detection.NewIDSDetector(s.idsEvents)
+	}
+
+	var rdns <-chan reverseDNS
+	if s.resolveDNS {
+		rdns = lookupAddr(ctx, s.dst)
+	}
+	var asn <-chan *intel.ASNInfo
+	if s.lookupASN {
+		asn = lookupASN(s.dst, s.logger)
+	}
+
+	// sentAt records when the probe to each port was sent, so that the
+	// receive loop can measure the round-trip time of SYN-ACKs.
+	var sendMu sync.Mutex
+	// openMu guards openPorts, read by the checkpointer while the receive
+	// loop fills it.
+	var openMu sync.Mutex
+	sentAt := make(map[layers.TCPPort]time.Time, len(ports))
+	latencies := make(map[layers.TCPPort]time.Duration)
+	mptcpKeys := make(map[layers.TCPPort]uint64)
+
+	// Replies are read and handled on their own goroutine, so that probes
+	// are sent without waiting for them.
+	stopReading := make(chan struct{})
+	readerDone := make(chan struct{})
+	if s.monitorBandwidth {
+		monitor := newBandwidthMonitor()
+		s.monitor.Store(monitor)
+		defer func() {
+			monitor.halt()
+			s.monitor.CompareAndSwap(monitor, nil)
+		}()
+	}
+
+	var received, duplicates int
+	var dedupe *filter.DedupeFilter
+	if s.dedupe {
+		dedupe = filter.NewDedupeFilter(s.dedupeSize)
+	}
+	s.readers.Add(1)
+	go func() {
+		defer s.readers.Done()
+		defer close(readerDone)
+		cpu := s.cpu.worker()
+		for {
+			select {
+			case <-stopReading:
+				return
+			case <-s.done:
+				return
+			default:
+			}
+
+			data, ci, err := handle.ReadPacketData()
+			if err == pcap.NextErrorTimeoutExpired {
+				continue
+			} else if err != nil {
+				// Errors other than timeouts do not go away, such as the
+				// handle being closed: retrying would spin.
+				select {
+				case <-s.done:
+					return
+				default:
+				}
+				s.logger.Error("error reading packet", "err", err)
+				s.readMu.Lock()
+				s.readErr = err
+				s.readMu.Unlock()
+				return
+			}
+			limited := cpu.begin()
+			received++
+			s.received.Add(1)
+			s.monitor.Load().received(len(data))
+			s.packetLog.Record(debug.DirectionRx, ci.Timestamp, data)
+			s.metrics.PacketReceived(s.dst.String())
+			if dedupe != nil && dedupe.Duplicate(data) {
+				duplicates++
+				s.endCPUWork(cpu, limited)
+				continue
+			}
 
-		if tcp.DstPort < 65535 {
-			tcp.DstPort++
-			if err := s.send(&eth, &ip4, &tcp); err != nil {
-				log.Printf("error sending to port %v: %v", tcp.DstPort, err)
+			// Handle the packet and update openPorts map
+			openMu.Lock()
+			r := s.handlePacket(data, srctcpport, openPorts)
+			openMu.Unlock()
+			if r.quench {
+				// The target is overwhelmed, slow down.
+				pace.quench()
+			}
+			if r.answered != 0 {
+				ids.ResponseReceived(r.answered)
+			}
+			if r.mptcp {
+				mptcpKeys[r.synAck] = r.mptcpKey
+			}
+			if _, seen := latencies[r.synAck]; r.synAck != 0 && !seen {
+				s.emit(ScanEvent{Type: EventPortOpen, Port: r.synAck})
+				sendMu.Lock()
+				sent, ok := sentAt[r.synAck]
+				sendMu.Unlock()
+				if ok {
+					latencies[r.synAck] = ci.Timestamp.Sub(sent)
+				}
 			}
-		} else if tcp.DstPort == 65535 {
-			log.Printf("last port scanned for %v dst port %s", s.dst, tcp.DstPort)
-			return openPorts, nil
+			s.endCPUWork(cpu, limited)
 		}
+	}()
+
+	finish := func() *ScanResult {
+		close(stopReading)
+		<-readerDone
+		stats.PacketsReceived = received
+		stats.DuplicatesFiltered = duplicates
+		s.monitor.Load().finish(&stats)
+		stats.RateLimitEvents = pace.rateLimitEvents()
+		result := newScanResult(s.dst, start, openPorts)
+		stats.Duration = result.Stats.Duration
+		result.Stats = stats
+		result.setLatencies(latencies)
+		result.setMPTCP(mptcpKeys)
+		result.flagSuspicious(s.suspicious)
+		result.GeoInfo = s.geoLookup(s.dst)
+		if rdns != nil {
+			result.setHostnames(<-rdns)
+		}
+		if asn != nil {
+			result.ASNInfo = <-asn
+		}
+		if s.httpFingerprint {
+			s.fingerprintHTTP(result)
+		}
+		if s.axfrDomain != "" {
+			s.zoneTransfer(ctx, result)
+		}
+		if s.vncProbe {
+			s.probeVNC(ctx, result)
+		}
+		if s.detectHoneypots {
+			result.HoneypotScore = HoneypotScore(result)
+		}
+		s.metrics.SetOpenPorts(s.dst.String(), len(openPorts))
+		s.metrics.ObserveScanDuration(s.dst.String(), stats.Duration)
+		s.runPlugins(result)
+		s.notifyWebhook(result)
+		return result
+	}
 
-		// Read in the next packet.
-		data, _, err := handle.ReadPacketData()
-		if err == pcap.NextErrorTimeoutExpired {
-			continue
-		} else if err != nil {
-			log.Printf("error reading packet: %v", err)
+	// interrupted ends a scan stopped by SIGTERM, saving a checkpoint once
+	// the receive loop has exited.
+	interrupted := func() (*ScanResult, error) {
+		result := finish()
+		cp.save(openPorts)
+		s.logger.Info("scan interrupted, progress saved", "target", s.dst, "path", cp.path)
+		return result, ErrScanInterrupted
+	}
+
+	s.emit(ScanEvent{Type: EventScanStarted, Total: len(ports)})
+	defer func() {
+		s.emit(ScanEvent{Type: EventScanDone, Total: len(ports)})
+	}()
+
+	_, endTransmit := s.tracer.start(ctx, "transmit")
+	cpu := s.cpu.worker()
+	for i, port := range ports {
+		// Send one packet per loop iteration until we've sent packets
+		// to all of the ports.
+		if !s.allowed(port) {
+			if cp != nil {
+				cp.probed(port)
+			}
 			continue
 		}
+		if err := pace.wait(ctx); err != nil {
+			endTransmit()
+			return finish(), s.scanTimeout(err)
+		}
+		if err := s.sharedLimit.wait(ctx); err != nil {
+			endTransmit()
+			return finish(), s.scanTimeout(err)
+		}
+		select {
+		case <-s.done:
+			endTransmit()
+			return finish(), ErrScannerClosed
+		case <-s.draining:
+			// GracefulClose closes the scanner once the replies to the
+			// probes sent are in.
+			endTransmit()
+			<-s.done
+			return finish(), ErrScannerClosed
+		case <-sigterm:
+			endTransmit()
+			return interrupted()
+		default:
+		}
+		limited := cpu.begin()
+
+		s.setProbePort(&tcp, port)
+		decoys, dropped := s.sendDecoys(&eth, ip4, tcp)
+		stats.PacketsSent += decoys
+		stats.PacketsDroppedQueue += dropped
+		// The probe waits for room in the send queue when it is full.
+		sent := time.Now()
+		if err := s.sendProbe(queue.PriorityNormal, &eth, &ip4, &tcp); err != nil {
+			s.logger.Error("error sending probe", "port", tcp.DstPort, "err", err)
+		} else {
+			sendMu.Lock()
+			sentAt[port] = sent
+			sendMu.Unlock()
+			stats.PacketsSent++
+			if ids.ProbeSent(port) {
+				s.logger.Warn("response rate dropped, an IDS may be rate limiting, slowing down", "target", s.dst)
+				pace.backoff()
+			}
+		}
+		s.emit(ScanEvent{Type: EventProbeSent, Port: port, Sent: i + 1, Total: len(ports)})
+		if cp != nil && cp.probed(port) {
+			openMu.Lock()
+			cp.save(openPorts)
+			openMu.Unlock()
+		}
+		s.endCPUWork(cpu, limited)
+	}
+	endTransmit()
+	if len(ports) > 0 {
+		s.logger.Info("last port scanned", "target", s.dst, "port", ports[len(ports)-1])
+	}
 
-		// Handle the packet and update openPorts map
-		s.HandlePacket(data, srctcpport, openPorts)
+	// Keep listening for late replies once everything has been sent.
+	settle := time.NewTimer(pace.settle(s.settle))
+	defer settle.Stop()
+	select {
+	case <-ctx.Done():
+		return finish(), s.scanTimeout(ctx.Err())
+	case <-s.done:
+		return finish(), ErrScannerClosed
+	case <-sigterm:
+		return interrupted()
+	case <-settle.C:
 	}
+	if cp != nil {
+		if err := ClearCheckpoint(cp.path); err != nil {
+			s.logger.Error("error removing checkpoint", "path", cp.path, "err", err)
+		}
+	}
+	return finish(), nil
 }
 
 // ConnScan performs a full handshake on each TCP port, it supports ipv4 and ipv6.
-func (s *Scanner) ConnScan() (map[layers.TCPPort]string, error) {
+// The connections go through the proxy set with WithSOCKS5Proxy, if any.
+func (s *scanner) ConnScan() (map[layers.TCPPort]string, error) {
 	openPorts := make(map[layers.TCPPort]string)
 	var mutex sync.Mutex
 
 	retry := 3
 
+	// Without a memory limit, every port gets its own goroutine at once.
+	workers := s.limitMemory(connScanWorkers)
+	defer workers.close()
+
 	var wg sync.WaitGroup
 	for port := 1; port <= 65535; port++ {
+		workers.acquire()
 		wg.Add(1)
 		go func(p int) {
 			defer wg.Done()
+			defer workers.release()
 
 			// Use a loop for retries
 			for attempt := 1; attempt <= retry; attempt++ {
 				addr := fmt.Sprintf("[%s]:%d", s.dst.String(), p)
-				conn, err := net.DialTimeout("tcp", addr, 3*time.Second)
+				conn, err := s.dialTCP(addr, 3*time.Second)
 				if err == nil {
 					conn.Close()
 					serviceName, err := utils.GetServiceName(strconv.Itoa(p), "tcp")
 					if err != nil {
 						// Log or handle the error, and continue the loop
-						log.Printf("Error getting service name for port %d: %v", p, err)
+						s.logger.Error("error getting service name", "port", p, "err", err)
 					}
 
 					// Use mutex to safely update the map
@@ -414,19 +1238,17 @@ func (s *Scanner) ConnScan() (map[layers.TCPPort]string, error) {
 				time.Sleep(500 * time.Millisecond)
 			}
 		}(port)
-		if port == 65535 {
-			log.Printf("last port scanned for %v dst port %d", s.dst, port)
-			return openPorts, nil
-		}
-
 	}
 
+	// Every dial must complete before openPorts is returned, the map
+	// being written by the workers.
 	wg.Wait()
+	s.logger.Info("last port scanned", "target", s.dst, "port", 65535)
 
 	return openPorts, nil
 }
 
-func (s *Scanner) HandlePacketSock(data []byte, srcport layers.TCPPort) {
+func (s *scanner) HandlePacketSock(data []byte, srcport layers.TCPPort) {
 	var ip4 layers.IPv4
 	var ip6 layers.IPv6
 	var tcp layers.TCP
@@ -436,38 +1258,41 @@ func (s *Scanner) HandlePacketSock(data []byte, srcport layers.TCPPort) {
 
 	err := parser.DecodeLayers(data, &decoded)
 	if err != nil {
-		log.Printf("Decoding error:%v\n", err)
+		s.logger.Debug("decoding error", "err", err)
 	}
 	for _, typ := range decoded {
 		switch typ {
 		case layers.LayerTypeTCP:
 			if tcp.DstPort == layers.TCPPort(srcport) {
 				if tcp.SYN && tcp.ACK {
-					log.Printf("Port %v open\n", tcp.SrcPort)
+					s.logger.Info("port open", "port", tcp.SrcPort)
 				}
 			}
 		}
 	}
 }
 
-func (s *Scanner) SendSynTCP4(ip string, p layers.TCPPort) {
+// SendSynTCP4 sends a SYN to port p of ip through a raw socket and logs the
+// ports answering with a SYN-ACK. An error is returned when the socket
+// cannot be opened.
+func (s *scanner) SendSynTCP4(ip string, p layers.TCPPort) error {
 
 	conn, err := net.ListenPacket("ip4:tcp", "0.0.0.0")
 	if err != nil {
-		fmt.Println(err)
+		return err
 	}
 	defer conn.Close()
 
 	srctcpport, err := getFreeTCPPort()
 	if err != nil {
-		fmt.Println(err)
+		s.logger.Error(err.Error())
 	}
 
 	ip4 := layers.IPv4{
 		SrcIP:    s.src,
 		DstIP:    s.dst,
 		Version:  4,
-		TTL:      64,
+		TTL:      s.ttl,
 		Protocol: layers.IPProtocolTCP,
 	}
 
@@ -482,23 +1307,23 @@ func (s *Scanner) SendSynTCP4(ip string, p layers.TCPPort) {
 		DstPort: p,
 		Window:  1024,
 		Options: []layers.TCPOption{tcpOption},
-		Seq:     s.tcpsequencer.Next(),
+		Seq:     s.nextSeq(),
 		SYN:     true,
 	}
 
 	err = tcp.SetNetworkLayerForChecksum(&ip4)
 	if err != nil {
-		fmt.Println(err)
+		s.logger.Error(err.Error())
 	}
 
 	// Set deadline so we don't wait forever.
 	if err := conn.SetDeadline(time.Now().Add(50 * time.Millisecond)); err != nil {
-		fmt.Println(err)
+		return err
 	}
 
 	err = s.sendsock(ip, conn, &tcp)
 	if err != nil {
-		fmt.Println(err)
+		s.logger.Error(err.Error())
 	}
 
 	for {
@@ -511,20 +1336,21 @@ func (s *Scanner) SendSynTCP4(ip string, p layers.TCPPort) {
 			s.HandlePacketSock(b[:n], srctcpport)
 		}
 	}
+	return nil
 }
 
-func (s *Scanner) SendSynTCP6(ip string, p layers.TCPPort) {
+// SendSynTCP6 is the IPv6 counterpart of SendSynTCP4.
+func (s *scanner) SendSynTCP6(ip string, p layers.TCPPort) error {
 
 	conn, err := net.ListenPacket("ip6:tcp", "::")
 	if err != nil {
-		fmt.Println(err)
-		return
+		return err
 	}
 	defer conn.Close()
 
 	srctcpport, err := getFreeTCPPort()
 	if err != nil {
-		fmt.Println(err)
+		s.logger.Error(err.Error())
 	}
 	ip6 := layers.IPv6{
 		DstIP:      s.dst,
@@ -545,22 +1371,22 @@ func (s *Scanner) SendSynTCP6(ip string, p layers.TCPPort) {
 		DstPort: p,
 		Window:  1024,
 		Options: []layers.TCPOption{tcpOption},
-		Seq:     s.tcpsequencer.Next(),
+		Seq:     s.nextSeq(),
 		SYN:     true,
 	}
 
 	err = tcp.SetNetworkLayerForChecksum(&ip6)
 	if err != nil {
-		fmt.Println(err)
+		s.logger.Error(err.Error())
 	}
 
 	err = s.sendsock(ip, conn, &tcp)
 	if err != nil {
-		fmt.Println(err)
+		s.logger.Error(err.Error())
 	}
 	// Set deadline so we don't wait forever.
 	if err := conn.SetDeadline(time.Now().Add(50 * time.Millisecond)); err != nil {
-		log.Fatal(err)
+		return err
 	}
 	for {
 		b := make([]byte, 4096)
@@ -572,10 +1398,12 @@ func (s *Scanner) SendSynTCP6(ip string, p layers.TCPPort) {
 			s.HandlePacketSock(b[:n], srctcpport)
 		}
 	}
+	return nil
 }
 
-func (s *Scanner) sendsock(destIP string, conn net.PacketConn, l ...gopacket.SerializableLayer) error {
-	buf := gopacket.NewSerializeBuffer()
+func (s *scanner) sendsock(destIP string, conn net.PacketConn, l ...gopacket.SerializableLayer) error {
+	buf := bufferPool.Get().(gopacket.SerializeBuffer)
+	defer bufferPool.Put(buf)
 
 	if err := gopacket.SerializeLayers(buf, s.opts, l...); err != nil {
 		return err
@@ -0,0 +1,333 @@
+package scanme
+
+import (
+	"errors"
+	"net"
+	"os"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/CyberRoute/scanme/scanme/queue"
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"github.com/google/gopacket/routing"
+	"go.uber.org/goleak"
+)
+
+var (
+	testLocal  = net.IPv4(192, 168, 1, 10).To4()
+	testTarget = net.IPv4(10, 0, 0, 5).To4()
+	testRouter = net.IPv4(192, 168, 1, 1).To4()
+)
+
+const testLocalPort layers.TCPPort = 54321
+
+// serialize serializes l with computed lengths and checksums.
+func serialize(t *testing.T, l ...gopacket.SerializableLayer) []byte {
+	t.Helper()
+	buf := gopacket.NewSerializeBuffer()
+	opts := gopacket.SerializeOptions{FixLengths: true, ComputeChecksums: true}
+	if err := gopacket.SerializeLayers(buf, opts, l...); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// icmpUnreachable crafts the ICMP port unreachable a router sends back for
+// a SYN from src:srcPort to dst:dstPort, quoting its IPv4 header and the
+// first 8 bytes of its TCP header as RFC 792 requires.
+func icmpUnreachable(t *testing.T, src, dst net.IP, srcPort, dstPort layers.TCPPort) []byte {
+	t.Helper()
+	probeIP := layers.IPv4{Version: 4, TTL: 64, Protocol: layers.IPProtocolTCP, SrcIP: src, DstIP: dst}
+	probeTCP := layers.TCP{SrcPort: srcPort, DstPort: dstPort, Seq: 1, SYN: true, Window: 1024}
+	if err := probeTCP.SetNetworkLayerForChecksum(&probeIP); err != nil {
+		t.Fatal(err)
+	}
+	probe := serialize(t, &probeIP, &probeTCP)
+	quoted := probe[:20+8]
+
+	return serialize(t,
+		&layers.Ethernet{
+			SrcMAC:       net.HardwareAddr{0, 1, 2, 3, 4, 5},
+			DstMAC:       net.HardwareAddr{0, 1, 2, 3, 4, 6},
+			EthernetType: layers.EthernetTypeIPv4,
+		},
+		&layers.IPv4{Version: 4, TTL: 64, Protocol: layers.IPProtocolICMPv4, SrcIP: testRouter, DstIP: src},
+		&layers.ICMPv4{TypeCode: layers.CreateICMPv4TypeCode(layers.ICMPv4TypeDestinationUnreachable, layers.ICMPv4CodePort)},
+		gopacket.Payload(quoted),
+	)
+}
+
+func TestClassifyReplyICMPUnreachable(t *testing.T) {
+	tests := []struct {
+		name      string
+		packet    []byte
+		wantPort  layers.TCPPort
+		wantState string
+		wantOK    bool
+	}{
+		{
+			name:      "quotes our probe",
+			packet:    icmpUnreachable(t, testLocal, testTarget, testLocalPort, 8080),
+			wantPort:  8080,
+			wantState: "filtered",
+			wantOK:    true,
+		},
+		{
+			name:   "quotes another source port",
+			packet: icmpUnreachable(t, testLocal, testTarget, testLocalPort+1, 8080),
+		},
+		{
+			name:   "quotes another target",
+			packet: icmpUnreachable(t, testLocal, net.IPv4(10, 0, 0, 6).To4(), testLocalPort, 8080),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			port, state, ok := ClassifyReply(tt.packet, testTarget, testLocal, testLocalPort)
+			if ok != tt.wantOK || port != tt.wantPort || state != tt.wantState {
+				t.Errorf("ClassifyReply() = %d, %q, %v, want %d, %q, %v", port, state, ok, tt.wantPort, tt.wantState, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestQuotedProbePortTruncated(t *testing.T) {
+	packet := icmpUnreachable(t, testLocal, testTarget, testLocalPort, 443)
+	// The quoted datagram starts after the Ethernet, IPv4 and ICMP headers.
+	quoted := packet[14+20+8:]
+	if port, ok := quotedProbePort(quoted, testLocal, testTarget, testLocalPort); !ok || port != 443 {
+		t.Fatalf("quotedProbePort() = %d, %v, want 443, true", port, ok)
+	}
+	if _, ok := quotedProbePort(quoted[:20+2], testLocal, testTarget, testLocalPort); ok {
+		t.Error("quotedProbePort() accepted a quote without the TCP ports")
+	}
+}
+
+func TestCloseDuringSynscanLeaksNoGoroutine(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("SYN scans capture packets, which requires root")
+	}
+	defer goleak.VerifyNone(t, goleak.IgnoreCurrent())
+
+	router, err := routing.New()
+	if err != nil {
+		t.Skipf("no routing table: %v", err)
+	}
+	// TEST-NET-1 is routed through the default gateway, which the scan
+	// resolves with ARP before probing.
+	s, err := NewScanner(net.IPv4(192, 0, 2, 1), router, WithPortList([]layers.TCPPort{22, 80, 443}))
+	if err != nil {
+		t.Skipf("cannot create scanner: %v", err)
+	}
+	scanned := make(chan struct{})
+	go func() {
+		defer close(scanned)
+		s.Synscan() //nolint:errcheck // interrupted by Close
+	}()
+	time.Sleep(10 * time.Millisecond)
+	s.Close()
+	<-scanned
+	if err := s.Wait(); err != nil {
+		t.Errorf("Wait() = %v, want nil after Close", err)
+	}
+}
+
+// probeSeqs serializes the SYN probes a scan by s sends to ports and returns
+// their sequence numbers, checking their acknowledgment numbers are zero.
+func probeSeqs(t *testing.T, s *scanner, ports []layers.TCPPort) []uint32 {
+	t.Helper()
+	ip4 := layers.IPv4{Version: 4, TTL: 64, Protocol: layers.IPProtocolTCP, SrcIP: testLocal, DstIP: testTarget}
+	tcp := layers.TCP{SrcPort: testLocalPort, Window: 1024, Seq: s.nextSeq(), SYN: true}
+	if err := tcp.SetNetworkLayerForChecksum(&ip4); err != nil {
+		t.Fatal(err)
+	}
+	var seqs []uint32
+	for _, port := range ports {
+		s.setProbePort(&tcp, port)
+		packet := gopacket.NewPacket(serialize(t, &ip4, &tcp), layers.LayerTypeIPv4, gopacket.Default)
+		sent, ok := packet.Layer(layers.LayerTypeTCP).(*layers.TCP)
+		if !ok {
+			t.Fatalf("probe to port %d has no TCP layer", port)
+		}
+		if sent.DstPort != port || sent.Ack != 0 {
+			t.Errorf("probe to port %d: DstPort %d, Ack %d, want Ack 0", port, sent.DstPort, sent.Ack)
+		}
+		seqs = append(seqs, sent.Seq)
+	}
+	return seqs
+}
+
+func TestRandomISN(t *testing.T) {
+	ports := []layers.TCPPort{22, 80, 443, 8080, 8443}
+
+	linear := probeSeqs(t, newTestScanner(), ports)
+	for _, seq := range linear[1:] {
+		if seq != linear[0] {
+			t.Fatalf("without WithRandomISN, probes carry different Seq values: %v", linear)
+		}
+	}
+
+	random := probeSeqs(t, newTestScanner(WithRandomISN()), ports)
+	seen := make(map[uint32]bool)
+	for _, seq := range random {
+		if seen[seq] {
+			t.Fatalf("with WithRandomISN, probes share a Seq value: %v", random)
+		}
+		seen[seq] = true
+	}
+}
+
+// injectReplies counts a reply on s every millisecond, as the receive loop
+// of a scan does, until s is closed or, once GracefulClose stopped the
+// probes, for inFlight. It returns when the last reply was counted.
+func injectReplies(s *scanner, inFlight time.Duration) <-chan time.Time {
+	last := make(chan time.Time, 1)
+	go func() {
+		var drainStart time.Time
+		ticker := time.NewTicker(time.Millisecond)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-s.done:
+				last <- time.Now()
+				return
+			case <-ticker.C:
+			}
+			select {
+			case <-s.draining:
+				if drainStart.IsZero() {
+					drainStart = time.Now()
+				}
+				if time.Since(drainStart) >= inFlight {
+					last <- time.Now()
+					return
+				}
+			default:
+			}
+			s.received.Add(1)
+		}
+	}()
+	return last
+}
+
+func TestGracefulCloseWaitsForReplies(t *testing.T) {
+	s := newTestScanner()
+	const inFlight = 150 * time.Millisecond
+	last := injectReplies(s, inFlight)
+	time.Sleep(20 * time.Millisecond)
+
+	start := time.Now()
+	if err := s.GracefulClose(5 * time.Second); err != nil {
+		t.Fatalf("GracefulClose() error = %v", err)
+	}
+	closed := time.Now()
+	select {
+	case <-s.done:
+	default:
+		t.Error("scanner not closed")
+	}
+
+	// Replies are counted every drainPoll, the last one may have been
+	// seen up to drainPoll late.
+	stopped := <-last
+	if quiet := drainQuiet - drainPoll; closed.Before(stopped.Add(quiet)) {
+		t.Errorf("closed %v after the last reply, want at least %v", closed.Sub(stopped), quiet)
+	}
+	if elapsed := closed.Sub(start); elapsed < inFlight {
+		t.Errorf("GracefulClose() returned after %v, before the replies in flight for %v", elapsed, inFlight)
+	}
+}
+
+func TestGracefulCloseTimeout(t *testing.T) {
+	s := newTestScanner()
+	// The replies never stop until the scanner is closed.
+	last := injectReplies(s, time.Hour)
+
+	const timeout = 100 * time.Millisecond
+	start := time.Now()
+	if err := s.GracefulClose(timeout); !errors.Is(err, ErrDrainTimeout) {
+		t.Errorf("GracefulClose() error = %v, want ErrDrainTimeout", err)
+	}
+	if elapsed := time.Since(start); elapsed < timeout || elapsed > timeout+time.Second {
+		t.Errorf("GracefulClose() returned after %v, want about %v", elapsed, timeout)
+	}
+	select {
+	case <-last:
+	case <-time.After(time.Second):
+		t.Error("scanner not closed after the drain timeout")
+	}
+}
+
+func TestGracefulCloseQuiet(t *testing.T) {
+	s := newTestScanner()
+	start := time.Now()
+	if err := s.GracefulClose(5 * time.Second); err != nil {
+		t.Fatalf("GracefulClose() error = %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("GracefulClose() of an idle scanner took %v", elapsed)
+	}
+	// Closing again is harmless.
+	s.Close()
+	if err := s.GracefulClose(time.Second); err != nil {
+		t.Errorf("second GracefulClose() error = %v", err)
+	}
+}
+
+// BenchmarkSendBuffers serializes and queues SYN probes with the buffers of
+// bufferPool, as sendPriority does, and with a new buffer for each probe as
+// before the pool. Besides allocs/op, it reports the mallocs, bytes and
+// garbage collections per probe read from runtime.MemStats.
+func BenchmarkSendBuffers(b *testing.B) {
+	eth := layers.Ethernet{
+		SrcMAC:       net.HardwareAddr{0, 1, 2, 3, 4, 5},
+		DstMAC:       net.HardwareAddr{0, 1, 2, 3, 4, 6},
+		EthernetType: layers.EthernetTypeIPv4,
+	}
+	ip4 := layers.IPv4{Version: 4, TTL: 64, Protocol: layers.IPProtocolTCP, SrcIP: testLocal, DstIP: testTarget}
+	tcp := layers.TCP{SrcPort: testLocalPort, DstPort: 80, Window: 1024, SYN: true}
+	if err := tcp.SetNetworkLayerForChecksum(&ip4); err != nil {
+		b.Fatal(err)
+	}
+
+	run := func(b *testing.B, send func(s *scanner) error) {
+		s := newTestScanner()
+		s.opts = gopacket.SerializeOptions{FixLengths: true, ComputeChecksums: true}
+		s.queue = queue.New(func([]byte) error { return nil }, 1024, nil)
+		defer s.queue.Close()
+
+		var before, after runtime.MemStats
+		runtime.GC()
+		runtime.ReadMemStats(&before)
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			if err := send(s); err != nil {
+				b.Fatal(err)
+			}
+		}
+		b.StopTimer()
+		runtime.ReadMemStats(&after)
+		n := float64(b.N)
+		b.ReportMetric(float64(after.Mallocs-before.Mallocs)/n, "mallocs/probe")
+		b.ReportMetric(float64(after.TotalAlloc-before.TotalAlloc)/n, "bytes/probe")
+		b.ReportMetric(float64(after.NumGC-before.NumGC)/n*1e6, "GCs/1M-probes")
+	}
+
+	b.Run("pool", func(b *testing.B) {
+		run(b, func(s *scanner) error {
+			return s.sendPriority(queue.PriorityHigh, &eth, &ip4, &tcp)
+		})
+	})
+	b.Run("no-pool", func(b *testing.B) {
+		run(b, func(s *scanner) error {
+			buf := gopacket.NewSerializeBuffer()
+			if err := gopacket.SerializeLayers(buf, s.opts, &eth, &ip4, &tcp); err != nil {
+				return err
+			}
+			return s.queue.Enqueue(buf.Bytes(), queue.PriorityHigh)
+		})
+	})
+}
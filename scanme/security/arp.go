@@ -0,0 +1,110 @@
+package security
+
+import (
+	"context"
+	"log/slog"
+	"net"
+	"time"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"github.com/google/gopacket/pcap"
+)
+
+// pcapReadTimeout bounds every read, so that ARPMonitor notices when its
+// context is done.
+const pcapReadTimeout = 100 * time.Millisecond
+
+// ARPConflict is an IP address seen with a new MAC address.
+type ARPConflict struct {
+	IP          net.IP
+	ExpectedMAC net.HardwareAddr
+	NewMAC      net.HardwareAddr
+	Time        time.Time
+}
+
+// ARPMonitor captures the ARP traffic on iface, without sending anything,
+// and learns the MAC address of every IP address announced. Whenever an
+// address is announced from another MAC address, which is what ARP spoofing
+// looks like, an ARPConflict is sent on the returned channel and the new
+// address is learned. The channel is closed once ctx is done.
+//
+// Hosts legitimately changing MAC addresses, such as failover pairs sharing
+// a virtual IP, also cause conflicts. Capture errors are logged to logger,
+// slog.Default when nil.
+func ARPMonitor(ctx context.Context, iface *net.Interface, logger *slog.Logger) (<-chan ARPConflict, error) {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	handle, err := pcap.OpenLive(iface.Name, 65535, true, pcapReadTimeout)
+	if err != nil {
+		return nil, err
+	}
+	if err := handle.SetBPFFilter("arp"); err != nil {
+		handle.Close()
+		return nil, err
+	}
+
+	conflicts := make(chan ARPConflict, 16)
+	go func() {
+		defer close(conflicts)
+		defer handle.Close()
+
+		var eth layers.Ethernet
+		var dot1q layers.Dot1Q
+		var arp layers.ARP
+		parser := gopacket.NewDecodingLayerParser(layers.LayerTypeEthernet, &eth, &dot1q, &arp)
+		parser.IgnoreUnsupported = true
+		decoded := []gopacket.LayerType{}
+
+		table := make(map[string]net.HardwareAddr)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			default:
+			}
+
+			data, ci, err := handle.ReadPacketData()
+			if err == pcap.NextErrorTimeoutExpired {
+				continue
+			} else if err != nil {
+				logger.Error("error reading packet", "err", err)
+				continue
+			}
+
+			//nolint:staticcheck // SA9003 ignore this!
+			if err := parser.DecodeLayers(data, &decoded); err != nil {
+				// Errors here are due to the decoder, and not all layers are implemented.
+			}
+			if len(decoded) == 0 || decoded[len(decoded)-1] != layers.LayerTypeARP {
+				continue
+			}
+
+			ip := net.IP(arp.SourceProtAddress)
+			// ARP probes (RFC 5227) are sent from 0.0.0.0.
+			if ip.IsUnspecified() {
+				continue
+			}
+			mac := net.HardwareAddr(append([]byte(nil), arp.SourceHwAddress...))
+			known, ok := table[ip.String()]
+			table[ip.String()] = mac
+			if !ok || known.String() == mac.String() {
+				continue
+			}
+
+			conflict := ARPConflict{
+				IP:          append(net.IP(nil), ip...),
+				ExpectedMAC: known,
+				NewMAC:      mac,
+				Time:        ci.Timestamp,
+			}
+			select {
+			case conflicts <- conflict:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return conflicts, nil
+}
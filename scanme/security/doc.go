@@ -0,0 +1,3 @@
+// Package security detects attacks on the local network that may tamper
+// with scan results, such as ARP spoofing by a man in the middle.
+package security
@@ -0,0 +1,231 @@
+// Package session manages the scans of many hosts over the lifetime of a
+// program: targets are added as they are discovered and scanned, one after
+// the other or in parallel, sharing an ARP cache, the pcap handles of their
+// interfaces and a global rate limit.
+package session
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/CyberRoute/scanme/scanme"
+	"github.com/google/gopacket/routing"
+)
+
+// arpCacheTTL is how long the MAC addresses resolved by the scans of a
+// session are reused.
+const arpCacheTTL = time.Minute
+
+// ScanSession scans the targets added to it with SYN scans. Scans of the
+// same session resolve every next hop once, inject packets through a single
+// pcap handle per interface and, when a rate limit is set with
+// SetRateLimit, send no more probes per second together than it allows. A
+// ScanSession is safe for concurrent use.
+type ScanSession struct {
+	router   routing.Router
+	opts     []scanme.Option
+	arpCache *scanme.ARPCache
+	handles  *scanme.HandlePool
+
+	mu          sync.Mutex
+	limiter     *scanme.RateLimiter
+	concurrency int
+	pending     []net.IP
+	results     []*scanme.ScanResult
+	onResult    []func(*scanme.ScanResult)
+	running     map[closer]struct{}
+	closed      bool
+	// scans tracks the running scans, so that Close can wait for them
+	// before closing the handles they use.
+	scans sync.WaitGroup
+
+	// callbackMu serializes the calls to the OnResult callbacks.
+	callbackMu sync.Mutex
+}
+
+// closer is the scanner of a running scan, that Close stops.
+type closer interface{ Close() }
+
+// NewSession creates a session routing packets with router and creating
+// every scanner with opts. Scans run one at a time, see SetConcurrency.
+func NewSession(router routing.Router, opts ...scanme.Option) *ScanSession {
+	return &ScanSession{
+		router:      router,
+		opts:        opts,
+		arpCache:    scanme.NewARPCache(arpCacheTTL),
+		handles:     scanme.NewHandlePool(),
+		concurrency: 1,
+		running:     make(map[closer]struct{}),
+	}
+}
+
+// SetConcurrency sets the number of scans Run starts at once, one by
+// default. A n lower than one is treated as one.
+func (s *ScanSession) SetConcurrency(n int) {
+	if n < 1 {
+		n = 1
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.concurrency = n
+}
+
+// SetRateLimit caps the number of probes per second the scans started
+// afterwards send together, across all of them.
+func (s *ScanSession) SetRateLimit(packetsPerSecond int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.limiter = scanme.NewRateLimiter(packetsPerSecond)
+}
+
+// Add queues ip to be scanned by the next call to Run.
+func (s *ScanSession) Add(ip net.IP) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pending = append(s.pending, ip)
+}
+
+// OnResult registers fn to be called with the result of every scan as soon
+// as it completes, e.g. to stream results. Callbacks are called one at a
+// time, in the order they were registered.
+func (s *ScanSession) OnResult(fn func(*scanme.ScanResult)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.onResult = append(s.onResult, fn)
+}
+
+// Run scans the targets added since the previous call and returns once all
+// scans are done. A failed scan does not stop the others: its error,
+// annotated with the target, is part of the returned error. When ctx is
+// done, running scans are stopped and targets not started yet are left
+// queued for the next call.
+func (s *ScanSession) Run(ctx context.Context) error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return scanme.ErrScannerClosed
+	}
+	targets := s.pending
+	s.pending = nil
+	sem := make(chan struct{}, s.concurrency)
+	s.mu.Unlock()
+
+	var errsMu sync.Mutex
+	var errs []error
+	var wg sync.WaitGroup
+	for i, target := range targets {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			s.mu.Lock()
+			s.pending = append(targets[i:len(targets):len(targets)], s.pending...)
+			s.mu.Unlock()
+			errsMu.Lock()
+			errs = append(errs, ctx.Err())
+			errsMu.Unlock()
+			break
+		}
+
+		wg.Add(1)
+		go func(target net.IP) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			if err := s.scan(ctx, target); err != nil {
+				errsMu.Lock()
+				errs = append(errs, fmt.Errorf("%v: %w", target, err))
+				errsMu.Unlock()
+			}
+		}(target)
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+// scan runs a single scan and records its result.
+func (s *ScanSession) scan(ctx context.Context, target net.IP) error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return scanme.ErrScannerClosed
+	}
+	s.scans.Add(1)
+	defer s.scans.Done()
+	opts := append(s.opts[:len(s.opts):len(s.opts)],
+		scanme.WithARPCache(s.arpCache),
+		scanme.WithHandlePool(s.handles),
+		scanme.WithSharedRateLimit(s.limiter),
+	)
+	s.mu.Unlock()
+
+	scanner, err := scanme.NewScanner(target, s.router, opts...)
+	if err != nil {
+		return err
+	}
+	defer scanner.Close()
+
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return scanme.ErrScannerClosed
+	}
+	s.running[scanner] = struct{}{}
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.running, scanner)
+		s.mu.Unlock()
+	}()
+
+	// Synscan has no context: closing the scanner stops it.
+	stop := context.AfterFunc(ctx, scanner.Close)
+	defer stop()
+
+	result, err := scanner.Synscan()
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	s.results = append(s.results, result)
+	callbacks := s.onResult
+	s.mu.Unlock()
+
+	s.callbackMu.Lock()
+	defer s.callbackMu.Unlock()
+	for _, fn := range callbacks {
+		fn(result)
+	}
+	return nil
+}
+
+// Results returns the results of the scans completed so far, in the order
+// they completed.
+func (s *ScanSession) Results() []*scanme.ScanResult {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]*scanme.ScanResult(nil), s.results...)
+}
+
+// Close stops the running scans, waits for them to return and releases the
+// pcap handles of the session, which cannot be used anymore.
+func (s *ScanSession) Close() {
+	s.mu.Lock()
+	s.closed = true
+	running := make([]closer, 0, len(s.running))
+	for scanner := range s.running {
+		running = append(running, scanner)
+	}
+	s.mu.Unlock()
+
+	for _, scanner := range running {
+		scanner.Close()
+	}
+	s.scans.Wait()
+	s.handles.Close()
+}
@@ -0,0 +1,122 @@
+package scanme
+
+import (
+	"context"
+	"log/slog"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/google/gopacket/pcap"
+)
+
+// The types below hold resources several scanners can share, typically
+// when scanning many hosts from the same machine, see the scanme/session
+// package.
+
+// ARPCache remembers the MAC addresses ARP requests resolved, so that
+// scanners sharing it through WithARPCache resolve every next hop, such as
+// the gateway, once. It is safe for concurrent use.
+type ARPCache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	entries map[string]arpEntry
+}
+
+type arpEntry struct {
+	mac     net.HardwareAddr
+	expires time.Time
+}
+
+// NewARPCache returns an empty cache keeping addresses for ttl.
+func NewARPCache(ttl time.Duration) *ARPCache {
+	return &ARPCache{ttl: ttl, entries: make(map[string]arpEntry)}
+}
+
+// Lookup returns the MAC address of ip, if cached and not expired.
+func (c *ARPCache) Lookup(ip net.IP) (net.HardwareAddr, bool) {
+	if c == nil {
+		return nil, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[ip.String()]
+	if !ok || time.Now().After(e.expires) {
+		return nil, false
+	}
+	return e.mac, true
+}
+
+// Add records mac as the MAC address of ip.
+func (c *ARPCache) Add(ip net.IP, mac net.HardwareAddr) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[ip.String()] = arpEntry{mac: mac, expires: time.Now().Add(c.ttl)}
+}
+
+// HandlePool opens a single pcap handle per interface for the scanners
+// sharing it through WithHandlePool to inject packets with, instead of one
+// per scanner. It is safe for concurrent use.
+type HandlePool struct {
+	mu      sync.Mutex
+	handles map[string]*pcap.Handle
+}
+
+// NewHandlePool returns an empty pool.
+func NewHandlePool() *HandlePool {
+	return &HandlePool{handles: make(map[string]*pcap.Handle)}
+}
+
+// get returns the handle of iface, opening it on first use.
+func (p *HandlePool) get(iface string) (*pcap.Handle, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if h, ok := p.handles[iface]; ok {
+		return h, nil
+	}
+	h, err := openLive(iface)
+	if err != nil {
+		return nil, err
+	}
+	p.handles[iface] = h
+	return h, nil
+}
+
+// Close closes every handle of the pool. Scanners using them must not be
+// used anymore.
+func (p *HandlePool) Close() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for name, h := range p.handles {
+		h.Close()
+		delete(p.handles, name)
+	}
+}
+
+// RateLimiter caps the number of probes per second sent by all the
+// scanners sharing it through WithSharedRateLimit, on top of their own
+// WithRateLimit. It is safe for concurrent use.
+type RateLimiter struct {
+	pace *pacer
+}
+
+// NewRateLimiter returns a limiter allowing packetsPerSecond probes per
+// second, or any number of them when packetsPerSecond is not positive.
+func NewRateLimiter(packetsPerSecond int) *RateLimiter {
+	var interval time.Duration
+	if packetsPerSecond > 0 {
+		interval = time.Second / time.Duration(packetsPerSecond)
+	}
+	return &RateLimiter{pace: newPacer(interval, 0, slog.Default())}
+}
+
+// wait blocks until the next probe may be sent or ctx is done.
+func (l *RateLimiter) wait(ctx context.Context) error {
+	if l == nil {
+		return ctx.Err()
+	}
+	return l.pace.wait(ctx)
+}
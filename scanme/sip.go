@@ -0,0 +1,234 @@
+package scanme
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// sipPort is the port of SIP over UDP and TCP.
+const sipPort = 5060
+
+// sipTimeout bounds each SIP exchange when ctx has no deadline.
+const sipTimeout = 3 * time.Second
+
+// SIPServiceHint is the PortResult.ServiceHint of SIP servers.
+const SIPServiceHint = "sip-open"
+
+// sipProducts maps substrings of the Server or User-Agent header to the SIP
+// server they identify.
+var sipProducts = []struct {
+	match, name string
+}{
+	{"asterisk", "Asterisk"},
+	{"freeswitch", "FreeSWITCH"},
+	{"kamailio", "Kamailio"},
+	{"opensips", "OpenSIPS"},
+	{"cisco", "Cisco UCM"},
+}
+
+// SIPInfo describes the SIP server of the target.
+type SIPInfo struct {
+	// StatusCode is the status of the reply to OPTIONS, 200 unless the
+	// server requires authentication or rejects the request.
+	StatusCode int
+	// Allow lists the methods supported, Supported the SIP extensions.
+	Allow     []string
+	Supported []string
+	Server    string
+	UserAgent string
+	// Product is the SIP server identified from Server or User-Agent, such
+	// as "Asterisk" or "Kamailio", empty when unknown.
+	Product string
+	// Transports lists the transports the server answered on, "udp" and
+	// "tcp".
+	Transports []string
+}
+
+// SIPProbe sends a SIP OPTIONS request to port 5060 of the target over UDP
+// and TCP and parses the headers of the reply. Any SIP reply marks a SIP
+// server, which can be recorded with
+//
+//	result.SetServiceHint(5060, scanme.SIPServiceHint)
+func (s *scanner) SIPProbe(ctx context.Context) (*SIPInfo, error) {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(sipTimeout)
+	}
+
+	var info *SIPInfo
+	var errs []error
+	for _, transport := range []string{"udp", "tcp"} {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		var resp []byte
+		var err error
+		if transport == "udp" {
+			resp, err = s.sipUDP(deadline)
+		} else {
+			resp, err = s.sipTCP(deadline)
+		}
+		if err == nil {
+			var r *SIPInfo
+			if r, err = parseSIPResponse(resp); err == nil {
+				if info == nil {
+					info = r
+				}
+				info.Transports = append(info.Transports, transport)
+				continue
+			}
+		}
+		errs = append(errs, fmt.Errorf("sip over %s: %w", transport, err))
+	}
+	if info == nil {
+		return nil, errors.Join(errs...)
+	}
+	return info, nil
+}
+
+// sipOptions returns an OPTIONS request sent over transport from port.
+func (s *scanner) sipOptions(transport string, port int) []byte {
+	local := net.JoinHostPort(s.src.String(), strconv.Itoa(port))
+	tag := s.rng.Uint32()
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "OPTIONS sip:%s SIP/2.0\r\n", s.dst)
+	fmt.Fprintf(&b, "Via: SIP/2.0/%s %s;branch=z9hG4bK%08x;rport\r\n", strings.ToUpper(transport), local, tag)
+	b.WriteString("Max-Forwards: 70\r\n")
+	fmt.Fprintf(&b, "From: <sip:scanme@%s>;tag=%08x\r\n", s.src, tag)
+	fmt.Fprintf(&b, "To: <sip:%s>\r\n", s.dst)
+	fmt.Fprintf(&b, "Call-ID: %08x@%s\r\n", tag, s.src)
+	b.WriteString("CSeq: 1 OPTIONS\r\n")
+	fmt.Fprintf(&b, "Contact: <sip:scanme@%s>\r\n", local)
+	b.WriteString("Accept: application/sdp\r\n")
+	b.WriteString("Content-Length: 0\r\n\r\n")
+	return b.Bytes()
+}
+
+// sipUDP sends an OPTIONS request over UDP and returns the reply.
+func (s *scanner) sipUDP(deadline time.Time) ([]byte, error) {
+	conn, err := net.ListenPacket("udp4", "")
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	if err := conn.SetDeadline(deadline); err != nil {
+		return nil, err
+	}
+	addr := &net.UDPAddr{IP: s.dst, Port: sipPort}
+	port := conn.LocalAddr().(*net.UDPAddr).Port
+	if _, err := conn.WriteTo(s.sipOptions("udp", port), addr); err != nil {
+		return nil, err
+	}
+	buf := make([]byte, 65535)
+	for {
+		n, from, err := conn.ReadFrom(buf)
+		if err != nil {
+			return nil, err
+		}
+		if ip, ok := from.(*net.UDPAddr); ok && ip.IP.Equal(s.dst) {
+			return buf[:n], nil
+		}
+	}
+}
+
+// sipTCP sends an OPTIONS request over TCP and returns the reply headers.
+func (s *scanner) sipTCP(deadline time.Time) ([]byte, error) {
+	conn, err := net.DialTCP("tcp", nil, &net.TCPAddr{IP: s.dst, Port: sipPort})
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	if err := conn.SetDeadline(deadline); err != nil {
+		return nil, err
+	}
+	port := conn.LocalAddr().(*net.TCPAddr).Port
+	if _, err := conn.Write(s.sipOptions("tcp", port)); err != nil {
+		return nil, err
+	}
+	// Only the headers are of interest, up to the empty line.
+	r := bufio.NewReader(conn)
+	var resp []byte
+	for {
+		line, err := r.ReadBytes('\n')
+		if err != nil {
+			return nil, err
+		}
+		resp = append(resp, line...)
+		if len(bytes.TrimRight(line, "\r\n")) == 0 {
+			return resp, nil
+		}
+	}
+}
+
+// sipCompactHeaders maps the compact header forms of RFC 3261 to their full
+// names.
+var sipCompactHeaders = map[string]string{
+	"K": "Supported",
+}
+
+// parseSIPResponse parses the status line and headers of a SIP response.
+func parseSIPResponse(resp []byte) (*SIPInfo, error) {
+	tp := textproto.NewReader(bufio.NewReader(bytes.NewReader(resp)))
+	status, err := tp.ReadLine()
+	if err != nil {
+		return nil, err
+	}
+	proto, code, ok := strings.Cut(status, " ")
+	if !ok || proto != "SIP/2.0" {
+		return nil, fmt.Errorf("not a SIP response: %q", status)
+	}
+	code, _, _ = strings.Cut(code, " ")
+
+	info := &SIPInfo{}
+	if info.StatusCode, err = strconv.Atoi(code); err != nil {
+		return nil, fmt.Errorf("invalid SIP status line: %q", status)
+	}
+	header, err := tp.ReadMIMEHeader()
+	if err != nil && len(header) == 0 {
+		return nil, err
+	}
+	for name, full := range sipCompactHeaders {
+		if v, ok := header[name]; ok {
+			header[full] = append(header[full], v...)
+		}
+	}
+
+	info.Allow = sipList(header.Values("Allow"))
+	info.Supported = sipList(header.Values("Supported"))
+	info.Server = header.Get("Server")
+	info.UserAgent = header.Get("User-Agent")
+	info.Product = sipProduct(info.Server + " " + info.UserAgent)
+	return info, nil
+}
+
+// sipList splits comma separated header values.
+func sipList(values []string) []string {
+	var list []string
+	for _, v := range values {
+		for _, item := range strings.Split(v, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				list = append(list, item)
+			}
+		}
+	}
+	return list
+}
+
+// sipProduct identifies the SIP server from its Server or User-Agent.
+func sipProduct(agent string) string {
+	agent = strings.ToLower(agent)
+	for _, p := range sipProducts {
+		if strings.Contains(agent, p.match) {
+			return p.name
+		}
+	}
+	return ""
+}
@@ -0,0 +1,352 @@
+package scanme
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"time"
+	"unicode/utf16"
+)
+
+// smbPort is the port of SMB over TCP.
+const smbPort = "445"
+
+// smbTimeout bounds the SMB exchanges when ctx has no deadline.
+const smbTimeout = 5 * time.Second
+
+// SMBInfo describes the SMB server of the target.
+type SMBInfo struct {
+	// Dialect is the highest dialect supported: "SMBv1", "SMBv2.0.2",
+	// "SMBv2.1", "SMBv3.0" or "SMBv3.1.1".
+	Dialect string
+	// SMBv1 is set when the server still accepts SMBv1.
+	SMBv1 bool
+	// Signing is set when the server requires signed messages.
+	Signing    bool
+	Hostname   string
+	DomainName string
+	// OSVersion is the Windows version reported in the NTLM challenge, such
+	// as "10.0.17763".
+	OSVersion string
+	// Warning flags critical findings, i.e. SMBv1 support.
+	Warning string
+}
+
+// SMBProbe negotiates with the SMB server on port 445 of the target to find
+// the dialects it supports and whether it requires signing, then starts an
+// anonymous NTLM authentication to learn its name, domain and OS version.
+// SMBv1 is obsolete and exposed to wormable vulnerabilities such as
+// EternalBlue, so its support is flagged in SMBInfo.Warning.
+func (s *scanner) SMBProbe(ctx context.Context) (*SMBInfo, error) {
+	info := &SMBInfo{}
+
+	v1, v1Err := s.smbNegotiateV1(ctx)
+	if v1 {
+		info.SMBv1 = true
+		info.Dialect = "SMBv1"
+		info.Warning = "CRITICAL: SMBv1 is enabled"
+	}
+
+	conn, err := s.smbDial(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	dialect, signing, err := smbNegotiateV2(conn)
+	if err != nil {
+		if v1 {
+			return info, nil
+		}
+		if v1Err != nil {
+			return nil, v1Err
+		}
+		return nil, err
+	}
+	info.Dialect = dialect
+	info.Signing = signing
+
+	// The NTLM challenge is best effort: some servers refuse anonymous
+	// session setups.
+	if challenge, err := smbSessionSetup(conn); err == nil {
+		parseNTLMChallenge(challenge, info)
+	}
+	return info, nil
+}
+
+// smbDial connects to port 445 of the target, with a deadline from ctx.
+func (s *scanner) smbDial(ctx context.Context) (net.Conn, error) {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(smbTimeout)
+	}
+	d := net.Dialer{Deadline: deadline}
+	conn, err := d.DialContext(ctx, "tcp", net.JoinHostPort(s.dst.String(), smbPort))
+	if err != nil {
+		return nil, err
+	}
+	if err := conn.SetDeadline(deadline); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return conn, nil
+}
+
+// smbNegotiateV1 reports whether the server accepts the SMBv1 "NT LM 0.12"
+// dialect.
+func (s *scanner) smbNegotiateV1(ctx context.Context) (bool, error) {
+	conn, err := s.smbDial(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close()
+
+	header := []byte{
+		0xff, 'S', 'M', 'B',
+		0x72,                   // SMB_COM_NEGOTIATE
+		0x00, 0x00, 0x00, 0x00, // status
+		0x18,       // flags: canonicalized paths, case insensitive
+		0x01, 0xc8, // flags2: long names, NT status, unicode, extended security
+	}
+	header = append(header, make([]byte, 12)...) // PID high, security features, reserved
+	header = append(header, 0x00, 0x00, 0xff, 0xfe, 0x00, 0x00, 0x00, 0x00)
+	dialects := append([]byte{0x02}, "NT LM 0.12\x00"...)
+	msg := append(header, 0x00) // word count
+	msg = binary.LittleEndian.AppendUint16(msg, uint16(len(dialects)))
+	msg = append(msg, dialects...)
+
+	resp, err := smbRoundTrip(conn, msg)
+	if err != nil {
+		return false, err
+	}
+	// An SMBv1 reply selecting the dialect at index 0.
+	if len(resp) < 35 || !bytes.HasPrefix(resp, []byte{0xff, 'S', 'M', 'B'}) || resp[32] == 0 {
+		return false, nil
+	}
+	return binary.LittleEndian.Uint16(resp[33:]) == 0, nil
+}
+
+// smbHeaderV2 returns an SMB2 header for command with the given message ID.
+func smbHeaderV2(command uint16, messageID uint64) []byte {
+	h := []byte{0xfe, 'S', 'M', 'B'}
+	h = binary.LittleEndian.AppendUint16(h, 64) // structure size
+	h = binary.LittleEndian.AppendUint16(h, 0)  // credit charge
+	h = binary.LittleEndian.AppendUint32(h, 0)  // status
+	h = binary.LittleEndian.AppendUint16(h, command)
+	h = binary.LittleEndian.AppendUint16(h, 1) // credits requested
+	h = binary.LittleEndian.AppendUint32(h, 0) // flags
+	h = binary.LittleEndian.AppendUint32(h, 0) // next command
+	h = binary.LittleEndian.AppendUint64(h, messageID)
+	h = append(h, make([]byte, 4+4+8+16)...) // process ID, tree ID, session ID, signature
+	return h
+}
+
+// smbDialects maps the SMB2 dialect revisions offered to their names.
+var smbDialects = []struct {
+	revision uint16
+	name     string
+}{
+	{0x0202, "SMBv2.0.2"},
+	{0x0210, "SMBv2.1"},
+	{0x0300, "SMBv3.0"},
+	{0x0302, "SMBv3.0"},
+	{0x0311, "SMBv3.1.1"},
+}
+
+// smbNegotiateV2 negotiates the highest SMB2/3 dialect with the server and
+// reports whether it requires signing.
+func smbNegotiateV2(conn net.Conn) (string, bool, error) {
+	msg := smbHeaderV2(0x0000, 0)
+	body := binary.LittleEndian.AppendUint16(nil, 36) // structure size
+	body = binary.LittleEndian.AppendUint16(body, uint16(len(smbDialects)))
+	body = binary.LittleEndian.AppendUint16(body, 0x0001) // signing enabled
+	body = binary.LittleEndian.AppendUint16(body, 0)      // reserved
+	body = binary.LittleEndian.AppendUint32(body, 0)      // capabilities
+	body = append(body, "scanme-client-id"...)            // client GUID
+	contextOffset := len(msg) + len(body) + 8 + 2*len(smbDialects)
+	contextOffset += (8 - contextOffset%8) % 8
+	body = binary.LittleEndian.AppendUint32(body, uint32(contextOffset))
+	body = binary.LittleEndian.AppendUint16(body, 1) // negotiate context count
+	body = binary.LittleEndian.AppendUint16(body, 0) // reserved
+	for _, d := range smbDialects {
+		body = binary.LittleEndian.AppendUint16(body, d.revision)
+	}
+	msg = append(msg, body...)
+	msg = append(msg, make([]byte, contextOffset-len(msg))...)
+
+	// SMB 3.1.1 requires a pre-authentication integrity context.
+	preauth := binary.LittleEndian.AppendUint16(nil, 1)         // hash algorithm count
+	preauth = binary.LittleEndian.AppendUint16(preauth, 32)     // salt length
+	preauth = binary.LittleEndian.AppendUint16(preauth, 0x0001) // SHA-512
+	preauth = append(preauth, make([]byte, 32)...)
+	msg = binary.LittleEndian.AppendUint16(msg, 0x0001)
+	msg = binary.LittleEndian.AppendUint16(msg, uint16(len(preauth)))
+	msg = binary.LittleEndian.AppendUint32(msg, 0)
+	msg = append(msg, preauth...)
+
+	resp, err := smbRoundTrip(conn, msg)
+	if err != nil {
+		return "", false, err
+	}
+	if len(resp) < 64+6 || !bytes.HasPrefix(resp, []byte{0xfe, 'S', 'M', 'B'}) {
+		return "", false, errors.New("smb: not an SMB2 negotiate response")
+	}
+	if status := binary.LittleEndian.Uint32(resp[8:]); status != 0 {
+		return "", false, fmt.Errorf("smb: negotiate failed with status 0x%08x", status)
+	}
+	securityMode := binary.LittleEndian.Uint16(resp[64+2:])
+	revision := binary.LittleEndian.Uint16(resp[64+4:])
+	for _, d := range smbDialects {
+		if d.revision == revision {
+			return d.name, securityMode&0x0002 != 0, nil
+		}
+	}
+	return "", false, fmt.Errorf("smb: unknown dialect 0x%04x", revision)
+}
+
+// smbSessionSetup sends an SMB2 session setup carrying an NTLMSSP NEGOTIATE
+// message and returns the NTLMSSP CHALLENGE message of the reply.
+func smbSessionSetup(conn net.Conn) ([]byte, error) {
+	token := spnegoInit(ntlmNegotiate())
+
+	msg := smbHeaderV2(0x0001, 1)
+	msg = binary.LittleEndian.AppendUint16(msg, 25) // structure size
+	msg = append(msg, 0x00, 0x01)                   // flags, signing enabled
+	msg = binary.LittleEndian.AppendUint32(msg, 0)  // capabilities
+	msg = binary.LittleEndian.AppendUint32(msg, 0)  // channel
+	msg = binary.LittleEndian.AppendUint16(msg, 64+24)
+	msg = binary.LittleEndian.AppendUint16(msg, uint16(len(token)))
+	msg = binary.LittleEndian.AppendUint64(msg, 0) // previous session ID
+	msg = append(msg, token...)
+
+	resp, err := smbRoundTrip(conn, msg)
+	if err != nil {
+		return nil, err
+	}
+	i := bytes.Index(resp, []byte("NTLMSSP\x00\x02\x00\x00\x00"))
+	if i < 0 {
+		return nil, errors.New("smb: no NTLM challenge in session setup response")
+	}
+	return resp[i:], nil
+}
+
+// ntlmNegotiate returns an NTLMSSP NEGOTIATE message asking for the version
+// of the server in its challenge.
+func ntlmNegotiate() []byte {
+	negotiate := []byte("NTLMSSP\x00")
+	negotiate = binary.LittleEndian.AppendUint32(negotiate, 1)          // NEGOTIATE
+	negotiate = binary.LittleEndian.AppendUint32(negotiate, 0xe2088297) // flags, including version
+	negotiate = append(negotiate, make([]byte, 16)...)                  // domain and workstation fields
+	return append(negotiate, 0x06, 0x01, 0xb1, 0x1d, 0x00, 0x00, 0x00, 0x0f)
+}
+
+// spnegoInit wraps an NTLMSSP token in a SPNEGO NegTokenInit.
+func spnegoInit(token []byte) []byte {
+	ntlmOID := []byte{0x06, 0x0a, 0x2b, 0x06, 0x01, 0x04, 0x01, 0x82, 0x37, 0x02, 0x02, 0x0a}
+	mechTypes := derTLV(0xa0, derTLV(0x30, ntlmOID))
+	mechToken := derTLV(0xa2, derTLV(0x04, token))
+	negTokenInit := derTLV(0xa0, derTLV(0x30, append(mechTypes, mechToken...)))
+	spnegoOID := []byte{0x06, 0x06, 0x2b, 0x06, 0x01, 0x05, 0x05, 0x02}
+	return derTLV(0x60, append(spnegoOID, negTokenInit...))
+}
+
+// derTLV encodes a DER tag, length and value.
+func derTLV(tag byte, value []byte) []byte {
+	out := []byte{tag}
+	switch n := len(value); {
+	case n < 0x80:
+		out = append(out, byte(n))
+	case n < 0x100:
+		out = append(out, 0x81, byte(n))
+	default:
+		out = append(out, 0x82, byte(n>>8), byte(n))
+	}
+	return append(out, value...)
+}
+
+// parseNTLMChallenge fills in the name, domain and OS version of info from
+// the target info and version fields of an NTLMSSP CHALLENGE message.
+func parseNTLMChallenge(msg []byte, info *SMBInfo) {
+	if len(msg) < 56 {
+		return
+	}
+	info.OSVersion = ntlmVersion(msg)
+
+	length := int(binary.LittleEndian.Uint16(msg[40:]))
+	offset := int(binary.LittleEndian.Uint32(msg[44:]))
+	if offset+length > len(msg) {
+		return
+	}
+	var nbName, nbDomain, dnsName, dnsDomain string
+	for av := msg[offset : offset+length]; len(av) >= 4; {
+		id := binary.LittleEndian.Uint16(av)
+		n := int(binary.LittleEndian.Uint16(av[2:]))
+		if id == 0 || len(av) < 4+n {
+			break
+		}
+		value := decodeUTF16LE(av[4 : 4+n])
+		switch id {
+		case 1:
+			nbName = value
+		case 2:
+			nbDomain = value
+		case 3:
+			dnsName = value
+		case 4:
+			dnsDomain = value
+		}
+		av = av[4+n:]
+	}
+	info.Hostname, info.DomainName = nbName, nbDomain
+	if dnsName != "" {
+		info.Hostname = dnsName
+	}
+	if dnsDomain != "" {
+		info.DomainName = dnsDomain
+	}
+}
+
+// ntlmVersion returns the Windows version of the version field of an
+// NTLMSSP CHALLENGE message, empty when the server sent none.
+func ntlmVersion(msg []byte) string {
+	if len(msg) < 56 {
+		return ""
+	}
+	flags := binary.LittleEndian.Uint32(msg[20:])
+	if flags&0x02000000 == 0 { // NTLMSSP_NEGOTIATE_VERSION
+		return ""
+	}
+	build := binary.LittleEndian.Uint16(msg[50:])
+	return fmt.Sprintf("%d.%d.%d", msg[48], msg[49], build)
+}
+
+func decodeUTF16LE(b []byte) string {
+	u := make([]uint16, len(b)/2)
+	for i := range u {
+		u[i] = binary.LittleEndian.Uint16(b[2*i:])
+	}
+	return string(utf16.Decode(u))
+}
+
+// smbRoundTrip sends msg with its NetBIOS session header and returns the
+// reply, without its header.
+func smbRoundTrip(conn net.Conn, msg []byte) ([]byte, error) {
+	frame := binary.BigEndian.AppendUint32(nil, uint32(len(msg)))
+	if _, err := conn.Write(append(frame, msg...)); err != nil {
+		return nil, err
+	}
+	var hdr [4]byte
+	if _, err := io.ReadFull(conn, hdr[:]); err != nil {
+		return nil, err
+	}
+	n := binary.BigEndian.Uint32(hdr[:]) & 0x00ffffff
+	resp := make([]byte, n)
+	if _, err := io.ReadFull(conn, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
@@ -0,0 +1,248 @@
+package scanme
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+)
+
+// snmpPort is the port of SNMP agents.
+const snmpPort = "161"
+
+// snmpTimeout is how long SNMPProbe waits for replies when ctx has no
+// deadline. Agents silently drop requests with a wrong community, so every
+// probe waits this long.
+const snmpTimeout = 3 * time.Second
+
+// ErrNoSNMPCommunity is returned by SNMPProbe when the target accepted none
+// of the communities.
+var ErrNoSNMPCommunity = errors.New("snmp: no community accepted")
+
+// DefaultSNMPCommunities are the communities SNMP agents commonly ship with.
+var DefaultSNMPCommunities = []string{"public", "private", "community", "manager", "cisco"}
+
+// snmpOIDs are sysDescr.0, sysName.0 and sysLocation.0 of the SNMPv2-MIB.
+var snmpOIDs = [][]byte{
+	{0x2b, 6, 1, 2, 1, 1, 1, 0},
+	{0x2b, 6, 1, 2, 1, 1, 5, 0},
+	{0x2b, 6, 1, 2, 1, 1, 6, 0},
+}
+
+// SNMPResult describes the SNMP agent of the target.
+type SNMPResult struct {
+	// Community is the first community accepted, Communities all of them.
+	Community   string
+	Communities []string
+	SysDescr    string
+	SysName     string
+	SysLocation string
+}
+
+// ServiceHint returns the classification of the agent for
+// PortResult.ServiceHint.
+func (r *SNMPResult) ServiceHint() string {
+	return fmt.Sprintf("SNMP-open (community %q)", r.Community)
+}
+
+// SNMPProbe sends SNMPv2c GET requests for sysDescr, sysName and
+// sysLocation to UDP port 161 of the target, one per community, or
+// DefaultSNMPCommunities when none is given. The system description comes
+// from the reply to the first community in the list that the agent accepted.
+// Agents answering to default communities are a common misconfiguration,
+// which can be recorded in a ScanResult with
+//
+//	result.SetServiceHint(161, snmp.ServiceHint())
+func (s *scanner) SNMPProbe(ctx context.Context, communities []string) (*SNMPResult, error) {
+	if len(communities) == 0 {
+		communities = DefaultSNMPCommunities
+	}
+	conn, err := net.ListenPacket("udp4", "")
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(snmpTimeout)
+	}
+	if err := conn.SetDeadline(deadline); err != nil {
+		return nil, err
+	}
+	addr, err := net.ResolveUDPAddr("udp4", net.JoinHostPort(s.dst.String(), snmpPort))
+	if err != nil {
+		return nil, err
+	}
+
+	// Requests are sent at once and told apart by their request ID, the
+	// index of their community.
+	for i, community := range communities {
+		if _, err := conn.WriteTo(snmpGetRequest(community, i+1), addr); err != nil {
+			return nil, err
+		}
+	}
+
+	replies := make(map[int]*SNMPResult)
+	buf := make([]byte, 65535)
+	for len(replies) < len(communities) {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		n, from, err := conn.ReadFrom(buf)
+		if err != nil {
+			var netErr net.Error
+			if errors.As(err, &netErr) && netErr.Timeout() {
+				break
+			}
+			return nil, err
+		}
+		if ip, ok := from.(*net.UDPAddr); !ok || !ip.IP.Equal(s.dst) {
+			continue
+		}
+		id, r, err := parseSNMPResponse(buf[:n])
+		if err != nil || id < 1 || id > len(communities) {
+			continue
+		}
+		r.Community = communities[id-1]
+		replies[id] = r
+	}
+
+	var result *SNMPResult
+	for i, community := range communities {
+		r, ok := replies[i+1]
+		if !ok {
+			continue
+		}
+		if result == nil {
+			result = r
+		}
+		result.Communities = append(result.Communities, community)
+	}
+	if result == nil {
+		return nil, ErrNoSNMPCommunity
+	}
+	return result, nil
+}
+
+// snmpGetRequest encodes an SNMPv2c GetRequest for snmpOIDs.
+func snmpGetRequest(community string, requestID int) []byte {
+	var varbinds []byte
+	for _, oid := range snmpOIDs {
+		varbinds = append(varbinds, derTLV(0x30, append(derTLV(0x06, oid), 0x05, 0x00))...)
+	}
+	pdu := berInt(requestID)
+	pdu = append(pdu, berInt(0)...) // error status
+	pdu = append(pdu, berInt(0)...) // error index
+	pdu = append(pdu, derTLV(0x30, varbinds)...)
+
+	msg := berInt(1) // version: SNMPv2c
+	msg = append(msg, derTLV(0x04, []byte(community))...)
+	msg = append(msg, derTLV(0xa0, pdu)...)
+	return derTLV(0x30, msg)
+}
+
+// berInt encodes a non-negative BER INTEGER.
+func berInt(v int) []byte {
+	b := []byte{byte(v)}
+	for v >>= 8; v > 0; v >>= 8 {
+		b = append([]byte{byte(v)}, b...)
+	}
+	if b[0]&0x80 != 0 {
+		b = append([]byte{0}, b...)
+	}
+	return derTLV(0x02, b)
+}
+
+// berNext splits the first BER TLV off b, returning its tag, its value and
+// the rest of b.
+func berNext(b []byte) (byte, []byte, []byte, error) {
+	if len(b) < 2 {
+		return 0, nil, nil, errors.New("snmp: truncated message")
+	}
+	tag, n, b := b[0], int(b[1]), b[2:]
+	if n&0x80 != 0 {
+		size := n & 0x7f
+		if size == 0 || size > 3 || len(b) < size {
+			return 0, nil, nil, errors.New("snmp: invalid length")
+		}
+		n = 0
+		for _, c := range b[:size] {
+			n = n<<8 | int(c)
+		}
+		b = b[size:]
+	}
+	if len(b) < n {
+		return 0, nil, nil, errors.New("snmp: truncated message")
+	}
+	return tag, b[:n], b[n:], nil
+}
+
+// berUint decodes the value of a BER INTEGER.
+func berUint(b []byte) int {
+	n := 0
+	for _, c := range b {
+		n = n<<8 | int(c)
+	}
+	return n
+}
+
+// parseSNMPResponse decodes an SNMP GetResponse, returning its request ID
+// and the system description it carries.
+func parseSNMPResponse(msg []byte) (int, *SNMPResult, error) {
+	tag, msg, _, err := berNext(msg)
+	if err != nil || tag != 0x30 {
+		return 0, nil, errors.New("snmp: not an SNMP message")
+	}
+	var value []byte
+	for i := 0; i < 2; i++ { // version and community
+		if _, _, msg, err = berNext(msg); err != nil {
+			return 0, nil, err
+		}
+	}
+	if tag, value, _, err = berNext(msg); err != nil || tag != 0xa2 {
+		return 0, nil, errors.New("snmp: not a GetResponse")
+	}
+	pdu := value
+
+	if _, value, pdu, err = berNext(pdu); err != nil {
+		return 0, nil, err
+	}
+	id := berUint(value)
+	if _, value, pdu, err = berNext(pdu); err != nil {
+		return 0, nil, err
+	}
+	if status := berUint(value); status != 0 {
+		return 0, nil, fmt.Errorf("snmp: error status %d", status)
+	}
+	if _, _, pdu, err = berNext(pdu); err != nil { // error index
+		return 0, nil, err
+	}
+	if _, pdu, _, err = berNext(pdu); err != nil { // variable bindings
+		return 0, nil, err
+	}
+
+	r := &SNMPResult{}
+	for len(pdu) > 0 {
+		var varbind, oid []byte
+		if _, varbind, pdu, err = berNext(pdu); err != nil {
+			return 0, nil, err
+		}
+		if _, oid, varbind, err = berNext(varbind); err != nil {
+			return 0, nil, err
+		}
+		if tag, value, _, err = berNext(varbind); err != nil || tag != 0x04 {
+			// noSuchObject and other exceptions carry no string.
+			continue
+		}
+		switch string(oid) {
+		case string(snmpOIDs[0]):
+			r.SysDescr = string(value)
+		case string(snmpOIDs[1]):
+			r.SysName = string(value)
+		case string(snmpOIDs[2]):
+			r.SysLocation = string(value)
+		}
+	}
+	return id, r, nil
+}
@@ -0,0 +1,45 @@
+package scanme
+
+import (
+	"context"
+	"net"
+	"time"
+
+	"golang.org/x/net/proxy"
+)
+
+// ProxyCreds are the username and password a SOCKS5 proxy is authenticated
+// with, see WithSOCKS5Proxy.
+type ProxyCreds struct {
+	User     string
+	Password string
+}
+
+// dialTCP connects to addr within timeout, through the SOCKS5 proxy set with
+// WithSOCKS5Proxy if any.
+func (s *scanner) dialTCP(addr string, timeout time.Duration) (net.Conn, error) {
+	if s.socks5Addr == "" {
+		return net.DialTimeout("tcp", addr, timeout)
+	}
+	var auth *proxy.Auth
+	if s.socks5Creds != nil {
+		auth = &proxy.Auth{User: s.socks5Creds.User, Password: s.socks5Creds.Password}
+	}
+	dialer, err := proxy.SOCKS5("tcp", s.socks5Addr, auth, &net.Dialer{Timeout: timeout})
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	return dialer.(proxy.ContextDialer).DialContext(ctx, "tcp", addr)
+}
+
+// checkRawPackets returns an ErrProxyNotSupported when s goes through a
+// SOCKS5 proxy: scans crafting their own packets cannot, a proxy only
+// relays TCP connections.
+func (s *scanner) checkRawPackets() error {
+	if s.socks5Addr != "" {
+		return &ErrProxyNotSupported{Proxy: s.socks5Addr}
+	}
+	return nil
+}
@@ -0,0 +1,272 @@
+package scanme
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// socks5Server is a minimal SOCKS5 proxy, implementing the CONNECT command
+// of RFC 1928 and the username/password authentication of RFC 1929, run on
+// 127.0.0.1 for the tests to dial through.
+type socks5Server struct {
+	ln    net.Listener
+	creds *ProxyCreds // required when not nil
+
+	mu       sync.Mutex
+	requests []string // the CONNECT targets, as host:port
+}
+
+func newSOCKS5Server(t *testing.T, creds *ProxyCreds) *socks5Server {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := &socks5Server{ln: ln, creds: creds}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go p.serve(conn)
+		}
+	}()
+	return p
+}
+
+func (p *socks5Server) addr() string {
+	return p.ln.Addr().String()
+}
+
+func (p *socks5Server) targets() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]string(nil), p.requests...)
+}
+
+func (p *socks5Server) serve(conn net.Conn) {
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	// Greeting: VER NMETHODS METHODS...
+	hdr := make([]byte, 2)
+	if _, err := io.ReadFull(conn, hdr); err != nil || hdr[0] != 5 {
+		return
+	}
+	methods := make([]byte, hdr[1])
+	if _, err := io.ReadFull(conn, methods); err != nil {
+		return
+	}
+	want := byte(0x00) // no authentication
+	if p.creds != nil {
+		want = 0x02 // username/password
+	}
+	offered := false
+	for _, m := range methods {
+		offered = offered || m == want
+	}
+	if !offered {
+		conn.Write([]byte{5, 0xff})
+		return
+	}
+	conn.Write([]byte{5, want})
+
+	if p.creds != nil {
+		// VER ULEN UNAME PLEN PASSWD
+		if _, err := io.ReadFull(conn, hdr); err != nil || hdr[0] != 1 {
+			return
+		}
+		user := make([]byte, hdr[1])
+		if _, err := io.ReadFull(conn, user); err != nil {
+			return
+		}
+		if _, err := io.ReadFull(conn, hdr[:1]); err != nil {
+			return
+		}
+		password := make([]byte, hdr[0])
+		if _, err := io.ReadFull(conn, password); err != nil {
+			return
+		}
+		if string(user) != p.creds.User || string(password) != p.creds.Password {
+			conn.Write([]byte{1, 1})
+			return
+		}
+		conn.Write([]byte{1, 0})
+	}
+
+	// Request: VER CMD RSV ATYP DST.ADDR DST.PORT
+	req := make([]byte, 4)
+	if _, err := io.ReadFull(conn, req); err != nil || req[0] != 5 {
+		return
+	}
+	var host string
+	switch req[3] {
+	case 1, 4:
+		ip := make(net.IP, 4)
+		if req[3] == 4 {
+			ip = make(net.IP, 16)
+		}
+		if _, err := io.ReadFull(conn, ip); err != nil {
+			return
+		}
+		host = ip.String()
+	case 3:
+		if _, err := io.ReadFull(conn, hdr[:1]); err != nil {
+			return
+		}
+		name := make([]byte, hdr[0])
+		if _, err := io.ReadFull(conn, name); err != nil {
+			return
+		}
+		host = string(name)
+	default:
+		conn.Write([]byte{5, 8, 0, 1, 0, 0, 0, 0, 0, 0}) // address type not supported
+		return
+	}
+	if _, err := io.ReadFull(conn, hdr); err != nil {
+		return
+	}
+	target := net.JoinHostPort(host, strconv.Itoa(int(binary.BigEndian.Uint16(hdr))))
+	p.mu.Lock()
+	p.requests = append(p.requests, target)
+	p.mu.Unlock()
+	if req[1] != 1 {
+		conn.Write([]byte{5, 7, 0, 1, 0, 0, 0, 0, 0, 0}) // command not supported
+		return
+	}
+
+	upstream, err := net.DialTimeout("tcp", target, time.Second)
+	if err != nil {
+		conn.Write([]byte{5, 5, 0, 1, 0, 0, 0, 0, 0, 0}) // connection refused
+		return
+	}
+	defer upstream.Close()
+	conn.Write([]byte{5, 0, 0, 1, 0, 0, 0, 0, 0, 0})
+	conn.SetDeadline(time.Time{})
+	go io.Copy(upstream, conn)
+	io.Copy(conn, upstream)
+}
+
+// bannerListener returns the address of a TCP listener on 127.0.0.1 sending
+// banner to each connection.
+func bannerListener(t *testing.T, banner string) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			conn.Write([]byte(banner))
+			conn.Close()
+		}
+	}()
+	return ln.Addr().String()
+}
+
+// closedPort returns the address of a port on 127.0.0.1 nothing listens on.
+func closedPort(t *testing.T) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+	return addr
+}
+
+// ConnScan always sweeps the 65535 ports, so the tests dial single ports
+// with dialTCP, through which ConnScan makes all its connections.
+func TestSOCKS5Dial(t *testing.T) {
+	p := newSOCKS5Server(t, nil)
+	target := bannerListener(t, "SSH-2.0-test\r\n")
+	host, port, _ := net.SplitHostPort(target)
+	s := newTestScanner(WithSOCKS5Proxy(p.addr(), nil))
+
+	// The form ConnScan dials.
+	conn, err := s.dialTCP("["+host+"]:"+port, time.Second)
+	if err != nil {
+		t.Fatalf("dialTCP(%s) through the proxy: %v", target, err)
+	}
+	defer conn.Close()
+	if got, err := readBanner(conn, time.Second); err != nil || got != "SSH-2.0-test\r\n" {
+		t.Errorf("readBanner() = %q, %v, want SSH-2.0-test\\r\\n", got, err)
+	}
+	if got := p.targets(); len(got) != 1 || got[0] != target {
+		t.Errorf("proxy was asked to connect to %v, want [%s]", got, target)
+	}
+
+	closed := closedPort(t)
+	if conn, err := s.dialTCP(closed, time.Second); err == nil {
+		conn.Close()
+		t.Errorf("dialTCP(%s) of a closed port through the proxy: error = nil", closed)
+	}
+}
+
+func TestSOCKS5DialWithCreds(t *testing.T) {
+	creds := &ProxyCreds{User: "scanme", Password: "s3cret"}
+	p := newSOCKS5Server(t, creds)
+	target := bannerListener(t, "220 ready\r\n")
+
+	s := newTestScanner(WithSOCKS5Proxy(p.addr(), &ProxyCreds{User: "scanme", Password: "s3cret"}))
+	conn, err := s.dialTCP(target, time.Second)
+	if err != nil {
+		t.Fatalf("dialTCP() with the right credentials: %v", err)
+	}
+	defer conn.Close()
+	if got, err := readBanner(conn, time.Second); err != nil || got != "220 ready\r\n" {
+		t.Errorf("readBanner() = %q, %v, want 220 ready\\r\\n", got, err)
+	}
+
+	for _, c := range []*ProxyCreds{nil, {User: "scanme", Password: "wrong"}} {
+		s := newTestScanner(WithSOCKS5Proxy(p.addr(), c))
+		if conn, err := s.dialTCP(target, time.Second); err == nil {
+			conn.Close()
+			t.Errorf("dialTCP() with credentials %+v: error = nil", c)
+		}
+	}
+	if got := p.targets(); len(got) != 1 {
+		t.Errorf("proxy connected to %v, want only the authenticated dial", got)
+	}
+}
+
+func TestSOCKS5ProxyDown(t *testing.T) {
+	s := newTestScanner(WithSOCKS5Proxy(closedPort(t), nil))
+	target := bannerListener(t, "banner")
+	if conn, err := s.dialTCP(target, time.Second); err == nil {
+		conn.Close()
+		t.Error("dialTCP() through a proxy that is down: error = nil, want no direct connection")
+	}
+}
+
+func TestSOCKS5RawPacketScans(t *testing.T) {
+	p := newSOCKS5Server(t, nil)
+	s := newTestScanner(WithSOCKS5Proxy(p.addr(), nil))
+
+	_, err := s.Synscan()
+	var perr *ErrProxyNotSupported
+	if !errors.As(err, &perr) || perr.Proxy != p.addr() {
+		t.Errorf("Synscan() error = %v, want ErrProxyNotSupported for %s", err, p.addr())
+	}
+	if _, err := s.sendARPRequest(context.Background()); !errors.Is(err, &ErrProxyNotSupported{}) {
+		t.Errorf("sendARPRequest() error = %v, want ErrProxyNotSupported", err)
+	}
+	if got := p.targets(); len(got) != 0 {
+		t.Errorf("raw packet scans went to the proxy: %v", got)
+	}
+}
@@ -0,0 +1,62 @@
+package scanme
+
+import "time"
+
+// ScanSpeed is a timing template bundling the rate limit, settle time, ARP
+// retries and jitter of a scan, like nmap's -T0 to -T5.
+type ScanSpeed int
+
+const (
+	// SpeedParanoid sends a probe every 5 minutes, to stay under the radar
+	// of intrusion detection systems. A full scan takes months.
+	SpeedParanoid ScanSpeed = iota
+	// SpeedSneaky sends a probe every 15 seconds.
+	SpeedSneaky
+	// SpeedPolite sends a probe every 400 milliseconds, to spare the
+	// bandwidth and resources of the target.
+	SpeedPolite
+	// SpeedNormal sends up to 1000 probes per second.
+	SpeedNormal
+	// SpeedAggressive sends up to 5000 probes per second and assumes a
+	// fast, reliable network.
+	SpeedAggressive
+	// SpeedInsane sends up to 10000 probes per second and waits only 50
+	// milliseconds for late replies, at the cost of accuracy.
+	SpeedInsane
+)
+
+// speedPreset holds the settings applied by WithSpeed.
+type speedPreset struct {
+	interval   time.Duration
+	settle     time.Duration
+	arpRetries int
+	jitter     float64
+}
+
+var speedPresets = map[ScanSpeed]speedPreset{
+	SpeedParanoid:   {interval: 5 * time.Minute, settle: 5 * time.Minute, arpRetries: 10, jitter: 0.5},
+	SpeedSneaky:     {interval: 15 * time.Second, settle: time.Minute, arpRetries: 10, jitter: 0.5},
+	SpeedPolite:     {interval: 400 * time.Millisecond, settle: 10 * time.Second, arpRetries: 5, jitter: 0.2},
+	SpeedNormal:     {interval: time.Millisecond, settle: defaultSettle, arpRetries: defaultARPRetries},
+	SpeedAggressive: {interval: 200 * time.Microsecond, settle: 500 * time.Millisecond, arpRetries: 2},
+	SpeedInsane:     {interval: 100 * time.Microsecond, settle: 50 * time.Millisecond, arpRetries: 1},
+}
+
+// String returns the name of the template.
+func (s ScanSpeed) String() string {
+	switch s {
+	case SpeedParanoid:
+		return "paranoid"
+	case SpeedSneaky:
+		return "sneaky"
+	case SpeedPolite:
+		return "polite"
+	case SpeedNormal:
+		return "normal"
+	case SpeedAggressive:
+		return "aggressive"
+	case SpeedInsane:
+		return "insane"
+	}
+	return "unknown"
+}
@@ -0,0 +1,248 @@
+package scanme
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// sshTimeout bounds SSHProbe when ctx has no deadline.
+const sshTimeout = 5 * time.Second
+
+// sshMsgKexInit is the message number of SSH_MSG_KEXINIT.
+const sshMsgKexInit = 20
+
+// sshMaxPacket bounds the packets SSHProbe reads, RFC 4253 requires
+// implementations to handle 35000 bytes.
+const sshMaxPacket = 35000
+
+// ErrNotSSH is returned by SSHProbe when the service on port 22 does not
+// speak SSH 2.
+var ErrNotSSH = errors.New("not an SSH service")
+
+// weakSSHAlgorithms are the algorithms considered broken or too weak,
+// following the deprecations of OpenSSH and RFC 9142.
+var weakSSHAlgorithms = map[string]bool{
+	// Ciphers.
+	"arcfour":                     true,
+	"arcfour128":                  true,
+	"arcfour256":                  true,
+	"3des-cbc":                    true,
+	"des-cbc":                     true,
+	"blowfish-cbc":                true,
+	"cast128-cbc":                 true,
+	"aes128-cbc":                  true,
+	"aes192-cbc":                  true,
+	"aes256-cbc":                  true,
+	"rijndael-cbc@lysator.liu.se": true,
+	"none":                        true,
+	// MACs.
+	"hmac-md5":                     true,
+	"hmac-md5-96":                  true,
+	"hmac-sha1-96":                 true,
+	"hmac-md5-etm@openssh.com":     true,
+	"hmac-md5-96-etm@openssh.com":  true,
+	"hmac-sha1-96-etm@openssh.com": true,
+	"umac-64@openssh.com":          true,
+	// Key exchanges.
+	"diffie-hellman-group1-sha1":         true,
+	"diffie-hellman-group-exchange-sha1": true,
+	// Host keys.
+	"ssh-dss": true,
+}
+
+// SSHInfo describes the SSH server of the target.
+type SSHInfo struct {
+	// Banner is the identification string of the server, such as
+	// "SSH-2.0-OpenSSH_8.9p1 Ubuntu-3ubuntu0.6".
+	Banner string
+	// ServerVersion is the software of the server from its banner, such as
+	// "OpenSSH_8.9p1".
+	ServerVersion string
+	// The algorithms of the KEXINIT message of the server, in its order of
+	// preference. Ciphers and MACs merge those offered in both directions.
+	KEXAlgorithms     []string
+	HostKeyAlgorithms []string
+	Ciphers           []string
+	MACs              []string
+	// WeakCiphers are the known-weak algorithms among those offered, such
+	// as arcfour or 3des-cbc, including weak MACs, key exchanges and host
+	// keys.
+	WeakCiphers []string
+}
+
+// ServiceHint returns the classification of the server for
+// PortResult.ServiceHint.
+func (i *SSHInfo) ServiceHint() string {
+	hint := "ssh"
+	if i.ServerVersion != "" {
+		hint += " (" + i.ServerVersion + ")"
+	}
+	if len(i.WeakCiphers) > 0 {
+		hint += " weak algorithms"
+	}
+	return hint
+}
+
+// SSHProbe connects to port 22 of the target, reads the banner of the SSH
+// server and the algorithms of its key exchange initialization, answering
+// with a KEXINIT of its own. The connection is closed before the key
+// exchange starts, nothing is authenticated.
+func (s *scanner) SSHProbe(ctx context.Context) (*SSHInfo, error) {
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, sshTimeout)
+		defer cancel()
+	}
+	conn, err := dialICS(ctx, s.dst, "22")
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+
+	// Servers may send other lines before their identification string.
+	info := &SSHInfo{}
+	for lines := 0; info.Banner == ""; lines++ {
+		line, err := r.ReadString('\n')
+		if err != nil || lines > 20 {
+			return nil, ErrNotSSH
+		}
+		line = strings.TrimRight(line, "\r\n")
+		if strings.HasPrefix(line, "SSH-") {
+			info.Banner = line
+		}
+	}
+	if !strings.HasPrefix(info.Banner, "SSH-2.0-") && !strings.HasPrefix(info.Banner, "SSH-1.99-") {
+		return info, fmt.Errorf("%w: %s", ErrNotSSH, info.Banner)
+	}
+	software := info.Banner[strings.Index(info.Banner[4:], "-")+5:]
+	info.ServerVersion, _, _ = strings.Cut(software, " ")
+
+	if _, err := conn.Write([]byte("SSH-2.0-scanme\r\n")); err != nil {
+		return info, err
+	}
+	if _, err := conn.Write(sshPacket(sshKexInit())); err != nil {
+		return info, err
+	}
+	payload, err := readSSHPacket(r)
+	if err != nil {
+		return info, err
+	}
+	if len(payload) < 17 || payload[0] != sshMsgKexInit {
+		return info, ErrNotSSH
+	}
+	lists, err := parseNameLists(payload[17:], 6)
+	if err != nil {
+		return info, err
+	}
+	info.KEXAlgorithms = lists[0]
+	info.HostKeyAlgorithms = lists[1]
+	info.Ciphers = mergeAlgorithms(lists[2], lists[3])
+	info.MACs = mergeAlgorithms(lists[4], lists[5])
+	for _, list := range [][]string{info.KEXAlgorithms, info.HostKeyAlgorithms, info.Ciphers, info.MACs} {
+		for _, a := range list {
+			if weakSSHAlgorithms[a] {
+				info.WeakCiphers = append(info.WeakCiphers, a)
+			}
+		}
+	}
+	return info, nil
+}
+
+// sshKexInit returns an SSH_MSG_KEXINIT payload offering common algorithms.
+func sshKexInit() []byte {
+	msg := []byte{sshMsgKexInit}
+	cookie := make([]byte, 16)
+	rand.Read(cookie)
+	msg = append(msg, cookie...)
+	for _, list := range []string{
+		"curve25519-sha256,ecdh-sha2-nistp256,diffie-hellman-group14-sha256",
+		"ssh-ed25519,ecdsa-sha2-nistp256,rsa-sha2-256,ssh-rsa",
+		"aes128-ctr,aes256-ctr", "aes128-ctr,aes256-ctr",
+		"hmac-sha2-256,hmac-sha1", "hmac-sha2-256,hmac-sha1",
+		"none", "none",
+		"", "",
+	} {
+		msg = binary.BigEndian.AppendUint32(msg, uint32(len(list)))
+		msg = append(msg, list...)
+	}
+	msg = append(msg, 0)                         // first_kex_packet_follows
+	return binary.BigEndian.AppendUint32(msg, 0) // reserved
+}
+
+// sshPacket wraps payload in an unencrypted SSH binary packet.
+func sshPacket(payload []byte) []byte {
+	padding := 8 - (5+len(payload))%8
+	if padding < 4 {
+		padding += 8
+	}
+	packet := binary.BigEndian.AppendUint32(nil, uint32(1+len(payload)+padding))
+	packet = append(packet, byte(padding))
+	packet = append(packet, payload...)
+	return append(packet, make([]byte, padding)...)
+}
+
+// readSSHPacket reads an unencrypted SSH binary packet from r and returns
+// its payload.
+func readSSHPacket(r io.Reader) ([]byte, error) {
+	var header [5]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return nil, err
+	}
+	n := binary.BigEndian.Uint32(header[:])
+	padding := uint32(header[4])
+	if n < 1+padding || n > sshMaxPacket {
+		return nil, ErrNotSSH
+	}
+	packet := make([]byte, n-1)
+	if _, err := io.ReadFull(r, packet); err != nil {
+		return nil, err
+	}
+	return packet[:n-1-padding], nil
+}
+
+// parseNameLists parses the first count name-lists of b.
+func parseNameLists(b []byte, count int) ([][]string, error) {
+	lists := make([][]string, 0, count)
+	for len(lists) < count {
+		if len(b) < 4 {
+			return nil, errors.New("ssh: truncated KEXINIT")
+		}
+		n := binary.BigEndian.Uint32(b)
+		if uint32(len(b)-4) < n {
+			return nil, errors.New("ssh: truncated KEXINIT")
+		}
+		var list []string
+		if n > 0 {
+			list = strings.Split(string(b[4:4+n]), ",")
+		}
+		lists = append(lists, list)
+		b = b[4+n:]
+	}
+	return lists, nil
+}
+
+// mergeAlgorithms returns a followed by the algorithms of b missing from a.
+func mergeAlgorithms(a, b []string) []string {
+	merged := append([]string(nil), a...)
+	for _, alg := range b {
+		found := false
+		for _, m := range merged {
+			if m == alg {
+				found = true
+				break
+			}
+		}
+		if !found {
+			merged = append(merged, alg)
+		}
+	}
+	return merged
+}
@@ -0,0 +1,157 @@
+package scanme
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"net"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// serverKexInit returns an SSH_MSG_KEXINIT payload offering the name-lists
+// given, the languages being left empty.
+func serverKexInit(lists ...string) []byte {
+	msg := append([]byte{sshMsgKexInit}, make([]byte, 16)...)
+	for _, list := range append(lists, "", "") {
+		msg = binary.BigEndian.AppendUint32(msg, uint32(len(list)))
+		msg = append(msg, list...)
+	}
+	return append(msg, 0, 0, 0, 0, 0)
+}
+
+// sshServer returns a handler sending banner, then kexinit once the client
+// sent its identification string and KEXINIT, which it sends on clients.
+func sshServer(banner string, kexinit []byte, clients chan<- []byte) func(net.Conn) {
+	return func(conn net.Conn) {
+		conn.Write([]byte(banner))
+		r := bufio.NewReader(conn)
+		ident, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		payload, err := readSSHPacket(r)
+		if err != nil {
+			return
+		}
+		clients <- append([]byte(ident), payload...)
+		conn.Write(sshPacket(kexinit))
+	}
+}
+
+func TestSSHProbe(t *testing.T) {
+	clients := make(chan []byte, 1)
+	testServer(t, "127.0.0.1:22", sshServer(
+		"Welcome to the lab\r\nSSH-2.0-OpenSSH_8.9p1 Ubuntu-3ubuntu0.6\r\n",
+		serverKexInit(
+			"curve25519-sha256,diffie-hellman-group1-sha1",
+			"ssh-ed25519,ssh-dss",
+			"aes128-ctr,3des-cbc", "aes128-ctr,arcfour",
+			"hmac-sha2-256", "hmac-sha2-256,hmac-md5",
+			"none", "none",
+		),
+		clients,
+	))
+	s := newTestScanner()
+	s.dst = net.IPv4(127, 0, 0, 1)
+	info, err := s.SSHProbe(context.Background())
+	if err != nil {
+		t.Fatalf("SSHProbe() error = %v", err)
+	}
+
+	if info.Banner != "SSH-2.0-OpenSSH_8.9p1 Ubuntu-3ubuntu0.6" || info.ServerVersion != "OpenSSH_8.9p1" {
+		t.Errorf("Banner, ServerVersion = %q, %q", info.Banner, info.ServerVersion)
+	}
+	for _, l := range []struct {
+		name      string
+		got, want []string
+	}{
+		{"KEXAlgorithms", info.KEXAlgorithms, []string{"curve25519-sha256", "diffie-hellman-group1-sha1"}},
+		{"HostKeyAlgorithms", info.HostKeyAlgorithms, []string{"ssh-ed25519", "ssh-dss"}},
+		{"Ciphers", info.Ciphers, []string{"aes128-ctr", "3des-cbc", "arcfour"}},
+		{"MACs", info.MACs, []string{"hmac-sha2-256", "hmac-md5"}},
+		{"WeakCiphers", info.WeakCiphers, []string{"diffie-hellman-group1-sha1", "ssh-dss", "3des-cbc", "arcfour", "hmac-md5"}},
+	} {
+		if !slices.Equal(l.got, l.want) {
+			t.Errorf("%s = %q, want %q", l.name, l.got, l.want)
+		}
+	}
+	if hint := info.ServiceHint(); hint != "ssh (OpenSSH_8.9p1) weak algorithms" {
+		t.Errorf("ServiceHint() = %q", hint)
+	}
+
+	client := <-clients
+	ident, kexinit, _ := bytes.Cut(client, []byte("\n"))
+	if string(ident) != "SSH-2.0-scanme\r" {
+		t.Errorf("client identification %q", ident)
+	}
+	if kexinit[0] != sshMsgKexInit {
+		t.Fatalf("client sent message %d, want KEXINIT", kexinit[0])
+	}
+	lists, err := parseNameLists(kexinit[17:], 10)
+	if err != nil {
+		t.Fatalf("client KEXINIT: %v", err)
+	}
+	if !slices.Contains(lists[0], "curve25519-sha256") || !slices.Contains(lists[2], "aes128-ctr") {
+		t.Errorf("client KEXINIT offers %q", lists)
+	}
+}
+
+func TestSSHProbeNotSSH(t *testing.T) {
+	for _, tt := range []struct {
+		banner     string
+		wantBanner string
+	}{
+		{"SSH-1.5-OpenSSH_2.9\r\n", "SSH-1.5-OpenSSH_2.9"},
+		{"HTTP/1.1 400 Bad Request\r\n\r\n", ""},
+	} {
+		t.Run(strings.Fields(tt.banner)[0], func(t *testing.T) {
+			testServer(t, "127.0.0.1:22", func(conn net.Conn) {
+				conn.Write([]byte(tt.banner))
+			})
+			s := newTestScanner()
+			s.dst = net.IPv4(127, 0, 0, 1)
+			info, err := s.SSHProbe(context.Background())
+			if !errors.Is(err, ErrNotSSH) {
+				t.Fatalf("SSHProbe() error = %v, want ErrNotSSH", err)
+			}
+			if tt.wantBanner != "" && (info == nil || info.Banner != tt.wantBanner) {
+				t.Errorf("SSHProbe() = %+v, want the banner %q", info, tt.wantBanner)
+			}
+		})
+	}
+}
+
+func TestSSHPacket(t *testing.T) {
+	for n := 0; n < 40; n++ {
+		payload := bytes.Repeat([]byte{0xab}, n)
+		packet := sshPacket(payload)
+		if len(packet)%8 != 0 || packet[4] < 4 {
+			t.Errorf("packet of %d bytes with %d bytes of padding, want a multiple of 8 and at least 4", len(packet), packet[4])
+		}
+		got, err := readSSHPacket(bytes.NewReader(packet))
+		if err != nil || !bytes.Equal(got, payload) {
+			t.Errorf("readSSHPacket(sshPacket(%d bytes)) = %x, %v", n, got, err)
+		}
+	}
+	if _, err := readSSHPacket(strings.NewReader("SSH-2.0-OpenSSH_9.6\r\n")); !errors.Is(err, ErrNotSSH) {
+		t.Errorf("readSSHPacket() of a banner: error = %v, want ErrNotSSH", err)
+	}
+}
+
+func TestParseNameLists(t *testing.T) {
+	kexinit := serverKexInit("a,b", "", "c", "c", "d", "d", "none", "none")
+	lists, err := parseNameLists(kexinit[17:], 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(lists) != 3 || !slices.Equal(lists[0], []string{"a", "b"}) || lists[1] != nil || !slices.Equal(lists[2], []string{"c"}) {
+		t.Errorf("parseNameLists() = %q", lists)
+	}
+	if _, err := parseNameLists(kexinit[17:24], 2); err == nil {
+		t.Error("parseNameLists() of a truncated KEXINIT: error = nil")
+	}
+}
@@ -0,0 +1,18 @@
+CREATE TABLE scans (
+	id               INTEGER PRIMARY KEY AUTOINCREMENT,
+	target           TEXT    NOT NULL,
+	start_time       INTEGER NOT NULL,
+	end_time         INTEGER NOT NULL,
+	packets_sent     INTEGER NOT NULL DEFAULT 0,
+	packets_received INTEGER NOT NULL DEFAULT 0
+);
+
+CREATE INDEX scans_target_start_time ON scans (target, start_time);
+
+CREATE TABLE ports (
+	scan_id INTEGER NOT NULL REFERENCES scans (id) ON DELETE CASCADE,
+	port    INTEGER NOT NULL,
+	state   TEXT    NOT NULL,
+	service TEXT    NOT NULL DEFAULT '',
+	PRIMARY KEY (scan_id, port)
+);
@@ -0,0 +1,229 @@
+// Package store persists scan results in a local SQLite database, so that
+// the history of a target can be queried and compared over time.
+package store
+
+import (
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"time"
+
+	"github.com/CyberRoute/scanme/scanme"
+	"github.com/google/gopacket/layers"
+	_ "modernc.org/sqlite" // registers the pure Go sqlite driver
+)
+
+// migrations holds the schema migrations, applied in file name order. The
+// number of migrations applied is tracked with the user_version pragma.
+//
+//go:embed migrations/*.sql
+var migrations embed.FS
+
+// ErrNoScan is returned by Diff when no scan of the target was found.
+var ErrNoScan = errors.New("store: no scan found")
+
+// Store is a SQLite backed history of scan results.
+type Store struct {
+	db *sql.DB
+}
+
+// Open opens, creating it if needed, the SQLite database at path and brings
+// its schema up to date.
+func Open(path string) (*Store, error) {
+	db, err := sql.Open("sqlite", path+"?_pragma=foreign_keys(1)")
+	if err != nil {
+		return nil, err
+	}
+	s := &Store{db: db}
+	if err := s.migrate(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// Close closes the database.
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+func (s *Store) migrate() error {
+	var current int
+	if err := s.db.QueryRow("PRAGMA user_version").Scan(&current); err != nil {
+		return err
+	}
+
+	files, err := migrations.ReadDir("migrations")
+	if err != nil {
+		return err
+	}
+	names := make([]string, 0, len(files))
+	for _, f := range files {
+		names = append(names, f.Name())
+	}
+	sort.Strings(names)
+
+	for i := current; i < len(names); i++ {
+		if err := s.applyMigration(names[i], i+1); err != nil {
+			return fmt.Errorf("store: applying migration %s: %w", names[i], err)
+		}
+	}
+	return nil
+}
+
+// applyMigration runs the migration script name and sets the schema version
+// to version, in a single transaction.
+func (s *Store) applyMigration(name string, version int) error {
+	script, err := migrations.ReadFile("migrations/" + name)
+	if err != nil {
+		return err
+	}
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback() //nolint:errcheck // no-op once committed
+
+	if _, err := tx.Exec(string(script)); err != nil {
+		return err
+	}
+	// PRAGMA does not accept bound parameters.
+	if _, err := tx.Exec(fmt.Sprintf("PRAGMA user_version = %d", version)); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Save stores result, with one row per port result.
+func (s *Store) Save(result *scanme.ScanResult) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback() //nolint:errcheck // no-op once committed
+
+	res, err := tx.Exec(
+		"INSERT INTO scans (target, start_time, end_time, packets_sent, packets_received) VALUES (?, ?, ?, ?, ?)",
+		result.Target.String(), result.StartTime.UnixNano(), result.EndTime.UnixNano(),
+		result.Stats.PacketsSent, result.Stats.PacketsReceived,
+	)
+	if err != nil {
+		return err
+	}
+	scanID, err := res.LastInsertId()
+	if err != nil {
+		return err
+	}
+
+	stmt, err := tx.Prepare("INSERT INTO ports (scan_id, port, state, service) VALUES (?, ?, ?, ?)")
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	for _, p := range result.Ports {
+		if _, err := stmt.Exec(scanID, int(p.Port), p.State, p.Service); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// Query returns the scans of target started at or after since, oldest first.
+func (s *Store) Query(target net.IP, since time.Time) ([]*scanme.ScanResult, error) {
+	return s.scans(
+		"SELECT id, target, start_time, end_time, packets_sent, packets_received FROM scans WHERE target = ? AND start_time >= ? ORDER BY start_time",
+		target.String(), since.UnixNano(),
+	)
+}
+
+// Diff compares the scans of target that were the latest at times a and b.
+func (s *Store) Diff(target net.IP, a, b time.Time) (*scanme.ScanDiff, error) {
+	before, err := s.latest(target, a)
+	if err != nil {
+		return nil, err
+	}
+	after, err := s.latest(target, b)
+	if err != nil {
+		return nil, err
+	}
+	return scanme.Diff(before, after), nil
+}
+
+// latest returns the most recent scan of target started at or before t.
+func (s *Store) latest(target net.IP, t time.Time) (*scanme.ScanResult, error) {
+	results, err := s.scans(
+		"SELECT id, target, start_time, end_time, packets_sent, packets_received FROM scans WHERE target = ? AND start_time <= ? ORDER BY start_time DESC LIMIT 1",
+		target.String(), t.UnixNano(),
+	)
+	if err != nil {
+		return nil, err
+	}
+	if len(results) == 0 {
+		return nil, fmt.Errorf("%w for %v at %s", ErrNoScan, target, t.Format(time.RFC3339))
+	}
+	return results[0], nil
+}
+
+// scans runs query, which must select the columns of the scans table, and
+// loads the ports of every scan returned.
+func (s *Store) scans(query string, args ...interface{}) ([]*scanme.ScanResult, error) {
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int64
+	var results []*scanme.ScanResult
+	for rows.Next() {
+		var (
+			id         int64
+			target     string
+			start, end int64
+			r          scanme.ScanResult
+		)
+		if err := rows.Scan(&id, &target, &start, &end, &r.Stats.PacketsSent, &r.Stats.PacketsReceived); err != nil {
+			return nil, err
+		}
+		r.Target = net.ParseIP(target)
+		r.StartTime = time.Unix(0, start)
+		r.EndTime = time.Unix(0, end)
+		r.Stats.Duration = r.EndTime.Sub(r.StartTime)
+		ids = append(ids, id)
+		results = append(results, &r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for i, id := range ids {
+		if results[i].Ports, err = s.ports(id); err != nil {
+			return nil, err
+		}
+	}
+	return results, nil
+}
+
+func (s *Store) ports(scanID int64) ([]scanme.PortResult, error) {
+	rows, err := s.db.Query("SELECT port, state, service FROM ports WHERE scan_id = ? ORDER BY port", scanID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ports []scanme.PortResult
+	for rows.Next() {
+		var port int
+		var p scanme.PortResult
+		if err := rows.Scan(&port, &p.State, &p.Service); err != nil {
+			return nil, err
+		}
+		p.Port = layers.TCPPort(port)
+		ports = append(ports, p)
+	}
+	return ports, rows.Err()
+}
@@ -0,0 +1,114 @@
+package store
+
+import (
+	"errors"
+	"net"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/CyberRoute/scanme/scanme"
+	"github.com/google/gopacket/layers"
+)
+
+func openTestStore(t *testing.T, path string) *Store {
+	t.Helper()
+	s, err := Open(path)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+func scanAt(target net.IP, start time.Time, ports ...scanme.PortResult) *scanme.ScanResult {
+	return &scanme.ScanResult{
+		Target:    target,
+		StartTime: start,
+		EndTime:   start.Add(time.Minute),
+		Ports:     ports,
+		Stats:     scanme.ScanStats{PacketsSent: 100, PacketsReceived: 10},
+	}
+}
+
+func TestSaveQueryDiff(t *testing.T) {
+	s := openTestStore(t, filepath.Join(t.TempDir(), "history.db"))
+	target := net.IPv4(192, 0, 2, 1)
+	other := net.IPv4(192, 0, 2, 2)
+	day := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	for _, r := range []*scanme.ScanResult{
+		scanAt(target, day,
+			scanme.PortResult{Port: 22, State: "open", Service: "ssh"},
+			scanme.PortResult{Port: 80, State: "open", Service: "http"}),
+		scanAt(target, day.AddDate(0, 0, 10),
+			scanme.PortResult{Port: 22, State: "filtered"},
+			scanme.PortResult{Port: 443, State: "open", Service: "https"}),
+		scanAt(other, day.AddDate(0, 0, 5),
+			scanme.PortResult{Port: 25, State: "open", Service: "smtp"}),
+	} {
+		if err := s.Save(r); err != nil {
+			t.Fatalf("Save() error = %v", err)
+		}
+	}
+
+	results, err := s.Query(target, day.AddDate(0, 0, 1))
+	if err != nil {
+		t.Fatalf("Query() error = %v", err)
+	}
+	if len(results) != 1 {
+		t.Fatalf("Query() returned %d scans, want the one after the first day", len(results))
+	}
+	got := results[0]
+	if !got.Target.Equal(target) || !got.StartTime.Equal(day.AddDate(0, 0, 10)) || got.Stats.Duration != time.Minute {
+		t.Errorf("Query() = %v started at %v lasting %v", got.Target, got.StartTime, got.Stats.Duration)
+	}
+	if got.Stats.PacketsSent != 100 || got.Stats.PacketsReceived != 10 {
+		t.Errorf("Stats = %+v, want 100 sent and 10 received", got.Stats)
+	}
+	want := []scanme.PortResult{{Port: 22, State: "filtered"}, {Port: 443, State: "open", Service: "https"}}
+	if !slices.Equal(got.Ports, want) {
+		t.Errorf("Ports = %+v, want %+v", got.Ports, want)
+	}
+
+	if results, err := s.Query(target, time.Time{}); err != nil || len(results) != 2 {
+		t.Errorf("Query() of the whole history = %d scans, %v, want 2", len(results), err)
+	}
+
+	d, err := s.Diff(target, day.AddDate(0, 0, 1), day.AddDate(0, 0, 20))
+	if err != nil {
+		t.Fatalf("Diff() error = %v", err)
+	}
+	if !slices.Equal(d.Opened, []layers.TCPPort{443}) || !slices.Equal(d.Closed, []layers.TCPPort{80}) ||
+		!slices.Equal(d.Changed, []scanme.PortChange{{Port: 22, From: "open", To: "filtered"}}) {
+		t.Errorf("Diff() = opened %v, closed %v, changed %v", d.Opened, d.Closed, d.Changed)
+	}
+
+	if _, err := s.Diff(target, day.AddDate(0, 0, -1), day); !errors.Is(err, ErrNoScan) {
+		t.Errorf("Diff() before the first scan: error = %v, want ErrNoScan", err)
+	}
+}
+
+func TestOpenMigratesOnce(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.db")
+	s := openTestStore(t, path)
+	if err := s.Save(scanAt(net.IPv4(192, 0, 2, 1), time.Now(), scanme.PortResult{Port: 22, State: "open"})); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	s.Close()
+
+	// Reopening an up to date database applies no migration and keeps the
+	// scans saved.
+	s = openTestStore(t, path)
+	var version int
+	if err := s.db.QueryRow("PRAGMA user_version").Scan(&version); err != nil {
+		t.Fatal(err)
+	}
+	if files, _ := migrations.ReadDir("migrations"); version != len(files) {
+		t.Errorf("user_version = %d, want %d", version, len(files))
+	}
+	if results, err := s.Query(net.IPv4(192, 0, 2, 1), time.Time{}); err != nil || len(results) != 1 {
+		t.Errorf("Query() after reopening = %d scans, %v, want 1", len(results), err)
+	}
+}
@@ -0,0 +1,75 @@
+package scanme
+
+import (
+	"encoding/binary"
+	"math/rand"
+
+	"github.com/google/gopacket/layers"
+)
+
+// TCPOptionsLinux returns the options of a SYN sent by a recent Linux
+// kernel: MSS, SACK permitted, timestamps, NOP and window scale 7.
+func TCPOptionsLinux() []layers.TCPOption {
+	return []layers.TCPOption{
+		mssOption(1460),
+		sackPermittedOption(),
+		timestampOption(),
+		nopOption(),
+		windowScaleOption(7),
+	}
+}
+
+// TCPOptionsWindows10 returns the options of a SYN sent by Windows 10: MSS,
+// NOP, window scale 8, NOP, NOP and SACK permitted.
+func TCPOptionsWindows10() []layers.TCPOption {
+	return []layers.TCPOption{
+		mssOption(1460),
+		nopOption(),
+		windowScaleOption(8),
+		nopOption(),
+		nopOption(),
+		sackPermittedOption(),
+	}
+}
+
+// TCPOptionsMacOS returns the options of a SYN sent by macOS: MSS, NOP,
+// window scale 6, NOP, NOP, timestamps, SACK permitted and end of list.
+func TCPOptionsMacOS() []layers.TCPOption {
+	return []layers.TCPOption{
+		mssOption(1460),
+		nopOption(),
+		windowScaleOption(6),
+		nopOption(),
+		nopOption(),
+		timestampOption(),
+		sackPermittedOption(),
+		{OptionType: layers.TCPOptionKindEndList},
+		{OptionType: layers.TCPOptionKindEndList},
+	}
+}
+
+func mssOption(mss uint16) layers.TCPOption {
+	data := make([]byte, 2)
+	binary.BigEndian.PutUint16(data, mss)
+	return layers.TCPOption{OptionType: layers.TCPOptionKindMSS, OptionLength: 4, OptionData: data}
+}
+
+func windowScaleOption(shift uint8) layers.TCPOption {
+	return layers.TCPOption{OptionType: layers.TCPOptionKindWindowScale, OptionLength: 3, OptionData: []byte{shift}}
+}
+
+func sackPermittedOption() layers.TCPOption {
+	return layers.TCPOption{OptionType: layers.TCPOptionKindSACKPermitted, OptionLength: 2}
+}
+
+func nopOption() layers.TCPOption {
+	return layers.TCPOption{OptionType: layers.TCPOptionKindNop, OptionLength: 1}
+}
+
+// timestampOption returns a timestamps option with a random TSval, as
+// real stacks don't expose their uptime, and a zero TSecr.
+func timestampOption() layers.TCPOption {
+	data := make([]byte, 8)
+	binary.BigEndian.PutUint32(data, rand.Uint32())
+	return layers.TCPOption{OptionType: layers.TCPOptionKindTimestamps, OptionLength: 10, OptionData: data}
+}
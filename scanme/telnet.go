@@ -0,0 +1,253 @@
+package scanme
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"time"
+)
+
+// telnetTimeout bounds TelnetProbe, unless ctx has an earlier deadline.
+const telnetTimeout = 10 * time.Second
+
+// telnetIdle is how long TelnetProbe waits for more data once the server
+// stopped sending, devices sending their prompt in one go.
+const telnetIdle = 2 * time.Second
+
+// telnetBannerSize is the number of bytes of data TelnetProbe captures.
+const telnetBannerSize = 512
+
+// The Telnet commands and options TelnetProbe negotiates.
+const (
+	telnetIAC  = 255
+	telnetDont = 254
+	telnetDo   = 253
+	telnetWont = 252
+	telnetWill = 251
+	telnetSB   = 250
+	telnetSE   = 240
+
+	telnetEcho            = 1
+	telnetSuppressGoAhead = 3
+)
+
+// ErrNotTelnet is returned by TelnetProbe when the server on port 23 sends
+// nothing.
+var ErrNotTelnet = errors.New("not a Telnet service")
+
+// telnetDevices are the patterns of the banners and prompts of common
+// devices, matched in order against the lowercased banner.
+var telnetDevices = []struct {
+	pattern string
+	hint    string
+}{
+	{"user access verification", "Cisco IOS"},
+	{"cisco", "Cisco IOS"},
+	{"junos", "Juniper Junos"},
+	{"mikrotik", "MikroTik RouterOS"},
+	{"huawei", "Huawei VRP"},
+	{"zxan", "ZTE OLT"},
+	{"fortigate", "Fortinet FortiGate"},
+	{"procurve", "HP ProCurve switch"},
+	{"jetdirect", "HP JetDirect printer"},
+	{"microsoft telnet", "Windows Telnet Server"},
+	{"busybox", "BusyBox embedded Linux"},
+	{"vxworks", "VxWorks"},
+	{"schneider", "Schneider Electric PLC"},
+	{"siemens", "Siemens device"},
+	{"polycom", "Polycom"},
+	{"ubuntu", "Linux (Ubuntu)"},
+	{"debian", "Linux (Debian)"},
+	{"centos", "Linux (CentOS)"},
+	{"red hat", "Linux (Red Hat)"},
+	{"linux", "Linux"},
+	{"freebsd", "FreeBSD"},
+}
+
+// telnetLoginPrompts are the prompts asking for credentials.
+var telnetLoginPrompts = []string{"login", "username", "user name", "password", "passcode"}
+
+// TelnetInfo describes the Telnet server of the target.
+type TelnetInfo struct {
+	// Banner is the data the server sent, up to 512 bytes, without the
+	// Telnet negotiation.
+	Banner string
+	// DeviceHint identifies the device from the banner and prompt, such as
+	// "Cisco IOS", empty when unknown.
+	DeviceHint string
+	// NegotiationOptions are the Telnet options the server negotiated,
+	// in order.
+	NegotiationOptions []byte
+	// NoAuth is set when the server presented a shell prompt without
+	// asking for credentials.
+	NoAuth bool
+}
+
+// ServiceHint returns the classification of the server for
+// PortResult.ServiceHint.
+func (t *TelnetInfo) ServiceHint() string {
+	hint := "telnet"
+	if t.NoAuth {
+		hint = "telnet-noauth"
+	}
+	if t.DeviceHint != "" {
+		hint += " (" + t.DeviceHint + ")"
+	}
+	return hint
+}
+
+// TelnetProbe connects to port 23 of the target and captures what the
+// server sends, up to 512 bytes, until it waits for input. Negotiations are
+// answered so that servers proceed to their prompt: echo and suppress
+// go-ahead are accepted, every other option refused. Nothing is sent beyond
+// them, no credentials are tried.
+func (s *scanner) TelnetProbe(ctx context.Context) (*TelnetInfo, error) {
+	ctx, cancel := context.WithTimeout(ctx, telnetTimeout)
+	defer cancel()
+	conn, err := dialICS(ctx, s.dst, "23")
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	deadline, _ := ctx.Deadline()
+
+	info := &TelnetInfo{}
+	var data []byte
+	seen := make(map[byte]bool)
+	buf := make([]byte, 1024)
+	// Bytes of an IAC sequence split across reads.
+	var pending []byte
+	for len(data) < telnetBannerSize {
+		conn.SetReadDeadline(earliest(deadline, time.Now().Add(telnetIdle)))
+		n, err := conn.Read(buf)
+		if n > 0 {
+			in := append(pending, buf[:n]...)
+			var text, reply []byte
+			text, reply, pending = telnetDecode(in, func(opt byte) {
+				if !seen[opt] {
+					seen[opt] = true
+					info.NegotiationOptions = append(info.NegotiationOptions, opt)
+				}
+			})
+			data = append(data, text...)
+			if len(reply) > 0 {
+				if _, err := conn.Write(reply); err != nil {
+					break
+				}
+			}
+		}
+		if err != nil {
+			var ne net.Error
+			if len(data) == 0 && len(info.NegotiationOptions) == 0 && !(errors.As(err, &ne) && ne.Timeout()) {
+				return nil, ErrNotTelnet
+			}
+			break
+		}
+	}
+	if len(data) == 0 && len(info.NegotiationOptions) == 0 {
+		return nil, ErrNotTelnet
+	}
+	if len(data) > telnetBannerSize {
+		data = data[:telnetBannerSize]
+	}
+	info.Banner = string(data)
+	info.DeviceHint = telnetDevice(info.Banner)
+	info.NoAuth = telnetShellPrompt(info.Banner)
+	if info.NoAuth {
+		s.logger.Warn("Telnet server requires no authentication", "target", s.dst, "device", info.DeviceHint)
+	}
+	return info, nil
+}
+
+// telnetDecode splits the data in from the Telnet commands it holds,
+// calling option with each option the server negotiates, and returns the
+// data, the replies to the negotiation and the bytes of an incomplete
+// command at the end of in.
+func telnetDecode(in []byte, option func(byte)) (text, reply, rest []byte) {
+	for i := 0; i < len(in); i++ {
+		if in[i] != telnetIAC {
+			text = append(text, in[i])
+			continue
+		}
+		if i+1 >= len(in) {
+			return text, reply, in[i:]
+		}
+		switch cmd := in[i+1]; cmd {
+		case telnetIAC: // escaped 255
+			text = append(text, telnetIAC)
+			i++
+		case telnetDo, telnetDont, telnetWill, telnetWont:
+			if i+2 >= len(in) {
+				return text, reply, in[i:]
+			}
+			opt := in[i+2]
+			option(opt)
+			switch {
+			case cmd == telnetWill && (opt == telnetEcho || opt == telnetSuppressGoAhead):
+				reply = append(reply, telnetIAC, telnetDo, opt)
+			case cmd == telnetWill:
+				reply = append(reply, telnetIAC, telnetDont, opt)
+			case cmd == telnetDo && opt == telnetSuppressGoAhead:
+				reply = append(reply, telnetIAC, telnetWill, opt)
+			case cmd == telnetDo:
+				reply = append(reply, telnetIAC, telnetWont, opt)
+			}
+			i += 2
+		case telnetSB:
+			end := -1
+			for j := i + 2; j+1 < len(in); j++ {
+				if in[j] == telnetIAC && in[j+1] == telnetSE {
+					end = j + 1
+					break
+				}
+			}
+			if end < 0 {
+				return text, reply, in[i:]
+			}
+			i = end
+		default: // commands without option, such as go-ahead
+			i++
+		}
+	}
+	return text, reply, nil
+}
+
+// telnetDevice identifies the device of a Telnet banner.
+func telnetDevice(banner string) string {
+	lower := strings.ToLower(banner)
+	for _, d := range telnetDevices {
+		if strings.Contains(lower, d.pattern) {
+			return d.hint
+		}
+	}
+	return ""
+}
+
+// telnetShellPrompt tells whether banner ends with a shell prompt, such as
+// "router#" or "$ ", without having asked for credentials.
+func telnetShellPrompt(banner string) bool {
+	lower := strings.ToLower(banner)
+	for _, p := range telnetLoginPrompts {
+		if strings.Contains(lower, p) {
+			return false
+		}
+	}
+	prompt := strings.TrimRight(banner, " \r\n\x00")
+	if prompt == "" {
+		return false
+	}
+	switch prompt[len(prompt)-1] {
+	case '#', '$', '>', '%':
+		return true
+	}
+	return false
+}
+
+// earliest returns the earliest of a and b, b when a is zero.
+func earliest(a, b time.Time) time.Time {
+	if a.IsZero() || b.Before(a) {
+		return b
+	}
+	return a
+}
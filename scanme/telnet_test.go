@@ -0,0 +1,158 @@
+package scanme
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"testing"
+)
+
+const telnetTerminalType = 24
+
+func TestTelnetDecode(t *testing.T) {
+	tests := []struct {
+		name              string
+		in                []byte
+		text, reply, rest []byte
+		options           []byte
+	}{
+		{
+			name:    "negotiation",
+			in:      []byte{telnetIAC, telnetWill, telnetEcho, telnetIAC, telnetWill, telnetSuppressGoAhead, telnetIAC, telnetDo, telnetTerminalType, telnetIAC, telnetDo, telnetSuppressGoAhead, 'o', 'k'},
+			text:    []byte("ok"),
+			reply:   []byte{telnetIAC, telnetDo, telnetEcho, telnetIAC, telnetDo, telnetSuppressGoAhead, telnetIAC, telnetWont, telnetTerminalType, telnetIAC, telnetWill, telnetSuppressGoAhead},
+			options: []byte{telnetEcho, telnetSuppressGoAhead, telnetTerminalType, telnetSuppressGoAhead},
+		},
+		{
+			name:    "refused options",
+			in:      []byte{telnetIAC, telnetWill, 31, telnetIAC, telnetWont, telnetEcho, telnetIAC, telnetDont, 31},
+			reply:   []byte{telnetIAC, telnetDont, 31},
+			options: []byte{31, telnetEcho, 31},
+		},
+		{
+			name: "escaped IAC and go-ahead",
+			in:   []byte{'a', telnetIAC, telnetIAC, 'b', telnetIAC, 249, 'c'},
+			text: []byte{'a', telnetIAC, 'b', 'c'},
+		},
+		{
+			name: "subnegotiation",
+			in:   []byte{'a', telnetIAC, telnetSB, telnetTerminalType, 1, telnetIAC, telnetSE, 'b'},
+			text: []byte("ab"),
+		},
+		{
+			name: "split command",
+			in:   []byte{'a', telnetIAC, telnetDo},
+			text: []byte("a"),
+			rest: []byte{telnetIAC, telnetDo},
+		},
+		{
+			name: "split subnegotiation",
+			in:   []byte{telnetIAC, telnetSB, telnetTerminalType, 1, telnetIAC},
+			rest: []byte{telnetIAC, telnetSB, telnetTerminalType, 1, telnetIAC},
+		},
+	}
+	for _, tt := range tests {
+		var options []byte
+		text, reply, rest := telnetDecode(tt.in, func(opt byte) { options = append(options, opt) })
+		if !bytes.Equal(text, tt.text) || !bytes.Equal(reply, tt.reply) || !bytes.Equal(rest, tt.rest) || !bytes.Equal(options, tt.options) {
+			t.Errorf("%s: telnetDecode() = %q, %v, %v with options %v, want %q, %v, %v with options %v",
+				tt.name, text, reply, rest, options, tt.text, tt.reply, tt.rest, tt.options)
+		}
+	}
+}
+
+// telnetServer returns a handler negotiating echo, suppress go-ahead and
+// the terminal type, then sending banner and closing the connection once
+// the client answered. The replies of the client are sent on replies.
+func telnetServer(banner string, replies chan<- []byte) func(net.Conn) {
+	return func(conn net.Conn) {
+		conn.Write([]byte{telnetIAC, telnetWill, telnetEcho, telnetIAC, telnetWill, telnetSuppressGoAhead})
+		// The banner is split in the middle of a negotiation.
+		conn.Write([]byte{telnetIAC, telnetDo})
+		conn.Write([]byte{telnetTerminalType})
+		reply := make([]byte, 9)
+		if _, err := io.ReadFull(conn, reply); err != nil {
+			return
+		}
+		replies <- reply
+		conn.Write([]byte(banner))
+	}
+}
+
+func TestTelnetProbe(t *testing.T) {
+	tests := []struct {
+		name   string
+		banner string
+		want   TelnetInfo
+	}{
+		{
+			name:   "Cisco login",
+			banner: "\r\n\r\nUser Access Verification\r\n\r\nUsername: ",
+			want:   TelnetInfo{Banner: "\r\n\r\nUser Access Verification\r\n\r\nUsername: ", DeviceHint: "Cisco IOS"},
+		},
+		{
+			name:   "BusyBox shell",
+			banner: "\r\n\r\nBusyBox v1.31.1 () built-in shell (ash)\r\n\r\n# ",
+			want:   TelnetInfo{Banner: "\r\n\r\nBusyBox v1.31.1 () built-in shell (ash)\r\n\r\n# ", DeviceHint: "BusyBox embedded Linux", NoAuth: true},
+		},
+		{
+			name:   "long banner",
+			banner: strings.Repeat("Authorized access only. ", 30) + "\r\nlogin: ",
+			want:   TelnetInfo{Banner: strings.Repeat("Authorized access only. ", 30)[:telnetBannerSize]},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			replies := make(chan []byte, 1)
+			testServer(t, "127.0.0.1:23", telnetServer(tt.banner, replies))
+			s := newTestScanner()
+			s.dst = net.IPv4(127, 0, 0, 1)
+			info, err := s.TelnetProbe(context.Background())
+			if err != nil {
+				t.Fatalf("TelnetProbe() error = %v", err)
+			}
+			if info.Banner != tt.want.Banner || info.DeviceHint != tt.want.DeviceHint || info.NoAuth != tt.want.NoAuth {
+				t.Errorf("TelnetProbe() = %+v, want %+v", info, tt.want)
+			}
+			if want := []byte{telnetEcho, telnetSuppressGoAhead, telnetTerminalType}; !bytes.Equal(info.NegotiationOptions, want) {
+				t.Errorf("NegotiationOptions = %v, want %v", info.NegotiationOptions, want)
+			}
+			want := []byte{telnetIAC, telnetDo, telnetEcho, telnetIAC, telnetDo, telnetSuppressGoAhead, telnetIAC, telnetWont, telnetTerminalType}
+			if reply := <-replies; !bytes.Equal(reply, want) {
+				t.Errorf("client replied %v, want %v", reply, want)
+			}
+		})
+	}
+}
+
+func TestTelnetProbeNotTelnet(t *testing.T) {
+	testServer(t, "127.0.0.1:23", func(net.Conn) {})
+	s := newTestScanner()
+	s.dst = net.IPv4(127, 0, 0, 1)
+	if _, err := s.TelnetProbe(context.Background()); !errors.Is(err, ErrNotTelnet) {
+		t.Errorf("TelnetProbe() error = %v, want ErrNotTelnet", err)
+	}
+}
+
+func TestTelnetShellPrompt(t *testing.T) {
+	tests := []struct {
+		banner string
+		want   bool
+	}{
+		{"router#", true},
+		{"Welcome\r\n[admin@MikroTik] > ", true},
+		{"user@host:~$ ", true},
+		{"Password: ", false},
+		{"login: ", false},
+		{"Welcome to the lab\r\n", false},
+		{"\r\n", false},
+	}
+	for _, tt := range tests {
+		if got := telnetShellPrompt(tt.banner); got != tt.want {
+			t.Errorf("telnetShellPrompt(%q) = %v, want %v", tt.banner, got, tt.want)
+		}
+	}
+}
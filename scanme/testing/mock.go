@@ -0,0 +1,92 @@
+// Package testing provides test doubles for code depending on the
+// scanme.Scanner interface, so it can be unit tested without opening a pcap
+// handle or sending packets.
+package testing
+
+import (
+	"context"
+	"time"
+
+	"github.com/CyberRoute/scanme/scanme"
+	"github.com/google/gopacket/layers"
+)
+
+// MockScanner implements scanme.Scanner returning canned values.
+type MockScanner struct {
+	// Result and Err are returned by Synscan and SynscanContext; ConnScan
+	// returns the open ports of Result and Err.
+	Result *scanme.ScanResult
+	Err    error
+	// Banners holds the banner returned by GrabBanner for each port, and
+	// BannerErr the error returned for every call when set.
+	Banners   map[layers.TCPPort]string
+	BannerErr error
+
+	// SynscanCalls counts the calls to Synscan and SynscanContext,
+	// ConnScanCalls those to ConnScan, and Closed reports whether Close has
+	// been called.
+	SynscanCalls  int
+	ConnScanCalls int
+	Closed        bool
+	// WaitErr is returned by Wait.
+	WaitErr error
+}
+
+var _ scanme.Scanner = (*MockScanner)(nil)
+
+// Synscan returns m.Result and m.Err.
+func (m *MockScanner) Synscan() (*scanme.ScanResult, error) {
+	m.SynscanCalls++
+	return m.Result, m.Err
+}
+
+// SynscanContext returns m.Result and m.Err, or the error of ctx when it is
+// already done.
+func (m *MockScanner) SynscanContext(ctx context.Context) (*scanme.ScanResult, error) {
+	m.SynscanCalls++
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return m.Result, m.Err
+}
+
+// ConnScan returns the service of every open port of m.Result, and m.Err.
+func (m *MockScanner) ConnScan() (map[layers.TCPPort]string, error) {
+	m.ConnScanCalls++
+	if m.Err != nil {
+		return nil, m.Err
+	}
+	open := make(map[layers.TCPPort]string)
+	if m.Result != nil {
+		for _, p := range m.Result.Ports {
+			if p.State == "open" {
+				open[p.Port] = p.Service
+			}
+		}
+	}
+	return open, nil
+}
+
+// GrabBanner returns the banner configured for port in m.Banners.
+func (m *MockScanner) GrabBanner(port layers.TCPPort, timeout time.Duration) (string, error) {
+	if m.BannerErr != nil {
+		return "", m.BannerErr
+	}
+	return m.Banners[port], nil
+}
+
+// Close records that the scanner has been closed.
+func (m *MockScanner) Close() {
+	m.Closed = true
+}
+
+// GracefulClose records that the scanner has been closed.
+func (m *MockScanner) GracefulClose(timeout time.Duration) error {
+	m.Closed = true
+	return nil
+}
+
+// Wait returns m.WaitErr.
+func (m *MockScanner) Wait() error {
+	return m.WaitErr
+}
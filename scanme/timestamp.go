@@ -0,0 +1,144 @@
+package scanme
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"time"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"github.com/google/gopacket/pcap"
+)
+
+// timestampTimeout is how long ICMPTimestamp waits for a reply.
+const timestampTimeout = 3 * time.Second
+
+// ErrTimestampBlocked is returned by ICMPTimestamp when the target does not
+// answer ICMP timestamp requests, as many hosts and firewalls are set up to.
+var ErrTimestampBlocked = errors.New("no ICMP timestamp reply")
+
+// TimestampResult is the outcome of an ICMP timestamp request.
+type TimestampResult struct {
+	// RemoteTime is the clock of the target when it sent its reply.
+	RemoteTime time.Time
+	// ClockSkew is how far the clock of the target is ahead of ours.
+	ClockSkew time.Duration
+	// RTT is the round-trip time of the request.
+	RTT time.Duration
+}
+
+// ICMPTimestamp sends an ICMP timestamp request (type 13) to the target and
+// reads the clock of the target from its timestamp reply (type 14). Clock
+// skew helps fingerprinting hosts and telling apart hosts sharing an address.
+//
+// RFC 792 timestamps count milliseconds since midnight UT, so the skew is
+// computed as ((receive - originate) + (transmit - arrival)) / 2, which
+// cancels out the network delay assuming it is symmetric.
+func (s *scanner) ICMPTimestamp(ctx context.Context) (*TimestampResult, error) {
+	eth, ip4, err := s.icmpLayers(ctx)
+	if err != nil {
+		return nil, err
+	}
+	handle, err := s.openICMPHandle(uint8(layers.ICMPv4TypeTimestampReply))
+	if err != nil {
+		return nil, err
+	}
+	defer handle.Close()
+
+	id := uint16(s.rng.Intn(0xffff))
+	icmp := layers.ICMPv4{
+		TypeCode: layers.CreateICMPv4TypeCode(layers.ICMPv4TypeTimestampRequest, 0),
+		Id:       id,
+		Seq:      1,
+	}
+	originate := time.Now()
+	// The originate timestamp is followed by the receive and transmit
+	// timestamps, filled in by the target.
+	payload := make(gopacket.Payload, 12)
+	binary.BigEndian.PutUint32(payload, msSinceMidnight(originate))
+	if err := s.send(&eth, &ip4, &icmp, &payload); err != nil {
+		return nil, err
+	}
+
+	return readTimestampReply(ctx, handle, id, originate)
+}
+
+// readTimestampReply reads packets from src until the timestamp reply to the
+// request id sent at originate, and returns the result it gives.
+// ErrTimestampBlocked is returned when none arrives within
+// timestampTimeout, or before the end of a capture file.
+func readTimestampReply(ctx context.Context, src gopacket.PacketDataSource, id uint16, originate time.Time) (*TimestampResult, error) {
+	var eth layers.Ethernet
+	var dot1q layers.Dot1Q
+	var ip4 layers.IPv4
+	var icmp layers.ICMPv4
+	parser := gopacket.NewDecodingLayerParser(layers.LayerTypeEthernet, &eth, &dot1q, &ip4, &icmp)
+	parser.IgnoreUnsupported = true
+	decoded := []gopacket.LayerType{}
+
+	deadline := time.Now().Add(timestampTimeout)
+	for time.Now().Before(deadline) {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		data, ci, err := src.ReadPacketData()
+		if err == pcap.NextErrorTimeoutExpired {
+			continue
+		} else if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+
+		//nolint:staticcheck // SA9003 ignore this!
+		if err := parser.DecodeLayers(data, &decoded); err != nil {
+			// Errors here are due to the decoder, and not all layers are implemented.
+		}
+		if icmp.TypeCode.Type() != layers.ICMPv4TypeTimestampReply || icmp.Id != id || len(icmp.Payload) < 12 {
+			continue
+		}
+
+		receive := binary.BigEndian.Uint32(icmp.Payload[4:])
+		transmit := binary.BigEndian.Uint32(icmp.Payload[8:])
+		return timestampResult(originate, ci.Timestamp, receive, transmit), nil
+	}
+	return nil, ErrTimestampBlocked
+}
+
+// timestampResult computes the result of a request sent at originate and
+// answered at arrival with the receive and transmit timestamps of the target.
+func timestampResult(originate, arrival time.Time, receive, transmit uint32) *TimestampResult {
+	ms := func(t time.Time) int64 { return int64(msSinceMidnight(t)) }
+	skew := (msDiff(int64(receive), ms(originate)) + msDiff(int64(transmit), ms(arrival))) / 2
+	return &TimestampResult{
+		RemoteTime: arrival.Add(time.Duration(skew) * time.Millisecond),
+		ClockSkew:  time.Duration(skew) * time.Millisecond,
+		RTT:        arrival.Sub(originate),
+	}
+}
+
+// msPerDay is the number of milliseconds in a day, at which RFC 792
+// timestamps wrap around.
+const msPerDay = 24 * 60 * 60 * 1000
+
+// msSinceMidnight returns t as an RFC 792 timestamp.
+func msSinceMidnight(t time.Time) uint32 {
+	t = t.UTC()
+	midnight := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	return uint32(t.Sub(midnight) / time.Millisecond)
+}
+
+// msDiff returns a - b for timestamps taken around the same time, wrapping
+// around midnight.
+func msDiff(a, b int64) int64 {
+	d := a - b
+	if d > msPerDay/2 {
+		d -= msPerDay
+	} else if d < -msPerDay/2 {
+		d += msPerDay
+	}
+	return d
+}
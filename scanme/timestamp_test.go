@@ -0,0 +1,78 @@
+package scanme
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/gopacket/pcap"
+)
+
+// testdata/timestamp.pcap holds an ICMP timestamp request with id 0x1234
+// sent at 10:12:01 UTC, then an echo reply, a timestamp reply to another
+// request, a truncated reply and, 40ms after the request, the reply of a
+// target whose clock is 1.5s ahead.
+const timestampID = 0x1234
+
+var timestampSent = time.Date(2024, 3, 5, 10, 12, 1, 0, time.UTC)
+
+func replayTimestamp(t *testing.T, id uint16) (*TimestampResult, error) {
+	t.Helper()
+	handle, err := pcap.OpenOffline("testdata/timestamp.pcap")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer handle.Close()
+	return readTimestampReply(context.Background(), handle, id, timestampSent)
+}
+
+func TestReadTimestampReply(t *testing.T) {
+	result, err := replayTimestamp(t, timestampID)
+	if err != nil {
+		t.Fatalf("readTimestampReply() error = %v", err)
+	}
+	if result.RTT != 40*time.Millisecond {
+		t.Errorf("RTT = %v, want 40ms", result.RTT)
+	}
+	if result.ClockSkew != 1500*time.Millisecond {
+		t.Errorf("ClockSkew = %v, want 1.5s", result.ClockSkew)
+	}
+	if want := timestampSent.Add(1540 * time.Millisecond); !result.RemoteTime.Equal(want) {
+		t.Errorf("RemoteTime = %v, want %v", result.RemoteTime, want)
+	}
+}
+
+func TestReadTimestampReplyBlocked(t *testing.T) {
+	if _, err := replayTimestamp(t, 0x4321); !errors.Is(err, ErrTimestampBlocked) {
+		t.Errorf("error = %v, want ErrTimestampBlocked", err)
+	}
+}
+
+func TestReadTimestampReplyCanceled(t *testing.T) {
+	handle, err := pcap.OpenOffline("testdata/timestamp.pcap")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer handle.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := readTimestampReply(ctx, handle, timestampID, timestampSent); !errors.Is(err, context.Canceled) {
+		t.Errorf("error = %v, want context.Canceled", err)
+	}
+}
+
+// TestTimestampResultAcrossMidnight checks the skew of a request sent just
+// before midnight UT and answered by a target whose clock already wrapped.
+func TestTimestampResultAcrossMidnight(t *testing.T) {
+	originate := time.Date(2024, 3, 5, 23, 59, 59, 900*int(time.Millisecond), time.UTC)
+	arrival := originate.Add(20 * time.Millisecond)
+	// The clock of the target is 300ms ahead: 00:00:00.210 and .211.
+	result := timestampResult(originate, arrival, 210, 211)
+	if result.ClockSkew != 300*time.Millisecond {
+		t.Errorf("ClockSkew = %v, want 300ms", result.ClockSkew)
+	}
+	if result.RTT != 20*time.Millisecond {
+		t.Errorf("RTT = %v, want 20ms", result.RTT)
+	}
+}
@@ -0,0 +1,33 @@
+package scanme
+
+import (
+	"crypto/tls"
+	"errors"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/google/gopacket/layers"
+)
+
+// TLSCertExpiry completes a TLS handshake with port on the target and
+// returns the expiry date of the certificate the server presented. The
+// certificate is not verified, an expired or self-signed one is reported
+// all the same.
+func (s *scanner) TLSCertExpiry(port layers.TCPPort, timeout time.Duration) (time.Time, error) {
+	addr := net.JoinHostPort(s.dst.String(), strconv.Itoa(int(port)))
+	dialer := &net.Dialer{Timeout: timeout}
+	conn, err := tls.DialWithDialer(dialer, "tcp", addr, &tls.Config{
+		InsecureSkipVerify: true, // the certificate is inspected, not trusted
+	})
+	if err != nil {
+		return time.Time{}, err
+	}
+	defer conn.Close()
+
+	certs := conn.ConnectionState().PeerCertificates
+	if len(certs) == 0 {
+		return time.Time{}, errors.New("no certificate presented by " + addr)
+	}
+	return certs[0].NotAfter, nil
+}
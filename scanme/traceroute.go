@@ -0,0 +1,147 @@
+package scanme
+
+import (
+	"context"
+	"encoding/binary"
+	"net"
+	"os"
+	"time"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"github.com/google/gopacket/pcap"
+)
+
+// tracerouteHopTimeout is how long Traceroute waits for an answer at each hop.
+const tracerouteHopTimeout = time.Second
+
+// TracerouteHop is a router, or the target itself, found at TTL hops from
+// the scanner. IP is nil when nothing answered at that TTL.
+type TracerouteHop struct {
+	TTL uint8
+	IP  net.IP
+	RTT time.Duration
+}
+
+// Traceroute discovers the path to the target by sending ICMP Echo Requests
+// with a TTL from 1 to maxHops and collecting the ICMP Time Exceeded messages
+// sent back by routers along the way. It stops once the target answers with
+// an Echo Reply. Probes are sent and captured on the scanner's pcap handle.
+func (s *scanner) Traceroute(ctx context.Context, maxHops uint8) ([]TracerouteHop, error) {
+	mac, err := s.sendARPRequest(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.handle.SetBPFFilter(s.bpfFilter("icmp")); err != nil {
+		return nil, err
+	}
+	//nolint:errcheck // restoring the match-all filter can't fail in practice
+	defer s.handle.SetBPFFilter("")
+
+	eth := layers.Ethernet{
+		SrcMAC:       s.iface.HardwareAddr,
+		DstMAC:       mac,
+		EthernetType: layers.EthernetTypeIPv4,
+	}
+	id := uint16(os.Getpid())
+
+	var hops []TracerouteHop
+	for ttl := uint8(1); ttl <= maxHops && ttl != 0; ttl++ {
+		ip4 := layers.IPv4{
+			SrcIP:    s.src,
+			DstIP:    s.dst,
+			Version:  4,
+			TTL:      ttl,
+			Protocol: layers.IPProtocolICMPv4,
+		}
+		icmp := layers.ICMPv4{
+			TypeCode: layers.CreateICMPv4TypeCode(layers.ICMPv4TypeEchoRequest, 0),
+			Id:       id,
+			Seq:      uint16(ttl),
+		}
+
+		sent := time.Now()
+		if err := s.send(&eth, &ip4, &icmp); err != nil {
+			return hops, err
+		}
+
+		hop, reached, err := s.readHop(ctx, id, ttl, sent)
+		if err != nil {
+			return hops, err
+		}
+		hops = append(hops, hop)
+		if reached {
+			break
+		}
+	}
+
+	return hops, nil
+}
+
+// readHop waits for the answer to the echo request sent with the given id
+// and TTL. It reports whether the answer came from the target itself.
+func (s *scanner) readHop(ctx context.Context, id uint16, ttl uint8, sent time.Time) (TracerouteHop, bool, error) {
+	hop := TracerouteHop{TTL: ttl}
+
+	var eth layers.Ethernet
+	var dot1q layers.Dot1Q
+	var ip4 layers.IPv4
+	var icmp layers.ICMPv4
+	var payload gopacket.Payload
+	parser := gopacket.NewDecodingLayerParser(layers.LayerTypeEthernet, &eth, &dot1q, &ip4, &icmp, &payload)
+	parser.IgnoreUnsupported = true
+	decoded := []gopacket.LayerType{}
+
+	deadline := sent.Add(tracerouteHopTimeout)
+	for time.Now().Before(deadline) {
+		select {
+		case <-ctx.Done():
+			return hop, false, ctx.Err()
+		default:
+		}
+
+		data, ci, err := s.handle.ReadPacketData()
+		if err == pcap.NextErrorTimeoutExpired {
+			continue
+		} else if err != nil {
+			return hop, false, err
+		}
+
+		//nolint:staticcheck // SA9003 ignore this!
+		if err := parser.DecodeLayers(data, &decoded); err != nil {
+			// Errors here are due to the decoder, and not all layers are implemented.
+		}
+		if len(decoded) < 3 || decoded[2] != layers.LayerTypeICMPv4 || !ip4.DstIP.Equal(s.src) {
+			continue
+		}
+
+		switch icmp.TypeCode.Type() {
+		case layers.ICMPv4TypeEchoReply:
+			if ip4.SrcIP.Equal(s.dst) && icmp.Id == id && icmp.Seq == uint16(ttl) {
+				hop.IP, hop.RTT = append(net.IP(nil), ip4.SrcIP...), ci.Timestamp.Sub(sent)
+				return hop, true, nil
+			}
+		case layers.ICMPv4TypeTimeExceeded:
+			if origID, origSeq, ok := originalEcho(icmp.Payload); ok && origID == id && origSeq == uint16(ttl) {
+				hop.IP, hop.RTT = append(net.IP(nil), ip4.SrcIP...), ci.Timestamp.Sub(sent)
+				return hop, false, nil
+			}
+		}
+	}
+
+	return hop, false, nil
+}
+
+// originalEcho extracts the identifier and sequence number of the ICMP Echo
+// Request quoted in the payload of an ICMP error message.
+func originalEcho(payload []byte) (id, seq uint16, ok bool) {
+	var orig layers.IPv4
+	if err := orig.DecodeFromBytes(payload, gopacket.NilDecodeFeedback); err != nil {
+		return 0, 0, false
+	}
+	if orig.Protocol != layers.IPProtocolICMPv4 || len(orig.Payload) < 8 {
+		return 0, 0, false
+	}
+	return binary.BigEndian.Uint16(orig.Payload[4:6]), binary.BigEndian.Uint16(orig.Payload[6:8]), true
+}
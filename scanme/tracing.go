@@ -0,0 +1,9 @@
+package scanme
+
+// spanAttr is a key/value attribute attached to a tracing span. Values are
+// converted to the matching OpenTelemetry attribute type when the package is
+// built with the otel tag, and ignored otherwise.
+type spanAttr struct {
+	key   string
+	value interface{}
+}
@@ -0,0 +1,14 @@
+//go:build !otel
+
+package scanme
+
+import "context"
+
+// tracer is a no-op when the package is built without the otel tag, so that
+// scanme can be used without depending on OpenTelemetry.
+type tracer struct{}
+
+// start returns ctx and a function ending the span, which do nothing here.
+func (t *tracer) start(ctx context.Context, name string, attrs ...spanAttr) (context.Context, func()) {
+	return ctx, func() {}
+}
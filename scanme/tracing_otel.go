@@ -0,0 +1,66 @@
+//go:build otel
+
+package scanme
+
+import (
+	"context"
+	"fmt"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracerName is the instrumentation scope reported with every span.
+const tracerName = "github.com/CyberRoute/scanme"
+
+// tracer creates OpenTelemetry spans for the operations of a scanner. Spans
+// are nested through the context they are started with, such as the ARP
+// resolution under the Synscan it is part of, so that concurrent operations
+// of a scanner do not share a parent.
+type tracer struct {
+	tracer trace.Tracer
+}
+
+// WithTracerProvider enables OpenTelemetry tracing of NewScanner, the ARP
+// resolution, Synscan and Close using tp. It is only available when building
+// with the otel tag.
+func WithTracerProvider(tp trace.TracerProvider) Option {
+	return func(s *scanner) {
+		s.tracer = &tracer{
+			tracer: tp.Tracer(tracerName),
+		}
+	}
+}
+
+// start opens a span named name as a child of trace.SpanFromContext(ctx), a
+// root span when ctx holds none. It returns ctx with the new span, to start
+// its children with, and the function ending it. A nil tracer returns ctx
+// and a no-op function.
+func (t *tracer) start(ctx context.Context, name string, attrs ...spanAttr) (context.Context, func()) {
+	if t == nil {
+		return ctx, func() {}
+	}
+	ctx, span := t.tracer.Start(ctx, name, trace.WithAttributes(otelAttributes(attrs)...))
+	return ctx, func() { span.End() }
+}
+
+func otelAttributes(attrs []spanAttr) []attribute.KeyValue {
+	kvs := make([]attribute.KeyValue, 0, len(attrs))
+	for _, a := range attrs {
+		switch v := a.value.(type) {
+		case string:
+			kvs = append(kvs, attribute.String(a.key, v))
+		case int:
+			kvs = append(kvs, attribute.Int(a.key, v))
+		case int64:
+			kvs = append(kvs, attribute.Int64(a.key, v))
+		case float64:
+			kvs = append(kvs, attribute.Float64(a.key, v))
+		case bool:
+			kvs = append(kvs, attribute.Bool(a.key, v))
+		default:
+			kvs = append(kvs, attribute.String(a.key, fmt.Sprint(v)))
+		}
+	}
+	return kvs
+}
@@ -0,0 +1,112 @@
+//go:build otel
+
+package scanme
+
+import (
+	"context"
+	"encoding/binary"
+	"sync"
+	"testing"
+
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/embedded"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+// recordingProvider provides its recordingTracer whatever the name.
+type recordingProvider struct {
+	embedded.TracerProvider
+	tracer *recordingTracer
+}
+
+func (p recordingProvider) Tracer(string, ...trace.TracerOption) trace.Tracer { return p.tracer }
+
+// recordingTracer numbers the spans it starts and records the parent of
+// each, by span name.
+type recordingTracer struct {
+	embedded.Tracer
+
+	mu      sync.Mutex
+	last    uint64
+	parents map[string][]trace.SpanID
+}
+
+// recordedSpan is a non-recording span with the span context given by
+// recordingTracer.
+type recordedSpan struct {
+	trace.Span
+	sc trace.SpanContext
+}
+
+func (s recordedSpan) SpanContext() trace.SpanContext { return s.sc }
+
+func (r *recordingTracer) Start(ctx context.Context, name string, _ ...trace.SpanStartOption) (context.Context, trace.Span) {
+	parent := trace.SpanFromContext(ctx).SpanContext()
+	r.mu.Lock()
+	r.last++
+	var id trace.SpanID
+	binary.BigEndian.PutUint64(id[:], r.last)
+	r.parents[name] = append(r.parents[name], parent.SpanID())
+	r.mu.Unlock()
+
+	traceID := parent.TraceID()
+	if !traceID.IsValid() {
+		binary.BigEndian.PutUint64(traceID[:], r.last)
+	}
+	sc := trace.NewSpanContext(trace.SpanContextConfig{TraceID: traceID, SpanID: id})
+	_, noopSpan := noop.NewTracerProvider().Tracer("").Start(ctx, name)
+	span := recordedSpan{Span: noopSpan, sc: sc}
+	return trace.ContextWithSpan(ctx, span), span
+}
+
+// TestTracerNestsSpansByContext starts spans from several goroutines, as
+// concurrent calls on a scanner do, and checks that each is the child of
+// the span of the context it was started with; run it with -race.
+func TestTracerNestsSpansByContext(t *testing.T) {
+	rec := &recordingTracer{parents: make(map[string][]trace.SpanID)}
+	var s scanner
+	WithTracerProvider(recordingProvider{tracer: rec})(&s)
+
+	var wg sync.WaitGroup
+	scans := make([]trace.SpanID, 8)
+	for i := range scans {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			ctx, end := s.tracer.start(context.Background(), "Synscan", spanAttr{"scan", i})
+			defer end()
+			scans[i] = trace.SpanFromContext(ctx).SpanContext().SpanID()
+			for j := 0; j < 10; j++ {
+				_, endARP := s.tracer.start(ctx, "ARP")
+				endARP()
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	for _, parent := range rec.parents["Synscan"] {
+		if parent.IsValid() {
+			t.Errorf("Synscan span has parent %v, want a root span", parent)
+		}
+	}
+	children := make(map[trace.SpanID]int)
+	for _, parent := range rec.parents["ARP"] {
+		children[parent]++
+	}
+	for i, scan := range scans {
+		if children[scan] != 10 {
+			t.Errorf("Synscan %d has %d ARP children, want 10", i, children[scan])
+		}
+	}
+}
+
+func TestNilTracerReturnsContext(t *testing.T) {
+	var tr *tracer
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	got, end := tr.start(ctx, "Close")
+	end()
+	if got != ctx {
+		t.Error("start() on a nil tracer returned another context")
+	}
+}
@@ -0,0 +1,43 @@
+package scanme
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+)
+
+// tagVLAN inserts an 802.1Q header after the Ethernet header of the packet
+// made of l when WithVLAN is set. The caller's Ethernet layer is left as is.
+func (s *scanner) tagVLAN(l []gopacket.SerializableLayer) []gopacket.SerializableLayer {
+	if !s.vlan || len(l) == 0 {
+		return l
+	}
+	eth, ok := l[0].(*layers.Ethernet)
+	if !ok {
+		return l
+	}
+	tagged := *eth
+	tagged.EthernetType = layers.EthernetTypeDot1Q
+	dot1q := &layers.Dot1Q{
+		Priority:       s.vlanPCP,
+		VLANIdentifier: s.vlanID,
+		Type:           eth.EthernetType,
+	}
+	return append([]gopacket.SerializableLayer{&tagged, dot1q}, l[1:]...)
+}
+
+// bpfFilter restricts filter to the VLAN set with WithVLAN, if any.
+func (s *scanner) bpfFilter(filter string) string {
+	if !s.vlan || filter == "" {
+		return filter
+	}
+	return fmt.Sprintf("vlan %d and (%s)", s.vlanID, filter)
+}
+
+// isVLANInterface reports whether name is a Linux 802.1Q sub-interface.
+func isVLANInterface(name string) bool {
+	_, err := os.Stat("/proc/net/vlan/" + name)
+	return err == nil
+}
@@ -0,0 +1,210 @@
+package scanme
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/gopacket/layers"
+)
+
+// vncTimeout bounds VNCProbe when ctx has no deadline.
+const vncTimeout = 5 * time.Second
+
+// The ports of VNC servers, one per display, checked by WithVNCProbe.
+const (
+	vncFirstPort layers.TCPPort = 5900
+	vncLastPort  layers.TCPPort = 5909
+)
+
+// The security types of the RFB protocol reported in VNCInfo.SecurityTypes.
+const (
+	VNCSecurityNone     uint8 = 1
+	VNCSecurityVNCAuth  uint8 = 2
+	VNCSecurityTight    uint8 = 16
+	VNCSecurityTLS      uint8 = 18
+	VNCSecurityVeNCrypt uint8 = 19
+)
+
+// ErrNotVNC is returned by VNCProbe when the service on the port does not
+// speak RFB.
+var ErrNotVNC = errors.New("not a VNC service")
+
+// VNCInfo describes a VNC server of the target.
+type VNCInfo struct {
+	// RFBVersion is the version of the RFB protocol the server announced,
+	// such as "003.008".
+	RFBVersion string
+	// SecurityTypes are the security types the server offered, in its
+	// order of preference, see VNCSecurityNone and the other types.
+	SecurityTypes []uint8
+	// NoAuthSupported is set when the server offers the None security
+	// type: anyone reaching it gets control of the desktop without a
+	// password.
+	NoAuthSupported bool
+}
+
+// Supports tells whether the server offered security type t.
+func (v *VNCInfo) Supports(t uint8) bool {
+	for _, st := range v.SecurityTypes {
+		if st == t {
+			return true
+		}
+	}
+	return false
+}
+
+// ServiceHint returns the classification of the server for
+// PortResult.ServiceHint, such as "VNC-noauth" or "vnc (VNC auth, TLS)".
+func (v *VNCInfo) ServiceHint() string {
+	if v.NoAuthSupported {
+		return "VNC-noauth"
+	}
+	var names []string
+	for _, t := range v.SecurityTypes {
+		names = append(names, vncSecurityName(t))
+	}
+	if len(names) == 0 {
+		return "vnc"
+	}
+	return "vnc (" + strings.Join(names, ", ") + ")"
+}
+
+// vncSecurityName names the security type t.
+func vncSecurityName(t uint8) string {
+	switch t {
+	case VNCSecurityNone:
+		return "None"
+	case VNCSecurityVNCAuth:
+		return "VNC auth"
+	case VNCSecurityTight:
+		return "Tight"
+	case VNCSecurityTLS:
+		return "TLS"
+	case VNCSecurityVeNCrypt:
+		return "VeNCrypt"
+	}
+	return "type " + strconv.Itoa(int(t))
+}
+
+// VNCProbe connects to port of the target, answers the RFB version the
+// server announces with the highest version both support, and reads the
+// security types the server offers, without choosing any. Servers offering
+// the None type require no password, a critical finding.
+func (s *scanner) VNCProbe(ctx context.Context, port layers.TCPPort) (*VNCInfo, error) {
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, vncTimeout)
+		defer cancel()
+	}
+	conn, err := dialICS(ctx, s.dst, strconv.Itoa(int(port)))
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	var banner [12]byte
+	if _, err := io.ReadFull(conn, banner[:]); err != nil {
+		return nil, ErrNotVNC
+	}
+	major, minor, ok := parseRFBVersion(banner[:])
+	if !ok {
+		return nil, ErrNotVNC
+	}
+	info := &VNCInfo{RFBVersion: string(banner[4:11])}
+
+	// Servers announcing versions past 3.8, such as 3.889 for Apple
+	// Remote Desktop, speak 3.8 to clients that do.
+	reply := "RFB 003.008\n"
+	switch {
+	case major == 3 && minor < 7:
+		reply = "RFB 003.003\n"
+	case major == 3 && minor == 7:
+		reply = "RFB 003.007\n"
+	}
+	if _, err := conn.Write([]byte(reply)); err != nil {
+		return nil, err
+	}
+
+	if reply == "RFB 003.003\n" {
+		// The server chooses the security type itself.
+		var t [4]byte
+		if _, err := io.ReadFull(conn, t[:]); err != nil {
+			return nil, err
+		}
+		st := binary.BigEndian.Uint32(t[:])
+		if st == 0 {
+			return nil, vncFailure(conn)
+		}
+		info.SecurityTypes = []uint8{uint8(st)}
+	} else {
+		var n [1]byte
+		if _, err := io.ReadFull(conn, n[:]); err != nil {
+			return nil, err
+		}
+		if n[0] == 0 {
+			return nil, vncFailure(conn)
+		}
+		info.SecurityTypes = make([]uint8, n[0])
+		if _, err := io.ReadFull(conn, info.SecurityTypes); err != nil {
+			return nil, err
+		}
+	}
+	info.NoAuthSupported = info.Supports(VNCSecurityNone)
+	return info, nil
+}
+
+// parseRFBVersion parses a ProtocolVersion message, "RFB 003.008\n".
+func parseRFBVersion(b []byte) (major, minor int, ok bool) {
+	if len(b) != 12 || string(b[:4]) != "RFB " || b[7] != '.' || b[11] != '\n' {
+		return 0, 0, false
+	}
+	major, err := strconv.Atoi(string(b[4:7]))
+	if err != nil {
+		return 0, 0, false
+	}
+	minor, err = strconv.Atoi(string(b[8:11]))
+	if err != nil {
+		return 0, 0, false
+	}
+	return major, minor, true
+}
+
+// vncFailure reads the reason of a server refusing the connection, such as
+// one blacklisting clients after too many failed logons.
+func vncFailure(conn net.Conn) error {
+	var n [4]byte
+	if _, err := io.ReadFull(conn, n[:]); err != nil {
+		return errors.New("vnc: connection refused")
+	}
+	reason := make([]byte, min(binary.BigEndian.Uint32(n[:]), 1024))
+	if _, err := io.ReadFull(conn, reason); err != nil {
+		return errors.New("vnc: connection refused")
+	}
+	return fmt.Errorf("vnc: connection refused: %s", reason)
+}
+
+// probeVNC runs VNCProbe, for scanners created with WithVNCProbe, on the
+// ports of the VNC displays found open, setting their ServiceHint.
+func (s *scanner) probeVNC(ctx context.Context, result *ScanResult) {
+	for port := vncFirstPort; port <= vncLastPort; port++ {
+		if p, ok := result.Port(port); !ok || p.State != "open" {
+			continue
+		}
+		info, err := s.VNCProbe(ctx, port)
+		if err != nil {
+			s.logger.Debug("VNC probe failed", "target", s.dst, "port", port, "err", err)
+			continue
+		}
+		if info.NoAuthSupported {
+			s.logger.Warn("VNC server requires no authentication", "target", s.dst, "port", port, "rfb", info.RFBVersion)
+		}
+		result.SetServiceHint(port, info.ServiceHint())
+	}
+}
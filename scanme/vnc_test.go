@@ -0,0 +1,202 @@
+package scanme
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/gopacket/layers"
+)
+
+// testServer listens on addr, "127.0.0.1:0" for any port, and runs serve
+// on each connection it accepts, which it then closes. It returns the port
+// listened on.
+func testServer(t *testing.T, addr string, serve func(conn net.Conn)) layers.TCPPort {
+	t.Helper()
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		t.Skipf("listening on %s: %v", addr, err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			conn.SetDeadline(time.Now().Add(5 * time.Second))
+			serve(conn)
+			conn.Close()
+		}
+	}()
+	return layers.TCPPort(ln.Addr().(*net.TCPAddr).Port)
+}
+
+// vncServer returns a handler announcing version and, once the client
+// answered with its own version, sent on versions, writing security.
+func vncServer(version string, security []byte, versions chan<- string) func(net.Conn) {
+	return func(conn net.Conn) {
+		conn.Write([]byte(version))
+		reply := make([]byte, 12)
+		if _, err := io.ReadFull(conn, reply); err != nil {
+			return
+		}
+		versions <- string(reply)
+		conn.Write(security)
+		io.Copy(io.Discard, conn)
+	}
+}
+
+func TestVNCProbe(t *testing.T) {
+	refused := append([]byte{0, 0, 0, 0, 19}, "Too many auth fails"...)
+	tests := []struct {
+		name      string
+		version   string
+		security  []byte
+		want      *VNCInfo
+		wantReply string
+		wantErr   string
+	}{
+		{
+			name:      "3.8 without password",
+			version:   "RFB 003.008\n",
+			security:  []byte{3, VNCSecurityTight, VNCSecurityNone, VNCSecurityVNCAuth},
+			want:      &VNCInfo{RFBVersion: "003.008", SecurityTypes: []uint8{16, 1, 2}, NoAuthSupported: true},
+			wantReply: "RFB 003.008\n",
+		},
+		{
+			name:      "3.7",
+			version:   "RFB 003.007\n",
+			security:  []byte{2, VNCSecurityVeNCrypt, VNCSecurityTLS},
+			want:      &VNCInfo{RFBVersion: "003.007", SecurityTypes: []uint8{19, 18}},
+			wantReply: "RFB 003.007\n",
+		},
+		{
+			name:      "3.3",
+			version:   "RFB 003.003\n",
+			security:  []byte{0, 0, 0, VNCSecurityVNCAuth},
+			want:      &VNCInfo{RFBVersion: "003.003", SecurityTypes: []uint8{2}},
+			wantReply: "RFB 003.003\n",
+		},
+		{
+			name:      "Apple Remote Desktop",
+			version:   "RFB 003.889\n",
+			security:  []byte{2, 30, VNCSecurityVNCAuth},
+			want:      &VNCInfo{RFBVersion: "003.889", SecurityTypes: []uint8{30, 2}},
+			wantReply: "RFB 003.008\n",
+		},
+		{
+			name:      "refused",
+			version:   "RFB 003.008\n",
+			security:  refused,
+			wantReply: "RFB 003.008\n",
+			wantErr:   "Too many auth fails",
+		},
+		{
+			name:      "refused with 3.3",
+			version:   "RFB 003.003\n",
+			security:  append([]byte{0, 0, 0}, refused...),
+			wantReply: "RFB 003.003\n",
+			wantErr:   "Too many auth fails",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			versions := make(chan string, 1)
+			port := testServer(t, "127.0.0.1:0", vncServer(tt.version, tt.security, versions))
+			s := newTestScanner()
+			s.dst = net.IPv4(127, 0, 0, 1)
+			info, err := s.VNCProbe(context.Background(), port)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("VNCProbe() error = %v, want %q", err, tt.wantErr)
+				}
+			} else if err != nil {
+				t.Fatalf("VNCProbe() error = %v", err)
+			} else if info.RFBVersion != tt.want.RFBVersion || !slices.Equal(info.SecurityTypes, tt.want.SecurityTypes) || info.NoAuthSupported != tt.want.NoAuthSupported {
+				t.Errorf("VNCProbe() = %+v, want %+v", info, tt.want)
+			}
+			if reply := <-versions; reply != tt.wantReply {
+				t.Errorf("client version %q, want %q", reply, tt.wantReply)
+			}
+		})
+	}
+}
+
+func TestVNCProbeNotVNC(t *testing.T) {
+	for _, banner := range []string{"SSH-2.0-OpenSSH_9.6\r\n", "RFB 3.8\n", "HTTP/1.1 400\r\n"} {
+		port := testServer(t, "127.0.0.1:0", func(conn net.Conn) {
+			conn.Write([]byte(banner))
+		})
+		s := newTestScanner()
+		s.dst = net.IPv4(127, 0, 0, 1)
+		if _, err := s.VNCProbe(context.Background(), port); !errors.Is(err, ErrNotVNC) {
+			t.Errorf("banner %q: VNCProbe() error = %v, want ErrNotVNC", banner, err)
+		}
+	}
+}
+
+func TestVNCServiceHint(t *testing.T) {
+	tests := []struct {
+		info VNCInfo
+		want string
+	}{
+		{VNCInfo{SecurityTypes: []uint8{2, 1}, NoAuthSupported: true}, "VNC-noauth"},
+		{VNCInfo{SecurityTypes: []uint8{19, 2, 7}}, "vnc (VeNCrypt, VNC auth, type 7)"},
+		{VNCInfo{}, "vnc"},
+	}
+	for _, tt := range tests {
+		if got := tt.info.ServiceHint(); got != tt.want {
+			t.Errorf("ServiceHint() of %v = %q, want %q", tt.info.SecurityTypes, got, tt.want)
+		}
+	}
+}
+
+// TestProbeVNC runs what Synscan does with WithVNCProbe against a server
+// on port 5901 of 127.0.0.1, the second VNC display.
+func TestProbeVNC(t *testing.T) {
+	versions := make(chan string, 1)
+	testServer(t, "127.0.0.1:5901", vncServer("RFB 003.008\n", []byte{1, VNCSecurityNone}, versions))
+	s := newTestScanner(WithVNCProbe())
+	s.dst = net.IPv4(127, 0, 0, 1)
+
+	result := &ScanResult{Ports: []PortResult{{Port: 22, State: "open"}, {Port: 5901, State: "open"}, {Port: 5902, State: "closed"}}}
+	s.probeVNC(context.Background(), result)
+	if p, _ := result.Port(5901); p.ServiceHint != "VNC-noauth" {
+		t.Errorf("ServiceHint of port 5901 = %q, want VNC-noauth", p.ServiceHint)
+	}
+	for _, port := range []layers.TCPPort{22, 5902} {
+		if p, _ := result.Port(port); p.ServiceHint != "" {
+			t.Errorf("ServiceHint of port %d = %q, want none", port, p.ServiceHint)
+		}
+	}
+	if len(versions) != 1 {
+		t.Errorf("%d connections, want 1", len(versions))
+	}
+}
+
+func TestParseRFBVersion(t *testing.T) {
+	tests := []struct {
+		banner       string
+		major, minor int
+		ok           bool
+	}{
+		{"RFB 003.008\n", 3, 8, true},
+		{"RFB 004.001\n", 4, 1, true},
+		{"RFB 003.008", 0, 0, false},
+		{"RFB 003,008\n", 0, 0, false},
+		{"RFB 00x.008\n", 0, 0, false},
+		{"SSH-2.0-Open", 0, 0, false},
+	}
+	for _, tt := range tests {
+		major, minor, ok := parseRFBVersion([]byte(tt.banner))
+		if major != tt.major || minor != tt.minor || ok != tt.ok {
+			t.Errorf("parseRFBVersion(%q) = %d, %d, %v, want %d, %d, %v", tt.banner, major, minor, ok, tt.major, tt.minor, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,185 @@
+// Package vuln correlates the open ports of scan results with known
+// vulnerabilities, from the port, the service and the banners found on it.
+package vuln
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/CyberRoute/scanme/scanme"
+)
+
+// defaultDB holds the rules VulnCorrelate matches unless WithVulnDB is set.
+// Rules without a banner only tell that a vulnerable service may be
+// exposed, their findings warrant a closer look rather than a verdict.
+//
+//go:embed vulndb.json
+var defaultDB []byte
+
+// VulnFinding is a known vulnerability an open port may be exposed to.
+// Evidence tells what matched.
+type VulnFinding struct {
+	CVE         string
+	Description string
+	CVSS        float64
+	Port        int
+	Evidence    string
+}
+
+// VulnOption configures VulnCorrelate.
+type VulnOption func(*vulnConfig)
+
+type vulnConfig struct {
+	dbPath  string
+	minCVSS float64
+}
+
+// WithVulnDB makes VulnCorrelate match the rules of the JSON file at path
+// instead of the embedded ones. The file holds an array of rules with the
+// fields port (0 for any), service, banner_regex, cve, description and
+// cvss, see vulndb.json.
+func WithVulnDB(path string) VulnOption {
+	return func(c *vulnConfig) {
+		c.dbPath = path
+	}
+}
+
+// WithMinCVSS leaves out the findings whose CVSS score is below score.
+func WithMinCVSS(score float64) VulnOption {
+	return func(c *vulnConfig) {
+		c.minCVSS = score
+	}
+}
+
+// rule is an entry of the vulnerability database. A port matches a rule
+// when it matches every field set among Port, Service and BannerRegex.
+type rule struct {
+	Port        int     `json:"port"`
+	Service     string  `json:"service"`
+	BannerRegex string  `json:"banner_regex"`
+	CVE         string  `json:"cve"`
+	Description string  `json:"description"`
+	CVSS        float64 `json:"cvss"`
+
+	banner *regexp.Regexp
+}
+
+// VulnCorrelate matches the open ports of result against the vulnerability
+// database, and returns the findings sorted by decreasing CVSS score, then
+// by port. Banners are read from the ServiceHint of the ports (see the
+// banner hook of config.ScanTemplate) and from their HTTP Server and
+// X-Powered-By headers (see scanme.WithHTTPFingerprinting); rules with a
+// banner regular expression never match ports without banners.
+func VulnCorrelate(result *scanme.ScanResult, opts ...VulnOption) ([]VulnFinding, error) {
+	var c vulnConfig
+	for _, opt := range opts {
+		opt(&c)
+	}
+	rules, err := loadRules(c.dbPath)
+	if err != nil {
+		return nil, err
+	}
+
+	var findings []VulnFinding
+	for _, p := range result.Ports {
+		if p.State != "open" {
+			continue
+		}
+		banner := portBanner(p)
+		for _, r := range rules {
+			if r.CVSS < c.minCVSS {
+				continue
+			}
+			if evidence, ok := r.match(p, banner); ok {
+				findings = append(findings, VulnFinding{
+					CVE:         r.CVE,
+					Description: r.Description,
+					CVSS:        r.CVSS,
+					Port:        int(p.Port),
+					Evidence:    evidence,
+				})
+			}
+		}
+	}
+	sort.SliceStable(findings, func(i, j int) bool {
+		if findings[i].CVSS != findings[j].CVSS {
+			return findings[i].CVSS > findings[j].CVSS
+		}
+		return findings[i].Port < findings[j].Port
+	})
+	return findings, nil
+}
+
+// loadRules parses the rules of the file at path, or the embedded rules
+// when path is empty.
+func loadRules(path string) ([]rule, error) {
+	data := defaultDB
+	if path != "" {
+		var err error
+		if data, err = os.ReadFile(path); err != nil {
+			return nil, err
+		}
+	}
+	var rules []rule
+	if err := json.Unmarshal(data, &rules); err != nil {
+		return nil, fmt.Errorf("vuln: parsing vulnerability database: %w", err)
+	}
+	for i := range rules {
+		r := &rules[i]
+		if r.Port == 0 && r.Service == "" && r.BannerRegex == "" {
+			return nil, fmt.Errorf("vuln: rule %s matches every port", r.CVE)
+		}
+		if r.BannerRegex != "" {
+			re, err := regexp.Compile(r.BannerRegex)
+			if err != nil {
+				return nil, fmt.Errorf("vuln: rule %s: invalid banner_regex: %v", r.CVE, err)
+			}
+			r.banner = re
+		}
+	}
+	return rules, nil
+}
+
+// match reports whether the open port p, carrying banner, matches r, and
+// what matched.
+func (r *rule) match(p scanme.PortResult, banner string) (evidence string, ok bool) {
+	if r.Port != 0 && r.Port != int(p.Port) {
+		return "", false
+	}
+	if r.Service != "" && !strings.EqualFold(r.Service, p.Service) {
+		return "", false
+	}
+	evidence = fmt.Sprintf("port %d open", p.Port)
+	if p.Service != "" {
+		evidence += " (" + p.Service + ")"
+	}
+	if r.banner != nil {
+		m := r.banner.FindString(banner)
+		if m == "" {
+			return "", false
+		}
+		evidence += fmt.Sprintf(", banner matches %q", m)
+	}
+	return evidence, true
+}
+
+// portBanner returns the banners found on p, one per line.
+func portBanner(p scanme.PortResult) string {
+	var lines []string
+	if p.ServiceHint != "" {
+		lines = append(lines, p.ServiceHint)
+	}
+	if fp := p.HTTPFingerprint; fp != nil {
+		for _, h := range []string{fp.ServerHeader, fp.PoweredBy} {
+			if h != "" {
+				lines = append(lines, h)
+			}
+		}
+	}
+	return strings.Join(lines, "\n")
+}
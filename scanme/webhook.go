@@ -0,0 +1,82 @@
+package scanme
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+const (
+	// webhookAttempts is the number of times a webhook delivery is tried.
+	webhookAttempts = 3
+	// webhookBackoff is the delay before the first retry, doubled for
+	// every later retry.
+	webhookBackoff = time.Second
+	// webhookTimeout bounds a single webhook request.
+	webhookTimeout = 10 * time.Second
+)
+
+// webhookSignatureHeader carries the HMAC-SHA256 of the request body, in
+// hex, when a secret is set with WithWebhookHMACSecret.
+const webhookSignatureHeader = "X-Scanme-Signature"
+
+var webhookClient = &http.Client{Timeout: webhookTimeout}
+
+// notifyWebhook posts result as JSON to the webhook URL, if one is set. A
+// failed delivery is logged and doesn't fail the scan.
+func (s *scanner) notifyWebhook(result *ScanResult) {
+	if s.webhookURL == "" {
+		return
+	}
+	body, err := json.Marshal(result)
+	if err != nil {
+		s.logger.Error("webhook: error encoding result", "err", err)
+		return
+	}
+
+	backoff := webhookBackoff
+	for attempt := 1; ; attempt++ {
+		err = s.postWebhook(body)
+		if err == nil {
+			return
+		}
+		if attempt == webhookAttempts {
+			break
+		}
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+	s.logger.Error("webhook: delivery failed", "url", s.webhookURL, "attempts", webhookAttempts, "err", err)
+}
+
+// postWebhook sends a single webhook request carrying body.
+func (s *scanner) postWebhook(body []byte) error {
+	req, err := http.NewRequest(http.MethodPost, s.webhookURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range s.webhookHeaders {
+		req.Header.Set(k, v)
+	}
+	if s.webhookSecret != "" {
+		mac := hmac.New(sha256.New, []byte(s.webhookSecret))
+		mac.Write(body)
+		req.Header.Set(webhookSignatureHeader, hex.EncodeToString(mac.Sum(nil)))
+	}
+
+	resp, err := webhookClient.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,324 @@
+package scanme
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/CyberRoute/scanme/scanme/intel"
+	"github.com/google/gopacket/layers"
+)
+
+// XMLNamespace is the namespace of the documents written by WriteXML, the
+// target namespace of XMLSchema.
+const XMLNamespace = "urn:scanme:scan-result:1"
+
+// The xml* types map a ScanResult to the elements of XMLSchema.
+type xmlScanResult struct {
+	XMLName   xml.Name        `xml:"urn:scanme:scan-result:1 scanResult"`
+	Target    string          `xml:"target"`
+	StartTime string          `xml:"startTime"`
+	EndTime   string          `xml:"endTime"`
+	Hostnames []string        `xml:"hostname"`
+	Stats     xmlScanStats    `xml:"stats"`
+	Ports     xmlPorts        `xml:"ports"`
+	GeoInfo   *xmlGeoLocation `xml:"geoInfo"`
+	ASNInfo   *xmlASNInfo     `xml:"asnInfo"`
+}
+
+type xmlScanStats struct {
+	PacketsSent     int    `xml:"packetsSent"`
+	PacketsReceived int    `xml:"packetsReceived"`
+	Duration        string `xml:"duration"`
+	RateLimitEvents int    `xml:"rateLimitEvents"`
+	MinLatency      string `xml:"minLatency"`
+	MaxLatency      string `xml:"maxLatency"`
+	MeanLatency     string `xml:"meanLatency"`
+	DNSTimeouts     int    `xml:"dnsTimeouts"`
+}
+
+type xmlPorts struct {
+	Ports []xmlPortResult `xml:"port"`
+}
+
+type xmlPortResult struct {
+	Number          uint16              `xml:"number"`
+	State           string              `xml:"state"`
+	Service         string              `xml:"service,omitempty"`
+	Latency         string              `xml:"latency,omitempty"`
+	ServiceHint     string              `xml:"serviceHint,omitempty"`
+	HTTPFingerprint *xmlHTTPFingerprint `xml:"httpFingerprint"`
+}
+
+type xmlHTTPFingerprint struct {
+	StatusCode            int      `xml:"statusCode"`
+	ServerHeader          string   `xml:"serverHeader,omitempty"`
+	PoweredBy             string   `xml:"poweredBy,omitempty"`
+	SetCookie             string   `xml:"setCookie,omitempty"`
+	ContentType           string   `xml:"contentType,omitempty"`
+	XFrameOptions         string   `xml:"xFrameOptions,omitempty"`
+	ContentSecurityPolicy string   `xml:"contentSecurityPolicy,omitempty"`
+	ViaHeader             string   `xml:"via,omitempty"`
+	HeaderOrder           []string `xml:"header"`
+	HeaderOrderHash       string   `xml:"headerOrderHash,omitempty"`
+}
+
+type xmlGeoLocation struct {
+	Country   string  `xml:"country,omitempty"`
+	City      string  `xml:"city,omitempty"`
+	Latitude  float64 `xml:"latitude"`
+	Longitude float64 `xml:"longitude"`
+	ASN       uint    `xml:"asn,omitempty"`
+	ISP       string  `xml:"isp,omitempty"`
+}
+
+type xmlASNInfo struct {
+	ASN       uint32 `xml:"asn"`
+	ASName    string `xml:"asName,omitempty"`
+	BGPPrefix string `xml:"bgpPrefix,omitempty"`
+	Country   string `xml:"country,omitempty"`
+	Registry  string `xml:"registry,omitempty"`
+	Allocated string `xml:"allocated,omitempty"`
+}
+
+// WriteXML writes result as an XML document in the XMLNamespace namespace,
+// valid against XMLSchema. Unlike WriteNmapXML, it keeps every field of the
+// result.
+func WriteXML(w io.Writer, result *ScanResult) error {
+	doc := xmlScanResult{
+		Target:    result.Target.String(),
+		StartTime: result.StartTime.Format(time.RFC3339Nano),
+		EndTime:   result.EndTime.Format(time.RFC3339Nano),
+		Hostnames: result.Hostnames,
+		Stats: xmlScanStats{
+			PacketsSent:     result.Stats.PacketsSent,
+			PacketsReceived: result.Stats.PacketsReceived,
+			Duration:        formatXSDuration(result.Stats.Duration),
+			RateLimitEvents: result.Stats.RateLimitEvents,
+			MinLatency:      formatXSDuration(result.Stats.MinLatency),
+			MaxLatency:      formatXSDuration(result.Stats.MaxLatency),
+			MeanLatency:     formatXSDuration(result.Stats.MeanLatency),
+			DNSTimeouts:     result.Stats.DNSTimeouts,
+		},
+	}
+	if len(doc.Hostnames) == 0 && result.Hostname != "" {
+		doc.Hostnames = []string{result.Hostname}
+	}
+	for _, p := range result.Ports {
+		port := xmlPortResult{
+			Number:      uint16(p.Port),
+			State:       p.State,
+			Service:     p.Service,
+			ServiceHint: p.ServiceHint,
+		}
+		if p.Latency != 0 {
+			port.Latency = formatXSDuration(p.Latency)
+		}
+		if fp := p.HTTPFingerprint; fp != nil {
+			port.HTTPFingerprint = &xmlHTTPFingerprint{
+				StatusCode:            fp.StatusCode,
+				ServerHeader:          fp.ServerHeader,
+				PoweredBy:             fp.PoweredBy,
+				SetCookie:             fp.SetCookie,
+				ContentType:           fp.ContentType,
+				XFrameOptions:         fp.XFrameOptions,
+				ContentSecurityPolicy: fp.ContentSecurityPolicy,
+				ViaHeader:             fp.ViaHeader,
+				HeaderOrder:           fp.HeaderOrder,
+				HeaderOrderHash:       fp.HeaderOrderHash,
+			}
+		}
+		doc.Ports.Ports = append(doc.Ports.Ports, port)
+	}
+	if g := result.GeoInfo; g != nil {
+		doc.GeoInfo = &xmlGeoLocation{
+			Country:   g.Country,
+			City:      g.City,
+			Latitude:  g.Latitude,
+			Longitude: g.Longitude,
+			ASN:       g.ASN,
+			ISP:       g.ISP,
+		}
+	}
+	if a := result.ASNInfo; a != nil {
+		doc.ASNInfo = &xmlASNInfo{
+			ASN:       a.ASN,
+			ASName:    a.ASName,
+			BGPPrefix: a.BGPPrefix,
+			Country:   a.Country,
+			Registry:  a.Registry,
+		}
+		if !a.Allocated.IsZero() {
+			doc.ASNInfo.Allocated = a.Allocated.Format(time.RFC3339)
+		}
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// ReadXML imports a document written by WriteXML. The document is validated
+// against XMLSchema first.
+func ReadXML(r io.Reader) (*ScanResult, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	if err := ValidateXML(data); err != nil {
+		return nil, err
+	}
+	var doc xmlScanResult
+	if err := xml.NewDecoder(bytes.NewReader(data)).Decode(&doc); err != nil {
+		return nil, err
+	}
+
+	result := &ScanResult{
+		Target:    net.ParseIP(doc.Target),
+		Hostnames: doc.Hostnames,
+	}
+	if result.Target == nil {
+		return nil, fmt.Errorf("xml: invalid target %q", doc.Target)
+	}
+	if len(doc.Hostnames) > 0 {
+		result.Hostname = doc.Hostnames[0]
+	}
+	if result.StartTime, err = time.Parse(time.RFC3339Nano, doc.StartTime); err != nil {
+		return nil, err
+	}
+	if result.EndTime, err = time.Parse(time.RFC3339Nano, doc.EndTime); err != nil {
+		return nil, err
+	}
+
+	result.Stats = ScanStats{
+		PacketsSent:     doc.Stats.PacketsSent,
+		PacketsReceived: doc.Stats.PacketsReceived,
+		RateLimitEvents: doc.Stats.RateLimitEvents,
+		DNSTimeouts:     doc.Stats.DNSTimeouts,
+	}
+	for _, d := range []struct {
+		dst *time.Duration
+		src string
+	}{
+		{&result.Stats.Duration, doc.Stats.Duration},
+		{&result.Stats.MinLatency, doc.Stats.MinLatency},
+		{&result.Stats.MaxLatency, doc.Stats.MaxLatency},
+		{&result.Stats.MeanLatency, doc.Stats.MeanLatency},
+	} {
+		if *d.dst, err = parseXSDuration(d.src); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, p := range doc.Ports.Ports {
+		port := PortResult{
+			Port:        layers.TCPPort(p.Number),
+			State:       p.State,
+			Service:     p.Service,
+			ServiceHint: p.ServiceHint,
+		}
+		if p.Latency != "" {
+			if port.Latency, err = parseXSDuration(p.Latency); err != nil {
+				return nil, err
+			}
+		}
+		if fp := p.HTTPFingerprint; fp != nil {
+			port.HTTPFingerprint = &HTTPFingerprint{
+				StatusCode:            fp.StatusCode,
+				ServerHeader:          fp.ServerHeader,
+				PoweredBy:             fp.PoweredBy,
+				SetCookie:             fp.SetCookie,
+				ContentType:           fp.ContentType,
+				XFrameOptions:         fp.XFrameOptions,
+				ContentSecurityPolicy: fp.ContentSecurityPolicy,
+				ViaHeader:             fp.ViaHeader,
+				HeaderOrder:           fp.HeaderOrder,
+				HeaderOrderHash:       fp.HeaderOrderHash,
+			}
+		}
+		result.Ports = append(result.Ports, port)
+	}
+	result.sortPorts()
+
+	if g := doc.GeoInfo; g != nil {
+		result.GeoInfo = &GeoLocation{
+			Country:   g.Country,
+			City:      g.City,
+			Latitude:  g.Latitude,
+			Longitude: g.Longitude,
+			ASN:       g.ASN,
+			ISP:       g.ISP,
+		}
+	}
+	if a := doc.ASNInfo; a != nil {
+		result.ASNInfo = &intel.ASNInfo{
+			ASN:       a.ASN,
+			ASName:    a.ASName,
+			BGPPrefix: a.BGPPrefix,
+			Country:   a.Country,
+			Registry:  a.Registry,
+		}
+		if a.Allocated != "" {
+			if result.ASNInfo.Allocated, err = time.Parse(time.RFC3339, a.Allocated); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return result, nil
+}
+
+// formatXSDuration formats d as an xs:duration in seconds, such as
+// "PT1.5S".
+func formatXSDuration(d time.Duration) string {
+	sign := ""
+	if d < 0 {
+		sign, d = "-", -d
+	}
+	s := strconv.FormatInt(int64(d/time.Second), 10)
+	if frac := d % time.Second; frac != 0 {
+		s += strings.TrimRight(fmt.Sprintf(".%09d", frac), "0")
+	}
+	return sign + "PT" + s + "S"
+}
+
+// parseXSDuration parses an xs:duration without years or months.
+func parseXSDuration(s string) (time.Duration, error) {
+	m := xsDurationRE.FindStringSubmatch(strings.TrimSpace(s))
+	if m == nil || m[0] == "P" || m[0] == "-P" || strings.HasSuffix(m[0], "T") {
+		return 0, fmt.Errorf("xml: invalid duration %q", s)
+	}
+	var d time.Duration
+	for i, unit := range []time.Duration{24 * time.Hour, time.Hour, time.Minute} {
+		if m[i+2] == "" {
+			continue
+		}
+		n, err := strconv.ParseInt(m[i+2], 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("xml: invalid duration %q", s)
+		}
+		d += time.Duration(n) * unit
+	}
+	if m[5] != "" {
+		sec, err := time.ParseDuration(m[5] + "s")
+		if err != nil {
+			return 0, fmt.Errorf("xml: invalid duration %q", s)
+		}
+		d += sec
+	}
+	if m[1] == "-" {
+		d = -d
+	}
+	return d, nil
+}
@@ -0,0 +1,267 @@
+package scanme
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// XMLSchema is the XML Schema of the documents written by WriteXML.
+//
+//go:embed schemas/result.xsd
+var XMLSchema []byte
+
+// xsDurationRE matches the xs:duration values without years and months.
+var xsDurationRE = regexp.MustCompile(`^(-)?P(?:(\d+)D)?(?:T(?:(\d+)H)?(?:(\d+)M)?(?:(\d+(?:\.\d+)?)S)?)?$`)
+
+// The xsd* types map the subset of XML Schema used by XMLSchema: named
+// complex types made of a sequence of elements, and simple types
+// restricting a built-in type with patterns or enumerations.
+type xsdSchema struct {
+	TargetNamespace string           `xml:"targetNamespace,attr"`
+	Elements        []xsdElement     `xml:"element"`
+	ComplexTypes    []xsdComplexType `xml:"complexType"`
+	SimpleTypes     []xsdSimpleType  `xml:"simpleType"`
+}
+
+type xsdElement struct {
+	Name      string `xml:"name,attr"`
+	Type      string `xml:"type,attr"`
+	MinOccurs string `xml:"minOccurs,attr"`
+	MaxOccurs string `xml:"maxOccurs,attr"`
+}
+
+type xsdComplexType struct {
+	Name     string       `xml:"name,attr"`
+	Sequence []xsdElement `xml:"sequence>element"`
+}
+
+type xsdSimpleType struct {
+	Name        string `xml:"name,attr"`
+	Restriction struct {
+		Base         string     `xml:"base,attr"`
+		Patterns     []xsdValue `xml:"pattern"`
+		Enumerations []xsdValue `xml:"enumeration"`
+	} `xml:"restriction"`
+}
+
+type xsdValue struct {
+	Value string `xml:"value,attr"`
+}
+
+// xmlNode is an element of the document being validated.
+type xmlNode struct {
+	name     xml.Name
+	text     strings.Builder
+	children []*xmlNode
+}
+
+// ValidateXML validates the XML document xmlData against XMLSchema. The
+// validator only supports the XML Schema features XMLSchema uses.
+func ValidateXML(xmlData []byte) error {
+	var schema xsdSchema
+	if err := xml.Unmarshal(XMLSchema, &schema); err != nil {
+		return fmt.Errorf("xml schema: %w", err)
+	}
+	root, err := parseXMLTree(xmlData)
+	if err != nil {
+		return err
+	}
+	for _, e := range schema.Elements {
+		if root.name == (xml.Name{Space: schema.TargetNamespace, Local: e.Name}) {
+			return schema.validate(root, e.Type)
+		}
+	}
+	return fmt.Errorf("xml: unexpected root element %s in namespace %q", root.name.Local, root.name.Space)
+}
+
+// parseXMLTree parses the elements of an XML document.
+func parseXMLTree(data []byte) (*xmlNode, error) {
+	dec := xml.NewDecoder(bytes.NewReader(data))
+	var root *xmlNode
+	var stack []*xmlNode
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			n := &xmlNode{name: t.Name}
+			if len(stack) > 0 {
+				parent := stack[len(stack)-1]
+				parent.children = append(parent.children, n)
+			} else if root != nil {
+				return nil, errors.New("xml: more than one root element")
+			} else {
+				root = n
+			}
+			stack = append(stack, n)
+		case xml.EndElement:
+			stack = stack[:len(stack)-1]
+		case xml.CharData:
+			if len(stack) > 0 {
+				stack[len(stack)-1].text.Write(t)
+			}
+		}
+	}
+	if root == nil {
+		return nil, errors.New("xml: no root element")
+	}
+	return root, nil
+}
+
+// validate checks that n is a valid element of type typ.
+func (s *xsdSchema) validate(n *xmlNode, typ string) error {
+	prefix, name, ok := strings.Cut(typ, ":")
+	if !ok {
+		name = prefix
+	} else if prefix == "xs" {
+		if len(n.children) > 0 {
+			return fmt.Errorf("xml: element %s has unexpected children", n.name.Local)
+		}
+		return validateBuiltin(n.name.Local, name, n.text.String())
+	}
+
+	for _, st := range s.SimpleTypes {
+		if st.Name == name {
+			return s.validateSimple(n, &st)
+		}
+	}
+	for _, ct := range s.ComplexTypes {
+		if ct.Name == name {
+			return s.validateComplex(n, &ct)
+		}
+	}
+	return fmt.Errorf("xml schema: unknown type %s", typ)
+}
+
+// validateSimple checks the value of n against the restrictions of st.
+func (s *xsdSchema) validateSimple(n *xmlNode, st *xsdSimpleType) error {
+	if err := s.validate(n, st.Restriction.Base); err != nil {
+		return err
+	}
+	value := n.text.String()
+	if st.Restriction.Base != "xs:string" {
+		value = strings.TrimSpace(value)
+	}
+	if p := st.Restriction.Patterns; len(p) > 0 {
+		matched := false
+		for _, pattern := range p {
+			re, err := regexp.Compile("^(?:" + pattern.Value + ")$")
+			if err != nil {
+				return fmt.Errorf("xml schema: %w", err)
+			}
+			matched = matched || re.MatchString(value)
+		}
+		if !matched {
+			return fmt.Errorf("xml: invalid %s value %q of element %s", st.Name, value, n.name.Local)
+		}
+	}
+	if e := st.Restriction.Enumerations; len(e) > 0 {
+		for _, allowed := range e {
+			if value == allowed.Value {
+				return nil
+			}
+		}
+		return fmt.Errorf("xml: invalid %s value %q of element %s", st.Name, value, n.name.Local)
+	}
+	return nil
+}
+
+// validateComplex checks that the children of n match the sequence of ct.
+func (s *xsdSchema) validateComplex(n *xmlNode, ct *xsdComplexType) error {
+	if strings.TrimSpace(n.text.String()) != "" {
+		return fmt.Errorf("xml: element %s has unexpected text", n.name.Local)
+	}
+	children := n.children
+	for _, e := range ct.Sequence {
+		min, max, err := e.occurs()
+		if err != nil {
+			return err
+		}
+		count := 0
+		for len(children) > 0 && (max < 0 || count < max) &&
+			children[0].name == (xml.Name{Space: s.TargetNamespace, Local: e.Name}) {
+			if err := s.validate(children[0], e.Type); err != nil {
+				return err
+			}
+			children = children[1:]
+			count++
+		}
+		if count < min {
+			return fmt.Errorf("xml: element %s is missing %s", n.name.Local, e.Name)
+		}
+	}
+	if len(children) > 0 {
+		return fmt.Errorf("xml: unexpected element %s in %s", children[0].name.Local, n.name.Local)
+	}
+	return nil
+}
+
+// occurs returns the minimum and maximum number of occurrences of e, a
+// negative maximum meaning unbounded.
+func (e *xsdElement) occurs() (int, int, error) {
+	min, max := 1, 1
+	var err error
+	if e.MinOccurs != "" {
+		if min, err = strconv.Atoi(e.MinOccurs); err != nil {
+			return 0, 0, fmt.Errorf("xml schema: invalid minOccurs of %s", e.Name)
+		}
+	}
+	switch e.MaxOccurs {
+	case "":
+	case "unbounded":
+		max = -1
+	default:
+		if max, err = strconv.Atoi(e.MaxOccurs); err != nil {
+			return 0, 0, fmt.Errorf("xml schema: invalid maxOccurs of %s", e.Name)
+		}
+	}
+	return min, max, nil
+}
+
+// validateBuiltin checks the value of element against the built-in XML
+// Schema type typ.
+func validateBuiltin(element, typ, value string) error {
+	if typ != "string" {
+		value = strings.TrimSpace(value)
+	}
+	var err error
+	switch typ {
+	case "string":
+	case "boolean":
+		switch value {
+		case "true", "false", "1", "0":
+		default:
+			err = errors.New("not a boolean")
+		}
+	case "int":
+		_, err = strconv.ParseInt(value, 10, 32)
+	case "unsignedInt":
+		_, err = strconv.ParseUint(value, 10, 32)
+	case "unsignedShort":
+		_, err = strconv.ParseUint(value, 10, 16)
+	case "double":
+		_, err = strconv.ParseFloat(value, 64)
+	case "dateTime":
+		_, err = time.Parse(time.RFC3339Nano, value)
+	case "duration":
+		_, err = parseXSDuration(value)
+	default:
+		return fmt.Errorf("xml schema: unsupported type xs:%s", typ)
+	}
+	if err != nil {
+		return fmt.Errorf("xml: invalid xs:%s value %q of element %s", typ, value, element)
+	}
+	return nil
+}
@@ -0,0 +1,287 @@
+package scanme
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"time"
+
+	"github.com/CyberRoute/scanme/scanme/intel"
+	"github.com/google/gopacket/layers"
+	"gopkg.in/yaml.v3"
+)
+
+// Duration is a time.Duration written to YAML as a human readable string,
+// such as "1.234ms", rather than a number of nanoseconds.
+type Duration time.Duration
+
+// MarshalYAML implements yaml.Marshaler.
+func (d Duration) MarshalYAML() (interface{}, error) {
+	return time.Duration(d).String(), nil
+}
+
+// UnmarshalYAML implements yaml.Unmarshaler.
+func (d *Duration) UnmarshalYAML(value *yaml.Node) error {
+	var s string
+	if err := value.Decode(&s); err != nil {
+		return err
+	}
+	parsed, err := time.ParseDuration(s)
+	if err != nil {
+		return fmt.Errorf("yaml: invalid duration %q on line %d", s, value.Line)
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+// The yaml* types map a ScanResult to the YAML documents of WriteYAML.
+type yamlScanResult struct {
+	Target    string           `yaml:"target"`
+	StartTime time.Time        `yaml:"start_time"`
+	EndTime   time.Time        `yaml:"end_time"`
+	Hostname  string           `yaml:"hostname,omitempty"`
+	Hostnames []string         `yaml:"hostnames,omitempty"`
+	Ports     []yamlPortResult `yaml:"ports"`
+	Stats     yamlScanStats    `yaml:"stats"`
+	GeoInfo   *yamlGeoLocation `yaml:"geo_info,omitempty"`
+	ASNInfo   *yamlASNInfo     `yaml:"asn_info,omitempty"`
+	Honeypot  float64          `yaml:"honeypot_score,omitempty"`
+	MPTCP     bool             `yaml:"mptcp_enabled,omitempty"`
+	Shodan    *yamlShodan      `yaml:"shodan_discrepancies,omitempty"`
+	Zone      []yamlDNSRecord  `yaml:"zone_transfer,omitempty"`
+}
+
+type yamlPortResult struct {
+	Port            uint16               `yaml:"port"`
+	State           string               `yaml:"state"`
+	Service         string               `yaml:"service,omitempty"`
+	Latency         Duration             `yaml:"latency,omitempty"`
+	HTTPFingerprint *yamlHTTPFingerprint `yaml:"http_fingerprint,omitempty"`
+	ServiceHint     string               `yaml:"service_hint,omitempty"`
+	MPTCP           bool                 `yaml:"mptcp,omitempty"`
+	MPTCPKey        uint64               `yaml:"mptcp_key,omitempty"`
+	Suspicious      bool                 `yaml:"suspicious,omitempty"`
+	CertExpiry      time.Time            `yaml:"cert_expiry,omitempty"`
+}
+
+type yamlScanStats struct {
+	PacketsSent         int      `yaml:"packets_sent"`
+	PacketsReceived     int      `yaml:"packets_received"`
+	Duration            Duration `yaml:"duration"`
+	RateLimitEvents     int      `yaml:"rate_limit_events"`
+	MinLatency          Duration `yaml:"min_latency"`
+	MaxLatency          Duration `yaml:"max_latency"`
+	MeanLatency         Duration `yaml:"mean_latency"`
+	DNSTimeouts         int      `yaml:"dns_timeouts"`
+	PacketsDroppedQueue int      `yaml:"packets_dropped_queue,omitempty"`
+	DuplicatesFiltered  int      `yaml:"duplicates_filtered,omitempty"`
+	PeakTxKBps          float64  `yaml:"peak_tx_kbps,omitempty"`
+	PeakRxKBps          float64  `yaml:"peak_rx_kbps,omitempty"`
+	AvgTxKBps           float64  `yaml:"avg_tx_kbps,omitempty"`
+}
+
+type yamlHTTPFingerprint struct {
+	StatusCode            int      `yaml:"status_code"`
+	ServerHeader          string   `yaml:"server,omitempty"`
+	PoweredBy             string   `yaml:"powered_by,omitempty"`
+	SetCookie             string   `yaml:"set_cookie,omitempty"`
+	ContentType           string   `yaml:"content_type,omitempty"`
+	XFrameOptions         string   `yaml:"x_frame_options,omitempty"`
+	ContentSecurityPolicy string   `yaml:"content_security_policy,omitempty"`
+	ViaHeader             string   `yaml:"via,omitempty"`
+	HeaderOrder           []string `yaml:"header_order,omitempty"`
+	HeaderOrderHash       string   `yaml:"header_order_hash,omitempty"`
+}
+
+type yamlGeoLocation struct {
+	Country   string  `yaml:"country,omitempty"`
+	City      string  `yaml:"city,omitempty"`
+	Latitude  float64 `yaml:"latitude"`
+	Longitude float64 `yaml:"longitude"`
+	ASN       uint    `yaml:"asn,omitempty"`
+	ISP       string  `yaml:"isp,omitempty"`
+}
+
+type yamlASNInfo struct {
+	ASN       uint32    `yaml:"asn"`
+	ASName    string    `yaml:"as_name,omitempty"`
+	BGPPrefix string    `yaml:"bgp_prefix,omitempty"`
+	Country   string    `yaml:"country,omitempty"`
+	Registry  string    `yaml:"registry,omitempty"`
+	Allocated time.Time `yaml:"allocated,omitempty"`
+}
+
+type yamlShodan struct {
+	OnlyShodan []layers.TCPPort `yaml:"only_shodan,omitempty"`
+	OnlyScan   []layers.TCPPort `yaml:"only_scan,omitempty"`
+}
+
+type yamlDNSRecord struct {
+	Name  string `yaml:"name"`
+	Type  string `yaml:"type"`
+	TTL   string `yaml:"ttl"`
+	Value string `yaml:"value"`
+}
+
+// WriteYAML writes result as a YAML document, for Ansible playbooks,
+// Kubernetes operators and other YAML consumers. Durations are written as
+// strings such as "1.234ms".
+func WriteYAML(w io.Writer, result *ScanResult) error {
+	doc := yamlScanResult{
+		Target:    result.Target.String(),
+		StartTime: result.StartTime,
+		EndTime:   result.EndTime,
+		Hostname:  result.Hostname,
+		Hostnames: result.Hostnames,
+		Honeypot:  result.HoneypotScore,
+		MPTCP:     result.MPTCPEnabled,
+		Ports:     make([]yamlPortResult, 0, len(result.Ports)),
+		Stats: yamlScanStats{
+			PacketsSent:         result.Stats.PacketsSent,
+			PacketsReceived:     result.Stats.PacketsReceived,
+			Duration:            Duration(result.Stats.Duration),
+			RateLimitEvents:     result.Stats.RateLimitEvents,
+			MinLatency:          Duration(result.Stats.MinLatency),
+			MaxLatency:          Duration(result.Stats.MaxLatency),
+			MeanLatency:         Duration(result.Stats.MeanLatency),
+			DNSTimeouts:         result.Stats.DNSTimeouts,
+			PacketsDroppedQueue: result.Stats.PacketsDroppedQueue,
+			DuplicatesFiltered:  result.Stats.DuplicatesFiltered,
+			PeakTxKBps:          result.Stats.PeakTxKBps,
+			PeakRxKBps:          result.Stats.PeakRxKBps,
+			AvgTxKBps:           result.Stats.AvgTxKBps,
+		},
+	}
+	for _, p := range result.Ports {
+		port := yamlPortResult{
+			Port:        uint16(p.Port),
+			State:       p.State,
+			Service:     p.Service,
+			Latency:     Duration(p.Latency),
+			ServiceHint: p.ServiceHint,
+			MPTCP:       p.MPTCP,
+			MPTCPKey:    p.MPTCPKey,
+			Suspicious:  p.Suspicious,
+			CertExpiry:  p.CertExpiry,
+		}
+		if fp := p.HTTPFingerprint; fp != nil {
+			port.HTTPFingerprint = &yamlHTTPFingerprint{
+				StatusCode:            fp.StatusCode,
+				ServerHeader:          fp.ServerHeader,
+				PoweredBy:             fp.PoweredBy,
+				SetCookie:             fp.SetCookie,
+				ContentType:           fp.ContentType,
+				XFrameOptions:         fp.XFrameOptions,
+				ContentSecurityPolicy: fp.ContentSecurityPolicy,
+				ViaHeader:             fp.ViaHeader,
+				HeaderOrder:           fp.HeaderOrder,
+				HeaderOrderHash:       fp.HeaderOrderHash,
+			}
+		}
+		doc.Ports = append(doc.Ports, port)
+	}
+	if g := result.GeoInfo; g != nil {
+		geo := yamlGeoLocation(*g)
+		doc.GeoInfo = &geo
+	}
+	if a := result.ASNInfo; a != nil {
+		asn := yamlASNInfo(*a)
+		doc.ASNInfo = &asn
+	}
+	if d := result.ShodanDiscrepancies; d != nil {
+		shodan := yamlShodan(*d)
+		doc.Shodan = &shodan
+	}
+	for _, r := range result.ZoneTransfer {
+		doc.Zone = append(doc.Zone, yamlDNSRecord(r))
+	}
+
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	if err := enc.Encode(doc); err != nil {
+		return err
+	}
+	return enc.Close()
+}
+
+// ReadYAML imports a document written by WriteYAML.
+func ReadYAML(r io.Reader) (*ScanResult, error) {
+	var doc yamlScanResult
+	if err := yaml.NewDecoder(r).Decode(&doc); err != nil {
+		return nil, err
+	}
+
+	result := &ScanResult{
+		Target:    net.ParseIP(doc.Target),
+		StartTime: doc.StartTime,
+		EndTime:   doc.EndTime,
+		Hostname:  doc.Hostname,
+		Hostnames: doc.Hostnames,
+		Ports:     make([]PortResult, 0, len(doc.Ports)),
+		Stats: ScanStats{
+			PacketsSent:         doc.Stats.PacketsSent,
+			PacketsReceived:     doc.Stats.PacketsReceived,
+			Duration:            time.Duration(doc.Stats.Duration),
+			RateLimitEvents:     doc.Stats.RateLimitEvents,
+			MinLatency:          time.Duration(doc.Stats.MinLatency),
+			MaxLatency:          time.Duration(doc.Stats.MaxLatency),
+			MeanLatency:         time.Duration(doc.Stats.MeanLatency),
+			DNSTimeouts:         doc.Stats.DNSTimeouts,
+			PacketsDroppedQueue: doc.Stats.PacketsDroppedQueue,
+			DuplicatesFiltered:  doc.Stats.DuplicatesFiltered,
+			PeakTxKBps:          doc.Stats.PeakTxKBps,
+			PeakRxKBps:          doc.Stats.PeakRxKBps,
+			AvgTxKBps:           doc.Stats.AvgTxKBps,
+		},
+	}
+	if result.Target == nil {
+		return nil, fmt.Errorf("yaml: invalid target %q", doc.Target)
+	}
+	result.HoneypotScore = doc.Honeypot
+	result.MPTCPEnabled = doc.MPTCP
+	for _, p := range doc.Ports {
+		port := PortResult{
+			Port:        layers.TCPPort(p.Port),
+			State:       p.State,
+			Service:     p.Service,
+			Latency:     time.Duration(p.Latency),
+			ServiceHint: p.ServiceHint,
+			MPTCP:       p.MPTCP,
+			MPTCPKey:    p.MPTCPKey,
+			Suspicious:  p.Suspicious,
+			CertExpiry:  p.CertExpiry,
+		}
+		if fp := p.HTTPFingerprint; fp != nil {
+			port.HTTPFingerprint = &HTTPFingerprint{
+				StatusCode:            fp.StatusCode,
+				ServerHeader:          fp.ServerHeader,
+				PoweredBy:             fp.PoweredBy,
+				SetCookie:             fp.SetCookie,
+				ContentType:           fp.ContentType,
+				XFrameOptions:         fp.XFrameOptions,
+				ContentSecurityPolicy: fp.ContentSecurityPolicy,
+				ViaHeader:             fp.ViaHeader,
+				HeaderOrder:           fp.HeaderOrder,
+				HeaderOrderHash:       fp.HeaderOrderHash,
+			}
+		}
+		result.Ports = append(result.Ports, port)
+	}
+	result.sortPorts()
+	if g := doc.GeoInfo; g != nil {
+		geo := GeoLocation(*g)
+		result.GeoInfo = &geo
+	}
+	if a := doc.ASNInfo; a != nil {
+		asn := intel.ASNInfo(*a)
+		result.ASNInfo = &asn
+	}
+	if d := doc.Shodan; d != nil {
+		shodan := intel.ShodanDiscrepancies(*d)
+		result.ShodanDiscrepancies = &shodan
+	}
+	for _, r := range doc.Zone {
+		result.ZoneTransfer = append(result.ZoneTransfer, DNSRecord(r))
+	}
+	return result, nil
+}
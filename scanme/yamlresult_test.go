@@ -0,0 +1,184 @@
+package scanme
+
+import (
+	"bytes"
+	"encoding/json"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/CyberRoute/scanme/scanme/intel"
+	"github.com/google/gopacket/layers"
+	"gopkg.in/yaml.v3"
+)
+
+// fullScanResult returns a ScanResult with every field WriteYAML writes set.
+func fullScanResult() *ScanResult {
+	start := time.Date(2024, 3, 5, 10, 12, 1, 500, time.UTC)
+	return &ScanResult{
+		Target:    net.ParseIP("192.0.2.10"),
+		StartTime: start,
+		EndTime:   start.Add(2 * time.Second),
+		Hostname:  "www.example.test",
+		Hostnames: []string{"www.example.test", "example.test"},
+		Ports: []PortResult{
+			{
+				Port:    80,
+				State:   "open",
+				Service: "http",
+				Latency: 1234 * time.Microsecond,
+				HTTPFingerprint: &HTTPFingerprint{
+					StatusCode:   200,
+					ServerHeader: "nginx/1.25.3",
+					ContentType:  "text/html",
+					HeaderOrder:  []string{"Server", "Date", "Content-Type"},
+				},
+			},
+			{Port: 161, State: "filtered", ServiceHint: "SNMP-open"},
+			{
+				Port:       443,
+				State:      "open",
+				Service:    "https",
+				Latency:    2 * time.Millisecond,
+				MPTCP:      true,
+				MPTCPKey:   0xdeadbeef,
+				CertExpiry: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC),
+			},
+			{Port: 31337, State: "open", Suspicious: true},
+		},
+		Stats: ScanStats{
+			PacketsSent:         65535,
+			PacketsReceived:     4,
+			Duration:            2 * time.Second,
+			RateLimitEvents:     1,
+			MinLatency:          1234 * time.Microsecond,
+			MaxLatency:          2 * time.Millisecond,
+			MeanLatency:         1617 * time.Microsecond,
+			DNSTimeouts:         1,
+			PacketsDroppedQueue: 3,
+			DuplicatesFiltered:  2,
+			PeakTxKBps:          512.5,
+			PeakRxKBps:          1.25,
+			AvgTxKBps:           256,
+		},
+		GeoInfo: &GeoLocation{Country: "NL", City: "Amsterdam", Latitude: 52.37, Longitude: 4.89, ASN: 64496, ISP: "Example"},
+		ASNInfo: &intel.ASNInfo{
+			ASN:       64496,
+			ASName:    "EXAMPLE-AS",
+			BGPPrefix: "192.0.2.0/24",
+			Country:   "NL",
+			Registry:  "ripencc",
+			Allocated: time.Date(2001, 9, 4, 0, 0, 0, 0, time.UTC),
+		},
+		MPTCPEnabled:        true,
+		HoneypotScore:       0.25,
+		ShodanDiscrepancies: &intel.ShodanDiscrepancies{OnlyShodan: []layers.TCPPort{22}, OnlyScan: []layers.TCPPort{31337}},
+		ZoneTransfer: []DNSRecord{
+			{Name: "example.test.", Type: "A", TTL: "3600", Value: "192.0.2.10"},
+		},
+	}
+}
+
+// TestYAMLRoundTripMatchesJSON checks that a ScanResult read back from YAML
+// has the same JSON representation as the one written.
+func TestYAMLRoundTripMatchesJSON(t *testing.T) {
+	tests := []struct {
+		name   string
+		result *ScanResult
+	}{
+		{"full", fullScanResult()},
+		{"minimal", &ScanResult{Target: net.ParseIP("2001:db8::1"), Ports: []PortResult{}}},
+	}
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		if err := WriteYAML(&buf, tt.result); err != nil {
+			t.Fatalf("%s: WriteYAML() error = %v", tt.name, err)
+		}
+		got, err := ReadYAML(&buf)
+		if err != nil {
+			t.Fatalf("%s: ReadYAML() error = %v", tt.name, err)
+		}
+
+		want, err := json.Marshal(tt.result)
+		if err != nil {
+			t.Fatal(err)
+		}
+		gotJSON, err := json.Marshal(got)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(gotJSON, want) {
+			t.Errorf("%s: JSON after a YAML round trip =\n%s\nwant\n%s", tt.name, gotJSON, want)
+		}
+	}
+}
+
+// TestYAMLFromJSON checks that a ScanResult decoded from JSON writes the same
+// YAML as the original.
+func TestYAMLFromJSON(t *testing.T) {
+	result := fullScanResult()
+	data, err := json.Marshal(result)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var fromJSON ScanResult
+	if err := json.Unmarshal(data, &fromJSON); err != nil {
+		t.Fatal(err)
+	}
+
+	var want, got bytes.Buffer
+	if err := WriteYAML(&want, result); err != nil {
+		t.Fatal(err)
+	}
+	if err := WriteYAML(&got, &fromJSON); err != nil {
+		t.Fatal(err)
+	}
+	if got.String() != want.String() {
+		t.Errorf("YAML of the JSON round trip =\n%s\nwant\n%s", got.String(), want.String())
+	}
+}
+
+func TestYAMLDurationsAreStrings(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteYAML(&buf, fullScanResult()); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"latency: 1.234ms", "duration: 2s", "mean_latency: 1.617ms"} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("YAML does not contain %q:\n%s", want, buf.String())
+		}
+	}
+}
+
+func TestReadYAMLErrors(t *testing.T) {
+	tests := []struct {
+		name, doc string
+	}{
+		{"invalid duration", "target: 192.0.2.1\nstats:\n  duration: 2 seconds\n"},
+		{"invalid target", "target: example.test\n"},
+		{"malformed", "target: [\n"},
+	}
+	for _, tt := range tests {
+		if _, err := ReadYAML(strings.NewReader(tt.doc)); err == nil {
+			t.Errorf("%s: ReadYAML() error = nil", tt.name)
+		}
+	}
+}
+
+func TestDurationYAML(t *testing.T) {
+	data, err := yaml.Marshal(Duration(1500 * time.Microsecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.TrimSpace(string(data)); got != "1.5ms" {
+		t.Errorf("Marshal = %q, want 1.5ms", got)
+	}
+	var d Duration
+	if err := yaml.Unmarshal(data, &d); err != nil {
+		t.Fatal(err)
+	}
+	if time.Duration(d) != 1500*time.Microsecond {
+		t.Errorf("Unmarshal = %v, want 1.5ms", time.Duration(d))
+	}
+}